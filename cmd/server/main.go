@@ -68,8 +68,6 @@ func setKiroIncognitoMode(cfg *config.Config, useIncognito, noIncognito bool) {
 // It parses command-line flags, loads configuration, and starts the appropriate
 // service based on the provided flags (login, codex-login, or server mode).
 func main() {
-	fmt.Printf("CLIProxyAPI Version: %s, Commit: %s, BuiltAt: %s\n", buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate)
-
 	// Command-line flags to control the application's behavior.
 	var login bool
 	var codexLogin bool
@@ -94,6 +92,9 @@ func main() {
 	var kiroIDCStartURL string
 	var kiroIDCRegion string
 	var kiroIDCFlow string
+	var kiroExportTokens bool
+	var kiroExportUnmasked bool
+	var kiroImportTokens string
 	var githubCopilotLogin bool
 	var codeBuddyLogin bool
 	var projectID string
@@ -133,6 +134,9 @@ func main() {
 	flag.StringVar(&kiroIDCStartURL, "kiro-idc-start-url", "", "IDC start URL (required with --kiro-idc-login)")
 	flag.StringVar(&kiroIDCRegion, "kiro-idc-region", "", "IDC region (default: us-east-1)")
 	flag.StringVar(&kiroIDCFlow, "kiro-idc-flow", "", "IDC flow type: authcode (default) or device")
+	flag.BoolVar(&kiroExportTokens, "kiro-export-tokens", false, "Write all Kiro tokens in the auth directory to stdout as JSON (secrets masked)")
+	flag.BoolVar(&kiroExportUnmasked, "kiro-export-unmasked", false, "With --kiro-export-tokens, write secrets in clear text so the export can be imported")
+	flag.StringVar(&kiroImportTokens, "kiro-import-tokens", "", "Import Kiro tokens from a --kiro-export-tokens file (\"-\" reads stdin)")
 	flag.BoolVar(&githubCopilotLogin, "github-copilot-login", false, "Login to GitHub Copilot using device flow")
 	flag.BoolVar(&codeBuddyLogin, "codebuddy-login", false, "Login to CodeBuddy using browser OAuth flow")
 	flag.StringVar(&projectID, "project_id", "", "Project ID (Gemini only, not required)")
@@ -174,6 +178,12 @@ func main() {
 	// Parse the command-line flags.
 	flag.Parse()
 
+	// --kiro-export-tokens writes its document to stdout, so keep stdout free of
+	// the banner and logs.
+	if !kiroExportTokens {
+		fmt.Printf("CLIProxyAPI Version: %s, Commit: %s, BuiltAt: %s\n", buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate)
+	}
+
 	// Core application variables.
 	var err error
 	var cfg *config.Config
@@ -477,6 +487,9 @@ func main() {
 		log.Errorf("failed to configure log output: %v", err)
 		return
 	}
+	if kiroExportTokens && !cfg.LoggingToFile {
+		log.SetOutput(os.Stderr)
+	}
 
 	log.Infof("CLIProxyAPI Version: %s, Commit: %s, BuiltAt: %s", buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate)
 
@@ -585,6 +598,10 @@ func main() {
 		setKiroIncognitoMode(cfg, useIncognito, noIncognito)
 		kiro.InitFingerprintConfig(cfg)
 		cmd.DoKiroIDCLogin(cfg, options, kiroIDCStartURL, kiroIDCRegion, kiroIDCFlow)
+	} else if kiroExportTokens {
+		cmd.DoKiroExportTokens(cfg, kiroExportUnmasked)
+	} else if kiroImportTokens != "" {
+		cmd.DoKiroImportTokens(cfg, kiroImportTokens)
 	} else {
 		// In cloud deploy mode without config file, just wait for shutdown signals
 		if isCloudDeploy && !configFileExists {
@@ -0,0 +1,47 @@
+// Command plugin_template is a sample translator plugin. Build it with
+//
+//	go build -buildmode=plugin -o template.so ./examples/plugin_template
+//
+// using the same Go toolchain and module versions as the proxy, then place the
+// .so file in the directory configured as translator-plugin-dir.
+package main
+
+import (
+	"context"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/translator/plugin"
+	"github.com/tidwall/sjson"
+)
+
+// TranslatorPlugin is the symbol the proxy looks up when loading the plugin.
+var TranslatorPlugin plugin.TranslatorFunc = templateTranslator{}
+
+// templateTranslator forwards OpenAI chat requests to a hypothetical
+// "acme" backend that expects the same body plus a client tag.
+type templateTranslator struct{}
+
+func (templateTranslator) Formats() (from, to translator.Format) {
+	return translator.FormatOpenAI, translator.FromString("acme")
+}
+
+func (templateTranslator) TranslateRequest(model string, rawJSON []byte, stream bool) []byte {
+	out, _ := sjson.SetBytes(rawJSON, "model", model)
+	out, _ = sjson.SetBytes(out, "stream", stream)
+	out, _ = sjson.SetBytes(out, "client", "cliproxy")
+	return out
+}
+
+func (templateTranslator) Response() translator.ResponseTransform {
+	return translator.ResponseTransform{
+		Stream: func(_ context.Context, _ string, _, _, rawJSON []byte, _ *any) [][]byte {
+			return [][]byte{rawJSON}
+		},
+		NonStream: func(_ context.Context, _ string, _, _, rawJSON []byte, _ *any) []byte {
+			return rawJSON
+		},
+	}
+}
+
+// main is required for the package to build as a regular binary; plugins never call it.
+func main() {}
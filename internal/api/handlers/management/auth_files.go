@@ -3422,6 +3422,7 @@ func (h *Handler) RequestKiroToken(c *gin.Context) {
 			for time.Now().Before(deadline) {
 				select {
 				case <-ctx.Done():
+					kiroauth.AuditDevicePollAbandoned(regResp.ClientID, ctx.Err())
 					SetOAuthSessionError(state, "Authorization cancelled")
 					return
 				case <-time.After(interval):
@@ -3436,6 +3437,7 @@ func (h *Handler) RequestKiroToken(c *gin.Context) {
 							continue
 						}
 						log.Errorf("Token creation failed: %v", errToken)
+						kiroauth.AuditDevicePollAbandoned(regResp.ClientID, errToken)
 						SetOAuthSessionError(state, "Token creation failed")
 						return
 					}
@@ -3486,6 +3488,7 @@ func (h *Handler) RequestKiroToken(c *gin.Context) {
 				}
 			}
 
+			kiroauth.AuditDevicePollAbandoned(regResp.ClientID, nil)
 			SetOAuthSessionError(state, "Authorization timed out")
 		}()
 
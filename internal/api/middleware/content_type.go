@@ -0,0 +1,96 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains the JSON content-type guard for request bodies.
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+)
+
+// maxLeadingWhitespace bounds how far RequireJSONContentType scans for the first
+// non-whitespace byte of a request body.
+const maxLeadingWhitespace = 4096
+
+// RequireJSONContentType returns middleware that rejects requests carrying a body
+// unless the Content-Type is application/json (or a +json type) and the body
+// starts with '{' or '[' after optional whitespace. Rejected requests receive
+// 415 Unsupported Media Type with a JSON error body. Requests without a body pass
+// through, and the body is left intact for the next handler.
+func RequireJSONContentType() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !isJSONMediaType(r.Header.Get("Content-Type")) {
+				writeUnsupportedMediaType(w, "Content-Type must be application/json")
+				return
+			}
+
+			br := bufio.NewReader(r.Body)
+			var prefix []byte
+			first, errRead := br.ReadByte()
+			for errRead == nil && isJSONWhitespace(first) && len(prefix) < maxLeadingWhitespace {
+				prefix = append(prefix, first)
+				first, errRead = br.ReadByte()
+			}
+			if errRead == nil {
+				_ = br.UnreadByte()
+			}
+			if errRead == io.EOF && len(prefix) == 0 {
+				// Body was empty despite a non-zero or unknown length; let the handler decide.
+				r.Body = readCloser{Reader: br, Closer: r.Body}
+				next.ServeHTTP(w, r)
+				return
+			}
+			if errRead != nil || (first != '{' && first != '[') {
+				writeUnsupportedMediaType(w, "request body must be a JSON object or array")
+				return
+			}
+
+			r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(prefix), br), Closer: r.Body}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func isJSONMediaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func isJSONWhitespace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+func writeUnsupportedMediaType(w http.ResponseWriter, message string) {
+	writeJSONError(w, http.StatusUnsupportedMediaType, "invalid_request_error", message)
+}
+
+// writeJSONError writes an OpenAI-style error body with the given status.
+func writeJSONError(w http.ResponseWriter, status int, errType, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(handlers.ErrorResponse{
+		Error: handlers.ErrorDetail{
+			Message: message,
+			Type:    errType,
+		},
+	})
+}
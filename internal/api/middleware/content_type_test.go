@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireJSONContentType(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		wantStatus  int
+	}{
+		{"json object", http.MethodPost, "application/json", `{"model":"x"}`, http.StatusOK},
+		{"json with charset and whitespace", http.MethodPost, "application/json; charset=utf-8", "\n  [1,2]", http.StatusOK},
+		{"vendor json", http.MethodPost, "application/vnd.api+json", `{}`, http.StatusOK},
+		{"json type with non-json body", http.MethodPost, "application/json", `model=x`, http.StatusUnsupportedMediaType},
+		{"form encoded", http.MethodPost, "application/x-www-form-urlencoded", `model=x`, http.StatusUnsupportedMediaType},
+		{"multipart", http.MethodPost, "multipart/form-data; boundary=abc", "--abc\r\n\r\n--abc--", http.StatusUnsupportedMediaType},
+		{"missing content type", http.MethodPost, "", `{"model":"x"}`, http.StatusUnsupportedMediaType},
+		{"no body", http.MethodGet, "", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotBody string
+			handler := RequireJSONContentType()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, _ := io.ReadAll(r.Body)
+				gotBody = string(data)
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			var body io.Reader = http.NoBody
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			req := httptest.NewRequest(tt.method, "/v1/chat/completions", body)
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if gotBody != tt.body {
+					t.Fatalf("handler body = %q, want %q", gotBody, tt.body)
+				}
+				return
+			}
+			var errResp struct {
+				Error struct {
+					Message string `json:"message"`
+					Type    string `json:"type"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &errResp); err != nil || errResp.Error.Message == "" {
+				t.Fatalf("error body = %q, want JSON error (%v)", rec.Body.String(), err)
+			}
+		})
+	}
+}
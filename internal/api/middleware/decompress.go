@@ -0,0 +1,55 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains transparent decompression of gzip request bodies.
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxDecompressedBodySize caps the size of a decompressed request body to guard
+// against decompression bombs.
+var maxDecompressedBodySize int64 = 64 << 20
+
+// DecompressRequestBody returns middleware that decompresses request bodies sent
+// with Content-Encoding: gzip. The body is replaced with the decompressed bytes,
+// Content-Length is updated to the decompressed size and the Content-Encoding
+// header is removed. A body that is not valid gzip is rejected with 400, and one
+// that decompresses to more than 64 MiB with 413. Other requests pass through.
+func DecompressRequestBody() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody || !strings.EqualFold(strings.TrimSpace(r.Header.Get("Content-Encoding")), "gzip") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			reader, errReader := gzip.NewReader(r.Body)
+			if errReader != nil {
+				writeJSONError(w, http.StatusBadRequest, "invalid_request_error", "invalid gzip request body")
+				return
+			}
+			decompressed, errRead := io.ReadAll(io.LimitReader(reader, maxDecompressedBodySize+1))
+			_ = reader.Close()
+			_ = r.Body.Close()
+			if errRead != nil {
+				writeJSONError(w, http.StatusBadRequest, "invalid_request_error", "invalid gzip request body")
+				return
+			}
+			if int64(len(decompressed)) > maxDecompressedBodySize {
+				writeJSONError(w, http.StatusRequestEntityTooLarge, "invalid_request_error", "decompressed request body too large")
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(decompressed))
+			r.ContentLength = int64(len(decompressed))
+			r.Header.Set("Content-Length", strconv.Itoa(len(decompressed)))
+			r.Header.Del("Content-Encoding")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func gzipBytes(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(data)); err != nil {
+		t.Fatalf("gzip write: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("gzip close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+type decompressCapture struct {
+	body          string
+	contentLength int64
+	encoding      string
+	called        bool
+}
+
+func newDecompressHandler(capture *decompressCapture) http.Handler {
+	return DecompressRequestBody()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		capture.body = string(data)
+		capture.contentLength = r.ContentLength
+		capture.encoding = r.Header.Get("Content-Encoding")
+		capture.called = true
+	}))
+}
+
+func TestDecompressRequestBody_ValidGzip(t *testing.T) {
+	const payload = `{"model":"gpt-4","messages":[]}`
+	var capture decompressCapture
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(gzipBytes(t, payload)))
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	newDecompressHandler(&capture).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if capture.body != payload {
+		t.Fatalf("body = %q, want %q", capture.body, payload)
+	}
+	if capture.contentLength != int64(len(payload)) {
+		t.Fatalf("ContentLength = %d, want %d", capture.contentLength, len(payload))
+	}
+	if capture.encoding != "" {
+		t.Fatalf("Content-Encoding = %q, want it removed", capture.encoding)
+	}
+}
+
+func TestDecompressRequestBody_InvalidGzip(t *testing.T) {
+	var capture decompressCapture
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader("not gzip"))
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	newDecompressHandler(&capture).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if capture.called {
+		t.Fatal("next handler should not be called for invalid gzip")
+	}
+}
+
+func TestDecompressRequestBody_TruncatedGzip(t *testing.T) {
+	compressed := gzipBytes(t, strings.Repeat("a", 1024))
+	var capture decompressCapture
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(compressed[:len(compressed)-6]))
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	newDecompressHandler(&capture).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestDecompressRequestBody_TooLarge(t *testing.T) {
+	original := maxDecompressedBodySize
+	maxDecompressedBodySize = 16
+	t.Cleanup(func() { maxDecompressedBodySize = original })
+
+	var capture decompressCapture
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(gzipBytes(t, strings.Repeat("a", 17))))
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	newDecompressHandler(&capture).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestDecompressRequestBody_NonGzipPassesThrough(t *testing.T) {
+	const payload = `{"model":"gpt-4"}`
+	var capture decompressCapture
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(payload))
+	rec := httptest.NewRecorder()
+	newDecompressHandler(&capture).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || capture.body != payload {
+		t.Fatalf("status = %d body = %q, want 200 and %q", rec.Code, capture.body, payload)
+	}
+	if capture.contentLength != int64(len(payload)) {
+		t.Fatalf("ContentLength = %d, want %d", capture.contentLength, len(payload))
+	}
+}
@@ -0,0 +1,142 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains per-client-IP request rate limiting.
+package middleware
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// ipLimiterIdleTTL is how long a client IP may stay idle before its limiter is evicted.
+	ipLimiterIdleTTL = 10 * time.Minute
+	// ipLimiterSweepInterval bounds how often stale limiters are swept.
+	ipLimiterSweepInterval = time.Minute
+)
+
+// RateLimiter decides whether a request identified by key may proceed. When it
+// may not, retryAfter tells the client how long to wait. An error means the
+// limiter could not decide; the middleware then lets the request through.
+type RateLimiter interface {
+	Allow(ctx context.Context, key string) (allowed bool, retryAfter time.Duration, err error)
+}
+
+type ipLimiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen atomic.Int64 // unix nanoseconds
+}
+
+type ipRateLimiter struct {
+	limiters  sync.Map // client IP -> *ipLimiterEntry
+	rps       rate.Limit
+	burst     int
+	lastSweep atomic.Int64
+	now       func() time.Time
+}
+
+// NewIPRateLimiter returns middleware that limits each client IP to rps requests
+// per second with the given burst, tracked in this process. It is shorthand for
+// NewRateLimitMiddleware(NewInProcessRateLimiter(rps, burst)).
+func NewIPRateLimiter(rps float64, burst int) func(http.Handler) http.Handler {
+	return NewRateLimitMiddleware(NewInProcessRateLimiter(rps, burst))
+}
+
+// NewInProcessRateLimiter returns a RateLimiter allowing rps requests per second
+// with the given burst per key, tracked in this process only. Limiters for keys
+// idle for more than 10 minutes are evicted.
+func NewInProcessRateLimiter(rps float64, burst int) RateLimiter {
+	return newIPRateLimiter(rps, burst, time.Now)
+}
+
+// NewRateLimitMiddleware returns middleware that limits each client IP with
+// limiter. Requests over the limit receive 429 Too Many Requests with a
+// Retry-After header. The client IP is taken from the connection's RemoteAddr,
+// not from forwarding headers, so it cannot be spoofed.
+func NewRateLimitMiddleware(limiter RateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, retryAfter, err := limiter.Allow(r.Context(), clientIP(r))
+			if err == nil && !allowed {
+				writeTooManyRequests(w, retryAfter)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func newIPRateLimiter(rps float64, burst int, now func() time.Time) *ipRateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	l := &ipRateLimiter{rps: rate.Limit(rps), burst: burst, now: now}
+	l.lastSweep.Store(now().UnixNano())
+	return l
+}
+
+// Allow implements RateLimiter with a token bucket per key.
+func (l *ipRateLimiter) Allow(_ context.Context, key string) (bool, time.Duration, error) {
+	now := l.now()
+	l.sweep(now)
+
+	reservation := l.limiterFor(key, now).ReserveN(now, 1)
+	if !reservation.OK() {
+		return false, time.Second, nil
+	}
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay, nil
+	}
+	return true, 0, nil
+}
+
+func (l *ipRateLimiter) limiterFor(ip string, now time.Time) *rate.Limiter {
+	value, ok := l.limiters.Load(ip)
+	if !ok {
+		value, _ = l.limiters.LoadOrStore(ip, &ipLimiterEntry{limiter: rate.NewLimiter(l.rps, l.burst)})
+	}
+	entry := value.(*ipLimiterEntry)
+	entry.lastSeen.Store(now.UnixNano())
+	return entry.limiter
+}
+
+// sweep evicts limiters idle for longer than ipLimiterIdleTTL. It runs at most once
+// per ipLimiterSweepInterval and only one caller performs each sweep.
+func (l *ipRateLimiter) sweep(now time.Time) {
+	last := l.lastSweep.Load()
+	if now.UnixNano()-last < int64(ipLimiterSweepInterval) || !l.lastSweep.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	cutoff := now.Add(-ipLimiterIdleTTL).UnixNano()
+	l.limiters.Range(func(key, value any) bool {
+		if value.(*ipLimiterEntry).lastSeen.Load() < cutoff {
+			l.limiters.Delete(key)
+		}
+		return true
+	})
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func writeTooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	writeJSONError(w, http.StatusTooManyRequests, "rate_limit_error", "rate limit exceeded")
+}
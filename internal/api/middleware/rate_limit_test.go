@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func serveFrom(handler http.Handler, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.RemoteAddr = remoteAddr
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNewIPRateLimiter_Returns429WithRetryAfter(t *testing.T) {
+	handler := NewIPRateLimiter(0.5, 2)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i := 0; i < 2; i++ {
+		if rec := serveFrom(handler, "192.0.2.1:1234"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d status = %d, want %d", i, rec.Code, http.StatusOK)
+		}
+	}
+	rec := serveFrom(handler, "192.0.2.1:5678")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Fatalf("Retry-After = %q, want %q", got, "2")
+	}
+}
+
+func TestNewIPRateLimiter_IndependentPerIP(t *testing.T) {
+	handler := NewIPRateLimiter(1, 1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	if rec := serveFrom(handler, "192.0.2.1:1234"); rec.Code != http.StatusOK {
+		t.Fatalf("first IP status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec := serveFrom(handler, "192.0.2.1:1234"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("first IP second request status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if rec := serveFrom(handler, "[2001:db8::1]:1234"); rec.Code != http.StatusOK {
+		t.Fatalf("second IP status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestIPRateLimiter_EvictsStaleEntries(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	limiter := newIPRateLimiter(1, 1, func() time.Time { return now })
+	handler := NewRateLimitMiddleware(limiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serveFrom(handler, "192.0.2.1:1234")
+	now = now.Add(5 * time.Minute)
+	serveFrom(handler, "192.0.2.2:1234")
+
+	now = now.Add(6 * time.Minute)
+	serveFrom(handler, "192.0.2.2:1234")
+
+	if _, ok := limiter.limiters.Load("192.0.2.1"); ok {
+		t.Fatal("expected limiter idle for 11 minutes to be evicted")
+	}
+	if _, ok := limiter.limiters.Load("192.0.2.2"); !ok {
+		t.Fatal("expected recently used limiter to be kept")
+	}
+}
@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// redisRateLimitKeyPrefix namespaces the counters written by RedisRateLimiter.
+	redisRateLimitKeyPrefix = "cliproxy:ratelimit:"
+	// redisRateLimitCooldown is how long RedisRateLimiter skips Redis after a failure.
+	redisRateLimitCooldown = 5 * time.Second
+	// redisRateLimitTimeout bounds dialing and each command of the config-built
+	// client so an unreachable Redis adds little latency before the fallback.
+	redisRateLimitTimeout = 200 * time.Millisecond
+)
+
+// redisFixedWindowScript increments the counter for the current window and
+// starts the window on the first request, atomically. It returns the count and
+// the window's remaining lifetime in milliseconds.
+var redisFixedWindowScript = redis.NewScript(`
+local current = redis.call("INCR", KEYS[1])
+if current == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+	ttl = tonumber(ARGV[1])
+end
+return {current, ttl}
+`)
+
+// RedisRateLimiter is a fixed-window RateLimiter shared by every proxy instance
+// using the same Redis server: each key may make limit requests per window.
+// When Redis is unreachable the fallback limiter decides; without a fallback
+// the error is returned and the middleware lets the request through. After a
+// failure Redis is not contacted again for redisRateLimitCooldown.
+type RedisRateLimiter struct {
+	client    redis.UniversalClient
+	limit     int
+	window    time.Duration
+	fallback  RateLimiter
+	downUntil atomic.Int64 // unix nanoseconds; Redis is skipped until then
+}
+
+// NewRedisRateLimiter creates a Redis-backed limiter. A limit below 1 is treated
+// as 1 and a non-positive window as one second.
+func NewRedisRateLimiter(client redis.UniversalClient, limit int, window time.Duration, fallback RateLimiter) *RedisRateLimiter {
+	if limit < 1 {
+		limit = 1
+	}
+	if window <= 0 {
+		window = time.Second
+	}
+	return &RedisRateLimiter{client: client, limit: limit, window: window, fallback: fallback}
+}
+
+// NewRateLimiterFromConfig returns a RedisRateLimiter connected with the Redis*
+// settings of cfg, falling back to an in-process limiter while Redis is down.
+// Without a configured Redis address it returns the in-process limiter alone.
+// Both allow rps requests per second with the given burst. Callers close the
+// returned limiter when it implements io.Closer.
+func NewRateLimiterFromConfig(cfg *config.Config, rps float64, burst int) RateLimiter {
+	inProcess := NewInProcessRateLimiter(rps, burst)
+	if cfg == nil || strings.TrimSpace(cfg.RedisAddr) == "" {
+		return inProcess
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	// A window holding burst requests at rps keeps the shared limit close to the
+	// in-process token bucket.
+	window := time.Second
+	if rps > 0 {
+		window = time.Duration(float64(burst) / rps * float64(time.Second))
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:         strings.TrimSpace(cfg.RedisAddr),
+		Password:     cfg.RedisPassword,
+		DB:           cfg.RedisDB,
+		DialTimeout:  redisRateLimitTimeout,
+		ReadTimeout:  redisRateLimitTimeout,
+		WriteTimeout: redisRateLimitTimeout,
+		MaxRetries:   -1,
+	})
+	return NewRedisRateLimiter(client, burst, window, inProcess)
+}
+
+// errRedisCoolingDown is reported while Redis is skipped after a failure.
+var errRedisCoolingDown = errors.New("redis unavailable, retrying later")
+
+// Close closes the Redis client.
+func (l *RedisRateLimiter) Close() error {
+	return l.client.Close()
+}
+
+// Allow implements RateLimiter.
+func (l *RedisRateLimiter) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	var result []int64
+	var err error
+	if time.Now().UnixNano() < l.downUntil.Load() {
+		err = errRedisCoolingDown
+	} else {
+		result, err = redisFixedWindowScript.Run(ctx, l.client, []string{redisRateLimitKeyPrefix + key}, l.window.Milliseconds()).Int64Slice()
+		if err == nil && len(result) != 2 {
+			err = fmt.Errorf("unexpected rate limit script result %v", result)
+		}
+		if err != nil && ctx.Err() == nil {
+			l.downUntil.Store(time.Now().Add(redisRateLimitCooldown).UnixNano())
+		}
+	}
+	if err != nil {
+		if l.fallback == nil {
+			return false, 0, fmt.Errorf("redis rate limiter: %w", err)
+		}
+		log.Debugf("redis rate limiter unavailable, using in-process limiter: %v", err)
+		return l.fallback.Allow(ctx, key)
+	}
+
+	count, ttl := result[0], time.Duration(result[1])*time.Millisecond
+	if count > int64(l.limit) {
+		return false, ttl, nil
+	}
+	return true, 0, nil
+}
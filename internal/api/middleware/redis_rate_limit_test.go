@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func newMiniredisClient(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return mr, client
+}
+
+func TestRedisRateLimiter_SharedAcrossInstances(t *testing.T) {
+	mr, client := newMiniredisClient(t)
+	// Two limiters on the same Redis stand in for two proxy instances.
+	first := NewRedisRateLimiter(client, 2, time.Minute, nil)
+	second := NewRedisRateLimiter(client, 2, time.Minute, nil)
+	ctx := context.Background()
+
+	for i, limiter := range []*RedisRateLimiter{first, second} {
+		allowed, _, err := limiter.Allow(ctx, "192.0.2.1")
+		if err != nil || !allowed {
+			t.Fatalf("request %d: allowed = %v, err = %v", i, allowed, err)
+		}
+	}
+	allowed, retryAfter, err := first.Allow(ctx, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	if allowed {
+		t.Fatal("third request in the window was allowed")
+	}
+	if retryAfter <= 0 || retryAfter > time.Minute {
+		t.Fatalf("retryAfter = %v, want within the window", retryAfter)
+	}
+	if allowed, _, _ := second.Allow(ctx, "192.0.2.2"); !allowed {
+		t.Fatal("a different key must have its own window")
+	}
+
+	// A new window starts once the counter expires.
+	mr.FastForward(time.Minute)
+	if allowed, _, _ := second.Allow(ctx, "192.0.2.1"); !allowed {
+		t.Fatal("request after the window expired was rejected")
+	}
+}
+
+func TestRedisRateLimiter_MiddlewareReturns429(t *testing.T) {
+	_, client := newMiniredisClient(t)
+	handler := NewRateLimitMiddleware(NewRedisRateLimiter(client, 1, 3*time.Second, nil))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	if rec := serveFrom(handler, "192.0.2.1:1234"); rec.Code != http.StatusOK {
+		t.Fatalf("first status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	rec := serveFrom(handler, "192.0.2.1:1234")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "3" {
+		t.Fatalf("Retry-After = %q, want %q", got, "3")
+	}
+}
+
+func TestRedisRateLimiter_FallsBackWhenRedisDown(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	t.Cleanup(func() { _ = client.Close() })
+	mr.Close()
+	limiter := NewRedisRateLimiter(client, 10, time.Minute, NewInProcessRateLimiter(0.01, 1))
+	ctx := context.Background()
+
+	if allowed, _, err := limiter.Allow(ctx, "192.0.2.1"); err != nil || !allowed {
+		t.Fatalf("first request: allowed = %v, err = %v", allowed, err)
+	}
+	// The in-process fallback (burst 1) now decides.
+	if allowed, _, err := limiter.Allow(ctx, "192.0.2.1"); err != nil || allowed {
+		t.Fatalf("second request: allowed = %v, err = %v, want rejected by the fallback", allowed, err)
+	}
+
+	// Without a fallback the error surfaces and the middleware lets requests through.
+	noFallback := NewRedisRateLimiter(client, 1, time.Minute, nil)
+	if _, _, err := noFallback.Allow(ctx, "192.0.2.1"); err == nil {
+		t.Fatal("expected an error with Redis down and no fallback")
+	}
+	handler := NewRateLimitMiddleware(noFallback)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for i := 0; i < 2; i++ {
+		if rec := serveFrom(handler, "192.0.2.1:1234"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d status = %d, want %d", i, rec.Code, http.StatusOK)
+		}
+	}
+}
+
+// countingHook counts the commands a Redis client sends.
+type countingHook struct{ commands atomic.Int32 }
+
+func (h *countingHook) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h *countingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		h.commands.Add(1)
+		return next(ctx, cmd)
+	}
+}
+
+func (h *countingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestRedisRateLimiter_SkipsRedisDuringCooldown(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	t.Cleanup(func() { _ = client.Close() })
+	hook := &countingHook{}
+	client.AddHook(hook)
+	mr.Close()
+	limiter := NewRedisRateLimiter(client, 10, time.Minute, NewInProcessRateLimiter(100, 10))
+	ctx := context.Background()
+
+	if allowed, _, err := limiter.Allow(ctx, "192.0.2.1"); err != nil || !allowed {
+		t.Fatalf("first request: allowed = %v, err = %v", allowed, err)
+	}
+	sent := hook.commands.Load()
+	if sent == 0 {
+		t.Fatal("first request did not try Redis")
+	}
+	for i := 0; i < 3; i++ {
+		if allowed, _, err := limiter.Allow(ctx, "192.0.2.1"); err != nil || !allowed {
+			t.Fatalf("request %d: allowed = %v, err = %v", i, allowed, err)
+		}
+	}
+	if got := hook.commands.Load(); got != sent {
+		t.Fatalf("Redis commands = %d after the failure, want %d (cooldown)", got, sent)
+	}
+
+	limiter.downUntil.Store(0)
+	_, _, _ = limiter.Allow(ctx, "192.0.2.1")
+	if got := hook.commands.Load(); got == sent {
+		t.Fatal("Redis was not retried after the cooldown")
+	}
+}
+
+func TestNewRateLimiterFromConfig(t *testing.T) {
+	if _, ok := NewRateLimiterFromConfig(&config.Config{}, 1, 1).(*RedisRateLimiter); ok {
+		t.Fatal("expected the in-process limiter without redis-addr")
+	}
+
+	mr := miniredis.RunT(t)
+	limiter, ok := NewRateLimiterFromConfig(&config.Config{RedisAddr: mr.Addr()}, 1, 2).(*RedisRateLimiter)
+	if !ok {
+		t.Fatal("expected a RedisRateLimiter with redis-addr set")
+	}
+	if limiter.limit != 2 || limiter.window != 2*time.Second {
+		t.Fatalf("limit = %d, window = %v, want 2 per 2s", limiter.limit, limiter.window)
+	}
+	if allowed, _, err := limiter.Allow(context.Background(), "192.0.2.1"); err != nil || !allowed {
+		t.Fatalf("Allow: allowed = %v, err = %v", allowed, err)
+	}
+	if !mr.Exists(redisRateLimitKeyPrefix + "192.0.2.1") {
+		t.Fatal("counter was not written to Redis")
+	}
+	if errClose := limiter.Close(); errClose != nil {
+		t.Fatalf("Close: %v", errClose)
+	}
+	// A closed client falls back to the in-process limiter.
+	if allowed, _, err := limiter.Allow(context.Background(), "192.0.2.1"); err != nil || !allowed {
+		t.Fatalf("Allow after Close: allowed = %v, err = %v", allowed, err)
+	}
+}
@@ -0,0 +1,51 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains HMAC request signature verification.
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// SignatureHeader carries the hex-encoded HMAC-SHA256 of the request body.
+const SignatureHeader = "X-Signature-SHA256"
+
+// VerifyRequestSignature reports whether the X-Signature-SHA256 header matches the
+// HMAC-SHA256 of the raw request body keyed with secret. The header value is hex,
+// optionally prefixed with "sha256=". The comparison is constant time, and the
+// body is restored so later handlers can read it again. A missing header, empty
+// secret or unreadable body fails verification.
+func VerifyRequestSignature(r *http.Request, secret string) bool {
+	if r == nil || secret == "" {
+		return false
+	}
+	signature := strings.TrimSpace(r.Header.Get(SignatureHeader))
+	signature = strings.TrimPrefix(signature, "sha256=")
+	if signature == "" {
+		return false
+	}
+	expected, errDecode := hex.DecodeString(signature)
+	if errDecode != nil {
+		return false
+	}
+
+	var body []byte
+	if r.Body != nil {
+		var errRead error
+		body, errRead = io.ReadAll(r.Body)
+		_ = r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if errRead != nil {
+			return false
+		}
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func signBody(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyRequestSignature(t *testing.T) {
+	const secret = "shared-secret"
+	const body = `{"model":"gpt-5","input":"ping"}`
+
+	tests := []struct {
+		name      string
+		signature string
+		want      bool
+	}{
+		{"valid", signBody(secret, body), true},
+		{"valid with prefix", "sha256=" + signBody(secret, body), true},
+		{"wrong secret", signBody("other-secret", body), false},
+		{"tampered body", signBody(secret, body+" "), false},
+		{"not hex", "zz", false},
+		{"missing header", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/responses", strings.NewReader(body))
+			if tt.signature != "" {
+				req.Header.Set(SignatureHeader, tt.signature)
+			}
+
+			if got := VerifyRequestSignature(req, secret); got != tt.want {
+				t.Fatalf("VerifyRequestSignature() = %v, want %v", got, tt.want)
+			}
+
+			reread, err := io.ReadAll(req.Body)
+			if err != nil {
+				t.Fatalf("re-read body: %v", err)
+			}
+			if string(reread) != body {
+				t.Fatalf("body after verification = %q, want %q", reread, body)
+			}
+		})
+	}
+}
+
+func TestVerifyRequestSignature_EmptySecret(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("x"))
+	req.Header.Set(SignatureHeader, signBody("", "x"))
+	if VerifyRequestSignature(req, "") {
+		t.Fatal("expected verification to fail with an empty secret")
+	}
+}
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+)
+
+// TranslationPreviewMiddleware serves requests with ?dry_run=true without
+// contacting a backend. The request runs through the normal handler and auth
+// selection, but the executor only translates it; the translator output is
+// returned as the response with Content-Type application/json. Requests that
+// fail before reaching an executor (unknown model, no auth) get the handler's
+// error response.
+//
+// The preview is the translator output, not the exact upstream body: payload
+// building done inside executors (the Kiro conversation payload, the
+// Antigravity envelope, payload and thinking overrides) is not applied.
+func TranslationPreviewMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isTranslationPreviewRequest(c.Request) {
+			c.Next()
+			return
+		}
+
+		var mu sync.Mutex
+		var preview []byte
+		handlers.SetTranslationPreviewSink(c, func(payload []byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			if preview == nil {
+				preview = bytes.Clone(payload)
+			}
+		})
+
+		original := c.Writer
+		buffered := &previewResponseWriter{ResponseWriter: original, header: make(http.Header)}
+		c.Writer = buffered
+		c.Next()
+		c.Writer = original
+
+		mu.Lock()
+		body := preview
+		mu.Unlock()
+		if body != nil {
+			original.Header().Set("Content-Type", "application/json")
+			original.WriteHeader(http.StatusOK)
+			_, _ = original.Write(body)
+			return
+		}
+		buffered.replay(original)
+	}
+}
+
+func isTranslationPreviewRequest(r *http.Request) bool {
+	if r == nil || r.URL == nil {
+		return false
+	}
+	enabled, err := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	return err == nil && enabled
+}
+
+// previewResponseWriter buffers the handler's response so it can be replaced by
+// the translation preview or replayed unchanged.
+type previewResponseWriter struct {
+	gin.ResponseWriter
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *previewResponseWriter) Header() http.Header { return w.header }
+
+func (w *previewResponseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *previewResponseWriter) WriteHeaderNow() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+}
+
+func (w *previewResponseWriter) Write(data []byte) (int, error) {
+	w.WriteHeaderNow()
+	return w.body.Write(data)
+}
+
+func (w *previewResponseWriter) WriteString(s string) (int, error) {
+	w.WriteHeaderNow()
+	return w.body.WriteString(s)
+}
+
+func (w *previewResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *previewResponseWriter) Size() int {
+	if w.status == 0 {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *previewResponseWriter) Written() bool { return w.status != 0 }
+
+func (w *previewResponseWriter) Flush() {}
+
+func (w *previewResponseWriter) replay(dst gin.ResponseWriter) {
+	for key, values := range w.header {
+		dst.Header()[key] = values
+	}
+	dst.WriteHeader(w.Status())
+	_, _ = dst.Write(w.body.Bytes())
+}
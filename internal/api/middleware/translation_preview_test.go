@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/openai"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+// backendCountingExecutor stands in for a provider backend and counts every call.
+type backendCountingExecutor struct {
+	calls atomic.Int32
+}
+
+func (e *backendCountingExecutor) Identifier() string { return "claude" }
+
+func (e *backendCountingExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	e.calls.Add(1)
+	return coreexecutor.Response{Payload: []byte(`{"id":"backend"}`)}, nil
+}
+
+func (e *backendCountingExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (*coreexecutor.StreamResult, error) {
+	e.calls.Add(1)
+	return nil, errors.New("backend contacted")
+}
+
+func (e *backendCountingExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *backendCountingExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	e.calls.Add(1)
+	return coreexecutor.Response{}, errors.New("backend contacted")
+}
+
+func (e *backendCountingExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	e.calls.Add(1)
+	return nil, errors.New("backend contacted")
+}
+
+func newTranslationPreviewTestRouter(t *testing.T) (*gin.Engine, *backendCountingExecutor) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	backend := &backendCountingExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(backend)
+	auth := &coreauth.Auth{ID: "dry-run-auth", Provider: backend.Identifier(), Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("Register auth: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "dry-run-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	h := openai.NewOpenAIAPIHandler(handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager))
+	router := gin.New()
+	router.Use(TranslationPreviewMiddleware())
+	router.POST("/v1/chat/completions", h.ChatCompletions)
+	return router, backend
+}
+
+func postChat(router *gin.Engine, query, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestTranslationPreviewMiddlewareReturnsTranslatedRequest(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		router, backend := newTranslationPreviewTestRouter(t)
+		body := `{"model":"dry-run-model","messages":[{"role":"user","content":"hello"}]}`
+		if stream {
+			body = `{"model":"dry-run-model","stream":true,"messages":[{"role":"user","content":"hello"}]}`
+		}
+
+		resp := postChat(router, "?dry_run=true", body)
+
+		if resp.Code != http.StatusOK {
+			t.Fatalf("stream=%v: status = %d, body %s", stream, resp.Code, resp.Body.String())
+		}
+		if ct := resp.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("stream=%v: Content-Type = %q", stream, ct)
+		}
+		if calls := backend.calls.Load(); calls != 0 {
+			t.Errorf("stream=%v: backend calls = %d, want 0", stream, calls)
+		}
+		preview := resp.Body.Bytes()
+		if !gjson.ValidBytes(preview) {
+			t.Fatalf("stream=%v: preview is not JSON: %s", stream, preview)
+		}
+		// The OpenAI request must have been translated to the Claude Messages format.
+		if got := gjson.GetBytes(preview, "messages.0.content.0.text").String(); got != "hello" {
+			t.Errorf("stream=%v: translated text = %q, preview %s", stream, got, preview)
+		}
+		if !gjson.GetBytes(preview, "max_tokens").Exists() {
+			t.Errorf("stream=%v: preview lacks Claude max_tokens: %s", stream, preview)
+		}
+	}
+}
+
+func TestTranslationPreviewMiddlewarePassesThroughWithoutFlag(t *testing.T) {
+	router, backend := newTranslationPreviewTestRouter(t)
+
+	resp := postChat(router, "?dry_run=false", `{"model":"dry-run-model","messages":[{"role":"user","content":"hello"}]}`)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", resp.Code, resp.Body.String())
+	}
+	if calls := backend.calls.Load(); calls != 1 {
+		t.Fatalf("backend calls = %d, want 1", calls)
+	}
+}
+
+func TestTranslationPreviewMiddlewareKeepsErrorsBeforeExecution(t *testing.T) {
+	router, backend := newTranslationPreviewTestRouter(t)
+
+	resp := postChat(router, "?dry_run=1", `{"model":"no-such-model","messages":[{"role":"user","content":"hello"}]}`)
+
+	if resp.Code == http.StatusOK {
+		t.Fatalf("expected an error status for an unknown model, body %s", resp.Body.String())
+	}
+	if !strings.Contains(resp.Body.String(), "error") {
+		t.Errorf("expected the handler's error body, got %s", resp.Body.String())
+	}
+	if calls := backend.calls.Load(); calls != 0 {
+		t.Errorf("backend calls = %d, want 0", calls)
+	}
+}
@@ -248,3 +248,27 @@ func TestDefaultRequestLoggerFactory_UsesResolvedLogDirectory(t *testing.T) {
 		}
 	}
 }
+
+func TestServerRateLimitsPerClientIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tmpDir := t.TempDir()
+	cfg := &proxyconfig.Config{
+		AuthDir:   tmpDir,
+		RateLimit: proxyconfig.RateLimitConfig{RequestsPerSecond: 0.01, Burst: 1},
+	}
+	server := NewServer(cfg, auth.NewManager(nil, nil, nil), sdkaccess.NewManager(), filepath.Join(tmpDir, "config.yaml"))
+
+	serve := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		req.RemoteAddr = "192.0.2.10:1234"
+		rr := httptest.NewRecorder()
+		server.server.Handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if code := serve(); code != http.StatusOK {
+		t.Fatalf("first request status = %d, want %d", code, http.StatusOK)
+	}
+	if code := serve(); code != http.StatusTooManyRequests {
+		t.Fatalf("second request status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+}
@@ -13,7 +13,6 @@ import (
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
-	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -62,10 +61,10 @@ type CopilotAuth struct {
 
 // NewCopilotAuth creates a new CopilotAuth service instance.
 // It initializes an HTTP client with proxy settings from the provided configuration.
-func NewCopilotAuth(cfg *config.Config) *CopilotAuth {
+func NewCopilotAuth(cfg *config.Config, opts ...ClientOption) *CopilotAuth {
 	return &CopilotAuth{
-		httpClient:   util.SetProxy(&cfg.SDKConfig, &http.Client{Timeout: 30 * time.Second}),
-		deviceClient: NewDeviceFlowClient(cfg),
+		httpClient:   newHTTPClient(cfg, 30*time.Second, opts),
+		deviceClient: NewDeviceFlowClient(cfg, opts...),
 		cfg:          cfg,
 	}
 }
@@ -172,6 +171,7 @@ func (c *CopilotAuth) CreateTokenStorage(bundle *CopilotAuthBundle) *CopilotToke
 		AccessToken: bundle.TokenData.AccessToken,
 		TokenType:   bundle.TokenData.TokenType,
 		Scope:       bundle.TokenData.Scope,
+		ExpiresAt:   bundle.TokenData.ExpiresAt,
 		Username:    bundle.Username,
 		Email:       bundle.Email,
 		Name:        bundle.Name,
@@ -0,0 +1,197 @@
+package copilot
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// githubAPIBaseURL is the default GitHub REST API root for GitHub App requests.
+	githubAPIBaseURL = "https://api.github.com"
+	// githubAPIVersion is the REST API version requested by GitHub App calls.
+	githubAPIVersion = "2022-11-28"
+	// githubAppJWTLifetime is the lifetime of an app JWT; GitHub allows at most 10 minutes.
+	githubAppJWTLifetime = 9 * time.Minute
+	// githubAppJWTClockSkew backdates the issued-at claim to tolerate clock drift.
+	githubAppJWTClockSkew = 60 * time.Second
+)
+
+// GitHubAppAuthenticator authenticates as a GitHub App installation using the
+// app's private key instead of the OAuth device flow.
+type GitHubAppAuthenticator struct {
+	appID      int64
+	privateKey *rsa.PrivateKey
+	apiBaseURL string
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// NewGitHubAppAuthenticator creates an authenticator for the given app ID and
+// PEM-encoded RSA private key (PKCS#1 as downloaded from GitHub, or PKCS#8).
+func NewGitHubAppAuthenticator(appID int64, privateKey []byte, opts ...ClientOption) (*GitHubAppAuthenticator, error) {
+	if appID <= 0 {
+		return nil, fmt.Errorf("github app: invalid app id %d", appID)
+	}
+	key, err := parseRSAPrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("github app: %w", err)
+	}
+	return &GitHubAppAuthenticator{
+		appID:      appID,
+		privateKey: key,
+		apiBaseURL: githubAPIBaseURL,
+		httpClient: newHTTPClient(nil, 30*time.Second, opts),
+		now:        time.Now,
+	}, nil
+}
+
+// SetAPIBaseURL points the authenticator at another GitHub REST API root, such
+// as https://github.example.com/api/v3 for GitHub Enterprise Server. An empty
+// value restores api.github.com.
+func (a *GitHubAppAuthenticator) SetAPIBaseURL(baseURL string) {
+	baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if baseURL == "" {
+		baseURL = githubAPIBaseURL
+	}
+	a.apiBaseURL = baseURL
+}
+
+func parseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+	if key, errPKCS1 := x509.ParsePKCS1PrivateKey(block.Bytes); errPKCS1 == nil {
+		return key, nil
+	}
+	parsed, errPKCS8 := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if errPKCS8 != nil {
+		return nil, fmt.Errorf("parse private key: %w", errPKCS8)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is %T, want RSA", parsed)
+	}
+	return key, nil
+}
+
+// GenerateJWT returns an RS256-signed app JWT as required by GitHub: iat is
+// backdated by 60 seconds, exp is under 10 minutes ahead and iss is the app ID.
+func (a *GitHubAppAuthenticator) GenerateJWT() (string, error) {
+	now := a.now()
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	claims := map[string]any{
+		"iat": now.Add(-githubAppJWTClockSkew).Unix(),
+		"exp": now.Add(githubAppJWTLifetime).Unix(),
+		"iss": strconv.FormatInt(a.appID, 10),
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("github app: sign jwt: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Authenticate exchanges an app JWT for an installation access token and wraps
+// it in a CopilotAuthBundle. The bundle username is the installation account
+// login when it can be resolved.
+func (a *GitHubAppAuthenticator) Authenticate(ctx context.Context, installationID int64) (*CopilotAuthBundle, error) {
+	appJWT, err := a.GenerateJWT()
+	if err != nil {
+		return nil, NewAuthenticationError(ErrTokenExchangeFailed, err)
+	}
+
+	var tokenResp struct {
+		Token     string `json:"token"`
+		ExpiresAt string `json:"expires_at"`
+	}
+	tokenURL := fmt.Sprintf("%s/app/installations/%d/access_tokens", a.apiBaseURL, installationID)
+	if err = a.doJSON(ctx, http.MethodPost, tokenURL, appJWT, &tokenResp); err != nil {
+		return nil, NewAuthenticationError(ErrTokenExchangeFailed, err)
+	}
+	if tokenResp.Token == "" {
+		return nil, NewAuthenticationError(ErrTokenExchangeFailed, fmt.Errorf("empty installation token"))
+	}
+
+	var installation struct {
+		Account struct {
+			Login string `json:"login"`
+		} `json:"account"`
+	}
+	installationURL := fmt.Sprintf("%s/app/installations/%d", a.apiBaseURL, installationID)
+	if errInstallation := a.doJSON(ctx, http.MethodGet, installationURL, appJWT, &installation); errInstallation != nil {
+		log.Warnf("copilot: failed to fetch github app installation: %v", errInstallation)
+	}
+
+	username := installation.Account.Login
+	if username == "" {
+		username = fmt.Sprintf("github-app-%d", a.appID)
+	}
+
+	return &CopilotAuthBundle{
+		TokenData: &CopilotTokenData{
+			AccessToken: tokenResp.Token,
+			TokenType:   "token",
+			ExpiresAt:   tokenResp.ExpiresAt,
+		},
+		Username: username,
+	}, nil
+}
+
+func (a *GitHubAppAuthenticator) doJSON(ctx context.Context, method, url, appJWT string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+appJWT)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", githubAPIVersion)
+	req.Header.Set("User-Agent", "CLIProxyAPI")
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			log.Errorf("copilot github app: close body error: %v", errClose)
+		}
+	}()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if !isHTTPSuccess(resp.StatusCode) {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+	return json.Unmarshal(bodyBytes, out)
+}
@@ -0,0 +1,167 @@
+package copilot
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestGitHubAppAuthenticator(t *testing.T) (*GitHubAppAuthenticator, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	auth, err := NewGitHubAppAuthenticator(12345, keyPEM)
+	if err != nil {
+		t.Fatalf("NewGitHubAppAuthenticator() error = %v", err)
+	}
+	return auth, key
+}
+
+// verifyAppJWT checks the RS256 signature of token and returns its claims.
+func verifyAppJWT(t *testing.T, token string, pub *rsa.PublicKey) map[string]any {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("jwt has %d parts, want 3", len(parts))
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("decode signature: %v", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature); err != nil {
+		t.Fatalf("jwt signature invalid: %v", err)
+	}
+
+	var header map[string]any
+	headerJSON, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	if err = json.Unmarshal(headerJSON, &header); err != nil || header["alg"] != "RS256" {
+		t.Fatalf("jwt header = %s, want alg RS256", headerJSON)
+	}
+	var claims map[string]any
+	claimsJSON, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	if err = json.Unmarshal(claimsJSON, &claims); err != nil {
+		t.Fatalf("unmarshal claims: %v", err)
+	}
+	return claims
+}
+
+func TestGitHubAppAuthenticator_GenerateJWT(t *testing.T) {
+	auth, key := newTestGitHubAppAuthenticator(t)
+	now := time.Unix(1_700_000_000, 0)
+	auth.now = func() time.Time { return now }
+
+	token, err := auth.GenerateJWT()
+	if err != nil {
+		t.Fatalf("GenerateJWT() error = %v", err)
+	}
+	claims := verifyAppJWT(t, token, &key.PublicKey)
+	if claims["iss"] != "12345" {
+		t.Errorf("iss = %v, want 12345", claims["iss"])
+	}
+	iat, _ := claims["iat"].(float64)
+	exp, _ := claims["exp"].(float64)
+	if int64(iat) != now.Unix()-60 {
+		t.Errorf("iat = %v, want %d", iat, now.Unix()-60)
+	}
+	if lifetime := int64(exp) - now.Unix(); lifetime <= 0 || lifetime > 600 {
+		t.Errorf("exp is %ds after now, want within (0, 600]", lifetime)
+	}
+}
+
+func TestGitHubAppAuthenticator_Authenticate(t *testing.T) {
+	auth, key := newTestGitHubAppAuthenticator(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bearer := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		verifyAppJWT(t, bearer, &key.PublicKey)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/app/installations/42/access_tokens":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"token":"ghs_installation","expires_at":"2030-01-01T00:00:00Z"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/app/installations/42":
+			_, _ = w.Write([]byte(`{"id":42,"account":{"login":"acme-org"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	auth.httpClient = newTestClient(srv)
+
+	bundle, err := auth.Authenticate(context.Background(), 42)
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if bundle.TokenData == nil || bundle.TokenData.AccessToken != "ghs_installation" {
+		t.Fatalf("TokenData = %+v, want installation token", bundle.TokenData)
+	}
+	if bundle.Username != "acme-org" {
+		t.Errorf("Username = %q, want acme-org", bundle.Username)
+	}
+	if bundle.TokenData.ExpiresAt != "2030-01-01T00:00:00Z" {
+		t.Errorf("ExpiresAt = %q, want the installation token expiry", bundle.TokenData.ExpiresAt)
+	}
+}
+
+func TestGitHubAppAuthenticator_EnterpriseAPIBaseURL(t *testing.T) {
+	auth, _ := newTestGitHubAppAuthenticator(t)
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"token":"ghs_enterprise","expires_at":"2030-01-01T00:00:00Z"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":7,"account":{"login":"enterprise-org"}}`))
+	}))
+	defer srv.Close()
+	auth.httpClient = srv.Client()
+	auth.SetAPIBaseURL(srv.URL + "/api/v3/")
+
+	if _, err := auth.Authenticate(context.Background(), 7); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	want := []string{"/api/v3/app/installations/7/access_tokens", "/api/v3/app/installations/7"}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+}
+
+func TestGitHubAppAuthenticator_AuthenticateHTTPError(t *testing.T) {
+	auth, _ := newTestGitHubAppAuthenticator(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	auth.httpClient = newTestClient(srv)
+
+	_, err := auth.Authenticate(context.Background(), 42)
+	if err == nil || !IsAuthenticationError(err) {
+		t.Fatalf("Authenticate() error = %v, want authentication error", err)
+	}
+}
+
+func TestNewGitHubAppAuthenticator_InvalidKey(t *testing.T) {
+	if _, err := NewGitHubAppAuthenticator(1, []byte("not a key")); err == nil {
+		t.Fatal("expected error for non-PEM key")
+	}
+	if _, err := NewGitHubAppAuthenticator(0, nil); err == nil {
+		t.Fatal("expected error for invalid app id")
+	}
+}
@@ -0,0 +1,57 @@
+package copilot
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// DefaultHTTPClientConfig holds the timeouts of Copilot HTTP clients: 10s to
+// dial, 10s for the TLS handshake, 60s for response headers and 2m in total for
+// non-streaming requests.
+var DefaultHTTPClientConfig = util.HTTPClientConfig{
+	DialTimeout:           10 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ResponseHeaderTimeout: 60 * time.Second,
+	TotalTimeout:          2 * time.Minute,
+}
+
+// ClientOption configures the HTTP client used by Copilot auth clients.
+type ClientOption = util.AuthClientOption
+
+// WithHTTPClient uses client instead of building one from the proxy configuration.
+// The minimum TLS version is still enforced on a copy of the client.
+func WithHTTPClient(client *http.Client) ClientOption {
+	return util.WithAuthHTTPClient(client)
+}
+
+// WithMinTLS sets the minimum TLS version (e.g. tls.VersionTLS13) for outbound
+// connections. The default is TLS 1.2.
+func WithMinTLS(version uint16) ClientOption {
+	return util.WithAuthMinTLS(version)
+}
+
+// newHTTPClient builds the HTTP client for a Copilot auth client: the client from
+// WithHTTPClient, or a proxy-aware client that gives up after timeout unless the
+// provider-http settings for "github-copilot" say otherwise.
+func newHTTPClient(cfg *config.Config, timeout time.Duration, opts []ClientOption) *http.Client {
+	defaults := util.HTTPClientConfig{TotalTimeout: timeout}.WithDefaults(DefaultHTTPClientConfig)
+	return util.NewAuthHTTPClient(cfg, "github-copilot", defaults, opts...)
+}
+
+// NewHTTPClientWithConfig builds a proxy-aware Copilot HTTP client whose transport
+// honors timeouts. Zero fields use the provider-http settings for "github-copilot", then
+// DefaultHTTPClientConfig. The total timeout covers reading the response body.
+func NewHTTPClientWithConfig(cfg *config.Config, timeouts util.HTTPClientConfig) *http.Client {
+	return util.NewProviderHTTPClient(cfg, "github-copilot", timeouts, DefaultHTTPClientConfig, false)
+}
+
+// NewStreamingHTTPClientWithConfig is like NewHTTPClientWithConfig but does not
+// apply the total timeout, so streaming bodies can be read for as long as the
+// upstream keeps sending. Dial, TLS handshake and response header timeouts
+// still apply.
+func NewStreamingHTTPClientWithConfig(cfg *config.Config, timeouts util.HTTPClientConfig) *http.Client {
+	return util.NewProviderHTTPClient(cfg, "github-copilot", timeouts, DefaultHTTPClientConfig, true)
+}
@@ -0,0 +1,65 @@
+package copilot
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+func TestClientMinTLS(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []ClientOption
+		want uint16
+	}{
+		{"default", nil, tls.VersionTLS12},
+		{"configured", []ClientOption{WithMinTLS(tls.VersionTLS13)}, tls.VersionTLS13},
+		{"custom client", []ClientOption{WithHTTPClient(&http.Client{}), WithMinTLS(tls.VersionTLS13)}, tls.VersionTLS13},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewCopilotAuth(&config.Config{}, tt.opts...).httpClient
+			transport, ok := client.Transport.(*http.Transport)
+			if !ok {
+				t.Fatalf("transport = %T, want *http.Transport", client.Transport)
+			}
+			if got := transport.TLSClientConfig.MinVersion; got != tt.want {
+				t.Fatalf("MinVersion = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHTTPClientWithConfig(t *testing.T) {
+	custom := util.HTTPClientConfig{ResponseHeaderTimeout: 7 * time.Second}
+	for _, streaming := range []bool{false, true} {
+		client := NewHTTPClientWithConfig(&config.Config{}, custom)
+		if streaming {
+			client = NewStreamingHTTPClientWithConfig(&config.Config{}, custom)
+		}
+		transport, ok := client.Transport.(*http.Transport)
+		if !ok {
+			t.Fatalf("streaming=%v: transport = %T, want *http.Transport", streaming, client.Transport)
+		}
+		if transport.ResponseHeaderTimeout != custom.ResponseHeaderTimeout {
+			t.Errorf("streaming=%v: ResponseHeaderTimeout = %v, want %v", streaming, transport.ResponseHeaderTimeout, custom.ResponseHeaderTimeout)
+		}
+		if transport.TLSHandshakeTimeout != DefaultHTTPClientConfig.TLSHandshakeTimeout {
+			t.Errorf("streaming=%v: TLSHandshakeTimeout = %v, want default %v", streaming, transport.TLSHandshakeTimeout, DefaultHTTPClientConfig.TLSHandshakeTimeout)
+		}
+		if transport.TLSClientConfig == nil || transport.TLSClientConfig.MinVersion != tls.VersionTLS12 {
+			t.Errorf("streaming=%v: minimum TLS version not enforced", streaming)
+		}
+		wantTotal := DefaultHTTPClientConfig.TotalTimeout
+		if streaming {
+			wantTotal = 0
+		}
+		if client.Timeout != wantTotal {
+			t.Errorf("streaming=%v: client Timeout = %v, want %v", streaming, client.Timeout, wantTotal)
+		}
+	}
+}
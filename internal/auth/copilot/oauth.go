@@ -12,7 +12,6 @@ import (
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
-	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -38,11 +37,8 @@ type DeviceFlowClient struct {
 }
 
 // NewDeviceFlowClient creates a new device flow client.
-func NewDeviceFlowClient(cfg *config.Config) *DeviceFlowClient {
-	client := &http.Client{Timeout: 30 * time.Second}
-	if cfg != nil {
-		client = util.SetProxy(&cfg.SDKConfig, client)
-	}
+func NewDeviceFlowClient(cfg *config.Config, opts ...ClientOption) *DeviceFlowClient {
+	client := newHTTPClient(cfg, 30*time.Second, opts)
 	return &DeviceFlowClient{
 		httpClient: client,
 		cfg:        cfg,
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 )
 
 // CopilotTokenStorage stores OAuth2 token information for GitHub Copilot API authentication.
@@ -34,6 +35,53 @@ type CopilotTokenStorage struct {
 	Type string `json:"type"`
 }
 
+// Diff reports the fields that differ between s and other, keyed by field name.
+// Each value has the form "old → new"; the access token is masked so the result
+// is safe to write to audit logs. A nil storage is treated as empty.
+//
+// Parameters:
+//   - other: The newer storage snapshot to compare against
+//
+// Returns:
+//   - map[string]string: Changed field names mapped to "old → new"; empty when nothing changed
+func (s *CopilotTokenStorage) Diff(other *CopilotTokenStorage) map[string]string {
+	var before, after CopilotTokenStorage
+	if s != nil {
+		before = *s
+	}
+	if other != nil {
+		after = *other
+	}
+
+	fields := []struct {
+		name          string
+		before, after string
+		secret        bool
+	}{
+		{"AccessToken", before.AccessToken, after.AccessToken, true},
+		{"TokenType", before.TokenType, after.TokenType, false},
+		{"Scope", before.Scope, after.Scope, false},
+		{"ExpiresAt", before.ExpiresAt, after.ExpiresAt, false},
+		{"Username", before.Username, after.Username, false},
+		{"Email", before.Email, after.Email, false},
+		{"Name", before.Name, after.Name, false},
+		{"Type", before.Type, after.Type, false},
+	}
+
+	changes := make(map[string]string)
+	for _, f := range fields {
+		if f.before == f.after {
+			continue
+		}
+		oldValue, newValue := f.before, f.after
+		if f.secret {
+			oldValue, newValue = util.HideAPIKey(oldValue), util.HideAPIKey(newValue)
+		}
+		changes[f.name] = oldValue + " → " + newValue
+	}
+	return changes
+}
+
 // CopilotTokenData holds the raw OAuth token response from GitHub.
 type CopilotTokenData struct {
 	// AccessToken is the OAuth2 access token.
@@ -42,6 +90,9 @@ type CopilotTokenData struct {
 	TokenType string `json:"token_type"`
 	// Scope is the OAuth2 scope granted to the token.
 	Scope string `json:"scope"`
+	// ExpiresAt is the RFC 3339 expiry of the token, set for GitHub App
+	// installation tokens. Device flow tokens do not expire and leave it empty.
+	ExpiresAt string `json:"expires_at,omitempty"`
 }
 
 // CopilotAuthBundle bundles authentication data for storage.
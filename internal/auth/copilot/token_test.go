@@ -0,0 +1,43 @@
+package copilot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCopilotTokenStorageDiff(t *testing.T) {
+	before := &CopilotTokenStorage{
+		AccessToken: "gho_oldaccesstoken1234",
+		TokenType:   "bearer",
+		Scope:       "read:user",
+		Username:    "octocat",
+		Type:        "github-copilot",
+	}
+	after := *before
+	after.AccessToken = "gho_newaccesstoken5678"
+	after.Scope = "read:user user:email"
+
+	diff := before.Diff(&after)
+	if len(diff) != 2 {
+		t.Fatalf("expected 2 changed fields, got %d: %v", len(diff), diff)
+	}
+
+	if got, want := diff["Scope"], "read:user → read:user user:email"; got != want {
+		t.Errorf("Scope diff = %q, want %q", got, want)
+	}
+
+	tokenDiff, ok := diff["AccessToken"]
+	if !ok {
+		t.Fatal("expected AccessToken in diff")
+	}
+	if strings.Contains(tokenDiff, "oldaccesstoken") || strings.Contains(tokenDiff, "newaccesstoken") {
+		t.Errorf("AccessToken diff leaks token values: %q", tokenDiff)
+	}
+	if !strings.Contains(tokenDiff, " → ") {
+		t.Errorf("AccessToken diff = %q, want old → new format", tokenDiff)
+	}
+
+	if diff := before.Diff(before); len(diff) != 0 {
+		t.Errorf("expected no changes when diffing a snapshot with itself, got %v", diff)
+	}
+}
@@ -154,7 +154,7 @@ func (ia *IFlowAuth) doTokenRequest(ctx context.Context, req *http.Request) (*IF
 	}
 
 	if tokenResp.AccessToken == "" {
-		log.Debug(string(body))
+		log.Debugf("iflow token: response missing access token (token_type=%s refresh_token=%s)", tokenResp.TokenType, util.ObfuscateToken(tokenResp.RefreshToken))
 		return nil, fmt.Errorf("iflow token: missing access token in response")
 	}
 
@@ -0,0 +1,90 @@
+package kiro
+
+import (
+	"errors"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Audit event types emitted for Kiro token lifecycle events.
+const (
+	AuditEventTokenCreate             = "token_create"
+	AuditEventTokenCreateWithAuthCode = "token_create_auth_code"
+	AuditEventTokenRefresh            = "token_refresh"
+	AuditEventBackgroundRefresh       = "background_refresh"
+)
+
+// auditProvider is the provider name recorded on Kiro audit events.
+const auditProvider = "kiro"
+
+// AuditEvent describes a single token acquisition or refresh attempt.
+// AccountKey is the derived fingerprint key, never a raw credential.
+type AuditEvent struct {
+	Timestamp  time.Time
+	EventType  string
+	Provider   string
+	AccountKey string
+	Success    bool
+	Error      string
+}
+
+// LogAuditEvent emits event as a structured INFO entry on logger.
+// A nil logger falls back to the standard logrus logger and a zero Timestamp to now.
+func LogAuditEvent(logger *log.Logger, event AuditEvent) {
+	if logger == nil {
+		logger = log.StandardLogger()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	fields := log.Fields{
+		"audit":       true,
+		"event_type":  event.EventType,
+		"provider":    event.Provider,
+		"account_key": event.AccountKey,
+		"success":     event.Success,
+	}
+	if event.Error != "" {
+		fields["error"] = event.Error
+	}
+	logger.WithTime(event.Timestamp).WithFields(fields).Info("audit: token event")
+}
+
+// auditTokenEvent records the outcome of a Kiro token operation.
+func auditTokenEvent(eventType, accountKey string, err error) {
+	event := AuditEvent{
+		EventType:  eventType,
+		Provider:   auditProvider,
+		AccountKey: accountKey,
+		Success:    err == nil,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	LogAuditEvent(nil, event)
+}
+
+// auditDeviceTokenResult records a device-code token request only when the
+// server ended the flow: success, access_denied or expired_token. Polling states
+// and transport failures are not outcomes; the poll loop records giving up
+// through AuditDevicePollAbandoned.
+func auditDeviceTokenResult(clientID string, err error) {
+	if err == nil || errors.Is(err, ErrAccessDenied) || errors.Is(err, ErrExpiredToken) {
+		auditTokenEvent(AuditEventTokenCreate, GenerateAccountKey(clientID), err)
+	}
+}
+
+// AuditDevicePollAbandoned records a failed token_create event when a device-code
+// poll for clientID stops without a token: it timed out, was cancelled, or hit an
+// unexpected error. Errors already recorded as server results are skipped.
+func AuditDevicePollAbandoned(clientID string, err error) {
+	if errors.Is(err, ErrAccessDenied) || errors.Is(err, ErrExpiredToken) {
+		return
+	}
+	if err == nil {
+		err = errors.New("device authorization polling gave up")
+	}
+	auditTokenEvent(AuditEventTokenCreate, GenerateAccountKey(clientID), err)
+}
@@ -0,0 +1,77 @@
+package kiro
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestLogAuditEvent(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	LogAuditEvent(logger, AuditEvent{
+		Timestamp:  ts,
+		EventType:  AuditEventTokenRefresh,
+		Provider:   "kiro",
+		AccountKey: "0123456789abcdef",
+		Success:    false,
+		Error:      "token refresh failed (status 400)",
+	})
+
+	entry := hook.LastEntry()
+	if entry == nil {
+		t.Fatal("expected an audit log entry")
+	}
+	if entry.Level != log.InfoLevel {
+		t.Errorf("level = %v, want info", entry.Level)
+	}
+	if !entry.Time.Equal(ts) {
+		t.Errorf("time = %v, want %v", entry.Time, ts)
+	}
+	want := log.Fields{
+		"audit":       true,
+		"event_type":  AuditEventTokenRefresh,
+		"provider":    "kiro",
+		"account_key": "0123456789abcdef",
+		"success":     false,
+		"error":       "token refresh failed (status 400)",
+	}
+	for key, value := range want {
+		if entry.Data[key] != value {
+			t.Errorf("field %s = %v, want %v", key, entry.Data[key], value)
+		}
+	}
+}
+
+func TestDeviceTokenAuditRecordsOnlyTerminalOutcomes(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	auditDeviceTokenResult("client", ErrAuthorizationPending)
+	auditDeviceTokenResult("client", ErrSlowDown)
+	auditDeviceTokenResult("client", errors.New("create token failed (status 500)"))
+	if n := len(hook.AllEntries()); n != 0 {
+		t.Fatalf("expected polling states to be skipped, got %d entries", n)
+	}
+
+	auditDeviceTokenResult("client", nil)
+	if entry := hook.LastEntry(); entry == nil || entry.Data["success"] != true {
+		t.Fatalf("expected a successful audit entry, got %+v", entry)
+	}
+	auditDeviceTokenResult("client", ErrExpiredToken)
+	// Already recorded as a server result above.
+	AuditDevicePollAbandoned("client", ErrExpiredToken)
+	if n := len(hook.AllEntries()); n != 2 {
+		t.Fatalf("entries = %d, want 2", n)
+	}
+
+	AuditDevicePollAbandoned("client", nil)
+	entry := hook.LastEntry()
+	if n := len(hook.AllEntries()); n != 3 || entry.Data["success"] != false || entry.Data["event_type"] != AuditEventTokenCreate {
+		t.Fatalf("entries = %d, last = %+v, want a failed token_create for the abandoned poll", n, entry)
+	}
+}
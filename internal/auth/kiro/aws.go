@@ -7,10 +7,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -52,6 +54,74 @@ type KiroTokenData struct {
 	StartURL string `json:"startUrl,omitempty"`
 	// Region is the OIDC region for IDC login and token refresh
 	Region string `json:"region,omitempty"`
+	// SecondaryStartURL is an optional second IDC start URL the user is also valid
+	// on, used when the primary instance no longer has the client registered
+	SecondaryStartURL string `json:"secondaryStartUrl,omitempty"`
+	// SecondaryRegion is the OIDC region of SecondaryStartURL (derived from the URL when unset)
+	SecondaryRegion string `json:"secondaryRegion,omitempty"`
+	// SecondaryClientID and SecondaryClientSecret are the OIDC client registered
+	// with the secondary instance; client registrations do not carry over
+	// between IDC instances
+	SecondaryClientID     string `json:"secondaryClientId,omitempty"`
+	SecondaryClientSecret string `json:"secondaryClientSecret,omitempty"`
+	// SecondaryRefreshToken is the refresh token issued by the secondary instance
+	// to SecondaryClientID; refresh tokens are not accepted across instances
+	SecondaryRefreshToken string `json:"secondaryRefreshToken,omitempty"`
+}
+
+// PreferredStartURL returns StartURL, or SecondaryStartURL when no primary is set.
+func (t *KiroTokenData) PreferredStartURL() string {
+	if t.StartURL != "" {
+		return t.StartURL
+	}
+	return t.SecondaryStartURL
+}
+
+// Clone returns an independent copy of t, or nil if t is nil. KiroTokenData
+// holds only value fields, so a struct copy is a deep copy; extend this if
+// reference-typed fields are added.
+func (t *KiroTokenData) Clone() *KiroTokenData {
+	if t == nil {
+		return nil
+	}
+	clone := *t
+	return &clone
+}
+
+// Validate returns the names of required fields that are empty: AccessToken,
+// RefreshToken, ExpiresAt and AuthMethod. An empty result means the token is usable.
+func (t *KiroTokenData) Validate() []string {
+	if t == nil {
+		return []string{"AccessToken", "RefreshToken", "ExpiresAt", "AuthMethod"}
+	}
+	var missing []string
+	if strings.TrimSpace(t.AccessToken) == "" {
+		missing = append(missing, "AccessToken")
+	}
+	if strings.TrimSpace(t.RefreshToken) == "" {
+		missing = append(missing, "RefreshToken")
+	}
+	if strings.TrimSpace(t.ExpiresAt) == "" {
+		missing = append(missing, "ExpiresAt")
+	}
+	if strings.TrimSpace(t.AuthMethod) == "" {
+		missing = append(missing, "AuthMethod")
+	}
+	return missing
+}
+
+// TimeToExpiry returns how long the access token stays valid at now; a negative
+// duration means it has expired. ok is false when the expiry is unknown because
+// ExpiresAt is empty or not RFC3339.
+func (t *KiroTokenData) TimeToExpiry(now time.Time) (remaining time.Duration, ok bool) {
+	if t == nil {
+		return 0, false
+	}
+	expiresAt := ParseExpiresAt(strings.TrimSpace(t.ExpiresAt))
+	if expiresAt.IsZero() {
+		return 0, false
+	}
+	return expiresAt.Sub(now), true
 }
 
 // KiroAuthBundle aggregates authentication data after OAuth flow completion
@@ -354,25 +424,46 @@ func LoadAllKiroTokens() ([]*KiroTokenData, error) {
 // JWTClaims represents the claims we care about from a JWT token.
 // JWT tokens from Kiro/AWS contain user information in the payload.
 type JWTClaims struct {
-	Email         string `json:"email,omitempty"`
-	Sub           string `json:"sub,omitempty"`
-	PreferredUser string `json:"preferred_username,omitempty"`
-	Name          string `json:"name,omitempty"`
-	Iss           string `json:"iss,omitempty"`
+	Email           string      `json:"email,omitempty"`
+	Sub             string      `json:"sub,omitempty"`
+	PreferredUser   string      `json:"preferred_username,omitempty"`
+	Name            string      `json:"name,omitempty"`
+	Iss             string      `json:"iss,omitempty"`
+	Aud             JWTAudience `json:"aud,omitempty"`
+	IdentityStoreID string      `json:"identity_store_id,omitempty"`
+	InstanceArn     string      `json:"instance_arn,omitempty"`
 }
 
-// ExtractEmailFromJWT extracts the user's email from a JWT access token.
+// JWTAudience holds the "aud" claim, which may be a single string or an array.
+type JWTAudience []string
+
+// UnmarshalJSON accepts both the string and array forms of the audience claim.
+func (a *JWTAudience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = JWTAudience{single}
+		return nil
+	}
+	var multi []string
+	if err := json.Unmarshal(data, &multi); err != nil {
+		return fmt.Errorf("invalid aud claim: %w", err)
+	}
+	*a = multi
+	return nil
+}
+
+// ParseJWTClaims decodes the payload of a JWT without verifying its signature.
 // JWT tokens typically have format: header.payload.signature
 // The payload is base64url-encoded JSON containing user claims.
-func ExtractEmailFromJWT(accessToken string) string {
-	if accessToken == "" {
-		return ""
+func ParseJWTClaims(token string) (*JWTClaims, error) {
+	if token == "" {
+		return nil, fmt.Errorf("empty token")
 	}
 
 	// JWT format: header.payload.signature
-	parts := strings.Split(accessToken, ".")
+	parts := strings.Split(token, ".")
 	if len(parts) != 3 {
-		return ""
+		return nil, fmt.Errorf("invalid JWT format: expected 3 parts, got %d", len(parts))
 	}
 
 	// Decode the payload (second part)
@@ -391,12 +482,21 @@ func ExtractEmailFromJWT(accessToken string) string {
 		// Try RawURLEncoding (no padding)
 		decoded, err = base64.RawURLEncoding.DecodeString(parts[1])
 		if err != nil {
-			return ""
+			return nil, fmt.Errorf("failed to decode JWT payload: %w", err)
 		}
 	}
 
 	var claims JWTClaims
 	if err := json.Unmarshal(decoded, &claims); err != nil {
+		return nil, fmt.Errorf("failed to parse JWT claims: %w", err)
+	}
+	return &claims, nil
+}
+
+// ExtractEmailFromJWT extracts the user's email from a JWT access token.
+func ExtractEmailFromJWT(accessToken string) string {
+	claims, err := ParseJWTClaims(accessToken)
+	if err != nil {
 		return ""
 	}
 
@@ -418,6 +518,75 @@ func ExtractEmailFromJWT(accessToken string) string {
 	return ""
 }
 
+// ErrRefreshIdentityMismatch is returned when a refreshed access token belongs to a
+// different user than the token it replaces. The refreshed token must not be
+// persisted, or one account's requests would be served under another's quota.
+var ErrRefreshIdentityMismatch = errors.New("refreshed token belongs to a different account")
+
+// VerifyRefreshedIdentity checks that newAccessToken identifies the same user as
+// the stored token: its email must match storedEmail (case-insensitively) and
+// its sub must match the sub of previousAccessToken. Claims that are missing on
+// either side, e.g. for opaque tokens, are not compared.
+func VerifyRefreshedIdentity(storedEmail, previousAccessToken, newAccessToken string) error {
+	newClaims, err := ParseJWTClaims(newAccessToken)
+	if err != nil {
+		return nil
+	}
+
+	storedEmail = strings.TrimSpace(storedEmail)
+	if storedEmail == "" {
+		storedEmail = ExtractEmailFromJWT(previousAccessToken)
+	}
+	if newEmail := ExtractEmailFromJWT(newAccessToken); storedEmail != "" && newEmail != "" && !strings.EqualFold(storedEmail, newEmail) {
+		return fmt.Errorf("%w: email %s does not match stored %s", ErrRefreshIdentityMismatch, newEmail, storedEmail)
+	}
+
+	if previousClaims, errPrevious := ParseJWTClaims(previousAccessToken); errPrevious == nil &&
+		previousClaims.Sub != "" && newClaims.Sub != "" && previousClaims.Sub != newClaims.Sub {
+		return fmt.Errorf("%w: sub %s does not match stored %s", ErrRefreshIdentityMismatch, newClaims.Sub, previousClaims.Sub)
+	}
+	return nil
+}
+
+// builderIDStartHost is the AWS Builder ID portal host; IDC portals use other awsapps.com hosts.
+const builderIDStartHost = "view.awsapps.com"
+
+// DetectAuthMethodFromJWT classifies a token as "idc" or "builder-id" from its claims.
+// IDC is indicated by identity-store or instance claims, or an organization
+// awsapps.com issuer; Builder ID by the view.awsapps.com issuer or a Builder ID
+// audience. Returns "" when the token cannot be parsed or the claims are ambiguous.
+func DetectAuthMethodFromJWT(token string) string {
+	claims, err := ParseJWTClaims(token)
+	if err != nil {
+		return ""
+	}
+
+	if claims.IdentityStoreID != "" || strings.Contains(claims.InstanceArn, ":instance/") {
+		return "idc"
+	}
+
+	host := ""
+	if claims.Iss != "" {
+		if u, errParse := url.Parse(claims.Iss); errParse == nil {
+			host = strings.ToLower(u.Hostname())
+		}
+	}
+	switch {
+	case host == builderIDStartHost:
+		return "builder-id"
+	case strings.HasSuffix(host, ".awsapps.com") || strings.HasPrefix(host, "identitystore."):
+		return "idc"
+	}
+
+	for _, aud := range claims.Aud {
+		aud = strings.ToLower(aud)
+		if strings.Contains(aud, "builderid") || strings.Contains(aud, "builder-id") {
+			return "builder-id"
+		}
+	}
+	return ""
+}
+
 // SanitizeEmailForFilename sanitizes an email address for use in a filename.
 // Replaces special characters with underscores and prevents path traversal attacks.
 // Also handles URL-encoded characters to prevent encoded path traversal attempts.
@@ -426,38 +595,63 @@ func SanitizeEmailForFilename(email string) string {
 		return ""
 	}
 
-	result := email
-
-	// First, handle URL-encoded path traversal attempts (%2F, %2E, %5C, etc.)
-	// This prevents encoded characters from bypassing the sanitization.
-	// Note: We replace % last to catch any remaining encodings including double-encoding (%252F)
-	result = strings.ReplaceAll(result, "%2F", "_") // /
-	result = strings.ReplaceAll(result, "%2f", "_")
-	result = strings.ReplaceAll(result, "%5C", "_") // \
-	result = strings.ReplaceAll(result, "%5c", "_")
-	result = strings.ReplaceAll(result, "%2E", "_") // .
-	result = strings.ReplaceAll(result, "%2e", "_")
-	result = strings.ReplaceAll(result, "%00", "_") // null byte
-	result = strings.ReplaceAll(result, "%", "_")   // Catch remaining % to prevent double-encoding attacks
-
-	// Replace characters that are problematic in filenames
-	// Keep @ and . in middle but replace other special characters
-	for _, char := range []string{"/", "\\", ":", "*", "?", "\"", "<", ">", "|", " ", "\x00"} {
-		result = strings.ReplaceAll(result, char, "_")
-	}
-
-	// Prevent path traversal: replace leading dots in each path component
-	// This handles cases like "../../../etc/passwd" → "_.._.._.._etc_passwd"
-	parts := strings.Split(result, "_")
-	for i, part := range parts {
-		for strings.HasPrefix(part, ".") {
-			part = "_" + part[1:]
+	// Single pass over the bytes: every character we rewrite is ASCII, so byte
+	// scanning leaves multi-byte UTF-8 sequences untouched.
+	var b strings.Builder
+	b.Grow(len(email))
+
+	// prev is the previous character after encoding/special-character mapping,
+	// before leading-dot handling, so "_.." becomes "__." rather than "___".
+	var prev byte
+	for i := 0; i < len(email); i++ {
+		c := email[i]
+		switch {
+		case c == '%':
+			// Handle URL-encoded path traversal attempts (%2F, %2E, %5C, %00) and
+			// any remaining % to prevent double-encoding attacks (%252F).
+			if i+2 < len(email) && isEncodedUnsafeChar(email[i+1], email[i+2]) {
+				i += 2
+			}
+			c = '_'
+		case isUnsafeFilenameChar(c):
+			c = '_'
+		}
+
+		// Prevent path traversal: replace a leading dot in each "_"-separated component.
+		if c == '.' && (b.Len() == 0 || prev == '_') {
+			prev = c
+			b.WriteByte('_')
+			continue
 		}
-		parts[i] = part
+		prev = c
+		b.WriteByte(c)
 	}
-	result = strings.Join(parts, "_")
 
-	return result
+	return b.String()
+}
+
+// isEncodedUnsafeChar reports whether the two hex digits following a % encode
+// a slash, backslash, dot or null byte.
+func isEncodedUnsafeChar(hi, lo byte) bool {
+	switch hi {
+	case '2':
+		return lo == 'F' || lo == 'f' || lo == 'E' || lo == 'e'
+	case '5':
+		return lo == 'C' || lo == 'c'
+	case '0':
+		return lo == '0'
+	}
+	return false
+}
+
+// isUnsafeFilenameChar reports whether c is problematic in filenames.
+// @ and . are kept so the email stays readable.
+func isUnsafeFilenameChar(c byte) bool {
+	switch c {
+	case '/', '\\', ':', '*', '?', '"', '<', '>', '|', ' ', 0:
+		return true
+	}
+	return false
 }
 
 // ExtractIDCIdentifier extracts a unique identifier from IDC startUrl.
@@ -490,31 +684,31 @@ func ExtractIDCIdentifier(startURL string) string {
 }
 
 // GenerateTokenFileName generates a unique filename for token storage.
-// Priority: email > startUrl identifier (for IDC) > authMethod only
+// Priority: email > startUrl identifier (for IDC, primary start URL first) > authMethod only
 // Email is unique, so no sequence suffix needed. Sequence is only added
 // when email is unavailable to prevent filename collisions.
 // Format: kiro-{authMethod}-{identifier}[-{seq}].json
 func GenerateTokenFileName(tokenData *KiroTokenData) string {
 	authMethod := tokenData.AuthMethod
+	if authMethod == "" {
+		authMethod = DetectAuthMethodFromJWT(tokenData.AccessToken)
+	}
 	if authMethod == "" {
 		authMethod = "unknown"
 	}
 
 	// Priority 1: Use email if available (no sequence needed, email is unique)
 	if tokenData.Email != "" {
-		// Sanitize email for filename (replace @ and . with -)
-		sanitizedEmail := tokenData.Email
-		sanitizedEmail = strings.ReplaceAll(sanitizedEmail, "@", "-")
-		sanitizedEmail = strings.ReplaceAll(sanitizedEmail, ".", "-")
-		return fmt.Sprintf("kiro-%s-%s.json", authMethod, sanitizedEmail)
+		// Sanitize email for filename (replace @, . and unsafe characters with -)
+		return fmt.Sprintf("kiro-%s-%s.json", authMethod, sanitizeFileNamePart(tokenData.Email))
 	}
 
 	// Generate sequence only when email is unavailable
 	seq := time.Now().UnixNano() % 100000
 
 	// Priority 2: For IDC, use startUrl identifier with sequence
-	if authMethod == "idc" && tokenData.StartURL != "" {
-		identifier := ExtractIDCIdentifier(tokenData.StartURL)
+	if startURL := tokenData.PreferredStartURL(); authMethod == "idc" && startURL != "" {
+		identifier := ExtractIDCIdentifier(startURL)
 		if identifier != "" {
 			return fmt.Sprintf("kiro-%s-%s-%05d.json", authMethod, identifier, seq)
 		}
@@ -527,6 +721,55 @@ func GenerateTokenFileName(tokenData *KiroTokenData) string {
 // DefaultKiroRegion is the fallback region when none is specified.
 const DefaultKiroRegion = "us-east-1"
 
+var (
+	defaultRegionMu sync.RWMutex
+	defaultRegion   = DefaultKiroRegion
+)
+
+func init() {
+	loadDefaultRegionFromEnv()
+}
+
+// loadDefaultRegionFromEnv sets the default region from AWS_REGION or, failing
+// that, AWS_DEFAULT_REGION. Unknown regions are logged and ignored.
+func loadDefaultRegionFromEnv() {
+	for _, key := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		region := strings.TrimSpace(os.Getenv(key))
+		if region == "" {
+			continue
+		}
+		if errSet := SetDefaultRegion(region); errSet != nil {
+			log.Warnf("kiro: ignoring %s: %v", key, errSet)
+			continue
+		}
+		return
+	}
+}
+
+// SetDefaultRegion sets the region ExtractRegionFromMetadata falls back to when a
+// token has neither api_region nor a usable profile_arn. The region must be one
+// of the known Kiro regions; an empty region restores DefaultKiroRegion.
+func SetDefaultRegion(region string) error {
+	region = strings.TrimSpace(region)
+	if region == "" {
+		region = DefaultKiroRegion
+	}
+	if _, ok := regionToEndpoint[region]; !ok {
+		return fmt.Errorf("unknown kiro region %q", region)
+	}
+	defaultRegionMu.Lock()
+	defaultRegion = region
+	defaultRegionMu.Unlock()
+	return nil
+}
+
+// DefaultRegion returns the configured fallback region.
+func DefaultRegion() string {
+	defaultRegionMu.RLock()
+	defer defaultRegionMu.RUnlock()
+	return defaultRegion
+}
+
 // GetCodeWhispererLegacyEndpoint returns the legacy CodeWhisperer JSON-RPC endpoint.
 // This endpoint supports JSON-RPC style requests with x-amz-target headers.
 // The Q endpoint (q.{region}.amazonaws.com) does NOT support JSON-RPC style.
@@ -557,6 +800,15 @@ type ProfileARN struct {
 	ResourceID string
 }
 
+// validPartitions lists the AWS partitions accepted in profile ARNs.
+var validPartitions = map[string]struct{}{
+	"aws":        {},
+	"aws-cn":     {},
+	"aws-us-gov": {},
+	"aws-iso":    {},
+	"aws-iso-b":  {},
+}
+
 // ParseProfileARN parses an AWS ARN string into a ProfileARN struct.
 // Returns nil if the ARN is empty, invalid, or not a codewhisperer ARN.
 func ParseProfileARN(arn string) *ProfileARN {
@@ -576,7 +828,7 @@ func ParseProfileARN(arn string) *ProfileARN {
 	}
 	// Validate partition
 	partition := parts[1]
-	if partition == "" {
+	if _, ok := validPartitions[partition]; !ok {
 		return nil
 	}
 	// Validate service is codewhisperer
@@ -615,20 +867,112 @@ func ParseProfileARN(arn string) *ProfileARN {
 	}
 }
 
+// regionToEndpoint maps known regions to their Q API endpoint. GovCloud, China
+// and ISO regions have their own subdomains and domains.
+var regionToEndpoint = map[string]string{
+	"us-east-1":      "https://q.us-east-1.amazonaws.com",
+	"us-east-2":      "https://q.us-east-2.amazonaws.com",
+	"us-west-1":      "https://q.us-west-1.amazonaws.com",
+	"us-west-2":      "https://q.us-west-2.amazonaws.com",
+	"ca-central-1":   "https://q.ca-central-1.amazonaws.com",
+	"eu-central-1":   "https://q.eu-central-1.amazonaws.com",
+	"eu-west-1":      "https://q.eu-west-1.amazonaws.com",
+	"eu-west-2":      "https://q.eu-west-2.amazonaws.com",
+	"eu-west-3":      "https://q.eu-west-3.amazonaws.com",
+	"eu-north-1":     "https://q.eu-north-1.amazonaws.com",
+	"ap-northeast-1": "https://q.ap-northeast-1.amazonaws.com",
+	"ap-northeast-2": "https://q.ap-northeast-2.amazonaws.com",
+	"ap-south-1":     "https://q.ap-south-1.amazonaws.com",
+	"ap-southeast-1": "https://q.ap-southeast-1.amazonaws.com",
+	"ap-southeast-2": "https://q.ap-southeast-2.amazonaws.com",
+	"sa-east-1":      "https://q.sa-east-1.amazonaws.com",
+	"us-gov-east-1":  "https://q.us-gov-east-1.amazonaws.com",
+	"us-gov-west-1":  "https://q.us-gov-west-1.amazonaws.com",
+	"cn-north-1":     "https://q.cn-north-1.amazonaws.com.cn",
+	"cn-northwest-1": "https://q.cn-northwest-1.amazonaws.com.cn",
+	"us-iso-east-1":  "https://q.us-iso-east-1.c2s.ic.gov",
+	"us-iso-west-1":  "https://q.us-iso-west-1.c2s.ic.gov",
+	"us-isob-east-1": "https://q.us-isob-east-1.sc2s.sgov.gov",
+}
+
+// unknownRegionsLogged records regions GetKiroAPIEndpoint already warned about,
+// so a token with an unlisted region logs once rather than on every request.
+var unknownRegionsLogged sync.Map
+
 // GetKiroAPIEndpoint returns the Q API endpoint for the specified region.
-// If region is empty, defaults to us-east-1.
+// If region is empty, defaults to us-east-1. Unknown regions are logged and
+// used as given (with a one-time warning per region); China and ISO regions use
+// their partition's domain.
 func GetKiroAPIEndpoint(region string) string {
 	if region == "" {
 		region = DefaultKiroRegion
 	}
-	return "https://q." + region + ".amazonaws.com"
+	if endpoint, ok := regionToEndpoint[region]; ok {
+		return endpoint
+	}
+	if _, seen := unknownRegionsLogged.LoadOrStore(region, struct{}{}); !seen {
+		log.Warnf("kiro: unknown region %q, building endpoint from it anyway", region)
+	}
+	return GetKiroAPIEndpointForPartition(region, partitionForRegion(region))
+}
+
+// GetCodeWhispererEndpoint returns the CodeWhisperer API endpoint for the specified
+// region, using the amazonaws.com.cn domain for China regions (cn-*) and the ISO
+// domains for us-iso-* and us-isob-* regions.
+// If region is empty, defaults to us-east-1.
+func GetCodeWhispererEndpoint(region string) string {
+	if region == "" {
+		region = DefaultKiroRegion
+	}
+	return "https://codewhisperer." + region + "." + dnsSuffixForPartition(partitionForRegion(region))
+}
+
+// partitionForRegion guesses the AWS partition of a region from its name.
+func partitionForRegion(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-isob-"):
+		return "aws-iso-b"
+	case strings.HasPrefix(region, "us-iso-"):
+		return "aws-iso"
+	default:
+		return "aws"
+	}
+}
+
+// GetKiroAPIEndpointForPartition returns the Q API endpoint for region in the given
+// AWS partition, using the partition's domain (amazonaws.com, amazonaws.com.cn, or
+// the ISO domains). If region is empty, defaults to us-east-1.
+func GetKiroAPIEndpointForPartition(region, partition string) string {
+	if region == "" {
+		region = DefaultKiroRegion
+	}
+	return "https://q." + region + "." + dnsSuffixForPartition(partition)
 }
 
-// GetKiroAPIEndpointFromProfileArn extracts region from profileArn and returns the endpoint.
-// Returns default us-east-1 endpoint if region cannot be extracted.
+// dnsSuffixForPartition returns the service domain suffix for an AWS partition.
+func dnsSuffixForPartition(partition string) string {
+	switch partition {
+	case "aws-cn":
+		return "amazonaws.com.cn"
+	case "aws-iso":
+		return "c2s.ic.gov"
+	case "aws-iso-b":
+		return "sc2s.sgov.gov"
+	default:
+		return "amazonaws.com"
+	}
+}
+
+// GetKiroAPIEndpointFromProfileArn extracts region and partition from profileArn and
+// returns the endpoint. Returns default us-east-1 endpoint if the ARN cannot be parsed.
 func GetKiroAPIEndpointFromProfileArn(profileArn string) string {
-	region := ExtractRegionFromProfileArn(profileArn)
-	return GetKiroAPIEndpoint(region)
+	parsed := ParseProfileARN(profileArn)
+	if parsed == nil {
+		return GetKiroAPIEndpoint("")
+	}
+	return GetKiroAPIEndpointForPartition(parsed.Region, parsed.Partition)
 }
 
 // ExtractRegionFromProfileArn extracts the AWS region from a ProfileARN string.
@@ -641,11 +985,21 @@ func ExtractRegionFromProfileArn(profileArn string) string {
 	return parsed.Region
 }
 
+// ExtractAccountIDFromProfileArn extracts the AWS account ID from a ProfileARN string.
+// Returns empty string if ARN is invalid or account ID cannot be extracted.
+func ExtractAccountIDFromProfileArn(profileArn string) string {
+	parsed := ParseProfileARN(profileArn)
+	if parsed == nil {
+		return ""
+	}
+	return parsed.AccountID
+}
+
 // ExtractRegionFromMetadata extracts API region from auth metadata.
-// Priority: api_region > profile_arn > DefaultKiroRegion
+// Priority: api_region > profile_arn > DefaultRegion()
 func ExtractRegionFromMetadata(metadata map[string]interface{}) string {
 	if metadata == nil {
-		return DefaultKiroRegion
+		return DefaultRegion()
 	}
 
 	// Priority 1: Explicit api_region override
@@ -660,7 +1014,72 @@ func ExtractRegionFromMetadata(metadata map[string]interface{}) string {
 		}
 	}
 
-	return DefaultKiroRegion
+	return DefaultRegion()
+}
+
+// RewriteTokenRegion updates Kiro token files in dir (including provider
+// subdirectories) whose resolved API region is fromRegion so that api_region
+// becomes toRegion. profile_arn is left intact. Files are rewritten atomically;
+// files that cannot be read or parsed are skipped. It returns the number of
+// files changed.
+func RewriteTokenRegion(dir, fromRegion, toRegion string) (changed int, err error) {
+	fromRegion = strings.TrimSpace(fromRegion)
+	toRegion = strings.TrimSpace(toRegion)
+	if fromRegion == "" || toRegion == "" {
+		return 0, fmt.Errorf("rewrite token region: from and to regions are required")
+	}
+	if fromRegion == toRegion {
+		return 0, nil
+	}
+
+	errWalk := filepath.WalkDir(dir, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			if path == dir {
+				return walkErr
+			}
+			return nil
+		}
+		if d.IsDir() || !strings.HasSuffix(strings.ToLower(d.Name()), ".json") {
+			return nil
+		}
+
+		data, errRead := os.ReadFile(path)
+		if errRead != nil {
+			log.Debugf("rewrite token region: skipping %s: %v", path, errRead)
+			return nil
+		}
+		var metadata map[string]interface{}
+		if errUnmarshal := json.Unmarshal(data, &metadata); errUnmarshal != nil {
+			log.Debugf("rewrite token region: skipping %s: %v", path, errUnmarshal)
+			return nil
+		}
+		if tokenType, _ := metadata["type"].(string); tokenType != "kiro" {
+			return nil
+		}
+		if ExtractRegionFromMetadata(metadata) != fromRegion {
+			return nil
+		}
+
+		metadata["api_region"] = toRegion
+		raw, errMarshal := json.MarshalIndent(metadata, "", "  ")
+		if errMarshal != nil {
+			return fmt.Errorf("rewrite token region: marshal %s: %w", path, errMarshal)
+		}
+		tmpPath := path + ".tmp"
+		if errWrite := os.WriteFile(tmpPath, raw, 0600); errWrite != nil {
+			return fmt.Errorf("rewrite token region: write %s: %w", tmpPath, errWrite)
+		}
+		if errRename := os.Rename(tmpPath, path); errRename != nil {
+			_ = os.Remove(tmpPath)
+			return fmt.Errorf("rewrite token region: rename %s: %w", path, errRename)
+		}
+		changed++
+		return nil
+	})
+	if errWalk != nil {
+		return changed, errWalk
+	}
+	return changed, nil
 }
 
 func buildURL(endpoint, path string, queryParams map[string]string) string {
@@ -14,7 +14,6 @@ import (
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
-	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -27,7 +26,7 @@ const (
 // It provides methods for loading tokens, refreshing expired tokens,
 // and communicating with the CodeWhisperer API.
 type KiroAuth struct {
-	httpClient *http.Client
+	httpClient *KiroHTTPClient
 }
 
 // NewKiroAuth creates a new Kiro authentication service.
@@ -38,9 +37,12 @@ type KiroAuth struct {
 //
 // Returns:
 //   - *KiroAuth: A new Kiro authentication service instance
-func NewKiroAuth(cfg *config.Config) *KiroAuth {
+func NewKiroAuth(cfg *config.Config, opts ...ClientOption) *KiroAuth {
+	if len(opts) == 0 {
+		return &KiroAuth{httpClient: NewKiroHTTPClient(cfg)}
+	}
 	return &KiroAuth{
-		httpClient: util.SetProxy(&cfg.SDKConfig, &http.Client{Timeout: 120 * time.Second}),
+		httpClient: wrapHTTPClient(newHTTPClient(cfg, 120*time.Second, opts)),
 	}
 }
 
@@ -124,9 +126,7 @@ func (k *KiroAuth) makeRequest(ctx context.Context, path string, tokenData *Kiro
 	}
 
 	accountKey := GetAccountKey(tokenData.ClientID, tokenData.RefreshToken)
-	setRuntimeHeaders(req, tokenData.AccessToken, accountKey)
-
-	resp, err := k.httpClient.Do(req)
+	resp, err := k.httpClient.DoRuntime(req, tokenData.AccessToken, accountKey)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -277,6 +277,12 @@ func (k *KiroAuth) CreateTokenStorage(tokenData *KiroTokenData) *KiroTokenStorag
 		Region:       tokenData.Region,
 		StartURL:     tokenData.StartURL,
 		Email:        tokenData.Email,
+
+		SecondaryStartURL:     tokenData.SecondaryStartURL,
+		SecondaryRegion:       tokenData.SecondaryRegion,
+		SecondaryClientID:     tokenData.SecondaryClientID,
+		SecondaryClientSecret: tokenData.SecondaryClientSecret,
+		SecondaryRefreshToken: tokenData.SecondaryRefreshToken,
 	}
 }
 
@@ -320,6 +326,21 @@ func (k *KiroAuth) UpdateTokenStorage(storage *KiroTokenStorage, tokenData *Kiro
 	if tokenData.StartURL != "" {
 		storage.StartURL = tokenData.StartURL
 	}
+	if tokenData.SecondaryStartURL != "" {
+		storage.SecondaryStartURL = tokenData.SecondaryStartURL
+	}
+	if tokenData.SecondaryRegion != "" {
+		storage.SecondaryRegion = tokenData.SecondaryRegion
+	}
+	if tokenData.SecondaryClientID != "" {
+		storage.SecondaryClientID = tokenData.SecondaryClientID
+	}
+	if tokenData.SecondaryClientSecret != "" {
+		storage.SecondaryClientSecret = tokenData.SecondaryClientSecret
+	}
+	if tokenData.SecondaryRefreshToken != "" {
+		storage.SecondaryRefreshToken = tokenData.SecondaryRefreshToken
+	}
 	if tokenData.Email != "" {
 		storage.Email = tokenData.Email
 	}
@@ -3,8 +3,14 @@ package kiro
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
 )
 
 func TestExtractEmailFromJWT(t *testing.T) {
@@ -149,6 +155,21 @@ func TestSanitizeEmailForFilename(t *testing.T) {
 	}
 }
 
+func BenchmarkSanitizeEmailForFilename(b *testing.B) {
+	emails := []string{
+		"user@example.com",
+		"first.last+tag@sub.example.co.uk",
+		"user%2F..%2F..%2Fetc@example.com",
+		"..%252F..%5c\\evil: name?@example.com",
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, email := range emails {
+			_ = SanitizeEmailForFilename(email)
+		}
+	}
+}
+
 // createTestJWT creates a test JWT token with the given claims
 func createTestJWT(claims map[string]any) string {
 	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
@@ -248,6 +269,23 @@ func TestGenerateTokenFileName(t *testing.T) {
 			},
 			prefix: "kiro-idc-my-company-",
 		},
+		{
+			name: "IDC prefers primary over secondary startUrl",
+			tokenData: &KiroTokenData{
+				AuthMethod:        "idc",
+				StartURL:          "https://primary-org.awsapps.com/start",
+				SecondaryStartURL: "https://secondary-org.awsapps.com/start",
+			},
+			prefix: "kiro-idc-primary-org-",
+		},
+		{
+			name: "IDC with only a secondary startUrl",
+			tokenData: &KiroTokenData{
+				AuthMethod:        "idc",
+				SecondaryStartURL: "https://secondary-org.awsapps.com/start",
+			},
+			prefix: "kiro-idc-secondary-org-",
+		},
 		{
 			name: "IDC without email and without startUrl",
 			tokenData: &KiroTokenData{
@@ -411,6 +449,50 @@ func TestParseProfileARN(t *testing.T) {
 				ResourceID:   "CHINAID",
 			},
 		},
+		{
+			name: "Valid ARN - aws-us-gov partition",
+			arn:  "arn:aws-us-gov:codewhisperer:us-gov-west-1:123456789012:profile/GOVID",
+			expected: &ProfileARN{
+				Raw:          "arn:aws-us-gov:codewhisperer:us-gov-west-1:123456789012:profile/GOVID",
+				Partition:    "aws-us-gov",
+				Service:      "codewhisperer",
+				Region:       "us-gov-west-1",
+				AccountID:    "123456789012",
+				ResourceType: "profile",
+				ResourceID:   "GOVID",
+			},
+		},
+		{
+			name: "Valid ARN - aws-iso partition",
+			arn:  "arn:aws-iso:codewhisperer:us-iso-east-1:123456789012:profile/ISOID",
+			expected: &ProfileARN{
+				Raw:          "arn:aws-iso:codewhisperer:us-iso-east-1:123456789012:profile/ISOID",
+				Partition:    "aws-iso",
+				Service:      "codewhisperer",
+				Region:       "us-iso-east-1",
+				AccountID:    "123456789012",
+				ResourceType: "profile",
+				ResourceID:   "ISOID",
+			},
+		},
+		{
+			name: "Valid ARN - aws-iso-b partition",
+			arn:  "arn:aws-iso-b:codewhisperer:us-isob-east-1:123456789012:profile/ISOBID",
+			expected: &ProfileARN{
+				Raw:          "arn:aws-iso-b:codewhisperer:us-isob-east-1:123456789012:profile/ISOBID",
+				Partition:    "aws-iso-b",
+				Service:      "codewhisperer",
+				Region:       "us-isob-east-1",
+				AccountID:    "123456789012",
+				ResourceType: "profile",
+				ResourceID:   "ISOBID",
+			},
+		},
+		{
+			name:     "Unknown partition",
+			arn:      "arn:aws-fake:codewhisperer:us-east-1:123456789012:profile/ABC",
+			expected: nil,
+		},
 		{
 			name: "Valid ARN - resource without slash",
 			arn:  "arn:aws:codewhisperer:us-west-2:123456789012:profile",
@@ -525,6 +607,54 @@ func TestExtractRegionFromProfileArn(t *testing.T) {
 	}
 }
 
+func TestExtractAccountIDFromProfileArn(t *testing.T) {
+	tests := []struct {
+		name       string
+		profileArn string
+		expected   string
+	}{
+		{
+			name:       "Empty ARN",
+			profileArn: "",
+			expected:   "",
+		},
+		{
+			name:       "Invalid ARN",
+			profileArn: "invalid-arn",
+			expected:   "",
+		},
+		{
+			name:       "Valid ARN - aws partition",
+			profileArn: "arn:aws:codewhisperer:us-east-1:123456789012:profile/ABC",
+			expected:   "123456789012",
+		},
+		{
+			name:       "Valid ARN - aws-cn partition",
+			profileArn: "arn:aws-cn:codewhisperer:cn-north-1:210987654321:profile/XYZ",
+			expected:   "210987654321",
+		},
+		{
+			name:       "Non-codewhisperer ARN",
+			profileArn: "arn:aws:s3:us-east-1:123456789012:bucket/mybucket",
+			expected:   "",
+		},
+		{
+			name:       "Too few parts",
+			profileArn: "arn:aws:codewhisperer:us-east-1:123456789012",
+			expected:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ExtractAccountIDFromProfileArn(tt.profileArn)
+			if result != tt.expected {
+				t.Errorf("ExtractAccountIDFromProfileArn(%q) = %q, want %q", tt.profileArn, result, tt.expected)
+			}
+		})
+	}
+}
+
 func TestGetKiroAPIEndpoint(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -559,7 +689,52 @@ func TestGetKiroAPIEndpoint(t *testing.T) {
 		{
 			name:     "cn-north-1",
 			region:   "cn-north-1",
-			expected: "https://q.cn-north-1.amazonaws.com",
+			expected: "https://q.cn-north-1.amazonaws.com.cn",
+		},
+		{
+			name:     "cn-northwest-1",
+			region:   "cn-northwest-1",
+			expected: "https://q.cn-northwest-1.amazonaws.com.cn",
+		},
+		{
+			name:     "GovCloud us-gov-east-1",
+			region:   "us-gov-east-1",
+			expected: "https://q.us-gov-east-1.amazonaws.com",
+		},
+		{
+			name:     "GovCloud us-gov-west-1",
+			region:   "us-gov-west-1",
+			expected: "https://q.us-gov-west-1.amazonaws.com",
+		},
+		{
+			name:     "Unknown region - used as given",
+			region:   "xx-moon-1",
+			expected: "https://q.xx-moon-1.amazonaws.com",
+		},
+		{
+			name:     "Unknown China region - China domain",
+			region:   "cn-south-9",
+			expected: "https://q.cn-south-9.amazonaws.com.cn",
+		},
+		{
+			name:     "ISO us-iso-east-1",
+			region:   "us-iso-east-1",
+			expected: "https://q.us-iso-east-1.c2s.ic.gov",
+		},
+		{
+			name:     "ISO-B us-isob-east-1",
+			region:   "us-isob-east-1",
+			expected: "https://q.us-isob-east-1.sc2s.sgov.gov",
+		},
+		{
+			name:     "Unknown ISO region - ISO domain",
+			region:   "us-iso-south-9",
+			expected: "https://q.us-iso-south-9.c2s.ic.gov",
+		},
+		{
+			name:     "Unknown ISO-B region - ISO-B domain",
+			region:   "us-isob-west-9",
+			expected: "https://q.us-isob-west-9.sc2s.sgov.gov",
 		},
 	}
 
@@ -573,6 +748,34 @@ func TestGetKiroAPIEndpoint(t *testing.T) {
 	}
 }
 
+func TestGetKiroAPIEndpointWarnsOncePerUnknownRegion(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	for i := 0; i < 3; i++ {
+		GetKiroAPIEndpoint("xx-warn-once-1")
+	}
+	GetKiroAPIEndpoint("us-east-1")
+	if n := len(hook.AllEntries()); n != 1 {
+		t.Fatalf("warnings = %d, want 1", n)
+	}
+}
+
+func TestGetCodeWhispererEndpoint(t *testing.T) {
+	tests := map[string]string{
+		"":               "https://codewhisperer.us-east-1.amazonaws.com",
+		"eu-west-1":      "https://codewhisperer.eu-west-1.amazonaws.com",
+		"cn-north-1":     "https://codewhisperer.cn-north-1.amazonaws.com.cn",
+		"us-iso-east-1":  "https://codewhisperer.us-iso-east-1.c2s.ic.gov",
+		"us-isob-east-1": "https://codewhisperer.us-isob-east-1.sc2s.sgov.gov",
+	}
+	for region, expected := range tests {
+		if result := GetCodeWhispererEndpoint(region); result != expected {
+			t.Errorf("GetCodeWhispererEndpoint(%q) = %q, want %q", region, result, expected)
+		}
+	}
+}
+
 func TestGetKiroAPIEndpointFromProfileArn(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -604,6 +807,26 @@ func TestGetKiroAPIEndpointFromProfileArn(t *testing.T) {
 			profileArn: "arn:aws:codewhisperer:eu-central-1:123456789012:profile/ABC",
 			expected:   "https://q.eu-central-1.amazonaws.com",
 		},
+		{
+			name:       "China ARN - aws-cn partition",
+			profileArn: "arn:aws-cn:codewhisperer:cn-north-1:123456789012:profile/ABC",
+			expected:   "https://q.cn-north-1.amazonaws.com.cn",
+		},
+		{
+			name:       "GovCloud ARN - aws-us-gov partition",
+			profileArn: "arn:aws-us-gov:codewhisperer:us-gov-west-1:123456789012:profile/ABC",
+			expected:   "https://q.us-gov-west-1.amazonaws.com",
+		},
+		{
+			name:       "ISO ARN - aws-iso partition",
+			profileArn: "arn:aws-iso:codewhisperer:us-iso-east-1:123456789012:profile/ABC",
+			expected:   "https://q.us-iso-east-1.c2s.ic.gov",
+		},
+		{
+			name:       "Unknown partition - defaults to us-east-1",
+			profileArn: "arn:aws-fake:codewhisperer:eu-west-1:123456789012:profile/ABC",
+			expected:   "https://q.us-east-1.amazonaws.com",
+		},
 	}
 
 	for _, tt := range tests {
@@ -654,7 +877,20 @@ func TestGetCodeWhispererLegacyEndpoint(t *testing.T) {
 	}
 }
 
+// withDefaultRegion sets the package default region for the duration of the test.
+func withDefaultRegion(t *testing.T, region string) {
+	t.Helper()
+	previous := DefaultRegion()
+	if err := SetDefaultRegion(region); err != nil {
+		t.Fatalf("SetDefaultRegion(%q): %v", region, err)
+	}
+	t.Cleanup(func() {
+		_ = SetDefaultRegion(previous)
+	})
+}
+
 func TestExtractRegionFromMetadata(t *testing.T) {
+	withDefaultRegion(t, DefaultKiroRegion)
 	tests := []struct {
 		name     string
 		metadata map[string]interface{}
@@ -748,3 +984,240 @@ func TestExtractRegionFromMetadata(t *testing.T) {
 		})
 	}
 }
+
+func TestParseJWTClaims(t *testing.T) {
+	claims, err := ParseJWTClaims(createTestJWT(map[string]any{
+		"iss": "https://view.awsapps.com/start",
+		"aud": []string{"a", "b"},
+	}))
+	if err != nil {
+		t.Fatalf("ParseJWTClaims() error = %v", err)
+	}
+	if claims.Iss != "https://view.awsapps.com/start" || len(claims.Aud) != 2 {
+		t.Errorf("ParseJWTClaims() = %+v", claims)
+	}
+
+	claims, err = ParseJWTClaims(createTestJWT(map[string]any{"aud": "single"}))
+	if err != nil || len(claims.Aud) != 1 || claims.Aud[0] != "single" {
+		t.Errorf("ParseJWTClaims(string aud) = %+v, %v", claims, err)
+	}
+
+	for _, token := range []string{"", "not-a-jwt", "a.!!!.c"} {
+		if _, err := ParseJWTClaims(token); err == nil {
+			t.Errorf("ParseJWTClaims(%q) expected error", token)
+		}
+	}
+}
+
+func TestDetectAuthMethodFromJWT(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    string
+		expected string
+	}{
+		{
+			name:     "IDC organization issuer",
+			token:    createTestJWT(map[string]any{"iss": "https://d-1234567890.awsapps.com/start", "sub": "user"}),
+			expected: "idc",
+		},
+		{
+			name:     "IDC identity store claim",
+			token:    createTestJWT(map[string]any{"iss": "https://oidc.us-east-1.amazonaws.com", "identity_store_id": "d-1234567890"}),
+			expected: "idc",
+		},
+		{
+			name:     "IDC instance ARN claim",
+			token:    createTestJWT(map[string]any{"instance_arn": "arn:aws:sso:::instance/ssoins-1234567890abcdef"}),
+			expected: "idc",
+		},
+		{
+			name:     "Builder ID issuer",
+			token:    createTestJWT(map[string]any{"iss": "https://view.awsapps.com/start", "sub": "user"}),
+			expected: "builder-id",
+		},
+		{
+			name:     "Builder ID audience",
+			token:    createTestJWT(map[string]any{"iss": "https://oidc.us-east-1.amazonaws.com", "aud": "AWSBuilderID"}),
+			expected: "builder-id",
+		},
+		{
+			name:     "Ambiguous OIDC token",
+			token:    createTestJWT(map[string]any{"iss": "https://oidc.us-east-1.amazonaws.com", "sub": "user", "aud": "client"}),
+			expected: "",
+		},
+		{
+			name:     "Not a JWT",
+			token:    "opaque-token",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectAuthMethodFromJWT(tt.token); got != tt.expected {
+				t.Errorf("DetectAuthMethodFromJWT() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGenerateTokenFileName_DetectsAuthMethodFromJWT(t *testing.T) {
+	name := GenerateTokenFileName(&KiroTokenData{
+		AccessToken: createTestJWT(map[string]any{"iss": "https://view.awsapps.com/start"}),
+		Email:       "user@example.com",
+	})
+	if name != "kiro-builder-id-user-example-com.json" {
+		t.Errorf("GenerateTokenFileName() = %q, want kiro-builder-id-user-example-com.json", name)
+	}
+}
+
+func TestRewriteTokenRegion(t *testing.T) {
+	dir := t.TempDir()
+	euARN := "arn:aws:codewhisperer:eu-central-1:123456789012:profile/EU"
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-explicit.json"), map[string]any{"api_region": "eu-central-1"})
+	writeTestTokenFile(t, filepath.Join(dir, "kiro", "kiro-arn.json"), map[string]any{"profile_arn": euARN})
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-other.json"), map[string]any{"api_region": "us-east-1", "profile_arn": euARN})
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-default.json"), nil)
+	if err := os.WriteFile(filepath.Join(dir, "kiro-broken.json"), []byte("{not json"), 0600); err != nil {
+		t.Fatalf("write broken token: %v", err)
+	}
+
+	changed, err := RewriteTokenRegion(dir, "eu-central-1", "us-east-1")
+	if err != nil {
+		t.Fatalf("RewriteTokenRegion() error = %v", err)
+	}
+	if changed != 2 {
+		t.Fatalf("RewriteTokenRegion() changed = %d, want 2", changed)
+	}
+
+	for _, name := range []string{"kiro-explicit.json", filepath.Join("kiro", "kiro-arn.json"), "kiro-other.json"} {
+		if got := readTestTokenFile(t, filepath.Join(dir, name))["api_region"]; got != "us-east-1" {
+			t.Errorf("%s api_region = %v, want us-east-1", name, got)
+		}
+	}
+	if got := readTestTokenFile(t, filepath.Join(dir, "kiro", "kiro-arn.json"))["profile_arn"]; got != euARN {
+		t.Errorf("profile_arn = %v, want %s", got, euARN)
+	}
+	if _, ok := readTestTokenFile(t, filepath.Join(dir, "kiro-default.json"))["api_region"]; ok {
+		t.Error("default-region token should not be rewritten")
+	}
+	if raw, _ := os.ReadFile(filepath.Join(dir, "kiro-broken.json")); string(raw) != "{not json" {
+		t.Errorf("broken token was modified: %q", raw)
+	}
+}
+
+func TestRewriteTokenRegion_RequiresRegions(t *testing.T) {
+	if _, err := RewriteTokenRegion(t.TempDir(), "", "us-east-1"); err == nil {
+		t.Fatal("expected error for empty fromRegion")
+	}
+}
+
+func TestExtractRegionFromMetadataDefaultRegionFromEnv(t *testing.T) {
+	tests := []struct {
+		name         string
+		awsRegion    string
+		awsDefRegion string
+		wantFallback string
+	}{
+		{"unset", "", "", DefaultKiroRegion},
+		{"AWS_REGION", "eu-west-1", "", "eu-west-1"},
+		{"AWS_DEFAULT_REGION", "", "ap-southeast-2", "ap-southeast-2"},
+		{"AWS_REGION wins", "eu-central-1", "ap-southeast-2", "eu-central-1"},
+		{"unknown region ignored", "moon-base-1", "", DefaultKiroRegion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withDefaultRegion(t, DefaultKiroRegion)
+			t.Setenv("AWS_REGION", tt.awsRegion)
+			t.Setenv("AWS_DEFAULT_REGION", tt.awsDefRegion)
+			loadDefaultRegionFromEnv()
+
+			if got := ExtractRegionFromMetadata(nil); got != tt.wantFallback {
+				t.Fatalf("fallback region = %q, want %q", got, tt.wantFallback)
+			}
+			metadata := map[string]interface{}{
+				"profile_arn": "arn:aws:codewhisperer:us-west-2:123456789012:profile/ABC",
+			}
+			if got := ExtractRegionFromMetadata(metadata); got != "us-west-2" {
+				t.Fatalf("profile_arn region = %q, want us-west-2", got)
+			}
+			metadata["api_region"] = "sa-east-1"
+			if got := ExtractRegionFromMetadata(metadata); got != "sa-east-1" {
+				t.Fatalf("api_region = %q, want sa-east-1", got)
+			}
+		})
+	}
+}
+
+func TestSetDefaultRegion(t *testing.T) {
+	withDefaultRegion(t, DefaultKiroRegion)
+
+	if err := SetDefaultRegion("eu-north-1"); err != nil {
+		t.Fatalf("SetDefaultRegion: %v", err)
+	}
+	if got := ExtractRegionFromMetadata(map[string]interface{}{}); got != "eu-north-1" {
+		t.Fatalf("fallback region = %q, want eu-north-1", got)
+	}
+	if err := SetDefaultRegion("not-a-region"); err == nil {
+		t.Fatal("expected error for unknown region")
+	}
+	if got := DefaultRegion(); got != "eu-north-1" {
+		t.Fatalf("DefaultRegion() = %q after rejected update, want eu-north-1", got)
+	}
+	if err := SetDefaultRegion(""); err != nil || DefaultRegion() != DefaultKiroRegion {
+		t.Fatalf("SetDefaultRegion(\"\") = %v, DefaultRegion() = %q, want reset to %s", err, DefaultRegion(), DefaultKiroRegion)
+	}
+}
+
+func TestVerifyRefreshedIdentity(t *testing.T) {
+	alice := createTestJWT(map[string]any{"email": "alice@example.com", "sub": "user-alice"})
+	aliceUpper := createTestJWT(map[string]any{"email": "Alice@Example.com", "sub": "user-alice"})
+	bob := createTestJWT(map[string]any{"email": "bob@example.com", "sub": "user-bob"})
+	otherSub := createTestJWT(map[string]any{"sub": "user-other"})
+
+	tests := []struct {
+		name                    string
+		storedEmail, prev, next string
+		wantErr                 bool
+	}{
+		{"same account", "alice@example.com", alice, alice, false},
+		{"email case differs", "alice@example.com", alice, aliceUpper, false},
+		{"different email", "alice@example.com", alice, bob, true},
+		{"email from previous token", "", alice, bob, true},
+		{"different sub", "", alice, otherSub, true},
+		{"opaque new token", "alice@example.com", alice, "opaque-token", false},
+		{"opaque previous token", "", "opaque-token", bob, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyRefreshedIdentity(tt.storedEmail, tt.prev, tt.next)
+			if tt.wantErr != errors.Is(err, ErrRefreshIdentityMismatch) {
+				t.Fatalf("VerifyRefreshedIdentity() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKiroTokenDataTimeToExpiry(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		token     *KiroTokenData
+		want      time.Duration
+		wantKnown bool
+	}{
+		{"future", &KiroTokenData{ExpiresAt: now.Add(45 * time.Minute).Format(time.RFC3339)}, 45 * time.Minute, true},
+		{"past", &KiroTokenData{ExpiresAt: now.Add(-10 * time.Minute).Format(time.RFC3339)}, -10 * time.Minute, true},
+		{"unknown", &KiroTokenData{}, 0, false},
+		{"unparseable", &KiroTokenData{ExpiresAt: "tomorrow"}, 0, false},
+		{"nil", nil, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, known := tt.token.TimeToExpiry(now)
+			if got != tt.want || known != tt.wantKnown {
+				t.Fatalf("TimeToExpiry() = (%v, %v), want (%v, %v)", got, known, tt.want, tt.wantKnown)
+			}
+		})
+	}
+}
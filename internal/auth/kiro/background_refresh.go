@@ -2,13 +2,16 @@ package kiro
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"golang.org/x/sync/semaphore"
+	"golang.org/x/sync/singleflight"
 )
 
 type Token struct {
@@ -23,6 +26,21 @@ type Token struct {
 	Provider     string
 	StartURL     string
 	Region       string
+	Email        string
+
+	// SecondaryStartURL and SecondaryRegion name the IDC instance tried when the
+	// primary no longer has the client registered; SecondaryClientID,
+	// SecondaryClientSecret and SecondaryRefreshToken come from a login there.
+	SecondaryStartURL     string
+	SecondaryRegion       string
+	SecondaryClientID     string
+	SecondaryClientSecret string
+	SecondaryRefreshToken string
+
+	// Refresh backoff bookkeeping, persisted so a restart keeps honoring it.
+	LastRefreshAttempt  time.Time
+	ConsecutiveFailures int
+	NextEligibleAt      time.Time // zero when the token is not backing off
 }
 
 type TokenRepository interface {
@@ -30,6 +48,22 @@ type TokenRepository interface {
 	UpdateToken(token *Token) error
 }
 
+// RefreshBackoffStore is implemented by repositories that can persist a token's
+// refresh backoff fields without touching its credentials. The refresher uses it
+// after a failed refresh so the backoff survives a restart.
+type RefreshBackoffStore interface {
+	UpdateRefreshBackoff(token *Token) error
+}
+
+// Default backoff between failed refreshes of the same token.
+const (
+	defaultRefreshBackoffBase = 30 * time.Second
+	defaultRefreshBackoffMax  = 30 * time.Minute
+)
+
+// defaultStopTimeout bounds how long Stop waits for in-flight refreshes to persist.
+const defaultStopTimeout = 30 * time.Second
+
 type RefresherOption func(*BackgroundRefresher)
 
 func WithInterval(interval time.Duration) RefresherOption {
@@ -50,28 +84,110 @@ func WithConcurrency(concurrency int) RefresherOption {
 	}
 }
 
+// WithMaxRetries limits how many consecutive refresh failures a token may accumulate.
+// After n consecutive failures the token is marked permanently failed and skipped by
+// later sweeps until ResetTokenFailures is called or the process restarts.
+// n <= 0 disables the limit.
+func WithMaxRetries(n int) RefresherOption {
+	return func(r *BackgroundRefresher) {
+		r.maxRetries = n
+	}
+}
+
+// WithDryRun makes the refresher log which tokens it would refresh, and why,
+// without calling the refresh endpoints or updating token files.
+func WithDryRun(enabled bool) RefresherOption {
+	return func(r *BackgroundRefresher) {
+		r.dryRun = enabled
+	}
+}
+
+// WithRefreshBackoff sets the delay before a token that failed to refresh is
+// retried. The delay starts at base and doubles with each consecutive failure, up
+// to maxDelay. base <= 0 disables the backoff so failed tokens are retried every
+// sweep.
+func WithRefreshBackoff(base, maxDelay time.Duration) RefresherOption {
+	return func(r *BackgroundRefresher) {
+		r.backoffBase = base
+		r.backoffMax = maxDelay
+	}
+}
+
+// WithRequestPacing spaces the refresh requests a sweep sends so that
+// consecutive requests start at least minGap apart, even when several tokens are
+// refreshed concurrently. It complements the jittered backoff; minGap <= 0
+// disables pacing. On-demand refreshes via RefreshToken are not paced.
+func WithRequestPacing(minGap time.Duration) RefresherOption {
+	return func(r *BackgroundRefresher) {
+		if minGap <= 0 {
+			r.pacer = nil
+			return
+		}
+		r.pacer = newRequestPacer(minGap)
+	}
+}
+
+// RefresherStatus is a point-in-time snapshot of a BackgroundRefresher's configuration and state.
+type RefresherStatus struct {
+	DryRun       bool
+	Interval     time.Duration
+	BatchSize    int
+	Concurrency  int
+	MaxRetries   int
+	InFlight     []string // token IDs currently being refreshed
+	FailedTokens []string // token IDs skipped after exceeding MaxRetries
+}
+
+// TokenRefreshResult describes the outcome of a single token refresh attempt.
+type TokenRefreshResult struct {
+	TokenID      string
+	Duration     time.Duration // time spent calling the refresh endpoint
+	Err          error         // nil on success
+	NewExpiry    time.Time     // expiry of the token now in use; zero on failure
+	UsedFallback bool          // refresh failed but the existing token is still valid
+}
+
 type BackgroundRefresher struct {
 	interval         time.Duration
 	batchSize        int
 	concurrency      int
 	tokenRepo        TokenRepository
 	stopCh           chan struct{}
+	stopOnce         sync.Once
 	wg               sync.WaitGroup
+	inflightWg       sync.WaitGroup      // tracks refreshSingle goroutines that may outlive a batch
+	inflightMu       sync.Mutex          // guards inflight
+	inflight         map[string]struct{} // token IDs currently being refreshed
+	maxRetries       int                 // consecutive failures before a token is skipped; 0 means unlimited
+	dryRun           bool                // log intended refreshes without performing them
+	backoffBase      time.Duration       // delay after the first consecutive failure; 0 disables backoff
+	backoffMax       time.Duration       // upper bound for the backoff delay
+	pacer            *requestPacer       // spaces sweep refresh requests; nil disables pacing
+	failureMu        sync.Mutex          // guards failures and failedTokens
+	failures         map[string]int      // token ID -> consecutive refresh failures
+	failedTokens     map[string]struct{} // token IDs marked permanently failed
+	refreshGroup     singleflight.Group  // deduplicates concurrent refreshes of the same token ID
 	oauth            *KiroOAuth
-	ssoClient        *SSOOIDCClient
+	ssoClient        OIDCClient
 	callbackMu       sync.RWMutex                                   // 保护回调函数的并发访问
 	onTokenRefreshed func(tokenID string, tokenData *KiroTokenData) // 刷新成功回调
+	onRefreshResult  func(result TokenRefreshResult)                // invoked after every refresh attempt
 }
 
 func NewBackgroundRefresher(repo TokenRepository, opts ...RefresherOption) *BackgroundRefresher {
 	r := &BackgroundRefresher{
-		interval:    time.Minute,
-		batchSize:   50,
-		concurrency: 10,
-		tokenRepo:   repo,
-		stopCh:      make(chan struct{}),
-		oauth:       nil, // Lazy init - will be set when config available
-		ssoClient:   nil, // Lazy init - will be set when config available
+		interval:     time.Minute,
+		batchSize:    50,
+		concurrency:  10,
+		tokenRepo:    repo,
+		stopCh:       make(chan struct{}),
+		inflight:     make(map[string]struct{}),
+		failures:     make(map[string]int),
+		failedTokens: make(map[string]struct{}),
+		backoffBase:  defaultRefreshBackoffBase,
+		backoffMax:   defaultRefreshBackoffMax,
+		oauth:        nil, // Lazy init - will be set when config available
+		ssoClient:    nil, // Lazy init - will be set when config available
 	}
 	for _, opt := range opts {
 		opt(r)
@@ -83,7 +199,17 @@ func NewBackgroundRefresher(repo TokenRepository, opts ...RefresherOption) *Back
 func WithConfig(cfg *config.Config) RefresherOption {
 	return func(r *BackgroundRefresher) {
 		r.oauth = NewKiroOAuth(cfg)
-		r.ssoClient = NewSSOOIDCClient(cfg)
+		if r.ssoClient == nil {
+			r.ssoClient = NewSSOOIDCClient(cfg)
+		}
+	}
+}
+
+// WithOIDCClient sets the client used for Builder ID and IDC refreshes instead
+// of the SSOOIDCClient WithConfig would create.
+func WithOIDCClient(client OIDCClient) RefresherOption {
+	return func(r *BackgroundRefresher) {
+		r.ssoClient = client
 	}
 }
 
@@ -98,6 +224,17 @@ func WithOnTokenRefreshed(callback func(tokenID string, tokenData *KiroTokenData
 	}
 }
 
+// WithOnTokenRefreshedResult sets a callback invoked after every refresh attempt,
+// successful or not, with per-token timing. It is called in addition to the
+// WithOnTokenRefreshed callback.
+func WithOnTokenRefreshedResult(callback func(result TokenRefreshResult)) RefresherOption {
+	return func(r *BackgroundRefresher) {
+		r.callbackMu.Lock()
+		r.onRefreshResult = callback
+		r.callbackMu.Unlock()
+	}
+}
+
 func (r *BackgroundRefresher) Start(ctx context.Context) {
 	r.wg.Add(1)
 	go func() {
@@ -120,13 +257,188 @@ func (r *BackgroundRefresher) Start(ctx context.Context) {
 	}()
 }
 
+// Stop halts the refresh loop and waits up to defaultStopTimeout for in-flight
+// refreshes to finish persisting their token files.
 func (r *BackgroundRefresher) Stop() {
-	close(r.stopCh)
-	r.wg.Wait()
+	r.StopWithTimeout(defaultStopTimeout)
+}
+
+// StopWithTimeout halts the refresh loop and waits up to timeout for in-flight
+// refreshes to complete. A non-positive timeout waits indefinitely.
+// It reports whether every in-flight refresh finished before the timeout elapsed;
+// on timeout the token IDs still in flight are logged.
+func (r *BackgroundRefresher) StopWithTimeout(timeout time.Duration) bool {
+	r.stopOnce.Do(func() {
+		close(r.stopCh)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		r.inflightWg.Wait()
+		close(done)
+	}()
+
+	if timeout <= 0 {
+		<-done
+		return true
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		log.Printf("background refresh: stop timed out after %v, tokens still in flight: %s",
+			timeout, strings.Join(r.inflightTokenIDs(), ", "))
+		return false
+	}
+}
+
+// inflightTokenIDs returns the sorted IDs of tokens currently being refreshed.
+func (r *BackgroundRefresher) inflightTokenIDs() []string {
+	r.inflightMu.Lock()
+	defer r.inflightMu.Unlock()
+	ids := make([]string, 0, len(r.inflight))
+	for id := range r.inflight {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (r *BackgroundRefresher) markInflight(tokenID string) {
+	r.inflightWg.Add(1)
+	r.inflightMu.Lock()
+	r.inflight[tokenID] = struct{}{}
+	r.inflightMu.Unlock()
+}
+
+func (r *BackgroundRefresher) clearInflight(tokenID string) {
+	r.inflightMu.Lock()
+	delete(r.inflight, tokenID)
+	r.inflightMu.Unlock()
+	r.inflightWg.Done()
+}
+
+// ResetTokenFailures clears the failure count for tokenID and, if it was marked
+// permanently failed, makes it eligible for refresh again.
+func (r *BackgroundRefresher) ResetTokenFailures(tokenID string) {
+	r.failureMu.Lock()
+	defer r.failureMu.Unlock()
+	delete(r.failures, tokenID)
+	delete(r.failedTokens, tokenID)
+}
+
+// Status returns a snapshot of the refresher's configuration, in-flight refreshes
+// and permanently failed tokens.
+func (r *BackgroundRefresher) Status() RefresherStatus {
+	r.failureMu.Lock()
+	failed := make([]string, 0, len(r.failedTokens))
+	for id := range r.failedTokens {
+		failed = append(failed, id)
+	}
+	r.failureMu.Unlock()
+	sort.Strings(failed)
+
+	return RefresherStatus{
+		DryRun:       r.dryRun,
+		Interval:     r.interval,
+		BatchSize:    r.batchSize,
+		Concurrency:  r.concurrency,
+		MaxRetries:   r.maxRetries,
+		InFlight:     r.inflightTokenIDs(),
+		FailedTokens: failed,
+	}
+}
+
+// isPermanentlyFailed reports whether tokenID exceeded the retry limit.
+func (r *BackgroundRefresher) isPermanentlyFailed(tokenID string) bool {
+	r.failureMu.Lock()
+	defer r.failureMu.Unlock()
+	_, failed := r.failedTokens[tokenID]
+	return failed
+}
+
+// recordFailure increments the consecutive failure count for tokenID and marks it
+// permanently failed once the retry limit is reached.
+func (r *BackgroundRefresher) recordFailure(tokenID string) {
+	if r.maxRetries <= 0 {
+		return
+	}
+	r.failureMu.Lock()
+	defer r.failureMu.Unlock()
+	r.failures[tokenID]++
+	if r.failures[tokenID] >= r.maxRetries {
+		r.failedTokens[tokenID] = struct{}{}
+		log.Printf("background refresh: token %s failed %d consecutive refreshes, skipping until reset", tokenID, r.failures[tokenID])
+	}
+}
+
+// recordSuccess clears the consecutive failure count for tokenID.
+func (r *BackgroundRefresher) recordSuccess(tokenID string) {
+	if r.maxRetries <= 0 {
+		return
+	}
+	r.failureMu.Lock()
+	defer r.failureMu.Unlock()
+	delete(r.failures, tokenID)
+}
+
+// refreshReason describes why token was selected for refresh.
+func refreshReason(token *Token, now time.Time) string {
+	switch {
+	case token.ExpiresAt.IsZero():
+		return "no expiry recorded"
+	case !now.Before(token.ExpiresAt):
+		return fmt.Sprintf("expired %v ago", now.Sub(token.ExpiresAt).Round(time.Second))
+	default:
+		return fmt.Sprintf("expires in %v, within the refresh window", token.ExpiresAt.Sub(now).Round(time.Second))
+	}
+}
+
+// recordRefreshAttempt updates token's backoff fields after a refresh attempt.
+// A failure schedules the next attempt with exponential backoff and persists the
+// fields when the repository supports it; a success clears them and is persisted
+// by UpdateToken.
+func (r *BackgroundRefresher) recordRefreshAttempt(token *Token, now time.Time, failed bool) {
+	token.LastRefreshAttempt = now
+	if !failed {
+		token.ConsecutiveFailures = 0
+		token.NextEligibleAt = time.Time{}
+		return
+	}
+	token.ConsecutiveFailures++
+	if r.backoffBase > 0 {
+		token.NextEligibleAt = now.Add(ExponentialBackoffWithJitter(token.ConsecutiveFailures-1, r.backoffBase, max(r.backoffMax, r.backoffBase)))
+	}
+
+	store, ok := r.tokenRepo.(RefreshBackoffStore)
+	if !ok {
+		return
+	}
+	if err := store.UpdateRefreshBackoff(token); err != nil {
+		log.Printf("background refresh: failed to persist backoff for token %s: %v", token.ID, err)
+	}
 }
 
 func (r *BackgroundRefresher) refreshBatch(ctx context.Context) {
-	tokens := r.tokenRepo.FindOldestUnverified(r.batchSize)
+	// Fetch every candidate and apply the batch limit after filtering, so
+	// permanently failed tokens cannot crowd healthy ones out of the batch.
+	found := r.tokenRepo.FindOldestUnverified(0)
+	now := time.Now()
+	tokens := found[:0]
+	for _, token := range found {
+		if r.isPermanentlyFailed(token.ID) || now.Before(token.NextEligibleAt) {
+			continue
+		}
+		tokens = append(tokens, token)
+		if r.batchSize > 0 && len(tokens) == r.batchSize {
+			break
+		}
+	}
 	if len(tokens) == 0 {
 		return
 	}
@@ -150,8 +462,10 @@ func (r *BackgroundRefresher) refreshBatch(ctx context.Context) {
 		}
 
 		wg.Add(1)
+		r.markInflight(token.ID)
 		go func(t *Token) {
 			defer wg.Done()
+			defer r.clearInflight(t.ID)
 			defer sem.Release(1)
 			r.refreshSingle(ctx, t)
 		}(token)
@@ -164,50 +478,82 @@ func (r *BackgroundRefresher) refreshSingle(ctx context.Context, token *Token) {
 	// Normalize auth method to lowercase for case-insensitive matching
 	authMethod := strings.ToLower(token.AuthMethod)
 
+	if r.dryRun {
+		log.Printf("background refresh (dry-run): would refresh token %s (auth method %s): %s",
+			token.ID, authMethod, refreshReason(token, time.Now()))
+		return
+	}
+
+	if r.pacer != nil {
+		if err := r.pacer.wait(ctx); err != nil {
+			return
+		}
+	}
+	_, _ = r.RefreshToken(ctx, token)
+}
+
+// RefreshToken refreshes token, persists it and notifies the refresh callback.
+// Concurrent calls for the same token ID share one refresh: a single goroutine
+// contacts the OIDC endpoint and updates the repository while the others wait
+// and receive a copy of its result. The OIDC call itself goes through
+// SharedRefresh, so it is also shared with executor and authenticator refreshes
+// of the same refresh token. If the refresh fails but the current access token
+// is still valid, that token is returned without updating the repository.
+func (r *BackgroundRefresher) RefreshToken(ctx context.Context, token *Token) (*KiroTokenData, error) {
+	return doSharedRefresh(ctx, &r.refreshGroup, token.ID, func(ctx context.Context) (*KiroTokenData, error) {
+		return r.performRefresh(ctx, token)
+	})
+}
+
+func (r *BackgroundRefresher) performRefresh(ctx context.Context, token *Token) (*KiroTokenData, error) {
+	authMethod := strings.ToLower(token.AuthMethod)
+
 	// Create refresh function based on auth method
 	refreshFunc := func(ctx context.Context) (*KiroTokenData, error) {
-		switch authMethod {
-		case "idc":
-			return r.ssoClient.RefreshTokenWithRegion(
-				ctx,
-				token.ClientID,
-				token.ClientSecret,
-				token.RefreshToken,
-				token.Region,
-				token.StartURL,
-			)
-		case "builder-id":
-			return r.ssoClient.RefreshToken(
-				ctx,
-				token.ClientID,
-				token.ClientSecret,
-				token.RefreshToken,
-			)
-		default:
-			return r.oauth.RefreshTokenWithFingerprint(ctx, token.RefreshToken, token.ID)
-		}
+		return SharedRefresh(ctx, token.RefreshToken, func(ctx context.Context) (*KiroTokenData, error) {
+			return r.refreshByAuthMethod(ctx, token, authMethod)
+		})
 	}
 
 	// Use graceful degradation for better reliability
+	start := time.Now()
 	result := RefreshWithGracefulDegradation(
 		ctx,
 		refreshFunc,
 		token.AccessToken,
 		token.ExpiresAt,
 	)
+	report := TokenRefreshResult{TokenID: token.ID, Duration: time.Since(start)}
 
 	if result.Error != nil {
 		log.Printf("failed to refresh token %s: %v", token.ID, result.Error)
-		return
+		r.recordFailure(token.ID)
+		r.recordRefreshAttempt(token, time.Now(), true)
+		report.Err = result.Error
+		r.reportRefreshResult(report)
+		return nil, result.Error
 	}
 
 	newTokenData := result.TokenData
+	if !result.UsedFallback {
+		if errIdentity := VerifyRefreshedIdentity(token.Email, token.AccessToken, newTokenData.AccessToken); errIdentity != nil {
+			log.Printf("refusing refreshed token %s: %v", token.ID, errIdentity)
+			r.recordFailure(token.ID)
+			r.recordRefreshAttempt(token, time.Now(), true)
+			report.Err = errIdentity
+			r.reportRefreshResult(report)
+			return nil, errIdentity
+		}
+	}
 	if result.UsedFallback {
 		log.Printf("token %s: using existing token as fallback (refresh failed but token still valid)", token.ID)
 		// Don't update the token file if we're using fallback
 		// Just update LastVerified to prevent immediate re-check
 		token.LastVerified = time.Now()
-		return
+		report.UsedFallback = true
+		report.NewExpiry = token.ExpiresAt
+		r.reportRefreshResult(report)
+		return newTokenData, nil
 	}
 
 	token.AccessToken = newTokenData.AccessToken
@@ -222,10 +568,17 @@ func (r *BackgroundRefresher) refreshSingle(ctx context.Context, token *Token) {
 		}
 	}
 
+	r.recordSuccess(token.ID)
+	r.recordRefreshAttempt(token, token.LastVerified, false)
+
 	if err := r.tokenRepo.UpdateToken(token); err != nil {
 		log.Printf("failed to update token %s: %v", token.ID, err)
-		return
+		report.Err = fmt.Errorf("update token %s: %w", token.ID, err)
+		r.reportRefreshResult(report)
+		return nil, report.Err
 	}
+	report.NewExpiry = token.ExpiresAt
+	r.reportRefreshResult(report)
 
 	// 方案 A: 刷新成功后触发回调，通知 Watcher 更新内存中的 Auth 对象
 	r.callbackMu.RLock()
@@ -244,4 +597,52 @@ func (r *BackgroundRefresher) refreshSingle(ctx context.Context, token *Token) {
 			callback(token.ID, newTokenData)
 		}()
 	}
+	return newTokenData, nil
+}
+
+// refreshByAuthMethod contacts the refresh endpoint that matches the token's auth method.
+func (r *BackgroundRefresher) refreshByAuthMethod(ctx context.Context, token *Token, authMethod string) (*KiroTokenData, error) {
+	switch authMethod {
+
+	case "idc":
+		return r.ssoClient.RefreshIDCToken(ctx, &KiroTokenData{
+			ClientID:              token.ClientID,
+			ClientSecret:          token.ClientSecret,
+			RefreshToken:          token.RefreshToken,
+			Region:                token.Region,
+			StartURL:              token.StartURL,
+			SecondaryStartURL:     token.SecondaryStartURL,
+			SecondaryRegion:       token.SecondaryRegion,
+			SecondaryClientID:     token.SecondaryClientID,
+			SecondaryClientSecret: token.SecondaryClientSecret,
+			SecondaryRefreshToken: token.SecondaryRefreshToken,
+		})
+	case "builder-id":
+		return r.ssoClient.RefreshToken(
+			ctx,
+			token.ClientID,
+			token.ClientSecret,
+			token.RefreshToken,
+		)
+	default:
+		return r.oauth.RefreshTokenWithFingerprint(ctx, token.RefreshToken, token.ID)
+	}
+
+}
+
+// reportRefreshResult forwards result to the WithOnTokenRefreshedResult callback.
+func (r *BackgroundRefresher) reportRefreshResult(result TokenRefreshResult) {
+	r.callbackMu.RLock()
+	callback := r.onRefreshResult
+	r.callbackMu.RUnlock()
+	if callback == nil {
+		return
+	}
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			log.Printf("background refresh: result callback panic for token %s: %v", result.TokenID, rec)
+		}
+	}()
+	callback(result)
 }
@@ -0,0 +1,560 @@
+package kiro
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeTokenRepository hands out its tokens once (or on every sweep when repeat
+// is set) and records updates.
+type fakeTokenRepository struct {
+	mu      sync.Mutex
+	tokens  []*Token
+	repeat  bool
+	updated []*Token
+}
+
+func (r *fakeTokenRepository) FindOldestUnverified(limit int) []*Token {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tokens := r.tokens
+	if limit > 0 && len(tokens) > limit {
+		tokens = tokens[:limit]
+	}
+	if r.repeat {
+		return append([]*Token(nil), tokens...)
+	}
+	r.tokens = nil
+	return tokens
+}
+
+func (r *fakeTokenRepository) UpdateToken(token *Token) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updated = append(r.updated, token)
+	return nil
+}
+
+func (r *fakeTokenRepository) updatedCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.updated)
+}
+
+// newSlowRefreshServer returns a refresh endpoint that signals on started and
+// blocks until release is closed before answering.
+func newSlowRefreshServer(t *testing.T, started chan<- struct{}, release <-chan struct{}) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(CreateTokenResponse{
+			AccessToken:  "new-access-token",
+			RefreshToken: "new-refresh-token",
+			ExpiresIn:    3600,
+		})
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func newTestRefresher(ts *httptest.Server, repo TokenRepository) *BackgroundRefresher {
+	r := NewBackgroundRefresher(repo, WithInterval(time.Hour))
+	r.ssoClient = &SSOOIDCClient{
+		httpClient: &http.Client{Transport: &rewriteTransport{targetURL: ts.URL}},
+	}
+	return r
+}
+
+func TestBackgroundRefresherStopWaitsForInflight(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	ts := newSlowRefreshServer(t, started, release)
+
+	repo := &fakeTokenRepository{tokens: []*Token{{
+		ID:           "kiro-builder-id-test.json",
+		AuthMethod:   "builder-id",
+		RefreshToken: "old-refresh-token",
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+	}}}
+	refresher := newTestRefresher(ts, repo)
+	refresher.Start(context.Background())
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("refresh request was not issued")
+	}
+
+	stopped := make(chan bool, 1)
+	go func() {
+		stopped <- refresher.StopWithTimeout(5 * time.Second)
+	}()
+
+	select {
+	case <-stopped:
+		t.Fatal("Stop returned while a refresh was still in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+
+	select {
+	case ok := <-stopped:
+		if !ok {
+			t.Fatal("StopWithTimeout reported a timeout, want completion")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not return after the refresh completed")
+	}
+
+	if got := repo.updatedCount(); got != 1 {
+		t.Fatalf("UpdateToken calls = %d, want 1", got)
+	}
+}
+
+func TestBackgroundRefresherStopWithTimeoutExpires(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	ts := newSlowRefreshServer(t, started, release)
+	defer close(release)
+
+	repo := &fakeTokenRepository{tokens: []*Token{{
+		ID:           "kiro-builder-id-stuck.json",
+		AuthMethod:   "builder-id",
+		RefreshToken: "old-refresh-token",
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+	}}}
+	refresher := newTestRefresher(ts, repo)
+	refresher.Start(context.Background())
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("refresh request was not issued")
+	}
+
+	begin := time.Now()
+	if refresher.StopWithTimeout(50 * time.Millisecond) {
+		t.Fatal("StopWithTimeout reported completion, want timeout")
+	}
+	if elapsed := time.Since(begin); elapsed > 2*time.Second {
+		t.Fatalf("StopWithTimeout took %v, want it bounded by the timeout", elapsed)
+	}
+
+	if ids := refresher.inflightTokenIDs(); len(ids) != 1 || ids[0] != "kiro-builder-id-stuck.json" {
+		t.Fatalf("in-flight token IDs = %v, want [kiro-builder-id-stuck.json]", ids)
+	}
+}
+
+func TestBackgroundRefresherMaxRetriesSkipsFailedToken(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	repo := &fakeTokenRepository{repeat: true, tokens: []*Token{{
+		ID:           "kiro-builder-id-failing.json",
+		AuthMethod:   "builder-id",
+		RefreshToken: "refresh-token",
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+	}}}
+	refresher := NewBackgroundRefresher(repo, WithMaxRetries(2), WithRefreshBackoff(0, 0))
+	refresher.ssoClient = &SSOOIDCClient{
+		httpClient: &http.Client{Transport: &rewriteTransport{targetURL: ts.URL}},
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		refresher.refreshBatch(ctx)
+	}
+	if got := hits.Load(); got != 2 {
+		t.Fatalf("refresh attempts = %d, want 2 before the token is skipped", got)
+	}
+	if !refresher.isPermanentlyFailed("kiro-builder-id-failing.json") {
+		t.Fatal("expected token to be marked permanently failed")
+	}
+
+	refresher.ResetTokenFailures("kiro-builder-id-failing.json")
+	refresher.refreshBatch(ctx)
+	if got := hits.Load(); got != 3 {
+		t.Fatalf("refresh attempts after reset = %d, want 3", got)
+	}
+	if refresher.isPermanentlyFailed("kiro-builder-id-failing.json") {
+		t.Fatal("expected a single failure after reset to stay under the limit")
+	}
+}
+
+func TestBackgroundRefresherFailedTokensDoNotStarveBatch(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(CreateTokenResponse{
+			AccessToken:  "new-access-token",
+			RefreshToken: "new-refresh-token",
+			ExpiresIn:    3600,
+		})
+	}))
+	defer ts.Close()
+
+	newToken := func(id string) *Token {
+		return &Token{
+			ID:           id,
+			AuthMethod:   "builder-id",
+			RefreshToken: "refresh-token",
+			ClientID:     "client-id",
+			ClientSecret: "client-secret",
+		}
+	}
+	repo := &fakeTokenRepository{tokens: []*Token{
+		newToken("kiro-failed-1.json"),
+		newToken("kiro-failed-2.json"),
+		newToken("kiro-healthy.json"),
+	}}
+	refresher := NewBackgroundRefresher(repo, WithBatchSize(2), WithMaxRetries(1))
+	refresher.ssoClient = &SSOOIDCClient{
+		httpClient: &http.Client{Transport: &rewriteTransport{targetURL: ts.URL}},
+	}
+	refresher.recordFailure("kiro-failed-1.json")
+	refresher.recordFailure("kiro-failed-2.json")
+
+	refresher.refreshBatch(context.Background())
+	if got := hits.Load(); got != 1 {
+		t.Fatalf("refresh attempts = %d, want 1 for the healthy token", got)
+	}
+	if got := repo.updatedCount(); got != 1 || repo.updated[0].ID != "kiro-healthy.json" {
+		t.Fatalf("updated tokens = %d, want only kiro-healthy.json", got)
+	}
+}
+
+func TestBackgroundRefresherDryRunSkipsHTTP(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	repo := &fakeTokenRepository{tokens: []*Token{
+		{
+			ID:           "kiro-builder-id-expiring.json",
+			AuthMethod:   "builder-id",
+			RefreshToken: "refresh-token",
+			ExpiresAt:    time.Now().Add(2 * time.Minute),
+		},
+		{
+			ID:           "kiro-idc-expired.json",
+			AuthMethod:   "idc",
+			RefreshToken: "refresh-token",
+			ExpiresAt:    time.Now().Add(-time.Minute),
+		},
+	}}
+	refresher := NewBackgroundRefresher(repo, WithDryRun(true))
+	refresher.ssoClient = &SSOOIDCClient{
+		httpClient: &http.Client{Transport: &rewriteTransport{targetURL: ts.URL}},
+	}
+
+	refresher.refreshBatch(context.Background())
+
+	if got := hits.Load(); got != 0 {
+		t.Fatalf("HTTP calls in dry-run = %d, want 0", got)
+	}
+	if got := repo.updatedCount(); got != 0 {
+		t.Fatalf("UpdateToken calls in dry-run = %d, want 0", got)
+	}
+	if !refresher.Status().DryRun {
+		t.Fatal("Status().DryRun = false, want true")
+	}
+}
+
+func TestRefreshReason(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		want      string
+	}{
+		{"no expiry", time.Time{}, "no expiry recorded"},
+		{"expired", now.Add(-90 * time.Second), "expired 1m30s ago"},
+		{"expiring", now.Add(3 * time.Minute), "expires in 3m0s, within the refresh window"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := refreshReason(&Token{ExpiresAt: tt.expiresAt}, now); got != tt.want {
+				t.Errorf("refreshReason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackgroundRefresherRefreshTokenSingleflight(t *testing.T) {
+	var hits atomic.Int32
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(CreateTokenResponse{
+			AccessToken:  "new-access-token",
+			RefreshToken: "new-refresh-token",
+			ExpiresIn:    3600,
+		})
+	}))
+	t.Cleanup(ts.Close)
+
+	repo := &fakeTokenRepository{}
+	refresher := newTestRefresher(ts, repo)
+
+	const callers = 50
+	joined := waitForSharedRefreshJoins(t, "shared.json", callers)
+	gate := make(chan struct{})
+	var wg sync.WaitGroup
+	results := make([]*KiroTokenData, callers)
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-gate
+			token := &Token{
+				ID:           "shared.json",
+				AuthMethod:   "builder-id",
+				AccessToken:  "old-access-token",
+				RefreshToken: "old-refresh-token",
+				ExpiresAt:    time.Now().Add(-time.Minute),
+			}
+			results[i], errs[i] = refresher.RefreshToken(context.Background(), token)
+		}(i)
+	}
+	close(gate)
+	// Hold the refresh open until every caller has joined it.
+	<-joined
+	close(release)
+	wg.Wait()
+
+	if got := hits.Load(); got != 1 {
+		t.Fatalf("refresh requests = %d, want 1", got)
+	}
+	if got := repo.updatedCount(); got != 1 {
+		t.Fatalf("repository updates = %d, want 1", got)
+	}
+	for i := 0; i < callers; i++ {
+		if errs[i] != nil {
+			t.Fatalf("caller %d: unexpected error: %v", i, errs[i])
+		}
+		if results[i] == nil || results[i].AccessToken != "new-access-token" {
+			t.Fatalf("caller %d: result = %+v, want new-access-token", i, results[i])
+		}
+	}
+	if results[0] == results[1] {
+		t.Fatal("callers share the same *KiroTokenData, want independent copies")
+	}
+}
+
+func TestBackgroundRefresherReportsRefreshResult(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(CreateTokenResponse{
+			AccessToken:  "new-access-token",
+			RefreshToken: "new-refresh-token",
+			ExpiresIn:    3600,
+		})
+	}))
+	t.Cleanup(ts.Close)
+
+	refresher := newTestRefresher(ts, &fakeTokenRepository{})
+	var results []TokenRefreshResult
+	WithOnTokenRefreshedResult(func(result TokenRefreshResult) {
+		results = append(results, result)
+	})(refresher)
+
+	before := time.Now()
+	_, err := refresher.RefreshToken(context.Background(), &Token{
+		ID:           "timed.json",
+		AuthMethod:   "builder-id",
+		RefreshToken: "old-refresh-token",
+		ExpiresAt:    before.Add(-time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+
+	if len(results) != 1 {
+		t.Fatalf("result callbacks = %d, want 1", len(results))
+	}
+	got := results[0]
+	if got.TokenID != "timed.json" || got.Err != nil || got.UsedFallback {
+		t.Fatalf("result = %+v, want successful refresh of timed.json", got)
+	}
+	if got.Duration <= 0 {
+		t.Fatalf("Duration = %v, want > 0", got.Duration)
+	}
+	if !got.NewExpiry.After(before) {
+		t.Fatalf("NewExpiry = %v, want after %v", got.NewExpiry, before)
+	}
+}
+
+func newCountingRefreshServer(t *testing.T, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if status != http.StatusOK {
+			http.Error(w, `{"error":"server_error"}`, status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(CreateTokenResponse{
+			AccessToken:  "new-access-token",
+			RefreshToken: "new-refresh-token",
+			ExpiresIn:    3600,
+		})
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &hits
+}
+
+func TestBackgroundRefresherSkipsTokenInBackoff(t *testing.T) {
+	ts, hits := newCountingRefreshServer(t, http.StatusOK)
+	repo := &fakeTokenRepository{tokens: []*Token{{
+		ID:             "kiro-builder-id-backoff.json",
+		AuthMethod:     "builder-id",
+		RefreshToken:   "old-refresh-token",
+		ClientID:       "client-id",
+		ClientSecret:   "client-secret",
+		NextEligibleAt: time.Now().Add(time.Hour),
+	}}}
+
+	newTestRefresher(ts, repo).refreshBatch(context.Background())
+
+	if got := hits.Load(); got != 0 {
+		t.Fatalf("refresh endpoint hits = %d, want 0 while backing off", got)
+	}
+	if got := repo.updatedCount(); got != 0 {
+		t.Fatalf("repository updates = %d, want 0", got)
+	}
+}
+
+func TestBackgroundRefresherHonorsPersistedBackoffAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "kiro-builder-id-restart.json")
+	writeTestTokenFile(t, path, map[string]any{
+		"client_id":     "client-id",
+		"client_secret": "client-secret",
+	})
+
+	failing, failingHits := newCountingRefreshServer(t, http.StatusInternalServerError)
+	before := time.Now()
+	newTestRefresher(failing, NewFileTokenRepository(dir)).refreshBatch(context.Background())
+	if got := failingHits.Load(); got != 1 {
+		t.Fatalf("refresh endpoint hits = %d, want 1", got)
+	}
+
+	data := readTestTokenFile(t, path)
+	if got := data["consecutive_failures"]; got != float64(1) {
+		t.Fatalf("consecutive_failures = %v, want 1", got)
+	}
+	nextEligible, err := time.Parse(time.RFC3339, data["next_eligible_at"].(string))
+	if err != nil || !nextEligible.After(before) {
+		t.Fatalf("next_eligible_at = %v (%v), want a time after %v", data["next_eligible_at"], err, before)
+	}
+	if data["access_token"] != "access-token" {
+		t.Fatalf("access_token = %v, want credentials untouched", data["access_token"])
+	}
+
+	// A fresh repository and refresher simulate a process restart.
+	healthy, healthyHits := newCountingRefreshServer(t, http.StatusOK)
+	newTestRefresher(healthy, NewFileTokenRepository(dir)).refreshBatch(context.Background())
+	if got := healthyHits.Load(); got != 0 {
+		t.Fatalf("refresh endpoint hits after restart = %d, want 0 while backing off", got)
+	}
+}
+
+func TestBackgroundRefresherClearsPersistedBackoffOnSuccess(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "kiro-builder-id-recovered.json")
+	writeTestTokenFile(t, path, map[string]any{
+		"client_id":            "client-id",
+		"client_secret":        "client-secret",
+		"consecutive_failures": 3,
+		"next_eligible_at":     time.Now().Add(-time.Minute).Format(time.RFC3339),
+	})
+
+	ts, hits := newCountingRefreshServer(t, http.StatusOK)
+	newTestRefresher(ts, NewFileTokenRepository(dir)).refreshBatch(context.Background())
+	if got := hits.Load(); got != 1 {
+		t.Fatalf("refresh endpoint hits = %d, want 1 once the backoff elapsed", got)
+	}
+
+	data := readTestTokenFile(t, path)
+	if data["access_token"] != "new-access-token" {
+		t.Fatalf("access_token = %v, want new-access-token", data["access_token"])
+	}
+	for _, key := range []string{"consecutive_failures", "next_eligible_at"} {
+		if _, ok := data[key]; ok {
+			t.Errorf("%s = %v, want it cleared after a successful refresh", key, data[key])
+		}
+	}
+	if _, ok := data["last_refresh_attempt"]; !ok {
+		t.Error("last_refresh_attempt should be recorded")
+	}
+}
+
+func TestBackgroundRefresherRejectsTokenForDifferentAccount(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "kiro-builder-id-alice.json")
+	storedAccess := createTestJWT(map[string]any{"email": "alice@example.com", "sub": "user-alice"})
+	writeTestTokenFile(t, path, map[string]any{
+		"access_token":  storedAccess,
+		"client_id":     "client-id",
+		"client_secret": "client-secret",
+		"email":         "alice@example.com",
+	})
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(CreateTokenResponse{
+			AccessToken:  createTestJWT(map[string]any{"email": "bob@example.com", "sub": "user-bob"}),
+			RefreshToken: "bob-refresh-token",
+			ExpiresIn:    3600,
+		})
+	}))
+	t.Cleanup(ts.Close)
+
+	repo := NewFileTokenRepository(dir)
+	token := repo.FindOldestUnverified(1)
+	if len(token) != 1 {
+		t.Fatalf("found %d tokens, want 1", len(token))
+	}
+	_, err := newTestRefresher(ts, repo).RefreshToken(context.Background(), token[0])
+	if !errors.Is(err, ErrRefreshIdentityMismatch) {
+		t.Fatalf("RefreshToken error = %v, want ErrRefreshIdentityMismatch", err)
+	}
+
+	data := readTestTokenFile(t, path)
+	if data["access_token"] != storedAccess {
+		t.Error("access_token was overwritten by the mismatched refresh")
+	}
+	if data["refresh_token"] != "refresh-token" {
+		t.Errorf("refresh_token = %v, want the stored refresh-token", data["refresh_token"])
+	}
+	if data["email"] != "alice@example.com" {
+		t.Errorf("email = %v, want alice@example.com", data["email"])
+	}
+}
@@ -10,7 +10,6 @@ import (
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
-	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -52,11 +51,8 @@ type UsageBreakdown struct {
 }
 
 // NewCodeWhispererClient creates a new CodeWhisperer client.
-func NewCodeWhispererClient(cfg *config.Config, machineID string) *CodeWhispererClient {
-	client := &http.Client{Timeout: 30 * time.Second}
-	if cfg != nil {
-		client = util.SetProxy(&cfg.SDKConfig, client)
-	}
+func NewCodeWhispererClient(cfg *config.Config, machineID string, opts ...ClientOption) *CodeWhispererClient {
+	client := newHTTPClient(cfg, 30*time.Second, opts)
 	return &CodeWhispererClient{
 		httpClient: client,
 	}
@@ -84,11 +80,9 @@ func (c *CodeWhispererClient) GetUsageLimits(ctx context.Context, accessToken, c
 	}
 
 	accountKey := GetAccountKey(clientID, refreshToken)
-	setRuntimeHeaders(req, accessToken, accountKey)
-
 	log.Debugf("codewhisperer: GET %s", url)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := wrapHTTPClient(c.httpClient).DoRuntime(req, accessToken, accountKey)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
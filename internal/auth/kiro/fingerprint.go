@@ -1,8 +1,11 @@
 package kiro
 
 import (
+	"bytes"
 	"crypto/sha256"
+	"encoding"
 	"encoding/binary"
+	"encoding/gob"
 	"encoding/hex"
 	"fmt"
 	"math/rand"
@@ -10,11 +13,17 @@ import (
 	"runtime"
 	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 )
 
+// maxUserAgentBytes caps generated user-agent headers so oversized config
+// overrides cannot push a request past server header size limits.
+const maxUserAgentBytes = 1024
+
 // Fingerprint holds multi-dimensional fingerprint data for runtime request disguise.
 type Fingerprint struct {
 	OIDCSDKVersion      string // 3.7xx (AWS SDK JS)
@@ -45,6 +54,10 @@ type FingerprintManager struct {
 	fingerprints map[string]*Fingerprint // tokenKey -> fingerprint
 	rng          *rand.Rand
 	config       *FingerprintConfig // External config (Optional)
+	sessionNS    uuid.UUID          // per-process namespace for session IDs
+	sessionTTL   time.Duration      // session ID rotation period; 0 keeps IDs for the process lifetime
+	now          func() time.Time
+	generation   atomic.Uint64 // incremented by SetConfig
 }
 
 var (
@@ -92,22 +105,96 @@ func SetGlobalFingerprintConfig(cfg *FingerprintConfig) {
 	GlobalFingerprintManager().SetConfig(cfg)
 }
 
-// SetConfig applies the config and clears the fingerprint cache.
+// SetConfig applies the config, clears the fingerprint cache and advances the
+// generation counter.
 func (fm *FingerprintManager) SetConfig(cfg *FingerprintConfig) {
 	fm.mu.Lock()
 	defer fm.mu.Unlock()
 	fm.config = cfg
 	// Clear cached fingerprints so they regenerate with the new config
 	fm.fingerprints = make(map[string]*Fingerprint)
+	fm.generation.Add(1)
+}
+
+// Generation returns a counter that increases every time SetConfig is called.
+// Fingerprints returned by GetFingerprint are not updated in place, so callers
+// that keep a *Fingerprint should remember the generation it was fetched at and
+// call GetFingerprint again once Generation reports a different value.
+func (fm *FingerprintManager) Generation() uint64 {
+	return fm.generation.Load()
+}
+
+var (
+	_ encoding.BinaryMarshaler   = (*FingerprintManager)(nil)
+	_ encoding.BinaryUnmarshaler = (*FingerprintManager)(nil)
+)
+
+// MarshalBinary encodes the cached fingerprints, keyed by token key, in gob
+// format so another process can restore them with UnmarshalBinary.
+func (fm *FingerprintManager) MarshalBinary() ([]byte, error) {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(fm.fingerprints); err != nil {
+		return nil, fmt.Errorf("encode fingerprints: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary replaces the cached fingerprints with those encoded by
+// MarshalBinary and advances the generation counter.
+func (fm *FingerprintManager) UnmarshalBinary(data []byte) error {
+	fingerprints := make(map[string]*Fingerprint)
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&fingerprints); err != nil {
+		return fmt.Errorf("decode fingerprints: %w", err)
+	}
+	for key, fp := range fingerprints {
+		if fp == nil {
+			delete(fingerprints, key)
+		}
+	}
+
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	fm.fingerprints = fingerprints
+	fm.generation.Add(1)
+	return nil
 }
 
 func NewFingerprintManager() *FingerprintManager {
 	return &FingerprintManager{
 		fingerprints: make(map[string]*Fingerprint),
 		rng:          rand.New(rand.NewSource(time.Now().UnixNano())),
+		sessionNS:    uuid.New(),
+		now:          time.Now,
 	}
 }
 
+// SetSessionTTL sets how long a session ID stays valid before SessionID derives a
+// new one. A non-positive ttl keeps session IDs stable for the process lifetime.
+func (fm *FingerprintManager) SetSessionTTL(ttl time.Duration) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	fm.sessionTTL = ttl
+}
+
+// SessionID returns a UUID identifying the current session for tokenKey. It is
+// derived deterministically from tokenKey and a per-process namespace, so it is
+// stable across requests for the same key (within the session TTL, if set) and
+// differs between keys and process restarts.
+func (fm *FingerprintManager) SessionID(tokenKey string) string {
+	fm.mu.RLock()
+	ns, ttl, now := fm.sessionNS, fm.sessionTTL, fm.now
+	fm.mu.RUnlock()
+
+	name := tokenKey
+	if ttl > 0 {
+		name = fmt.Sprintf("%s|%d", tokenKey, now().UnixNano()/int64(ttl))
+	}
+	return uuid.NewSHA1(ns, []byte(name)).String()
+}
+
 // GetFingerprint returns the fingerprint for tokenKey, creating one if it doesn't exist.
 func (fm *FingerprintManager) GetFingerprint(tokenKey string) *Fingerprint {
 	fm.mu.RLock()
@@ -129,6 +216,79 @@ func (fm *FingerprintManager) GetFingerprint(tokenKey string) *Fingerprint {
 	return fp
 }
 
+// Fingerprint purposes accepted by GetFingerprintFor and SDKVersionFor.
+const (
+	FingerprintPurposeOIDC      = "oidc"
+	FingerprintPurposeRuntime   = "runtime"
+	FingerprintPurposeStreaming = "streaming"
+)
+
+// GetFingerprintFor returns the fingerprint for tokenKey used for one kind of
+// request, cached under tokenKey+":"+purpose.
+func (fm *FingerprintManager) GetFingerprintFor(tokenKey, purpose string) *Fingerprint {
+	return fm.GetFingerprint(tokenKey + ":" + purpose)
+}
+
+// Warm generates and caches fingerprints for tokenKeys ahead of their first request.
+// Seeded fingerprints are generated in parallel; config-backed ones share fm.rng
+// and are generated under the write lock. Cache writes always hold the lock.
+func (fm *FingerprintManager) Warm(tokenKeys []string) {
+	fm.mu.RLock()
+	useConfig := fm.config != nil
+	seen := make(map[string]struct{}, len(tokenKeys))
+	missing := make([]string, 0, len(tokenKeys))
+	for _, key := range tokenKeys {
+		if _, dup := seen[key]; dup {
+			continue
+		}
+		seen[key] = struct{}{}
+		if _, exists := fm.fingerprints[key]; !exists {
+			missing = append(missing, key)
+		}
+	}
+	fm.mu.RUnlock()
+
+	if len(missing) == 0 {
+		return
+	}
+
+	var generated []*Fingerprint
+	if !useConfig {
+		generated = make([]*Fingerprint, len(missing))
+		workers := min(runtime.GOMAXPROCS(0), len(missing))
+		var next atomic.Int64
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					i := int(next.Add(1) - 1)
+					if i >= len(missing) {
+						return
+					}
+					generated[i] = fm.generateRandom(missing[i])
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	for i, key := range missing {
+		if _, exists := fm.fingerprints[key]; exists {
+			continue
+		}
+		// A config set while warming invalidates the seeded fingerprints.
+		if generated == nil || fm.config != nil {
+			fm.fingerprints[key] = fm.generateFingerprint(key)
+			continue
+		}
+		fm.fingerprints[key] = generated[i]
+	}
+}
+
 func (fm *FingerprintManager) generateFingerprint(tokenKey string) *Fingerprint {
 	if fm.config != nil {
 		return fm.generateFromConfig(tokenKey)
@@ -228,51 +388,144 @@ func GetAccountKey(clientID, refreshToken string) string {
 	return GenerateAccountKey(uuid.New().String())
 }
 
-// BuildUserAgent format: aws-sdk-js/{SDKVersion} ua/2.1 os/{OSType}#{OSVersion} lang/js md/nodejs#{NodeVersion} api/codewhispererstreaming#{SDKVersion} m/E KiroIDE-{KiroVersion}-{KiroHash}
-func (fp *Fingerprint) BuildUserAgent() string {
+// UserAgentComponents holds the fields a Kiro User-Agent header is composed from.
+type UserAgentComponents struct {
+	SDKVersion  string
+	OSType      string
+	OSVersion   string
+	NodeVersion string
+	APIName     string // e.g. codewhispererstreaming
+	MetricFlags string // the m/ segment, e.g. E or N,E
+	KiroVersion string
+	KiroHash    string
+}
+
+// String format: aws-sdk-js/{SDKVersion} ua/2.1 os/{OSType}#{OSVersion} lang/js md/nodejs#{NodeVersion} api/{APIName}#{SDKVersion} m/{MetricFlags} KiroIDE-{KiroVersion}-{KiroHash}
+func (c UserAgentComponents) String() string {
 	return fmt.Sprintf(
-		"aws-sdk-js/%s ua/2.1 os/%s#%s lang/js md/nodejs#%s api/codewhispererstreaming#%s m/E KiroIDE-%s-%s",
-		fp.StreamingSDKVersion,
-		fp.OSType,
-		fp.OSVersion,
-		fp.NodeVersion,
-		fp.StreamingSDKVersion,
-		fp.KiroVersion,
-		fp.KiroHash,
+		"aws-sdk-js/%s ua/2.1 os/%s#%s lang/js md/nodejs#%s api/%s#%s m/%s KiroIDE-%s-%s",
+		c.SDKVersion,
+		c.OSType,
+		c.OSVersion,
+		c.NodeVersion,
+		c.APIName,
+		c.SDKVersion,
+		c.MetricFlags,
+		c.KiroVersion,
+		c.KiroHash,
 	)
 }
 
-// BuildAmzUserAgent format: aws-sdk-js/{SDKVersion} KiroIDE-{KiroVersion}-{KiroHash}
+// UserAgentComponents returns the streaming API User-Agent components that
+// BuildUserAgent is composed from.
+func (fp *Fingerprint) UserAgentComponents() UserAgentComponents {
+	return UserAgentComponents{
+		SDKVersion:  fp.StreamingSDKVersion,
+		OSType:      fp.OSType,
+		OSVersion:   fp.OSVersion,
+		NodeVersion: fp.NodeVersion,
+		APIName:     "codewhispererstreaming",
+		MetricFlags: "E",
+		KiroVersion: fp.KiroVersion,
+		KiroHash:    fp.KiroHash,
+	}
+}
+
+// runtimeUserAgentComponents returns the runtime API User-Agent components.
+func (fp *Fingerprint) runtimeUserAgentComponents() UserAgentComponents {
+	c := fp.UserAgentComponents()
+	c.SDKVersion = fp.RuntimeSDKVersion
+	c.APIName = "codewhispererruntime"
+	c.MetricFlags = "N,E"
+	return c
+}
+
+// oidcUserAgentComponents returns the SSO OIDC User-Agent components. Like the
+// x-amz-user-agent, they end with KiroIDE-{KiroVersion}-{KiroHash}.
+func (fp *Fingerprint) oidcUserAgentComponents(sdkVersion string) UserAgentComponents {
+	c := fp.UserAgentComponents()
+	c.SDKVersion = sdkVersion
+	c.APIName = "sso-oidc"
+	c.MetricFlags = "E"
+	return c
+}
+
+// BuildUserAgent returns UserAgentComponents().String(), capped at maxUserAgentBytes.
+func (fp *Fingerprint) BuildUserAgent() string {
+	return util.TruncateString(fp.UserAgentComponents().String(), maxUserAgentBytes)
+}
+
+// BuildAmzUserAgent returns the streaming API X-Amz-User-Agent,
+// i.e. BuildAmzUserAgentFor(fp.StreamingSDKVersion).
 func (fp *Fingerprint) BuildAmzUserAgent() string {
-	return fmt.Sprintf(
+	return fp.BuildAmzUserAgentFor(fp.StreamingSDKVersion)
+}
+
+// SDKVersionFor returns the SDK version sent to the API named by purpose:
+// OIDCSDKVersion for OIDC, RuntimeSDKVersion for runtime and StreamingSDKVersion
+// for streaming and unknown purposes.
+func (fp *Fingerprint) SDKVersionFor(purpose string) string {
+	switch purpose {
+	case FingerprintPurposeOIDC:
+		return fp.OIDCSDKVersion
+	case FingerprintPurposeRuntime:
+		return fp.RuntimeSDKVersion
+	default:
+		return fp.StreamingSDKVersion
+	}
+}
+
+// BuildAmzUserAgentFor format: aws-sdk-js/{sdkVersion} KiroIDE-{KiroVersion}-{KiroHash}
+// The OIDC, runtime and streaming APIs each pass their own SDK version.
+func (fp *Fingerprint) BuildAmzUserAgentFor(sdkVersion string) string {
+	return util.TruncateString(fmt.Sprintf(
 		"aws-sdk-js/%s KiroIDE-%s-%s",
-		fp.StreamingSDKVersion,
+		sdkVersion,
 		fp.KiroVersion,
 		fp.KiroHash,
-	)
+	), maxUserAgentBytes)
 }
 
+// newUUID generates the amz-sdk-invocation-id of OIDC, runtime and streaming requests.
+// Tests replace it with pinUUID to get deterministic headers.
+var newUUID = uuid.NewString
+
+// SetOIDCHeaders applies the SSO OIDC headers to req. OIDC requests are not tied
+// to an account, so they share the fingerprint of the empty token key.
 func SetOIDCHeaders(req *http.Request) {
-	fp := GlobalFingerprintManager().GetFingerprint("oidc-session")
+	fp := GlobalFingerprintManager().GetFingerprintFor("", FingerprintPurposeOIDC)
+	sdkVersion := fp.SDKVersionFor(FingerprintPurposeOIDC)
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-amz-user-agent", fmt.Sprintf("aws-sdk-js/%s KiroIDE", fp.OIDCSDKVersion))
-	req.Header.Set("User-Agent", fmt.Sprintf(
-		"aws-sdk-js/%s ua/2.1 os/%s#%s lang/js md/nodejs#%s api/%s#%s m/E KiroIDE",
-		fp.OIDCSDKVersion, fp.OSType, fp.OSVersion, fp.NodeVersion, "sso-oidc", fp.OIDCSDKVersion))
-	req.Header.Set("amz-sdk-invocation-id", uuid.New().String())
+	req.Header.Set("x-amz-user-agent", fp.BuildAmzUserAgentFor(sdkVersion))
+	req.Header.Set("User-Agent", fp.oidcUserAgentComponents(sdkVersion).String())
+	req.Header.Set("amz-sdk-invocation-id", newUUID())
 	req.Header.Set("amz-sdk-request", "attempt=1; max=4")
 }
 
+// AgentMode is the x-amzn-kiro-agent-mode value Kiro IDE sends on streaming
+// API requests.
+const AgentMode = "vibe"
+
+// SetStreamingHeaders applies the headers of a streaming API request
+// (generateAssistantResponse, MCP) for accessToken and the account identified by
+// accountKey: the account's streaming fingerprint, the Kiro agent mode, the SDK
+// request headers and the bearer token.
+func SetStreamingHeaders(req *http.Request, accessToken string, accountKey string) {
+	fp := GlobalFingerprintManager().GetFingerprintFor(accountKey, FingerprintPurposeStreaming)
+	req.Header.Set("User-Agent", fp.BuildUserAgent())
+	req.Header.Set("X-Amz-User-Agent", fp.BuildAmzUserAgent())
+	req.Header.Set("x-amzn-kiro-agent-mode", AgentMode)
+	req.Header.Set("x-amzn-codewhisperer-optout", "true")
+	req.Header.Set("Amz-Sdk-Request", "attempt=1; max=3")
+	req.Header.Set("Amz-Sdk-Invocation-Id", newUUID())
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+}
+
 func setRuntimeHeaders(req *http.Request, accessToken string, accountKey string) {
-	fp := GlobalFingerprintManager().GetFingerprint(accountKey)
-	machineID := fp.KiroHash
+	fp := GlobalFingerprintManager().GetFingerprintFor(accountKey, FingerprintPurposeRuntime)
 	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("x-amz-user-agent", fmt.Sprintf("aws-sdk-js/%s KiroIDE-%s-%s",
-		fp.RuntimeSDKVersion, fp.KiroVersion, machineID))
-	req.Header.Set("User-Agent", fmt.Sprintf(
-		"aws-sdk-js/%s ua/2.1 os/%s#%s lang/js md/nodejs#%s api/codewhispererruntime#%s m/N,E KiroIDE-%s-%s",
-		fp.RuntimeSDKVersion, fp.OSType, fp.OSVersion, fp.NodeVersion, fp.RuntimeSDKVersion,
-		fp.KiroVersion, machineID))
-	req.Header.Set("amz-sdk-invocation-id", uuid.New().String())
+	req.Header.Set("x-amz-user-agent", fp.BuildAmzUserAgentFor(fp.RuntimeSDKVersion))
+	req.Header.Set("User-Agent", fp.runtimeUserAgentComponents().String())
+	req.Header.Set("amz-sdk-invocation-id", newUUID())
 	req.Header.Set("amz-sdk-request", "attempt=1; max=1")
 }
@@ -1,11 +1,13 @@
 package kiro
 
 import (
+	"fmt"
 	"net/http"
 	"runtime"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestNewFingerprintManager(t *testing.T) {
@@ -89,6 +91,22 @@ func TestBuildUserAgent(t *testing.T) {
 	}
 }
 
+func TestBuildUserAgent_TruncatesOversizedOverrides(t *testing.T) {
+	fp := &Fingerprint{KiroVersion: strings.Repeat("9", 2*maxUserAgentBytes)}
+
+	for name, ua := range map[string]string{
+		"User-Agent":       fp.BuildUserAgent(),
+		"X-Amz-User-Agent": fp.BuildAmzUserAgent(),
+	} {
+		if len(ua) > maxUserAgentBytes {
+			t.Errorf("%s length = %d, want <= %d", name, len(ua), maxUserAgentBytes)
+		}
+		if !strings.HasSuffix(ua, "...") {
+			t.Errorf("%s = %q, want truncation marker", name, ua[len(ua)-10:])
+		}
+	}
+}
+
 func TestGetFingerprint_OSVersionMatchesOSType(t *testing.T) {
 	fm := NewFingerprintManager()
 
@@ -207,6 +225,14 @@ func TestGlobalFingerprintManager(t *testing.T) {
 	}
 }
 
+// pinUUID makes newUUID return id for the rest of the test.
+func pinUUID(t *testing.T, id string) {
+	t.Helper()
+	prev := newUUID
+	newUUID = func() string { return id }
+	t.Cleanup(func() { newUUID = prev })
+}
+
 func TestSetOIDCHeaders(t *testing.T) {
 	req, _ := http.NewRequest("GET", "http://example.com", nil)
 	SetOIDCHeaders(req)
@@ -343,6 +369,125 @@ func TestBuildUserAgentFormat(t *testing.T) {
 	}
 }
 
+func TestBuildAmzUserAgentFor(t *testing.T) {
+	fp := &Fingerprint{
+		OIDCSDKVersion:      "3.738.0",
+		RuntimeSDKVersion:   "1.0.0",
+		StreamingSDKVersion: "1.0.27",
+		KiroVersion:         "0.10.32",
+		KiroHash:            "abc123",
+	}
+
+	tests := []struct {
+		name       string
+		sdkVersion string
+		want       string
+	}{
+		{"oidc", fp.OIDCSDKVersion, "aws-sdk-js/3.738.0 KiroIDE-0.10.32-abc123"},
+		{"runtime", fp.RuntimeSDKVersion, "aws-sdk-js/1.0.0 KiroIDE-0.10.32-abc123"},
+		{"streaming", fp.StreamingSDKVersion, "aws-sdk-js/1.0.27 KiroIDE-0.10.32-abc123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fp.BuildAmzUserAgentFor(tt.sdkVersion); got != tt.want {
+				t.Errorf("BuildAmzUserAgentFor(%q) = %q, want %q", tt.sdkVersion, got, tt.want)
+			}
+		})
+	}
+	if got := fp.BuildAmzUserAgent(); got != "aws-sdk-js/1.0.27 KiroIDE-0.10.32-abc123" {
+		t.Errorf("BuildAmzUserAgent() = %q, want the streaming variant", got)
+	}
+}
+
+func TestSetHeadersUseAPISpecificAmzUserAgent(t *testing.T) {
+	oidcFP := GlobalFingerprintManager().GetFingerprintFor("", FingerprintPurposeOIDC)
+	oidcReq, _ := http.NewRequest("GET", "http://example.com", nil)
+	SetOIDCHeaders(oidcReq)
+	if got, want := oidcReq.Header.Get("x-amz-user-agent"), oidcFP.BuildAmzUserAgentFor(oidcFP.OIDCSDKVersion); got != want {
+		t.Errorf("OIDC x-amz-user-agent = %q, want %q", got, want)
+	}
+	kiroIDE := fmt.Sprintf("KiroIDE-%s-%s", oidcFP.KiroVersion, oidcFP.KiroHash)
+	if got := oidcReq.Header.Get("User-Agent"); !strings.HasSuffix(got, " "+kiroIDE) {
+		t.Errorf("OIDC User-Agent = %q, want it to end with %s like the x-amz-user-agent", got, kiroIDE)
+	}
+
+	runtimeFP := GlobalFingerprintManager().GetFingerprintFor("runtime-account", FingerprintPurposeRuntime)
+	runtimeReq, _ := http.NewRequest("GET", "http://example.com", nil)
+	setRuntimeHeaders(runtimeReq, "token", "runtime-account")
+	if got, want := runtimeReq.Header.Get("x-amz-user-agent"), runtimeFP.BuildAmzUserAgentFor(runtimeFP.RuntimeSDKVersion); got != want {
+		t.Errorf("runtime x-amz-user-agent = %q, want %q", got, want)
+	}
+}
+
+func TestFingerprintManagerBinaryRoundTrip(t *testing.T) {
+	fm := NewFingerprintManager()
+	keys := []string{"account-a", "account-b", "account-c:" + FingerprintPurposeOIDC}
+	for _, key := range keys {
+		fm.GetFingerprint(key)
+	}
+
+	data, err := fm.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	restored := NewFingerprintManager()
+	restored.GetFingerprint("stale")
+	generation := restored.Generation()
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+	if restored.Generation() == generation {
+		t.Error("UnmarshalBinary did not advance the generation")
+	}
+	if len(restored.fingerprints) != len(keys) {
+		t.Fatalf("restored %d fingerprints, want %d", len(restored.fingerprints), len(keys))
+	}
+	for _, key := range keys {
+		if got, want := *restored.GetFingerprint(key), *fm.GetFingerprint(key); got != want {
+			t.Errorf("fingerprint %q = %+v, want %+v", key, got, want)
+		}
+	}
+}
+
+func TestFingerprintManagerUnmarshalBinaryRejectsGarbage(t *testing.T) {
+	fm := NewFingerprintManager()
+	want := *fm.GetFingerprint("account")
+	if err := fm.UnmarshalBinary([]byte("not gob")); err == nil {
+		t.Fatal("expected a decode error")
+	}
+	if got := *fm.GetFingerprint("account"); got != want {
+		t.Error("failed UnmarshalBinary changed the cache")
+	}
+}
+
+func TestGetFingerprintForPurpose(t *testing.T) {
+	fm := NewFingerprintManager()
+	oidc := fm.GetFingerprintFor("account", FingerprintPurposeOIDC)
+	if oidc != fm.GetFingerprint("account:"+FingerprintPurposeOIDC) {
+		t.Fatal("GetFingerprintFor did not use tokenKey:purpose as the cache key")
+	}
+	if oidc != fm.GetFingerprintFor("account", FingerprintPurposeOIDC) {
+		t.Fatal("GetFingerprintFor is not cached")
+	}
+	if fm.GetFingerprintFor("account", FingerprintPurposeStreaming) == oidc {
+		t.Fatal("purposes share one fingerprint")
+	}
+
+	fp := &Fingerprint{OIDCSDKVersion: "3.738.0", RuntimeSDKVersion: "1.0.0", StreamingSDKVersion: "1.0.27"}
+	tests := map[string]string{
+		FingerprintPurposeOIDC:      "3.738.0",
+		FingerprintPurposeRuntime:   "1.0.0",
+		FingerprintPurposeStreaming: "1.0.27",
+		"unknown":                   "1.0.27",
+	}
+	for purpose, want := range tests {
+		if got := fp.SDKVersionFor(purpose); got != want {
+			t.Errorf("SDKVersionFor(%q) = %q, want %q", purpose, got, want)
+		}
+	}
+}
+
 func TestBuildAmzUserAgentFormat(t *testing.T) {
 	fm := NewFingerprintManager()
 	fp := fm.GetFingerprint("token1")
@@ -370,7 +515,7 @@ func TestSetRuntimeHeaders(t *testing.T) {
 	accessToken := "test-access-token-1234567890"
 	clientID := "test-client-id-12345"
 	accountKey := GenerateAccountKey(clientID)
-	fp := GlobalFingerprintManager().GetFingerprint(accountKey)
+	fp := GlobalFingerprintManager().GetFingerprintFor(accountKey, FingerprintPurposeRuntime)
 	machineID := fp.KiroHash
 
 	setRuntimeHeaders(req, accessToken, accountKey)
@@ -422,6 +567,30 @@ func TestSetRuntimeHeaders(t *testing.T) {
 	}
 }
 
+func TestInvocationIDUsesPinnedUUID(t *testing.T) {
+	const pinned = "00000000-0000-4000-8000-000000000001"
+	pinUUID(t, pinned)
+
+	oidcReq, _ := http.NewRequest("POST", "http://example.com", nil)
+	SetOIDCHeaders(oidcReq)
+	if got := oidcReq.Header.Get("amz-sdk-invocation-id"); got != pinned {
+		t.Errorf("SetOIDCHeaders invocation id = %q, want %q", got, pinned)
+	}
+
+	runtimeReq, _ := http.NewRequest("POST", "http://example.com", nil)
+	setRuntimeHeaders(runtimeReq, "token", GenerateAccountKey("pinned-client"))
+	if got := runtimeReq.Header.Get("amz-sdk-invocation-id"); got != pinned {
+		t.Errorf("setRuntimeHeaders invocation id = %q, want %q", got, pinned)
+	}
+
+	// The executor's generateAssistantResponse and MCP requests use SetStreamingHeaders.
+	streamingReq, _ := http.NewRequest("POST", "http://example.com", nil)
+	SetStreamingHeaders(streamingReq, "token", GenerateAccountKey("pinned-client"))
+	if got := streamingReq.Header.Get("amz-sdk-invocation-id"); got != pinned {
+		t.Errorf("SetStreamingHeaders invocation id = %q, want %q", got, pinned)
+	}
+}
+
 func TestSDKVersionsAreValid(t *testing.T) {
 	// Verify all OIDC SDK versions match expected format (3.xxx.x)
 	for _, v := range oidcSDKVersions {
@@ -582,6 +751,41 @@ func TestFingerprintManager_SetConfig_ClearsCache(t *testing.T) {
 	}
 }
 
+func TestFingerprintManager_Generation(t *testing.T) {
+	fm := NewFingerprintManager()
+	if got := fm.Generation(); got != 0 {
+		t.Fatalf("initial generation = %d, want 0", got)
+	}
+
+	gen := fm.Generation()
+	fp := fm.GetFingerprint("token1")
+	fm.GetFingerprint("token2")
+	if fm.Generation() != gen {
+		t.Fatal("GetFingerprint must not change the generation")
+	}
+
+	fm.SetConfig(&FingerprintConfig{KiroHash: "rotatedhash"})
+	if got := fm.Generation(); got != gen+1 {
+		t.Fatalf("generation after SetConfig = %d, want %d", got, gen+1)
+	}
+
+	// A caller holding fp detects staleness via the generation and re-fetches.
+	if fm.Generation() == gen {
+		t.Fatal("expected stale generation to be detected")
+	}
+	if fp.KiroHash == "rotatedhash" {
+		t.Fatal("previously returned fingerprint should keep its old value")
+	}
+	if refreshed := fm.GetFingerprint("token1"); refreshed.KiroHash != "rotatedhash" {
+		t.Fatalf("re-fetched KiroHash = %q, want %q", refreshed.KiroHash, "rotatedhash")
+	}
+
+	fm.SetConfig(nil)
+	if got := fm.Generation(); got != gen+2 {
+		t.Fatalf("generation after second SetConfig = %d, want %d", got, gen+2)
+	}
+}
+
 func TestGenerateAccountKey(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -776,3 +980,128 @@ func TestFingerprintDeterministic(t *testing.T) {
 		t.Errorf("NodeVersion should be deterministic: %s vs %s", fp1.NodeVersion, fp3.NodeVersion)
 	}
 }
+
+func TestFingerprintManager_Warm(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  *FingerprintConfig
+	}{
+		{"seeded", nil},
+		{"config", &FingerprintConfig{KiroVersion: "0.10.32"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fm := NewFingerprintManager()
+			if tc.cfg != nil {
+				fm.SetConfig(tc.cfg)
+			}
+
+			keys := make([]string, 0, 257)
+			for i := range 256 {
+				keys = append(keys, fmt.Sprintf("token-%d", i))
+			}
+			keys = append(keys, keys[0]) // duplicates are ignored
+			fm.Warm(keys)
+
+			fm.mu.RLock()
+			cached := make(map[string]*Fingerprint, len(fm.fingerprints))
+			for k, fp := range fm.fingerprints {
+				cached[k] = fp
+			}
+			fm.mu.RUnlock()
+
+			if len(cached) != 256 {
+				t.Fatalf("cached %d fingerprints, want 256", len(cached))
+			}
+			for _, key := range keys {
+				if got := fm.GetFingerprint(key); got != cached[key] {
+					t.Fatalf("GetFingerprint(%q) returned a new fingerprint after Warm", key)
+				}
+			}
+		})
+	}
+}
+
+func TestUserAgentComponents(t *testing.T) {
+	fp := &Fingerprint{
+		StreamingSDKVersion: "1.0.27",
+		RuntimeSDKVersion:   "1.0.9",
+		OSType:              "darwin",
+		OSVersion:           "24.6.0",
+		NodeVersion:         "22.21.1",
+		KiroVersion:         "0.10.0",
+		KiroHash:            "abc123",
+	}
+
+	got := fp.UserAgentComponents()
+	want := UserAgentComponents{
+		SDKVersion:  fp.StreamingSDKVersion,
+		OSType:      fp.OSType,
+		OSVersion:   fp.OSVersion,
+		NodeVersion: fp.NodeVersion,
+		APIName:     "codewhispererstreaming",
+		MetricFlags: "E",
+		KiroVersion: fp.KiroVersion,
+		KiroHash:    fp.KiroHash,
+	}
+	if got != want {
+		t.Fatalf("UserAgentComponents() = %+v, want %+v", got, want)
+	}
+
+	wantUA := "aws-sdk-js/1.0.27 ua/2.1 os/darwin#24.6.0 lang/js md/nodejs#22.21.1 api/codewhispererstreaming#1.0.27 m/E KiroIDE-0.10.0-abc123"
+	if ua := fp.BuildUserAgent(); ua != wantUA {
+		t.Fatalf("BuildUserAgent() = %q, want %q", ua, wantUA)
+	}
+}
+
+func TestFingerprintManagerSessionID(t *testing.T) {
+	fm := NewFingerprintManager()
+
+	first := fm.SessionID("token1")
+	if first == "" {
+		t.Fatal("expected non-empty session ID")
+	}
+	if again := fm.SessionID("token1"); again != first {
+		t.Fatalf("SessionID not stable: %q then %q", first, again)
+	}
+	if other := fm.SessionID("token2"); other == first {
+		t.Fatalf("SessionID(token2) = SessionID(token1) = %q, want different", other)
+	}
+	if fresh := NewFingerprintManager().SessionID("token1"); fresh == first {
+		t.Fatal("session IDs should differ between managers (process restarts)")
+	}
+}
+
+func TestFingerprintManagerSessionIDTTL(t *testing.T) {
+	fm := NewFingerprintManager()
+	now := time.Unix(1_700_000_000, 0)
+	fm.now = func() time.Time { return now }
+	fm.SetSessionTTL(time.Hour)
+
+	first := fm.SessionID("token1")
+	now = now.Add(time.Hour)
+	if rotated := fm.SessionID("token1"); rotated == first {
+		t.Fatal("expected a new session ID after the TTL elapsed")
+	}
+}
+
+func TestSetStreamingHeaders(t *testing.T) {
+	req, _ := http.NewRequest("POST", "http://example.com", nil)
+	accountKey := GenerateAccountKey("test-streaming-client-id")
+	fp := GlobalFingerprintManager().GetFingerprintFor(accountKey, FingerprintPurposeStreaming)
+
+	SetStreamingHeaders(req, "streaming-access-token", accountKey)
+
+	want := map[string]string{
+		"Authorization":               "Bearer streaming-access-token",
+		"User-Agent":                  fp.BuildUserAgent(),
+		"X-Amz-User-Agent":            fp.BuildAmzUserAgent(),
+		"X-Amzn-Kiro-Agent-Mode":      "vibe",
+		"X-Amzn-Codewhisperer-Optout": "true",
+		"Amz-Sdk-Request":             "attempt=1; max=3",
+	}
+	for name, value := range want {
+		if got := req.Header.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package kiro
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// DefaultHTTPClientConfig holds the timeouts of Kiro HTTP clients: 10s to dial,
+// 10s for the TLS handshake, 2m for response headers (CodeWhisperer may take a
+// while to start answering large prompts) and 5m in total for non-streaming
+// requests.
+var DefaultHTTPClientConfig = util.HTTPClientConfig{
+	DialTimeout:           10 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ResponseHeaderTimeout: 2 * time.Minute,
+	TotalTimeout:          5 * time.Minute,
+}
+
+// ClientOption configures the HTTP client used by Kiro auth clients.
+type ClientOption = util.AuthClientOption
+
+// WithHTTPClient uses client instead of building one from the proxy configuration.
+// The minimum TLS version is still enforced on a copy of the client.
+func WithHTTPClient(client *http.Client) ClientOption {
+	return util.WithAuthHTTPClient(client)
+}
+
+// WithMinTLS sets the minimum TLS version (e.g. tls.VersionTLS13) for outbound
+// connections. The default is TLS 1.2.
+func WithMinTLS(version uint16) ClientOption {
+	return util.WithAuthMinTLS(version)
+}
+
+// newHTTPClient builds the HTTP client for a Kiro auth client: the client from
+// WithHTTPClient, or a proxy-aware client that gives up after timeout unless the
+// provider-http settings for "kiro" say otherwise.
+func newHTTPClient(cfg *config.Config, timeout time.Duration, opts []ClientOption) *http.Client {
+	defaults := util.HTTPClientConfig{TotalTimeout: timeout}.WithDefaults(DefaultHTTPClientConfig)
+	return util.NewAuthHTTPClient(cfg, "kiro", defaults, opts...)
+}
+
+// NewHTTPClientWithConfig builds a proxy-aware Kiro HTTP client whose transport
+// honors timeouts. Zero fields use the provider-http settings for "kiro", then
+// DefaultHTTPClientConfig. The total timeout covers reading the response body.
+func NewHTTPClientWithConfig(cfg *config.Config, timeouts util.HTTPClientConfig) *http.Client {
+	return util.NewProviderHTTPClient(cfg, "kiro", timeouts, DefaultHTTPClientConfig, false)
+}
+
+// NewStreamingHTTPClientWithConfig is like NewHTTPClientWithConfig but does not
+// apply the total timeout, so streaming bodies can be read for as long as the
+// upstream keeps sending. Dial, TLS handshake and response header timeouts
+// still apply.
+func NewStreamingHTTPClientWithConfig(cfg *config.Config, timeouts util.HTTPClientConfig) *http.Client {
+	return util.NewProviderHTTPClient(cfg, "kiro", timeouts, DefaultHTTPClientConfig, true)
+}
+
+// KiroHTTPClient sends Kiro requests with the headers their API expects: OIDC
+// requests get the SSO OIDC SDK fingerprint, runtime requests the bearer token
+// and the account's runtime fingerprint. Runtime requests go through a client
+// without a total timeout so long responses can be read to the end.
+type KiroHTTPClient struct {
+	client    *http.Client
+	streaming *http.Client
+}
+
+// KiroHTTPClientOption configures a KiroHTTPClient.
+type KiroHTTPClientOption func(*KiroHTTPClient)
+
+// WithTransport sends requests through transport, e.g. a recording transport in
+// tests. The clients' timeouts are kept.
+func WithTransport(transport http.RoundTripper) KiroHTTPClientOption {
+	return func(c *KiroHTTPClient) {
+		client := *c.client
+		client.Transport = transport
+		c.client = &client
+		streaming := *c.streaming
+		streaming.Transport = transport
+		c.streaming = &streaming
+	}
+}
+
+// NewKiroHTTPClient builds a KiroHTTPClient with the default timeouts: OIDC
+// requests use NewHTTPClientWithConfig, runtime requests
+// NewStreamingHTTPClientWithConfig.
+func NewKiroHTTPClient(cfg *config.Config, opts ...KiroHTTPClientOption) *KiroHTTPClient {
+	c := &KiroHTTPClient{
+		client:    NewHTTPClientWithConfig(cfg, util.HTTPClientConfig{}),
+		streaming: NewStreamingHTTPClientWithConfig(cfg, util.HTTPClientConfig{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// wrapHTTPClient returns a KiroHTTPClient that sends every request through
+// client, for auth clients built with their own timeout or WithHTTPClient.
+func wrapHTTPClient(client *http.Client) *KiroHTTPClient {
+	return &KiroHTTPClient{client: client, streaming: client}
+}
+
+// HTTPClient returns the client used for OIDC requests.
+func (c *KiroHTTPClient) HTTPClient() *http.Client {
+	return c.client
+}
+
+// StreamingHTTPClient returns the client used for runtime requests.
+func (c *KiroHTTPClient) StreamingHTTPClient() *http.Client {
+	return c.streaming
+}
+
+// DoOIDC applies the SSO OIDC headers to req and sends it.
+func (c *KiroHTTPClient) DoOIDC(req *http.Request) (*http.Response, error) {
+	SetOIDCHeaders(req)
+	return c.client.Do(req)
+}
+
+// DoRuntime applies the runtime API headers for accessToken and the account
+// identified by accountKey (see GetAccountKey) to req and sends it.
+func (c *KiroHTTPClient) DoRuntime(req *http.Request, accessToken, accountKey string) (*http.Response, error) {
+	setRuntimeHeaders(req, accessToken, accountKey)
+	return c.streaming.Do(req)
+}
@@ -0,0 +1,152 @@
+package kiro
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+func TestClientMinTLS(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []ClientOption
+		want uint16
+	}{
+		{"default", nil, tls.VersionTLS12},
+		{"configured", []ClientOption{WithMinTLS(tls.VersionTLS13)}, tls.VersionTLS13},
+		{"custom client", []ClientOption{WithHTTPClient(&http.Client{}), WithMinTLS(tls.VersionTLS13)}, tls.VersionTLS13},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewSSOOIDCClient(&config.Config{}, tt.opts...).httpClient
+			transport, ok := client.Transport.(*http.Transport)
+			if !ok {
+				t.Fatalf("transport = %T, want *http.Transport", client.Transport)
+			}
+			if got := transport.TLSClientConfig.MinVersion; got != tt.want {
+				t.Fatalf("MinVersion = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHTTPClientWithConfig(t *testing.T) {
+	custom := util.HTTPClientConfig{ResponseHeaderTimeout: 7 * time.Second}
+	for _, streaming := range []bool{false, true} {
+		client := NewHTTPClientWithConfig(&config.Config{}, custom)
+		if streaming {
+			client = NewStreamingHTTPClientWithConfig(&config.Config{}, custom)
+		}
+		transport, ok := client.Transport.(*http.Transport)
+		if !ok {
+			t.Fatalf("streaming=%v: transport = %T, want *http.Transport", streaming, client.Transport)
+		}
+		if transport.ResponseHeaderTimeout != custom.ResponseHeaderTimeout {
+			t.Errorf("streaming=%v: ResponseHeaderTimeout = %v, want %v", streaming, transport.ResponseHeaderTimeout, custom.ResponseHeaderTimeout)
+		}
+		if transport.TLSHandshakeTimeout != DefaultHTTPClientConfig.TLSHandshakeTimeout {
+			t.Errorf("streaming=%v: TLSHandshakeTimeout = %v, want default %v", streaming, transport.TLSHandshakeTimeout, DefaultHTTPClientConfig.TLSHandshakeTimeout)
+		}
+		if transport.TLSClientConfig == nil || transport.TLSClientConfig.MinVersion != tls.VersionTLS12 {
+			t.Errorf("streaming=%v: minimum TLS version not enforced", streaming)
+		}
+		wantTotal := DefaultHTTPClientConfig.TotalTimeout
+		if streaming {
+			wantTotal = 0
+		}
+		if client.Timeout != wantTotal {
+			t.Errorf("streaming=%v: client Timeout = %v, want %v", streaming, client.Timeout, wantTotal)
+		}
+	}
+}
+
+// headerRecordingTransport records every request and answers 200 with an empty
+// JSON object.
+type headerRecordingTransport struct {
+	requests []*http.Request
+}
+
+func (rt *headerRecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.requests = append(rt.requests, req)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(`{}`)),
+		Header:     make(http.Header),
+		Request:    req,
+	}, nil
+}
+
+func TestKiroHTTPClientAppliesHeaders(t *testing.T) {
+	const invocationID = "11111111-2222-4333-8444-555555555555"
+	pinUUID(t, invocationID)
+	rt := &headerRecordingTransport{}
+	client := NewKiroHTTPClient(&config.Config{}, WithTransport(rt))
+	if client.HTTPClient().Transport != rt || client.StreamingHTTPClient().Transport != rt {
+		t.Fatal("WithTransport did not replace the transports")
+	}
+	if client.HTTPClient().Timeout == 0 {
+		t.Error("OIDC client has no total timeout")
+	}
+	if got := client.StreamingHTTPClient().Timeout; got != 0 {
+		t.Errorf("runtime client Timeout = %v, want none so long responses can be read", got)
+	}
+
+	oidcReq, _ := http.NewRequest(http.MethodPost, "https://oidc.us-east-1.amazonaws.com/token", nil)
+	resp, errOIDC := client.DoOIDC(oidcReq)
+	if errOIDC != nil {
+		t.Fatalf("DoOIDC: %v", errOIDC)
+	}
+	_ = resp.Body.Close()
+
+	accountKey := GetAccountKey("client-id", "refresh-token")
+	runtimeReq, _ := http.NewRequest(http.MethodGet, "https://q.us-east-1.amazonaws.com/getUsageLimits", nil)
+	resp, errRuntime := client.DoRuntime(runtimeReq, "access-token", accountKey)
+	if errRuntime != nil {
+		t.Fatalf("DoRuntime: %v", errRuntime)
+	}
+	_ = resp.Body.Close()
+
+	if len(rt.requests) != 2 {
+		t.Fatalf("recorded %d requests, want 2", len(rt.requests))
+	}
+
+	oidc := rt.requests[0].Header
+	if got := oidc.Get("Content-Type"); got != "application/json" {
+		t.Errorf("OIDC Content-Type = %q", got)
+	}
+	if !strings.Contains(oidc.Get("User-Agent"), "api/sso-oidc#") {
+		t.Errorf("OIDC User-Agent = %q, want the sso-oidc API", oidc.Get("User-Agent"))
+	}
+	if got := oidc.Get("amz-sdk-request"); got != "attempt=1; max=4" {
+		t.Errorf("OIDC amz-sdk-request = %q", got)
+	}
+	if got := oidc.Get("Authorization"); got != "" {
+		t.Errorf("OIDC request carries Authorization %q", got)
+	}
+
+	runtime := rt.requests[1].Header
+	if got := runtime.Get("Authorization"); got != "Bearer access-token" {
+		t.Errorf("runtime Authorization = %q", got)
+	}
+	fp := GlobalFingerprintManager().GetFingerprintFor(accountKey, FingerprintPurposeRuntime)
+	if got, want := runtime.Get("X-Amz-User-Agent"), fp.BuildAmzUserAgentFor(fp.RuntimeSDKVersion); got != want {
+		t.Errorf("runtime X-Amz-User-Agent = %q, want %q", got, want)
+	}
+	if !strings.Contains(runtime.Get("User-Agent"), "codewhispererruntime") {
+		t.Errorf("runtime User-Agent = %q, want the runtime API", runtime.Get("User-Agent"))
+	}
+	if got := runtime.Get("amz-sdk-request"); got != "attempt=1; max=1" {
+		t.Errorf("runtime amz-sdk-request = %q", got)
+	}
+	for i, req := range rt.requests {
+		if got := req.Header.Get("amz-sdk-invocation-id"); got != invocationID {
+			t.Errorf("request %d amz-sdk-invocation-id = %q, want %q", i, got, invocationID)
+		}
+	}
+}
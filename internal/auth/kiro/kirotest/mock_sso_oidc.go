@@ -0,0 +1,155 @@
+// Package kirotest provides test doubles for the Kiro auth clients.
+package kirotest
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	kiroauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
+)
+
+// Method names recorded in MockCall.Method.
+const (
+	MethodRegisterClient        = "RegisterClientWithRegion"
+	MethodCreateToken           = "CreateTokenWithRegion"
+	MethodRefreshToken          = "RefreshTokenWithRegion"
+	MethodRefreshBuilderIDToken = "RefreshToken"
+	MethodRefreshIDCToken       = "RefreshIDCToken"
+	MethodListProfiles          = "FetchProfileArn"
+)
+
+// MockCall is one recorded call to a MockSSOOIDCClient. Args holds the call's
+// arguments in order, without the context.
+type MockCall struct {
+	Method string
+	Args   []string
+}
+
+// MockSSOOIDCClient is a kiroauth.OIDCClient that answers with canned responses
+// and records every call. Methods without a configured response return a nil
+// result and no error. It is safe for concurrent use.
+type MockSSOOIDCClient struct {
+	mu sync.Mutex
+
+	registerResp *kiroauth.RegisterClientResponse
+	registerErr  error
+	createResp   *kiroauth.CreateTokenResponse
+	createErr    error
+	refreshResp  *kiroauth.KiroTokenData
+	refreshErr   error
+	profileArn   string
+
+	calls []MockCall
+}
+
+var _ kiroauth.OIDCClient = (*MockSSOOIDCClient)(nil)
+
+// NewMockSSOOIDCClient returns a mock with no responses configured.
+func NewMockSSOOIDCClient() *MockSSOOIDCClient {
+	return &MockSSOOIDCClient{}
+}
+
+// SetRegisterClientResponse sets the result of RegisterClientWithRegion.
+func (m *MockSSOOIDCClient) SetRegisterClientResponse(resp *kiroauth.RegisterClientResponse, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.registerResp, m.registerErr = resp, err
+}
+
+// SetCreateTokenResponse sets the result of CreateTokenWithRegion.
+func (m *MockSSOOIDCClient) SetCreateTokenResponse(resp *kiroauth.CreateTokenResponse, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.createResp, m.createErr = resp, err
+}
+
+// SetRefreshTokenResponse sets the result of RefreshTokenWithRegion,
+// RefreshToken and RefreshIDCToken. Each call returns its own copy of token.
+func (m *MockSSOOIDCClient) SetRefreshTokenResponse(token *kiroauth.KiroTokenData, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refreshResp, m.refreshErr = token.Clone(), err
+}
+
+// SetListProfilesResponse sets the profile ARN returned by FetchProfileArn.
+func (m *MockSSOOIDCClient) SetListProfilesResponse(profileArn string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.profileArn = profileArn
+}
+
+// Calls returns the recorded calls in the order they were made.
+func (m *MockSSOOIDCClient) Calls() []MockCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	calls := make([]MockCall, len(m.calls))
+	for i, call := range m.calls {
+		calls[i] = MockCall{Method: call.Method, Args: slices.Clone(call.Args)}
+	}
+	return calls
+}
+
+func (m *MockSSOOIDCClient) record(method string, args ...string) {
+	m.calls = append(m.calls, MockCall{Method: method, Args: args})
+}
+
+// RegisterClientWithRegion records the call and returns the configured response.
+func (m *MockSSOOIDCClient) RegisterClientWithRegion(_ context.Context, region string) (*kiroauth.RegisterClientResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.record(MethodRegisterClient, region)
+	if m.registerResp == nil {
+		return nil, m.registerErr
+	}
+	resp := *m.registerResp
+	return &resp, m.registerErr
+}
+
+// CreateTokenWithRegion records the call and returns the configured response.
+func (m *MockSSOOIDCClient) CreateTokenWithRegion(_ context.Context, clientID, clientSecret, deviceCode, region string) (*kiroauth.CreateTokenResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.record(MethodCreateToken, clientID, clientSecret, deviceCode, region)
+	if m.createResp == nil {
+		return nil, m.createErr
+	}
+	resp := *m.createResp
+	return &resp, m.createErr
+}
+
+// RefreshToken records the call and returns the configured refresh response.
+func (m *MockSSOOIDCClient) RefreshToken(_ context.Context, clientID, clientSecret, refreshToken string) (*kiroauth.KiroTokenData, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.record(MethodRefreshBuilderIDToken, clientID, clientSecret, refreshToken)
+	return m.refreshResp.Clone(), m.refreshErr
+}
+
+// RefreshIDCToken records the token's client ID, client secret, refresh token,
+// region and start URL and returns the configured refresh response.
+func (m *MockSSOOIDCClient) RefreshIDCToken(_ context.Context, token *kiroauth.KiroTokenData) (*kiroauth.KiroTokenData, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if token == nil {
+		token = &kiroauth.KiroTokenData{}
+	}
+	m.record(MethodRefreshIDCToken, token.ClientID, token.ClientSecret, token.RefreshToken, token.Region, token.StartURL)
+	return m.refreshResp.Clone(), m.refreshErr
+}
+
+// RefreshTokenWithRegion records the call and returns the configured response.
+func (m *MockSSOOIDCClient) RefreshTokenWithRegion(_ context.Context, clientID, clientSecret, refreshToken, region, startURL string) (*kiroauth.KiroTokenData, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.record(MethodRefreshToken, clientID, clientSecret, refreshToken, region, startURL)
+	return m.refreshResp.Clone(), m.refreshErr
+}
+
+// FetchProfileArn records the call and returns the configured profile ARN.
+func (m *MockSSOOIDCClient) FetchProfileArn(_ context.Context, accessToken, clientID, refreshToken string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.record(MethodListProfiles, accessToken, clientID, refreshToken)
+	return m.profileArn
+}
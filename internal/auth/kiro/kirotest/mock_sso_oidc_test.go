@@ -0,0 +1,89 @@
+package kirotest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	kiroauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
+)
+
+func TestMockSSOOIDCClient(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockSSOOIDCClient()
+	errRefresh := errors.New("refresh failed")
+	mock.SetRegisterClientResponse(&kiroauth.RegisterClientResponse{ClientID: "client", ClientSecret: "secret"}, nil)
+	mock.SetRefreshTokenResponse(&kiroauth.KiroTokenData{AccessToken: "access"}, errRefresh)
+	mock.SetListProfilesResponse("arn:aws:codewhisperer:us-east-1:123456789012:profile/ABC")
+
+	var client kiroauth.OIDCClient = mock
+	reg, errRegister := client.RegisterClientWithRegion(ctx, "eu-west-1")
+	if errRegister != nil || reg.ClientID != "client" || reg.ClientSecret != "secret" {
+		t.Fatalf("RegisterClientWithRegion = (%+v, %v)", reg, errRegister)
+	}
+	token, err := client.RefreshTokenWithRegion(ctx, "client", "secret", "refresh", "eu-west-1", "https://org.awsapps.com/start")
+	if !errors.Is(err, errRefresh) || token == nil || token.AccessToken != "access" {
+		t.Fatalf("RefreshTokenWithRegion = (%+v, %v)", token, err)
+	}
+	token.AccessToken = "mutated"
+	if again, _ := client.RefreshTokenWithRegion(ctx, "", "", "", "", ""); again.AccessToken != "access" {
+		t.Errorf("mutating a returned token changed the configured response: %q", again.AccessToken)
+	}
+	if arn := client.FetchProfileArn(ctx, "access", "client", "refresh"); arn != "arn:aws:codewhisperer:us-east-1:123456789012:profile/ABC" {
+		t.Errorf("FetchProfileArn = %q", arn)
+	}
+
+	want := []MockCall{
+		{Method: MethodRegisterClient, Args: []string{"eu-west-1"}},
+		{Method: MethodRefreshToken, Args: []string{"client", "secret", "refresh", "eu-west-1", "https://org.awsapps.com/start"}},
+		{Method: MethodRefreshToken, Args: []string{"", "", "", "", ""}},
+		{Method: MethodListProfiles, Args: []string{"access", "client", "refresh"}},
+	}
+	if got := mock.Calls(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Calls() = %+v, want %+v", got, want)
+	}
+}
+
+func TestMockSSOOIDCClientDefaults(t *testing.T) {
+	mock := NewMockSSOOIDCClient()
+	if reg, err := mock.RegisterClientWithRegion(context.Background(), "us-east-1"); reg != nil || err != nil {
+		t.Errorf("RegisterClientWithRegion = (%+v, %v), want (nil, nil)", reg, err)
+	}
+	if token, err := mock.RefreshTokenWithRegion(context.Background(), "", "", "", "", ""); token != nil || err != nil {
+		t.Errorf("RefreshTokenWithRegion = (%+v, %v), want (nil, nil)", token, err)
+	}
+	if arn := mock.FetchProfileArn(context.Background(), "", "", ""); arn != "" {
+		t.Errorf("FetchProfileArn = %q, want empty", arn)
+	}
+	if calls := mock.Calls(); len(calls) != 3 {
+		t.Errorf("recorded %d calls, want 3", len(calls))
+	}
+}
+
+func TestMockSSOOIDCClientTokenMethods(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockSSOOIDCClient()
+	mock.SetCreateTokenResponse(&kiroauth.CreateTokenResponse{AccessToken: "created"}, nil)
+	mock.SetRefreshTokenResponse(&kiroauth.KiroTokenData{AccessToken: "refreshed"}, nil)
+
+	if resp, err := mock.CreateTokenWithRegion(ctx, "client", "secret", "device", "eu-west-1"); err != nil || resp.AccessToken != "created" {
+		t.Fatalf("CreateTokenWithRegion = (%+v, %v)", resp, err)
+	}
+	if token, err := mock.RefreshToken(ctx, "client", "secret", "refresh"); err != nil || token.AccessToken != "refreshed" {
+		t.Fatalf("RefreshToken = (%+v, %v)", token, err)
+	}
+	idcToken := &kiroauth.KiroTokenData{ClientID: "client", ClientSecret: "secret", RefreshToken: "refresh", Region: "eu-west-1", StartURL: "https://org.awsapps.com/start"}
+	if token, err := mock.RefreshIDCToken(ctx, idcToken); err != nil || token.AccessToken != "refreshed" {
+		t.Fatalf("RefreshIDCToken = (%+v, %v)", token, err)
+	}
+
+	want := []MockCall{
+		{Method: MethodCreateToken, Args: []string{"client", "secret", "device", "eu-west-1"}},
+		{Method: MethodRefreshBuilderIDToken, Args: []string{"client", "secret", "refresh"}},
+		{Method: MethodRefreshIDCToken, Args: []string{"client", "secret", "refresh", "eu-west-1", "https://org.awsapps.com/start"}},
+	}
+	if got := mock.Calls(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Calls() = %+v, want %+v", got, want)
+	}
+}
@@ -13,10 +13,10 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
-	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -48,11 +48,8 @@ type KiroOAuth struct {
 }
 
 // NewKiroOAuth creates a new Kiro OAuth handler.
-func NewKiroOAuth(cfg *config.Config) *KiroOAuth {
-	client := &http.Client{Timeout: 30 * time.Second}
-	if cfg != nil {
-		client = util.SetProxy(&cfg.SDKConfig, client)
-	}
+func NewKiroOAuth(cfg *config.Config, opts ...ClientOption) *KiroOAuth {
+	client := newHTTPClient(cfg, 30*time.Second, opts)
 	fp := GlobalFingerprintManager().GetFingerprint("login")
 	return &KiroOAuth{
 		httpClient:  client,
@@ -110,6 +107,17 @@ func (o *KiroOAuth) startCallbackServer(ctx context.Context, expectedState strin
 	// Use http scheme for local callback server
 	redirectURI := fmt.Sprintf("http://localhost:%d/oauth/callback", port)
 	resultChan := make(chan AuthResult, 1)
+	doneChan := make(chan struct{})
+
+	// Only the first callback is delivered; repeated callbacks must not block
+	// the handler goroutine on the buffered channel.
+	var finishOnce sync.Once
+	finish := func(result AuthResult) {
+		finishOnce.Do(func() {
+			resultChan <- result
+			close(doneChan)
+		})
+	}
 
 	server := &http.Server{
 		ReadHeaderTimeout: 10 * time.Second,
@@ -125,21 +133,21 @@ func (o *KiroOAuth) startCallbackServer(ctx context.Context, expectedState strin
 			w.Header().Set("Content-Type", "text/html")
 			w.WriteHeader(http.StatusBadRequest)
 			fmt.Fprintf(w, `<html><body><h1>Login Failed</h1><p>%s</p><p>You can close this window.</p></body></html>`, html.EscapeString(errParam))
-			resultChan <- AuthResult{Error: errParam}
+			finish(AuthResult{Error: errParam})
 			return
 		}
 
-		if state != expectedState {
+		if !secureEqual(state, expectedState) {
 			w.Header().Set("Content-Type", "text/html")
 			w.WriteHeader(http.StatusBadRequest)
 			fmt.Fprint(w, `<html><body><h1>Login Failed</h1><p>Invalid state parameter</p><p>You can close this window.</p></body></html>`)
-			resultChan <- AuthResult{Error: "state mismatch"}
+			finish(AuthResult{Error: "state mismatch"})
 			return
 		}
 
 		w.Header().Set("Content-Type", "text/html")
 		fmt.Fprint(w, `<html><body><h1>Login Successful!</h1><p>You can close this window and return to the terminal.</p></body></html>`)
-		resultChan <- AuthResult{Code: code, State: state}
+		finish(AuthResult{Code: code, State: state})
 	})
 
 	server.Handler = mux
@@ -154,7 +162,7 @@ func (o *KiroOAuth) startCallbackServer(ctx context.Context, expectedState strin
 		select {
 		case <-ctx.Done():
 		case <-time.After(authTimeout):
-		case <-resultChan:
+		case <-doneChan:
 		}
 		_ = server.Shutdown(context.Background())
 	}()
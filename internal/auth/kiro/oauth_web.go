@@ -48,7 +48,7 @@ type webAuthSession struct {
 	expiresAt       time.Time
 	error           string
 	tokenData       *KiroTokenData
-	ssoClient       *SSOOIDCClient
+	ssoClient       OIDCClient
 	clientID        string
 	clientSecret    string
 	region          string
@@ -64,6 +64,7 @@ type OAuthWebHandler struct {
 	sessions        map[string]*webAuthSession
 	mu              sync.RWMutex
 	onTokenObtained func(*KiroTokenData)
+	oidcClient      OIDCClient // client used by manual refresh; nil creates an SSOOIDCClient per refresh
 }
 
 func NewOAuthWebHandler(cfg *config.Config) *OAuthWebHandler {
@@ -131,7 +132,7 @@ func (h *OAuthWebHandler) startSocialAuth(c *gin.Context, method string) {
 		return
 	}
 
-	codeVerifier, codeChallenge, err := generatePKCE()
+	codeVerifier, codeChallenge, err := GeneratePKCE()
 	if err != nil {
 		h.renderError(c, "Failed to generate PKCE parameters")
 		return
@@ -259,9 +260,7 @@ func (h *OAuthWebHandler) startIDCAuth(c *gin.Context) {
 		h.renderError(c, "Missing startUrl parameter for IDC authentication")
 		return
 	}
-	if region == "" {
-		region = defaultIDCRegion
-	}
+	region = OIDCRegionForStartURL(startURL, region)
 
 	stateID, err := generateStateID()
 	if err != nil {
@@ -341,6 +340,7 @@ func (h *OAuthWebHandler) pollForToken(ctx context.Context, session *webAuthSess
 				session.error = "Authentication timed out"
 			}
 			h.mu.Unlock()
+			AuditDevicePollAbandoned(session.clientID, ctx.Err())
 			return
 		case <-ticker.C:
 			tokenResp, err := h.ssoClient(session).CreateTokenWithRegion(
@@ -369,6 +369,7 @@ func (h *OAuthWebHandler) pollForToken(ctx context.Context, session *webAuthSess
 				h.mu.Unlock()
 
 				log.Errorf("OAuth Web: token polling failed: %v", err)
+				AuditDevicePollAbandoned(session.clientID, err)
 				return
 			}
 
@@ -416,6 +417,20 @@ func (h *OAuthWebHandler) pollForToken(ctx context.Context, session *webAuthSess
 	}
 }
 
+// tokenFileName names tokenData's file with the kiro-token-file-naming namer. A
+// re-login of the same account overwrites its previous file.
+func (h *OAuthWebHandler) tokenFileName(tokenData *KiroTokenData) string {
+	naming := ""
+	if h.cfg != nil {
+		naming = h.cfg.KiroTokenFileNaming
+	}
+	namer, ok := TokenFileNamerByName(naming)
+	if !ok {
+		log.Warnf("OAuth Web: unknown kiro-token-file-naming %q, using %q", naming, TokenFileNamingEmail)
+	}
+	return NewFileTokenRepository("", WithTokenFileNamer(namer)).BaseTokenFileName(tokenData)
+}
+
 // saveTokenToFile saves the token data to the auth directory
 func (h *OAuthWebHandler) saveTokenToFile(tokenData *KiroTokenData) {
 	// Get auth directory from config or use default
@@ -444,8 +459,7 @@ func (h *OAuthWebHandler) saveTokenToFile(tokenData *KiroTokenData) {
 		return
 	}
 
-	// Generate filename using the unified function
-	fileName := GenerateTokenFileName(tokenData)
+	fileName := h.tokenFileName(tokenData)
 
 	authFilePath := filepath.Join(authDir, fileName)
 
@@ -474,7 +488,7 @@ func (h *OAuthWebHandler) saveTokenToFile(tokenData *KiroTokenData) {
 	log.Infof("OAuth Web: token saved to %s", authFilePath)
 }
 
-func (h *OAuthWebHandler) ssoClient(session *webAuthSession) *SSOOIDCClient {
+func (h *OAuthWebHandler) ssoClient(session *webAuthSession) OIDCClient {
 	return session.ssoClient
 }
 
@@ -809,8 +823,7 @@ func (h *OAuthWebHandler) handleImportToken(c *gin.Context) {
 	// Save token to file
 	h.saveTokenToFile(tokenData)
 
-	// Generate filename for response using the unified function
-	fileName := GenerateTokenFileName(tokenData)
+	fileName := h.tokenFileName(tokenData)
 
 	log.Infof("OAuth Web: token imported successfully")
 	c.JSON(http.StatusOK, gin.H{
@@ -952,24 +965,37 @@ func (h *OAuthWebHandler) handleManualRefresh(c *gin.Context) {
 
 // refreshTokenData refreshes a token using the appropriate method based on auth type.
 // This mirrors the logic in kiro_executor.Refresh for consistency.
+// The refreshed token is rejected if it identifies a different account.
 func (h *OAuthWebHandler) refreshTokenData(ctx context.Context, storage *KiroTokenStorage) (*KiroTokenData, error) {
-	ssoClient := NewSSOOIDCClient(h.cfg)
+	ssoClient := h.oidcClient
+	if ssoClient == nil {
+		ssoClient = NewSSOOIDCClient(h.cfg)
+	}
 
+	var tokenData *KiroTokenData
+	var err error
 	switch {
 	case storage.ClientID != "" && storage.ClientSecret != "" && storage.AuthMethod == "idc" && storage.Region != "":
 		// IDC refresh with region-specific endpoint
 		log.Debugf("OAuth Web: using SSO OIDC refresh for IDC (region=%s)", storage.Region)
-		return ssoClient.RefreshTokenWithRegion(ctx, storage.ClientID, storage.ClientSecret, storage.RefreshToken, storage.Region, storage.StartURL)
+		tokenData, err = ssoClient.RefreshIDCToken(ctx, storage.ToTokenData())
 
 	case storage.ClientID != "" && storage.ClientSecret != "" && storage.AuthMethod == "builder-id":
 		// Builder ID refresh with default endpoint
 		log.Debugf("OAuth Web: using SSO OIDC refresh for AWS Builder ID")
-		return ssoClient.RefreshToken(ctx, storage.ClientID, storage.ClientSecret, storage.RefreshToken)
+		tokenData, err = ssoClient.RefreshToken(ctx, storage.ClientID, storage.ClientSecret, storage.RefreshToken)
 
 	default:
 		// Fallback to Kiro's OAuth refresh endpoint (for social auth: Google/GitHub)
 		log.Debugf("OAuth Web: using Kiro OAuth refresh endpoint")
 		oauth := NewKiroOAuth(h.cfg)
-		return oauth.RefreshToken(ctx, storage.RefreshToken)
+		tokenData, err = oauth.RefreshToken(ctx, storage.RefreshToken)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if errIdentity := VerifyRefreshedIdentity(storage.Email, storage.AccessToken, tokenData.AccessToken); errIdentity != nil {
+		return nil, errIdentity
 	}
+	return tokenData, nil
 }
@@ -0,0 +1,58 @@
+package kiro
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestOAuthWebRefreshTokenDataRejectsDifferentAccount(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(CreateTokenResponse{
+			AccessToken:  createTestJWT(map[string]any{"email": "bob@example.com", "sub": "user-bob"}),
+			RefreshToken: "bob-refresh-token",
+			ExpiresIn:    3600,
+		})
+	}))
+	t.Cleanup(ts.Close)
+
+	h := NewOAuthWebHandler(nil)
+	h.oidcClient = NewSSOOIDCClient(nil, WithHTTPClient(&http.Client{Transport: &rewriteTransport{targetURL: ts.URL}}))
+	storage := &KiroTokenStorage{
+		AccessToken:  createTestJWT(map[string]any{"email": "alice@example.com", "sub": "user-alice"}),
+		RefreshToken: "refresh-token",
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		AuthMethod:   "builder-id",
+		Email:        "alice@example.com",
+	}
+
+	if _, err := h.refreshTokenData(context.Background(), storage); !errors.Is(err, ErrRefreshIdentityMismatch) {
+		t.Fatalf("refreshTokenData() error = %v, want ErrRefreshIdentityMismatch", err)
+	}
+}
+
+func TestOAuthWebSaveTokenUsesConfiguredNamer(t *testing.T) {
+	dir := t.TempDir()
+	h := NewOAuthWebHandler(&config.Config{AuthDir: dir, KiroTokenFileNaming: TokenFileNamingAccountKey})
+	tokenData := &KiroTokenData{AuthMethod: "idc", ClientID: "client-1", RefreshToken: "refresh", Email: "user@example.com"}
+
+	h.saveTokenToFile(tokenData)
+	h.saveTokenToFile(tokenData)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "kiro-" + GenerateAccountKey("client-1") + ".json"
+	if len(entries) != 1 || entries[0].Name() != want {
+		t.Fatalf("auth dir = %v, want only %s", entries, want)
+	}
+}
@@ -11,13 +11,20 @@ import (
 )
 
 // RefreshManager is a singleton manager for background token refreshing.
+//
+// Locking: mu guards the refresher lifecycle (refresher, ctx, cancel, started)
+// and callbackMu guards onTokenRefreshed and onRefreshResult. The two are never held together, so
+// callback registration can interleave with Initialize/Start/Stop, and a
+// callback that calls back into the manager cannot deadlock against Stop.
 type RefreshManager struct {
 	mu               sync.Mutex
 	refresher        *BackgroundRefresher
 	ctx              context.Context
 	cancel           context.CancelFunc
 	started          bool
+	callbackMu       sync.RWMutex
 	onTokenRefreshed func(tokenID string, tokenData *KiroTokenData)
+	onRefreshResult  func(result TokenRefreshResult)
 }
 
 var (
@@ -63,11 +70,10 @@ func (m *RefreshManager) Initialize(baseDir string, cfg *config.Config) error {
 		WithBatchSize(50),
 		WithConcurrency(10),
 		WithConfig(cfg),
-	}
-
-	// Pass callback to BackgroundRefresher if already set
-	if m.onTokenRefreshed != nil {
-		opts = append(opts, WithOnTokenRefreshed(m.onTokenRefreshed))
+		// Always route through the manager so callbacks registered before or
+		// after Initialize are picked up without touching the refresher.
+		WithOnTokenRefreshed(m.notifyTokenRefreshed),
+		WithOnTokenRefreshedResult(m.notifyRefreshResult),
 	}
 
 	m.refresher = NewBackgroundRefresher(repo, opts...)
@@ -98,24 +104,37 @@ func (m *RefreshManager) Start() {
 	log.Info("refresh manager: background refresh started")
 }
 
-// Stop halts background token refreshing.
+// Stop halts background token refreshing, waiting up to defaultStopTimeout
+// for in-flight refreshes to finish persisting.
 func (m *RefreshManager) Stop() {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.StopWithTimeout(defaultStopTimeout)
+}
 
+// StopWithTimeout halts background token refreshing, waiting up to timeout for
+// in-flight refreshes to complete before cancelling any that remain. The wait
+// happens without holding mu, so other manager calls are not blocked by it.
+// A stopped refresher cannot be restarted; Initialize must run again before Start.
+func (m *RefreshManager) StopWithTimeout(timeout time.Duration) {
+	m.mu.Lock()
 	if !m.started {
+		m.mu.Unlock()
 		return
 	}
+	refresher, cancel := m.refresher, m.cancel
+	m.refresher, m.ctx, m.cancel = nil, nil, nil
+	m.started = false
+	m.mu.Unlock()
 
-	if m.cancel != nil {
-		m.cancel()
+	// Let in-flight refreshes finish before cancelling the shared context,
+	// otherwise a refresh could be aborted between the OIDC call and persistence.
+	if refresher != nil {
+		refresher.StopWithTimeout(timeout)
 	}
 
-	if m.refresher != nil {
-		m.refresher.Stop()
+	if cancel != nil {
+		cancel()
 	}
 
-	m.started = false
 	log.Info("refresh manager: background refresh stopped")
 }
 
@@ -140,21 +159,52 @@ func (m *RefreshManager) UpdateBaseDir(baseDir string) {
 }
 
 // SetOnTokenRefreshed registers a callback invoked after a successful token refresh.
-// Can be called at any time; supports runtime callback updates.
+// Can be called at any time, before or after Initialize; supports runtime callback updates.
 func (m *RefreshManager) SetOnTokenRefreshed(callback func(tokenID string, tokenData *KiroTokenData)) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
+	m.callbackMu.Lock()
 	m.onTokenRefreshed = callback
+	m.callbackMu.Unlock()
 
-	// Update the refresher's callback in a thread-safe manner if already created
-	if m.refresher != nil {
-		m.refresher.callbackMu.Lock()
-		m.refresher.onTokenRefreshed = callback
-		m.refresher.callbackMu.Unlock()
+	log.Debug("refresh manager: token refresh callback registered")
+}
+
+// SetOnTokenRefreshedResult registers a callback invoked after every refresh
+// attempt with the token ID, refresh duration, error and new expiry. It is
+// called alongside the SetOnTokenRefreshed callback and can be set at any time.
+func (m *RefreshManager) SetOnTokenRefreshedResult(callback func(result TokenRefreshResult)) {
+	m.callbackMu.Lock()
+	m.onRefreshResult = callback
+	m.callbackMu.Unlock()
+
+	log.Debug("refresh manager: token refresh result callback registered")
+}
+
+// notifyRefreshResult forwards a refresh attempt result to the currently
+// registered result callback.
+func (m *RefreshManager) notifyRefreshResult(result TokenRefreshResult) {
+	m.callbackMu.RLock()
+	callback := m.onRefreshResult
+	m.callbackMu.RUnlock()
+
+	if callback != nil {
+		callback(result)
+	}
+}
+
+// notifyTokenRefreshed records an audit event and forwards a refresh result to the
+// currently registered callback.
+func (m *RefreshManager) notifyTokenRefreshed(tokenID string, tokenData *KiroTokenData) {
+	if tokenData != nil {
+		auditTokenEvent(AuditEventBackgroundRefresh, GetAccountKey(tokenData.ClientID, tokenData.RefreshToken), nil)
 	}
 
-	log.Debug("refresh manager: token refresh callback registered")
+	m.callbackMu.RLock()
+	callback := m.onTokenRefreshed
+	m.callbackMu.RUnlock()
+
+	if callback != nil {
+		callback(tokenID, tokenData)
+	}
 }
 
 // InitializeAndStart initializes and starts background refreshing (convenience method).
@@ -196,7 +246,5 @@ func InitFingerprintConfig(cfg *config.Config) {
 
 // StopGlobalRefreshManager stops the global refresh manager.
 func StopGlobalRefreshManager() {
-	if globalRefreshManager != nil {
-		globalRefreshManager.Stop()
-	}
+	GetRefreshManager().Stop()
 }
@@ -0,0 +1,156 @@
+package kiro
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRefreshManagerConcurrentCallbackAndInitialize(t *testing.T) {
+	baseDir := t.TempDir()
+	m := &RefreshManager{}
+
+	var calls atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			m.SetOnTokenRefreshed(func(string, *KiroTokenData) {
+				calls.Add(1)
+			})
+		}()
+		go func() {
+			defer wg.Done()
+			if err := m.Initialize(baseDir, nil); err != nil {
+				t.Errorf("Initialize: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			// Simulate the refresher delivering a result while registration is in progress.
+			m.notifyTokenRefreshed("kiro-test.json", &KiroTokenData{})
+		}()
+	}
+	wg.Wait()
+
+	m.mu.Lock()
+	refresher := m.refresher
+	m.mu.Unlock()
+	if refresher == nil {
+		t.Fatal("expected refresher to be initialized")
+	}
+
+	refresher.callbackMu.RLock()
+	callback := refresher.onTokenRefreshed
+	refresher.callbackMu.RUnlock()
+	if callback == nil {
+		t.Fatal("expected refresher to forward refresh results to the manager")
+	}
+
+	before := calls.Load()
+	callback("kiro-test.json", &KiroTokenData{})
+	if calls.Load() != before+1 {
+		t.Fatal("expected refresher callback to reach the callback registered on the manager")
+	}
+}
+
+func TestRefreshManagerCallbackRegisteredAfterInitialize(t *testing.T) {
+	m := &RefreshManager{}
+	if err := m.Initialize(t.TempDir(), nil); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	var gotID string
+	m.SetOnTokenRefreshed(func(tokenID string, _ *KiroTokenData) {
+		gotID = tokenID
+	})
+
+	m.refresher.onTokenRefreshed("kiro-late.json", &KiroTokenData{})
+	if gotID != "kiro-late.json" {
+		t.Fatalf("callback received token ID %q, want kiro-late.json", gotID)
+	}
+}
+
+func TestRefreshManagerResultCallbackRegisteredAfterInitialize(t *testing.T) {
+	m := &RefreshManager{}
+	if err := m.Initialize(t.TempDir(), nil); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	var got TokenRefreshResult
+	m.SetOnTokenRefreshedResult(func(result TokenRefreshResult) {
+		got = result
+	})
+
+	m.refresher.reportRefreshResult(TokenRefreshResult{TokenID: "kiro-late.json", Duration: time.Second})
+	if got.TokenID != "kiro-late.json" || got.Duration != time.Second {
+		t.Fatalf("callback received %+v, want kiro-late.json after 1s", got)
+	}
+}
+
+func TestRefreshManagerStopDoesNotHoldLockWhileWaiting(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	ts := newSlowRefreshServer(t, started, release)
+	// Registered after the server so it runs before ts.Close on failure.
+	var releaseOnce sync.Once
+	unblock := func() { releaseOnce.Do(func() { close(release) }) }
+	t.Cleanup(unblock)
+
+	repo := &fakeTokenRepository{tokens: []*Token{{
+		ID:           "kiro-builder-id-test.json",
+		AuthMethod:   "builder-id",
+		RefreshToken: "old-refresh-token",
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+	}}}
+	m := &RefreshManager{refresher: newTestRefresher(ts, repo)}
+	m.Start()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("refresh request was not issued")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		m.StopWithTimeout(5 * time.Second)
+		close(stopped)
+	}()
+
+	running := make(chan bool, 1)
+	go func() {
+		// Give Stop time to reach the in-flight wait before probing the lock.
+		time.Sleep(50 * time.Millisecond)
+		running <- m.IsRunning()
+	}()
+
+	select {
+	case isRunning := <-running:
+		if isRunning {
+			t.Fatal("IsRunning() = true while stopping, want false")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("IsRunning blocked while Stop waited for an in-flight refresh")
+	}
+
+	select {
+	case <-stopped:
+		t.Fatal("Stop returned while a refresh was still in flight")
+	default:
+	}
+
+	unblock()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not return after the refresh completed")
+	}
+	if got := repo.updatedCount(); got != 1 {
+		t.Fatalf("UpdateToken calls = %d, want 1", got)
+	}
+}
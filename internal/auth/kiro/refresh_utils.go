@@ -7,8 +7,21 @@ import (
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
 )
 
+// sharedRefreshTimeout bounds a shared refresh once it runs detached from the
+// context of the caller that started it.
+const sharedRefreshTimeout = 2 * time.Minute
+
+// tokenRefreshGroup deduplicates concurrent refreshes of the same refresh token
+// across the background refresher, the executor and the authenticator.
+var tokenRefreshGroup singleflight.Group
+
+// sharedRefreshJoined is called with the key after a caller has joined a shared
+// refresh. Tests replace it to wait until every caller is attached.
+var sharedRefreshJoined = func(key string) {}
+
 // RefreshResult contains the result of a token refresh attempt.
 type RefreshResult struct {
 	TokenData    *KiroTokenData
@@ -157,3 +170,36 @@ func RefreshWithRetry(
 
 	return nil, fmt.Errorf("token refresh failed after %d attempts: %w", maxAttempts, lastErr)
 }
+
+// SharedRefresh runs refreshFunc once for all concurrent callers refreshing the
+// same refresh token and returns a copy of its result to each of them.
+// refreshFunc runs on a context detached from the caller's cancellation, so a
+// caller that gives up returns ctx.Err() without failing the others.
+func SharedRefresh(ctx context.Context, refreshToken string, refreshFunc func(ctx context.Context) (*KiroTokenData, error)) (*KiroTokenData, error) {
+	if refreshToken == "" {
+		return refreshFunc(ctx)
+	}
+	return doSharedRefresh(ctx, &tokenRefreshGroup, refreshToken, refreshFunc)
+}
+
+// doSharedRefresh joins the call for key in group, starting it if needed, and
+// waits for its result or for ctx to be done.
+func doSharedRefresh(ctx context.Context, group *singleflight.Group, key string, refreshFunc func(ctx context.Context) (*KiroTokenData, error)) (*KiroTokenData, error) {
+	ch := group.DoChan(key, func() (any, error) {
+		refreshCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedRefreshTimeout)
+		defer cancel()
+		return refreshFunc(refreshCtx)
+	})
+	sharedRefreshJoined(key)
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		tokenData, _ := res.Val.(*KiroTokenData)
+		return tokenData.Clone(), nil
+	}
+}
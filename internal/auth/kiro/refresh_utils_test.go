@@ -0,0 +1,73 @@
+package kiro
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// waitForSharedRefreshJoins returns a channel that is closed once n callers
+// have joined the shared refresh for key.
+func waitForSharedRefreshJoins(t *testing.T, key string, n int) <-chan struct{} {
+	t.Helper()
+	joined := make(chan struct{})
+	var count atomic.Int32
+	prev := sharedRefreshJoined
+	sharedRefreshJoined = func(k string) {
+		if k == key && count.Add(1) == int32(n) {
+			close(joined)
+		}
+	}
+	t.Cleanup(func() { sharedRefreshJoined = prev })
+	return joined
+}
+
+func TestSharedRefreshCancelledCallerDoesNotFailOthers(t *testing.T) {
+	joined := waitForSharedRefreshJoins(t, "refresh-token", 2)
+	release := make(chan struct{})
+	var calls atomic.Int32
+	refreshFunc := func(ctx context.Context) (*KiroTokenData, error) {
+		calls.Add(1)
+		<-release
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return &KiroTokenData{AccessToken: "new-access-token"}, nil
+	}
+
+	cancelCtx, cancel := context.WithCancel(context.Background())
+	cancelledDone := make(chan struct{})
+	var cancelledErr, otherErr error
+	var other *KiroTokenData
+	go func() {
+		defer close(cancelledDone)
+		_, cancelledErr = SharedRefresh(cancelCtx, "refresh-token", refreshFunc)
+	}()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		other, otherErr = SharedRefresh(context.Background(), "refresh-token", refreshFunc)
+	}()
+
+	<-joined
+	cancel()
+	<-cancelledDone
+	close(release)
+	wg.Wait()
+
+	if !errors.Is(cancelledErr, context.Canceled) {
+		t.Fatalf("cancelled caller error = %v, want context.Canceled", cancelledErr)
+	}
+	if otherErr != nil {
+		t.Fatalf("other caller error = %v, want nil", otherErr)
+	}
+	if other == nil || other.AccessToken != "new-access-token" {
+		t.Fatalf("other caller result = %+v, want new-access-token", other)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("refresh calls = %d, want 1", got)
+	}
+}
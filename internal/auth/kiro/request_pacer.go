@@ -0,0 +1,52 @@
+package kiro
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// requestPacer enforces a minimum gap between consecutive outbound requests.
+// Callers of wait are released one at a time, each at least minGap after the
+// previous one.
+type requestPacer struct {
+	mu     sync.Mutex
+	minGap time.Duration
+	last   time.Time
+
+	// now and sleep are replaced by tests to run the pacer on a fake clock.
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func newRequestPacer(minGap time.Duration) *requestPacer {
+	return &requestPacer{minGap: minGap, now: time.Now, sleep: sleepContext}
+}
+
+// wait blocks until minGap has passed since the previous caller was released,
+// or until ctx is done.
+func (p *requestPacer) wait(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.last.IsZero() {
+		if delay := p.last.Add(p.minGap).Sub(p.now()); delay > 0 {
+			if err := p.sleep(ctx, delay); err != nil {
+				return err
+			}
+		}
+	}
+	p.last = p.now()
+	return nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,95 @@
+package kiro
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manual clock whose sleeps advance time instantly.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(_ context.Context, d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	return nil
+}
+
+func TestBackgroundRefresherRequestPacing(t *testing.T) {
+	const gap = 5 * time.Second
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	var mu sync.Mutex
+	var requestTimes []time.Time
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requestTimes = append(requestTimes, clock.Now())
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(CreateTokenResponse{AccessToken: "access", RefreshToken: "refresh", ExpiresIn: 3600})
+	}))
+	t.Cleanup(ts.Close)
+
+	var tokens []*Token
+	for _, id := range []string{"a", "b", "c"} {
+		tokens = append(tokens, &Token{
+			ID:           "kiro-builder-id-" + id + ".json",
+			AuthMethod:   "builder-id",
+			RefreshToken: "old-refresh-" + id,
+			ClientID:     "client-" + id,
+			ClientSecret: "secret",
+		})
+	}
+	refresher := newTestRefresher(ts, &fakeTokenRepository{tokens: tokens})
+	WithConcurrency(1)(refresher)
+	WithRequestPacing(gap)(refresher)
+	refresher.pacer.now = clock.Now
+	refresher.pacer.sleep = clock.Sleep
+
+	refresher.refreshBatch(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requestTimes) != len(tokens) {
+		t.Fatalf("refresh requests = %d, want %d", len(requestTimes), len(tokens))
+	}
+	for i := 1; i < len(requestTimes); i++ {
+		if spacing := requestTimes[i].Sub(requestTimes[i-1]); spacing < gap {
+			t.Errorf("request %d started %v after the previous one, want at least %v", i, spacing, gap)
+		}
+	}
+}
+
+func TestRequestPacerStopsOnContextDone(t *testing.T) {
+	pacer := newRequestPacer(time.Hour)
+	if err := pacer.wait(context.Background()); err != nil {
+		t.Fatalf("first wait: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := pacer.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("wait after cancel = %v, want context.Canceled", err)
+	}
+}
+
+func TestWithRequestPacingDisabled(t *testing.T) {
+	refresher := NewBackgroundRefresher(&fakeTokenRepository{}, WithRequestPacing(time.Second), WithRequestPacing(0))
+	if refresher.pacer != nil {
+		t.Fatal("WithRequestPacing(0) did not disable pacing")
+	}
+}
@@ -0,0 +1,75 @@
+package kiro
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestSignKiroRequest(t *testing.T) {
+	fixed := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	prevNow := sigV4Now
+	sigV4Now = func() time.Time { return fixed }
+	t.Cleanup(func() { sigV4Now = prevNow })
+
+	body := []byte(`{"conversationState":{}}`)
+	req, err := http.NewRequest(http.MethodPost, "https://q.eu-central-1.amazonaws.com/generateAssistantResponse", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	creds := aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "session-token"}
+
+	if errSign := SignKiroRequest(req, creds, "eu-central-1", "codewhisperer"); errSign != nil {
+		t.Fatalf("SignKiroRequest: %v", errSign)
+	}
+
+	authz := req.Header.Get("Authorization")
+	if !strings.HasPrefix(authz, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20260304/eu-central-1/codewhisperer/aws4_request, SignedHeaders=") {
+		t.Errorf("Authorization = %q", authz)
+	}
+	if !strings.Contains(authz, "x-amz-security-token") || !strings.Contains(authz, ", Signature=") {
+		t.Errorf("Authorization does not sign the session token: %q", authz)
+	}
+	if got := req.Header.Get("X-Amz-Date"); got != "20260304T050607Z" {
+		t.Errorf("X-Amz-Date = %q, want 20260304T050607Z", got)
+	}
+	if got := req.Header.Get("X-Amz-Security-Token"); got != "session-token" {
+		t.Errorf("X-Amz-Security-Token = %q, want session-token", got)
+	}
+	if data, _ := io.ReadAll(req.Body); !bytes.Equal(data, body) {
+		t.Errorf("body after signing = %q, want %q", data, body)
+	}
+
+	again, _ := http.NewRequest(http.MethodPost, req.URL.String(), bytes.NewReader(body))
+	again.Header.Set("Content-Type", "application/json")
+	if errSign := SignKiroRequest(again, creds, "eu-central-1", "codewhisperer"); errSign != nil {
+		t.Fatalf("SignKiroRequest again: %v", errSign)
+	}
+	if again.Header.Get("Authorization") != authz {
+		t.Error("signing the same request twice produced different signatures")
+	}
+}
+
+func TestSignKiroRequestDefaults(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://codewhisperer.us-east-1.amazonaws.com/getUsageLimits", nil)
+	creds := aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"}
+	if err := SignKiroRequest(req, creds, "", ""); err != nil {
+		t.Fatalf("SignKiroRequest: %v", err)
+	}
+	if authz := req.Header.Get("Authorization"); !strings.Contains(authz, "/"+DefaultKiroRegion+"/"+DefaultSigV4Service+"/aws4_request") {
+		t.Errorf("Authorization = %q, want default region and service scope", authz)
+	}
+	if got := req.Header.Get("X-Amz-Security-Token"); got != "" {
+		t.Errorf("X-Amz-Security-Token = %q without a session token", got)
+	}
+
+	if err := SignKiroRequest(req, aws.Credentials{}, "", ""); err == nil {
+		t.Error("expected an error for missing credentials")
+	}
+}
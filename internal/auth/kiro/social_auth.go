@@ -5,7 +5,6 @@ import (
 	"bufio"
 	"context"
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -18,11 +17,11 @@ import (
 	"os/exec"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/browser"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
-	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/term"
 )
@@ -89,11 +88,8 @@ type SocialAuthClient struct {
 }
 
 // NewSocialAuthClient creates a new social auth client.
-func NewSocialAuthClient(cfg *config.Config) *SocialAuthClient {
-	client := &http.Client{Timeout: 30 * time.Second}
-	if cfg != nil {
-		client = util.SetProxy(&cfg.SDKConfig, client)
-	}
+func NewSocialAuthClient(cfg *config.Config, opts ...ClientOption) *SocialAuthClient {
+	client := newHTTPClient(cfg, 30*time.Second, opts)
 	fp := GlobalFingerprintManager().GetFingerprint("login")
 	return &SocialAuthClient{
 		httpClient:      client,
@@ -122,6 +118,17 @@ func (c *SocialAuthClient) startWebCallbackServer(ctx context.Context, expectedS
 	// Use http scheme for local callback server
 	redirectURI := fmt.Sprintf("http://localhost:%d/oauth/callback", port)
 	resultChan := make(chan WebCallbackResult, 1)
+	doneChan := make(chan struct{})
+
+	// Only the first callback is delivered; repeated callbacks must not block
+	// the handler goroutine on the buffered channel.
+	var finishOnce sync.Once
+	finish := func(result WebCallbackResult) {
+		finishOnce.Do(func() {
+			resultChan <- result
+			close(doneChan)
+		})
+	}
 
 	server := &http.Server{
 		ReadHeaderTimeout: 10 * time.Second,
@@ -139,17 +146,17 @@ func (c *SocialAuthClient) startWebCallbackServer(ctx context.Context, expectedS
 			fmt.Fprintf(w, `<!DOCTYPE html>
 <html><head><title>Login Failed</title></head>
 <body><h1>Login Failed</h1><p>%s</p><p>You can close this window.</p></body></html>`, html.EscapeString(errParam))
-			resultChan <- WebCallbackResult{Error: errParam}
+			finish(WebCallbackResult{Error: errParam})
 			return
 		}
 
-		if state != expectedState {
+		if !secureEqual(state, expectedState) {
 			w.Header().Set("Content-Type", "text/html; charset=utf-8")
 			w.WriteHeader(http.StatusBadRequest)
 			fmt.Fprint(w, `<!DOCTYPE html>
 <html><head><title>Login Failed</title></head>
 <body><h1>Login Failed</h1><p>Invalid state parameter</p><p>You can close this window.</p></body></html>`)
-			resultChan <- WebCallbackResult{Error: "state mismatch"}
+			finish(WebCallbackResult{Error: "state mismatch"})
 			return
 		}
 
@@ -158,7 +165,7 @@ func (c *SocialAuthClient) startWebCallbackServer(ctx context.Context, expectedS
 <html><head><title>Login Successful</title></head>
 <body><h1>Login Successful!</h1><p>You can close this window and return to the terminal.</p>
 <script>window.close();</script></body></html>`)
-		resultChan <- WebCallbackResult{Code: code, State: state}
+		finish(WebCallbackResult{Code: code, State: state})
 	})
 
 	server.Handler = mux
@@ -173,7 +180,7 @@ func (c *SocialAuthClient) startWebCallbackServer(ctx context.Context, expectedS
 		select {
 		case <-ctx.Done():
 		case <-time.After(socialAuthTimeout):
-		case <-resultChan:
+		case <-doneChan:
 		}
 		_ = server.Shutdown(context.Background())
 	}()
@@ -181,22 +188,6 @@ func (c *SocialAuthClient) startWebCallbackServer(ctx context.Context, expectedS
 	return redirectURI, resultChan, nil
 }
 
-// generatePKCE generates PKCE code verifier and challenge.
-func generatePKCE() (verifier, challenge string, err error) {
-	// Generate 32 bytes of random data for verifier
-	b := make([]byte, 32)
-	if _, err := rand.Read(b); err != nil {
-		return "", "", fmt.Errorf("failed to generate random bytes: %w", err)
-	}
-	verifier = base64.RawURLEncoding.EncodeToString(b)
-
-	// Generate SHA256 hash of verifier for challenge
-	h := sha256.Sum256([]byte(verifier))
-	challenge = base64.RawURLEncoding.EncodeToString(h[:])
-
-	return verifier, challenge, nil
-}
-
 // generateState generates a random state parameter.
 func generateStateParam() (string, error) {
 	b := make([]byte, 16)
@@ -331,7 +322,7 @@ func (c *SocialAuthClient) LoginWithSocial(ctx context.Context, provider SocialP
 	fmt.Println("\nSetting up authentication...")
 
 	// Step 2: Generate PKCE codes
-	codeVerifier, codeChallenge, err := generatePKCE()
+	codeVerifier, codeChallenge, err := GeneratePKCE()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate PKCE: %w", err)
 	}
@@ -3,9 +3,11 @@ package kiro
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -17,12 +19,13 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/browser"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
-	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
 )
 
 const (
@@ -41,24 +44,55 @@ const (
 	// Authorization code flow callback
 	authCodeCallbackPath = "/oauth/callback"
 	authCodeCallbackPort = 19877
+
+	// Client identity sent on registration. The client name must stay aligned with
+	// the "KiroIDE" token in the User-Agent built by SetOIDCHeaders.
+	defaultRegisterClientName = "Kiro IDE"
+	defaultRegisterClientType = "public"
 )
 
 var (
 	ErrAuthorizationPending = errors.New("authorization_pending")
 	ErrSlowDown             = errors.New("slow_down")
+	// ErrAccessDenied is returned when the user denies the device authorization request.
+	ErrAccessDenied = errors.New("access_denied")
+	// ErrExpiredToken is returned when the device code expires before the user authorizes it.
+	ErrExpiredToken = errors.New("expired_token")
+	// ErrStateMismatch is returned when the OAuth callback state does not match the
+	// state sent in the authorization request, indicating a possible CSRF attempt.
+	ErrStateMismatch = errors.New("oauth state mismatch")
+	// ErrClientNotRegistered is returned when the IDC instance no longer knows the
+	// OIDC client, e.g. because its registration expired or was revoked.
+	ErrClientNotRegistered = errors.New("oidc client not registered")
+	// ErrSecondaryLoginRequired is returned when a refresh has to fail over to the
+	// secondary IDC instance but no login there has been stored.
+	ErrSecondaryLoginRequired = errors.New("login with the secondary IDC start URL required")
 )
 
+// OIDCClient is the part of SSOOIDCClient that registers OIDC clients, exchanges
+// device codes, refreshes Builder ID and IDC tokens and looks up the profile ARN.
+// The background refresher, the web OAuth handler, the Kiro executor and the
+// Kiro authenticator depend on it; kirotest.MockSSOOIDCClient implements it for
+// tests.
+type OIDCClient interface {
+	RegisterClientWithRegion(ctx context.Context, region string) (*RegisterClientResponse, error)
+	CreateTokenWithRegion(ctx context.Context, clientID, clientSecret, deviceCode, region string) (*CreateTokenResponse, error)
+	RefreshToken(ctx context.Context, clientID, clientSecret, refreshToken string) (*KiroTokenData, error)
+	RefreshTokenWithRegion(ctx context.Context, clientID, clientSecret, refreshToken, region, startURL string) (*KiroTokenData, error)
+	RefreshIDCToken(ctx context.Context, token *KiroTokenData) (*KiroTokenData, error)
+	FetchProfileArn(ctx context.Context, accessToken, clientID, refreshToken string) string
+}
+
+var _ OIDCClient = (*SSOOIDCClient)(nil)
+
 type SSOOIDCClient struct {
 	httpClient *http.Client
 	cfg        *config.Config
 }
 
 // NewSSOOIDCClient creates a new SSO OIDC client.
-func NewSSOOIDCClient(cfg *config.Config) *SSOOIDCClient {
-	client := &http.Client{Timeout: 30 * time.Second}
-	if cfg != nil {
-		client = util.SetProxy(&cfg.SDKConfig, client)
-	}
+func NewSSOOIDCClient(cfg *config.Config, opts ...ClientOption) *SSOOIDCClient {
+	client := newHTTPClient(cfg, 30*time.Second, opts)
 	return &SSOOIDCClient{
 		httpClient: client,
 		cfg:        cfg,
@@ -99,6 +133,51 @@ func getOIDCEndpoint(region string) string {
 	return fmt.Sprintf("https://oidc.%s.amazonaws.com", region)
 }
 
+// govCloudStartHost is the shared start URL host for AWS GovCloud (US) portals.
+const govCloudStartHost = "start.us-gov-home.awsapps.com"
+
+// OIDCRegionForStartURL returns the OIDC region client registration should use
+// for an IDC start URL. An explicit region always wins. Otherwise the region is
+// taken from start URLs that carry one ({instance}.portal.{region}.app.aws or
+// the GovCloud portal), and classic {identifier}.awsapps.com URLs fall back to
+// defaultIDCRegion since their host does not encode it.
+func OIDCRegionForStartURL(startURL, explicitRegion string) string {
+	if region := strings.TrimSpace(explicitRegion); region != "" {
+		return region
+	}
+	parsed, errParse := url.Parse(strings.TrimSpace(startURL))
+	if errParse != nil {
+		return defaultIDCRegion
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if host == govCloudStartHost {
+		return "us-gov-west-1"
+	}
+	labels := strings.Split(host, ".")
+	for i := 0; i+3 < len(labels); i++ {
+		if labels[i] == "portal" && labels[i+2] == "app" && labels[i+3] == "aws" && isRegionName(labels[i+1]) {
+			return labels[i+1]
+		}
+	}
+	return defaultIDCRegion
+}
+
+// isRegionName reports whether s looks like an AWS region name such as
+// "eu-west-1" or "us-gov-west-1".
+func isRegionName(s string) bool {
+	parts := strings.Split(s, "-")
+	if len(parts) < 3 {
+		return false
+	}
+	for _, part := range parts[:len(parts)-1] {
+		if part == "" || strings.Trim(part, "abcdefghijklmnopqrstuvwxyz") != "" {
+			return false
+		}
+	}
+	last := parts[len(parts)-1]
+	return last != "" && strings.Trim(last, "0123456789") == ""
+}
+
 // promptInput prompts the user for input with an optional default value.
 func promptInput(prompt, defaultValue string) string {
 	reader := bufio.NewReader(os.Stdin)
@@ -152,8 +231,8 @@ func (c *SSOOIDCClient) RegisterClientWithRegion(ctx context.Context, region str
 	endpoint := getOIDCEndpoint(region)
 
 	payload := map[string]interface{}{
-		"clientName": "Kiro IDE",
-		"clientType": "public",
+		"clientName": defaultRegisterClientName,
+		"clientType": defaultRegisterClientType,
 		"scopes":     []string{"codewhisperer:completions", "codewhisperer:analysis", "codewhisperer:conversations", "codewhisperer:transformations", "codewhisperer:taskassist"},
 		"grantTypes": []string{"urn:ietf:params:oauth:grant-type:device_code", "refresh_token"},
 	}
@@ -167,9 +246,7 @@ func (c *SSOOIDCClient) RegisterClientWithRegion(ctx context.Context, region str
 	if err != nil {
 		return nil, err
 	}
-	SetOIDCHeaders(req)
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := wrapHTTPClient(c.httpClient).DoOIDC(req)
 	if err != nil {
 		return nil, err
 	}
@@ -212,9 +289,7 @@ func (c *SSOOIDCClient) StartDeviceAuthorizationWithIDC(ctx context.Context, cli
 	if err != nil {
 		return nil, err
 	}
-	SetOIDCHeaders(req)
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := wrapHTTPClient(c.httpClient).DoOIDC(req)
 	if err != nil {
 		return nil, err
 	}
@@ -239,7 +314,9 @@ func (c *SSOOIDCClient) StartDeviceAuthorizationWithIDC(ctx context.Context, cli
 }
 
 // CreateTokenWithRegion polls for the access token after user authorization using a specific region.
-func (c *SSOOIDCClient) CreateTokenWithRegion(ctx context.Context, clientID, clientSecret, deviceCode, region string) (*CreateTokenResponse, error) {
+func (c *SSOOIDCClient) CreateTokenWithRegion(ctx context.Context, clientID, clientSecret, deviceCode, region string) (_ *CreateTokenResponse, err error) {
+	defer func() { auditDeviceTokenResult(clientID, err) }()
+
 	endpoint := getOIDCEndpoint(region)
 
 	payload := map[string]string{
@@ -258,9 +335,7 @@ func (c *SSOOIDCClient) CreateTokenWithRegion(ctx context.Context, clientID, cli
 	if err != nil {
 		return nil, err
 	}
-	SetOIDCHeaders(req)
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := wrapHTTPClient(c.httpClient).DoOIDC(req)
 	if err != nil {
 		return nil, err
 	}
@@ -283,6 +358,12 @@ func (c *SSOOIDCClient) CreateTokenWithRegion(ctx context.Context, clientID, cli
 			if errResp.Error == "slow_down" {
 				return nil, ErrSlowDown
 			}
+			if errResp.Error == "access_denied" {
+				return nil, ErrAccessDenied
+			}
+			if errResp.Error == "expired_token" {
+				return nil, ErrExpiredToken
+			}
 		}
 		log.Debugf("create token failed: %s", string(respBody))
 		return nil, fmt.Errorf("create token failed")
@@ -302,10 +383,11 @@ func (c *SSOOIDCClient) CreateTokenWithRegion(ctx context.Context, clientID, cli
 }
 
 // RefreshTokenWithRegion refreshes an access token using the refresh token with a specific OIDC region.
-func (c *SSOOIDCClient) RefreshTokenWithRegion(ctx context.Context, clientID, clientSecret, refreshToken, region, startURL string) (*KiroTokenData, error) {
-	if region == "" {
-		region = defaultIDCRegion
-	}
+func (c *SSOOIDCClient) RefreshTokenWithRegion(ctx context.Context, clientID, clientSecret, refreshToken, region, startURL string) (_ *KiroTokenData, err error) {
+	defer func() { auditTokenEvent(AuditEventTokenRefresh, GetAccountKey(clientID, refreshToken), err) }()
+
+	// The client was registered in the start URL's region, so refresh there too.
+	region = OIDCRegionForStartURL(startURL, region)
 	endpoint := getOIDCEndpoint(region)
 
 	payload := map[string]string{
@@ -324,9 +406,7 @@ func (c *SSOOIDCClient) RefreshTokenWithRegion(ctx context.Context, clientID, cl
 	if err != nil {
 		return nil, err
 	}
-	SetOIDCHeaders(req)
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := wrapHTTPClient(c.httpClient).DoOIDC(req)
 	if err != nil {
 		return nil, err
 	}
@@ -339,6 +419,9 @@ func (c *SSOOIDCClient) RefreshTokenWithRegion(ctx context.Context, clientID, cl
 
 	if resp.StatusCode != http.StatusOK {
 		log.Warnf("IDC token refresh failed (status %d): %s", resp.StatusCode, string(respBody))
+		if isClientNotRegistered(resp.StatusCode, respBody) {
+			return nil, fmt.Errorf("token refresh failed (status %d): %w", resp.StatusCode, ErrClientNotRegistered)
+		}
 		return nil, fmt.Errorf("token refresh failed (status %d)", resp.StatusCode)
 	}
 
@@ -362,6 +445,92 @@ func (c *SSOOIDCClient) RefreshTokenWithRegion(ctx context.Context, clientID, cl
 	}, nil
 }
 
+// isClientNotRegistered reports whether an OIDC error response says the client
+// registration is unknown to the IDC instance.
+func isClientNotRegistered(status int, body []byte) bool {
+	if status != http.StatusBadRequest && status != http.StatusUnauthorized {
+		return false
+	}
+	code := gjson.GetBytes(body, "error").String()
+	if code == "" {
+		code = gjson.GetBytes(body, "__type").String()
+	}
+	switch strings.ToLower(code) {
+	case "invalid_client", "invalidclientexception", "unauthorized_client", "unauthorizedclientexception":
+		return true
+	}
+	return false
+}
+
+// RefreshIDCToken refreshes an IDC token against its primary start URL and
+// region. When the primary instance reports that the client is no longer
+// registered and a secondary start URL is configured, the refresh is retried
+// against the secondary instance (SecondaryRegion, or the region derived from
+// SecondaryStartURL) with the client and refresh token from a login there; see
+// LoginSecondaryIDC. Without them it returns ErrSecondaryLoginRequired. After a
+// failover the secondary instance becomes the primary of the returned token and
+// the old primary is kept as its secondary.
+func (c *SSOOIDCClient) RefreshIDCToken(ctx context.Context, token *KiroTokenData) (*KiroTokenData, error) {
+	if token == nil {
+		return nil, errors.New("refresh idc token: token is nil")
+	}
+	refreshed, errPrimary := c.RefreshTokenWithRegion(ctx, token.ClientID, token.ClientSecret, token.RefreshToken, token.Region, token.StartURL)
+	if errPrimary == nil {
+		copySecondaryIDC(refreshed, token)
+		return refreshed, nil
+	}
+	if token.SecondaryStartURL == "" || !errors.Is(errPrimary, ErrClientNotRegistered) {
+		return nil, errPrimary
+	}
+	if token.SecondaryClientID == "" || token.SecondaryClientSecret == "" || token.SecondaryRefreshToken == "" {
+		return nil, fmt.Errorf("refresh idc token: %w with %s (primary: %v)", ErrSecondaryLoginRequired, token.SecondaryStartURL, errPrimary)
+	}
+
+	secondaryRegion := OIDCRegionForStartURL(token.SecondaryStartURL, token.SecondaryRegion)
+	log.Warnf("IDC client not registered with %s, falling back to %s", token.StartURL, token.SecondaryStartURL)
+
+	refreshed, errSecondary := c.RefreshTokenWithRegion(ctx, token.SecondaryClientID, token.SecondaryClientSecret, token.SecondaryRefreshToken, secondaryRegion, token.SecondaryStartURL)
+	if errSecondary != nil {
+		if errors.Is(errSecondary, ErrClientNotRegistered) {
+			errSecondary = fmt.Errorf("%w: %w", ErrSecondaryLoginRequired, errSecondary)
+		}
+		return nil, fmt.Errorf("refresh with secondary start URL failed: %w (primary: %v)", errSecondary, errPrimary)
+	}
+	refreshed.SecondaryStartURL = token.StartURL
+	refreshed.SecondaryRegion = token.Region
+	refreshed.SecondaryClientID = token.ClientID
+	refreshed.SecondaryClientSecret = token.ClientSecret
+	refreshed.SecondaryRefreshToken = token.RefreshToken
+	return refreshed, nil
+}
+
+// LoginSecondaryIDC runs LoginIDC against token's secondary start URL and stores
+// the resulting client and refresh token in token's Secondary fields, so that
+// RefreshIDCToken can fail over to that instance.
+func (c *SSOOIDCClient) LoginSecondaryIDC(ctx context.Context, token *KiroTokenData, openBrowser func(url string) error) error {
+	if token == nil || token.SecondaryStartURL == "" {
+		return errors.New("login secondary idc: no secondary start URL")
+	}
+	secondary, err := c.LoginIDC(ctx, token.SecondaryStartURL, token.SecondaryRegion, openBrowser)
+	if err != nil {
+		return err
+	}
+	token.SecondaryRegion = secondary.Region
+	token.SecondaryClientID = secondary.ClientID
+	token.SecondaryClientSecret = secondary.ClientSecret
+	token.SecondaryRefreshToken = secondary.RefreshToken
+	return nil
+}
+
+// copySecondaryIDC carries the secondary IDC instance settings of src over to dst.
+func copySecondaryIDC(dst, src *KiroTokenData) {
+	dst.SecondaryStartURL = src.SecondaryStartURL
+	dst.SecondaryRegion = src.SecondaryRegion
+	dst.SecondaryClientID = src.SecondaryClientID
+	dst.SecondaryClientSecret = src.SecondaryClientSecret
+	dst.SecondaryRefreshToken = src.SecondaryRefreshToken
+}
+
 // LoginWithIDC performs the full device code flow for AWS Identity Center (IDC).
 func (c *SSOOIDCClient) LoginWithIDC(ctx context.Context, startURL, region string) (*KiroTokenData, error) {
 	fmt.Println("\n╔══════════════════════════════════════════════════════════╗")
@@ -426,6 +595,7 @@ func (c *SSOOIDCClient) LoginWithIDC(ctx context.Context, startURL, region strin
 		select {
 		case <-ctx.Done():
 			browser.CloseBrowser()
+			AuditDevicePollAbandoned(regResp.ClientID, ctx.Err())
 			return nil, ctx.Err()
 		case <-time.After(interval):
 			tokenResp, err := c.CreateTokenWithRegion(ctx, regResp.ClientID, regResp.ClientSecret, authResp.DeviceCode, region)
@@ -439,6 +609,7 @@ func (c *SSOOIDCClient) LoginWithIDC(ctx context.Context, startURL, region strin
 					continue
 				}
 				browser.CloseBrowser()
+				AuditDevicePollAbandoned(regResp.ClientID, err)
 				return nil, fmt.Errorf("token creation failed: %w", err)
 			}
 
@@ -481,6 +652,7 @@ func (c *SSOOIDCClient) LoginWithIDC(ctx context.Context, startURL, region strin
 	if err := browser.CloseBrowser(); err != nil {
 		log.Debugf("Failed to close browser on timeout: %v", err)
 	}
+	AuditDevicePollAbandoned(regResp.ClientID, nil)
 	return nil, fmt.Errorf("authorization timed out")
 }
 
@@ -501,10 +673,7 @@ func (c *SSOOIDCClient) LoginWithMethodSelection(ctx context.Context, opts *IDCL
 
 	// If IDC options with StartURL are provided, skip method selection and use IDC directly
 	if opts != nil && opts.StartURL != "" {
-		region := opts.Region
-		if region == "" {
-			region = defaultIDCRegion
-		}
+		region := OIDCRegionForStartURL(opts.StartURL, opts.Region)
 		fmt.Printf("\n  Using IDC with Start URL: %s\n", opts.StartURL)
 		fmt.Printf("  Region: %s\n", region)
 
@@ -548,7 +717,7 @@ func (c *SSOOIDCClient) LoginWithMethodSelection(ctx context.Context, opts *IDCL
 
 	// Use pre-configured region or prompt
 	if region == "" {
-		region = promptInput("? Enter Region", defaultIDCRegion)
+		region = promptInput("? Enter Region", OIDCRegionForStartURL(startURL, ""))
 	} else {
 		fmt.Printf("  Using pre-configured Region: %s\n", region)
 	}
@@ -567,8 +736,8 @@ func (c *SSOOIDCClient) LoginWithIDCAndOptions(ctx context.Context, startURL, re
 // RegisterClient registers a new OIDC client with AWS.
 func (c *SSOOIDCClient) RegisterClient(ctx context.Context) (*RegisterClientResponse, error) {
 	payload := map[string]interface{}{
-		"clientName": "Kiro IDE",
-		"clientType": "public",
+		"clientName": defaultRegisterClientName,
+		"clientType": defaultRegisterClientType,
 		"scopes":     []string{"codewhisperer:completions", "codewhisperer:analysis", "codewhisperer:conversations", "codewhisperer:transformations", "codewhisperer:taskassist"},
 		"grantTypes": []string{"urn:ietf:params:oauth:grant-type:device_code", "refresh_token"},
 	}
@@ -582,9 +751,7 @@ func (c *SSOOIDCClient) RegisterClient(ctx context.Context) (*RegisterClientResp
 	if err != nil {
 		return nil, err
 	}
-	SetOIDCHeaders(req)
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := wrapHTTPClient(c.httpClient).DoOIDC(req)
 	if err != nil {
 		return nil, err
 	}
@@ -608,51 +775,93 @@ func (c *SSOOIDCClient) RegisterClient(ctx context.Context) (*RegisterClientResp
 	return &result, nil
 }
 
-// StartDeviceAuthorization starts the device authorization flow.
+// StartDeviceAuthorization starts the Builder ID device authorization flow.
 func (c *SSOOIDCClient) StartDeviceAuthorization(ctx context.Context, clientID, clientSecret string) (*StartDeviceAuthResponse, error) {
-	payload := map[string]string{
-		"clientId":     clientID,
-		"clientSecret": clientSecret,
-		"startUrl":     builderIDStartURL,
-	}
+	return c.StartDeviceAuthorizationWithRegion(ctx, clientID, clientSecret, defaultIDCRegion)
+}
 
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return nil, err
-	}
+// DeviceAuthorizationResponse is the device_authorization result used by headless
+// and CLI login flows: the user enters UserCode at VerificationURI while the caller
+// polls with DeviceCode every Interval seconds until ExpiresIn elapses.
+type DeviceAuthorizationResponse = StartDeviceAuthResponse
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ssoOIDCEndpoint+"/device_authorization", strings.NewReader(string(body)))
-	if err != nil {
-		return nil, err
-	}
-	SetOIDCHeaders(req)
+// StartDeviceAuthorizationWithRegion starts the Builder ID device authorization flow
+// against the OIDC device_authorization endpoint of region (us-east-1 when empty).
+func (c *SSOOIDCClient) StartDeviceAuthorizationWithRegion(ctx context.Context, clientID, clientSecret, region string) (*DeviceAuthorizationResponse, error) {
+	return c.StartDeviceAuthorizationWithIDC(ctx, clientID, clientSecret, builderIDStartURL, region)
+}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
+// slowDownIncrement is added to the polling interval each time the token
+// endpoint answers slow_down.
+var slowDownIncrement = 5 * time.Second
+
+// PollDeviceToken polls the token endpoint in region until the device code is
+// authorized. authorization_pending keeps polling at the current interval and
+// slow_down increases it by 5 seconds. access_denied and expired_token end the
+// poll with ErrAccessDenied and ErrExpiredToken, and so does the device code's
+// expiresIn elapsing (from its device_authorization response); a non-positive
+// expiresIn polls until ctx is done. A non-positive interval uses the default
+// polling interval. authMethod ("builder-id" or "idc") is recorded on the
+// returned token; empty means "builder-id".
+func (c *SSOOIDCClient) PollDeviceToken(ctx context.Context, clientID, clientSecret, deviceCode, region, authMethod string, interval, expiresIn time.Duration) (*KiroTokenData, error) {
+	if interval <= 0 {
+		interval = pollInterval
 	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	if region == "" {
+		region = defaultIDCRegion
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		log.Debugf("start device auth failed (status %d): %s", resp.StatusCode, string(respBody))
-		return nil, fmt.Errorf("start device auth failed (status %d)", resp.StatusCode)
+	if authMethod == "" {
+		authMethod = "builder-id"
 	}
-
-	var result StartDeviceAuthResponse
-	if err := json.Unmarshal(respBody, &result); err != nil {
-		return nil, err
+	var expired <-chan time.Time
+	if expiresIn > 0 {
+		expiry := time.NewTimer(expiresIn)
+		defer expiry.Stop()
+		expired = expiry.C
 	}
 
-	return &result, nil
+	for {
+		select {
+		case <-ctx.Done():
+			AuditDevicePollAbandoned(clientID, ctx.Err())
+			return nil, ctx.Err()
+		case <-expired:
+			AuditDevicePollAbandoned(clientID, nil)
+			return nil, fmt.Errorf("poll device token: device code expired: %w", ErrExpiredToken)
+		case <-time.After(interval):
+		}
+
+		tokenResp, err := c.CreateTokenWithRegion(ctx, clientID, clientSecret, deviceCode, region)
+		if err != nil {
+			if errors.Is(err, ErrAuthorizationPending) {
+				continue
+			}
+			if errors.Is(err, ErrSlowDown) {
+				interval += slowDownIncrement
+				continue
+			}
+			AuditDevicePollAbandoned(clientID, err)
+			return nil, fmt.Errorf("poll device token: %w", err)
+		}
+
+		expiresAt := time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+		return &KiroTokenData{
+			AccessToken:  tokenResp.AccessToken,
+			RefreshToken: tokenResp.RefreshToken,
+			ExpiresAt:    expiresAt.Format(time.RFC3339),
+			AuthMethod:   authMethod,
+			Provider:     "AWS",
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Region:       region,
+		}, nil
+	}
 }
 
 // CreateToken polls for the access token after user authorization.
-func (c *SSOOIDCClient) CreateToken(ctx context.Context, clientID, clientSecret, deviceCode string) (*CreateTokenResponse, error) {
+func (c *SSOOIDCClient) CreateToken(ctx context.Context, clientID, clientSecret, deviceCode string) (_ *CreateTokenResponse, err error) {
+	defer func() { auditDeviceTokenResult(clientID, err) }()
+
 	payload := map[string]string{
 		"clientId":     clientID,
 		"clientSecret": clientSecret,
@@ -669,9 +878,7 @@ func (c *SSOOIDCClient) CreateToken(ctx context.Context, clientID, clientSecret,
 	if err != nil {
 		return nil, err
 	}
-	SetOIDCHeaders(req)
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := wrapHTTPClient(c.httpClient).DoOIDC(req)
 	if err != nil {
 		return nil, err
 	}
@@ -694,6 +901,12 @@ func (c *SSOOIDCClient) CreateToken(ctx context.Context, clientID, clientSecret,
 			if errResp.Error == "slow_down" {
 				return nil, ErrSlowDown
 			}
+			if errResp.Error == "access_denied" {
+				return nil, ErrAccessDenied
+			}
+			if errResp.Error == "expired_token" {
+				return nil, ErrExpiredToken
+			}
 		}
 		log.Debugf("create token failed: %s", string(respBody))
 		return nil, fmt.Errorf("create token failed")
@@ -714,7 +927,9 @@ func (c *SSOOIDCClient) CreateToken(ctx context.Context, clientID, clientSecret,
 
 // RefreshToken refreshes an access token using the refresh token.
 // Includes retry logic and improved error handling for better reliability.
-func (c *SSOOIDCClient) RefreshToken(ctx context.Context, clientID, clientSecret, refreshToken string) (*KiroTokenData, error) {
+func (c *SSOOIDCClient) RefreshToken(ctx context.Context, clientID, clientSecret, refreshToken string) (_ *KiroTokenData, err error) {
+	defer func() { auditTokenEvent(AuditEventTokenRefresh, GetAccountKey(clientID, refreshToken), err) }()
+
 	payload := map[string]string{
 		"clientId":     clientID,
 		"clientSecret": clientSecret,
@@ -731,9 +946,7 @@ func (c *SSOOIDCClient) RefreshToken(ctx context.Context, clientID, clientSecret
 	if err != nil {
 		return nil, err
 	}
-	SetOIDCHeaders(req)
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := wrapHTTPClient(c.httpClient).DoOIDC(req)
 	if err != nil {
 		return nil, err
 	}
@@ -834,6 +1047,7 @@ func (c *SSOOIDCClient) LoginWithBuilderID(ctx context.Context) (*KiroTokenData,
 		select {
 		case <-ctx.Done():
 			browser.CloseBrowser() // Cleanup on cancel
+			AuditDevicePollAbandoned(regResp.ClientID, ctx.Err())
 			return nil, ctx.Err()
 		case <-time.After(interval):
 			tokenResp, err := c.CreateToken(ctx, regResp.ClientID, regResp.ClientSecret, authResp.DeviceCode)
@@ -848,6 +1062,7 @@ func (c *SSOOIDCClient) LoginWithBuilderID(ctx context.Context) (*KiroTokenData,
 				}
 				// Close browser on error before returning
 				browser.CloseBrowser()
+				AuditDevicePollAbandoned(regResp.ClientID, err)
 				return nil, fmt.Errorf("token creation failed: %w", err)
 			}
 
@@ -885,6 +1100,7 @@ func (c *SSOOIDCClient) LoginWithBuilderID(ctx context.Context) (*KiroTokenData,
 	if err := browser.CloseBrowser(); err != nil {
 		log.Debugf("Failed to close browser on timeout: %v", err)
 	}
+	AuditDevicePollAbandoned(regResp.ClientID, nil)
 	return nil, fmt.Errorf("authorization timed out")
 }
 
@@ -960,20 +1176,66 @@ func (c *SSOOIDCClient) FetchProfileArn(ctx context.Context, accessToken, client
 	return c.tryListProfilesLegacy(ctx, accessToken)
 }
 
+// maxProfileListPages caps how many ListAvailableProfiles pages are requested,
+// so a backend that keeps returning a cursor cannot loop forever.
+const maxProfileListPages = 10
+
 func (c *SSOOIDCClient) tryListAvailableProfiles(ctx context.Context, accessToken, clientID, refreshToken string) string {
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, GetKiroAPIEndpoint("")+"/ListAvailableProfiles", strings.NewReader("{}"))
+	accountKey := GetAccountKey(clientID, refreshToken)
+	seen := make(map[string]struct{})
+	nextToken := ""
+	for page := 0; page < maxProfileListPages; page++ {
+		respBody, ok := c.listAvailableProfilesPage(ctx, accessToken, accountKey, nextToken)
+		if !ok {
+			return ""
+		}
+
+		var result struct {
+			Profiles []struct {
+				Arn         string `json:"arn"`
+				ProfileName string `json:"profileName"`
+			} `json:"profiles"`
+		}
+		if err := json.Unmarshal(respBody, &result); err != nil {
+			log.Debugf("ListAvailableProfiles parse error: %v", err)
+			return ""
+		}
+		if len(result.Profiles) > 0 {
+			log.Debugf("Found profile: %s (%s)", result.Profiles[0].ProfileName, result.Profiles[0].Arn)
+			return result.Profiles[0].Arn
+		}
+
+		nextToken = parseNextToken(respBody)
+		if nextToken == "" {
+			return ""
+		}
+		if _, repeated := seen[nextToken]; repeated {
+			log.Debugf("ListAvailableProfiles returned a repeated nextToken, stopping pagination")
+			return ""
+		}
+		seen[nextToken] = struct{}{}
+	}
+	log.Debugf("ListAvailableProfiles stopped after %d pages", maxProfileListPages)
+	return ""
+}
+
+// listAvailableProfilesPage requests one ListAvailableProfiles page and returns
+// the response body, or false when the request fails.
+func (c *SSOOIDCClient) listAvailableProfilesPage(ctx context.Context, accessToken, accountKey, nextToken string) ([]byte, bool) {
+	reqBody := []byte("{}")
+	if nextToken != "" {
+		reqBody, _ = json.Marshal(map[string]string{"nextToken": nextToken})
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, GetKiroAPIEndpoint("")+"/ListAvailableProfiles", bytes.NewReader(reqBody))
 	if err != nil {
-		return ""
+		return nil, false
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	accountKey := GetAccountKey(clientID, refreshToken)
-	setRuntimeHeaders(req, accessToken, accountKey)
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := wrapHTTPClient(c.httpClient).DoRuntime(req, accessToken, accountKey)
 	if err != nil {
 		log.Debugf("ListAvailableProfiles request failed: %v", err)
-		return ""
+		return nil, false
 	}
 	defer resp.Body.Close()
 
@@ -981,29 +1243,27 @@ func (c *SSOOIDCClient) tryListAvailableProfiles(ctx context.Context, accessToke
 
 	if resp.StatusCode != http.StatusOK {
 		log.Debugf("ListAvailableProfiles failed (status %d): %s", resp.StatusCode, string(respBody))
-		return ""
+		return nil, false
 	}
 
 	log.Debugf("ListAvailableProfiles response: %s", string(respBody))
+	return respBody, true
+}
 
-	var result struct {
-		Profiles []struct {
-			Arn         string `json:"arn"`
-			ProfileName string `json:"profileName"`
-		} `json:"profiles"`
-		NextToken *string `json:"nextToken"`
-	}
-
-	if err := json.Unmarshal(respBody, &result); err != nil {
-		log.Debugf("ListAvailableProfiles parse error: %v", err)
-		return ""
-	}
-
-	if len(result.Profiles) > 0 {
-		log.Debugf("Found profile: %s (%s)", result.Profiles[0].ProfileName, result.Profiles[0].Arn)
-		return result.Profiles[0].Arn
+// nextTokenPaths lists where AWS list responses carry their pagination cursor:
+// the documented camelCase key, the capitalised variant some services return,
+// and the same keys nested under a pagination object.
+var nextTokenPaths = []string{"nextToken", "NextToken", "pagination.nextToken", "Pagination.NextToken"}
+
+// parseNextToken returns the pagination cursor in a list response body. A
+// missing key, JSON null and an empty string all mean there are no more pages.
+func parseNextToken(body []byte) string {
+	for _, path := range nextTokenPaths {
+		value := gjson.GetBytes(body, path)
+		if value.Type == gjson.String && strings.TrimSpace(value.Str) != "" {
+			return value.Str
+		}
 	}
-
 	return ""
 }
 
@@ -1069,8 +1329,8 @@ func (c *SSOOIDCClient) tryListProfilesLegacy(ctx context.Context, accessToken s
 // RegisterClientForAuthCode registers a new OIDC client for authorization code flow.
 func (c *SSOOIDCClient) RegisterClientForAuthCode(ctx context.Context, redirectURI string) (*RegisterClientResponse, error) {
 	payload := map[string]interface{}{
-		"clientName":   "Kiro IDE",
-		"clientType":   "public",
+		"clientName":   defaultRegisterClientName,
+		"clientType":   defaultRegisterClientType,
 		"scopes":       []string{"codewhisperer:completions", "codewhisperer:analysis", "codewhisperer:conversations", "codewhisperer:transformations", "codewhisperer:taskassist"},
 		"grantTypes":   []string{"authorization_code", "refresh_token"},
 		"redirectUris": []string{redirectURI},
@@ -1086,9 +1346,7 @@ func (c *SSOOIDCClient) RegisterClientForAuthCode(ctx context.Context, redirectU
 	if err != nil {
 		return nil, err
 	}
-	SetOIDCHeaders(req)
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := wrapHTTPClient(c.httpClient).DoOIDC(req)
 	if err != nil {
 		return nil, err
 	}
@@ -1112,12 +1370,46 @@ func (c *SSOOIDCClient) RegisterClientForAuthCode(ctx context.Context, redirectU
 	return &result, nil
 }
 
+// RegisterOptions overrides the client identity presented during client registration.
+// Empty fields fall back to the Kiro IDE defaults ("Kiro IDE", "public").
+//
+// ClientName is checked against the User-Agent sent by SetOIDCHeaders, which
+// always carries the "KiroIDE" token; a name that does not match that identity
+// may cause registration or later token verification to be rejected.
+type RegisterOptions struct {
+	ClientName string
+	ClientType string
+}
+
+// clientName returns the configured client name or the Kiro IDE default.
+func (o *RegisterOptions) clientName() string {
+	if o == nil || o.ClientName == "" {
+		return defaultRegisterClientName
+	}
+	return o.ClientName
+}
+
+// clientType returns the configured client type or the Kiro IDE default.
+func (o *RegisterOptions) clientType() string {
+	if o == nil || o.ClientType == "" {
+		return defaultRegisterClientType
+	}
+	return o.ClientType
+}
+
+// RegisterClientForAuthCodeWithIDC registers an IDC client for auth code flow using the default Kiro IDE identity.
 func (c *SSOOIDCClient) RegisterClientForAuthCodeWithIDC(ctx context.Context, redirectURI, issuerUrl, region string) (*RegisterClientResponse, error) {
+	return c.RegisterClientForAuthCodeWithIDCAndOptions(ctx, redirectURI, issuerUrl, region, nil)
+}
+
+// RegisterClientForAuthCodeWithIDCAndOptions registers an IDC client for auth code flow.
+// opts may be nil to use the default Kiro IDE identity.
+func (c *SSOOIDCClient) RegisterClientForAuthCodeWithIDCAndOptions(ctx context.Context, redirectURI, issuerUrl, region string, opts *RegisterOptions) (*RegisterClientResponse, error) {
 	endpoint := getOIDCEndpoint(region)
 
 	payload := map[string]interface{}{
-		"clientName":   "Kiro IDE",
-		"clientType":   "public",
+		"clientName":   opts.clientName(),
+		"clientType":   opts.clientType(),
 		"scopes":       []string{"codewhisperer:completions", "codewhisperer:analysis", "codewhisperer:conversations", "codewhisperer:transformations", "codewhisperer:taskassist"},
 		"grantTypes":   []string{"authorization_code", "refresh_token"},
 		"redirectUris": []string{redirectURI},
@@ -1133,9 +1425,7 @@ func (c *SSOOIDCClient) RegisterClientForAuthCodeWithIDC(ctx context.Context, re
 	if err != nil {
 		return nil, err
 	}
-	SetOIDCHeaders(req)
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := wrapHTTPClient(c.httpClient).DoOIDC(req)
 	if err != nil {
 		return nil, err
 	}
@@ -1184,6 +1474,16 @@ func (c *SSOOIDCClient) startAuthCodeCallbackServer(ctx context.Context, expecte
 	resultChan := make(chan AuthCodeCallbackResult, 1)
 	doneChan := make(chan struct{})
 
+	// Only the first callback is delivered; repeated callbacks (browser retries,
+	// reloads) must neither block on the buffered channel nor close doneChan twice.
+	var finishOnce sync.Once
+	finish := func(result AuthCodeCallbackResult) {
+		finishOnce.Do(func() {
+			resultChan <- result
+			close(doneChan)
+		})
+	}
+
 	server := &http.Server{
 		ReadHeaderTimeout: 10 * time.Second,
 	}
@@ -1201,18 +1501,16 @@ func (c *SSOOIDCClient) startAuthCodeCallbackServer(ctx context.Context, expecte
 			fmt.Fprintf(w, `<!DOCTYPE html>
 <html><head><title>Login Failed</title></head>
 <body><h1>Login Failed</h1><p>Error: %s</p><p>You can close this window.</p></body></html>`, html.EscapeString(errParam))
-			resultChan <- AuthCodeCallbackResult{Error: errParam}
-			close(doneChan)
+			finish(AuthCodeCallbackResult{Error: errParam})
 			return
 		}
 
-		if state != expectedState {
+		if validateAuthCodeState(expectedState, state) != nil {
 			w.WriteHeader(http.StatusBadRequest)
 			fmt.Fprint(w, `<!DOCTYPE html>
 <html><head><title>Login Failed</title></head>
 <body><h1>Login Failed</h1><p>Invalid state parameter</p><p>You can close this window.</p></body></html>`)
-			resultChan <- AuthCodeCallbackResult{Error: "state mismatch"}
-			close(doneChan)
+			finish(AuthCodeCallbackResult{Error: "state mismatch"})
 			return
 		}
 
@@ -1220,8 +1518,7 @@ func (c *SSOOIDCClient) startAuthCodeCallbackServer(ctx context.Context, expecte
 <html><head><title>Login Successful</title></head>
 <body><h1>Login Successful!</h1><p>You can close this window and return to the terminal.</p>
 <script>window.close();</script></body></html>`)
-		resultChan <- AuthCodeCallbackResult{Code: code, State: state}
-		close(doneChan)
+		finish(AuthCodeCallbackResult{Code: code, State: state})
 	})
 
 	server.Handler = mux
@@ -1244,9 +1541,17 @@ func (c *SSOOIDCClient) startAuthCodeCallbackServer(ctx context.Context, expecte
 	return redirectURI, resultChan, nil
 }
 
-// generatePKCEForAuthCode generates PKCE code verifier and challenge for authorization code flow.
-func generatePKCEForAuthCode() (verifier, challenge string, err error) {
-	b := make([]byte, 32)
+// PKCE code verifier length bounds from RFC 7636 section 4.1.
+const (
+	pkceVerifierMinLen = 43
+	pkceVerifierMaxLen = 128
+)
+
+// GeneratePKCE generates an RFC 7636 code verifier and its S256 challenge.
+// The verifier is 64 base64url characters drawn from 48 random bytes, and the
+// challenge is base64url(sha256(verifier)) without padding.
+func GeneratePKCE() (verifier, challenge string, err error) {
+	b := make([]byte, 48)
 	if _, err := rand.Read(b); err != nil {
 		return "", "", fmt.Errorf("failed to generate random bytes: %w", err)
 	}
@@ -1256,6 +1561,24 @@ func generatePKCEForAuthCode() (verifier, challenge string, err error) {
 	return verifier, challenge, nil
 }
 
+// validatePKCEVerifier checks the RFC 7636 length and unreserved-charset rules so an
+// auth code is never exchanged without a verifier bound to its challenge.
+func validatePKCEVerifier(verifier string) error {
+	if len(verifier) < pkceVerifierMinLen || len(verifier) > pkceVerifierMaxLen {
+		return fmt.Errorf("invalid PKCE code verifier: length %d outside %d-%d", len(verifier), pkceVerifierMinLen, pkceVerifierMaxLen)
+	}
+	for i := 0; i < len(verifier); i++ {
+		ch := verifier[i]
+		switch {
+		case ch >= 'A' && ch <= 'Z', ch >= 'a' && ch <= 'z', ch >= '0' && ch <= '9':
+		case ch == '-' || ch == '.' || ch == '_' || ch == '~':
+		default:
+			return fmt.Errorf("invalid PKCE code verifier: character %q not allowed", ch)
+		}
+	}
+	return nil
+}
+
 // generateStateForAuthCode generates a random state parameter.
 func generateStateForAuthCode() (string, error) {
 	b := make([]byte, 16)
@@ -1265,8 +1588,57 @@ func generateStateForAuthCode() (string, error) {
 	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
+// validateAuthCodeState compares the state returned by the authorization server with
+// the one generated for the request in constant time. An empty expected state is
+// always rejected so a missing state can never validate.
+func validateAuthCodeState(expected, received string) error {
+	if expected == "" || !secureEqual(expected, received) {
+		return ErrStateMismatch
+	}
+	return nil
+}
+
+// secureEqual reports whether a and b are equal in constant time. Both values are
+// padded to the longer length before comparing, so a length mismatch does not
+// short-circuit the byte comparison. Use it for states, secrets and other values
+// received from the network.
+func secureEqual(a, b string) bool {
+	n := len(a)
+	if len(b) > n {
+		n = len(b)
+	}
+	paddedA := make([]byte, n)
+	paddedB := make([]byte, n)
+	copy(paddedA, a)
+	copy(paddedB, b)
+	sameBytes := subtle.ConstantTimeCompare(paddedA, paddedB)
+	sameLength := subtle.ConstantTimeEq(int32(len(a)), int32(len(b)))
+	return sameBytes&sameLength == 1
+}
+
+// authCodeFromCallback returns the authorization code from a callback result after
+// re-validating its state, so the code is never exchanged for a mismatched request.
+func authCodeFromCallback(result AuthCodeCallbackResult, expectedState string) (string, error) {
+	if result.Error != "" {
+		return "", fmt.Errorf("authorization failed: %s", result.Error)
+	}
+	if err := validateAuthCodeState(expectedState, result.State); err != nil {
+		return "", fmt.Errorf("authorization failed: %w", err)
+	}
+	if result.Code == "" {
+		return "", fmt.Errorf("authorization failed: missing authorization code")
+	}
+	return result.Code, nil
+}
+
 // CreateTokenWithAuthCode exchanges authorization code for tokens.
-func (c *SSOOIDCClient) CreateTokenWithAuthCode(ctx context.Context, clientID, clientSecret, code, codeVerifier, redirectURI string) (*CreateTokenResponse, error) {
+func (c *SSOOIDCClient) CreateTokenWithAuthCode(ctx context.Context, clientID, clientSecret, code, codeVerifier, redirectURI string) (_ *CreateTokenResponse, err error) {
+	defer func() { auditTokenEvent(AuditEventTokenCreateWithAuthCode, GenerateAccountKey(clientID), err) }()
+
+	if errVerifier := validatePKCEVerifier(codeVerifier); errVerifier != nil {
+		return nil, errVerifier
+	}
+
 	payload := map[string]string{
 		"clientId":     clientID,
 		"clientSecret": clientSecret,
@@ -1285,9 +1657,7 @@ func (c *SSOOIDCClient) CreateTokenWithAuthCode(ctx context.Context, clientID, c
 	if err != nil {
 		return nil, err
 	}
-	SetOIDCHeaders(req)
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := wrapHTTPClient(c.httpClient).DoOIDC(req)
 	if err != nil {
 		return nil, err
 	}
@@ -1311,7 +1681,13 @@ func (c *SSOOIDCClient) CreateTokenWithAuthCode(ctx context.Context, clientID, c
 	return &result, nil
 }
 
-func (c *SSOOIDCClient) CreateTokenWithAuthCodeAndRegion(ctx context.Context, clientID, clientSecret, code, codeVerifier, redirectURI, region string) (*CreateTokenResponse, error) {
+func (c *SSOOIDCClient) CreateTokenWithAuthCodeAndRegion(ctx context.Context, clientID, clientSecret, code, codeVerifier, redirectURI, region string) (_ *CreateTokenResponse, err error) {
+	defer func() { auditTokenEvent(AuditEventTokenCreateWithAuthCode, GenerateAccountKey(clientID), err) }()
+
+	if errVerifier := validatePKCEVerifier(codeVerifier); errVerifier != nil {
+		return nil, errVerifier
+	}
+
 	endpoint := getOIDCEndpoint(region)
 
 	payload := map[string]string{
@@ -1332,9 +1708,7 @@ func (c *SSOOIDCClient) CreateTokenWithAuthCodeAndRegion(ctx context.Context, cl
 	if err != nil {
 		return nil, err
 	}
-	SetOIDCHeaders(req)
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := wrapHTTPClient(c.httpClient).DoOIDC(req)
 	if err != nil {
 		return nil, err
 	}
@@ -1358,6 +1732,44 @@ func (c *SSOOIDCClient) CreateTokenWithAuthCodeAndRegion(ctx context.Context, cl
 	return &result, nil
 }
 
+// OIDCCreateTokenRequest holds the parameters of an SSO OIDC authorization code
+// token exchange. It is distinct from CreateTokenRequest, which targets the
+// social login endpoint.
+type OIDCCreateTokenRequest struct {
+	ClientID     string
+	ClientSecret string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	Region       string // OIDC region; empty uses defaultIDCRegion
+}
+
+// CreateTokenFromAuthCode exchanges an authorization code for tokens at the OIDC
+// token endpoint of req.Region and returns them as token data. Unlike the login
+// flows it does no profile ARN or email lookup, so callers fill those in.
+// CreateToken remains the device code exchange.
+func (c *SSOOIDCClient) CreateTokenFromAuthCode(ctx context.Context, req OIDCCreateTokenRequest) (*KiroTokenData, error) {
+	region := req.Region
+	if region == "" {
+		region = defaultIDCRegion
+	}
+	tokenResp, err := c.CreateTokenWithAuthCodeAndRegion(ctx, req.ClientID, req.ClientSecret, req.Code, req.CodeVerifier, req.RedirectURI, region)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	return &KiroTokenData{
+		AccessToken:  tokenResp.AccessToken,
+		RefreshToken: tokenResp.RefreshToken,
+		ExpiresAt:    expiresAt.Format(time.RFC3339),
+		AuthMethod:   "idc",
+		Provider:     "AWS",
+		ClientID:     req.ClientID,
+		ClientSecret: req.ClientSecret,
+		Region:       region,
+	}, nil
+}
+
 // LoginWithBuilderIDAuthCode performs the authorization code flow for AWS Builder ID.
 // This provides a better UX than device code flow as it uses automatic browser callback.
 func (c *SSOOIDCClient) LoginWithBuilderIDAuthCode(ctx context.Context) (*KiroTokenData, error) {
@@ -1366,7 +1778,7 @@ func (c *SSOOIDCClient) LoginWithBuilderIDAuthCode(ctx context.Context) (*KiroTo
 	fmt.Println("╚══════════════════════════════════════════════════════════╝")
 
 	// Step 1: Generate PKCE and state
-	codeVerifier, codeChallenge, err := generatePKCEForAuthCode()
+	codeVerifier, codeChallenge, err := GeneratePKCE()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate PKCE: %w", err)
 	}
@@ -1435,9 +1847,10 @@ func (c *SSOOIDCClient) LoginWithBuilderIDAuthCode(ctx context.Context) (*KiroTo
 		browser.CloseBrowser()
 		return nil, fmt.Errorf("authorization timed out")
 	case result := <-resultChan:
-		if result.Error != "" {
+		code, errCallback := authCodeFromCallback(result, state)
+		if errCallback != nil {
 			browser.CloseBrowser()
-			return nil, fmt.Errorf("authorization failed: %s", result.Error)
+			return nil, errCallback
 		}
 
 		fmt.Println("\n✓ Authorization received!")
@@ -1449,7 +1862,7 @@ func (c *SSOOIDCClient) LoginWithBuilderIDAuthCode(ctx context.Context) (*KiroTo
 
 		// Step 7: Exchange code for tokens
 		fmt.Println("Exchanging code for tokens...")
-		tokenResp, err := c.CreateTokenWithAuthCode(ctx, regResp.ClientID, regResp.ClientSecret, result.Code, codeVerifier, redirectURI)
+		tokenResp, err := c.CreateTokenWithAuthCode(ctx, regResp.ClientID, regResp.ClientSecret, code, codeVerifier, redirectURI)
 		if err != nil {
 			return nil, fmt.Errorf("failed to exchange code for tokens: %w", err)
 		}
@@ -1479,115 +1892,131 @@ func (c *SSOOIDCClient) LoginWithBuilderIDAuthCode(ctx context.Context) (*KiroTo
 	}
 }
 
+// LoginWithIDCAuthCode runs LoginIDC for the CLI: it prints progress to the
+// terminal and opens the authorize URL in the system browser, honoring the
+// incognito-browser setting.
 func (c *SSOOIDCClient) LoginWithIDCAuthCode(ctx context.Context, startURL, region string) (*KiroTokenData, error) {
 	fmt.Println("\n╔══════════════════════════════════════════════════════════╗")
 	fmt.Println("║     Kiro Authentication (AWS IDC - Auth Code)             ║")
 	fmt.Println("╚══════════════════════════════════════════════════════════╝")
 
-	if region == "" {
-		region = defaultIDCRegion
+	if c.cfg != nil {
+		browser.SetIncognitoMode(c.cfg.IncognitoBrowser)
+	} else {
+		browser.SetIncognitoMode(true)
+	}
+
+	openBrowser := func(authURL string) error {
+		fmt.Println("\n════════════════════════════════════════════════════════════")
+		fmt.Println("  Opening browser for authentication...")
+		fmt.Println("════════════════════════════════════════════════════════════")
+		fmt.Printf("\n  URL: %s\n\n", authURL)
+
+		if err := browser.OpenURL(authURL); err != nil {
+			log.Warnf("Could not open browser automatically: %v", err)
+			fmt.Println("  ⚠ Could not open browser automatically.")
+			fmt.Println("  Please open the URL above in your browser manually.")
+		} else {
+			fmt.Println("  (Browser opened automatically)")
+		}
+
+		fmt.Println("\n  Waiting for authorization callback...")
+		return nil
 	}
 
-	codeVerifier, codeChallenge, err := generatePKCEForAuthCode()
+	tokenData, err := c.LoginIDC(ctx, startURL, region, openBrowser)
+	if errClose := browser.CloseBrowser(); errClose != nil {
+		log.Debugf("Failed to close browser: %v", errClose)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate PKCE: %w", err)
+		return nil, err
 	}
 
+	fmt.Println("\n✓ Authentication successful!")
+	if tokenData.Email == "" {
+		tokenData.Email = FetchUserEmailWithFallback(ctx, c.cfg, tokenData.AccessToken, tokenData.ClientID, tokenData.RefreshToken)
+	}
+	if tokenData.Email != "" {
+		fmt.Printf("  Logged in as: %s\n", tokenData.Email)
+	}
+	return tokenData, nil
+}
+
+// LoginIDC performs the AWS IDC authorization code flow without any terminal
+// interaction, for library consumers. It registers a client, starts a loopback
+// callback server, builds the authorize URL and passes it to openBrowser, waits
+// for the callback, exchanges the code and looks up the profile ARN and email.
+// An error from openBrowser aborts the login. The callback server is shut down
+// when LoginIDC returns.
+func (c *SSOOIDCClient) LoginIDC(ctx context.Context, startURL, region string, openBrowser func(url string) error) (*KiroTokenData, error) {
+	if openBrowser == nil {
+		return nil, errors.New("login idc: openBrowser is required")
+	}
+	region = OIDCRegionForStartURL(startURL, region)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	codeVerifier, codeChallenge, err := GeneratePKCE()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate PKCE: %w", err)
+	}
 	state, err := generateStateForAuthCode()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate state: %w", err)
 	}
 
-	fmt.Println("\nStarting callback server...")
 	redirectURI, resultChan, err := c.startAuthCodeCallbackServer(ctx, state)
 	if err != nil {
 		return nil, fmt.Errorf("failed to start callback server: %w", err)
 	}
-	log.Debugf("Callback server started, redirect URI: %s", redirectURI)
 
-	fmt.Println("Registering client...")
 	regResp, err := c.RegisterClientForAuthCodeWithIDC(ctx, redirectURI, startURL, region)
 	if err != nil {
 		return nil, fmt.Errorf("failed to register client: %w", err)
 	}
-	log.Debugf("Client registered: %s", regResp.ClientID)
 
-	endpoint := getOIDCEndpoint(region)
 	scopes := "codewhisperer:completions,codewhisperer:analysis,codewhisperer:conversations,codewhisperer:transformations,codewhisperer:taskassist"
-	authURL := buildAuthorizationURL(endpoint, regResp.ClientID, redirectURI, scopes, state, codeChallenge)
-
-	fmt.Println("\n════════════════════════════════════════════════════════════")
-	fmt.Println("  Opening browser for authentication...")
-	fmt.Println("════════════════════════════════════════════════════════════")
-	fmt.Printf("\n  URL: %s\n\n", authURL)
-
-	if c.cfg != nil {
-		browser.SetIncognitoMode(c.cfg.IncognitoBrowser)
-	} else {
-		browser.SetIncognitoMode(true)
-	}
-
-	if err := browser.OpenURL(authURL); err != nil {
-		log.Warnf("Could not open browser automatically: %v", err)
-		fmt.Println("  ⚠ Could not open browser automatically.")
-		fmt.Println("  Please open the URL above in your browser manually.")
-	} else {
-		fmt.Println("  (Browser opened automatically)")
+	authURL := buildAuthorizationURL(getOIDCEndpoint(region), regResp.ClientID, redirectURI, scopes, state, codeChallenge)
+	if errOpen := openBrowser(authURL); errOpen != nil {
+		return nil, fmt.Errorf("failed to open browser: %w", errOpen)
 	}
 
-	fmt.Println("\n  Waiting for authorization callback...")
-
+	var code string
 	select {
 	case <-ctx.Done():
-		browser.CloseBrowser()
 		return nil, ctx.Err()
 	case <-time.After(10 * time.Minute):
-		browser.CloseBrowser()
 		return nil, fmt.Errorf("authorization timed out")
 	case result := <-resultChan:
-		if result.Error != "" {
-			browser.CloseBrowser()
-			return nil, fmt.Errorf("authorization failed: %s", result.Error)
-		}
-
-		fmt.Println("\n✓ Authorization received!")
-
-		if err := browser.CloseBrowser(); err != nil {
-			log.Debugf("Failed to close browser: %v", err)
-		}
-
-		fmt.Println("Exchanging code for tokens...")
-		tokenResp, err := c.CreateTokenWithAuthCodeAndRegion(ctx, regResp.ClientID, regResp.ClientSecret, result.Code, codeVerifier, redirectURI, region)
+		code, err = authCodeFromCallback(result, state)
 		if err != nil {
-			return nil, fmt.Errorf("failed to exchange code for tokens: %w", err)
+			return nil, err
 		}
+	}
 
-		fmt.Println("\n✓ Authentication successful!")
-
-		fmt.Println("Fetching profile information...")
-		profileArn := c.FetchProfileArn(ctx, tokenResp.AccessToken, regResp.ClientID, tokenResp.RefreshToken)
-
-		email := FetchUserEmailWithFallback(ctx, c.cfg, tokenResp.AccessToken, regResp.ClientID, tokenResp.RefreshToken)
-		if email != "" {
-			fmt.Printf("  Logged in as: %s\n", email)
-		}
+	tokenResp, err := c.CreateTokenWithAuthCodeAndRegion(ctx, regResp.ClientID, regResp.ClientSecret, code, codeVerifier, redirectURI, region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code for tokens: %w", err)
+	}
 
-		expiresAt := time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	profileArn := c.FetchProfileArn(ctx, tokenResp.AccessToken, regResp.ClientID, tokenResp.RefreshToken)
+	email := c.FetchUserEmail(ctx, tokenResp.AccessToken)
+	expiresAt := time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
 
-		return &KiroTokenData{
-			AccessToken:  tokenResp.AccessToken,
-			RefreshToken: tokenResp.RefreshToken,
-			ProfileArn:   profileArn,
-			ExpiresAt:    expiresAt.Format(time.RFC3339),
-			AuthMethod:   "idc",
-			Provider:     "AWS",
-			ClientID:     regResp.ClientID,
-			ClientSecret: regResp.ClientSecret,
-			Email:        email,
-			StartURL:     startURL,
-			Region:       region,
-		}, nil
-	}
+	return &KiroTokenData{
+		AccessToken:  tokenResp.AccessToken,
+		RefreshToken: tokenResp.RefreshToken,
+		ProfileArn:   profileArn,
+		ExpiresAt:    expiresAt.Format(time.RFC3339),
+		AuthMethod:   "idc",
+		Provider:     "AWS",
+		ClientID:     regResp.ClientID,
+		ClientSecret: regResp.ClientSecret,
+		Email:        email,
+		StartURL:     startURL,
+		Region:       region,
+	}, nil
 }
 
 func buildAuthorizationURL(endpoint, clientID, redirectURI, scopes, state, codeChallenge string) string {
@@ -2,14 +2,21 @@ package kiro
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
 )
 
 type recordingRoundTripper struct {
@@ -38,7 +45,7 @@ func TestTryListAvailableProfiles_UsesClientIDForAccountKey(t *testing.T) {
 	}
 
 	accountKey := GetAccountKey("client-id-123", "refresh-token-456")
-	fp := GlobalFingerprintManager().GetFingerprint(accountKey)
+	fp := GlobalFingerprintManager().GetFingerprintFor(accountKey, FingerprintPurposeRuntime)
 	expected := fmt.Sprintf("aws-sdk-js/%s KiroIDE-%s-%s", fp.RuntimeSDKVersion, fp.KiroVersion, fp.KiroHash)
 	got := rt.lastReq.Header.Get("X-Amz-User-Agent")
 	if got != expected {
@@ -58,7 +65,7 @@ func TestTryListAvailableProfiles_UsesRefreshTokenWhenClientIDMissing(t *testing
 	}
 
 	accountKey := GetAccountKey("", "refresh-token-789")
-	fp := GlobalFingerprintManager().GetFingerprint(accountKey)
+	fp := GlobalFingerprintManager().GetFingerprintFor(accountKey, FingerprintPurposeRuntime)
 	expected := fmt.Sprintf("aws-sdk-js/%s KiroIDE-%s-%s", fp.RuntimeSDKVersion, fp.KiroVersion, fp.KiroHash)
 	got := rt.lastReq.Header.Get("X-Amz-User-Agent")
 	if got != expected {
@@ -202,6 +209,39 @@ func TestRegisterClientForAuthCodeWithIDC(t *testing.T) {
 	}
 }
 
+func TestRegisterClientForAuthCodeWithIDCAndOptions_CustomClientName(t *testing.T) {
+	var body map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bodyBytes, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(bodyBytes, &body)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(RegisterClientResponse{ClientID: "id", ClientSecret: "secret"})
+	}))
+	defer ts.Close()
+
+	client := &SSOOIDCClient{
+		httpClient: &http.Client{Transport: &rewriteTransport{base: ts.Client().Transport, targetURL: ts.URL}},
+	}
+
+	_, err := client.RegisterClientForAuthCodeWithIDCAndOptions(
+		context.Background(),
+		"http://127.0.0.1:19877/oauth/callback",
+		"https://my-idc-instance.awsapps.com/start",
+		"us-east-1",
+		&RegisterOptions{ClientName: "Kiro IDE Fork"},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if v, _ := body["clientName"].(string); v != "Kiro IDE Fork" {
+		t.Errorf("clientName = %q, want %q", v, "Kiro IDE Fork")
+	}
+	if v, _ := body["clientType"].(string); v != "public" {
+		t.Errorf("clientType = %q, want default %q", v, "public")
+	}
+}
+
 // rewriteTransport redirects all requests to the test server URL.
 type rewriteTransport struct {
 	base      http.RoundTripper
@@ -259,3 +299,882 @@ func TestBuildAuthorizationURL(t *testing.T) {
 		}
 	}
 }
+
+func TestStartAuthCodeCallbackServer_RepeatedCallbacksDoNotBlock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := &SSOOIDCClient{}
+	redirectURI, resultChan, err := client.startAuthCodeCallbackServer(ctx, "expected-state")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	httpClient := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	for i := 0; i < 3; i++ {
+		resp, errGet := httpClient.Get(redirectURI + "?code=auth-code&state=expected-state")
+		if errGet != nil {
+			// The server shuts down after the first callback; later requests may be refused.
+			if i == 0 {
+				t.Fatalf("callback request failed: %v", errGet)
+			}
+			continue
+		}
+		_ = resp.Body.Close()
+	}
+
+	result := <-resultChan
+	if result.Error != "" || result.Code != "auth-code" {
+		t.Fatalf("result = %+v, want code auth-code", result)
+	}
+}
+
+func TestStartAuthCodeCallbackServer_RejectsMismatchedState(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state, err := generateStateForAuthCode()
+	if err != nil {
+		t.Fatalf("generateStateForAuthCode: %v", err)
+	}
+
+	client := &SSOOIDCClient{}
+	redirectURI, resultChan, err := client.startAuthCodeCallbackServer(ctx, state)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	httpClient := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	resp, err := httpClient.Get(redirectURI + "?code=attacker-code&state=forged-state")
+	if err != nil {
+		t.Fatalf("callback request failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	result := <-resultChan
+	if result.Code != "" {
+		t.Errorf("result carries code %q for a mismatched state", result.Code)
+	}
+	if _, errCode := authCodeFromCallback(result, state); errCode == nil {
+		t.Fatal("expected exchange to be refused for mismatched state")
+	}
+}
+
+func TestAuthCodeFromCallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   AuthCodeCallbackResult
+		expected string
+		wantCode string
+		wantErr  error
+	}{
+		{"matching state", AuthCodeCallbackResult{Code: "code", State: "s1"}, "s1", "code", nil},
+		{"mismatched state", AuthCodeCallbackResult{Code: "code", State: "forged"}, "s1", "", ErrStateMismatch},
+		{"missing state", AuthCodeCallbackResult{Code: "code"}, "s1", "", ErrStateMismatch},
+		{"empty expected state", AuthCodeCallbackResult{Code: "code"}, "", "", ErrStateMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := authCodeFromCallback(tt.result, tt.expected)
+			if code != tt.wantCode {
+				t.Errorf("code = %q, want %q", code, tt.wantCode)
+			}
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerateStateForAuthCodeIsUnpredictable(t *testing.T) {
+	seen := make(map[string]struct{})
+	for i := 0; i < 100; i++ {
+		state, err := generateStateForAuthCode()
+		if err != nil {
+			t.Fatalf("generateStateForAuthCode: %v", err)
+		}
+		if len(state) < 22 {
+			t.Fatalf("state %q is shorter than 128 bits of entropy", state)
+		}
+		if _, dup := seen[state]; dup {
+			t.Fatalf("duplicate state generated: %q", state)
+		}
+		seen[state] = struct{}{}
+	}
+}
+
+func TestSecureEqual(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"state-value", "state-value", true},
+		{"", "", true},
+		{"state-value", "state-valuf", false},
+		{"state", "state-value", false},
+		{"state-value", "state", false},
+		{"", "state", false},
+		// Padding with zero bytes must not make a shorter value match.
+		{"state", "state\x00\x00", false},
+		{"state\x00", "state", false},
+	}
+	for _, tt := range tests {
+		if got := secureEqual(tt.a, tt.b); got != tt.want {
+			t.Errorf("secureEqual(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestGeneratePKCE(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		verifier, challenge, err := GeneratePKCE()
+		if err != nil {
+			t.Fatalf("GeneratePKCE: %v", err)
+		}
+		if err := validatePKCEVerifier(verifier); err != nil {
+			t.Fatalf("verifier %q violates RFC 7636: %v", verifier, err)
+		}
+		sum := sha256.Sum256([]byte(verifier))
+		if want := base64.RawURLEncoding.EncodeToString(sum[:]); challenge != want {
+			t.Fatalf("challenge = %q, want base64url(sha256(verifier)) = %q", challenge, want)
+		}
+	}
+}
+
+func TestValidatePKCEVerifier(t *testing.T) {
+	tests := []struct {
+		name     string
+		verifier string
+		wantErr  bool
+	}{
+		{"min length", strings.Repeat("a", 43), false},
+		{"max length", strings.Repeat("Z", 128), false},
+		{"unreserved chars", strings.Repeat("aZ09-._~", 6), false},
+		{"empty", "", true},
+		{"too short", strings.Repeat("a", 42), true},
+		{"too long", strings.Repeat("a", 129), true},
+		{"invalid char", strings.Repeat("a", 42) + "+", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validatePKCEVerifier(tt.verifier); (err != nil) != tt.wantErr {
+				t.Errorf("validatePKCEVerifier() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreateTokenWithAuthCodeRejectsMissingVerifier(t *testing.T) {
+	rt := &recordingRoundTripper{}
+	client := &SSOOIDCClient{httpClient: &http.Client{Transport: rt}}
+	if _, err := client.CreateTokenWithAuthCode(context.Background(), "client-id", "secret", "code", "", "http://127.0.0.1/cb"); err == nil {
+		t.Fatal("expected an error for a missing code verifier")
+	}
+	if rt.lastReq != nil {
+		t.Fatal("token endpoint called without a PKCE verifier")
+	}
+}
+
+func TestStartDeviceAuthorizationWithRegion(t *testing.T) {
+	var gotHost, gotPath string
+	var gotBody map[string]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHost, gotPath = r.Host, r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"deviceCode":"device-code","userCode":"ABCD-EFGH","verificationUri":"https://device.sso.eu-west-1.amazonaws.com/","verificationUriComplete":"https://device.sso.eu-west-1.amazonaws.com/?user_code=ABCD-EFGH","expiresIn":600,"interval":5}`)
+	}))
+	defer ts.Close()
+
+	client := &SSOOIDCClient{httpClient: &http.Client{Transport: &rewriteTransport{targetURL: ts.URL}}}
+	resp, err := client.StartDeviceAuthorizationWithRegion(context.Background(), "client-id", "client-secret", "eu-west-1")
+	if err != nil {
+		t.Fatalf("StartDeviceAuthorizationWithRegion() error = %v", err)
+	}
+
+	if gotHost != "oidc.eu-west-1.amazonaws.com" || gotPath != "/device_authorization" {
+		t.Errorf("request = %s%s, want oidc.eu-west-1.amazonaws.com/device_authorization", gotHost, gotPath)
+	}
+	if gotBody["clientId"] != "client-id" || gotBody["clientSecret"] != "client-secret" || gotBody["startUrl"] != builderIDStartURL {
+		t.Errorf("request body = %v", gotBody)
+	}
+	if resp.DeviceCode != "device-code" || resp.UserCode != "ABCD-EFGH" || resp.VerificationURI == "" || resp.ExpiresIn != 600 || resp.Interval != 5 {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestStartDeviceAuthorizationWithRegion_Error(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"error":"invalid_client"}`)
+	}))
+	defer ts.Close()
+
+	client := &SSOOIDCClient{httpClient: &http.Client{Transport: &rewriteTransport{targetURL: ts.URL}}}
+	if _, err := client.StartDeviceAuthorizationWithRegion(context.Background(), "client-id", "client-secret", ""); err == nil {
+		t.Fatal("expected an error for a non-200 response")
+	}
+}
+
+// newDeviceTokenServer returns a token endpoint that answers with the given
+// OAuth error codes in order, then succeeds. It records the time of each request.
+func newDeviceTokenServer(t *testing.T, states ...string) (*SSOOIDCClient, func() []time.Time) {
+	t.Helper()
+	var (
+		mu    sync.Mutex
+		calls []time.Time
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		n := len(calls)
+		calls = append(calls, time.Now())
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if n < len(states) {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": states[n]})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(CreateTokenResponse{AccessToken: "access", RefreshToken: "refresh", ExpiresIn: 3600})
+	}))
+	t.Cleanup(ts.Close)
+
+	client := &SSOOIDCClient{
+		httpClient: &http.Client{Transport: &rewriteTransport{base: ts.Client().Transport, targetURL: ts.URL}},
+	}
+	return client, func() []time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]time.Time(nil), calls...)
+	}
+}
+
+func TestPollDeviceToken_PendingAndSlowDown(t *testing.T) {
+	orig := slowDownIncrement
+	slowDownIncrement = 100 * time.Millisecond
+	t.Cleanup(func() { slowDownIncrement = orig })
+
+	client, calls := newDeviceTokenServer(t, "authorization_pending", "slow_down", "authorization_pending")
+	token, err := client.PollDeviceToken(context.Background(), "client", "secret", "device", "eu-west-1", "idc", 10*time.Millisecond, time.Minute)
+	if err != nil {
+		t.Fatalf("PollDeviceToken() error = %v", err)
+	}
+	if token.AccessToken != "access" || token.RefreshToken != "refresh" || token.Region != "eu-west-1" || token.ClientID != "client" || token.AuthMethod != "idc" {
+		t.Fatalf("unexpected token data: %+v", token)
+	}
+
+	got := calls()
+	if len(got) != 4 {
+		t.Fatalf("token endpoint called %d times, want 4", len(got))
+	}
+	if gap := got[2].Sub(got[1]); gap < slowDownIncrement {
+		t.Errorf("interval after slow_down = %v, want at least %v", gap, slowDownIncrement)
+	}
+}
+
+func TestPollDeviceToken_TerminalErrors(t *testing.T) {
+	tests := []struct {
+		state string
+		want  error
+	}{
+		{state: "access_denied", want: ErrAccessDenied},
+		{state: "expired_token", want: ErrExpiredToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.state, func(t *testing.T) {
+			client, calls := newDeviceTokenServer(t, "authorization_pending", tt.state)
+			_, err := client.PollDeviceToken(context.Background(), "client", "secret", "device", "", "", time.Millisecond, time.Minute)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("PollDeviceToken() error = %v, want %v", err, tt.want)
+			}
+			if n := len(calls()); n != 2 {
+				t.Errorf("token endpoint called %d times, want 2", n)
+			}
+		})
+	}
+}
+
+func TestPollDeviceToken_ContextCanceled(t *testing.T) {
+	client, _ := newDeviceTokenServer(t, "authorization_pending", "authorization_pending", "authorization_pending")
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Millisecond)
+	defer cancel()
+	_, err := client.PollDeviceToken(ctx, "client", "secret", "device", "", "", 10*time.Millisecond, 0)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("PollDeviceToken() error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestPollDeviceToken_StopsWhenDeviceCodeExpires(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	client, calls := newDeviceTokenServer(t, "authorization_pending", "authorization_pending", "authorization_pending", "authorization_pending")
+	_, err := client.PollDeviceToken(context.Background(), "client", "secret", "device", "", "", 10*time.Millisecond, 25*time.Millisecond)
+	if !errors.Is(err, ErrExpiredToken) {
+		t.Fatalf("PollDeviceToken() error = %v, want ErrExpiredToken", err)
+	}
+	if n := len(calls()); n > 3 {
+		t.Errorf("token endpoint called %d times after the device code expired", n)
+	}
+	if entry := hook.LastEntry(); entry == nil || entry.Data["success"] != false || entry.Data["event_type"] != AuditEventTokenCreate {
+		t.Fatalf("expected a failed token_create audit entry for the abandoned poll, got %+v", entry)
+	}
+}
+
+// hostRoutingTransport sends requests for OIDC hosts to oidcURL and every other
+// host to apiURL.
+type hostRoutingTransport struct {
+	oidcURL string
+	apiURL  string
+}
+
+func (t *hostRoutingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	targetURL := t.apiURL
+	if strings.HasPrefix(req.URL.Host, "oidc.") {
+		targetURL = t.oidcURL
+	}
+	target, _ := url.Parse(targetURL)
+	req.URL.Scheme = target.Scheme
+	req.URL.Host = target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestLoginIDC(t *testing.T) {
+	var registerBody, tokenBody map[string]any
+	oidcServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/client/register":
+			_ = json.NewDecoder(r.Body).Decode(&registerBody)
+			_, _ = w.Write([]byte(`{"clientId":"idc-client","clientSecret":"idc-secret"}`))
+		case "/token":
+			_ = json.NewDecoder(r.Body).Decode(&tokenBody)
+			_, _ = w.Write([]byte(`{"accessToken":"idc-access","refreshToken":"idc-refresh","expiresIn":3600}`))
+		case "/userinfo":
+			_, _ = w.Write([]byte(`{"email":"user@example.com"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer oidcServer.Close()
+
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ListAvailableProfiles" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"profiles":[{"arn":"arn:aws:codewhisperer:us-east-1:123456789012:profile/IDC","profileName":"idc"}]}`))
+	}))
+	defer apiServer.Close()
+
+	client := &SSOOIDCClient{
+		httpClient: &http.Client{Transport: &hostRoutingTransport{oidcURL: oidcServer.URL, apiURL: apiServer.URL}},
+	}
+
+	var authURL *url.URL
+	openBrowser := func(rawURL string) error {
+		parsed, errParse := url.Parse(rawURL)
+		if errParse != nil {
+			return errParse
+		}
+		authURL = parsed
+		query := parsed.Query()
+		callback := query.Get("redirect_uri") + "?code=auth-code&state=" + url.QueryEscape(query.Get("state"))
+		callbackClient := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+		resp, errGet := callbackClient.Get(callback)
+		if errGet != nil {
+			return errGet
+		}
+		return resp.Body.Close()
+	}
+
+	tokenData, err := client.LoginIDC(context.Background(), "https://example.awsapps.com/start", "us-west-2", openBrowser)
+	if err != nil {
+		t.Fatalf("LoginIDC() error = %v", err)
+	}
+
+	if authURL == nil || authURL.Host != "oidc.us-west-2.amazonaws.com" || authURL.Path != "/authorize" {
+		t.Fatalf("authorize URL = %v, want oidc.us-west-2.amazonaws.com/authorize", authURL)
+	}
+	if got := authURL.Query().Get("client_id"); got != "idc-client" {
+		t.Errorf("authorize client_id = %q, want idc-client", got)
+	}
+	if got := registerBody["issuerUrl"]; got != "https://example.awsapps.com/start" {
+		t.Errorf("register issuerUrl = %v, want start URL", got)
+	}
+	if got := tokenBody["code"]; got != "auth-code" {
+		t.Errorf("token code = %v, want auth-code", got)
+	}
+	if got := tokenBody["redirectUri"]; got != authURL.Query().Get("redirect_uri") {
+		t.Errorf("token redirectUri = %v, want %q", got, authURL.Query().Get("redirect_uri"))
+	}
+	if verifier, _ := tokenBody["codeVerifier"].(string); verifier == "" {
+		t.Error("token request missing codeVerifier")
+	} else {
+		sum := sha256.Sum256([]byte(verifier))
+		if challenge := base64.RawURLEncoding.EncodeToString(sum[:]); challenge != authURL.Query().Get("code_challenge") {
+			t.Error("code verifier does not match the authorize code_challenge")
+		}
+	}
+
+	want := KiroTokenData{
+		AccessToken:  "idc-access",
+		RefreshToken: "idc-refresh",
+		ProfileArn:   "arn:aws:codewhisperer:us-east-1:123456789012:profile/IDC",
+		AuthMethod:   "idc",
+		Provider:     "AWS",
+		ClientID:     "idc-client",
+		ClientSecret: "idc-secret",
+		Email:        "user@example.com",
+		StartURL:     "https://example.awsapps.com/start",
+		Region:       "us-west-2",
+	}
+	got := *tokenData
+	if _, errParse := time.Parse(time.RFC3339, got.ExpiresAt); errParse != nil {
+		t.Errorf("ExpiresAt = %q, want RFC3339: %v", got.ExpiresAt, errParse)
+	}
+	got.ExpiresAt = ""
+	if got != want {
+		t.Errorf("token data = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoginIDC_OpenBrowserErrorAborts(t *testing.T) {
+	oidcServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"clientId":"idc-client","clientSecret":"idc-secret"}`))
+	}))
+	defer oidcServer.Close()
+
+	client := &SSOOIDCClient{
+		httpClient: &http.Client{Transport: &rewriteTransport{targetURL: oidcServer.URL}},
+	}
+	errBrowser := errors.New("no browser")
+	_, err := client.LoginIDC(context.Background(), "https://example.awsapps.com/start", "", func(string) error { return errBrowser })
+	if !errors.Is(err, errBrowser) {
+		t.Fatalf("LoginIDC() error = %v, want %v", err, errBrowser)
+	}
+}
+
+func TestParseNextToken(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"camel case", `{"nextToken":"abc"}`, "abc"},
+		{"capitalised", `{"NextToken":"abc"}`, "abc"},
+		{"nested", `{"pagination":{"nextToken":"abc"}}`, "abc"},
+		{"nested capitalised", `{"Pagination":{"NextToken":"abc"}}`, "abc"},
+		{"null", `{"nextToken":null}`, ""},
+		{"empty string", `{"NextToken":""}`, ""},
+		{"missing", `{"profiles":[]}`, ""},
+		{"null falls through to capitalised", `{"nextToken":null,"NextToken":"abc"}`, "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseNextToken([]byte(tt.body)); got != tt.want {
+				t.Errorf("parseNextToken(%s) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+// newProfilePagesServer serves ListAvailableProfiles pages in order and records
+// the nextToken sent with each request.
+func newProfilePagesServer(t *testing.T, pages []string) (*SSOOIDCClient, *[]string) {
+	t.Helper()
+	var mu sync.Mutex
+	var tokens []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			NextToken string `json:"nextToken"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		idx := len(tokens)
+		tokens = append(tokens, body.NextToken)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if idx >= len(pages) {
+			idx = len(pages) - 1
+		}
+		_, _ = io.WriteString(w, pages[idx])
+	}))
+	t.Cleanup(ts.Close)
+	client := &SSOOIDCClient{httpClient: &http.Client{Transport: &rewriteTransport{targetURL: ts.URL}}}
+	return client, &tokens
+}
+
+func TestTryListAvailableProfiles_Pagination(t *testing.T) {
+	tests := []struct {
+		name       string
+		pages      []string
+		wantArn    string
+		wantTokens []string
+	}{
+		{
+			name: "camel case cursor",
+			pages: []string{
+				`{"profiles":[],"nextToken":"p2"}`,
+				`{"profiles":[{"arn":"arn:aws:codewhisperer:us-east-1:1:profile/A"}],"nextToken":null}`,
+			},
+			wantArn:    "arn:aws:codewhisperer:us-east-1:1:profile/A",
+			wantTokens: []string{"", "p2"},
+		},
+		{
+			name: "capitalised cursor",
+			pages: []string{
+				`{"profiles":[],"NextToken":"p2"}`,
+				`{"profiles":[],"NextToken":"p3"}`,
+				`{"profiles":[{"arn":"arn:aws:codewhisperer:us-east-1:1:profile/B"}]}`,
+			},
+			wantArn:    "arn:aws:codewhisperer:us-east-1:1:profile/B",
+			wantTokens: []string{"", "p2", "p3"},
+		},
+		{
+			name:       "null terminator",
+			pages:      []string{`{"profiles":[],"nextToken":null}`},
+			wantTokens: []string{""},
+		},
+		{
+			name:       "empty string terminator",
+			pages:      []string{`{"profiles":[],"NextToken":""}`},
+			wantTokens: []string{""},
+		},
+		{
+			name: "repeated cursor stops",
+			pages: []string{
+				`{"profiles":[],"nextToken":"same"}`,
+				`{"profiles":[],"nextToken":"same"}`,
+			},
+			wantTokens: []string{"", "same"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, tokens := newProfilePagesServer(t, tt.pages)
+			got := client.tryListAvailableProfiles(context.Background(), "access", "client", "refresh")
+			if got != tt.wantArn {
+				t.Errorf("profile ARN = %q, want %q", got, tt.wantArn)
+			}
+			if strings.Join(*tokens, ",") != strings.Join(tt.wantTokens, ",") {
+				t.Errorf("request cursors = %q, want %q", *tokens, tt.wantTokens)
+			}
+		})
+	}
+}
+
+func TestTryListAvailableProfiles_CapsPages(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"profiles":[],"nextToken":"page-%d"}`, requests)
+	}))
+	defer ts.Close()
+	client := &SSOOIDCClient{httpClient: &http.Client{Transport: &rewriteTransport{targetURL: ts.URL}}}
+
+	if got := client.tryListAvailableProfiles(context.Background(), "access", "client", "refresh"); got != "" {
+		t.Errorf("profile ARN = %q, want empty", got)
+	}
+	if requests != maxProfileListPages {
+		t.Errorf("requests = %d, want %d", requests, maxProfileListPages)
+	}
+}
+
+// regionRoundTripper answers OIDC requests per host and path, keyed as
+// "oidc.<region>.amazonaws.com/token". Token requests may also be keyed per
+// client as ".../token#<clientId>". Every request and token client ID is recorded.
+type regionRoundTripper struct {
+	responses map[string]*http.Response
+	calls     []string // host+path of each request
+	clientIDs []string // clientId of each token request
+	refreshes []string // refreshToken of each token request
+}
+
+func (rt *regionRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.URL.Host + req.URL.Path
+	rt.calls = append(rt.calls, key)
+	if req.URL.Path == "/token" && req.Body != nil {
+		var body struct {
+			ClientID     string `json:"clientId"`
+			RefreshToken string `json:"refreshToken"`
+		}
+		_ = json.NewDecoder(req.Body).Decode(&body)
+		rt.clientIDs = append(rt.clientIDs, body.ClientID)
+		rt.refreshes = append(rt.refreshes, body.RefreshToken)
+		if resp, ok := rt.responses[key+"#"+body.ClientID]; ok {
+			return resp, nil
+		}
+	}
+	resp, ok := rt.responses[key]
+	if !ok {
+		return nil, fmt.Errorf("unexpected request %s", key)
+	}
+	return resp, nil
+}
+
+func jsonResponse(status int, body string) *http.Response {
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}
+}
+
+func TestRefreshIDCToken_FailsOverToSecondary(t *testing.T) {
+	rt := &regionRoundTripper{responses: map[string]*http.Response{
+		"oidc.us-east-1.amazonaws.com/token": jsonResponse(http.StatusBadRequest, `{"error":"invalid_client","error_description":"client not found"}`),
+		"oidc.eu-west-1.amazonaws.com/token": jsonResponse(http.StatusOK, `{"accessToken":"new-access","refreshToken":"new-refresh","expiresIn":3600}`),
+	}}
+	client := &SSOOIDCClient{httpClient: &http.Client{Transport: rt}}
+
+	// No SecondaryRegion: the region comes from the secondary start URL, not Region.
+	token, err := client.RefreshIDCToken(context.Background(), &KiroTokenData{
+		ClientID:              "client-id",
+		ClientSecret:          "client-secret",
+		RefreshToken:          "refresh",
+		Region:                "us-east-1",
+		StartURL:              "https://primary-org.awsapps.com/start",
+		SecondaryStartURL:     "https://d-123.portal.eu-west-1.app.aws",
+		SecondaryClientID:     "secondary-client",
+		SecondaryClientSecret: "secondary-secret",
+		SecondaryRefreshToken: "secondary-refresh",
+	})
+	if err != nil {
+		t.Fatalf("RefreshIDCToken: %v", err)
+	}
+	want := []string{"oidc.us-east-1.amazonaws.com/token", "oidc.eu-west-1.amazonaws.com/token"}
+	if strings.Join(rt.calls, ",") != strings.Join(want, ",") {
+		t.Fatalf("calls = %v, want %v", rt.calls, want)
+	}
+	if strings.Join(rt.clientIDs, ",") != "client-id,secondary-client" || strings.Join(rt.refreshes, ",") != "refresh,secondary-refresh" {
+		t.Errorf("token requests = %v / %v, want the secondary credentials on retry", rt.clientIDs, rt.refreshes)
+	}
+	if token.AccessToken != "new-access" || token.RefreshToken != "new-refresh" {
+		t.Errorf("tokens = %q/%q, want the secondary response", token.AccessToken, token.RefreshToken)
+	}
+	if token.StartURL != "https://d-123.portal.eu-west-1.app.aws" || token.Region != "eu-west-1" || token.ClientID != "secondary-client" {
+		t.Errorf("primary = %q %q %q, want the secondary instance promoted", token.StartURL, token.Region, token.ClientID)
+	}
+	if token.SecondaryStartURL != "https://primary-org.awsapps.com/start" || token.SecondaryRegion != "us-east-1" ||
+		token.SecondaryClientID != "client-id" || token.SecondaryRefreshToken != "refresh" {
+		t.Errorf("secondary = %+v, want the old primary", token)
+	}
+}
+
+func TestRefreshIDCToken_RequiresSecondaryLogin(t *testing.T) {
+	rt := &regionRoundTripper{responses: map[string]*http.Response{
+		"oidc.us-east-1.amazonaws.com/token": jsonResponse(http.StatusBadRequest, `{"error":"invalid_client"}`),
+	}}
+	client := &SSOOIDCClient{httpClient: &http.Client{Transport: rt}}
+
+	_, err := client.RefreshIDCToken(context.Background(), &KiroTokenData{
+		ClientID:          "client-id",
+		ClientSecret:      "client-secret",
+		RefreshToken:      "refresh",
+		Region:            "us-east-1",
+		StartURL:          "https://primary-org.awsapps.com/start",
+		SecondaryStartURL: "https://secondary-org.awsapps.com/start",
+	})
+	if !errors.Is(err, ErrSecondaryLoginRequired) {
+		t.Fatalf("RefreshIDCToken error = %v, want ErrSecondaryLoginRequired", err)
+	}
+	if len(rt.calls) != 1 {
+		t.Errorf("calls = %v, want only the primary refresh", rt.calls)
+	}
+}
+
+func TestRefreshIDCToken_SecondaryClientNotRegistered(t *testing.T) {
+	rt := &regionRoundTripper{responses: map[string]*http.Response{
+		"oidc.us-east-1.amazonaws.com/token": jsonResponse(http.StatusBadRequest, `{"error":"invalid_client"}`),
+		"oidc.eu-west-1.amazonaws.com/token": jsonResponse(http.StatusBadRequest, `{"error":"invalid_client"}`),
+	}}
+	client := &SSOOIDCClient{httpClient: &http.Client{Transport: rt}}
+
+	_, err := client.RefreshIDCToken(context.Background(), &KiroTokenData{
+		ClientID:              "client-id",
+		ClientSecret:          "client-secret",
+		RefreshToken:          "refresh",
+		Region:                "us-east-1",
+		StartURL:              "https://primary-org.awsapps.com/start",
+		SecondaryStartURL:     "https://secondary-org.awsapps.com/start",
+		SecondaryRegion:       "eu-west-1",
+		SecondaryClientID:     "stored-client",
+		SecondaryClientSecret: "stored-secret",
+		SecondaryRefreshToken: "stored-refresh",
+	})
+	if !errors.Is(err, ErrSecondaryLoginRequired) {
+		t.Fatalf("RefreshIDCToken error = %v, want ErrSecondaryLoginRequired", err)
+	}
+}
+
+func TestRefreshIDCToken_NoFailoverForOtherErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		secondary string
+		resp      *http.Response
+	}{
+		{"server error", "https://secondary-org.awsapps.com/start", jsonResponse(http.StatusInternalServerError, `{"error":"internal"}`)},
+		{"invalid grant", "https://secondary-org.awsapps.com/start", jsonResponse(http.StatusBadRequest, `{"error":"invalid_grant"}`)},
+		{"no secondary", "", jsonResponse(http.StatusBadRequest, `{"error":"invalid_client"}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := &regionRoundTripper{responses: map[string]*http.Response{"oidc.us-east-1.amazonaws.com/token": tt.resp}}
+			client := &SSOOIDCClient{httpClient: &http.Client{Transport: rt}}
+
+			_, err := client.RefreshIDCToken(context.Background(), &KiroTokenData{
+				RefreshToken:      "refresh",
+				Region:            "us-east-1",
+				StartURL:          "https://primary-org.awsapps.com/start",
+				SecondaryStartURL: tt.secondary,
+				SecondaryRegion:   "eu-west-1",
+			})
+			if err == nil {
+				t.Fatal("expected the primary error")
+			}
+			if len(rt.calls) != 1 {
+				t.Fatalf("calls = %v, want only the primary", rt.calls)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenWithRegion_ClientNotRegistered(t *testing.T) {
+	rt := &regionRoundTripper{responses: map[string]*http.Response{
+		"oidc.us-east-1.amazonaws.com/token": jsonResponse(http.StatusBadRequest, `{"__type":"InvalidClientException","message":"expired"}`),
+	}}
+	client := &SSOOIDCClient{httpClient: &http.Client{Transport: rt}}
+
+	_, err := client.RefreshTokenWithRegion(context.Background(), "id", "secret", "refresh", "us-east-1", "")
+	if !errors.Is(err, ErrClientNotRegistered) {
+		t.Fatalf("err = %v, want ErrClientNotRegistered", err)
+	}
+}
+
+func TestLoginAndRefreshUseStartURLRegion(t *testing.T) {
+	const startURL = "https://ssoins-123.portal.eu-central-1.app.aws/start"
+
+	rt := &regionRoundTripper{responses: map[string]*http.Response{
+		"oidc.eu-central-1.amazonaws.com/client/register": jsonResponse(http.StatusOK, `{"clientId":"idc-client","clientSecret":"idc-secret"}`),
+	}}
+	client := &SSOOIDCClient{httpClient: &http.Client{Transport: rt}}
+	errBrowser := errors.New("no browser")
+	var authHost string
+	_, err := client.LoginIDC(context.Background(), startURL, "", func(rawURL string) error {
+		if parsed, errParse := url.Parse(rawURL); errParse == nil {
+			authHost = parsed.Host
+		}
+		return errBrowser
+	})
+	if !errors.Is(err, errBrowser) {
+		t.Fatalf("LoginIDC() error = %v, want %v", err, errBrowser)
+	}
+	if authHost != "oidc.eu-central-1.amazonaws.com" {
+		t.Errorf("authorize host = %q, want the start URL's region", authHost)
+	}
+
+	rt.responses["oidc.eu-central-1.amazonaws.com/token"] = jsonResponse(http.StatusOK, `{"accessToken":"access","refreshToken":"refresh-rotated","expiresIn":3600}`)
+	if _, err := client.RefreshTokenWithRegion(context.Background(), "idc-client", "idc-secret", "start-url-region-refresh", "", startURL); err != nil {
+		t.Fatalf("RefreshTokenWithRegion() error = %v", err)
+	}
+	if last := rt.calls[len(rt.calls)-1]; last != "oidc.eu-central-1.amazonaws.com/token" {
+		t.Errorf("refresh request = %q, want the start URL's region", last)
+	}
+}
+
+func TestOIDCRegionForStartURL(t *testing.T) {
+	tests := []struct {
+		name, startURL, explicit, want string
+	}{
+		{"explicit wins over classic URL", "https://my-company.awsapps.com/start", "eu-west-1", "eu-west-1"},
+		{"explicit wins over regional URL", "https://ssoins-123.portal.ap-southeast-2.app.aws", " eu-central-1 ", "eu-central-1"},
+		{"regional portal URL", "https://ssoins-7223c0a1b2.portal.ap-southeast-2.app.aws/start", "", "ap-southeast-2"},
+		{"GovCloud portal", "https://start.us-gov-home.awsapps.com/directory/d-1234567890", "", "us-gov-west-1"},
+		{"classic URL defaults", "https://d-1234567890.awsapps.com/start", "", defaultIDCRegion},
+		{"malformed region label defaults", "https://x.portal.not_a_region.app.aws", "", defaultIDCRegion},
+		{"empty URL defaults", "", "", defaultIDCRegion},
+		{"unparseable URL defaults", "://bad", "", defaultIDCRegion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OIDCRegionForStartURL(tt.startURL, tt.explicit); got != tt.want {
+				t.Errorf("OIDCRegionForStartURL(%q, %q) = %q, want %q", tt.startURL, tt.explicit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateTokenFromAuthCode(t *testing.T) {
+	var gotHost, gotPath string
+	var gotBody map[string]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHost, gotPath = r.Host, r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"accessToken":"access","refreshToken":"refresh","tokenType":"Bearer","expiresIn":3600}`)
+	}))
+	defer ts.Close()
+
+	verifier, _, err := GeneratePKCE()
+	if err != nil {
+		t.Fatalf("GeneratePKCE() error = %v", err)
+	}
+	client := &SSOOIDCClient{httpClient: &http.Client{Transport: &rewriteTransport{targetURL: ts.URL}}}
+	before := time.Now()
+	token, err := client.CreateTokenFromAuthCode(context.Background(), OIDCCreateTokenRequest{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		Code:         "auth-code",
+		RedirectURI:  "http://127.0.0.1:3128/oauth/callback",
+		CodeVerifier: verifier,
+		Region:       "eu-west-1",
+	})
+	if err != nil {
+		t.Fatalf("CreateTokenFromAuthCode() error = %v", err)
+	}
+
+	if gotHost != "oidc.eu-west-1.amazonaws.com" || gotPath != "/token" {
+		t.Errorf("request = %s%s, want oidc.eu-west-1.amazonaws.com/token", gotHost, gotPath)
+	}
+	want := map[string]string{
+		"clientId":     "client-id",
+		"clientSecret": "client-secret",
+		"code":         "auth-code",
+		"codeVerifier": verifier,
+		"redirectUri":  "http://127.0.0.1:3128/oauth/callback",
+		"grantType":    "authorization_code",
+	}
+	for key, value := range want {
+		if gotBody[key] != value {
+			t.Errorf("request %s = %q, want %q", key, gotBody[key], value)
+		}
+	}
+	if token.AccessToken != "access" || token.RefreshToken != "refresh" || token.ClientID != "client-id" || token.ClientSecret != "client-secret" || token.Region != "eu-west-1" || token.AuthMethod != "idc" {
+		t.Errorf("token = %+v", token)
+	}
+	if remaining, ok := token.TimeToExpiry(before); !ok || remaining < time.Hour-time.Second || remaining > time.Hour+time.Minute {
+		t.Errorf("TimeToExpiry = (%v, %v), want about 1h", remaining, ok)
+	}
+}
+
+func TestCreateTokenFromAuthCode_Errors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"error":"invalid_grant"}`)
+	}))
+	defer ts.Close()
+
+	verifier, _, _ := GeneratePKCE()
+	client := &SSOOIDCClient{httpClient: &http.Client{Transport: &rewriteTransport{targetURL: ts.URL}}}
+	if _, err := client.CreateTokenFromAuthCode(context.Background(), OIDCCreateTokenRequest{ClientID: "c", Code: "bad", CodeVerifier: verifier}); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("error = %v, want status 400 failure", err)
+	}
+	if _, err := client.CreateTokenFromAuthCode(context.Background(), OIDCCreateTokenRequest{ClientID: "c", Code: "code"}); err == nil {
+		t.Error("expected an error for a missing code verifier")
+	}
+}
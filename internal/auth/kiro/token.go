@@ -33,8 +33,24 @@ type KiroTokenStorage struct {
 	Region string `json:"region,omitempty"`
 	// StartURL is the AWS Identity Center start URL (for IDC auth)
 	StartURL string `json:"start_url,omitempty"`
+	// SecondaryStartURL is the fallback Identity Center start URL (for IDC auth)
+	SecondaryStartURL string `json:"secondary_start_url,omitempty"`
+	// SecondaryRegion is the OIDC region of SecondaryStartURL
+	SecondaryRegion string `json:"secondary_region,omitempty"`
+	// SecondaryClientID is the OIDC client registered with the secondary instance
+	SecondaryClientID string `json:"secondary_client_id,omitempty"`
+	// SecondaryClientSecret is the secret of SecondaryClientID
+	SecondaryClientSecret string `json:"secondary_client_secret,omitempty"`
+	// SecondaryRefreshToken is the refresh token issued by the secondary instance
+	SecondaryRefreshToken string `json:"secondary_refresh_token,omitempty"`
 	// Email is the user's email address
 	Email string `json:"email,omitempty"`
+	// LastRefreshAttempt is the timestamp of the last background refresh attempt
+	LastRefreshAttempt string `json:"last_refresh_attempt,omitempty"`
+	// ConsecutiveFailures counts background refresh failures since the last success
+	ConsecutiveFailures int `json:"consecutive_failures,omitempty"`
+	// NextEligibleAt is the earliest time the background refresher retries this token
+	NextEligibleAt string `json:"next_eligible_at,omitempty"`
 }
 
 // SaveTokenToFile persists the token storage to the specified file path.
@@ -85,5 +101,38 @@ func (s *KiroTokenStorage) ToTokenData() *KiroTokenData {
 		Region:       s.Region,
 		StartURL:     s.StartURL,
 		Email:        s.Email,
+
+		SecondaryStartURL:     s.SecondaryStartURL,
+		SecondaryRegion:       s.SecondaryRegion,
+		SecondaryClientID:     s.SecondaryClientID,
+		SecondaryClientSecret: s.SecondaryClientSecret,
+		SecondaryRefreshToken: s.SecondaryRefreshToken,
+	}
+}
+
+// AccountKey returns the account key used to cache this token's fingerprint,
+// derived from ClientID or, failing that, RefreshToken. It returns "" when
+// neither is set, since any other key would not match the cached fingerprint.
+func (t *KiroTokenData) AccountKey() string {
+	if t == nil || (t.ClientID == "" && t.RefreshToken == "") {
+		return ""
+	}
+	return GetAccountKey(t.ClientID, t.RefreshToken)
+}
+
+// AccountKeyFromTokenFile loads the token file named filename in dir and returns
+// its account key. filename must be a plain file name; directory components are rejected.
+func AccountKeyFromTokenFile(dir, filename string) (string, error) {
+	if filename == "" || filename != filepath.Base(filename) {
+		return "", fmt.Errorf("invalid token file name %q", filename)
+	}
+	storage, err := LoadFromFile(filepath.Join(dir, filename))
+	if err != nil {
+		return "", err
+	}
+	key := storage.ToTokenData().AccountKey()
+	if key == "" {
+		return "", fmt.Errorf("token file %s has no client_id or refresh_token", filename)
 	}
+	return key, nil
 }
@@ -0,0 +1,251 @@
+package kiro
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultTempFileMinAge is how old a temp file must be before RepairTokenDir
+// removes it, so writes still in progress are left alone.
+const defaultTempFileMinAge = 10 * time.Minute
+
+// TokenDirReport describes problems found by AuditTokenDir. File paths are
+// relative to Dir.
+type TokenDirReport struct {
+	Dir           string
+	Scanned       int                // Kiro token files parsed
+	Duplicates    []DuplicateAccount // accounts stored in more than one file
+	Unparseable   []string           // .json files that are not valid JSON
+	OrphanedTemps []string           // leftover .tmp files from interrupted writes
+	EmailRenames  []EmailRename      // token files not named after their email
+}
+
+// DuplicateAccount lists the token files that share one account key.
+type DuplicateAccount struct {
+	AccountKey string
+	Files      []string
+}
+
+// EmailRename is a token file whose email-based name differs from its current one.
+// Conflict is set when a file with the suggested name already exists.
+type EmailRename struct {
+	File      string
+	Suggested string
+	Conflict  bool
+}
+
+// HasIssues reports whether the audit found anything to report.
+func (r *TokenDirReport) HasIssues() bool {
+	return len(r.Duplicates) > 0 || len(r.Unparseable) > 0 || len(r.OrphanedTemps) > 0 || len(r.EmailRenames) > 0
+}
+
+// AuditTokenDir scans dir and its subdirectories for duplicate Kiro accounts
+// (by account key), unparseable JSON files, orphaned .tmp files and Kiro tokens
+// whose file name does not match the email-based name GenerateTokenFileName
+// would give them. A sequence-suffixed email name (kiro-x-1.json) counts as
+// applied. Nothing is modified.
+func AuditTokenDir(dir string) (*TokenDirReport, error) {
+	report := &TokenDirReport{Dir: dir}
+	byAccount := make(map[string][]string)
+	var renames []EmailRename
+
+	errWalk := filepath.WalkDir(dir, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			if path == dir {
+				return walkErr
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, errRel := filepath.Rel(dir, path)
+		if errRel != nil {
+			rel = path
+		}
+
+		name := strings.ToLower(d.Name())
+		if strings.HasSuffix(name, ".tmp") {
+			report.OrphanedTemps = append(report.OrphanedTemps, rel)
+			return nil
+		}
+		if !strings.HasSuffix(name, ".json") {
+			return nil
+		}
+
+		data, errRead := os.ReadFile(path)
+		if errRead != nil {
+			return nil
+		}
+		var storage KiroTokenStorage
+		if errUnmarshal := json.Unmarshal(data, &storage); errUnmarshal != nil {
+			report.Unparseable = append(report.Unparseable, rel)
+			return nil
+		}
+		if storage.Type != defaultTokenProvider {
+			return nil
+		}
+		report.Scanned++
+
+		tokenData := storage.ToTokenData()
+		if key := tokenData.AccountKey(); key != "" {
+			byAccount[key] = append(byAccount[key], rel)
+		}
+		if suggested, ok := emailTokenFileName(tokenData, d.Name()); ok {
+			_, errStat := os.Stat(filepath.Join(filepath.Dir(path), suggested))
+			renames = append(renames, EmailRename{
+				File:      rel,
+				Suggested: filepath.Join(filepath.Dir(rel), suggested),
+				Conflict:  errStat == nil,
+			})
+		}
+		return nil
+	})
+	if errWalk != nil {
+		return nil, fmt.Errorf("audit token dir: %w", errWalk)
+	}
+
+	for key, files := range byAccount {
+		if len(files) > 1 {
+			sort.Strings(files)
+			report.Duplicates = append(report.Duplicates, DuplicateAccount{AccountKey: key, Files: files})
+		}
+	}
+	sort.Slice(report.Duplicates, func(i, j int) bool {
+		return report.Duplicates[i].Files[0] < report.Duplicates[j].Files[0]
+	})
+	sort.Strings(report.Unparseable)
+	sort.Strings(report.OrphanedTemps)
+	sort.Slice(renames, func(i, j int) bool { return renames[i].File < renames[j].File })
+	report.EmailRenames = renames
+	return report, nil
+}
+
+// emailTokenFileName returns the email-based file name for tokenData when it has
+// an email and current is neither that name nor a sequence-suffixed variant of it.
+// Names written by the CLI login and import flows, kiro-aws-{email}.json and
+// kiro-{provider}-{email}.json, are email-based too and are left alone.
+func emailTokenFileName(tokenData *KiroTokenData, current string) (string, bool) {
+	if tokenData.Email == "" {
+		return "", false
+	}
+	stem := sanitizeTokenFileStem(GenerateTokenFileName(tokenData))
+	currentStem := strings.TrimSuffix(current, filepath.Ext(current))
+	if currentStem == stem {
+		return "", false
+	}
+	if suffix, ok := strings.CutPrefix(currentStem, stem+"-"); ok && suffix != "" && strings.Trim(suffix, "0123456789") == "" {
+		return "", false
+	}
+	if isCLIEmailTokenFileStem(currentStem, tokenData.Email) {
+		return "", false
+	}
+	return stem + ".json", true
+}
+
+// isCLIEmailTokenFileStem reports whether stem has the form kiro-{label}-{id},
+// where id is email as sanitized by the CLI flows and label is non-empty.
+func isCLIEmailTokenFileStem(stem, email string) bool {
+	id := SanitizeEmailForFilename(email)
+	if id == "" {
+		return false
+	}
+	rest, ok := strings.CutPrefix(stem, "kiro-")
+	if !ok {
+		return false
+	}
+	label, ok := strings.CutSuffix(rest, "-"+id)
+	return ok && label != ""
+}
+
+// RepairOptions selects which fixes RepairTokenDir applies.
+type RepairOptions struct {
+	// RemoveTempFiles deletes orphaned .tmp files older than TempFileMinAge.
+	RemoveTempFiles bool
+	// TempFileMinAge protects recent temp files that may belong to a write in
+	// progress. Non-positive values use 10 minutes.
+	TempFileMinAge time.Duration
+	// RenameToEmail renames token files to their email-based name when no file
+	// with that name exists and the account is not duplicated.
+	RenameToEmail bool
+}
+
+// RepairResult lists what RepairTokenDir changed and what it left alone.
+// File paths are relative to the audited directory.
+type RepairResult struct {
+	Report       *TokenDirReport // audit taken before any change
+	RemovedTemps []string
+	Renamed      []EmailRename
+	Skipped      []string // files a selected fix did not touch, with the reason
+}
+
+// RepairTokenDir audits dir and applies the safe fixes selected in opts: removing
+// stale temp files and renaming tokens to their email-based name. Duplicate
+// accounts and unparseable files are only reported, since choosing which copy
+// to keep needs an operator. A failed fix is recorded in Skipped and does not
+// stop the others.
+func RepairTokenDir(dir string, opts RepairOptions) (*RepairResult, error) {
+	report, err := AuditTokenDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	result := &RepairResult{Report: report}
+
+	if opts.RemoveTempFiles {
+		minAge := opts.TempFileMinAge
+		if minAge <= 0 {
+			minAge = defaultTempFileMinAge
+		}
+		now := time.Now()
+		for _, rel := range report.OrphanedTemps {
+			path := filepath.Join(dir, rel)
+			info, errStat := os.Stat(path)
+			if errStat != nil {
+				result.Skipped = append(result.Skipped, fmt.Sprintf("%s: %v", rel, errStat))
+				continue
+			}
+			if now.Sub(info.ModTime()) < minAge {
+				result.Skipped = append(result.Skipped, fmt.Sprintf("%s: modified less than %v ago", rel, minAge))
+				continue
+			}
+			if errRemove := os.Remove(path); errRemove != nil {
+				result.Skipped = append(result.Skipped, fmt.Sprintf("%s: %v", rel, errRemove))
+				continue
+			}
+			result.RemovedTemps = append(result.RemovedTemps, rel)
+		}
+	}
+
+	if opts.RenameToEmail {
+		duplicated := make(map[string]struct{})
+		for _, dup := range report.Duplicates {
+			for _, file := range dup.Files {
+				duplicated[file] = struct{}{}
+			}
+		}
+		for _, rename := range report.EmailRenames {
+			if _, ok := duplicated[rename.File]; ok {
+				result.Skipped = append(result.Skipped, fmt.Sprintf("%s: account has duplicate token files", rename.File))
+				continue
+			}
+			// Link then remove instead of os.Rename so an existing target is never overwritten.
+			source := filepath.Join(dir, rename.File)
+			if errLink := os.Link(source, filepath.Join(dir, rename.Suggested)); errLink != nil {
+				result.Skipped = append(result.Skipped, fmt.Sprintf("%s: %v", rename.File, errLink))
+				continue
+			}
+			if errRemove := os.Remove(source); errRemove != nil {
+				result.Skipped = append(result.Skipped, fmt.Sprintf("%s: %v", rename.File, errRemove))
+				continue
+			}
+			result.Renamed = append(result.Renamed, rename)
+		}
+	}
+	return result, nil
+}
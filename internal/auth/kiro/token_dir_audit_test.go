@@ -0,0 +1,166 @@
+package kiro
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeAuditTokenDir builds a token directory with one example of every problem
+// AuditTokenDir reports.
+func writeAuditTokenDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	alice := map[string]any{"email": "alice@example.com", "client_id": "alice-client"}
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-builder-id-alice-example-com.json"), alice)
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-builder-id-alice-example-com-1.json"), alice)
+	writeTestTokenFile(t, filepath.Join(dir, "kiro", "kiro-idc-acme-04211.json"), map[string]any{
+		"auth_method": "idc",
+		"email":       "bob@example.com",
+		"client_id":   "bob-client",
+	})
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-builder-id-00042.json"), map[string]any{"client_id": "anon-client"})
+	writeTestTokenFile(t, filepath.Join(dir, "claude-user.json"), map[string]any{"type": "claude", "email": "carol@example.com"})
+	if err := os.WriteFile(filepath.Join(dir, "kiro-broken.json"), []byte("{not json"), 0o600); err != nil {
+		t.Fatalf("write broken token: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "kiro-builder-id-alice-example-com.json.tmp"), []byte("{}"), 0o600); err != nil {
+		t.Fatalf("write temp file: %v", err)
+	}
+	return dir
+}
+
+func TestAuditTokenDir(t *testing.T) {
+	dir := writeAuditTokenDir(t)
+
+	report, err := AuditTokenDir(dir)
+	if err != nil {
+		t.Fatalf("AuditTokenDir() error = %v", err)
+	}
+
+	if report.Scanned != 4 {
+		t.Errorf("Scanned = %d, want 4", report.Scanned)
+	}
+	wantDuplicates := []DuplicateAccount{{
+		AccountKey: GetAccountKey("alice-client", "refresh-token"),
+		Files:      []string{"kiro-builder-id-alice-example-com-1.json", "kiro-builder-id-alice-example-com.json"},
+	}}
+	if !reflect.DeepEqual(report.Duplicates, wantDuplicates) {
+		t.Errorf("Duplicates = %+v, want %+v", report.Duplicates, wantDuplicates)
+	}
+	if want := []string{"kiro-broken.json"}; !reflect.DeepEqual(report.Unparseable, want) {
+		t.Errorf("Unparseable = %v, want %v", report.Unparseable, want)
+	}
+	if want := []string{"kiro-builder-id-alice-example-com.json.tmp"}; !reflect.DeepEqual(report.OrphanedTemps, want) {
+		t.Errorf("OrphanedTemps = %v, want %v", report.OrphanedTemps, want)
+	}
+	wantRenames := []EmailRename{{
+		File:      filepath.Join("kiro", "kiro-idc-acme-04211.json"),
+		Suggested: filepath.Join("kiro", "kiro-idc-bob-example-com.json"),
+	}}
+	if !reflect.DeepEqual(report.EmailRenames, wantRenames) {
+		t.Errorf("EmailRenames = %+v, want %+v", report.EmailRenames, wantRenames)
+	}
+	if !report.HasIssues() {
+		t.Error("HasIssues() = false, want true")
+	}
+
+	// The audit must not change anything.
+	for _, name := range []string{"kiro-builder-id-alice-example-com.json.tmp", filepath.Join("kiro", "kiro-idc-acme-04211.json")} {
+		if _, errStat := os.Stat(filepath.Join(dir, name)); errStat != nil {
+			t.Errorf("%s was modified by the audit: %v", name, errStat)
+		}
+	}
+}
+
+func TestAuditTokenDir_ReportsRenameConflict(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-builder-id-old.json"), map[string]any{"email": "dave@example.com", "client_id": "old"})
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-builder-id-dave-example-com.json"), map[string]any{"email": "dave@example.com", "client_id": "new"})
+
+	report, err := AuditTokenDir(dir)
+	if err != nil {
+		t.Fatalf("AuditTokenDir() error = %v", err)
+	}
+	if len(report.EmailRenames) != 1 || !report.EmailRenames[0].Conflict {
+		t.Fatalf("EmailRenames = %+v, want one conflicting rename", report.EmailRenames)
+	}
+}
+
+func TestAuditTokenDir_AcceptsCLINamingSchemes(t *testing.T) {
+	dir := t.TempDir()
+	email := "erin@example.com"
+	id := SanitizeEmailForFilename(email)
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-aws-"+id+".json"), map[string]any{"email": email, "client_id": "aws"})
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-google-"+id+".json"), map[string]any{"email": email, "client_id": "google"})
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-aws-someone-else.json"), map[string]any{"email": email, "client_id": "other"})
+
+	report, err := AuditTokenDir(dir)
+	if err != nil {
+		t.Fatalf("AuditTokenDir() error = %v", err)
+	}
+	if len(report.EmailRenames) != 1 || report.EmailRenames[0].File != "kiro-aws-someone-else.json" {
+		t.Fatalf("EmailRenames = %+v, want only kiro-aws-someone-else.json", report.EmailRenames)
+	}
+}
+
+func TestRepairTokenDir(t *testing.T) {
+	dir := writeAuditTokenDir(t)
+	tmpPath := filepath.Join(dir, "kiro-builder-id-alice-example-com.json.tmp")
+
+	// A fresh temp file may belong to a write in progress and is kept.
+	result, err := RepairTokenDir(dir, RepairOptions{RemoveTempFiles: true})
+	if err != nil {
+		t.Fatalf("RepairTokenDir() error = %v", err)
+	}
+	if len(result.RemovedTemps) != 0 || len(result.Skipped) != 1 {
+		t.Fatalf("fresh temp: removed = %v skipped = %v, want it skipped", result.RemovedTemps, result.Skipped)
+	}
+
+	old := time.Now().Add(-time.Hour)
+	if errTimes := os.Chtimes(tmpPath, old, old); errTimes != nil {
+		t.Fatalf("chtimes: %v", errTimes)
+	}
+	result, err = RepairTokenDir(dir, RepairOptions{RemoveTempFiles: true, RenameToEmail: true})
+	if err != nil {
+		t.Fatalf("RepairTokenDir() error = %v", err)
+	}
+	if want := []string{"kiro-builder-id-alice-example-com.json.tmp"}; !reflect.DeepEqual(result.RemovedTemps, want) {
+		t.Errorf("RemovedTemps = %v, want %v", result.RemovedTemps, want)
+	}
+	if len(result.Renamed) != 1 || result.Renamed[0].Suggested != filepath.Join("kiro", "kiro-idc-bob-example-com.json") {
+		t.Errorf("Renamed = %+v, want the idc token renamed to its email name", result.Renamed)
+	}
+	if _, errStat := os.Stat(filepath.Join(dir, "kiro", "kiro-idc-bob-example-com.json")); errStat != nil {
+		t.Errorf("renamed token missing: %v", errStat)
+	}
+
+	after, err := AuditTokenDir(dir)
+	if err != nil {
+		t.Fatalf("AuditTokenDir() error = %v", err)
+	}
+	if len(after.OrphanedTemps) != 0 || len(after.EmailRenames) != 0 {
+		t.Errorf("after repair: temps = %v renames = %+v, want none", after.OrphanedTemps, after.EmailRenames)
+	}
+	// Duplicates and corrupt files need an operator and are left in place.
+	if len(after.Duplicates) != 1 || len(after.Unparseable) != 1 {
+		t.Errorf("after repair: duplicates = %+v unparseable = %v, want them untouched", after.Duplicates, after.Unparseable)
+	}
+}
+
+func TestRepairTokenDir_SkipsDuplicatedAccountRename(t *testing.T) {
+	dir := t.TempDir()
+	fields := map[string]any{"email": "erin@example.com", "client_id": "erin-client"}
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-builder-id-00001.json"), fields)
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-builder-id-00002.json"), fields)
+
+	result, err := RepairTokenDir(dir, RepairOptions{RenameToEmail: true})
+	if err != nil {
+		t.Fatalf("RepairTokenDir() error = %v", err)
+	}
+	if len(result.Renamed) != 0 || len(result.Skipped) != 2 {
+		t.Fatalf("renamed = %+v skipped = %v, want both renames skipped", result.Renamed, result.Skipped)
+	}
+}
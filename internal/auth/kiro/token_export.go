@@ -0,0 +1,195 @@
+package kiro
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// TokenExportVersion is the current version of the token export format.
+const TokenExportVersion = 1
+
+// ErrMaskedExport is returned by ImportTokens when the export was written with
+// masked secrets and therefore cannot restore working credentials.
+var ErrMaskedExport = errors.New("token export has masked secrets")
+
+// TokenLister is implemented by repositories that can enumerate every stored token.
+type TokenLister interface {
+	ListKiroTokens(ctx context.Context) ([]*Token, error)
+}
+
+// TokenExport is the document written by ExportTokens and read by ImportTokens.
+type TokenExport struct {
+	Version    int                 `json:"version"`
+	ExportedAt time.Time           `json:"exported_at"`
+	Masked     bool                `json:"masked"`
+	Tokens     []ExportedKiroToken `json:"tokens"`
+}
+
+// ExportedKiroToken is the serialized form of a Token inside a TokenExport.
+type ExportedKiroToken struct {
+	ID           string    `json:"id"`
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	LastVerified time.Time `json:"last_verified"`
+	ClientID     string    `json:"client_id,omitempty"`
+	ClientSecret string    `json:"client_secret,omitempty"`
+	AuthMethod   string    `json:"auth_method,omitempty"`
+	Provider     string    `json:"provider,omitempty"`
+	StartURL     string    `json:"start_url,omitempty"`
+	Region       string    `json:"region,omitempty"`
+
+	SecondaryStartURL     string `json:"secondary_start_url,omitempty"`
+	SecondaryRegion       string `json:"secondary_region,omitempty"`
+	SecondaryClientID     string `json:"secondary_client_id,omitempty"`
+	SecondaryClientSecret string `json:"secondary_client_secret,omitempty"`
+	SecondaryRefreshToken string `json:"secondary_refresh_token,omitempty"`
+}
+
+// ExportOption configures ExportTokens.
+type ExportOption func(*exportConfig)
+
+type exportConfig struct {
+	unmasked bool
+}
+
+// WithUnmaskedSecrets writes access tokens, refresh tokens and client secrets in
+// clear text. Only unmasked exports can be imported.
+func WithUnmaskedSecrets(enabled bool) ExportOption {
+	return func(c *exportConfig) {
+		c.unmasked = enabled
+	}
+}
+
+// ExportTokens writes every token in repo to w as a versioned JSON document.
+// Secrets are masked unless WithUnmaskedSecrets(true) is passed. The repository
+// must implement TokenLister.
+func ExportTokens(ctx context.Context, repo TokenRepository, w io.Writer, opts ...ExportOption) error {
+	cfg := exportConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	lister, ok := repo.(TokenLister)
+	if !ok {
+		return fmt.Errorf("export tokens: repository %T cannot list tokens", repo)
+	}
+	tokens, err := lister.ListKiroTokens(ctx)
+	if err != nil {
+		return fmt.Errorf("export tokens: list failed: %w", err)
+	}
+
+	export := TokenExport{
+		Version:    TokenExportVersion,
+		ExportedAt: time.Now().UTC(),
+		Masked:     !cfg.unmasked,
+		Tokens:     make([]ExportedKiroToken, 0, len(tokens)),
+	}
+	for _, token := range tokens {
+		if token == nil {
+			continue
+		}
+		entry := ExportedKiroToken{
+			ID:           token.ID,
+			AccessToken:  token.AccessToken,
+			RefreshToken: token.RefreshToken,
+			ExpiresAt:    token.ExpiresAt,
+			LastVerified: token.LastVerified,
+			ClientID:     token.ClientID,
+			ClientSecret: token.ClientSecret,
+			AuthMethod:   token.AuthMethod,
+			Provider:     token.Provider,
+			StartURL:     token.StartURL,
+			Region:       token.Region,
+
+			SecondaryStartURL:     token.SecondaryStartURL,
+			SecondaryRegion:       token.SecondaryRegion,
+			SecondaryClientID:     token.SecondaryClientID,
+			SecondaryClientSecret: token.SecondaryClientSecret,
+			SecondaryRefreshToken: token.SecondaryRefreshToken,
+		}
+		if export.Masked {
+			entry.AccessToken = util.HideAPIKey(entry.AccessToken)
+			entry.RefreshToken = util.HideAPIKey(entry.RefreshToken)
+			entry.ClientSecret = util.HideAPIKey(entry.ClientSecret)
+			entry.SecondaryClientSecret = util.HideAPIKey(entry.SecondaryClientSecret)
+			entry.SecondaryRefreshToken = util.HideAPIKey(entry.SecondaryRefreshToken)
+		}
+		export.Tokens = append(export.Tokens, entry)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(export); err != nil {
+		return fmt.Errorf("export tokens: encode failed: %w", err)
+	}
+	return nil
+}
+
+// ImportTokens reads a document written by ExportTokens from r and stores each
+// token through repo.UpdateToken. It returns the number of tokens imported.
+// Masked exports are rejected with ErrMaskedExport, and IDs that are not plain
+// kiro-*.json file names are rejected so an import cannot write outside the
+// token directory.
+func ImportTokens(ctx context.Context, repo TokenRepository, r io.Reader) (int, error) {
+	var export TokenExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return 0, fmt.Errorf("import tokens: decode failed: %w", err)
+	}
+	if export.Version != TokenExportVersion {
+		return 0, fmt.Errorf("import tokens: unsupported export version %d", export.Version)
+	}
+	if export.Masked {
+		return 0, fmt.Errorf("import tokens: %w", ErrMaskedExport)
+	}
+
+	imported := 0
+	for _, entry := range export.Tokens {
+		if err := ctx.Err(); err != nil {
+			return imported, fmt.Errorf("import tokens: %w", err)
+		}
+		if entry.ID == "" {
+			return imported, fmt.Errorf("import tokens: token at index %d has no id", imported)
+		}
+		if !isImportableTokenID(entry.ID) {
+			return imported, fmt.Errorf("import tokens: invalid token id %q", entry.ID)
+		}
+		token := &Token{
+			ID:           entry.ID,
+			AccessToken:  entry.AccessToken,
+			RefreshToken: entry.RefreshToken,
+			ExpiresAt:    entry.ExpiresAt,
+			LastVerified: entry.LastVerified,
+			ClientID:     entry.ClientID,
+			ClientSecret: entry.ClientSecret,
+			AuthMethod:   entry.AuthMethod,
+			Provider:     entry.Provider,
+			StartURL:     entry.StartURL,
+			Region:       entry.Region,
+
+			SecondaryStartURL:     entry.SecondaryStartURL,
+			SecondaryRegion:       entry.SecondaryRegion,
+			SecondaryClientID:     entry.SecondaryClientID,
+			SecondaryClientSecret: entry.SecondaryClientSecret,
+			SecondaryRefreshToken: entry.SecondaryRefreshToken,
+		}
+		if err := repo.UpdateToken(token); err != nil {
+			return imported, fmt.Errorf("import tokens: update %s failed: %w", entry.ID, err)
+		}
+		imported++
+	}
+	return imported, nil
+}
+
+// isImportableTokenID reports whether id is a bare kiro-*.json file name.
+func isImportableTokenID(id string) bool {
+	return filepath.Base(id) == id && strings.HasPrefix(id, "kiro-") && strings.HasSuffix(id, ".json")
+}
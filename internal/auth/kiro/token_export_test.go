@@ -0,0 +1,129 @@
+package kiro
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeExportFixtures(t *testing.T, dir string) {
+	t.Helper()
+	expires := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC).Format(time.RFC3339)
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-builder-id-alice.json"), map[string]any{
+		"access_token":  "alice-access-token-value",
+		"refresh_token": "alice-refresh-token-value",
+		"client_id":     "alice-client",
+		"client_secret": "alice-client-secret-value",
+		"expires_at":    expires,
+		"region":        "us-east-1",
+	})
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-idc-bob.json"), map[string]any{
+		"auth_method":   "idc",
+		"access_token":  "bob-access-token-value",
+		"refresh_token": "bob-refresh-token-value",
+		"client_id":     "bob-client",
+		"client_secret": "bob-client-secret-value",
+		"expires_at":    expires,
+		"region":        "eu-west-1",
+		"start_url":     "https://bob.awsapps.com/start",
+		"provider":      "Enterprise",
+	})
+}
+
+func sortedTokens(t *testing.T, repo *FileTokenRepository) []*Token {
+	t.Helper()
+	tokens, err := repo.ListKiroTokens(t.Context())
+	if err != nil {
+		t.Fatalf("ListKiroTokens() error = %v", err)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID < tokens[j].ID })
+	return tokens
+}
+
+func TestExportImportTokensRoundTrip(t *testing.T) {
+	srcDir := t.TempDir()
+	writeExportFixtures(t, srcDir)
+	src := NewFileTokenRepository(srcDir)
+
+	var buf bytes.Buffer
+	if err := ExportTokens(t.Context(), src, &buf, WithUnmaskedSecrets(true)); err != nil {
+		t.Fatalf("ExportTokens() error = %v", err)
+	}
+
+	dst := NewFileTokenRepository(t.TempDir())
+	n, err := ImportTokens(t.Context(), dst, &buf)
+	if err != nil {
+		t.Fatalf("ImportTokens() error = %v", err)
+	}
+
+	want := sortedTokens(t, src)
+	got := sortedTokens(t, dst)
+	if n != len(want) || len(got) != len(want) {
+		t.Fatalf("imported %d tokens, listed %d, want %d", n, len(got), len(want))
+	}
+	for i := range want {
+		// LastVerified is reset to the import time by UpdateToken.
+		want[i].LastVerified, got[i].LastVerified = time.Time{}, time.Time{}
+		if *got[i] != *want[i] {
+			t.Errorf("token %d = %+v, want %+v", i, *got[i], *want[i])
+		}
+	}
+}
+
+func TestExportTokensMasksSecretsByDefault(t *testing.T) {
+	dir := t.TempDir()
+	writeExportFixtures(t, dir)
+
+	var buf bytes.Buffer
+	if err := ExportTokens(t.Context(), NewFileTokenRepository(dir), &buf); err != nil {
+		t.Fatalf("ExportTokens() error = %v", err)
+	}
+	raw := buf.String()
+	for _, secret := range []string{"alice-access-token-value", "alice-refresh-token-value", "bob-client-secret-value"} {
+		if strings.Contains(raw, secret) {
+			t.Errorf("masked export contains secret %q", secret)
+		}
+	}
+
+	var export TokenExport
+	if err := json.Unmarshal(buf.Bytes(), &export); err != nil {
+		t.Fatalf("unmarshal export: %v", err)
+	}
+	if export.Version != TokenExportVersion || !export.Masked || len(export.Tokens) != 2 {
+		t.Fatalf("export header = version %d masked %v tokens %d", export.Version, export.Masked, len(export.Tokens))
+	}
+
+	_, err := ImportTokens(t.Context(), NewFileTokenRepository(t.TempDir()), &buf)
+	if !errors.Is(err, ErrMaskedExport) {
+		t.Fatalf("ImportTokens(masked) error = %v, want ErrMaskedExport", err)
+	}
+}
+
+func TestImportTokensRejectsUnknownVersion(t *testing.T) {
+	_, err := ImportTokens(t.Context(), &fakeTokenRepository{}, strings.NewReader(`{"version":99,"tokens":[]}`))
+	if err == nil || !strings.Contains(err.Error(), "unsupported export version") {
+		t.Fatalf("ImportTokens() error = %v, want unsupported version", err)
+	}
+}
+
+func TestImportTokensRejectsUnsafeIDs(t *testing.T) {
+	for _, id := range []string{"../../.ssh/x", "../kiro-evil.json", "sub/kiro-a.json", "kiro-a.txt", "token.json", ".."} {
+		t.Run(id, func(t *testing.T) {
+			repo := &fakeTokenRepository{}
+			quoted, _ := json.Marshal(id)
+			doc := `{"version":1,"tokens":[{"id":` + string(quoted) + `,"access_token":"a","refresh_token":"r"}]}`
+			n, err := ImportTokens(t.Context(), repo, strings.NewReader(doc))
+			if err == nil || !strings.Contains(err.Error(), "invalid token id") {
+				t.Fatalf("ImportTokens(%q) error = %v, want invalid token id", id, err)
+			}
+			if n != 0 || len(repo.updated) != 0 {
+				t.Fatalf("ImportTokens(%q) stored %d tokens (updated %d), want none", id, n, len(repo.updated))
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package kiro
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// maxTokenFileNameAttempts bounds the sequence suffixes tried on name collisions.
+const maxTokenFileNameAttempts = 1000
+
+// TokenFileNamer chooses the file name for a newly stored Kiro token.
+// Names are sanitized by FileTokenRepository regardless of the namer.
+type TokenFileNamer interface {
+	TokenFileName(tokenData *KiroTokenData) string
+}
+
+// EmailBasedNamer names files kiro-{authMethod}-{email}.json, falling back to
+// the IDC identifier or auth method. It is the default namer.
+type EmailBasedNamer struct{}
+
+// TokenFileName implements TokenFileNamer using GenerateTokenFileName.
+func (EmailBasedNamer) TokenFileName(tokenData *KiroTokenData) string {
+	return GenerateTokenFileName(tokenData)
+}
+
+// AccountKeyNamer names files kiro-{accountKey}.json, where the account key is
+// derived from the client ID or refresh token via GetAccountKey. The kiro-
+// prefix keeps the files visible to the repository's token scans.
+type AccountKeyNamer struct{}
+
+// TokenFileName implements TokenFileNamer using the token's account key.
+func (AccountKeyNamer) TokenFileName(tokenData *KiroTokenData) string {
+	return "kiro-" + GetAccountKey(tokenData.ClientID, tokenData.RefreshToken) + ".json"
+}
+
+// Namer names accepted by TokenFileNamerByName.
+const (
+	TokenFileNamingEmail      = "email"
+	TokenFileNamingAccountKey = "account-key"
+)
+
+// TokenFileNamerByName returns the namer for a kiro-token-file-naming value. An
+// empty name selects EmailBasedNamer; ok is false for unknown names.
+func TokenFileNamerByName(name string) (namer TokenFileNamer, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", TokenFileNamingEmail:
+		return EmailBasedNamer{}, true
+	case TokenFileNamingAccountKey:
+		return AccountKeyNamer{}, true
+	}
+	return EmailBasedNamer{}, false
+}
+
+// WithTokenFileNamer sets the naming strategy used by TokenFileName.
+func WithTokenFileNamer(namer TokenFileNamer) TokenRepositoryOption {
+	return func(r *FileTokenRepository) {
+		r.namer = namer
+	}
+}
+
+// TokenFileName returns a sanitized file name for tokenData that does not collide
+// with an existing token file. On collision a numeric sequence suffix is added
+// before the extension (kiro-x-1.json, kiro-x-2.json, ...).
+func (r *FileTokenRepository) TokenFileName(tokenData *KiroTokenData) (string, error) {
+	if tokenData == nil {
+		return "", fmt.Errorf("token repository: token data is nil")
+	}
+
+	r.mu.RLock()
+	baseDir := r.baseDir
+	r.mu.RUnlock()
+
+	stem := r.tokenFileStem(tokenData)
+	if baseDir == "" {
+		return stem + ".json", nil
+	}
+
+	for seq := 0; seq < maxTokenFileNameAttempts; seq++ {
+		name := stem + ".json"
+		if seq > 0 {
+			name = fmt.Sprintf("%s-%d.json", stem, seq)
+		}
+		_, found, err := r.lookupTokenFile(baseDir, name)
+		if err != nil {
+			return "", err
+		}
+		if !found {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("token repository: no free file name for %s", stem)
+}
+
+// BaseTokenFileName returns the sanitized name the namer gives tokenData, without
+// the collision suffix. Saves that should overwrite the account's previous file
+// on a re-login use it instead of TokenFileName.
+func (r *FileTokenRepository) BaseTokenFileName(tokenData *KiroTokenData) string {
+	return r.tokenFileStem(tokenData) + ".json"
+}
+
+func (r *FileTokenRepository) tokenFileStem(tokenData *KiroTokenData) string {
+	r.mu.RLock()
+	namer := r.namer
+	r.mu.RUnlock()
+	if namer == nil {
+		namer = EmailBasedNamer{}
+	}
+	return sanitizeTokenFileStem(namer.TokenFileName(tokenData))
+}
+
+// sanitizeTokenFileStem strips directories and the .json extension from name and
+// replaces characters that are unsafe in file names.
+func sanitizeTokenFileStem(name string) string {
+	name = filepath.Base(filepath.ToSlash(strings.TrimSpace(name)))
+	name = strings.TrimSuffix(name, ".json")
+	stem := strings.Trim(sanitizeFileNamePart(name), "-")
+	if stem == "" {
+		return "kiro-token"
+	}
+	return stem
+}
+
+// sanitizeFileNamePart keeps letters, digits, '_', '+' and '-', replacing every
+// other character (including '@', '.' and path separators) with '-'.
+func sanitizeFileNamePart(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == '_' || r == '+' || r == '-':
+			return r
+		default:
+			return '-'
+		}
+	}, s)
+}
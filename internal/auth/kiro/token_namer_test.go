@@ -0,0 +1,114 @@
+package kiro
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEmailBasedNamer_SpecialCharacters(t *testing.T) {
+	repo := NewFileTokenRepository(t.TempDir())
+	name, err := repo.TokenFileName(&KiroTokenData{AuthMethod: "idc", Email: "../evil/al ice+tag@ex.com"})
+	if err != nil {
+		t.Fatalf("TokenFileName() error = %v", err)
+	}
+	if want := "kiro-idc----evil-al-ice+tag-ex-com.json"; name != want {
+		t.Fatalf("TokenFileName() = %q, want %q", name, want)
+	}
+	if strings.ContainsAny(name, `/\`) {
+		t.Fatalf("file name %q contains a path separator", name)
+	}
+}
+
+func TestAccountKeyNamer(t *testing.T) {
+	repo := NewFileTokenRepository(t.TempDir(), WithTokenFileNamer(AccountKeyNamer{}))
+	name, err := repo.TokenFileName(&KiroTokenData{ClientID: "client-1", Email: "user@example.com"})
+	if err != nil {
+		t.Fatalf("TokenFileName() error = %v", err)
+	}
+	if want := "kiro-" + GenerateAccountKey("client-1") + ".json"; name != want {
+		t.Fatalf("TokenFileName() = %q, want %q", name, want)
+	}
+}
+
+func TestAccountKeyNamerFilesAreListed(t *testing.T) {
+	dir := t.TempDir()
+	repo := NewFileTokenRepository(dir, WithTokenFileNamer(AccountKeyNamer{}))
+	name, err := repo.TokenFileName(&KiroTokenData{ClientID: "client-1"})
+	if err != nil {
+		t.Fatalf("TokenFileName() error = %v", err)
+	}
+	writeTestTokenFile(t, filepath.Join(dir, name), nil)
+
+	listed, err := repo.ListKiroTokens(context.Background())
+	if err != nil {
+		t.Fatalf("ListKiroTokens() error = %v", err)
+	}
+	if len(listed) != 1 || listed[0].ID != name {
+		t.Fatalf("ListKiroTokens() = %v, want %s", listed, name)
+	}
+	if found := repo.FindOldestUnverified(0); len(found) != 1 || found[0].ID != name {
+		t.Fatalf("FindOldestUnverified() = %v, want %s", found, name)
+	}
+}
+
+type fixedNamer string
+
+func (n fixedNamer) TokenFileName(*KiroTokenData) string { return string(n) }
+
+func TestTokenFileName_SanitizesCustomNamer(t *testing.T) {
+	repo := NewFileTokenRepository(t.TempDir(), WithTokenFileNamer(fixedNamer("../../etc/pass:wd.json")))
+	name, err := repo.TokenFileName(&KiroTokenData{})
+	if err != nil {
+		t.Fatalf("TokenFileName() error = %v", err)
+	}
+	if name != "pass-wd.json" {
+		t.Fatalf("TokenFileName() = %q, want pass-wd.json", name)
+	}
+}
+
+func TestTokenFileName_CollisionAddsSequenceSuffix(t *testing.T) {
+	for _, subdir := range []bool{false, true} {
+		dir := t.TempDir()
+		repo := NewFileTokenRepository(dir, WithSubdirectoryByProvider(subdir), WithTokenFileNamer(AccountKeyNamer{}))
+		data := &KiroTokenData{ClientID: "client-1"}
+		key := "kiro-" + GenerateAccountKey("client-1")
+
+		var got []string
+		for i := 0; i < 3; i++ {
+			name, err := repo.TokenFileName(data)
+			if err != nil {
+				t.Fatalf("TokenFileName() error = %v", err)
+			}
+			got = append(got, name)
+			path := filepath.Join(dir, name)
+			if subdir {
+				path = filepath.Join(dir, defaultTokenProvider, name)
+			}
+			writeTestTokenFile(t, path, nil)
+		}
+
+		want := []string{key + ".json", key + "-1.json", key + "-2.json"}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("subdir=%v names = %v, want %v", subdir, got, want)
+			}
+		}
+	}
+}
+
+func TestTokenFileNamerByName(t *testing.T) {
+	for name, want := range map[string]TokenFileNamer{
+		"":            EmailBasedNamer{},
+		"email":       EmailBasedNamer{},
+		"Account-Key": AccountKeyNamer{},
+	} {
+		if got, ok := TokenFileNamerByName(name); !ok || got != want {
+			t.Errorf("TokenFileNamerByName(%q) = %T, %v", name, got, ok)
+		}
+	}
+	if _, ok := TokenFileNamerByName("nested"); ok {
+		t.Error("TokenFileNamerByName(nested) ok = true, want false")
+	}
+}
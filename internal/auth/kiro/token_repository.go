@@ -15,17 +15,44 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// defaultTokenProvider is the provider subdirectory used for new Kiro token files.
+const defaultTokenProvider = "kiro"
+
 // FileTokenRepository 实现 TokenRepository 接口，基于文件系统存储
 type FileTokenRepository struct {
-	mu      sync.RWMutex
-	baseDir string
+	mu               sync.RWMutex
+	baseDir          string
+	subdirByProvider bool           // store files under {baseDir}/{provider}/ instead of flat
+	namer            TokenFileNamer // names new token files; EmailBasedNamer when nil
+
+	migrateMu   sync.Mutex // serializes flat-file migration
+	migratedDir string     // base directory whose flat files were already migrated
+
+	pathMu    sync.Mutex
+	pathCache map[string]string // flat path -> resolved file, for provider-subdirectory mode
+}
+
+// TokenRepositoryOption configures a FileTokenRepository.
+type TokenRepositoryOption func(*FileTokenRepository)
+
+// WithSubdirectoryByProvider stores token files under {baseDir}/{provider}/, where
+// provider is the token JSON "type" field. Flat files found in baseDir are moved
+// into their provider subdirectory on first access.
+func WithSubdirectoryByProvider(enabled bool) TokenRepositoryOption {
+	return func(r *FileTokenRepository) {
+		r.subdirByProvider = enabled
+	}
 }
 
 // NewFileTokenRepository 创建一个新的文件 token 存储库
-func NewFileTokenRepository(baseDir string) *FileTokenRepository {
-	return &FileTokenRepository{
+func NewFileTokenRepository(baseDir string, opts ...TokenRepositoryOption) *FileTokenRepository {
+	r := &FileTokenRepository{
 		baseDir: baseDir,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
 // SetBaseDir 设置基础目录
@@ -33,6 +60,7 @@ func (r *FileTokenRepository) SetBaseDir(dir string) {
 	r.mu.Lock()
 	r.baseDir = strings.TrimSpace(dir)
 	r.mu.Unlock()
+	r.resetPathCache()
 }
 
 // FindOldestUnverified 查找需要刷新的 token（按最后验证时间排序）
@@ -46,7 +74,10 @@ func (r *FileTokenRepository) FindOldestUnverified(limit int) []*Token {
 		return nil
 	}
 
+	r.ensureMigrated(baseDir)
+
 	var tokens []*Token
+	now := time.Now()
 
 	err := filepath.WalkDir(baseDir, func(path string, d fs.DirEntry, walkErr error) error {
 		if walkErr != nil {
@@ -71,6 +102,10 @@ func (r *FileTokenRepository) FindOldestUnverified(limit int) []*Token {
 		}
 
 		if token != nil && token.RefreshToken != "" {
+			// Skip tokens still in refresh backoff so they don't use up the limit
+			if now.Before(token.NextEligibleAt) {
+				return nil
+			}
 			// 检查 token 是否需要刷新（过期前 5 分钟）
 			if token.ExpiresAt.IsZero() || time.Until(token.ExpiresAt) < 5*time.Minute {
 				tokens = append(tokens, token)
@@ -112,9 +147,9 @@ func (r *FileTokenRepository) UpdateToken(token *Token) error {
 	}
 
 	// 构建文件路径
-	filePath := filepath.Join(baseDir, token.ID)
-	if !strings.HasSuffix(filePath, ".json") {
-		filePath += ".json"
+	filePath, err := r.tokenFilePath(baseDir, token.ID)
+	if err != nil {
+		return err
 	}
 
 	// 读取现有文件内容
@@ -123,6 +158,11 @@ func (r *FileTokenRepository) UpdateToken(token *Token) error {
 		_ = json.Unmarshal(data, &existingData)
 	}
 
+	// New files need the type marker so readTokenFile recognizes them
+	if _, ok := existingData["type"]; !ok {
+		existingData["type"] = defaultTokenProvider
+	}
+
 	// 更新字段
 	existingData["access_token"] = token.AccessToken
 	existingData["refresh_token"] = token.RefreshToken
@@ -148,9 +188,90 @@ func (r *FileTokenRepository) UpdateToken(token *Token) error {
 	if token.StartURL != "" {
 		existingData["start_url"] = token.StartURL
 	}
+	if token.SecondaryStartURL != "" {
+		existingData["secondary_start_url"] = token.SecondaryStartURL
+	}
+	if token.SecondaryRegion != "" {
+		existingData["secondary_region"] = token.SecondaryRegion
+	}
+	if token.SecondaryClientID != "" {
+		existingData["secondary_client_id"] = token.SecondaryClientID
+	}
+	if token.SecondaryClientSecret != "" {
+		existingData["secondary_client_secret"] = token.SecondaryClientSecret
+	}
+	if token.SecondaryRefreshToken != "" {
+		existingData["secondary_refresh_token"] = token.SecondaryRefreshToken
+	}
+	if token.Provider != "" {
+		existingData["provider"] = token.Provider
+	}
+	setRefreshBackoffFields(existingData, token)
+
+	if err := writeTokenFileAtomic(filePath, existingData); err != nil {
+		return err
+	}
+
+	log.Debugf("token repository: updated token %s", token.ID)
+	return nil
+}
+
+// UpdateRefreshBackoff persists only the refresh backoff fields of token, leaving
+// its credentials and last_refresh untouched. It implements RefreshBackoffStore.
+func (r *FileTokenRepository) UpdateRefreshBackoff(token *Token) error {
+	if token == nil {
+		return fmt.Errorf("token repository: token is nil")
+	}
+
+	r.mu.RLock()
+	baseDir := r.baseDir
+	r.mu.RUnlock()
+
+	if baseDir == "" {
+		return fmt.Errorf("token repository: base directory not configured")
+	}
 
+	filePath, err := r.tokenFilePath(baseDir, token.ID)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("token repository: read token file failed: %w", err)
+	}
+	existingData := make(map[string]any)
+	if err := json.Unmarshal(data, &existingData); err != nil {
+		return fmt.Errorf("token repository: parse token file failed: %w", err)
+	}
+
+	setRefreshBackoffFields(existingData, token)
+	return writeTokenFileAtomic(filePath, existingData)
+}
+
+// setRefreshBackoffFields copies token's backoff bookkeeping into a token file's
+// fields, removing the ones that are unset.
+func setRefreshBackoffFields(data map[string]any, token *Token) {
+	if token.LastRefreshAttempt.IsZero() {
+		delete(data, "last_refresh_attempt")
+	} else {
+		data["last_refresh_attempt"] = token.LastRefreshAttempt.Format(time.RFC3339)
+	}
+	if token.ConsecutiveFailures > 0 {
+		data["consecutive_failures"] = token.ConsecutiveFailures
+	} else {
+		delete(data, "consecutive_failures")
+	}
+	if token.NextEligibleAt.IsZero() {
+		delete(data, "next_eligible_at")
+	} else {
+		data["next_eligible_at"] = token.NextEligibleAt.Format(time.RFC3339)
+	}
+}
+
+// writeTokenFileAtomic writes data to filePath through a temp file and rename.
+func writeTokenFileAtomic(filePath string, data map[string]any) error {
 	// 序列化并写入文件
-	raw, err := json.MarshalIndent(existingData, "", "  ")
+	raw, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
 		return fmt.Errorf("token repository: marshal failed: %w", err)
 	}
@@ -164,11 +285,210 @@ func (r *FileTokenRepository) UpdateToken(token *Token) error {
 		_ = os.Remove(tmpPath)
 		return fmt.Errorf("token repository: rename failed: %w", err)
 	}
-
-	log.Debugf("token repository: updated token %s", token.ID)
 	return nil
 }
 
+// Get loads the token stored under tokenID without modifying the directory. It
+// returns an error if the file is missing, corrupt, not a Kiro token, or lacks any
+// field required by Validate.
+func (r *FileTokenRepository) Get(tokenID string) (*KiroTokenData, error) {
+	r.mu.RLock()
+	baseDir := r.baseDir
+	r.mu.RUnlock()
+
+	if baseDir == "" {
+		return nil, fmt.Errorf("token repository: base directory not configured")
+	}
+
+	filePath, _, err := r.lookupTokenFile(baseDir, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	storage, err := LoadFromFile(filePath)
+	if err != nil {
+		r.forgetTokenFile(baseDir, tokenID)
+		return nil, fmt.Errorf("token repository: %w", err)
+	}
+	if storage.Type != defaultTokenProvider {
+		return nil, fmt.Errorf("token repository: %s is not a kiro token", tokenID)
+	}
+
+	tokenData := storage.ToTokenData()
+	if missing := tokenData.Validate(); len(missing) > 0 {
+		return nil, fmt.Errorf("token repository: token %s is missing required fields: %s", tokenID, strings.Join(missing, ", "))
+	}
+	return tokenData, nil
+}
+
+// tokenFilePath resolves the file backing tokenID for a write. In provider-subdirectory
+// mode it returns the existing file in any provider subdirectory, or a path under the
+// default provider for new tokens, creating the subdirectory as needed.
+func (r *FileTokenRepository) tokenFilePath(baseDir, tokenID string) (string, error) {
+	if r.subdirByProvider {
+		r.ensureMigrated(baseDir)
+	}
+	path, found, err := r.lookupTokenFile(baseDir, tokenID)
+	if err != nil || found || !r.subdirByProvider {
+		return path, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", fmt.Errorf("token repository: create provider directory failed: %w", err)
+	}
+	return path, nil
+}
+
+// lookupTokenFile resolves the file backing tokenID without modifying the directory.
+// In provider-subdirectory mode it looks in every provider subdirectory and then for
+// a flat file that has not been migrated yet, caching the result so repeated updates
+// of the same token skip the scan. When no file exists, found is false and path is
+// where a new token would be stored.
+func (r *FileTokenRepository) lookupTokenFile(baseDir, tokenID string) (path string, found bool, err error) {
+	flatPath := flatTokenPath(baseDir, tokenID)
+	if !r.subdirByProvider {
+		_, errStat := os.Stat(flatPath)
+		return flatPath, errStat == nil, nil
+	}
+
+	r.pathMu.Lock()
+	cached, ok := r.pathCache[flatPath]
+	r.pathMu.Unlock()
+	if ok {
+		return cached, true, nil
+	}
+
+	path, found, err = r.scanTokenFile(baseDir, filepath.Base(flatPath))
+	if err != nil || !found {
+		return path, found, err
+	}
+	r.pathMu.Lock()
+	if r.pathCache == nil {
+		r.pathCache = make(map[string]string)
+	}
+	r.pathCache[flatPath] = path
+	r.pathMu.Unlock()
+	return path, true, nil
+}
+
+// scanTokenFile looks for name in every provider subdirectory of baseDir and then
+// in baseDir itself.
+func (r *FileTokenRepository) scanTokenFile(baseDir, name string) (string, bool, error) {
+	entries, err := os.ReadDir(baseDir)
+	if err != nil && !os.IsNotExist(err) {
+		return "", false, fmt.Errorf("token repository: read base directory failed: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		candidate := filepath.Join(baseDir, entry.Name(), name)
+		if _, errStat := os.Stat(candidate); errStat == nil {
+			return candidate, true, nil
+		}
+	}
+	flatPath := filepath.Join(baseDir, name)
+	if _, errStat := os.Stat(flatPath); errStat == nil {
+		return flatPath, true, nil
+	}
+	return filepath.Join(baseDir, defaultTokenProvider, name), false, nil
+}
+
+// forgetTokenFile drops the cached location of tokenID, e.g. after its file
+// could not be read.
+func (r *FileTokenRepository) forgetTokenFile(baseDir, tokenID string) {
+	r.pathMu.Lock()
+	delete(r.pathCache, flatTokenPath(baseDir, tokenID))
+	r.pathMu.Unlock()
+}
+
+// resetPathCache drops every cached token file location.
+func (r *FileTokenRepository) resetPathCache() {
+	r.pathMu.Lock()
+	r.pathCache = nil
+	r.pathMu.Unlock()
+}
+
+// flatTokenPath returns the flat-layout path of tokenID in baseDir.
+func flatTokenPath(baseDir, tokenID string) string {
+	name := tokenID
+	if !strings.HasSuffix(name, ".json") {
+		name += ".json"
+	}
+	return filepath.Join(baseDir, name)
+}
+
+// ensureMigrated moves flat token files in baseDir into the subdirectory named by
+// their "type" field, once per base directory, when provider-subdirectory mode is
+// enabled. Files without a usable type stay in place.
+func (r *FileTokenRepository) ensureMigrated(baseDir string) {
+	if !r.subdirByProvider {
+		return
+	}
+
+	r.migrateMu.Lock()
+	defer r.migrateMu.Unlock()
+	if r.migratedDir == baseDir {
+		return
+	}
+
+	entries, err := os.ReadDir(baseDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("token repository: failed to read %s for migration: %v", baseDir, err)
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(strings.ToLower(name), ".json") {
+			continue
+		}
+		flatPath := filepath.Join(baseDir, name)
+		provider := readTokenProvider(flatPath)
+		if provider == "" {
+			continue
+		}
+
+		targetDir := filepath.Join(baseDir, provider)
+		if errMkdir := os.MkdirAll(targetDir, 0o700); errMkdir != nil {
+			log.Warnf("token repository: failed to create %s: %v", targetDir, errMkdir)
+			continue
+		}
+		targetPath := filepath.Join(targetDir, name)
+		if _, errStat := os.Stat(targetPath); errStat == nil {
+			log.Warnf("token repository: %s already exists, leaving flat file %s in place", targetPath, flatPath)
+			continue
+		}
+		if errRename := os.Rename(flatPath, targetPath); errRename != nil {
+			log.Warnf("token repository: failed to migrate %s: %v", flatPath, errRename)
+			continue
+		}
+		log.Debugf("token repository: migrated %s to %s", flatPath, targetPath)
+	}
+
+	r.migratedDir = baseDir
+	r.resetPathCache()
+}
+
+// readTokenProvider returns the sanitized "type" field of a token file, or ""
+// when the file cannot be read or the type is not a safe directory name.
+func readTokenProvider(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	var metadata map[string]any
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return ""
+	}
+	provider, _ := metadata["type"].(string)
+	provider = strings.TrimSpace(provider)
+	if provider == "" || provider == "." || provider == ".." || strings.ContainsAny(provider, `/\`) {
+		return ""
+	}
+	return provider
+}
+
 // readTokenFile 从文件读取 token
 func (r *FileTokenRepository) readTokenFile(path string) (*Token, error) {
 	data, err := os.ReadFile(path)
@@ -206,7 +526,13 @@ func (r *FileTokenRepository) readTokenFile(path string) (*Token, error) {
 	token.ClientSecret, _ = metadata["client_secret"].(string)
 	token.Region, _ = metadata["region"].(string)
 	token.StartURL, _ = metadata["start_url"].(string)
+	token.SecondaryStartURL, _ = metadata["secondary_start_url"].(string)
+	token.SecondaryRegion, _ = metadata["secondary_region"].(string)
+	token.SecondaryClientID, _ = metadata["secondary_client_id"].(string)
+	token.SecondaryClientSecret, _ = metadata["secondary_client_secret"].(string)
+	token.SecondaryRefreshToken, _ = metadata["secondary_refresh_token"].(string)
 	token.Provider, _ = metadata["provider"].(string)
+	token.Email, _ = metadata["email"].(string)
 
 	// 解析时间字段
 	if expiresAtStr, ok := metadata["expires_at"].(string); ok && expiresAtStr != "" {
@@ -219,6 +545,19 @@ func (r *FileTokenRepository) readTokenFile(path string) (*Token, error) {
 			token.LastVerified = t
 		}
 	}
+	if attemptStr, ok := metadata["last_refresh_attempt"].(string); ok && attemptStr != "" {
+		if t, err := time.Parse(time.RFC3339, attemptStr); err == nil {
+			token.LastRefreshAttempt = t
+		}
+	}
+	if failures, ok := metadata["consecutive_failures"].(float64); ok && failures > 0 {
+		token.ConsecutiveFailures = int(failures)
+	}
+	if eligibleStr, ok := metadata["next_eligible_at"].(string); ok && eligibleStr != "" {
+		if t, err := time.Parse(time.RFC3339, eligibleStr); err == nil {
+			token.NextEligibleAt = t
+		}
+	}
 
 	return token, nil
 }
@@ -233,6 +572,8 @@ func (r *FileTokenRepository) ListKiroTokens(ctx context.Context) ([]*Token, err
 		return nil, fmt.Errorf("token repository: base directory not configured")
 	}
 
+	r.ensureMigrated(baseDir)
+
 	var tokens []*Token
 
 	err := filepath.WalkDir(baseDir, func(path string, d fs.DirEntry, walkErr error) error {
@@ -0,0 +1,307 @@
+package kiro
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestTokenFile(t *testing.T, path string, fields map[string]any) {
+	t.Helper()
+	data := map[string]any{
+		"type":          "kiro",
+		"auth_method":   "builder-id",
+		"access_token":  "access-token",
+		"refresh_token": "refresh-token",
+		"expires_at":    time.Now().Add(-time.Minute).Format(time.RFC3339),
+	}
+	for k, v := range fields {
+		data[k] = v
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		t.Fatalf("marshal token: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatalf("create dir: %v", err)
+	}
+	if err := os.WriteFile(path, raw, 0o600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+}
+
+func readTestTokenFile(t *testing.T, path string) map[string]any {
+	t.Helper()
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read token: %v", err)
+	}
+	var data map[string]any
+	if err := json.Unmarshal(raw, &data); err != nil {
+		t.Fatalf("unmarshal token: %v", err)
+	}
+	return data
+}
+
+func TestFileTokenRepositoryFlatReadWrite(t *testing.T) {
+	dir := t.TempDir()
+	flatPath := filepath.Join(dir, "kiro-builder-id-flat.json")
+	writeTestTokenFile(t, flatPath, nil)
+
+	repo := NewFileTokenRepository(dir)
+	tokens := repo.FindOldestUnverified(10)
+	if len(tokens) != 1 || tokens[0].ID != "kiro-builder-id-flat.json" {
+		t.Fatalf("FindOldestUnverified() = %v, want the flat token", tokens)
+	}
+
+	tokens[0].AccessToken = "new-access-token"
+	if err := repo.UpdateToken(tokens[0]); err != nil {
+		t.Fatalf("UpdateToken() error = %v", err)
+	}
+	if got := readTestTokenFile(t, flatPath)["access_token"]; got != "new-access-token" {
+		t.Fatalf("access_token = %v, want new-access-token", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, defaultTokenProvider)); !os.IsNotExist(err) {
+		t.Fatalf("flat mode created a provider subdirectory (stat err = %v)", err)
+	}
+}
+
+func TestFileTokenRepositorySkipsBackoffBeforeLimit(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-builder-id-backoff.json"), map[string]any{
+		"last_refresh":     time.Now().Add(-2 * time.Hour).Format(time.RFC3339),
+		"next_eligible_at": time.Now().Add(time.Hour).Format(time.RFC3339),
+	})
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-builder-id-ready.json"), map[string]any{
+		"last_refresh": time.Now().Add(-time.Hour).Format(time.RFC3339),
+	})
+
+	repo := NewFileTokenRepository(dir)
+	tokens := repo.FindOldestUnverified(1)
+	if len(tokens) != 1 || tokens[0].ID != "kiro-builder-id-ready.json" {
+		t.Fatalf("FindOldestUnverified(1) = %v, want the token outside backoff", tokens)
+	}
+}
+
+func TestFileTokenRepositoryKeepsSecondaryClient(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "kiro-idc-failover.json")
+	writeTestTokenFile(t, path, map[string]any{"auth_method": "idc", "secondary_start_url": "https://secondary-org.awsapps.com/start"})
+
+	repo := NewFileTokenRepository(dir)
+	tokens := repo.FindOldestUnverified(10)
+	if len(tokens) != 1 {
+		t.Fatalf("FindOldestUnverified() = %v, want one token", tokens)
+	}
+	tokens[0].SecondaryClientID = "secondary-client"
+	tokens[0].SecondaryClientSecret = "secondary-secret"
+	if err := repo.UpdateToken(tokens[0]); err != nil {
+		t.Fatalf("UpdateToken() error = %v", err)
+	}
+
+	reread := repo.FindOldestUnverified(10)
+	if len(reread) != 1 || reread[0].SecondaryClientID != "secondary-client" || reread[0].SecondaryClientSecret != "secondary-secret" {
+		t.Fatalf("secondary client after round trip = %+v", reread)
+	}
+}
+
+func TestFileTokenRepositorySubdirectoryReadWrite(t *testing.T) {
+	dir := t.TempDir()
+	nestedPath := filepath.Join(dir, "kiro", "kiro-idc-nested.json")
+	writeTestTokenFile(t, nestedPath, map[string]any{"auth_method": "idc"})
+
+	repo := NewFileTokenRepository(dir, WithSubdirectoryByProvider(true))
+	tokens, err := repo.ListKiroTokens(t.Context())
+	if err != nil {
+		t.Fatalf("ListKiroTokens() error = %v", err)
+	}
+	if len(tokens) != 1 || tokens[0].ID != "kiro-idc-nested.json" {
+		t.Fatalf("ListKiroTokens() = %v, want the nested token", tokens)
+	}
+
+	tokens[0].AccessToken = "new-access-token"
+	if err := repo.UpdateToken(tokens[0]); err != nil {
+		t.Fatalf("UpdateToken() error = %v", err)
+	}
+	if got := readTestTokenFile(t, nestedPath)["access_token"]; got != "new-access-token" {
+		t.Fatalf("access_token = %v, want new-access-token", got)
+	}
+
+	if err := repo.UpdateToken(&Token{ID: "kiro-builder-id-new.json", AccessToken: "fresh"}); err != nil {
+		t.Fatalf("UpdateToken(new) error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "kiro", "kiro-builder-id-new.json")); err != nil {
+		t.Fatalf("new token not written under provider subdirectory: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "kiro-builder-id-new.json")); !os.IsNotExist(err) {
+		t.Fatalf("new token written flat in subdirectory mode (stat err = %v)", err)
+	}
+}
+
+func TestFileTokenRepositoryMigratesFlatFiles(t *testing.T) {
+	dir := t.TempDir()
+	flatPath := filepath.Join(dir, "kiro-builder-id-legacy.json")
+	writeTestTokenFile(t, flatPath, nil)
+	otherPath := filepath.Join(dir, "user@example.com.json")
+	writeTestTokenFile(t, otherPath, map[string]any{"type": "codex"})
+	untypedPath := filepath.Join(dir, "settings.json")
+	writeTestTokenFile(t, untypedPath, map[string]any{"type": "../escape"})
+
+	repo := NewFileTokenRepository(dir, WithSubdirectoryByProvider(true))
+	tokens := repo.FindOldestUnverified(10)
+	if len(tokens) != 1 || tokens[0].ID != "kiro-builder-id-legacy.json" {
+		t.Fatalf("FindOldestUnverified() = %v, want the migrated token", tokens)
+	}
+
+	if _, err := os.Stat(flatPath); !os.IsNotExist(err) {
+		t.Fatalf("flat file still present after migration (stat err = %v)", err)
+	}
+	migratedPath := filepath.Join(dir, "kiro", "kiro-builder-id-legacy.json")
+	if got := readTestTokenFile(t, migratedPath)["refresh_token"]; got != "refresh-token" {
+		t.Fatalf("migrated refresh_token = %v, want refresh-token", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "codex", "user@example.com.json")); err != nil {
+		t.Fatalf("codex token not migrated to its provider directory: %v", err)
+	}
+	if _, err := os.Stat(untypedPath); err != nil {
+		t.Fatalf("file with an unsafe type was moved: %v", err)
+	}
+
+	tokens[0].AccessToken = "new-access-token"
+	if err := repo.UpdateToken(tokens[0]); err != nil {
+		t.Fatalf("UpdateToken() error = %v", err)
+	}
+	if got := readTestTokenFile(t, migratedPath)["access_token"]; got != "new-access-token" {
+		t.Fatalf("access_token = %v, want new-access-token", got)
+	}
+	if _, err := os.Stat(flatPath); !os.IsNotExist(err) {
+		t.Fatalf("UpdateToken recreated the flat file (stat err = %v)", err)
+	}
+}
+
+func TestFileTokenRepositoryCachesSubdirectoryLookup(t *testing.T) {
+	dir := t.TempDir()
+	nestedPath := filepath.Join(dir, "kiro", "kiro-idc-cached.json")
+	writeTestTokenFile(t, nestedPath, nil)
+
+	repo := NewFileTokenRepository(dir, WithSubdirectoryByProvider(true))
+	if err := repo.UpdateToken(&Token{ID: "kiro-idc-cached.json", AccessToken: "first"}); err != nil {
+		t.Fatalf("UpdateToken() error = %v", err)
+	}
+	// A second provider directory holding the same name must not be picked up
+	// once the first lookup is cached.
+	writeTestTokenFile(t, filepath.Join(dir, "aaa", "kiro-idc-cached.json"), nil)
+	if err := repo.UpdateToken(&Token{ID: "kiro-idc-cached.json", AccessToken: "second"}); err != nil {
+		t.Fatalf("UpdateToken() error = %v", err)
+	}
+	if got := readTestTokenFile(t, nestedPath)["access_token"]; got != "second" {
+		t.Fatalf("access_token = %v, want second", got)
+	}
+
+	repo.SetBaseDir(dir)
+	if err := repo.UpdateToken(&Token{ID: "kiro-idc-cached.json", AccessToken: "third"}); err != nil {
+		t.Fatalf("UpdateToken() error = %v", err)
+	}
+	if got := readTestTokenFile(t, filepath.Join(dir, "aaa", "kiro-idc-cached.json"))["access_token"]; got != "third" {
+		t.Fatalf("access_token after SetBaseDir = %v, want a fresh lookup", got)
+	}
+}
+
+func TestFileTokenRepositoryGetValidatesFields(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-valid.json"), map[string]any{"expires_at": "2030-01-01T00:00:00Z"})
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-truncated.json"), map[string]any{"refresh_token": "", "auth_method": "", "expires_at": ""})
+	if err := os.WriteFile(filepath.Join(dir, "kiro-corrupt.json"), []byte(`{"type":"kiro","access_`), 0o600); err != nil {
+		t.Fatalf("write corrupt token: %v", err)
+	}
+	repo := NewFileTokenRepository(dir)
+
+	token, err := repo.Get("kiro-valid.json")
+	if err != nil {
+		t.Fatalf("Get(valid) error = %v", err)
+	}
+	if token.AccessToken != "access-token" || token.AuthMethod != "builder-id" {
+		t.Fatalf("Get(valid) = %+v", token)
+	}
+
+	_, err = repo.Get("kiro-truncated.json")
+	if err == nil {
+		t.Fatal("Get(truncated) expected error")
+	}
+	for _, field := range []string{"RefreshToken", "ExpiresAt", "AuthMethod"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("Get(truncated) error %q does not name %s", err, field)
+		}
+	}
+	if strings.Contains(err.Error(), "AccessToken") {
+		t.Errorf("Get(truncated) error %q names AccessToken, which is present", err)
+	}
+
+	if _, err = repo.Get("kiro-corrupt.json"); err == nil {
+		t.Fatal("Get(corrupt) expected error")
+	}
+	if _, err = repo.Get("kiro-missing.json"); err == nil {
+		t.Fatal("Get(missing) expected error")
+	}
+}
+
+func TestFileTokenRepositorySweepNeedsOnlyRefreshToken(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-valid.json"), nil)
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-no-expiry.json"), map[string]any{"expires_at": ""})
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-no-access.json"), map[string]any{"access_token": ""})
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-no-refresh.json"), map[string]any{"refresh_token": ""})
+	repo := NewFileTokenRepository(dir)
+
+	var swept []string
+	for _, token := range repo.FindOldestUnverified(0) {
+		swept = append(swept, token.ID)
+	}
+	sort.Strings(swept)
+	if want := []string{"kiro-no-access.json", "kiro-no-expiry.json", "kiro-valid.json"}; strings.Join(swept, ",") != strings.Join(want, ",") {
+		t.Fatalf("FindOldestUnverified() = %v, want %v", swept, want)
+	}
+	listed, err := repo.ListKiroTokens(context.Background())
+	if err != nil {
+		t.Fatalf("ListKiroTokens() error = %v", err)
+	}
+	if len(listed) != 4 {
+		t.Fatalf("ListKiroTokens() returned %d tokens, want 4", len(listed))
+	}
+}
+
+func TestFileTokenRepositoryGetIsReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	flatPath := filepath.Join(dir, "kiro-builder-id-flat.json")
+	writeTestTokenFile(t, flatPath, nil)
+	repo := NewFileTokenRepository(dir, WithSubdirectoryByProvider(true))
+
+	if _, err := repo.Get("kiro-builder-id-flat.json"); err != nil {
+		t.Fatalf("Get(flat) error = %v", err)
+	}
+	if _, err := repo.Get("kiro-missing.json"); err == nil {
+		t.Fatal("Get(missing) expected error")
+	}
+	if _, err := os.Stat(flatPath); err != nil {
+		t.Fatalf("Get moved the flat file: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, defaultTokenProvider)); !os.IsNotExist(err) {
+		t.Fatalf("Get created a provider subdirectory (stat err = %v)", err)
+	}
+}
+
+func TestKiroTokenDataValidate(t *testing.T) {
+	if missing := (&KiroTokenData{AccessToken: "a", RefreshToken: "r", ExpiresAt: "e", AuthMethod: "idc"}).Validate(); len(missing) != 0 {
+		t.Fatalf("Validate() = %v, want none", missing)
+	}
+	missing := (&KiroTokenData{AccessToken: "a"}).Validate()
+	want := []string{"RefreshToken", "ExpiresAt", "AuthMethod"}
+	if strings.Join(missing, ",") != strings.Join(want, ",") {
+		t.Fatalf("Validate() = %v, want %v", missing, want)
+	}
+}
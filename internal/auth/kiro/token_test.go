@@ -0,0 +1,106 @@
+package kiro
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAccountKeyFromTokenFile(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-idc-user-example-com.json"), map[string]any{"client_id": "client-1"})
+	writeTestTokenFile(t, filepath.Join(dir, "kiro-social-00001.json"), map[string]any{"refresh_token": "refresh-1"})
+
+	key, err := AccountKeyFromTokenFile(dir, "kiro-idc-user-example-com.json")
+	if err != nil {
+		t.Fatalf("AccountKeyFromTokenFile() error = %v", err)
+	}
+	if want := GenerateAccountKey("client-1"); key != want {
+		t.Errorf("account key = %q, want %q (from client_id)", key, want)
+	}
+
+	key, err = AccountKeyFromTokenFile(dir, "kiro-social-00001.json")
+	if err != nil {
+		t.Fatalf("AccountKeyFromTokenFile() error = %v", err)
+	}
+	if want := GenerateAccountKey("refresh-1"); key != want {
+		t.Errorf("account key = %q, want %q (from refresh_token)", key, want)
+	}
+}
+
+func TestAccountKeyFromTokenFile_Errors(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "corrupt.json"), []byte(`{"client_id":`), 0o600); err != nil {
+		t.Fatalf("write corrupt file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "empty.json"), []byte(`{"type":"kiro"}`), 0o600); err != nil {
+		t.Fatalf("write empty file: %v", err)
+	}
+
+	if _, err := AccountKeyFromTokenFile(dir, "missing.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing file error = %v, want fs.ErrNotExist", err)
+	}
+	for _, name := range []string{"corrupt.json", "empty.json", "../empty.json", ""} {
+		if _, err := AccountKeyFromTokenFile(dir, name); err == nil {
+			t.Errorf("AccountKeyFromTokenFile(%q) expected error", name)
+		}
+	}
+}
+
+func TestKiroTokenDataAccountKey_MatchesGetAccountKey(t *testing.T) {
+	data := &KiroTokenData{ClientID: "client-1", RefreshToken: "refresh-1"}
+	if got, want := data.AccountKey(), GetAccountKey("client-1", "refresh-1"); got != want {
+		t.Fatalf("AccountKey() = %q, want %q", got, want)
+	}
+	if got := (&KiroTokenData{}).AccountKey(); got != "" {
+		t.Fatalf("AccountKey() without credentials = %q, want empty", got)
+	}
+}
+
+func TestKiroTokenDataClone(t *testing.T) {
+	original := &KiroTokenData{
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		ProfileArn:   "arn:aws:codewhisperer:us-east-1:123456789012:profile/ABC",
+		ExpiresAt:    "2030-01-01T00:00:00Z",
+		AuthMethod:   "idc",
+		Provider:     "AWS",
+		ClientID:     "client",
+		ClientSecret: "secret",
+		ClientIDHash: "hash",
+		Email:        "user@example.com",
+		StartURL:     "https://d-123.awsapps.com/start",
+		Region:       "eu-west-1",
+	}
+	snapshot := *original
+
+	clone := original.Clone()
+	if clone == original || *clone != snapshot {
+		t.Fatalf("Clone() = %+v, want an equal copy at a new address", clone)
+	}
+	clone.AccessToken = "changed"
+	clone.Region = "us-east-1"
+	if *original != snapshot {
+		t.Fatalf("modifying the clone changed the original: %+v", original)
+	}
+
+	var nilToken *KiroTokenData
+	if nilToken.Clone() != nil {
+		t.Fatal("Clone() of nil should be nil")
+	}
+}
+
+// TestKiroTokenDataClone_ValueFieldsOnly guards Clone's struct-copy implementation:
+// a reference-typed field would be shared between the clone and the original.
+func TestKiroTokenDataClone_ValueFieldsOnly(t *testing.T) {
+	typ := reflect.TypeOf(KiroTokenData{})
+	for i := 0; i < typ.NumField(); i++ {
+		switch field := typ.Field(i); field.Type.Kind() {
+		case reflect.Map, reflect.Slice, reflect.Pointer, reflect.Interface:
+			t.Errorf("field %s is %s; update Clone to deep-copy it", field.Name, field.Type.Kind())
+		}
+	}
+}
@@ -11,7 +11,6 @@ import (
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
-	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 )
 
 // UsageQuotaResponse represents the API response structure for usage quota checking.
@@ -53,9 +52,9 @@ type UsageChecker struct {
 }
 
 // NewUsageChecker creates a new UsageChecker instance.
-func NewUsageChecker(cfg *config.Config) *UsageChecker {
+func NewUsageChecker(cfg *config.Config, opts ...ClientOption) *UsageChecker {
 	return &UsageChecker{
-		httpClient: util.SetProxy(&cfg.SDKConfig, &http.Client{Timeout: 30 * time.Second}),
+		httpClient: newHTTPClient(cfg, 30*time.Second, opts),
 	}
 }
 
@@ -92,9 +91,7 @@ func (c *UsageChecker) CheckUsage(ctx context.Context, tokenData *KiroTokenData)
 	}
 
 	accountKey := GetAccountKey(tokenData.ClientID, tokenData.RefreshToken)
-	setRuntimeHeaders(req, tokenData.AccessToken, accountKey)
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := wrapHTTPClient(c.httpClient).DoRuntime(req, tokenData.AccessToken, accountKey)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	kiroauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// DoKiroExportTokens writes every Kiro token in the auth directory to stdout as a
+// versioned JSON document. Secrets are masked unless unmasked is set; only an
+// unmasked export can be read back by DoKiroImportTokens. The caller keeps logs
+// off stdout so it carries only the document.
+func DoKiroExportTokens(cfg *config.Config, unmasked bool) {
+	repo := kiroauth.NewFileTokenRepository(cfg.AuthDir)
+	if errExport := kiroauth.ExportTokens(context.Background(), repo, os.Stdout, kiroauth.WithUnmaskedSecrets(unmasked)); errExport != nil {
+		log.Errorf("Kiro token export failed: %v", errExport)
+		return
+	}
+}
+
+// DoKiroImportTokens stores the tokens of a DoKiroExportTokens document in the
+// auth directory. path "-" reads the document from stdin.
+func DoKiroImportTokens(cfg *config.Config, path string) {
+	var src io.Reader = os.Stdin
+	if path = strings.TrimSpace(path); path != "-" {
+		file, errOpen := os.Open(path)
+		if errOpen != nil {
+			log.Errorf("Kiro token import failed: %v", errOpen)
+			return
+		}
+		defer func() { _ = file.Close() }()
+		src = file
+	}
+	repo := kiroauth.NewFileTokenRepository(cfg.AuthDir)
+	imported, errImport := kiroauth.ImportTokens(context.Background(), repo, src)
+	if errImport != nil {
+		log.Errorf("Kiro token import failed after %d token(s): %v", imported, errImport)
+		return
+	}
+	log.Infof("Imported %d Kiro token(s) into %s", imported, cfg.AuthDir)
+}
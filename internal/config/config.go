@@ -12,6 +12,7 @@ import (
 	"os"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	log "github.com/sirupsen/logrus"
@@ -42,6 +43,13 @@ type Config struct {
 	// AuthDir is the directory where authentication token files are stored.
 	AuthDir string `yaml:"auth-dir" json:"-"`
 
+	// TranslatorPluginDir is a directory of translator plugins (.so files built with
+	// -buildmode=plugin) loaded at startup. Empty disables plugin loading.
+	TranslatorPluginDir string `yaml:"translator-plugin-dir" json:"-"`
+
+	// Translator holds request translation defaults applied once at startup.
+	Translator TranslatorConfig `yaml:"translator,omitempty" json:"translator,omitempty"`
+
 	// Debug enables or disables debug-level logging and other debug features.
 	Debug bool `yaml:"debug" json:"debug"`
 
@@ -70,6 +78,19 @@ type Config struct {
 	// Default: 60. Max: 3600.
 	RedisUsageQueueRetentionSeconds int `yaml:"redis-usage-queue-retention-seconds" json:"redis-usage-queue-retention-seconds"`
 
+	// RedisAddr is the host:port of a Redis server shared by proxy instances for
+	// rate limiting. Empty keeps rate limiting in-process.
+	RedisAddr string `yaml:"redis-addr" json:"redis-addr"`
+
+	// RedisPassword authenticates to the Redis server at RedisAddr.
+	RedisPassword string `yaml:"redis-password" json:"-"`
+
+	// RedisDB selects the Redis logical database. Default: 0.
+	RedisDB int `yaml:"redis-db" json:"redis-db"`
+
+	// RateLimit limits the requests each client IP may send to the server.
+	RateLimit RateLimitConfig `yaml:"rate-limit,omitempty" json:"rate-limit,omitempty"`
+
 	// DisableCooling disables quota cooldown scheduling when true.
 	DisableCooling bool `yaml:"disable-cooling" json:"disable-cooling"`
 
@@ -91,6 +112,14 @@ type Config struct {
 	// Routing controls credential selection behavior.
 	Routing RoutingConfig `yaml:"routing" json:"routing"`
 
+	// ProviderHTTP overrides the transport settings of upstream HTTP clients per
+	// provider, keyed by provider identifier (e.g. "claude", "gemini", "kiro").
+	ProviderHTTP map[string]ProviderHTTPConfig `yaml:"provider-http,omitempty" json:"provider-http,omitempty"`
+
+	// ModelTimeouts bounds upstream requests per model. The first rule whose
+	// pattern matches the requested model sets the deadline.
+	ModelTimeouts []ModelTimeout `yaml:"model-timeouts,omitempty" json:"model-timeouts,omitempty"`
+
 	// WebsocketAuth enables or disables authentication for the WebSocket API.
 	WebsocketAuth bool `yaml:"ws-auth" json:"ws-auth"`
 
@@ -115,6 +144,21 @@ type Config struct {
 	// Values: "ide" (default, CodeWhisperer) or "cli" (Amazon Q).
 	KiroPreferredEndpoint string `yaml:"kiro-preferred-endpoint" json:"kiro-preferred-endpoint"`
 
+	// KiroAmzTargets overrides the X-Amz-Target header per endpoint and operation.
+	// Keys are "endpoint/operation" or "endpoint/region/operation", e.g.
+	// "codewhisperer/GenerateAssistantResponse"; an empty value omits the header.
+	KiroAmzTargets map[string]string `yaml:"kiro-amz-targets,omitempty" json:"kiro-amz-targets,omitempty"`
+
+	// KiroSigV4Signing signs Kiro API requests with AWS Signature Version 4 using the
+	// auth's aws_access_key_id, aws_secret_access_key and aws_session_token instead
+	// of sending its access token as a bearer.
+	KiroSigV4Signing bool `yaml:"kiro-sigv4-signing,omitempty" json:"kiro-sigv4-signing,omitempty"`
+
+	// KiroTokenFileNaming chooses how Kiro tokens saved by the web login and import
+	// are named: "email" (default, kiro-{method}-{email}.json) or "account-key"
+	// (kiro-{accountKey}.json).
+	KiroTokenFileNaming string `yaml:"kiro-token-file-naming,omitempty" json:"kiro-token-file-naming,omitempty"`
+
 	// Codex defines a list of Codex API key configurations as specified in the YAML configuration file.
 	CodexKey []CodexKey `yaml:"codex-api-key" json:"codex-api-key"`
 
@@ -234,6 +278,108 @@ type QuotaExceeded struct {
 	AntigravityCredits bool `yaml:"antigravity-credits" json:"antigravity-credits"`
 }
 
+// TranslatorConfig holds request translation defaults. They are applied when the
+// service starts; changing them requires a restart.
+type TranslatorConfig struct {
+	// ClaudeSystemInstruction is sent to Claude models through Antigravity when the
+	// client provides no system instruction. Empty sends none.
+	ClaudeSystemInstruction string `yaml:"claude-system-instruction,omitempty" json:"claude-system-instruction,omitempty"`
+
+	// DefaultThinkingBudget is the Gemini thinking budget used when a request to a
+	// thinking-capable model sets neither a budget nor a level. Zero leaves it unset.
+	DefaultThinkingBudget int `yaml:"default-thinking-budget,omitempty" json:"default-thinking-budget,omitempty"`
+
+	// UnsupportedGenerationConfigKeys replaces, per model family, the generationConfig
+	// keys stripped from Antigravity requests. Families not listed keep the built-in list.
+	UnsupportedGenerationConfigKeys map[string][]string `yaml:"unsupported-generation-config-keys,omitempty" json:"unsupported-generation-config-keys,omitempty"`
+
+	// MaxFunctionDeclarations rejects Gemini and Antigravity requests declaring more
+	// functions than this before they are sent. Zero means unlimited.
+	MaxFunctionDeclarations int `yaml:"max-function-declarations,omitempty" json:"max-function-declarations,omitempty"`
+}
+
+// RateLimitConfig limits requests per client IP. The limit is shared through
+// Redis when RedisAddr is set and tracked per process otherwise.
+type RateLimitConfig struct {
+	// RequestsPerSecond is the sustained request rate allowed per client IP.
+	// Zero or negative disables rate limiting.
+	RequestsPerSecond float64 `yaml:"requests-per-second,omitempty" json:"requests-per-second,omitempty"`
+
+	// Burst is how many requests a client IP may send at once. Default: 1.
+	Burst int `yaml:"burst,omitempty" json:"burst,omitempty"`
+}
+
+// ProviderHTTPConfig tunes the transport of one provider's upstream HTTP client.
+// Zero fields keep the Go defaults.
+type ProviderHTTPConfig struct {
+	// ConnectTimeout bounds establishing the TCP connection.
+	ConnectTimeout time.Duration `yaml:"connect-timeout,omitempty" json:"connect-timeout,omitempty"`
+
+	// TLSHandshakeTimeout bounds the TLS handshake.
+	TLSHandshakeTimeout time.Duration `yaml:"tls-handshake-timeout,omitempty" json:"tls-handshake-timeout,omitempty"`
+
+	// ResponseHeaderTimeout bounds the wait for response headers once the request
+	// has been written. It does not limit reading streamed bodies.
+	ResponseHeaderTimeout time.Duration `yaml:"response-header-timeout,omitempty" json:"response-header-timeout,omitempty"`
+
+	// IdleConnTimeout is how long idle keep-alive connections are kept open.
+	IdleConnTimeout time.Duration `yaml:"idle-conn-timeout,omitempty" json:"idle-conn-timeout,omitempty"`
+
+	// MaxIdleConns caps idle keep-alive connections across all hosts.
+	MaxIdleConns int `yaml:"max-idle-conns,omitempty" json:"max-idle-conns,omitempty"`
+
+	// TotalTimeout bounds whole non-streaming requests of the provider's auth
+	// clients (Kiro, Copilot), including reading the body. Streaming requests and
+	// executor requests are not cut off by it.
+	TotalTimeout time.Duration `yaml:"total-timeout,omitempty" json:"total-timeout,omitempty"`
+
+	// CircuitBreakerThreshold opens a circuit breaker on the provider's executor
+	// transport after this many consecutive transport errors or 5xx responses.
+	// The breaker is per provider (and proxy URL), not per account: while it is
+	// open, every account of the provider gets a synthetic 503. Zero disables it.
+	CircuitBreakerThreshold int `yaml:"circuit-breaker-threshold,omitempty" json:"circuit-breaker-threshold,omitempty"`
+
+	// CircuitBreakerTimeout is how long an open breaker rejects requests before
+	// letting a probe through. Zero uses 60 seconds.
+	CircuitBreakerTimeout time.Duration `yaml:"circuit-breaker-timeout,omitempty" json:"circuit-breaker-timeout,omitempty"`
+}
+
+// IsZero reports whether c leaves every transport setting at its default.
+func (c ProviderHTTPConfig) IsZero() bool {
+	return c == ProviderHTTPConfig{}
+}
+
+// ModelTimeout sets request deadlines for the models matching ModelPattern.
+// Zero timeouts leave the corresponding requests unbounded.
+type ModelTimeout struct {
+	// ModelPattern is a case-insensitive model name where '*' matches any characters.
+	ModelPattern string `yaml:"model-pattern" json:"model-pattern"`
+
+	// StreamingTimeout bounds streaming requests, including reading the stream.
+	StreamingTimeout time.Duration `yaml:"streaming-timeout,omitempty" json:"streaming-timeout,omitempty"`
+
+	// NonStreamingTimeout bounds non-streaming requests.
+	NonStreamingTimeout time.Duration `yaml:"non-streaming-timeout,omitempty" json:"non-streaming-timeout,omitempty"`
+}
+
+// ProviderHTTPFor returns the transport settings configured for provider. Keys
+// are matched case-insensitively; ok is false when nothing is configured.
+func (cfg *Config) ProviderHTTPFor(provider string) (ProviderHTTPConfig, bool) {
+	if cfg == nil || len(cfg.ProviderHTTP) == 0 {
+		return ProviderHTTPConfig{}, false
+	}
+	provider = strings.TrimSpace(provider)
+	if httpCfg, ok := cfg.ProviderHTTP[provider]; ok && !httpCfg.IsZero() {
+		return httpCfg, true
+	}
+	for key, httpCfg := range cfg.ProviderHTTP {
+		if strings.EqualFold(strings.TrimSpace(key), provider) && !httpCfg.IsZero() {
+			return httpCfg, true
+		}
+	}
+	return ProviderHTTPConfig{}, false
+}
+
 // RoutingConfig configures how credentials are selected for requests.
 type RoutingConfig struct {
 	// Strategy selects the credential selection strategy.
@@ -495,6 +641,10 @@ type GeminiKey struct {
 	// APIKey is the authentication key for accessing Gemini API services.
 	APIKey string `yaml:"api-key" json:"api-key"`
 
+	// APIKeys optionally lists additional keys. Requests using this entry rotate
+	// across APIKey and APIKeys, skipping keys that were recently rate limited.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+
 	// Priority controls selection preference when multiple credentials match.
 	// Higher values are preferred; defaults to 0.
 	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
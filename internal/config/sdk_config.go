@@ -41,6 +41,10 @@ type SDKConfig struct {
 	// Streaming configures server-side streaming behavior (keep-alives and safe bootstrap retries).
 	Streaming StreamingConfig `yaml:"streaming" json:"streaming"`
 
+	// SystemPromptPrefix is prepended to the system prompt of every proxied request,
+	// separated from an existing system prompt by a blank line. Empty disables it.
+	SystemPromptPrefix string `yaml:"system-prompt-prefix,omitempty" json:"system-prompt-prefix,omitempty"`
+
 	// NonStreamKeepAliveInterval controls how often blank lines are emitted for non-streaming responses.
 	// <= 0 disables keep-alives. Value is in seconds.
 	NonStreamKeepAliveInterval int `yaml:"nonstream-keepalive-interval,omitempty" json:"nonstream-keepalive-interval,omitempty"`
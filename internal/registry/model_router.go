@@ -0,0 +1,131 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ErrUnknownModel is returned by ModelRouter.Route when no route matches the
+// requested model.
+var ErrUnknownModel = errors.New("unknown model")
+
+// ModelRoute maps a requested model name, or every name with a given prefix, to
+// a provider and canonical model. Exactly one of Model and Prefix is set.
+type ModelRoute struct {
+	// Model is an exact requested model name.
+	Model string `json:"model,omitempty"`
+	// Prefix matches every requested model name starting with it.
+	Prefix string `json:"prefix,omitempty"`
+	// Provider is the provider identifier, e.g. "kiro" or "antigravity".
+	Provider string `json:"provider"`
+	// Canonical is the model name sent upstream. When empty the requested name
+	// is used, minus Prefix if TrimPrefix is set.
+	Canonical string `json:"canonical,omitempty"`
+	// TrimPrefix strips Prefix from the requested name to form the canonical
+	// name. Only used by prefix routes without Canonical.
+	TrimPrefix bool `json:"trim_prefix,omitempty"`
+}
+
+// ModelRouterConfig is the JSON layout of a routing table file.
+type ModelRouterConfig struct {
+	Routes []ModelRoute `json:"routes"`
+}
+
+// ModelRouter resolves requested model names to a canonical model and provider.
+// Matching is case-insensitive; exact routes win over prefix routes and the
+// longest matching prefix wins among prefix routes. A ModelRouter is immutable
+// and safe for concurrent use.
+type ModelRouter struct {
+	exact    map[string]ModelRoute
+	prefixes []ModelRoute // sorted by descending prefix length
+}
+
+// NewModelRouter builds a router from routes. It rejects routes without a
+// provider, with both or neither of Model and Prefix, and duplicate matches.
+func NewModelRouter(routes []ModelRoute) (*ModelRouter, error) {
+	mr := &ModelRouter{exact: make(map[string]ModelRoute)}
+	seenPrefixes := make(map[string]struct{})
+	for i, route := range routes {
+		route.Model = strings.TrimSpace(route.Model)
+		route.Prefix = strings.TrimSpace(route.Prefix)
+		route.Provider = strings.TrimSpace(route.Provider)
+		route.Canonical = strings.TrimSpace(route.Canonical)
+
+		if route.Provider == "" {
+			return nil, fmt.Errorf("model router: route %d: provider is required", i)
+		}
+		switch {
+		case route.Model != "" && route.Prefix != "":
+			return nil, fmt.Errorf("model router: route %d: model and prefix are mutually exclusive", i)
+		case route.Model != "":
+			key := strings.ToLower(route.Model)
+			if _, exists := mr.exact[key]; exists {
+				return nil, fmt.Errorf("model router: route %d: duplicate model %q", i, route.Model)
+			}
+			mr.exact[key] = route
+		case route.Prefix != "":
+			key := strings.ToLower(route.Prefix)
+			if _, exists := seenPrefixes[key]; exists {
+				return nil, fmt.Errorf("model router: route %d: duplicate prefix %q", i, route.Prefix)
+			}
+			seenPrefixes[key] = struct{}{}
+			mr.prefixes = append(mr.prefixes, route)
+		default:
+			return nil, fmt.Errorf("model router: route %d: model or prefix is required", i)
+		}
+	}
+	sort.SliceStable(mr.prefixes, func(i, j int) bool {
+		return len(mr.prefixes[i].Prefix) > len(mr.prefixes[j].Prefix)
+	})
+	return mr, nil
+}
+
+// LoadModelRouter reads a ModelRouterConfig JSON file and builds a router from it.
+func LoadModelRouter(path string) (*ModelRouter, error) {
+	data, errRead := os.ReadFile(path)
+	if errRead != nil {
+		return nil, fmt.Errorf("model router: read %s: %w", path, errRead)
+	}
+	var cfg ModelRouterConfig
+	if errUnmarshal := json.Unmarshal(data, &cfg); errUnmarshal != nil {
+		return nil, fmt.Errorf("model router: parse %s: %w", path, errUnmarshal)
+	}
+	return NewModelRouter(cfg.Routes)
+}
+
+// Route returns the canonical model and provider for requestedModel, or an
+// error wrapping ErrUnknownModel when no route matches.
+func (mr *ModelRouter) Route(requestedModel string) (canonicalModel, provider string, err error) {
+	requested := strings.TrimSpace(requestedModel)
+	key := strings.ToLower(requested)
+	if mr != nil && key != "" {
+		if route, ok := mr.exact[key]; ok {
+			if route.Canonical != "" {
+				return route.Canonical, route.Provider, nil
+			}
+			return requested, route.Provider, nil
+		}
+		for _, route := range mr.prefixes {
+			if len(requested) < len(route.Prefix) || !strings.EqualFold(requested[:len(route.Prefix)], route.Prefix) {
+				continue
+			}
+			switch {
+			case route.Canonical != "":
+				canonicalModel = route.Canonical
+			case route.TrimPrefix:
+				canonicalModel = requested[len(route.Prefix):]
+			default:
+				canonicalModel = requested
+			}
+			if canonicalModel == "" {
+				continue
+			}
+			return canonicalModel, route.Provider, nil
+		}
+	}
+	return "", "", fmt.Errorf("%w: %q", ErrUnknownModel, requestedModel)
+}
@@ -0,0 +1,94 @@
+package registry
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testModelRouterConfig = `{
+  "routes": [
+    {"model": "gemini-2.5-pro", "provider": "antigravity"},
+    {"model": "claude-sonnet", "provider": "kiro", "canonical": "kiro-claude-sonnet-4-5"},
+    {"prefix": "kiro-", "provider": "kiro"},
+    {"prefix": "copilot/", "provider": "github-copilot", "trim_prefix": true},
+    {"prefix": "copilot/claude-", "provider": "kiro", "canonical": "kiro-claude-sonnet-4-5"},
+    {"prefix": "gemini-", "provider": "gemini"}
+  ]
+}`
+
+func loadTestModelRouter(t *testing.T) *ModelRouter {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "routes.json")
+	if err := os.WriteFile(path, []byte(testModelRouterConfig), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	mr, err := LoadModelRouter(path)
+	if err != nil {
+		t.Fatalf("LoadModelRouter: %v", err)
+	}
+	return mr
+}
+
+func TestModelRouterRoute(t *testing.T) {
+	mr := loadTestModelRouter(t)
+
+	tests := []struct {
+		name          string
+		requested     string
+		wantCanonical string
+		wantProvider  string
+	}{
+		{"exact keeps requested name", "gemini-2.5-pro", "gemini-2.5-pro", "antigravity"},
+		{"exact is case-insensitive", "Gemini-2.5-Pro", "Gemini-2.5-Pro", "antigravity"},
+		{"exact with canonical", "claude-sonnet", "kiro-claude-sonnet-4-5", "kiro"},
+		{"exact wins over prefix", " gemini-2.5-pro ", "gemini-2.5-pro", "antigravity"},
+		{"prefix keeps requested name", "kiro-claude-haiku-4-5", "kiro-claude-haiku-4-5", "kiro"},
+		{"prefix fallback", "gemini-2.5-flash", "gemini-2.5-flash", "gemini"},
+		{"prefix with trim", "copilot/gpt-4o", "gpt-4o", "github-copilot"},
+		{"longest prefix wins", "copilot/claude-opus", "kiro-claude-sonnet-4-5", "kiro"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			canonical, provider, err := mr.Route(tt.requested)
+			if err != nil {
+				t.Fatalf("Route(%q) error: %v", tt.requested, err)
+			}
+			if canonical != tt.wantCanonical || provider != tt.wantProvider {
+				t.Errorf("Route(%q) = (%q, %q), want (%q, %q)", tt.requested, canonical, provider, tt.wantCanonical, tt.wantProvider)
+			}
+		})
+	}
+}
+
+func TestModelRouterRouteUnknownModel(t *testing.T) {
+	mr := loadTestModelRouter(t)
+
+	for _, requested := range []string{"gpt-5", "", "copilot/"} {
+		canonical, provider, err := mr.Route(requested)
+		if !errors.Is(err, ErrUnknownModel) {
+			t.Errorf("Route(%q) error = %v, want ErrUnknownModel", requested, err)
+		}
+		if canonical != "" || provider != "" {
+			t.Errorf("Route(%q) = (%q, %q), want empty", requested, canonical, provider)
+		}
+	}
+}
+
+func TestNewModelRouterRejectsInvalidRoutes(t *testing.T) {
+	tests := map[string][]ModelRoute{
+		"missing provider": {{Model: "a"}},
+		"model and prefix": {{Model: "a", Prefix: "a-", Provider: "p"}},
+		"no match":         {{Provider: "p"}},
+		"duplicate model":  {{Model: "a", Provider: "p"}, {Model: "A", Provider: "q"}},
+		"duplicate prefix": {{Prefix: "a-", Provider: "p"}, {Prefix: "a-", Provider: "q"}},
+	}
+	for name, routes := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewModelRouter(routes); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor/helps"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
 	antigravityclaude "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/antigravity/claude"
+	geminicommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
@@ -56,6 +57,10 @@ const (
 	antigravityCreditsHintRefreshTimeout   = 5 * time.Second
 	antigravityShortQuotaCooldownThreshold = 5 * time.Minute
 	antigravityInstantRetryThreshold       = 3 * time.Second
+	// antigravityTransientRetryAttempts and antigravityTransientRetryBaseDelay bound
+	// the retries of transient upstream failures on a single base URL.
+	antigravityTransientRetryAttempts  = 3
+	antigravityTransientRetryBaseDelay = 500 * time.Millisecond
 	// systemInstruction              = "You are Antigravity, a powerful agentic AI coding assistant designed by the Google Deepmind team working on Advanced Agentic Coding.You are pair programming with a USER to solve their coding task. The task may require creating a new codebase, modifying or debugging an existing codebase, or simply answering a question.**Absolute paths only****Proactiveness**"
 )
 
@@ -519,6 +524,9 @@ func (e *AntigravityExecutor) Execute(ctx context.Context, auth *cliproxyauth.Au
 	if err != nil {
 		return resp, err
 	}
+	if errLimit := geminicommon.CheckFunctionDeclarationLimit(translated); errLimit != nil {
+		return resp, statusErr{code: http.StatusBadRequest, msg: errLimit.Error()}
+	}
 
 	requestedModel := helps.PayloadRequestedModel(opts, req.Model)
 	requestPath := helps.PayloadRequestPath(opts)
@@ -545,13 +553,19 @@ attemptLoop:
 				}
 			}
 
-			httpReq, errReq := e.buildRequest(ctx, auth, token, baseModel, requestPayload, false, opts.Alt, baseURL)
+			var errReq error
+			httpResp, errDo := helps.RetryableExecuteFor(ctx, func() (*http.Response, error) {
+				httpReq, errBuild := e.buildRequest(ctx, auth, token, baseModel, requestPayload, false, opts.Alt, baseURL)
+				if errBuild != nil {
+					errReq = errBuild
+					return nil, errBuild
+				}
+				return httpClient.Do(httpReq)
+			}, antigravityTransientRetryAttempts, antigravityTransientRetryBaseDelay, antigravityRetryableResponse)
 			if errReq != nil {
 				err = errReq
 				return resp, err
 			}
-
-			httpResp, errDo := httpClient.Do(httpReq)
 			if errDo != nil {
 				helps.RecordAPIResponseError(ctx, e.cfg, errDo)
 				if errors.Is(errDo, context.Canceled) || errors.Is(errDo, context.DeadlineExceeded) {
@@ -717,6 +731,9 @@ func (e *AntigravityExecutor) executeClaudeNonStream(ctx context.Context, auth *
 	if err != nil {
 		return resp, err
 	}
+	if errLimit := geminicommon.CheckFunctionDeclarationLimit(translated); errLimit != nil {
+		return resp, statusErr{code: http.StatusBadRequest, msg: errLimit.Error()}
+	}
 
 	requestedModel := helps.PayloadRequestedModel(opts, req.Model)
 	requestPath := helps.PayloadRequestPath(opts)
@@ -743,13 +760,19 @@ attemptLoop:
 					helps.MarkCreditsUsed(ctx)
 				}
 			}
-			httpReq, errReq := e.buildRequest(ctx, auth, token, baseModel, requestPayload, true, opts.Alt, baseURL)
+			var errReq error
+			httpResp, errDo := helps.RetryableExecuteFor(ctx, func() (*http.Response, error) {
+				httpReq, errBuild := e.buildRequest(ctx, auth, token, baseModel, requestPayload, true, opts.Alt, baseURL)
+				if errBuild != nil {
+					errReq = errBuild
+					return nil, errBuild
+				}
+				return httpClient.Do(httpReq)
+			}, antigravityTransientRetryAttempts, antigravityTransientRetryBaseDelay, antigravityRetryableResponse)
 			if errReq != nil {
 				err = errReq
 				return resp, err
 			}
-
-			httpResp, errDo := httpClient.Do(httpReq)
 			if errDo != nil {
 				helps.RecordAPIResponseError(ctx, e.cfg, errDo)
 				if errors.Is(errDo, context.Canceled) || errors.Is(errDo, context.DeadlineExceeded) {
@@ -1178,6 +1201,9 @@ func (e *AntigravityExecutor) ExecuteStream(ctx context.Context, auth *cliproxya
 	if err != nil {
 		return nil, err
 	}
+	if errLimit := geminicommon.CheckFunctionDeclarationLimit(translated); errLimit != nil {
+		return nil, statusErr{code: http.StatusBadRequest, msg: errLimit.Error()}
+	}
 
 	requestedModel := helps.PayloadRequestedModel(opts, req.Model)
 	requestPath := helps.PayloadRequestPath(opts)
@@ -1204,12 +1230,19 @@ attemptLoop:
 					helps.MarkCreditsUsed(ctx)
 				}
 			}
-			httpReq, errReq := e.buildRequest(ctx, auth, token, baseModel, requestPayload, true, opts.Alt, baseURL)
+			var errReq error
+			httpResp, errDo := helps.RetryableExecuteFor(ctx, func() (*http.Response, error) {
+				httpReq, errBuild := e.buildRequest(ctx, auth, token, baseModel, requestPayload, true, opts.Alt, baseURL)
+				if errBuild != nil {
+					errReq = errBuild
+					return nil, errBuild
+				}
+				return httpClient.Do(httpReq)
+			}, antigravityTransientRetryAttempts, antigravityTransientRetryBaseDelay, antigravityRetryableResponse)
 			if errReq != nil {
 				err = errReq
 				return nil, err
 			}
-			httpResp, errDo := httpClient.Do(httpReq)
 			if errDo != nil {
 				helps.RecordAPIResponseError(ctx, e.cfg, errDo)
 				if errors.Is(errDo, context.Canceled) || errors.Is(errDo, context.DeadlineExceeded) {
@@ -2110,6 +2143,23 @@ func antigravityConfiguredUserAgent(auth *cliproxyauth.Auth) string {
 	return raw
 }
 
+// antigravityRetryableResponse reports whether resp is a transient failure worth
+// retrying against the same base URL: 500, 502 and 504 always, and 429 or 503
+// only when the upstream sent a Retry-After shorter than
+// antigravityInstantRetryThreshold. Other 429 and 503 responses, including longer
+// Retry-After waits, are left to the quota, credits and auth rotation handling in
+// the request loops.
+func antigravityRetryableResponse(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return true
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		retryAfter, ok := helps.ParseRetryAfter(resp.Header, time.Now())
+		return ok && retryAfter < antigravityInstantRetryThreshold
+	}
+	return false
+}
+
 func antigravityRetryAttempts(auth *cliproxyauth.Auth, cfg *config.Config) int {
 	retry := 0
 	if cfg != nil {
@@ -15,6 +15,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor/helps"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	geminicommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
@@ -86,7 +87,7 @@ func (e *GeminiExecutor) HttpRequest(ctx context.Context, auth *cliproxyauth.Aut
 	if err := e.PrepareRequest(httpReq, auth); err != nil {
 		return nil, err
 	}
-	httpClient := helps.NewProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	httpClient := e.withGeminiKeyRotation(helps.NewProxyAwareHTTPClient(ctx, e.cfg, auth, 0), auth)
 	return httpClient.Do(httpReq)
 }
 
@@ -129,6 +130,9 @@ func (e *GeminiExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 	if err != nil {
 		return resp, err
 	}
+	if errLimit := geminicommon.CheckFunctionDeclarationLimit(body); errLimit != nil {
+		return resp, statusErr{code: http.StatusBadRequest, msg: errLimit.Error()}
+	}
 
 	body = fixGeminiImageAspectRatio(baseModel, body)
 	requestedModel := helps.PayloadRequestedModel(opts, req.Model)
@@ -179,7 +183,7 @@ func (e *GeminiExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 		AuthValue: authValue,
 	})
 
-	httpClient := helps.NewProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	httpClient := e.withGeminiKeyRotation(helps.NewProxyAwareHTTPClient(ctx, e.cfg, auth, 0), auth)
 	httpResp, err := httpClient.Do(httpReq)
 	if err != nil {
 		helps.RecordAPIResponseError(ctx, e.cfg, err)
@@ -237,6 +241,9 @@ func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 	if err != nil {
 		return nil, err
 	}
+	if errLimit := geminicommon.CheckFunctionDeclarationLimit(body); errLimit != nil {
+		return nil, statusErr{code: http.StatusBadRequest, msg: errLimit.Error()}
+	}
 
 	body = fixGeminiImageAspectRatio(baseModel, body)
 	requestedModel := helps.PayloadRequestedModel(opts, req.Model)
@@ -283,7 +290,7 @@ func (e *GeminiExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 		AuthValue: authValue,
 	})
 
-	httpClient := helps.NewProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	httpClient := e.withGeminiKeyRotation(helps.NewProxyAwareHTTPClient(ctx, e.cfg, auth, 0), auth)
 	httpResp, err := httpClient.Do(httpReq)
 	if err != nil {
 		helps.RecordAPIResponseError(ctx, e.cfg, err)
@@ -396,7 +403,7 @@ func (e *GeminiExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Aut
 		AuthValue: authValue,
 	})
 
-	httpClient := helps.NewProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	httpClient := e.withGeminiKeyRotation(helps.NewProxyAwareHTTPClient(ctx, e.cfg, auth, 0), auth)
 	resp, err := httpClient.Do(httpReq)
 	if err != nil {
 		helps.RecordAPIResponseError(ctx, e.cfg, err)
@@ -430,6 +437,36 @@ func (e *GeminiExecutor) Refresh(_ context.Context, auth *cliproxyauth.Auth) (*c
 	return auth, nil
 }
 
+// withGeminiKeyRotation wraps client with a MultiKeyGeminiTransport when the
+// auth's config entry lists additional API keys.
+func (e *GeminiExecutor) withGeminiKeyRotation(client *http.Client, auth *cliproxyauth.Auth) *http.Client {
+	entry := e.resolveGeminiConfig(auth)
+	if entry == nil || len(entry.APIKeys) == 0 {
+		return client
+	}
+	rotator := helps.MultiKeyGeminiTransportFor(append([]string{entry.APIKey}, entry.APIKeys...))
+	if rotator == nil {
+		return client
+	}
+	wrapped := *client
+	wrapped.Transport = rotator.WithBase(client.Transport)
+	return &wrapped
+}
+
+// PruneGeminiKeyRotation forgets the key rotation state of gemini-api-key entries
+// that are no longer in cfg. It is called after a config reload.
+func PruneGeminiKeyRotation(cfg *config.Config) {
+	var keySets [][]string
+	if cfg != nil {
+		for _, entry := range cfg.GeminiKey {
+			if len(entry.APIKeys) > 0 {
+				keySets = append(keySets, append([]string{entry.APIKey}, entry.APIKeys...))
+			}
+		}
+	}
+	helps.PruneMultiKeyGeminiTransports(keySets)
+}
+
 func geminiCreds(a *cliproxyauth.Auth) (apiKey, bearer string) {
 	if a == nil {
 		return "", ""
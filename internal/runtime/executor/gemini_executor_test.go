@@ -0,0 +1,113 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	geminicommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+func TestGeminiExecutorRejectsTooManyFunctionDeclarations(t *testing.T) {
+	previous := geminicommon.MaxFunctionDeclarations
+	geminicommon.MaxFunctionDeclarations = 2
+	t.Cleanup(func() { geminicommon.MaxFunctionDeclarations = previous })
+
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"candidates":[{"content":{"role":"model","parts":[{"text":"ok"}]}}]}`))
+	}))
+	defer server.Close()
+
+	exec := NewGeminiExecutor(&config.Config{})
+	auth := &cliproxyauth.Auth{Provider: "gemini", Attributes: map[string]string{"api_key": "test-key", "base_url": server.URL}}
+	opts := cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("gemini")}
+	request := func(declarations string) cliproxyexecutor.Request {
+		return cliproxyexecutor.Request{
+			Model:   "gemini-2.5-flash",
+			Payload: []byte(`{"contents":[{"role":"user","parts":[{"text":"hi"}]}],"tools":[{"function_declarations":[` + declarations + `]}]}`),
+		}
+	}
+
+	_, err := exec.Execute(context.Background(), auth, request(`{"name":"a"},{"name":"b"},{"name":"c"}`), opts)
+	var status interface{ StatusCode() int }
+	if err == nil || !errors.As(err, &status) || status.StatusCode() != http.StatusBadRequest {
+		t.Fatalf("over the cap: error = %v, want a 400 status error", err)
+	}
+	if !strings.Contains(err.Error(), "too many function declarations") {
+		t.Errorf("error = %q, want the declaration limit message", err.Error())
+	}
+	if got := hits.Load(); got != 0 {
+		t.Fatalf("upstream hits = %d, want 0 for a rejected request", got)
+	}
+
+	if _, err := exec.Execute(context.Background(), auth, request(`{"name":"a"},{"name":"b"}`), opts); err != nil {
+		t.Fatalf("under the cap: %v", err)
+	}
+	if got := hits.Load(); got != 1 {
+		t.Fatalf("upstream hits = %d, want 1", got)
+	}
+}
+
+func TestGeminiCLIAndVertexExecutorsRejectTooManyFunctionDeclarations(t *testing.T) {
+	previous := geminicommon.MaxFunctionDeclarations
+	geminicommon.MaxFunctionDeclarations = 1
+	t.Cleanup(func() { geminicommon.MaxFunctionDeclarations = previous })
+
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	req := cliproxyexecutor.Request{
+		Model:   "gemini-2.5-flash",
+		Payload: []byte(`{"contents":[{"role":"user","parts":[{"text":"hi"}]}],"tools":[{"functionDeclarations":[{"name":"a"},{"name":"b"}]}]}`),
+	}
+	opts := cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("gemini")}
+	cliAuth := &cliproxyauth.Auth{Provider: "gemini-cli", Metadata: map[string]any{"access_token": "token", "project_id": "project-1"}}
+	vertexAuth := &cliproxyauth.Auth{Provider: "vertex", Attributes: map[string]string{"api_key": "test-key", "base_url": server.URL}}
+
+	cases := []struct {
+		name string
+		run  func() error
+	}{
+		{"gemini-cli", func() error {
+			_, err := NewGeminiCLIExecutor(&config.Config{}).Execute(context.Background(), cliAuth, req, opts)
+			return err
+		}},
+		{"gemini-cli stream", func() error {
+			_, err := NewGeminiCLIExecutor(&config.Config{}).ExecuteStream(context.Background(), cliAuth, req, opts)
+			return err
+		}},
+		{"vertex", func() error {
+			_, err := NewGeminiVertexExecutor(&config.Config{}).Execute(context.Background(), vertexAuth, req, opts)
+			return err
+		}},
+		{"vertex stream", func() error {
+			_, err := NewGeminiVertexExecutor(&config.Config{}).ExecuteStream(context.Background(), vertexAuth, req, opts)
+			return err
+		}},
+	}
+	for _, tc := range cases {
+		err := tc.run()
+		var status interface{ StatusCode() int }
+		if err == nil || !errors.As(err, &status) || status.StatusCode() != http.StatusBadRequest {
+			t.Errorf("%s: error = %v, want a 400 status error", tc.name, err)
+		}
+	}
+	if got := hits.Load(); got != 0 {
+		t.Fatalf("upstream hits = %d, want 0 for rejected requests", got)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor/helps"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	geminicommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
@@ -332,6 +333,9 @@ func (e *GeminiVertexExecutor) executeWithServiceAccount(ctx context.Context, au
 		if err != nil {
 			return resp, err
 		}
+		if errLimit := geminicommon.CheckFunctionDeclarationLimit(body); errLimit != nil {
+			return resp, statusErr{code: http.StatusBadRequest, msg: errLimit.Error()}
+		}
 
 		body = fixGeminiImageAspectRatio(baseModel, body)
 		requestedModel := helps.PayloadRequestedModel(opts, req.Model)
@@ -453,6 +457,9 @@ func (e *GeminiVertexExecutor) executeWithAPIKey(ctx context.Context, auth *clip
 	if err != nil {
 		return resp, err
 	}
+	if errLimit := geminicommon.CheckFunctionDeclarationLimit(body); errLimit != nil {
+		return resp, statusErr{code: http.StatusBadRequest, msg: errLimit.Error()}
+	}
 
 	body = fixGeminiImageAspectRatio(baseModel, body)
 	requestedModel := helps.PayloadRequestedModel(opts, req.Model)
@@ -564,6 +571,9 @@ func (e *GeminiVertexExecutor) executeStreamWithServiceAccount(ctx context.Conte
 	if err != nil {
 		return nil, err
 	}
+	if errLimit := geminicommon.CheckFunctionDeclarationLimit(body); errLimit != nil {
+		return nil, statusErr{code: http.StatusBadRequest, msg: errLimit.Error()}
+	}
 
 	body = fixGeminiImageAspectRatio(baseModel, body)
 	requestedModel := helps.PayloadRequestedModel(opts, req.Model)
@@ -694,6 +704,9 @@ func (e *GeminiVertexExecutor) executeStreamWithAPIKey(ctx context.Context, auth
 	if err != nil {
 		return nil, err
 	}
+	if errLimit := geminicommon.CheckFunctionDeclarationLimit(body); errLimit != nil {
+		return nil, statusErr{code: http.StatusBadRequest, msg: errLimit.Error()}
+	}
 
 	body = fixGeminiImageAspectRatio(baseModel, body)
 	requestedModel := helps.PayloadRequestedModel(opts, req.Model)
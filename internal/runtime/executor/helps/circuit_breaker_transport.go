@@ -0,0 +1,116 @@
+package helps
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+// circuitOpenBody is the body of the synthetic response returned while the
+// breaker rejects requests.
+const circuitOpenBody = `{"error":{"message":"upstream circuit breaker is open","type":"service_unavailable"}}`
+
+// CircuitBreakerTransport fails fast once an upstream keeps failing. Transport
+// errors and 5xx responses count as failures; requests whose context is
+// cancelled are not counted. After threshold consecutive
+// failures the breaker opens and every request receives a synthetic 503 without
+// reaching the upstream. Once the timeout elapses a single probe request is let
+// through; its outcome closes the breaker again or reopens it.
+type CircuitBreakerTransport struct {
+	// Base is the underlying transport; http.DefaultTransport is used when nil.
+	Base    http.RoundTripper
+	breaker *gobreaker.TwoStepCircuitBreaker
+}
+
+// NewCircuitBreakerTransport creates a transport that opens after threshold
+// consecutive failures and attempts recovery after timeout. A non-positive
+// threshold is treated as 1; a non-positive timeout uses the gobreaker default
+// of 60 seconds.
+func NewCircuitBreakerTransport(base http.RoundTripper, threshold int, timeout time.Duration) *CircuitBreakerTransport {
+	if threshold < 1 {
+		threshold = 1
+	}
+	limit := uint32(threshold)
+	return &CircuitBreakerTransport{
+		Base: base,
+		breaker: gobreaker.NewTwoStepCircuitBreaker(gobreaker.Settings{
+			Name:    "upstream",
+			Timeout: timeout,
+			ReadyToTrip: func(counts gobreaker.Counts) bool {
+				return counts.ConsecutiveFailures >= limit
+			},
+		}),
+	}
+}
+
+// NewCircuitBreakerHTTPClient returns a copy of base whose transport is wrapped
+// in a CircuitBreakerTransport. A nil base uses http.DefaultClient's settings.
+func NewCircuitBreakerHTTPClient(base *http.Client, threshold int, timeout time.Duration) *http.Client {
+	client := &http.Client{}
+	if base != nil {
+		*client = *base
+	}
+	client.Transport = NewCircuitBreakerTransport(client.Transport, threshold, timeout)
+	return client
+}
+
+// State returns the current breaker state.
+func (t *CircuitBreakerTransport) State() gobreaker.State {
+	return t.breaker.State()
+}
+
+// RoundTrip sends req unless the breaker is open, in which case it returns a
+// synthetic 503 response.
+func (t *CircuitBreakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	done, errAllow := t.breaker.Allow()
+	if errAllow != nil {
+		if errors.Is(errAllow, gobreaker.ErrOpenState) || errors.Is(errAllow, gobreaker.ErrTooManyRequests) {
+			return circuitOpenResponse(req), nil
+		}
+		return nil, errAllow
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil && isCanceled(req, err) {
+		// The caller gave up; that says nothing about the upstream. A cancelled
+		// probe still has to be resolved, or the half-open breaker would wait for
+		// it forever, so it reopens the breaker until the next probe.
+		if t.breaker.State() == gobreaker.StateHalfOpen {
+			done(false)
+		}
+		return resp, err
+	}
+	done(err == nil && resp != nil && resp.StatusCode < http.StatusInternalServerError)
+	return resp, err
+}
+
+// isCanceled reports whether err is the result of req's context being cancelled.
+// Deadlines still count as failures: they mean the upstream was too slow.
+func isCanceled(req *http.Request, err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(req.Context().Err(), context.Canceled)
+}
+
+func circuitOpenResponse(req *http.Request) *http.Response {
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	return &http.Response{
+		Status:        "503 Service Unavailable",
+		StatusCode:    http.StatusServiceUnavailable,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(circuitOpenBody))),
+		ContentLength: int64(len(circuitOpenBody)),
+		Request:       req,
+	}
+}
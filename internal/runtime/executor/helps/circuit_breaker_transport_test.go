@@ -0,0 +1,166 @@
+package helps
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+// newToggleServer answers 500 while failing is set and 200 otherwise, counting
+// every request that reaches it.
+func newToggleServer(t *testing.T) (*httptest.Server, *atomic.Bool, *atomic.Int32) {
+	t.Helper()
+	var failing atomic.Bool
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &failing, &hits
+}
+
+func getStatus(t *testing.T, client *http.Client, url string) int {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestCircuitBreakerTransport_States(t *testing.T) {
+	srv, failing, hits := newToggleServer(t)
+	const timeout = 50 * time.Millisecond
+	client := NewCircuitBreakerHTTPClient(srv.Client(), 3, timeout)
+	transport := client.Transport.(*CircuitBreakerTransport)
+
+	// Closed: failures below the threshold still reach the upstream.
+	failing.Store(true)
+	for i := 0; i < 2; i++ {
+		if status := getStatus(t, client, srv.URL); status != http.StatusInternalServerError {
+			t.Fatalf("request %d: status = %d, want 500", i, status)
+		}
+	}
+	if state := transport.State(); state != gobreaker.StateClosed {
+		t.Fatalf("state after 2 failures = %v, want closed", state)
+	}
+
+	// The third consecutive failure opens the breaker.
+	getStatus(t, client, srv.URL)
+	if state := transport.State(); state != gobreaker.StateOpen {
+		t.Fatalf("state after 3 failures = %v, want open", state)
+	}
+
+	// Open: requests get a synthetic 503 without reaching the upstream.
+	before := hits.Load()
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET while open: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("status while open = %d, want 503", resp.StatusCode)
+	}
+	if len(body) == 0 {
+		t.Error("synthetic 503 has an empty body")
+	}
+	if hits.Load() != before {
+		t.Fatal("open breaker let a request reach the upstream")
+	}
+
+	// Half-open after the timeout: a failed probe reopens the breaker.
+	time.Sleep(timeout + 20*time.Millisecond)
+	if state := transport.State(); state != gobreaker.StateHalfOpen {
+		t.Fatalf("state after timeout = %v, want half-open", state)
+	}
+	getStatus(t, client, srv.URL)
+	if state := transport.State(); state != gobreaker.StateOpen {
+		t.Fatalf("state after failed probe = %v, want open", state)
+	}
+
+	// A successful probe closes it again.
+	failing.Store(false)
+	time.Sleep(timeout + 20*time.Millisecond)
+	if status := getStatus(t, client, srv.URL); status != http.StatusOK {
+		t.Fatalf("probe status = %d, want 200", status)
+	}
+	if state := transport.State(); state != gobreaker.StateClosed {
+		t.Fatalf("state after successful probe = %v, want closed", state)
+	}
+}
+
+func TestCircuitBreakerTransport_SuccessResetsFailures(t *testing.T) {
+	srv, failing, _ := newToggleServer(t)
+	client := NewCircuitBreakerHTTPClient(srv.Client(), 2, time.Minute)
+	transport := client.Transport.(*CircuitBreakerTransport)
+
+	for i := 0; i < 3; i++ {
+		failing.Store(true)
+		getStatus(t, client, srv.URL)
+		failing.Store(false)
+		getStatus(t, client, srv.URL)
+	}
+	if state := transport.State(); state != gobreaker.StateClosed {
+		t.Fatalf("state = %v, want closed: failures were never consecutive", state)
+	}
+}
+
+type errorRoundTripper struct{}
+
+func (errorRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("dial failed")
+}
+
+func TestCircuitBreakerTransport_TransportErrorsCount(t *testing.T) {
+	client := NewCircuitBreakerHTTPClient(&http.Client{Transport: errorRoundTripper{}}, 1, time.Minute)
+
+	if _, err := client.Get("http://upstream.invalid/"); err == nil {
+		t.Fatal("expected the transport error to be returned")
+	}
+	resp, err := client.Get("http://upstream.invalid/")
+	if err != nil {
+		t.Fatalf("GET while open: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", resp.StatusCode)
+	}
+}
+
+type canceledRoundTripper struct{}
+
+func (canceledRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, context.Canceled
+}
+
+func TestCircuitBreakerTransport_CanceledRequestsAreNeutral(t *testing.T) {
+	transport := NewCircuitBreakerTransport(canceledRoundTripper{}, 1, time.Minute)
+	req, errReq := http.NewRequest(http.MethodGet, "http://upstream.invalid/", nil)
+	if errReq != nil {
+		t.Fatalf("NewRequest: %v", errReq)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := transport.RoundTrip(req); !errors.Is(err, context.Canceled) {
+			t.Fatalf("request %d: err = %v, want context.Canceled", i, err)
+		}
+	}
+	if state := transport.State(); state != gobreaker.StateClosed {
+		t.Fatalf("state after cancelled requests = %v, want closed", state)
+	}
+}
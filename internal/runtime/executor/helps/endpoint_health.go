@@ -0,0 +1,115 @@
+package helps
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Defaults for endpoint health tracking: an endpoint is unhealthy when more than
+// half of its last 10 responses were 5xx, and is probed again after the recovery delay.
+const (
+	DefaultEndpointHealthWindow       = 10
+	DefaultEndpointHealthMaxErrorRate = 0.5
+	DefaultEndpointHealthRecovery     = 30 * time.Second
+)
+
+// EndpointHealth tracks the outcome of an endpoint's most recent responses in a
+// fixed-size sliding window.
+type EndpointHealth struct {
+	mu           sync.Mutex
+	window       []bool // true = failure (5xx)
+	next         int
+	count        int
+	failures     int
+	maxErrorRate float64
+	recovery     time.Duration
+	lastUpdate   time.Time
+	now          func() time.Time
+}
+
+// NewEndpointHealth creates a tracker over the last windowSize responses that
+// reports unhealthy once the 5xx rate exceeds maxErrorRate. After recovery has
+// passed without new results, an unhealthy endpoint is reported healthy again so
+// it can be probed. Non-positive arguments fall back to the defaults.
+func NewEndpointHealth(windowSize int, maxErrorRate float64, recovery time.Duration) *EndpointHealth {
+	if windowSize <= 0 {
+		windowSize = DefaultEndpointHealthWindow
+	}
+	if maxErrorRate <= 0 {
+		maxErrorRate = DefaultEndpointHealthMaxErrorRate
+	}
+	if recovery <= 0 {
+		recovery = DefaultEndpointHealthRecovery
+	}
+	return &EndpointHealth{
+		window:       make([]bool, windowSize),
+		maxErrorRate: maxErrorRate,
+		recovery:     recovery,
+		now:          time.Now,
+	}
+}
+
+// RecordStatus records a response status code; 5xx codes count as failures.
+func (h *EndpointHealth) RecordStatus(statusCode int) {
+	h.record(statusCode >= http.StatusInternalServerError)
+}
+
+func (h *EndpointHealth) record(failed bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.count == len(h.window) {
+		if h.window[h.next] {
+			h.failures--
+		}
+	} else {
+		h.count++
+	}
+	h.window[h.next] = failed
+	if failed {
+		h.failures++
+	}
+	h.next = (h.next + 1) % len(h.window)
+	h.lastUpdate = h.now()
+}
+
+// IsHealthy reports whether the endpoint may be selected. The window must be
+// full before an endpoint can be marked unhealthy.
+func (h *EndpointHealth) IsHealthy() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.count < len(h.window) {
+		return true
+	}
+	if float64(h.failures)/float64(h.count) <= h.maxErrorRate {
+		return true
+	}
+	return h.now().Sub(h.lastUpdate) >= h.recovery
+}
+
+// Counts returns the number of successes and failures in the current window.
+func (h *EndpointHealth) Counts() (successes, failures int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count - h.failures, h.failures
+}
+
+var (
+	endpointHealthMu       sync.Mutex
+	endpointHealthRegistry = make(map[string]*EndpointHealth)
+)
+
+// EndpointHealthFor returns the shared tracker for endpoint, creating one with the
+// default thresholds on first use.
+func EndpointHealthFor(endpoint string) *EndpointHealth {
+	endpointHealthMu.Lock()
+	defer endpointHealthMu.Unlock()
+	h, ok := endpointHealthRegistry[endpoint]
+	if !ok {
+		h = NewEndpointHealth(DefaultEndpointHealthWindow, DefaultEndpointHealthMaxErrorRate, DefaultEndpointHealthRecovery)
+		endpointHealthRegistry[endpoint] = h
+	}
+	return h
+}
@@ -0,0 +1,102 @@
+package helps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newAlternatingServer answers 200, 503, 200, 503, ...
+func newAlternatingServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1)%2 == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func recordRequests(t *testing.T, h *EndpointHealth, url string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		_ = resp.Body.Close()
+		h.RecordStatus(resp.StatusCode)
+	}
+}
+
+func TestEndpointHealth_AlternatingResponses(t *testing.T) {
+	srv := newAlternatingServer(t)
+
+	// 50% failures does not exceed a 50% threshold.
+	atHalf := NewEndpointHealth(10, 0.5, time.Minute)
+	recordRequests(t, atHalf, srv.URL, 10)
+	if successes, failures := atHalf.Counts(); successes != 5 || failures != 5 {
+		t.Fatalf("Counts() = %d/%d, want 5/5", successes, failures)
+	}
+	if !atHalf.IsHealthy() {
+		t.Fatal("expected endpoint at exactly 50% failures to stay healthy")
+	}
+
+	// The same traffic exceeds a stricter 40% threshold.
+	strict := NewEndpointHealth(10, 0.4, time.Minute)
+	recordRequests(t, strict, srv.URL, 10)
+	if strict.IsHealthy() {
+		t.Fatal("expected endpoint above 40% failures to be unhealthy")
+	}
+}
+
+func TestEndpointHealth_RequiresFullWindow(t *testing.T) {
+	h := NewEndpointHealth(10, 0.5, time.Minute)
+	for i := 0; i < 9; i++ {
+		h.RecordStatus(http.StatusServiceUnavailable)
+	}
+	if !h.IsHealthy() {
+		t.Fatal("expected endpoint to stay healthy until the window is full")
+	}
+	h.RecordStatus(http.StatusBadGateway)
+	if h.IsHealthy() {
+		t.Fatal("expected endpoint with a full window of 5xx to be unhealthy")
+	}
+}
+
+func TestEndpointHealth_SlidingWindowAndRecovery(t *testing.T) {
+	now := time.Now()
+	h := NewEndpointHealth(4, 0.5, time.Minute)
+	h.now = func() time.Time { return now }
+
+	for i := 0; i < 4; i++ {
+		h.RecordStatus(http.StatusInternalServerError)
+	}
+	if h.IsHealthy() {
+		t.Fatal("expected unhealthy after 4 failures")
+	}
+
+	// Old failures slide out of the window as successes arrive.
+	h.RecordStatus(http.StatusOK)
+	h.RecordStatus(http.StatusOK)
+	if !h.IsHealthy() {
+		t.Fatal("expected healthy once failures drop to 50%")
+	}
+
+	for i := 0; i < 4; i++ {
+		h.RecordStatus(http.StatusServiceUnavailable)
+	}
+	if h.IsHealthy() {
+		t.Fatal("expected unhealthy after new failures")
+	}
+	now = now.Add(time.Minute)
+	if !h.IsHealthy() {
+		t.Fatal("expected endpoint to be probed again after the recovery delay")
+	}
+}
@@ -0,0 +1,163 @@
+package helps
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultGeminiKeyBackoff is how long a key is skipped after it returns 429.
+const DefaultGeminiKeyBackoff = 60 * time.Second
+
+// MultiKeyGeminiTransport rotates requests across several Gemini API keys by
+// rewriting the x-goog-api-key header. A key that receives 429 Too Many
+// Requests is skipped for the backoff period. Copies made with WithBase share
+// rotation and backoff state.
+type MultiKeyGeminiTransport struct {
+	// Base is the underlying transport; http.DefaultTransport is used when nil.
+	Base  http.RoundTripper
+	state *multiKeyState
+}
+
+type multiKeyState struct {
+	keys      []string
+	next      atomic.Uint64
+	backoff   time.Duration
+	mu        sync.Mutex
+	skipUntil []time.Time
+	now       func() time.Time
+}
+
+// NewMultiKeyGeminiTransport creates a transport rotating through keys. Blank and
+// duplicate keys are dropped. A non-positive backoff uses DefaultGeminiKeyBackoff.
+func NewMultiKeyGeminiTransport(keys []string, backoff time.Duration, base http.RoundTripper) *MultiKeyGeminiTransport {
+	if backoff <= 0 {
+		backoff = DefaultGeminiKeyBackoff
+	}
+	seen := make(map[string]struct{}, len(keys))
+	unique := make([]string, 0, len(keys))
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		unique = append(unique, key)
+	}
+	return &MultiKeyGeminiTransport{
+		Base: base,
+		state: &multiKeyState{
+			keys:      unique,
+			backoff:   backoff,
+			skipUntil: make([]time.Time, len(unique)),
+			now:       time.Now,
+		},
+	}
+}
+
+// WithBase returns a copy of t that sends requests through base while sharing
+// key rotation and backoff state with t.
+func (t *MultiKeyGeminiTransport) WithBase(base http.RoundTripper) *MultiKeyGeminiTransport {
+	return &MultiKeyGeminiTransport{Base: base, state: t.state}
+}
+
+// RoundTrip sends req with the next available key and backs the key off on 429.
+func (t *MultiKeyGeminiTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if t.state == nil || len(t.state.keys) == 0 {
+		return base.RoundTrip(req)
+	}
+
+	idx := t.state.pick()
+	out := req.Clone(req.Context())
+	out.Header.Set("x-goog-api-key", t.state.keys[idx])
+	if out.URL.Query().Has("key") {
+		query := out.URL.Query()
+		query.Del("key")
+		out.URL.RawQuery = query.Encode()
+	}
+
+	resp, err := base.RoundTrip(out)
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		t.state.markBackoff(idx)
+	}
+	return resp, err
+}
+
+// pick returns the next key index in round-robin order, skipping keys in backoff.
+// When every key is backing off the round-robin choice is used anyway.
+func (s *multiKeyState) pick() int {
+	n := uint64(len(s.keys))
+	start := (s.next.Add(1) - 1) % n
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for i := uint64(0); i < n; i++ {
+		idx := (start + i) % n
+		if !now.Before(s.skipUntil[idx]) {
+			return int(idx)
+		}
+	}
+	return int(start)
+}
+
+func (s *multiKeyState) markBackoff(idx int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.skipUntil[idx] = s.now().Add(s.backoff)
+}
+
+var (
+	geminiMultiKeyMu       sync.Mutex
+	geminiMultiKeyRegistry = make(map[string]*MultiKeyGeminiTransport)
+)
+
+// MultiKeyGeminiTransportFor returns the shared transport for keys so rotation
+// and backoff persist across requests. It returns nil when fewer than two keys
+// are provided.
+func MultiKeyGeminiTransportFor(keys []string) *MultiKeyGeminiTransport {
+	t := NewMultiKeyGeminiTransport(keys, DefaultGeminiKeyBackoff, nil)
+	if len(t.state.keys) < 2 {
+		return nil
+	}
+	id := multiKeyRegistryID(t.state.keys)
+
+	geminiMultiKeyMu.Lock()
+	defer geminiMultiKeyMu.Unlock()
+	if existing, ok := geminiMultiKeyRegistry[id]; ok {
+		return existing
+	}
+	geminiMultiKeyRegistry[id] = t
+	return t
+}
+
+// PruneMultiKeyGeminiTransports drops the shared transports whose key set is not
+// in keySets, so key sets removed by a config reload do not stay registered.
+func PruneMultiKeyGeminiTransports(keySets [][]string) {
+	keep := make(map[string]struct{}, len(keySets))
+	for _, keys := range keySets {
+		normalized := NewMultiKeyGeminiTransport(keys, DefaultGeminiKeyBackoff, nil).state.keys
+		keep[multiKeyRegistryID(normalized)] = struct{}{}
+	}
+
+	geminiMultiKeyMu.Lock()
+	defer geminiMultiKeyMu.Unlock()
+	for id := range geminiMultiKeyRegistry {
+		if _, ok := keep[id]; !ok {
+			delete(geminiMultiKeyRegistry, id)
+		}
+	}
+}
+
+func multiKeyRegistryID(keys []string) string {
+	return strings.Join(keys, "\x00")
+}
@@ -0,0 +1,128 @@
+package helps
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newKeyRecordingServer records the API key of each request and answers 429
+// for keys listed in limited.
+func newKeyRecordingServer(t *testing.T, limited map[string]bool) (*httptest.Server, func() []string) {
+	t.Helper()
+	var (
+		mu   sync.Mutex
+		seen []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("x-goog-api-key")
+		mu.Lock()
+		seen = append(seen, key)
+		mu.Unlock()
+		if limited[key] {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), seen...)
+	}
+}
+
+func sendRequests(t *testing.T, client *http.Client, url string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		req, _ := http.NewRequest(http.MethodGet, url+"?key=original", nil)
+		req.Header.Set("x-goog-api-key", "original")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		_ = resp.Body.Close()
+	}
+}
+
+func TestMultiKeyGeminiTransport_RotatesKeys(t *testing.T) {
+	srv, seen := newKeyRecordingServer(t, nil)
+	transport := NewMultiKeyGeminiTransport([]string{"k1", "k2", "", "k2", "k3"}, 0, srv.Client().Transport)
+	sendRequests(t, &http.Client{Transport: transport}, srv.URL, 6)
+
+	want := []string{"k1", "k2", "k3", "k1", "k2", "k3"}
+	got := seen()
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("keys = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestMultiKeyGeminiTransport_SkipsRateLimitedKey(t *testing.T) {
+	srv, seen := newKeyRecordingServer(t, map[string]bool{"k2": true})
+	transport := NewMultiKeyGeminiTransport([]string{"k1", "k2", "k3"}, time.Minute, srv.Client().Transport)
+	now := time.Unix(1_700_000_000, 0)
+	transport.state.now = func() time.Time { return now }
+	client := &http.Client{Transport: transport}
+
+	sendRequests(t, client, srv.URL, 6)
+	got := seen()
+	want := []string{"k1", "k2", "k3", "k1", "k3", "k3"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("keys = %v, want %v", got, want)
+		}
+	}
+
+	// After the backoff expires the key is used again.
+	now = now.Add(2 * time.Minute)
+	sendRequests(t, client, srv.URL, 3)
+	got = seen()[6:]
+	found := false
+	for _, key := range got {
+		found = found || key == "k2"
+	}
+	if !found {
+		t.Fatalf("k2 not retried after backoff: %v", got)
+	}
+}
+
+func TestMultiKeyGeminiTransport_WithBaseSharesState(t *testing.T) {
+	srv, seen := newKeyRecordingServer(t, nil)
+	transport := NewMultiKeyGeminiTransport([]string{"k1", "k2"}, 0, nil)
+	sendRequests(t, &http.Client{Transport: transport.WithBase(srv.Client().Transport)}, srv.URL, 1)
+	sendRequests(t, &http.Client{Transport: transport.WithBase(srv.Client().Transport)}, srv.URL, 1)
+
+	if got := seen(); got[0] != "k1" || got[1] != "k2" {
+		t.Fatalf("keys = %v, want [k1 k2]", got)
+	}
+}
+
+func TestMultiKeyGeminiTransportFor(t *testing.T) {
+	if MultiKeyGeminiTransportFor([]string{"only"}) != nil {
+		t.Fatal("single key should not create a rotating transport")
+	}
+	a := MultiKeyGeminiTransportFor([]string{"shared-1", "shared-2"})
+	b := MultiKeyGeminiTransportFor([]string{"shared-1", "shared-2"})
+	if a == nil || a != b {
+		t.Fatal("expected the same shared transport for identical keys")
+	}
+}
+
+func TestPruneMultiKeyGeminiTransports(t *testing.T) {
+	kept := MultiKeyGeminiTransportFor([]string{"prune-keep-1", "prune-keep-2"})
+	dropped := MultiKeyGeminiTransportFor([]string{"prune-drop-1", "prune-drop-2"})
+
+	PruneMultiKeyGeminiTransports([][]string{{" prune-keep-1", "prune-keep-2", "prune-keep-1"}})
+
+	if MultiKeyGeminiTransportFor([]string{"prune-keep-1", "prune-keep-2"}) != kept {
+		t.Fatal("referenced key set should keep its shared transport")
+	}
+	if MultiKeyGeminiTransportFor([]string{"prune-drop-1", "prune-drop-2"}) == dropped {
+		t.Fatal("unreferenced key set should have been pruned")
+	}
+}
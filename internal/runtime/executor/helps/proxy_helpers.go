@@ -2,12 +2,14 @@ package helps
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/proxyutil"
 	log "github.com/sirupsen/logrus"
@@ -46,6 +48,15 @@ func NewProxyAwareHTTPClient(ctx context.Context, cfg *config.Config, auth *clip
 		proxyURL = strings.TrimSpace(cfg.ProxyURL)
 	}
 
+	// Provider-specific transport settings get their own cached transport.
+	if auth != nil {
+		if providerHTTP, ok := cfg.ProviderHTTPFor(auth.Provider); ok {
+			if client := providerHTTPClient(ctx, proxyURL, strings.ToLower(strings.TrimSpace(auth.Provider)), providerHTTP, timeout); client != nil {
+				return client
+			}
+		}
+	}
+
 	// If we have a proxy URL configured, try cache first to reuse TCP/TLS connections.
 	if proxyURL != "" {
 		httpClientCacheMutex.RLock()
@@ -104,3 +115,65 @@ func buildProxyTransport(proxyURL string) *http.Transport {
 	}
 	return transport
 }
+
+// providerHTTPClient returns a client whose transport applies the provider's
+// ProviderHTTPConfig on top of the proxy (or default) transport, behind a circuit
+// breaker when CircuitBreakerThreshold is set. Transports are cached per provider
+// and proxy URL, so the breaker state is shared by all of the provider's requests,
+// whichever account sends them. It returns nil when no proxy is configured and
+// the context carries its own RoundTripper, which is then used unchanged.
+func providerHTTPClient(ctx context.Context, proxyURL, provider string, providerHTTP config.ProviderHTTPConfig, timeout time.Duration) *http.Client {
+	if proxyURL == "" {
+		if rt, ok := ctx.Value("cliproxy.roundtripper").(http.RoundTripper); ok && rt != nil {
+			return nil
+		}
+	}
+
+	// The settings are part of the key so a config reload builds a fresh transport.
+	cacheKey := fmt.Sprintf("provider:%s|%s|%+v", provider, proxyURL, providerHTTP)
+	httpClientCacheMutex.RLock()
+	cachedClient, ok := httpClientCache[cacheKey]
+	httpClientCacheMutex.RUnlock()
+	if !ok {
+		var transport *http.Transport
+		if proxyURL != "" {
+			transport = buildProxyTransport(proxyURL)
+		}
+		if transport == nil {
+			base, isTransport := http.DefaultTransport.(*http.Transport)
+			if !isTransport {
+				return nil
+			}
+			transport = base.Clone()
+		}
+		ApplyProviderHTTPConfig(transport, providerHTTP)
+		cachedClient = &http.Client{Transport: transport}
+		if providerHTTP.CircuitBreakerThreshold > 0 {
+			cachedClient = NewCircuitBreakerHTTPClient(cachedClient, providerHTTP.CircuitBreakerThreshold, providerHTTP.CircuitBreakerTimeout)
+		}
+		httpClientCacheMutex.Lock()
+		httpClientCache[cacheKey] = cachedClient
+		httpClientCacheMutex.Unlock()
+	}
+	return &http.Client{Transport: cachedClient.Transport, Timeout: timeout}
+}
+
+// ApplyProviderHTTPConfig sets the non-zero fields of providerHTTP on transport.
+func ApplyProviderHTTPConfig(transport *http.Transport, providerHTTP config.ProviderHTTPConfig) {
+	if transport == nil {
+		return
+	}
+	util.SetDialTimeout(transport, providerHTTP.ConnectTimeout)
+	if providerHTTP.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = providerHTTP.TLSHandshakeTimeout
+	}
+	if providerHTTP.ResponseHeaderTimeout > 0 {
+		transport.ResponseHeaderTimeout = providerHTTP.ResponseHeaderTimeout
+	}
+	if providerHTTP.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = providerHTTP.IdleConnTimeout
+	}
+	if providerHTTP.MaxIdleConns > 0 {
+		transport.MaxIdleConns = providerHTTP.MaxIdleConns
+	}
+}
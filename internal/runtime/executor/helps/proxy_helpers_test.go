@@ -3,7 +3,10 @@ package helps
 import (
 	"context"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
@@ -28,3 +31,104 @@ func TestNewProxyAwareHTTPClientDirectBypassesGlobalProxy(t *testing.T) {
 		t.Fatal("expected direct transport to disable proxy function")
 	}
 }
+
+func TestNewProxyAwareHTTPClientProviderResponseHeaderTimeout(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := &config.Config{ProviderHTTP: map[string]config.ProviderHTTPConfig{
+		"Slow-Header-Test": {ResponseHeaderTimeout: 50 * time.Millisecond, IdleConnTimeout: 5 * time.Second, MaxIdleConns: 7},
+	}}
+
+	client := NewProxyAwareHTTPClient(context.Background(), cfg, &cliproxyauth.Auth{Provider: "slow-header-test"}, 0)
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("transport type = %T, want *http.Transport", client.Transport)
+	}
+	if transport.ResponseHeaderTimeout != 50*time.Millisecond || transport.IdleConnTimeout != 5*time.Second || transport.MaxIdleConns != 7 {
+		t.Fatalf("transport settings = %v/%v/%d, want the provider config", transport.ResponseHeaderTimeout, transport.IdleConnTimeout, transport.MaxIdleConns)
+	}
+	resp, err := client.Get(srv.URL)
+	if err == nil {
+		_ = resp.Body.Close()
+		t.Fatal("expected the delayed response header to time out")
+	}
+	if !strings.Contains(err.Error(), "timeout awaiting response headers") {
+		t.Fatalf("error = %v, want a response header timeout", err)
+	}
+
+	// Providers without settings keep the default transport and wait for the header.
+	other := NewProxyAwareHTTPClient(context.Background(), cfg, &cliproxyauth.Auth{Provider: "other-provider"}, 0)
+	resp, err = other.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("unconfigured provider: %v", err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestApplyProviderHTTPConfigKeepsUnsetFields(t *testing.T) {
+	t.Parallel()
+
+	transport := &http.Transport{ResponseHeaderTimeout: time.Minute, IdleConnTimeout: 90 * time.Second, MaxIdleConns: 100}
+	ApplyProviderHTTPConfig(transport, config.ProviderHTTPConfig{ConnectTimeout: time.Second})
+	if transport.DialContext == nil {
+		t.Fatal("ConnectTimeout should install a DialContext")
+	}
+	if transport.ResponseHeaderTimeout != time.Minute || transport.IdleConnTimeout != 90*time.Second || transport.MaxIdleConns != 100 {
+		t.Fatalf("unset fields changed: %v/%v/%d", transport.ResponseHeaderTimeout, transport.IdleConnTimeout, transport.MaxIdleConns)
+	}
+}
+
+func TestNewProxyAwareHTTPClientRebuildsTransportWhenSettingsChange(t *testing.T) {
+	t.Parallel()
+
+	auth := &cliproxyauth.Auth{Provider: "reload-test"}
+	newClient := func(timeout time.Duration) *http.Transport {
+		cfg := &config.Config{ProviderHTTP: map[string]config.ProviderHTTPConfig{
+			"reload-test": {ResponseHeaderTimeout: timeout},
+		}}
+		transport, ok := NewProxyAwareHTTPClient(context.Background(), cfg, auth, 0).Transport.(*http.Transport)
+		if !ok {
+			t.Fatal("expected an *http.Transport")
+		}
+		return transport
+	}
+
+	if got := newClient(time.Second).ResponseHeaderTimeout; got != time.Second {
+		t.Fatalf("ResponseHeaderTimeout = %v, want 1s", got)
+	}
+	if got := newClient(2 * time.Second).ResponseHeaderTimeout; got != 2*time.Second {
+		t.Fatalf("ResponseHeaderTimeout after the settings changed = %v, want 2s", got)
+	}
+}
+
+func TestNewProxyAwareHTTPClientProviderCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := &config.Config{ProviderHTTP: map[string]config.ProviderHTTPConfig{
+		"breaker-test": {CircuitBreakerThreshold: 1, CircuitBreakerTimeout: time.Minute},
+	}}
+	auth := &cliproxyauth.Auth{Provider: "breaker-test"}
+	client := NewProxyAwareHTTPClient(context.Background(), cfg, auth, 0)
+	if _, ok := client.Transport.(*CircuitBreakerTransport); !ok {
+		t.Fatalf("transport type = %T, want *CircuitBreakerTransport", client.Transport)
+	}
+	if status := getStatus(t, client, srv.URL); status != http.StatusBadGateway {
+		t.Fatalf("first status = %d, want the upstream 502", status)
+	}
+	// A later client for the same provider shares the cached, now open, breaker.
+	again := NewProxyAwareHTTPClient(context.Background(), cfg, auth, 0)
+	if status := getStatus(t, again, srv.URL); status != http.StatusServiceUnavailable {
+		t.Fatalf("status while open = %d, want 503", status)
+	}
+}
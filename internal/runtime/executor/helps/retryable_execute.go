@@ -0,0 +1,99 @@
+package helps
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRetryDelay caps the backoff between RetryableExecute attempts.
+const maxRetryDelay = 30 * time.Second
+
+// RetryableExecute calls fn until it returns a response that is not a transient
+// failure (429, 500, 502, 503 or 504), an error, or maxAttempts calls have been
+// made. The wait between attempts starts at baseDelay and doubles each time, up
+// to 30 seconds; a Retry-After header on the failed response replaces it, and
+// one asking for more than 30 seconds ends the retries. Bodies of retried
+// responses are drained and closed; the last response is returned unchanged so
+// the caller can report it. A cancelled ctx stops the retries and returns
+// ctx.Err(). maxAttempts below 1 is treated as 1.
+func RetryableExecute(ctx context.Context, fn func() (*http.Response, error), maxAttempts int, baseDelay time.Duration) (*http.Response, error) {
+	return RetryableExecuteFor(ctx, fn, maxAttempts, baseDelay, IsRetryableResponse)
+}
+
+// RetryableExecuteFor is RetryableExecute with retryable deciding which
+// responses are retried.
+func RetryableExecuteFor(ctx context.Context, fn func() (*http.Response, error), maxAttempts int, baseDelay time.Duration, retryable func(*http.Response) bool) (*http.Response, error) {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	delay := baseDelay
+	for attempt := 1; ; attempt++ {
+		resp, err := fn()
+		if err != nil || resp == nil || !retryable(resp) || attempt >= maxAttempts {
+			return resp, err
+		}
+		wait := delay
+		if retryAfter, ok := ParseRetryAfter(resp.Header, time.Now()); ok {
+			if retryAfter > maxRetryDelay {
+				return resp, nil
+			}
+			wait = retryAfter
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+		} else if errCtx := ctx.Err(); errCtx != nil {
+			return nil, errCtx
+		}
+		if delay > 0 {
+			delay = min(delay*2, maxRetryDelay)
+		}
+	}
+}
+
+// IsRetryableResponse reports whether resp is a transient failure: 429, 500,
+// 502, 503 or 504.
+func IsRetryableResponse(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// ParseRetryAfter returns the wait requested by a Retry-After header, given
+// either as delay seconds or as an HTTP date relative to now. ok is false when
+// the header is missing or malformed.
+func ParseRetryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	if seconds, errAtoi := strconv.Atoi(value); errAtoi == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	at, errParse := http.ParseTime(value)
+	if errParse != nil {
+		return 0, false
+	}
+	return max(at.Sub(now), 0), true
+}
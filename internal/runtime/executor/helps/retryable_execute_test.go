@@ -0,0 +1,174 @@
+package helps
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// trackedBody records whether it was closed.
+type trackedBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *trackedBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+// failingFn returns status for the first failures calls and 200 afterwards.
+func failingFn(failures, status int) (func() (*http.Response, error), *int, *[]*trackedBody) {
+	calls := 0
+	var bodies []*trackedBody
+	return func() (*http.Response, error) {
+		calls++
+		body := &trackedBody{Reader: strings.NewReader("body")}
+		bodies = append(bodies, body)
+		code := http.StatusOK
+		if calls <= failures {
+			code = status
+		}
+		return &http.Response{StatusCode: code, Body: body}, nil
+	}, &calls, &bodies
+}
+
+func TestRetryableExecuteRetriesTransientStatuses(t *testing.T) {
+	for _, status := range []int{429, 500, 502, 503, 504} {
+		fn, calls, bodies := failingFn(2, status)
+		resp, err := RetryableExecute(context.Background(), fn, 3, time.Millisecond)
+		if err != nil {
+			t.Fatalf("status %d: %v", status, err)
+		}
+		if resp.StatusCode != http.StatusOK || *calls != 3 {
+			t.Fatalf("status %d: got %d after %d calls, want 200 after 3", status, resp.StatusCode, *calls)
+		}
+		for i, body := range (*bodies)[:2] {
+			if !body.closed {
+				t.Errorf("status %d: retried response %d was not closed", status, i)
+			}
+		}
+		if (*bodies)[2].closed {
+			t.Errorf("status %d: returned response was closed", status)
+		}
+	}
+}
+
+func TestRetryableExecuteStopsAtMaxAttempts(t *testing.T) {
+	fn, calls, bodies := failingFn(5, http.StatusServiceUnavailable)
+	resp, err := RetryableExecute(context.Background(), fn, 3, time.Millisecond)
+	if err != nil {
+		t.Fatalf("RetryableExecute: %v", err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable || *calls != 3 {
+		t.Fatalf("got %d after %d calls, want 503 after 3", resp.StatusCode, *calls)
+	}
+	if (*bodies)[2].closed {
+		t.Error("the final response must be returned open")
+	}
+}
+
+func TestRetryableExecuteDoesNotRetryOtherResults(t *testing.T) {
+	fn, calls, _ := failingFn(1, http.StatusBadRequest)
+	resp, err := RetryableExecute(context.Background(), fn, 3, time.Millisecond)
+	if err != nil || resp.StatusCode != http.StatusBadRequest || *calls != 1 {
+		t.Fatalf("400: got (%v, %v) after %d calls, want the 400 after 1", resp, err, *calls)
+	}
+
+	errBoom := errors.New("boom")
+	errCalls := 0
+	_, err = RetryableExecute(context.Background(), func() (*http.Response, error) {
+		errCalls++
+		return nil, errBoom
+	}, 3, time.Millisecond)
+	if !errors.Is(err, errBoom) || errCalls != 1 {
+		t.Fatalf("error: got %v after %d calls, want boom after 1", err, errCalls)
+	}
+}
+
+func TestRetryableExecuteBacksOffExponentially(t *testing.T) {
+	var stamps []time.Time
+	fn := func() (*http.Response, error) {
+		stamps = append(stamps, time.Now())
+		return &http.Response{StatusCode: http.StatusTooManyRequests, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}
+	if _, err := RetryableExecute(context.Background(), fn, 3, 20*time.Millisecond); err != nil {
+		t.Fatalf("RetryableExecute: %v", err)
+	}
+	if first, second := stamps[1].Sub(stamps[0]), stamps[2].Sub(stamps[1]); first < 20*time.Millisecond || second < 40*time.Millisecond {
+		t.Fatalf("delays = %v, %v, want at least 20ms then 40ms", first, second)
+	}
+}
+
+func TestRetryableExecuteHonorsContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	fn, calls, _ := failingFn(5, http.StatusBadGateway)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	_, err := RetryableExecute(ctx, fn, 5, time.Hour)
+	if !errors.Is(err, context.Canceled) || *calls != 1 {
+		t.Fatalf("got %v after %d calls, want context.Canceled after 1", err, *calls)
+	}
+}
+
+func TestRetryableExecuteHonorsRetryAfter(t *testing.T) {
+	var stamps []time.Time
+	fn := func() (*http.Response, error) {
+		stamps = append(stamps, time.Now())
+		header := http.Header{}
+		status := http.StatusOK
+		if len(stamps) == 1 {
+			header.Set("Retry-After", "1")
+			status = http.StatusServiceUnavailable
+		}
+		return &http.Response{StatusCode: status, Header: header, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}
+	resp, err := RetryableExecute(context.Background(), fn, 3, time.Millisecond)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("RetryableExecute = (%v, %v), want 200", resp, err)
+	}
+	if wait := stamps[1].Sub(stamps[0]); wait < time.Second {
+		t.Fatalf("waited %v, want the 1s Retry-After", wait)
+	}
+
+	calls := 0
+	long := func() (*http.Response, error) {
+		calls++
+		header := http.Header{"Retry-After": []string{"120"}}
+		return &http.Response{StatusCode: http.StatusTooManyRequests, Header: header, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}
+	if resp, _ := RetryableExecute(context.Background(), long, 3, time.Millisecond); resp.StatusCode != http.StatusTooManyRequests || calls != 1 {
+		t.Fatalf("long Retry-After: got %d after %d calls, want the 429 after 1", resp.StatusCode, calls)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"7", 7 * time.Second, true},
+		{"-1", 0, false},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.value != "" {
+			header.Set("Retry-After", tt.value)
+		}
+		if got, ok := ParseRetryAfter(header, now); got != tt.want || ok != tt.ok {
+			t.Errorf("ParseRetryAfter(%q) = (%v, %v), want (%v, %v)", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
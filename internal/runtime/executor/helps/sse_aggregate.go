@@ -0,0 +1,422 @@
+package helps
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// maxSSELineSize bounds a single SSE line read by AggregateSSEStream.
+const maxSSELineSize = 50 * 1024 * 1024
+
+// AggregateSSEStream reads a complete SSE stream and merges its data events into
+// the equivalent non-streaming JSON response. Both Anthropic Messages streams
+// (message_start, content_block_* and message_delta events) and Gemini
+// streamGenerateContent streams (one GenerateContentResponse per event, optionally
+// wrapped in a {"response": ...} envelope) are supported; the format is detected
+// from the first event. A [DONE] sentinel is ignored. An Anthropic error event,
+// a data event that is not valid JSON or a stream with no events is an error.
+func AggregateSSEStream(r io.Reader) ([]byte, error) {
+	events, err := readSSEDataEvents(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, errors.New("sse aggregate: stream contained no data events")
+	}
+
+	first := gjson.ParseBytes(events[0])
+	switch {
+	case first.Get("type").Exists():
+		return aggregateClaudeSSEEvents(events)
+	case first.Get("candidates").Exists(), first.Get("usageMetadata").Exists():
+		return aggregateGeminiSSEEvents(events, false)
+	case first.Get("response").IsObject():
+		return aggregateGeminiSSEEvents(events, true)
+	default:
+		return nil, fmt.Errorf("sse aggregate: unrecognized stream format: %s", truncateForError(events[0]))
+	}
+}
+
+// readSSEDataEvents returns the data payload of every event in r. Multiple data
+// lines in one event are joined with newlines as the SSE specification requires.
+func readSSEDataEvents(r io.Reader) ([][]byte, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSSELineSize)
+
+	var (
+		events  [][]byte
+		current []byte
+		hasData bool
+	)
+	flush := func() error {
+		if !hasData {
+			return nil
+		}
+		payload := bytes.TrimSpace(current)
+		current, hasData = nil, false
+		if len(payload) == 0 || bytes.Equal(payload, []byte("[DONE]")) {
+			return nil
+		}
+		if !gjson.ValidBytes(payload) {
+			return fmt.Errorf("sse aggregate: invalid JSON in data event: %s", truncateForError(payload))
+		}
+		events = append(events, payload)
+		return nil
+	}
+
+	for scanner.Scan() {
+		line := bytes.TrimRight(scanner.Bytes(), "\r")
+		if len(line) == 0 {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		data := bytes.TrimPrefix(line[len("data:"):], []byte(" "))
+		if hasData {
+			current = append(current, '\n')
+		}
+		current = append(current, data...)
+		hasData = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("sse aggregate: read stream: %w", err)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+type claudeBlockAccumulator struct {
+	block       []byte
+	text        strings.Builder
+	thinking    strings.Builder
+	partialJSON strings.Builder
+	signature   string
+	citations   []string
+}
+
+func aggregateClaudeSSEEvents(events [][]byte) ([]byte, error) {
+	var (
+		message []byte
+		blocks  = map[int64]*claudeBlockAccumulator{}
+		order   []int64
+		err     error
+	)
+
+	for _, event := range events {
+		root := gjson.ParseBytes(event)
+		switch root.Get("type").String() {
+		case "message_start":
+			message = []byte(root.Get("message").Raw)
+		case "content_block_start":
+			idx := root.Get("index").Int()
+			if _, ok := blocks[idx]; !ok {
+				order = append(order, idx)
+			}
+			blocks[idx] = &claudeBlockAccumulator{block: []byte(root.Get("content_block").Raw)}
+		case "content_block_delta":
+			acc := blocks[root.Get("index").Int()]
+			if acc == nil {
+				continue
+			}
+			delta := root.Get("delta")
+			switch delta.Get("type").String() {
+			case "text_delta":
+				acc.text.WriteString(delta.Get("text").String())
+			case "thinking_delta":
+				acc.thinking.WriteString(delta.Get("thinking").String())
+			case "input_json_delta":
+				acc.partialJSON.WriteString(delta.Get("partial_json").String())
+			case "signature_delta":
+				acc.signature = delta.Get("signature").String()
+			case "citations_delta":
+				acc.citations = append(acc.citations, delta.Get("citation").Raw)
+			}
+		case "message_delta":
+			if message == nil {
+				message = []byte(`{"type":"message","role":"assistant","content":[]}`)
+			}
+			for _, field := range []string{"stop_reason", "stop_sequence"} {
+				if value := root.Get("delta." + field); value.Exists() {
+					if message, err = sjson.SetRawBytes(message, field, []byte(value.Raw)); err != nil {
+						return nil, fmt.Errorf("sse aggregate: set %s: %w", field, err)
+					}
+				}
+			}
+			var errUsage error
+			root.Get("usage").ForEach(func(key, value gjson.Result) bool {
+				message, errUsage = sjson.SetRawBytes(message, "usage."+key.String(), []byte(value.Raw))
+				return errUsage == nil
+			})
+			if errUsage != nil {
+				return nil, fmt.Errorf("sse aggregate: merge usage: %w", errUsage)
+			}
+		case "error":
+			return nil, fmt.Errorf("sse aggregate: stream error: %s", root.Get("error.message").String())
+		}
+	}
+
+	if message == nil {
+		return nil, errors.New("sse aggregate: anthropic stream has no message_start event")
+	}
+
+	content := []byte(`[]`)
+	for _, idx := range order {
+		block, errBlock := blocks[idx].finish()
+		if errBlock != nil {
+			return nil, errBlock
+		}
+		if content, err = sjson.SetRawBytes(content, "-1", block); err != nil {
+			return nil, fmt.Errorf("sse aggregate: append content block: %w", err)
+		}
+	}
+	if message, err = sjson.SetRawBytes(message, "content", content); err != nil {
+		return nil, fmt.Errorf("sse aggregate: set content: %w", err)
+	}
+	return message, nil
+}
+
+// finish applies the accumulated deltas to the block from content_block_start.
+func (a *claudeBlockAccumulator) finish() ([]byte, error) {
+	block := a.block
+	var err error
+	set := func(path string, value any) {
+		if err == nil {
+			block, err = sjson.SetBytes(block, path, value)
+		}
+	}
+
+	switch gjson.GetBytes(block, "type").String() {
+	case "text":
+		set("text", gjson.GetBytes(block, "text").String()+a.text.String())
+		if len(a.citations) > 0 {
+			citations := gjson.GetBytes(block, "citations").Raw
+			if !gjson.GetBytes(block, "citations").IsArray() {
+				citations = `[]`
+			}
+			for _, citation := range a.citations {
+				if err == nil {
+					citations, err = sjson.SetRaw(citations, "-1", citation)
+				}
+			}
+			if err == nil {
+				block, err = sjson.SetRawBytes(block, "citations", []byte(citations))
+			}
+		}
+	case "thinking":
+		set("thinking", gjson.GetBytes(block, "thinking").String()+a.thinking.String())
+		if a.signature != "" {
+			set("signature", a.signature)
+		}
+	case "tool_use", "server_tool_use", "mcp_tool_use":
+		if input := strings.TrimSpace(a.partialJSON.String()); input != "" {
+			if !gjson.Valid(input) {
+				return nil, fmt.Errorf("sse aggregate: invalid tool input JSON: %s", truncateForError([]byte(input)))
+			}
+			if err == nil {
+				block, err = sjson.SetRawBytes(block, "input", []byte(input))
+			}
+		} else if !gjson.GetBytes(block, "input").Exists() {
+			if err == nil {
+				block, err = sjson.SetRawBytes(block, "input", []byte(`{}`))
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sse aggregate: build content block: %w", err)
+	}
+	return block, nil
+}
+
+type geminiCandidateAccumulator struct {
+	role   string
+	parts  [][]byte
+	fields map[string]string // last value of other candidate fields, e.g. finishReason
+	keys   []string          // order in which fields were first seen
+}
+
+func aggregateGeminiSSEEvents(events [][]byte, enveloped bool) ([]byte, error) {
+	var (
+		candidates = map[int64]*geminiCandidateAccumulator{}
+		order      []int64
+		topLevel   = map[string]string{}
+		topKeys    []string
+	)
+
+	for _, event := range events {
+		root := gjson.ParseBytes(event)
+		if enveloped {
+			root = root.Get("response")
+		}
+		root.ForEach(func(key, value gjson.Result) bool {
+			name := key.String()
+			if name == "candidates" {
+				return true
+			}
+			if _, seen := topLevel[name]; !seen {
+				topKeys = append(topKeys, name)
+			}
+			topLevel[name] = value.Raw
+			return true
+		})
+
+		for pos, candidate := range root.Get("candidates").Array() {
+			idx := int64(pos)
+			if index := candidate.Get("index"); index.Exists() {
+				idx = index.Int()
+			}
+			acc := candidates[idx]
+			if acc == nil {
+				acc = &geminiCandidateAccumulator{fields: map[string]string{}}
+				candidates[idx] = acc
+				order = append(order, idx)
+			}
+			acc.merge(candidate)
+		}
+	}
+
+	response := []byte(`{}`)
+	var err error
+	if len(order) > 0 {
+		list := []byte(`[]`)
+		for _, idx := range order {
+			candidate, errCandidate := candidates[idx].build()
+			if errCandidate != nil {
+				return nil, errCandidate
+			}
+			if list, err = sjson.SetRawBytes(list, "-1", candidate); err != nil {
+				return nil, fmt.Errorf("sse aggregate: append candidate: %w", err)
+			}
+		}
+		if response, err = sjson.SetRawBytes(response, "candidates", list); err != nil {
+			return nil, fmt.Errorf("sse aggregate: set candidates: %w", err)
+		}
+	}
+	for _, key := range topKeys {
+		if response, err = sjson.SetRawBytes(response, escapeSJSONKey(key), []byte(topLevel[key])); err != nil {
+			return nil, fmt.Errorf("sse aggregate: set %s: %w", key, err)
+		}
+	}
+
+	if !enveloped {
+		return response, nil
+	}
+	out, err := sjson.SetRawBytes([]byte(`{}`), "response", response)
+	if err != nil {
+		return nil, fmt.Errorf("sse aggregate: wrap response: %w", err)
+	}
+	return out, nil
+}
+
+// merge folds one streamed candidate into the accumulator. Consecutive text parts
+// with the same thought flag are concatenated; every other part is kept as is.
+func (a *geminiCandidateAccumulator) merge(candidate gjson.Result) {
+	candidate.ForEach(func(key, value gjson.Result) bool {
+		name := key.String()
+		if name == "content" {
+			return true
+		}
+		if _, seen := a.fields[name]; !seen {
+			a.keys = append(a.keys, name)
+		}
+		a.fields[name] = value.Raw
+		return true
+	})
+
+	content := candidate.Get("content")
+	if role := content.Get("role").String(); role != "" {
+		a.role = role
+	}
+	for _, part := range content.Get("parts").Array() {
+		if last := len(a.parts) - 1; last >= 0 && canMergeGeminiTextParts(a.parts[last], part) {
+			merged, err := sjson.SetBytes(a.parts[last], "text", gjson.GetBytes(a.parts[last], "text").String()+part.Get("text").String())
+			if err == nil {
+				if signature := part.Get("thoughtSignature"); signature.Exists() {
+					merged, err = sjson.SetBytes(merged, "thoughtSignature", signature.String())
+				}
+			}
+			if err == nil {
+				a.parts[last] = merged
+				continue
+			}
+		}
+		a.parts = append(a.parts, []byte(part.Raw))
+	}
+}
+
+func canMergeGeminiTextParts(previous []byte, next gjson.Result) bool {
+	prev := gjson.ParseBytes(previous)
+	if !prev.Get("text").Exists() || !next.Get("text").Exists() {
+		return false
+	}
+	// Only text and the thought markers may be present; anything else is kept apart.
+	for _, part := range []gjson.Result{prev, next} {
+		onlyText := true
+		part.ForEach(func(key, _ gjson.Result) bool {
+			switch key.String() {
+			case "text", "thought", "thoughtSignature":
+				return true
+			}
+			onlyText = false
+			return false
+		})
+		if !onlyText {
+			return false
+		}
+	}
+	// A signature closes a thought segment, so nothing is appended after it.
+	if prev.Get("thoughtSignature").Exists() {
+		return false
+	}
+	return prev.Get("thought").Bool() == next.Get("thought").Bool()
+}
+
+func (a *geminiCandidateAccumulator) build() ([]byte, error) {
+	role := a.role
+	if role == "" {
+		role = "model"
+	}
+	candidate := []byte(`{"content":{"parts":[]}}`)
+	var err error
+	for _, part := range a.parts {
+		if candidate, err = sjson.SetRawBytes(candidate, "content.parts.-1", part); err != nil {
+			return nil, fmt.Errorf("sse aggregate: append part: %w", err)
+		}
+	}
+	if candidate, err = sjson.SetBytes(candidate, "content.role", role); err != nil {
+		return nil, fmt.Errorf("sse aggregate: set role: %w", err)
+	}
+	for _, key := range a.keys {
+		if candidate, err = sjson.SetRawBytes(candidate, escapeSJSONKey(key), []byte(a.fields[key])); err != nil {
+			return nil, fmt.Errorf("sse aggregate: set %s: %w", key, err)
+		}
+	}
+	return candidate, nil
+}
+
+// escapeSJSONKey escapes the characters sjson treats as path syntax so key is
+// set as a single top-level field.
+func escapeSJSONKey(key string) string {
+	replacer := strings.NewReplacer(".", `\.`, "*", `\*`, "?", `\?`, "|", `\|`, "#", `\#`, "@", `\@`, ":", `\:`)
+	return replacer.Replace(key)
+}
+
+func truncateForError(payload []byte) string {
+	const limit = 200
+	if len(payload) <= limit {
+		return string(payload)
+	}
+	return string(payload[:limit]) + "..."
+}
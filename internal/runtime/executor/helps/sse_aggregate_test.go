@@ -0,0 +1,108 @@
+package helps
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func aggregateFixture(t *testing.T, name string) gjson.Result {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", "sse", name))
+	if err != nil {
+		t.Fatalf("open fixture: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	out, err := AggregateSSEStream(f)
+	if err != nil {
+		t.Fatalf("AggregateSSEStream(%s) error = %v", name, err)
+	}
+	if !gjson.ValidBytes(out) {
+		t.Fatalf("AggregateSSEStream(%s) returned invalid JSON: %s", name, out)
+	}
+	return gjson.ParseBytes(out)
+}
+
+func expectJSON(t *testing.T, root gjson.Result, path string, want string) {
+	t.Helper()
+	if got := root.Get(path).String(); got != want {
+		t.Errorf("%s = %q, want %q", path, got, want)
+	}
+}
+
+func TestAggregateSSEStream_Claude(t *testing.T) {
+	root := aggregateFixture(t, "claude_stream.txt")
+
+	expectJSON(t, root, "id", "msg_01")
+	expectJSON(t, root, "model", "claude-sonnet-4-5")
+	expectJSON(t, root, "stop_reason", "tool_use")
+	expectJSON(t, root, "usage.input_tokens", "25")
+	expectJSON(t, root, "usage.output_tokens", "42")
+	if n := len(root.Get("content").Array()); n != 3 {
+		t.Fatalf("content blocks = %d, want 3: %s", n, root.Raw)
+	}
+	expectJSON(t, root, "content.0.type", "thinking")
+	expectJSON(t, root, "content.0.thinking", "Let me check the weather.")
+	expectJSON(t, root, "content.0.signature", "sig-abc")
+	expectJSON(t, root, "content.1.text", "Hello, world")
+	expectJSON(t, root, "content.2.name", "get_weather")
+	expectJSON(t, root, "content.2.input.city", "Paris")
+}
+
+func TestAggregateSSEStream_Gemini(t *testing.T) {
+	root := aggregateFixture(t, "gemini_stream.txt")
+
+	expectJSON(t, root, "responseId", "resp-1")
+	expectJSON(t, root, "modelVersion", "gemini-2.5-pro")
+	expectJSON(t, root, "usageMetadata.totalTokenCount", "17")
+	candidate := root.Get("candidates.0")
+	expectJSON(t, candidate, "finishReason", "STOP")
+	expectJSON(t, candidate, "content.role", "model")
+
+	parts := candidate.Get("content.parts").Array()
+	if len(parts) != 3 {
+		t.Fatalf("parts = %d, want 3: %s", len(parts), candidate.Raw)
+	}
+	expectJSON(t, parts[0], "text", "Thinking about it some more")
+	expectJSON(t, parts[0], "thought", "true")
+	expectJSON(t, parts[0], "thoughtSignature", "c2ln")
+	expectJSON(t, parts[1], "text", "The answer is 42.")
+	if parts[1].Get("thought").Exists() {
+		t.Error("answer text must not be marked as thought")
+	}
+	expectJSON(t, parts[2], "functionCall.name", "lookup")
+	expectJSON(t, parts[2], "functionCall.args.q", "42")
+}
+
+func TestAggregateSSEStream_GeminiCLIEnvelope(t *testing.T) {
+	root := aggregateFixture(t, "gemini_cli_stream.txt")
+
+	expectJSON(t, root, "response.candidates.0.content.parts.0.text", "Hello")
+	expectJSON(t, root, "response.candidates.0.finishReason", "STOP")
+	expectJSON(t, root, "response.usageMetadata.totalTokenCount", "5")
+	expectJSON(t, root, "response.modelVersion", "gemini-2.5-flash")
+}
+
+func TestAggregateSSEStream_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+	}{
+		{"empty", ""},
+		{"only done", "data: [DONE]\n\n"},
+		{"invalid json", "data: {not json}\n\n"},
+		{"claude error event", "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n"},
+		{"unknown format", "data: {\"foo\":1}\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := AggregateSSEStream(strings.NewReader(tt.stream)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
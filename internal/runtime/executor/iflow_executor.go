@@ -426,7 +426,7 @@ func (e *IFlowExecutor) refreshOAuthBased(ctx context.Context, auth *cliproxyaut
 
 	// Log the old access token (masked) before refresh
 	if oldAccessToken != "" {
-		log.Debugf("iflow executor: refreshing access token, old: %s", util.HideAPIKey(oldAccessToken))
+		log.Debugf("iflow executor: refreshing access token, old: %s", util.ObfuscateToken(oldAccessToken))
 	}
 
 	svc := iflowauth.NewIFlowAuth(e.cfg)
@@ -451,7 +451,7 @@ func (e *IFlowExecutor) refreshOAuthBased(ctx context.Context, auth *cliproxyaut
 	auth.Metadata["last_refresh"] = time.Now().Format(time.RFC3339)
 
 	// Log the new access token (masked) after successful refresh
-	log.Debugf("iflow executor: token refresh successful, new: %s", util.HideAPIKey(tokenData.AccessToken))
+	log.Debugf("iflow executor: token refresh successful, new: %s", util.ObfuscateToken(tokenData.AccessToken))
 
 	if auth.Attributes == nil {
 		auth.Attributes = make(map[string]string)
@@ -10,19 +10,24 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	kiroauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor/helps"
 	kiroclaude "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/kiro/claude"
 	kirocommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/kiro/common"
 	kiroopenai "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/kiro/openai"
@@ -49,9 +54,6 @@ const (
 	ErrStreamFatal     = "fatal"     // Connection/authentication errors, not recoverable
 	ErrStreamMalformed = "malformed" // Format errors, data cannot be parsed
 
-	// kiroIDEAgentMode is the agent mode header value for Kiro IDE requests
-	kiroIDEAgentMode = "vibe"
-
 	// Socket retry configuration constants
 	// Maximum number of retry attempts for socket/network errors
 	kiroSocketMaxRetries = 3
@@ -80,13 +82,25 @@ var (
 	usageUpdateTimeInterval  = 15 * time.Second // Or every 15 seconds, whichever comes first
 )
 
-// endpointAliases maps user preference values to canonical endpoint names.
+// endpointAliases maps user preference values to canonical endpoint names. Every
+// canonical name maps to itself so it is accepted as a preference too.
 var endpointAliases = map[string]string{
-	"codewhisperer": "codewhisperer",
-	"ide":           "codewhisperer",
-	"amazonq":       "amazonq",
-	"q":             "amazonq",
-	"cli":           "amazonq",
+	"codewhisperer":            "codewhisperer",
+	"ide":                      "codewhisperer",
+	"amazonq":                  "amazonq",
+	"q":                        "amazonq",
+	"cli":                      "amazonq",
+	"codewhisperersendmessage": "codewhisperersendmessage",
+	"sendmessage":              "codewhisperersendmessage",
+	"send_message":             "codewhisperersendmessage",
+}
+
+// ResolveEndpointPreference maps a preferred_endpoint value, case-insensitively
+// and ignoring surrounding whitespace, to its canonical endpoint name. It reports
+// ok=false for unknown preferences.
+func ResolveEndpointPreference(pref string) (canonical string, ok bool) {
+	canonical, ok = endpointAliases[strings.ToLower(strings.TrimSpace(pref))]
+	return canonical, ok
 }
 
 func enqueueTranslatedSSE(out chan<- cliproxyexecutor.StreamChunk, chunk []byte) {
@@ -282,6 +296,38 @@ func getKiroPooledHTTPClient() *http.Client {
 	return kiroHTTPClientPool
 }
 
+// kiroTunedHTTPClientPools holds pooled clients with provider-http settings for
+// "kiro" applied, keyed by those settings.
+var (
+	kiroTunedHTTPClientPools   = make(map[config.ProviderHTTPConfig]*http.Client)
+	kiroTunedHTTPClientPoolsMu sync.Mutex
+)
+
+// getKiroTunedPooledHTTPClient returns a pooled client like getKiroPooledHTTPClient
+// whose transport also applies providerHTTP, behind a circuit breaker when
+// CircuitBreakerThreshold is set. As with the proxy path, the breaker is shared by
+// every Kiro account using these settings.
+func getKiroTunedPooledHTTPClient(providerHTTP config.ProviderHTTPConfig) *http.Client {
+	kiroTunedHTTPClientPoolsMu.Lock()
+	defer kiroTunedHTTPClientPoolsMu.Unlock()
+	if client, ok := kiroTunedHTTPClientPools[providerHTTP]; ok {
+		return client
+	}
+	base := getKiroPooledHTTPClient()
+	transport, ok := base.Transport.(*http.Transport)
+	if !ok {
+		return base
+	}
+	transport = transport.Clone()
+	helps.ApplyProviderHTTPConfig(transport, providerHTTP)
+	client := &http.Client{Transport: transport}
+	if providerHTTP.CircuitBreakerThreshold > 0 {
+		client = helps.NewCircuitBreakerHTTPClient(client, providerHTTP.CircuitBreakerThreshold, providerHTTP.CircuitBreakerTimeout)
+	}
+	kiroTunedHTTPClientPools[providerHTTP] = client
+	return client
+}
+
 // newKiroHTTPClientWithPooling creates an HTTP client that uses connection pooling when appropriate.
 // It respects proxy configuration from auth or config, falling back to the pooled client.
 // This provides the best of both worlds: custom proxy support + connection reuse.
@@ -303,6 +349,9 @@ func newKiroHTTPClientWithPooling(ctx context.Context, cfg *config.Config, auth
 
 	// No proxy - use pooled client for better performance
 	pooledClient := getKiroPooledHTTPClient()
+	if providerHTTP, ok := cfg.ProviderHTTPFor("kiro"); ok {
+		pooledClient = getKiroTunedPooledHTTPClient(providerHTTP)
+	}
 
 	// If timeout is specified, we need to wrap the pooled transport with timeout
 	if timeout > 0 {
@@ -326,7 +375,84 @@ type kiroEndpointConfig struct {
 	URL       string // Endpoint URL
 	Origin    string // Request Origin: "CLI" for Amazon Q quota, "AI_EDITOR" for Kiro IDE quota
 	AmzTarget string // X-Amz-Target header value
+	Operation string // Streaming API operation, the key for kiro-amz-targets overrides
 	Name      string // Endpoint name for logging
+	Weight    int    // Relative weight from "endpoint_weights" for selectKiroEndpoint; 0 excludes the endpoint from weighted selection
+}
+
+// selectKiroEndpoint picks an endpoint config at random, proportionally to Weight,
+// so traffic can be shifted gradually between endpoints (e.g. 80% AmazonQ, 20% CodeWhisperer).
+// When no config has a positive weight, the first config is returned to preserve strict ordering.
+// A nil rng falls back to the shared math/rand source.
+func selectKiroEndpoint(configs []kiroEndpointConfig, rng *rand.Rand) kiroEndpointConfig {
+	if len(configs) == 0 {
+		return kiroEndpointConfig{}
+	}
+
+	total := 0
+	for _, cfg := range configs {
+		if cfg.Weight > 0 {
+			total += cfg.Weight
+		}
+	}
+	if total == 0 {
+		return configs[0]
+	}
+
+	var pick int
+	if rng != nil {
+		pick = rng.Intn(total)
+	} else {
+		pick = rand.Intn(total)
+	}
+	for _, cfg := range configs {
+		if cfg.Weight <= 0 {
+			continue
+		}
+		if pick < cfg.Weight {
+			return cfg
+		}
+		pick -= cfg.Weight
+	}
+	return configs[0]
+}
+
+// contextKey is the type for context values set by this package.
+type contextKey string
+
+// endpointContextKey holds the Kiro endpoint URL resolved for the current request.
+const endpointContextKey contextKey = "kiro.endpoint"
+
+// WithEndpoint returns a copy of ctx carrying the resolved endpoint URL, so logging,
+// metrics and response translation can read it without recomputing it.
+func WithEndpoint(ctx context.Context, url string) context.Context {
+	return context.WithValue(ctx, endpointContextKey, url)
+}
+
+// EndpointFromContext returns the endpoint URL stored by WithEndpoint, or "" if none.
+func EndpointFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	url, _ := ctx.Value(endpointContextKey).(string)
+	return url
+}
+
+// filterHealthyKiroEndpoints drops endpoints whose recent 5xx rate marks them unhealthy.
+// If every endpoint is unhealthy the original list is returned so requests still go out.
+func filterHealthyKiroEndpoints(configs []kiroEndpointConfig) []kiroEndpointConfig {
+	healthy := make([]kiroEndpointConfig, 0, len(configs))
+	for _, cfg := range configs {
+		if helps.EndpointHealthFor(cfg.URL).IsHealthy() {
+			healthy = append(healthy, cfg)
+		} else {
+			log.Debugf("kiro: skipping unhealthy endpoint %s (%s)", cfg.Name, cfg.URL)
+		}
+	}
+	if len(healthy) == 0 {
+		return configs
+	}
+	return healthy
 }
 
 // kiroDefaultRegion is the default AWS region for Kiro API endpoints.
@@ -347,47 +473,155 @@ func extractRegionFromProfileARN(profileArn string) string {
 	return ""
 }
 
+// Kiro streaming API operations addressed through the X-Amz-Target header.
+const (
+	kiroOperationGenerateAssistantResponse = "GenerateAssistantResponse"
+	kiroOperationSendMessage               = "SendMessage"
+)
+
+// kiroAmzTargets holds the default X-Amz-Target values, keyed by
+// "endpoint/operation" or, for region-specific values, "endpoint/region/operation".
+// Endpoints without an entry (AmazonQ) send no X-Amz-Target header.
+var kiroAmzTargets = map[string]string{
+	kiroAmzTargetKey("CodeWhisperer", kiroOperationGenerateAssistantResponse): "AmazonCodeWhispererStreamingService.GenerateAssistantResponse",
+	kiroAmzTargetKey("CodeWhisperer", kiroOperationSendMessage):               "AmazonCodeWhispererStreamingService.SendMessage",
+}
+
+// kiroAmzTargetKey joins key parts into the case-insensitive lookup key form.
+func kiroAmzTargetKey(parts ...string) string {
+	return strings.ToLower(strings.Join(parts, "/"))
+}
+
+// kiroAmzTargetOverrides holds configured X-Amz-Target values in the normalized
+// key form of kiroAmzTargets; an empty value disables the header.
+type kiroAmzTargetOverrides map[string]string
+
+// newKiroAmzTargetOverrides normalizes the kiro-amz-targets config map, whose keys
+// are case-insensitive "endpoint/operation" or "endpoint/region/operation".
+func newKiroAmzTargetOverrides(overrides map[string]string) kiroAmzTargetOverrides {
+	if len(overrides) == 0 {
+		return nil
+	}
+	normalized := make(kiroAmzTargetOverrides, len(overrides))
+	for key, target := range overrides {
+		normalized[kiroAmzTargetKey(strings.TrimSpace(key))] = strings.TrimSpace(target)
+	}
+	return normalized
+}
+
+// lookup returns the override for an endpoint, region and operation, preferring
+// the region-specific key.
+func (o kiroAmzTargetOverrides) lookup(endpointName, region, operation string) (string, bool) {
+	for _, key := range []string{kiroAmzTargetKey(endpointName, region, operation), kiroAmzTargetKey(endpointName, operation)} {
+		if target, ok := o[key]; ok {
+			return target, true
+		}
+	}
+	return "", false
+}
+
+// apply replaces the AmzTarget of every config in region that has an override.
+func (o kiroAmzTargetOverrides) apply(configs []kiroEndpointConfig, region string) {
+	if len(o) == 0 {
+		return
+	}
+	for i := range configs {
+		if configs[i].Operation == "" {
+			continue
+		}
+		if target, ok := o.lookup(configs[i].Name, region, configs[i].Operation); ok {
+			configs[i].AmzTarget = target
+		}
+	}
+}
+
+// resolveKiroAmzTarget returns the default X-Amz-Target value for an endpoint,
+// region and operation, preferring region-specific keys.
+func resolveKiroAmzTarget(endpointName, region, operation string) string {
+	target, _ := kiroAmzTargetOverrides(kiroAmzTargets).lookup(endpointName, region, operation)
+	return target
+}
+
+// kiroEndpointCapability enables an optional endpoint variant in buildKiroEndpointConfigs.
+type kiroEndpointCapability int
+
+const (
+	// kiroCapabilitySendMessage appends a CodeWhisperer endpoint that calls the
+	// streaming SendMessage operation, for accounts that only accept SendMessage.
+	// It is enabled per auth with the "send_message" metadata/attribute set to "true".
+	kiroCapabilitySendMessage kiroEndpointCapability = iota + 1
+)
+
+// kiroSendMessagePath is the REST path of the streaming SendMessage operation.
+const kiroSendMessagePath = "/SendMessageStreaming"
+
 // buildKiroEndpointConfigs creates endpoint configurations for the specified region.
 // This enables dynamic region support for Enterprise/IdC users in non-us-east-1 regions.
 //
-// Uses Q endpoint (q.{region}.amazonaws.com) as primary for ALL auth types:
+// Uses Q endpoint (q.{region}.amazonaws.com, or amazonaws.com.cn in China regions)
+// as primary for ALL auth types:
 // - Works universally across all AWS regions (CodeWhisperer endpoint only exists in us-east-1)
 // - Uses /generateAssistantResponse path with AI_EDITOR origin
 // - Does NOT require X-Amz-Target header
 //
 // The AmzTarget field is kept for backward compatibility but should be empty
 // to indicate that the header should NOT be set.
-func buildKiroEndpointConfigs(region string) []kiroEndpointConfig {
+//
+// caps adds optional variants after the default endpoints; see kiroEndpointCapability.
+func buildKiroEndpointConfigs(region string, caps ...kiroEndpointCapability) []kiroEndpointConfig {
 	if region == "" {
-		region = kiroDefaultRegion
+		region = kiroauth.DefaultRegion()
 	}
-	return []kiroEndpointConfig{
+	configs := []kiroEndpointConfig{
 		{
 			// Primary: Q endpoint - works for all regions and auth types
-			URL:       fmt.Sprintf("https://q.%s.amazonaws.com/generateAssistantResponse", region),
-			Origin:    "AI_EDITOR",
-			AmzTarget: "", // Empty = don't set X-Amz-Target header
+			URL:       kiroauth.GetKiroAPIEndpoint(region) + "/generateAssistantResponse",
+			Origin:    kiroDefaultOrigin,
+			AmzTarget: resolveKiroAmzTarget("AmazonQ", region, kiroOperationGenerateAssistantResponse), // Empty = don't set X-Amz-Target header
+			Operation: kiroOperationGenerateAssistantResponse,
 			Name:      "AmazonQ",
 		},
 		{
 			// Fallback: CodeWhisperer endpoint (legacy, only works in us-east-1)
-			URL:       fmt.Sprintf("https://codewhisperer.%s.amazonaws.com/generateAssistantResponse", region),
-			Origin:    "AI_EDITOR",
-			AmzTarget: "AmazonCodeWhispererStreamingService.GenerateAssistantResponse",
+			URL:       kiroauth.GetCodeWhispererEndpoint(region) + "/generateAssistantResponse",
+			Origin:    kiroDefaultOrigin,
+			AmzTarget: resolveKiroAmzTarget("CodeWhisperer", region, kiroOperationGenerateAssistantResponse),
+			Operation: kiroOperationGenerateAssistantResponse,
 			Name:      "CodeWhisperer",
 		},
 	}
+	if slices.Contains(caps, kiroCapabilitySendMessage) {
+		configs = append(configs, kiroEndpointConfig{
+			URL:       kiroauth.GetCodeWhispererEndpoint(region) + kiroSendMessagePath,
+			Origin:    kiroDefaultOrigin,
+			AmzTarget: resolveKiroAmzTarget("CodeWhisperer", region, kiroOperationSendMessage),
+			Operation: kiroOperationSendMessage,
+			Name:      "CodeWhispererSendMessage",
+		})
+	}
+	return configs
+}
+
+// kiroEndpointCapabilities returns the optional endpoint variants enabled for auth.
+func kiroEndpointCapabilities(auth *cliproxyauth.Auth) []kiroEndpointCapability {
+	var caps []kiroEndpointCapability
+	if getAuthValue(auth, "send_message") == "true" {
+		caps = append(caps, kiroCapabilitySendMessage)
+	}
+	return caps
 }
 
 // resolveKiroAPIRegion determines the AWS region for Kiro API calls.
 // Region priority:
 // 1. auth.Metadata["api_region"] - explicit API region override
 // 2. ProfileARN region - extracted from arn:aws:service:REGION:account:resource
-// 3. kiroDefaultRegion (us-east-1) - fallback
+// 3. auth.Metadata["api_gateway_stage_arn"] - e.g. arn:aws:apigateway:REGION::/restapis/ID/stages/STAGE
+// 4. kiroauth.DefaultRegion() (us-east-1 unless configured) - fallback
 // Note: OIDC "region" is NOT used - it's for token refresh, not API calls
+// The result is also the SigV4 signing region.
 func resolveKiroAPIRegion(auth *cliproxyauth.Auth) string {
 	if auth == nil || auth.Metadata == nil {
-		return kiroDefaultRegion
+		return kiroauth.DefaultRegion()
 	}
 	// Priority 1: Explicit api_region override
 	if r, ok := auth.Metadata["api_region"].(string); ok && r != "" {
@@ -401,11 +635,43 @@ func resolveKiroAPIRegion(auth *cliproxyauth.Auth) string {
 			return arnRegion
 		}
 	}
+	// Priority 3: API Gateway stage ARN, for custom domains without a region in the URL
+	if stageArn, ok := auth.Metadata["api_gateway_stage_arn"].(string); ok && stageArn != "" {
+		if arnRegion := extractRegionFromProfileARN(stageArn); arnRegion != "" {
+			log.Debugf("kiro: using region %s (source: api_gateway_stage_arn)", arnRegion)
+			return arnRegion
+		}
+	}
 	// Note: OIDC "region" field is NOT used for API endpoint
 	// Kiro API only exists in us-east-1, while OIDC region can vary (e.g., ap-northeast-2)
 	// Using OIDC region for API calls causes DNS failures
-	log.Debugf("kiro: using region %s (source: default)", kiroDefaultRegion)
-	return kiroDefaultRegion
+	region := kiroauth.DefaultRegion()
+	log.Debugf("kiro: using region %s (source: default)", region)
+	return region
+}
+
+// kiroDefaultOrigin is the request Origin used when auth metadata does not select one.
+const kiroDefaultOrigin = "AI_EDITOR"
+
+// kiroValidOrigins lists the Origin values accepted in the "q_origin" auth metadata.
+var kiroValidOrigins = map[string]struct{}{
+	"AI_EDITOR": {},
+	"CLI":       {},
+	"CONSOLE":   {},
+}
+
+// resolveKiroOrigin returns the request Origin configured via auth metadata "q_origin".
+// Unknown values are logged and replaced with kiroDefaultOrigin.
+func resolveKiroOrigin(auth *cliproxyauth.Auth) string {
+	origin := strings.ToUpper(getAuthValue(auth, "q_origin"))
+	if origin == "" {
+		return kiroDefaultOrigin
+	}
+	if _, ok := kiroValidOrigins[origin]; !ok {
+		log.Warnf("kiro: unknown q_origin %q, using %s", origin, kiroDefaultOrigin)
+		return kiroDefaultOrigin
+	}
+	return origin
 }
 
 // kiroEndpointConfigs is kept for backward compatibility with default us-east-1 region.
@@ -415,28 +681,41 @@ var kiroEndpointConfigs = buildKiroEndpointConfigs(kiroDefaultRegion)
 // getKiroEndpointConfigs returns the list of Kiro API endpoint configurations to try in order.
 // Supports dynamic region based on auth metadata "api_region", "profile_arn", or "region" field.
 // Supports reordering based on "preferred_endpoint" in auth metadata/attributes.
+// Without a preference, "endpoint_weights" (e.g. "amazonq=80,codewhisperer=20")
+// picks the first endpoint at random by weight.
+// Supports overriding the request Origin via "q_origin" (AI_EDITOR, CLI or CONSOLE).
 //
 // Region priority:
 // 1. auth.Metadata["api_region"] - explicit API region override
 // 2. ProfileARN region - extracted from arn:aws:service:REGION:account:resource
-// 3. kiroDefaultRegion (us-east-1) - fallback
+// 3. auth.Metadata["api_gateway_stage_arn"] - API Gateway stage ARN region
+// 4. kiroauth.DefaultRegion() (us-east-1 unless configured) - fallback
 // Note: OIDC "region" is NOT used - it's for token refresh, not API calls
 func getKiroEndpointConfigs(auth *cliproxyauth.Auth) []kiroEndpointConfig {
 	if auth == nil {
-		return kiroEndpointConfigs
+		return buildKiroEndpointConfigs(kiroauth.DefaultRegion())
 	}
 
 	region := resolveKiroAPIRegion(auth)
 	log.Debugf("kiro: using region %s", region)
 
-	configs := buildKiroEndpointConfigs(region)
+	configs := buildKiroEndpointConfigs(region, kiroEndpointCapabilities(auth)...)
+	if override := kiroEndpointOverride(auth); override != "" {
+		log.Debugf("kiro: using endpoint override %s", override)
+		configs = []kiroEndpointConfig{{URL: override, Origin: kiroDefaultOrigin, Name: "Override"}}
+	}
+	if origin := resolveKiroOrigin(auth); origin != kiroDefaultOrigin {
+		for i := range configs {
+			configs[i].Origin = origin
+		}
+	}
 
 	preference := getAuthValue(auth, "preferred_endpoint")
 	if preference == "" {
-		return configs
+		return applyKiroEndpointWeights(configs, getAuthValue(auth, "endpoint_weights"), nil)
 	}
 
-	targetName, ok := endpointAliases[preference]
+	targetName, ok := ResolveEndpointPreference(preference)
 	if !ok {
 		return configs
 	}
@@ -456,11 +735,195 @@ func getKiroEndpointConfigs(auth *cliproxyauth.Auth) []kiroEndpointConfig {
 	return append(preferred, others...)
 }
 
+// applyKiroEndpointWeights sets the Weight of each config from spec, a comma-separated
+// list of name=weight pairs using the preferred_endpoint names, and moves the
+// endpoint chosen by selectKiroEndpoint to the front. The remaining endpoints keep
+// their order as fallbacks. Without a positive weight configs is returned as is.
+func applyKiroEndpointWeights(configs []kiroEndpointConfig, spec string, rng *rand.Rand) []kiroEndpointConfig {
+	if spec == "" || len(configs) < 2 {
+		return configs
+	}
+	weights := make(map[string]int)
+	for _, pair := range strings.Split(spec, ",") {
+		name, value, found := strings.Cut(pair, "=")
+		canonical, ok := ResolveEndpointPreference(name)
+		weight, errParse := strconv.Atoi(strings.TrimSpace(value))
+		if !found || !ok || errParse != nil || weight < 0 {
+			log.Debugf("kiro: ignoring invalid endpoint weight %q", pair)
+			continue
+		}
+		weights[canonical] = weight
+	}
+
+	weighted := make([]kiroEndpointConfig, len(configs))
+	for i, cfg := range configs {
+		cfg.Weight = weights[strings.ToLower(cfg.Name)]
+		weighted[i] = cfg
+	}
+	if !slices.ContainsFunc(weighted, func(cfg kiroEndpointConfig) bool { return cfg.Weight > 0 }) {
+		return configs
+	}
+
+	chosen := selectKiroEndpoint(weighted, rng)
+	ordered := make([]kiroEndpointConfig, 0, len(weighted))
+	ordered = append(ordered, chosen)
+	for _, cfg := range weighted {
+		if cfg.Name != chosen.Name {
+			ordered = append(ordered, cfg)
+		}
+	}
+	return ordered
+}
+
+// kiroEndpointOverride returns the "endpoint_url" auth metadata/attribute, which
+// replaces the region-derived endpoints with a single custom endpoint.
+func kiroEndpointOverride(auth *cliproxyauth.Auth) string {
+	if auth == nil {
+		return ""
+	}
+	if auth.Metadata != nil {
+		if v, ok := auth.Metadata["endpoint_url"].(string); ok && strings.TrimSpace(v) != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+	if auth.Attributes != nil {
+		return strings.TrimSpace(auth.Attributes["endpoint_url"])
+	}
+	return ""
+}
+
+// validateKiroEndpointConfigs drops endpoints whose URL is not an absolute https URL.
+// When allowHTTP is set (auth "allow_http_endpoint" = "true", for local testing),
+// http URLs are accepted as well. It returns an error if no endpoint remains.
+func validateKiroEndpointConfigs(configs []kiroEndpointConfig, allowHTTP bool) ([]kiroEndpointConfig, error) {
+	valid := make([]kiroEndpointConfig, 0, len(configs))
+	for _, cfg := range configs {
+		parsed, err := url.Parse(cfg.URL)
+		if err != nil || !parsed.IsAbs() || parsed.Host == "" {
+			log.Warnf("kiro: dropping endpoint %s: %q is not an absolute URL", cfg.Name, cfg.URL)
+			continue
+		}
+		scheme := strings.ToLower(parsed.Scheme)
+		if scheme != "https" && !(allowHTTP && scheme == "http") {
+			log.Warnf("kiro: dropping endpoint %s: scheme %q is not allowed", cfg.Name, parsed.Scheme)
+			continue
+		}
+		valid = append(valid, cfg)
+	}
+	if len(valid) == 0 {
+		return nil, fmt.Errorf("kiro: no valid endpoints: every endpoint URL must be an absolute https URL")
+	}
+	return valid, nil
+}
+
+// resolveKiroEndpoints returns the validated, healthy endpoints to try for auth,
+// with the executor's X-Amz-Target overrides applied.
+func (e *KiroExecutor) resolveKiroEndpoints(auth *cliproxyauth.Auth) ([]kiroEndpointConfig, error) {
+	configs := getKiroEndpointConfigs(auth)
+	e.amzTargets.apply(configs, resolveKiroAPIRegion(auth))
+	configs, err := validateKiroEndpointConfigs(configs, getAuthValue(auth, "allow_http_endpoint") == "true")
+	if err != nil {
+		return nil, err
+	}
+	return filterHealthyKiroEndpoints(configs), nil
+}
+
 // KiroExecutor handles requests to AWS CodeWhisperer (Kiro) API.
 type KiroExecutor struct {
-	cfg          *config.Config
-	refreshMu    sync.Mutex // Serializes token refresh operations to prevent race conditions
-	profileArnMu sync.Mutex // Serializes profileArn fetches to prevent concurrent map writes
+	cfg               *config.Config
+	refreshMu         sync.Mutex             // Serializes token refresh operations to prevent race conditions
+	profileArnMu      sync.Mutex             // Serializes profileArn fetches to prevent concurrent map writes
+	accountKeyMetrics func(source string)    // Optional hook reporting which source an account key was derived from
+	sigV4Signing      bool                   // Sign upstream requests with SigV4 instead of sending the bearer token
+	amzTargets        kiroAmzTargetOverrides // X-Amz-Target overrides from kiro-amz-targets
+	oidcClient        kiroauth.OIDCClient    // Optional SSO OIDC client for refreshes and profile lookups
+}
+
+// KiroExecutorOption configures optional KiroExecutor behavior.
+type KiroExecutorOption func(*KiroExecutor)
+
+// Account key sources reported through WithAccountKeyMetrics, in fallback order.
+const (
+	AccountKeySourceClientID     = "client_id"
+	AccountKeySourceRefreshToken = "refresh_token"
+	AccountKeySourceAuthID       = "auth_id"
+	AccountKeySourceProfileArn   = "profile_arn"
+	AccountKeySourceAccessToken  = "access_token"
+	// AccountKeySourceAnonymous is the fixed seed used when auth carries nothing
+	// to derive a key from, so every such auth shares one fingerprint.
+	AccountKeySourceAnonymous = "anonymous"
+)
+
+// WithAccountKeyMetrics registers fn to be called with the source of every
+// account key the executor derives, one of the AccountKeySource constants.
+func WithAccountKeyMetrics(fn func(source string)) KiroExecutorOption {
+	return func(e *KiroExecutor) {
+		e.accountKeyMetrics = fn
+	}
+}
+
+// WithSigV4Signing makes the executor sign upstream requests with AWS Signature
+// Version 4 using the auth's aws_access_key_id, aws_secret_access_key and
+// optional aws_session_token, instead of sending the access token as a bearer.
+func WithSigV4Signing(enabled bool) KiroExecutorOption {
+	return func(e *KiroExecutor) {
+		e.sigV4Signing = enabled
+	}
+}
+
+// WithOIDCClient makes the executor use client for Builder ID and IDC refreshes
+// and profile ARN lookups instead of creating an SSOOIDCClient per call.
+func WithOIDCClient(client kiroauth.OIDCClient) KiroExecutorOption {
+	return func(e *KiroExecutor) {
+		e.oidcClient = client
+	}
+}
+
+// ssoClient returns the configured OIDC client or a new SSOOIDCClient.
+func (e *KiroExecutor) ssoClient() kiroauth.OIDCClient {
+	if e.oidcClient != nil {
+		return e.oidcClient
+	}
+	return kiroauth.NewSSOOIDCClient(e.cfg)
+}
+
+// kiroSigV4Credentials reads the static AWS credentials used for SigV4 signing
+// from auth metadata, falling back to attributes.
+func kiroSigV4Credentials(auth *cliproxyauth.Auth) (aws.Credentials, bool) {
+	if auth == nil {
+		return aws.Credentials{}, false
+	}
+	lookup := func(key string) string {
+		if v, ok := auth.Metadata[key].(string); ok && strings.TrimSpace(v) != "" {
+			return strings.TrimSpace(v)
+		}
+		return strings.TrimSpace(auth.Attributes[key])
+	}
+	creds := aws.Credentials{
+		AccessKeyID:     lookup("aws_access_key_id"),
+		SecretAccessKey: lookup("aws_secret_access_key"),
+		SessionToken:    lookup("aws_session_token"),
+		Source:          "kiro-auth",
+	}
+	return creds, creds.AccessKeyID != "" && creds.SecretAccessKey != ""
+}
+
+// signRequest replaces the bearer Authorization on req with a SigV4 signature
+// when signing is enabled. It must run after every other header is set.
+func (e *KiroExecutor) signRequest(req *http.Request, auth *cliproxyauth.Auth) error {
+	if !e.sigV4Signing {
+		return nil
+	}
+	creds, ok := kiroSigV4Credentials(auth)
+	if !ok {
+		return statusErr{code: http.StatusUnauthorized, msg: "missing AWS credentials for SigV4 signing"}
+	}
+	req.Header.Del("Authorization")
+	service := kiroauth.SigV4ServiceForHost(req.URL.Hostname())
+	if errSign := kiroauth.SignKiroRequest(req, creds, resolveKiroAPIRegion(auth), service); errSign != nil {
+		return statusErr{code: http.StatusUnauthorized, msg: errSign.Error()}
+	}
+	return nil
 }
 
 // buildKiroPayloadForFormat builds the Kiro API payload based on the source format.
@@ -486,29 +949,29 @@ func buildKiroPayloadForFormat(body []byte, modelID, profileArn, origin string,
 }
 
 // NewKiroExecutor creates a new Kiro executor instance.
-func NewKiroExecutor(cfg *config.Config) *KiroExecutor {
-	return &KiroExecutor{cfg: cfg}
+func NewKiroExecutor(cfg *config.Config, opts ...KiroExecutorOption) *KiroExecutor {
+	e := &KiroExecutor{cfg: cfg}
+	if cfg != nil {
+		e.amzTargets = newKiroAmzTargetOverrides(cfg.KiroAmzTargets)
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
 }
 
 // Identifier returns the unique identifier for this executor.
 func (e *KiroExecutor) Identifier() string { return "kiro" }
 
-// applyDynamicFingerprint applies account-specific fingerprint headers to the request.
-func applyDynamicFingerprint(req *http.Request, auth *cliproxyauth.Auth) {
+// applyDynamicFingerprint applies the account-specific streaming headers and the
+// bearer accessToken to the request.
+func applyDynamicFingerprint(req *http.Request, auth *cliproxyauth.Auth, accessToken string) {
 	accountKey := getAccountKey(auth)
-	fp := kiroauth.GlobalFingerprintManager().GetFingerprint(accountKey)
-
-	req.Header.Set("User-Agent", fp.BuildUserAgent())
-	req.Header.Set("X-Amz-User-Agent", fp.BuildAmzUserAgent())
-	req.Header.Set("x-amzn-kiro-agent-mode", kiroIDEAgentMode)
-	req.Header.Set("x-amzn-codewhisperer-optout", "true")
+	kiroauth.SetStreamingHeaders(req, accessToken, accountKey)
+	fp := kiroauth.GlobalFingerprintManager().GetFingerprintFor(accountKey, kiroauth.FingerprintPurposeStreaming)
 
-	keyPrefix := accountKey
-	if len(keyPrefix) > 8 {
-		keyPrefix = keyPrefix[:8]
-	}
 	log.Debugf("kiro: using dynamic fingerprint for account %s (SDK:%s, OS:%s/%s, Kiro:%s)",
-		keyPrefix+"...", fp.StreamingSDKVersion, fp.OSType, fp.OSVersion, fp.KiroVersion)
+		util.ObfuscateToken(accountKey), fp.StreamingSDKVersion, fp.OSType, fp.OSVersion, fp.KiroVersion)
 }
 
 // PrepareRequest prepares the HTTP request before execution.
@@ -522,17 +985,13 @@ func (e *KiroExecutor) PrepareRequest(req *http.Request, auth *cliproxyauth.Auth
 	}
 
 	// Apply dynamic fingerprint-based headers
-	applyDynamicFingerprint(req, auth)
-
-	req.Header.Set("Amz-Sdk-Request", "attempt=1; max=3")
-	req.Header.Set("Amz-Sdk-Invocation-Id", uuid.New().String())
-	req.Header.Set("Authorization", "Bearer "+accessToken)
+	applyDynamicFingerprint(req, auth, accessToken)
 	var attrs map[string]string
 	if auth != nil {
 		attrs = auth.Attributes
 	}
 	util.ApplyCustomHeadersFromAttrs(req, attrs)
-	return nil
+	return e.signRequest(req, auth)
 }
 
 // HttpRequest injects Kiro credentials into the request and executes it.
@@ -559,25 +1018,44 @@ func (e *KiroExecutor) HttpRequest(ctx context.Context, auth *cliproxyauth.Auth,
 // 4) access_token (least preferred but deterministic)
 // 5) fixed anonymous seed
 func getAccountKey(auth *cliproxyauth.Auth) string {
+	key, _ := resolveAccountKey(auth)
+	return key
+}
+
+// resolveAccountKey returns the account key along with the source it was derived from.
+func resolveAccountKey(auth *cliproxyauth.Auth) (string, string) {
 	var clientID, refreshToken, profileArn string
 	if auth != nil && auth.Metadata != nil {
 		clientID, _ = auth.Metadata["client_id"].(string)
 		refreshToken, _ = auth.Metadata["refresh_token"].(string)
 		profileArn, _ = auth.Metadata["profile_arn"].(string)
 	}
-	if clientID != "" || refreshToken != "" {
-		return kiroauth.GetAccountKey(clientID, refreshToken)
+	if clientID != "" {
+		return kiroauth.GetAccountKey(clientID, refreshToken), AccountKeySourceClientID
+	}
+	if refreshToken != "" {
+		return kiroauth.GetAccountKey("", refreshToken), AccountKeySourceRefreshToken
 	}
 	if auth != nil && auth.ID != "" {
-		return kiroauth.GenerateAccountKey(auth.ID)
+		return kiroauth.GenerateAccountKey(auth.ID), AccountKeySourceAuthID
 	}
 	if profileArn != "" {
-		return kiroauth.GenerateAccountKey(profileArn)
+		return kiroauth.GenerateAccountKey(profileArn), AccountKeySourceProfileArn
 	}
 	if accessToken, _ := kiroCredentials(auth); accessToken != "" {
-		return kiroauth.GenerateAccountKey(accessToken)
+		return kiroauth.GenerateAccountKey(accessToken), AccountKeySourceAccessToken
+	}
+	return kiroauth.GenerateAccountKey("kiro-anonymous"), AccountKeySourceAnonymous
+}
+
+// accountKey resolves the account key for auth and reports its source to the
+// configured account key metrics hook, if any.
+func (e *KiroExecutor) accountKey(auth *cliproxyauth.Auth) string {
+	key, source := resolveAccountKey(auth)
+	if e.accountKeyMetrics != nil {
+		e.accountKeyMetrics(source)
 	}
-	return kiroauth.GenerateAccountKey("kiro-anonymous")
+	return key
 }
 
 // getAuthValue looks up a value by key in auth Metadata, then Attributes.
@@ -607,7 +1085,7 @@ func (e *KiroExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req
 	}
 
 	// Rate limiting: get token key for tracking
-	tokenKey := getAccountKey(auth)
+	tokenKey := e.accountKey(auth)
 	rateLimiter := kiroauth.GetGlobalRateLimiter()
 	cooldownMgr := kiroauth.GetGlobalCooldownManager()
 
@@ -615,14 +1093,14 @@ func (e *KiroExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req
 	if cooldownMgr.IsInCooldown(tokenKey) {
 		remaining := cooldownMgr.GetRemainingCooldown(tokenKey)
 		reason := cooldownMgr.GetCooldownReason(tokenKey)
-		log.Warnf("kiro: token %s is in cooldown (reason: %s), remaining: %v", tokenKey, reason, remaining)
+		log.Warnf("kiro: token %s is in cooldown (reason: %s), remaining: %v", util.ObfuscateToken(tokenKey), reason, remaining)
 		return resp, fmt.Errorf("kiro: token is in cooldown for %v (reason: %s)", remaining, reason)
 	}
 
 	// Wait for rate limiter before proceeding
-	log.Debugf("kiro: waiting for rate limiter for token %s", tokenKey)
+	log.Debugf("kiro: waiting for rate limiter for token %s", util.ObfuscateToken(tokenKey))
 	rateLimiter.WaitForToken(tokenKey)
-	log.Debugf("kiro: rate limiter cleared for token %s", tokenKey)
+	log.Debugf("kiro: rate limiter cleared for token %s", util.ObfuscateToken(tokenKey))
 
 	// Check if token is expired before making request (covers both normal and web_search paths)
 	if e.isTokenExpired(accessToken) {
@@ -697,7 +1175,10 @@ func (e *KiroExecutor) executeWithRetry(ctx context.Context, auth *cliproxyauth.
 	maxRetries := 2 // Allow retries for token refresh + endpoint fallback
 	rateLimiter := kiroauth.GetGlobalRateLimiter()
 	cooldownMgr := kiroauth.GetGlobalCooldownManager()
-	endpointConfigs := getKiroEndpointConfigs(auth)
+	endpointConfigs, errEndpoints := e.resolveKiroEndpoints(auth)
+	if errEndpoints != nil {
+		return resp, errEndpoints
+	}
 	var last429Err error
 
 	for endpointIdx := 0; endpointIdx < len(endpointConfigs); endpointIdx++ {
@@ -720,7 +1201,7 @@ func (e *KiroExecutor) executeWithRetry(ctx context.Context, auth *cliproxyauth.
 				kiroauth.ApplyHumanLikeDelay()
 			}
 
-			httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(kiroPayload))
+			httpReq, err := http.NewRequestWithContext(WithEndpoint(ctx, url), http.MethodPost, url, bytes.NewReader(kiroPayload))
 			if err != nil {
 				return resp, err
 			}
@@ -731,24 +1212,19 @@ func (e *KiroExecutor) executeWithRetry(ctx context.Context, auth *cliproxyauth.
 			if endpointConfig.AmzTarget != "" {
 				httpReq.Header.Set("X-Amz-Target", endpointConfig.AmzTarget)
 			}
-			// Kiro-specific headers
-			httpReq.Header.Set("x-amzn-kiro-agent-mode", kiroIDEAgentMode)
-			httpReq.Header.Set("x-amzn-codewhisperer-optout", "true")
-
-			// Apply dynamic fingerprint-based headers
-			applyDynamicFingerprint(httpReq, auth)
-
-			httpReq.Header.Set("Amz-Sdk-Request", "attempt=1; max=3")
-			httpReq.Header.Set("Amz-Sdk-Invocation-Id", uuid.New().String())
-
-			// Bearer token authentication for all auth types (Builder ID, IDC, social, etc.)
-			httpReq.Header.Set("Authorization", "Bearer "+accessToken)
+			// Apply dynamic fingerprint-based headers, the Kiro agent mode and
+			// bearer token authentication for all auth types (Builder ID, IDC,
+			// social, etc.)
+			applyDynamicFingerprint(httpReq, auth, accessToken)
 
 			var attrs map[string]string
 			if auth != nil {
 				attrs = auth.Attributes
 			}
 			util.ApplyCustomHeadersFromAttrs(httpReq, attrs)
+			if errSign := e.signRequest(httpReq, auth); errSign != nil {
+				return resp, errSign
+			}
 
 			var authID, authLabel, authType, authValue string
 			if auth != nil {
@@ -799,6 +1275,7 @@ func (e *KiroExecutor) executeWithRetry(ctx context.Context, auth *cliproxyauth.
 				return resp, err
 			}
 			recordAPIResponseMetadata(ctx, e.cfg, httpResp.StatusCode, httpResp.Header.Clone())
+			helps.EndpointHealthFor(url).RecordStatus(httpResp.StatusCode)
 
 			// Handle 429 errors (quota exhausted) - try next endpoint
 			// Each endpoint has its own quota pool, so we can try different endpoints
@@ -811,7 +1288,7 @@ func (e *KiroExecutor) executeWithRetry(ctx context.Context, auth *cliproxyauth.
 				rateLimiter.MarkTokenFailed(tokenKey)
 				cooldownDuration := kiroauth.CalculateCooldownFor429(attempt)
 				cooldownMgr.SetCooldown(tokenKey, cooldownDuration, kiroauth.CooldownReason429)
-				log.Warnf("kiro: rate limit hit (429), token %s set to cooldown for %v", tokenKey, cooldownDuration)
+				log.Warnf("kiro: rate limit hit (429), token %s set to cooldown for %v", util.ObfuscateToken(tokenKey), cooldownDuration)
 
 				// Preserve last 429 so callers can correctly backoff when all endpoints are exhausted
 				last429Err = statusErr{code: httpResp.StatusCode, msg: string(respBody)}
@@ -915,7 +1392,7 @@ func (e *KiroExecutor) executeWithRetry(ctx context.Context, auth *cliproxyauth.
 					// Set long cooldown for suspended accounts
 					rateLimiter.CheckAndMarkSuspended(tokenKey, respBodyStr)
 					cooldownMgr.SetCooldown(tokenKey, kiroauth.LongCooldown, kiroauth.CooldownReasonSuspended)
-					log.Errorf("kiro: account is suspended, token %s set to cooldown for %v", tokenKey, kiroauth.LongCooldown)
+					log.Errorf("kiro: account is suspended, token %s set to cooldown for %v", util.ObfuscateToken(tokenKey), kiroauth.LongCooldown)
 					return resp, statusErr{code: httpResp.StatusCode, msg: "account suspended: " + string(respBody)}
 				}
 
@@ -1012,7 +1489,7 @@ func (e *KiroExecutor) executeWithRetry(ctx context.Context, auth *cliproxyauth.
 
 			// Record success for rate limiting
 			rateLimiter.MarkTokenSuccess(tokenKey)
-			log.Debugf("kiro: request successful, token %s marked as success", tokenKey)
+			log.Debugf("kiro: request successful, token %s marked as success", util.ObfuscateToken(tokenKey))
 
 			// Build response in Claude format for Kiro translator
 			// stopReason is extracted from upstream response by parseEventStream
@@ -1043,7 +1520,7 @@ func (e *KiroExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Aut
 	}
 
 	// Rate limiting: get token key for tracking
-	tokenKey := getAccountKey(auth)
+	tokenKey := e.accountKey(auth)
 	rateLimiter := kiroauth.GetGlobalRateLimiter()
 	cooldownMgr := kiroauth.GetGlobalCooldownManager()
 
@@ -1051,14 +1528,14 @@ func (e *KiroExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Aut
 	if cooldownMgr.IsInCooldown(tokenKey) {
 		remaining := cooldownMgr.GetRemainingCooldown(tokenKey)
 		reason := cooldownMgr.GetCooldownReason(tokenKey)
-		log.Warnf("kiro: token %s is in cooldown (reason: %s), remaining: %v", tokenKey, reason, remaining)
+		log.Warnf("kiro: token %s is in cooldown (reason: %s), remaining: %v", util.ObfuscateToken(tokenKey), reason, remaining)
 		return nil, fmt.Errorf("kiro: token is in cooldown for %v (reason: %s)", remaining, reason)
 	}
 
 	// Wait for rate limiter before proceeding
-	log.Debugf("kiro: stream waiting for rate limiter for token %s", tokenKey)
+	log.Debugf("kiro: stream waiting for rate limiter for token %s", util.ObfuscateToken(tokenKey))
 	rateLimiter.WaitForToken(tokenKey)
-	log.Debugf("kiro: stream rate limiter cleared for token %s", tokenKey)
+	log.Debugf("kiro: stream rate limiter cleared for token %s", util.ObfuscateToken(tokenKey))
 
 	// Check if token is expired before making request (covers both normal and web_search paths)
 	if e.isTokenExpired(accessToken) {
@@ -1139,7 +1616,10 @@ func (e *KiroExecutor) executeStreamWithRetry(ctx context.Context, auth *cliprox
 	maxRetries := 2 // Allow retries for token refresh + endpoint fallback
 	rateLimiter := kiroauth.GetGlobalRateLimiter()
 	cooldownMgr := kiroauth.GetGlobalCooldownManager()
-	endpointConfigs := getKiroEndpointConfigs(auth)
+	endpointConfigs, errEndpoints := e.resolveKiroEndpoints(auth)
+	if errEndpoints != nil {
+		return nil, errEndpoints
+	}
 	var last429Err error
 
 	for endpointIdx := 0; endpointIdx < len(endpointConfigs); endpointIdx++ {
@@ -1163,7 +1643,7 @@ func (e *KiroExecutor) executeStreamWithRetry(ctx context.Context, auth *cliprox
 				kiroauth.ApplyHumanLikeDelay()
 			}
 
-			httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(kiroPayload))
+			httpReq, err := http.NewRequestWithContext(WithEndpoint(ctx, url), http.MethodPost, url, bytes.NewReader(kiroPayload))
 			if err != nil {
 				return nil, err
 			}
@@ -1174,24 +1654,19 @@ func (e *KiroExecutor) executeStreamWithRetry(ctx context.Context, auth *cliprox
 			if endpointConfig.AmzTarget != "" {
 				httpReq.Header.Set("X-Amz-Target", endpointConfig.AmzTarget)
 			}
-			// Kiro-specific headers
-			httpReq.Header.Set("x-amzn-kiro-agent-mode", kiroIDEAgentMode)
-			httpReq.Header.Set("x-amzn-codewhisperer-optout", "true")
-
-			// Apply dynamic fingerprint-based headers
-			applyDynamicFingerprint(httpReq, auth)
-
-			httpReq.Header.Set("Amz-Sdk-Request", "attempt=1; max=3")
-			httpReq.Header.Set("Amz-Sdk-Invocation-Id", uuid.New().String())
-
-			// Bearer token authentication for all auth types (Builder ID, IDC, social, etc.)
-			httpReq.Header.Set("Authorization", "Bearer "+accessToken)
+			// Apply dynamic fingerprint-based headers, the Kiro agent mode and
+			// bearer token authentication for all auth types (Builder ID, IDC,
+			// social, etc.)
+			applyDynamicFingerprint(httpReq, auth, accessToken)
 
 			var attrs map[string]string
 			if auth != nil {
 				attrs = auth.Attributes
 			}
 			util.ApplyCustomHeadersFromAttrs(httpReq, attrs)
+			if errSign := e.signRequest(httpReq, auth); errSign != nil {
+				return nil, errSign
+			}
 
 			var authID, authLabel, authType, authValue string
 			if auth != nil {
@@ -1228,6 +1703,7 @@ func (e *KiroExecutor) executeStreamWithRetry(ctx context.Context, auth *cliprox
 				return nil, err
 			}
 			recordAPIResponseMetadata(ctx, e.cfg, httpResp.StatusCode, httpResp.Header.Clone())
+			helps.EndpointHealthFor(url).RecordStatus(httpResp.StatusCode)
 
 			// Handle 429 errors (quota exhausted) - try next endpoint
 			// Each endpoint has its own quota pool, so we can try different endpoints
@@ -1240,7 +1716,7 @@ func (e *KiroExecutor) executeStreamWithRetry(ctx context.Context, auth *cliprox
 				rateLimiter.MarkTokenFailed(tokenKey)
 				cooldownDuration := kiroauth.CalculateCooldownFor429(attempt)
 				cooldownMgr.SetCooldown(tokenKey, cooldownDuration, kiroauth.CooldownReason429)
-				log.Warnf("kiro: stream rate limit hit (429), token %s set to cooldown for %v", tokenKey, cooldownDuration)
+				log.Warnf("kiro: stream rate limit hit (429), token %s set to cooldown for %v", util.ObfuscateToken(tokenKey), cooldownDuration)
 
 				// Preserve last 429 so callers can correctly backoff when all endpoints are exhausted
 				last429Err = statusErr{code: httpResp.StatusCode, msg: string(respBody)}
@@ -1357,7 +1833,7 @@ func (e *KiroExecutor) executeStreamWithRetry(ctx context.Context, auth *cliprox
 					// Set long cooldown for suspended accounts
 					rateLimiter.CheckAndMarkSuspended(tokenKey, respBodyStr)
 					cooldownMgr.SetCooldown(tokenKey, kiroauth.LongCooldown, kiroauth.CooldownReasonSuspended)
-					log.Errorf("kiro: stream account is suspended, token %s set to cooldown for %v", tokenKey, kiroauth.LongCooldown)
+					log.Errorf("kiro: stream account is suspended, token %s set to cooldown for %v", util.ObfuscateToken(tokenKey), kiroauth.LongCooldown)
 					return nil, statusErr{code: httpResp.StatusCode, msg: "account suspended: " + string(respBody)}
 				}
 
@@ -1410,7 +1886,7 @@ func (e *KiroExecutor) executeStreamWithRetry(ctx context.Context, auth *cliprox
 			// Record success immediately since connection was established successfully
 			// Streaming errors will be handled separately
 			rateLimiter.MarkTokenSuccess(tokenKey)
-			log.Debugf("kiro: stream request successful, token %s marked as success", tokenKey)
+			log.Debugf("kiro: stream request successful, token %s marked as success", util.ObfuscateToken(tokenKey))
 
 			go func(resp *http.Response, thinkingEnabled bool) {
 				defer close(out)
@@ -1776,10 +2252,76 @@ func (e *EventStreamError) Error() string {
 	return fmt.Sprintf("event stream %s: %s", e.Type, e.Message)
 }
 
+// KiroStreamError is an exception or error frame received inside a Kiro event
+// stream, which the API may send after an HTTP 200 response has started.
+type KiroStreamError struct {
+	Code    string // :exception-type or :error-code header, e.g. "ThrottlingException"
+	Message string
+}
+
+func (e *KiroStreamError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("kiro stream error: %s", e.Code)
+	}
+	return fmt.Sprintf("kiro stream error: %s: %s", e.Code, e.Message)
+}
+
+// StatusCode maps the stream error code to the HTTP status reported to clients.
+func (e *KiroStreamError) StatusCode() int {
+	switch e.Code {
+	case "ThrottlingException", "ServiceQuotaExceededException":
+		return http.StatusTooManyRequests
+	case "ValidationException", "ContentLengthExceededException":
+		return http.StatusBadRequest
+	case "AccessDeniedException", "UnauthorizedException":
+		return http.StatusForbidden
+	case "ResourceNotFoundException":
+		return http.StatusNotFound
+	case "ServiceUnavailableException":
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadGateway
+	}
+}
+
 // eventStreamMessage represents a parsed AWS Event Stream message
 type eventStreamMessage struct {
-	EventType string // Event type from headers (e.g., "assistantResponseEvent")
-	Payload   []byte // JSON payload of the message
+	EventType string            // Event type from headers (e.g., "assistantResponseEvent")
+	Headers   map[string]string // String-valued headers such as :message-type and :exception-type
+	Payload   []byte            // JSON payload of the message
+}
+
+// streamError returns the typed error carried by an exception or error frame,
+// or nil for ordinary event frames.
+func (m *eventStreamMessage) streamError() *KiroStreamError {
+	switch m.Headers[":message-type"] {
+	case "exception":
+		streamErr := &KiroStreamError{Code: m.Headers[":exception-type"]}
+		var body struct {
+			Message      string `json:"message"`
+			MessageUpper string `json:"Message"`
+		}
+		if err := json.Unmarshal(m.Payload, &body); err == nil {
+			streamErr.Message = body.Message
+			if streamErr.Message == "" {
+				streamErr.Message = body.MessageUpper
+			}
+		} else {
+			streamErr.Message = strings.TrimSpace(string(m.Payload))
+		}
+		if streamErr.Code == "" {
+			streamErr.Code = "UnknownException"
+		}
+		return streamErr
+	case "error":
+		streamErr := &KiroStreamError{Code: m.Headers[":error-code"], Message: m.Headers[":error-message"]}
+		if streamErr.Code == "" {
+			streamErr.Code = "UnknownError"
+		}
+		return streamErr
+	default:
+		return nil
+	}
 }
 
 // NOTE: Request building functions moved to internal/translator/kiro/claude/kiro_claude_request.go
@@ -1813,6 +2355,10 @@ func (e *KiroExecutor) parseEventStream(body io.Reader) (string, []kiroclaude.Ki
 			// Normal end of stream (EOF)
 			break
 		}
+		if streamErr := msg.streamError(); streamErr != nil {
+			log.Errorf("kiro: parseEventStream received %v", streamErr)
+			return "", nil, usageInfo, stopReason, streamErr
+		}
 
 		eventType := msg.EventType
 		payload := msg.Payload
@@ -2329,12 +2875,13 @@ func (e *KiroExecutor) readEventStreamMessage(reader *bufio.Reader) (*eventStrea
 		}
 	}
 
-	// Extract event type from headers
+	// Extract string headers (event type, message type, exception type)
 	// Headers start at beginning of 'remaining', length is headersLength
-	var eventType string
+	var headers map[string]string
 	if headersLength > 0 && headersLength <= uint32(len(remaining)) {
-		eventType = e.extractEventTypeFromBytes(remaining[:headersLength])
+		headers = extractEventStreamStringHeaders(remaining[:headersLength])
 	}
+	eventType := headers[":event-type"]
 
 	// Calculate payload boundaries
 	// Payload starts after headers, ends before message_crc (last 4 bytes)
@@ -2346,6 +2893,7 @@ func (e *KiroExecutor) readEventStreamMessage(reader *bufio.Reader) (*eventStrea
 		// No payload, return empty message
 		return &eventStreamMessage{
 			EventType: eventType,
+			Headers:   headers,
 			Payload:   nil,
 		}, nil
 	}
@@ -2354,6 +2902,7 @@ func (e *KiroExecutor) readEventStreamMessage(reader *bufio.Reader) (*eventStrea
 
 	return &eventStreamMessage{
 		EventType: eventType,
+		Headers:   headers,
 		Payload:   payload,
 	}, nil
 }
@@ -2407,8 +2956,10 @@ func skipEventStreamHeaderValue(headers []byte, offset int, valueType byte) (int
 	}
 }
 
-// extractEventTypeFromBytes extracts the event type from raw header bytes (without prelude CRC prefix)
-func (e *KiroExecutor) extractEventTypeFromBytes(headers []byte) string {
+// extractEventStreamStringHeaders returns the string-valued headers from raw
+// header bytes (without prelude CRC prefix). Parsing stops at the first malformed header.
+func extractEventStreamStringHeaders(headers []byte) map[string]string {
+	values := make(map[string]string)
 	offset := 0
 	for offset < len(headers) {
 		nameLen := int(headers[offset])
@@ -2434,12 +2985,8 @@ func (e *KiroExecutor) extractEventTypeFromBytes(headers []byte) string {
 			if offset+valueLen > len(headers) {
 				break
 			}
-			value := string(headers[offset : offset+valueLen])
+			values[name] = string(headers[offset : offset+valueLen])
 			offset += valueLen
-
-			if name == ":event-type" {
-				return value
-			}
 			continue
 		}
 
@@ -2449,7 +2996,7 @@ func (e *KiroExecutor) extractEventTypeFromBytes(headers []byte) string {
 		}
 		offset = nextOffset
 	}
-	return ""
+	return values
 }
 
 // NOTE: Response building functions moved to internal/translator/kiro/claude/kiro_claude_response.go
@@ -2608,6 +3155,11 @@ func (e *KiroExecutor) streamToChannel(ctx context.Context, body io.Reader, out
 			// Original code preserved in git history.
 			break
 		}
+		if streamErr := msg.streamError(); streamErr != nil {
+			log.Errorf("kiro: streamToChannel received %v", streamErr)
+			out <- cliproxyexecutor.StreamChunk{Err: streamErr}
+			return
+		}
 
 		eventType := msg.EventType
 		payload := msg.Payload
@@ -3678,6 +4230,8 @@ func (e *KiroExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth) (*c
 	var clientID, clientSecret string
 	var authMethod string
 	var region, startURL string
+	var secondaryRegion, secondaryStartURL string
+	var secondaryClientID, secondaryClientSecret, secondaryRefreshToken string
 
 	if auth.Metadata != nil {
 		if rt, ok := auth.Metadata["refresh_token"].(string); ok {
@@ -3698,37 +4252,58 @@ func (e *KiroExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth) (*c
 		if su, ok := auth.Metadata["start_url"].(string); ok {
 			startURL = su
 		}
+		secondaryStartURL, _ = auth.Metadata["secondary_start_url"].(string)
+		secondaryRegion, _ = auth.Metadata["secondary_region"].(string)
+		secondaryClientID, _ = auth.Metadata["secondary_client_id"].(string)
+		secondaryClientSecret, _ = auth.Metadata["secondary_client_secret"].(string)
+		secondaryRefreshToken, _ = auth.Metadata["secondary_refresh_token"].(string)
 	}
 
 	if refreshToken == "" {
 		return nil, fmt.Errorf("kiro executor: refresh token not found")
 	}
 
-	var tokenData *kiroauth.KiroTokenData
-	var err error
-
-	ssoClient := kiroauth.NewSSOOIDCClient(e.cfg)
-
-	// Use SSO OIDC refresh for AWS Builder ID or IDC, otherwise use Kiro's OAuth refresh endpoint
-	switch {
-	case clientID != "" && clientSecret != "" && authMethod == "idc" && region != "":
-		// IDC refresh with region-specific endpoint
-		log.Debugf("kiro executor: using SSO OIDC refresh for IDC (region=%s)", region)
-		tokenData, err = ssoClient.RefreshTokenWithRegion(ctx, clientID, clientSecret, refreshToken, region, startURL)
-	case clientID != "" && clientSecret != "" && authMethod == "builder-id":
-		// Builder ID refresh with default endpoint
-		log.Debugf("kiro executor: using SSO OIDC refresh for AWS Builder ID")
-		tokenData, err = ssoClient.RefreshToken(ctx, clientID, clientSecret, refreshToken)
-	default:
-		// Fallback to Kiro's OAuth refresh endpoint (for social auth: Google/GitHub)
-		log.Debugf("kiro executor: using Kiro OAuth refresh endpoint")
-		oauth := kiroauth.NewKiroOAuth(e.cfg)
-		tokenData, err = oauth.RefreshToken(ctx, refreshToken)
-	}
-
+	ssoClient := e.ssoClient()
+
+	// Share the refresh with concurrent refreshes of the same token elsewhere
+	// (background refresher, authenticator) so the refresh token is used once.
+	tokenData, err := kiroauth.SharedRefresh(ctx, refreshToken, func(ctx context.Context) (*kiroauth.KiroTokenData, error) {
+		// Use SSO OIDC refresh for AWS Builder ID or IDC, otherwise use Kiro's OAuth refresh endpoint
+		switch {
+		case clientID != "" && clientSecret != "" && authMethod == "idc" && region != "":
+			// IDC refresh with region-specific endpoint
+			log.Debugf("kiro executor: using SSO OIDC refresh for IDC (region=%s)", region)
+			return ssoClient.RefreshIDCToken(ctx, &kiroauth.KiroTokenData{
+				ClientID:              clientID,
+				ClientSecret:          clientSecret,
+				RefreshToken:          refreshToken,
+				Region:                region,
+				StartURL:              startURL,
+				SecondaryStartURL:     secondaryStartURL,
+				SecondaryRegion:       secondaryRegion,
+				SecondaryClientID:     secondaryClientID,
+				SecondaryClientSecret: secondaryClientSecret,
+				SecondaryRefreshToken: secondaryRefreshToken,
+			})
+		case clientID != "" && clientSecret != "" && authMethod == "builder-id":
+			// Builder ID refresh with default endpoint
+			log.Debugf("kiro executor: using SSO OIDC refresh for AWS Builder ID")
+			return ssoClient.RefreshToken(ctx, clientID, clientSecret, refreshToken)
+		default:
+			// Fallback to Kiro's OAuth refresh endpoint (for social auth: Google/GitHub)
+			log.Debugf("kiro executor: using Kiro OAuth refresh endpoint")
+			oauth := kiroauth.NewKiroOAuth(e.cfg)
+			return oauth.RefreshToken(ctx, refreshToken)
+		}
+	})
 	if err != nil {
 		return nil, fmt.Errorf("kiro executor: token refresh failed: %w", err)
 	}
+	storedEmail, _ := auth.Metadata["email"].(string)
+	previousAccessToken, _ := auth.Metadata["access_token"].(string)
+	if errIdentity := kiroauth.VerifyRefreshedIdentity(storedEmail, previousAccessToken, tokenData.AccessToken); errIdentity != nil {
+		return nil, fmt.Errorf("kiro executor: %w", errIdentity)
+	}
 
 	updated := auth.Clone()
 	now := time.Now()
@@ -3765,6 +4340,18 @@ func (e *KiroExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth) (*c
 	if tokenData.StartURL != "" {
 		updated.Metadata["start_url"] = tokenData.StartURL
 	}
+	// A failover swaps the primary and secondary IDC instances; keep both in sync
+	for key, value := range map[string]string{
+		"secondary_start_url":     tokenData.SecondaryStartURL,
+		"secondary_region":        tokenData.SecondaryRegion,
+		"secondary_client_id":     tokenData.SecondaryClientID,
+		"secondary_client_secret": tokenData.SecondaryClientSecret,
+		"secondary_refresh_token": tokenData.SecondaryRefreshToken,
+	} {
+		if value != "" {
+			updated.Metadata[key] = value
+		}
+	}
 
 	if updated.Attributes == nil {
 		updated.Attributes = make(map[string]string)
@@ -3853,7 +4440,7 @@ func (e *KiroExecutor) fetchAndSaveProfileArn(ctx context.Context, auth *cliprox
 	clientID, _ := auth.Metadata["client_id"].(string)
 	refreshToken, _ := auth.Metadata["refresh_token"].(string)
 
-	ssoClient := kiroauth.NewSSOOIDCClient(e.cfg)
+	ssoClient := e.ssoClient()
 	profileArn := ssoClient.FetchProfileArn(ctx, accessToken, clientID, refreshToken)
 	if profileArn == "" {
 		log.Debugf("kiro executor: FetchProfileArn returned no profiles")
@@ -4172,21 +4759,11 @@ func (h *webSearchHandler) setMcpHeaders(req *http.Request) {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "*/*")
 
-	// 2. Kiro-specific headers (aligned with GAR)
-	req.Header.Set("x-amzn-kiro-agent-mode", "vibe")
-	req.Header.Set("x-amzn-codewhisperer-optout", "true")
-
-	// 3. User-Agent: Reuse applyDynamicFingerprint for consistency
-	applyDynamicFingerprint(req, h.auth)
-
-	// 4. AWS SDK identifiers
-	req.Header.Set("Amz-Sdk-Request", "attempt=1; max=3")
-	req.Header.Set("Amz-Sdk-Invocation-Id", uuid.New().String())
-
-	// 5. Authentication
-	req.Header.Set("Authorization", "Bearer "+h.authToken)
+	// 2. Kiro-specific headers, User-Agent, AWS SDK identifiers and
+	// authentication: reuse applyDynamicFingerprint for consistency
+	applyDynamicFingerprint(req, h.auth, h.authToken)
 
-	// 6. Custom headers from auth attributes
+	// 3. Custom headers from auth attributes
 	util.ApplyCustomHeadersFromAttrs(req, h.authAttrs)
 }
 
@@ -4604,7 +5181,7 @@ func (e *KiroExecutor) callKiroAndBuffer(
 	isAgentic, isChatOnly := determineAgenticMode(req.Model)
 	effectiveProfileArn := getEffectiveProfileArnWithWarning(auth, profileArn)
 
-	tokenKey := getAccountKey(auth)
+	tokenKey := e.accountKey(auth)
 
 	kiroStream, err := e.executeStreamWithRetry(
 		ctx, auth, req, opts, accessToken, effectiveProfileArn,
@@ -4646,7 +5223,7 @@ func (e *KiroExecutor) callKiroDirectStream(
 	isAgentic, isChatOnly := determineAgenticMode(req.Model)
 	effectiveProfileArn := getEffectiveProfileArnWithWarning(auth, profileArn)
 
-	tokenKey := getAccountKey(auth)
+	tokenKey := e.accountKey(auth)
 
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
 	var streamErr error
@@ -4695,7 +5272,7 @@ func (e *KiroExecutor) executeNonStreamFallback(
 	kiroModelID := e.mapModelToKiro(req.Model)
 	isAgentic, isChatOnly := determineAgenticMode(req.Model)
 	effectiveProfileArn := getEffectiveProfileArnWithWarning(auth, profileArn)
-	tokenKey := getAccountKey(auth)
+	tokenKey := e.accountKey(auth)
 
 	reporter := newUsageReporter(ctx, e.Identifier(), req.Model, auth)
 	var err error
@@ -1,11 +1,26 @@
 package executor
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	kiroauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro/kirotest"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor/helps"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 )
 
 func TestBuildKiroEndpointConfigs(t *testing.T) {
@@ -90,6 +105,96 @@ func TestBuildKiroEndpointConfigs(t *testing.T) {
 	}
 }
 
+func TestBuildKiroEndpointConfigs_ChinaRegion(t *testing.T) {
+	configs := buildKiroEndpointConfigs("cn-north-1", kiroCapabilitySendMessage)
+	want := []string{
+		"https://q.cn-north-1.amazonaws.com.cn/generateAssistantResponse",
+		"https://codewhisperer.cn-north-1.amazonaws.com.cn/generateAssistantResponse",
+		"https://codewhisperer.cn-north-1.amazonaws.com.cn" + kiroSendMessagePath,
+	}
+	if len(configs) != len(want) {
+		t.Fatalf("expected %d endpoint configs, got %d", len(want), len(configs))
+	}
+	for i, cfg := range configs {
+		if cfg.URL != want[i] {
+			t.Errorf("%s URL = %q, want %q", cfg.Name, cfg.URL, want[i])
+		}
+	}
+}
+
+func TestSelectKiroEndpoint_Weighted(t *testing.T) {
+	configs := []kiroEndpointConfig{
+		{Name: "AmazonQ", Weight: 80},
+		{Name: "CodeWhisperer", Weight: 20},
+	}
+	rng := rand.New(rand.NewSource(42))
+
+	const draws = 10000
+	counts := make(map[string]int)
+	for i := 0; i < draws; i++ {
+		counts[selectKiroEndpoint(configs, rng).Name]++
+	}
+
+	for _, cfg := range configs {
+		got := float64(counts[cfg.Name]) / draws
+		want := float64(cfg.Weight) / 100
+		if math.Abs(got-want) > 0.05 {
+			t.Errorf("%s selected %.3f of draws, want %.2f ±0.05", cfg.Name, got, want)
+		}
+	}
+}
+
+func TestSelectKiroEndpoint_NoWeightsKeepsOrder(t *testing.T) {
+	configs := buildKiroEndpointConfigs("us-east-1")
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		if got := selectKiroEndpoint(configs, rng); got.Name != configs[0].Name {
+			t.Fatalf("selected %s, want first config %s", got.Name, configs[0].Name)
+		}
+	}
+	if got := selectKiroEndpoint(nil, rng); got != (kiroEndpointConfig{}) {
+		t.Errorf("selectKiroEndpoint(nil) = %+v, want zero value", got)
+	}
+}
+
+func TestGetKiroEndpointConfigs_EndpointWeights(t *testing.T) {
+	auth := &cliproxyauth.Auth{Metadata: map[string]any{"endpoint_weights": "amazonq=0, ide=100"}}
+	configs := getKiroEndpointConfigs(auth)
+	if len(configs) != 2 || configs[0].Name != "CodeWhisperer" || configs[1].Name != "AmazonQ" {
+		t.Fatalf("configs = %+v, want CodeWhisperer first with AmazonQ as fallback", configs)
+	}
+
+	// A preferred endpoint wins over the weights.
+	auth.Metadata["preferred_endpoint"] = "amazonq"
+	if got := getKiroEndpointConfigs(auth)[0].Name; got != "AmazonQ" {
+		t.Errorf("first endpoint with preference = %q, want AmazonQ", got)
+	}
+}
+
+func TestApplyKiroEndpointWeights(t *testing.T) {
+	configs := buildKiroEndpointConfigs("us-east-1")
+	rng := rand.New(rand.NewSource(7))
+
+	const draws = 10000
+	first := make(map[string]int)
+	for i := 0; i < draws; i++ {
+		ordered := applyKiroEndpointWeights(configs, "q=80,codewhisperer=20", rng)
+		if len(ordered) != len(configs) {
+			t.Fatalf("len = %d, want %d", len(ordered), len(configs))
+		}
+		first[ordered[0].Name]++
+	}
+	if got := float64(first["AmazonQ"]) / draws; math.Abs(got-0.8) > 0.05 {
+		t.Errorf("AmazonQ first in %.3f of draws, want 0.80 ±0.05", got)
+	}
+
+	for _, spec := range []string{"", "bogus=50", "amazonq=abc", "amazonq=-1"} {
+		if got := applyKiroEndpointWeights(configs, spec, rng); got[0].Name != "AmazonQ" || got[0].Weight != 0 {
+			t.Errorf("spec %q: first = %+v, want the unweighted order", spec, got[0])
+		}
+	}
+}
+
 func TestGetKiroEndpointConfigs_NilAuth(t *testing.T) {
 	configs := getKiroEndpointConfigs(nil)
 
@@ -126,6 +231,78 @@ func TestGetKiroEndpointConfigs_WithRegionFromProfileArn(t *testing.T) {
 	}
 }
 
+func TestResolveKiroAPIRegion_APIGatewayStageArn(t *testing.T) {
+	const stageArn = "arn:aws:apigateway:ap-northeast-1::/restapis/abc123/stages/prod"
+	tests := []struct {
+		name     string
+		metadata map[string]any
+		want     string
+	}{
+		{"stage arn only", map[string]any{"api_gateway_stage_arn": stageArn}, "ap-northeast-1"},
+		{"api_region wins", map[string]any{"api_region": "eu-west-1", "api_gateway_stage_arn": stageArn}, "eu-west-1"},
+		{"profile_arn wins", map[string]any{"profile_arn": "arn:aws:codewhisperer:eu-central-1:123456789012:profile/ABC", "api_gateway_stage_arn": stageArn}, "eu-central-1"},
+		{"malformed stage arn falls back to default", map[string]any{"api_gateway_stage_arn": "prod"}, kiroDefaultRegion},
+		{"no region sources", map[string]any{}, kiroDefaultRegion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveKiroAPIRegion(&cliproxyauth.Auth{Metadata: tt.metadata}); got != tt.want {
+				t.Errorf("resolveKiroAPIRegion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveKiroAPIRegion_UsesConfiguredDefault(t *testing.T) {
+	if err := kiroauth.SetDefaultRegion("eu-west-1"); err != nil {
+		t.Fatalf("SetDefaultRegion() error = %v", err)
+	}
+	t.Cleanup(func() { _ = kiroauth.SetDefaultRegion("") })
+
+	if got := resolveKiroAPIRegion(&cliproxyauth.Auth{Metadata: map[string]any{}}); got != "eu-west-1" {
+		t.Errorf("resolveKiroAPIRegion() = %q, want eu-west-1", got)
+	}
+	if got := resolveKiroAPIRegion(nil); got != "eu-west-1" {
+		t.Errorf("resolveKiroAPIRegion(nil) = %q, want eu-west-1", got)
+	}
+	if got := getKiroEndpointConfigs(nil)[0].URL; got != "https://q.eu-west-1.amazonaws.com/generateAssistantResponse" {
+		t.Errorf("getKiroEndpointConfigs(nil) primary URL = %q", got)
+	}
+}
+
+func TestGetKiroEndpointConfigs_QOrigin(t *testing.T) {
+	tests := []struct {
+		name   string
+		origin any
+		want   string
+	}{
+		{"default", nil, "AI_EDITOR"},
+		{"cli", "CLI", "CLI"},
+		{"console lowercase", "console", "CONSOLE"},
+		{"unknown falls back", "BROWSER", "AI_EDITOR"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := &cliproxyauth.Auth{Metadata: map[string]any{}}
+			if tt.origin != nil {
+				auth.Metadata["q_origin"] = tt.origin
+			}
+			configs := getKiroEndpointConfigs(auth)
+			for _, cfg := range configs {
+				if cfg.Origin != tt.want {
+					t.Errorf("%s Origin = %q, want %q", cfg.Name, cfg.Origin, tt.want)
+				}
+			}
+
+			body := []byte(`{"model":"claude-sonnet-4","messages":[{"role":"user","content":"hi"}]}`)
+			payload, _ := buildKiroPayloadForFormat(body, "claude-sonnet-4", "", configs[0].Origin, false, false, sdktranslator.FromString("claude"), nil)
+			if !strings.Contains(string(payload), `"origin":"`+tt.want+`"`) {
+				t.Errorf("payload does not carry origin %q: %s", tt.want, payload)
+			}
+		})
+	}
+}
+
 func TestGetKiroEndpointConfigs_WithApiRegionOverride(t *testing.T) {
 	auth := &cliproxyauth.Auth{
 		Metadata: map[string]any{
@@ -231,6 +408,43 @@ func TestGetKiroEndpointConfigs_MetadataTakesPrecedenceOverAttributes(t *testing
 	}
 }
 
+func TestResolveEndpointPreference(t *testing.T) {
+	tests := []struct {
+		pref   string
+		want   string
+		wantOK bool
+	}{
+		{"codewhisperer", "codewhisperer", true},
+		{"ide", "codewhisperer", true},
+		{"amazonq", "amazonq", true},
+		{"q", "amazonq", true},
+		{"cli", "amazonq", true},
+		{"sendmessage", "codewhisperersendmessage", true},
+		{"send_message", "codewhisperersendmessage", true},
+		{"codewhisperersendmessage", "codewhisperersendmessage", true},
+		{"CodeWhispererSendMessage", "codewhisperersendmessage", true},
+		{"  AmazonQ ", "amazonq", true},
+		{"IDE", "codewhisperer", true},
+		{"", "", false},
+		{"amazon-q", "", false},
+		{"codewhisper", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ResolveEndpointPreference(tt.pref)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("ResolveEndpointPreference(%q) = (%q, %v), want (%q, %v)", tt.pref, got, ok, tt.want, tt.wantOK)
+		}
+	}
+	for alias, canonical := range endpointAliases {
+		if _, ok := ResolveEndpointPreference(alias); !ok {
+			t.Errorf("alias %q is not resolved", alias)
+		}
+		if got, ok := ResolveEndpointPreference(canonical); !ok || got != canonical {
+			t.Errorf("canonical name %q resolves to (%q, %v), want itself", canonical, got, ok)
+		}
+	}
+}
+
 func TestGetAuthValue(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -398,14 +612,139 @@ func TestGetAccountKey(t *testing.T) {
 	}
 }
 
+func TestWithAccountKeyMetrics(t *testing.T) {
+	tests := []struct {
+		name       string
+		auth       *cliproxyauth.Auth
+		wantSource string
+	}{
+		{
+			name: "client_id",
+			auth: &cliproxyauth.Auth{
+				Metadata: map[string]any{
+					"client_id":     "test-client-id-123",
+					"refresh_token": "test-refresh-token-456",
+				},
+			},
+			wantSource: "client_id",
+		},
+		{
+			name: "refresh_token",
+			auth: &cliproxyauth.Auth{
+				Metadata: map[string]any{
+					"refresh_token": "test-refresh-token-789",
+				},
+			},
+			wantSource: "refresh_token",
+		},
+		{
+			name:       "auth_id",
+			auth:       &cliproxyauth.Auth{ID: "kiro-user.json"},
+			wantSource: "auth_id",
+		},
+		{
+			name:       "profile_arn",
+			auth:       &cliproxyauth.Auth{Metadata: map[string]any{"profile_arn": "arn:aws:codewhisperer:us-east-1:123:profile/X"}},
+			wantSource: "profile_arn",
+		},
+		{
+			name:       "access_token",
+			auth:       &cliproxyauth.Auth{Metadata: map[string]any{"access_token": "token"}},
+			wantSource: "access_token",
+		},
+		{
+			name:       "anonymous",
+			auth:       &cliproxyauth.Auth{},
+			wantSource: "anonymous",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sources []string
+			e := NewKiroExecutor(nil, WithAccountKeyMetrics(func(source string) {
+				sources = append(sources, source)
+			}))
+
+			key := e.accountKey(tt.auth)
+			if key != getAccountKey(tt.auth) {
+				t.Errorf("accountKey = %s, want %s", key, getAccountKey(tt.auth))
+			}
+			if len(sources) != 1 || sources[0] != tt.wantSource {
+				t.Errorf("reported sources = %v, want [%s]", sources, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestWithSigV4Signing(t *testing.T) {
+	auth := &cliproxyauth.Auth{
+		Metadata: map[string]any{
+			"access_token":          "bearer-token",
+			"api_region":            "eu-central-1",
+			"aws_access_key_id":     "AKIDEXAMPLE",
+			"aws_secret_access_key": "secret",
+			"aws_session_token":     "session-token",
+		},
+	}
+	newReq := func() *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, "https://q.eu-central-1.amazonaws.com/", bytes.NewReader([]byte(`{}`)))
+		return req
+	}
+	newCodeWhispererReq := func() *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, "https://codewhisperer.eu-central-1.amazonaws.com/", bytes.NewReader([]byte(`{}`)))
+		return req
+	}
+
+	req := newReq()
+	if err := NewKiroExecutor(nil).PrepareRequest(req, auth); err != nil {
+		t.Fatalf("PrepareRequest: %v", err)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer bearer-token" {
+		t.Errorf("default Authorization = %q, want bearer token", got)
+	}
+
+	e := NewKiroExecutor(nil, WithSigV4Signing(true))
+	req = newReq()
+	if err := e.PrepareRequest(req, auth); err != nil {
+		t.Fatalf("PrepareRequest with signing: %v", err)
+	}
+	if got := req.Header.Get("Authorization"); !strings.HasPrefix(got, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") || !strings.Contains(got, "/eu-central-1/q/aws4_request") {
+		t.Errorf("signed Authorization = %q, want the Amazon Q signing name", got)
+	}
+	cwReq := newCodeWhispererReq()
+	if err := e.PrepareRequest(cwReq, auth); err != nil {
+		t.Fatalf("PrepareRequest with signing: %v", err)
+	}
+	if got := cwReq.Header.Get("Authorization"); !strings.Contains(got, "/eu-central-1/codewhisperer/aws4_request") {
+		t.Errorf("signed CodeWhisperer Authorization = %q", got)
+	}
+	if req.Header.Get("X-Amz-Date") == "" {
+		t.Error("X-Amz-Date not set")
+	}
+	if got := req.Header.Get("X-Amz-Security-Token"); got != "session-token" {
+		t.Errorf("X-Amz-Security-Token = %q, want session-token", got)
+	}
+
+	noKeys := &cliproxyauth.Auth{Metadata: map[string]any{"access_token": "bearer-token"}}
+	err := e.PrepareRequest(newReq(), noKeys)
+	var se statusErr
+	if !errors.As(err, &se) || se.code != http.StatusUnauthorized {
+		t.Errorf("PrepareRequest without AWS keys = %v, want 401", err)
+	}
+}
+
 func TestEndpointAliases(t *testing.T) {
 	// Verify all expected aliases are defined
 	expectedAliases := map[string]string{
-		"codewhisperer": "codewhisperer",
-		"ide":           "codewhisperer",
-		"amazonq":       "amazonq",
-		"q":             "amazonq",
-		"cli":           "amazonq",
+		"codewhisperer":            "codewhisperer",
+		"ide":                      "codewhisperer",
+		"amazonq":                  "amazonq",
+		"q":                        "amazonq",
+		"cli":                      "amazonq",
+		"codewhisperersendmessage": "codewhisperersendmessage",
+		"sendmessage":              "codewhisperersendmessage",
+		"send_message":             "codewhisperersendmessage",
 	}
 
 	for alias, target := range expectedAliases {
@@ -421,3 +760,370 @@ func TestEndpointAliases(t *testing.T) {
 		t.Errorf("unexpected number of aliases: got %d, want %d", len(endpointAliases), len(expectedAliases))
 	}
 }
+
+func TestEndpointFromContext(t *testing.T) {
+	if got := EndpointFromContext(context.Background()); got != "" {
+		t.Fatalf("EndpointFromContext(empty) = %q, want empty", got)
+	}
+
+	const endpoint = "https://q.us-east-1.amazonaws.com/generateAssistantResponse"
+	ctx := WithEndpoint(context.Background(), endpoint)
+
+	// Simulate middleware layers that wrap the context with their own values,
+	// deadlines and cancellation before the request reaches the handler.
+	type middlewareKey struct{}
+	ctx = context.WithValue(ctx, middlewareKey{}, "request-id")
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	ctx = context.WithoutCancel(ctx)
+
+	var seen string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = EndpointFromContext(r.Context())
+	})
+	wrap := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), middlewareKey{}, "wrapped")))
+		})
+	}
+	req := httptest.NewRequest(http.MethodPost, endpoint, nil).WithContext(ctx)
+	wrap(wrap(handler)).ServeHTTP(httptest.NewRecorder(), req)
+
+	if seen != endpoint {
+		t.Fatalf("EndpointFromContext after middleware = %q, want %q", seen, endpoint)
+	}
+}
+
+func TestFilterHealthyKiroEndpoints(t *testing.T) {
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1)%2 == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	flaky := srv.URL + "/flaky"
+	steady := srv.URL + "/steady"
+	configs := []kiroEndpointConfig{{URL: flaky, Name: "Flaky"}, {URL: steady, Name: "Steady"}}
+
+	// Alternating 200/503 sits at the 50% threshold and keeps the endpoint selectable.
+	for i := 0; i < helps.DefaultEndpointHealthWindow; i++ {
+		resp, err := http.Get(flaky)
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		_ = resp.Body.Close()
+		helps.EndpointHealthFor(flaky).RecordStatus(resp.StatusCode)
+	}
+	if got := filterHealthyKiroEndpoints(configs); len(got) != 2 {
+		t.Fatalf("expected both endpoints at 50%% failures, got %d", len(got))
+	}
+
+	// One more failure tips the window over the threshold.
+	helps.EndpointHealthFor(flaky).RecordStatus(http.StatusServiceUnavailable)
+	got := filterHealthyKiroEndpoints(configs)
+	if len(got) != 1 || got[0].Name != "Steady" {
+		t.Fatalf("expected only the steady endpoint, got %+v", got)
+	}
+
+	// With every endpoint unhealthy the full list is kept.
+	for i := 0; i < helps.DefaultEndpointHealthWindow; i++ {
+		helps.EndpointHealthFor(steady).RecordStatus(http.StatusBadGateway)
+	}
+	if got := filterHealthyKiroEndpoints(configs); len(got) != 2 {
+		t.Fatalf("expected fallback to all endpoints, got %d", len(got))
+	}
+}
+
+func TestResolveKiroEndpoints_Override(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]any
+		wantURL  string
+		wantErr  bool
+	}{
+		{
+			name:     "valid https override is accepted",
+			metadata: map[string]any{"endpoint_url": "https://kiro.internal.example.com/generateAssistantResponse"},
+			wantURL:  "https://kiro.internal.example.com/generateAssistantResponse",
+		},
+		{
+			name:     "relative override is rejected",
+			metadata: map[string]any{"endpoint_url": "/generateAssistantResponse"},
+			wantErr:  true,
+		},
+		{
+			name:     "http override is rejected by default",
+			metadata: map[string]any{"endpoint_url": "http://127.0.0.1:8080/generateAssistantResponse"},
+			wantErr:  true,
+		},
+		{
+			name:     "http override is accepted when explicitly allowed",
+			metadata: map[string]any{"endpoint_url": "http://127.0.0.1:8080/generateAssistantResponse", "allow_http_endpoint": "true"},
+			wantURL:  "http://127.0.0.1:8080/generateAssistantResponse",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configs, err := NewKiroExecutor(nil).resolveKiroEndpoints(&cliproxyauth.Auth{Metadata: tt.metadata})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got endpoints %+v", configs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveKiroEndpoints() error = %v", err)
+			}
+			if len(configs) != 1 || configs[0].URL != tt.wantURL {
+				t.Fatalf("endpoints = %+v, want single %s", configs, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestValidateKiroEndpointConfigs_DropsInvalid(t *testing.T) {
+	configs := []kiroEndpointConfig{
+		{Name: "Relative", URL: "q.us-east-1.amazonaws.com/generateAssistantResponse"},
+		{Name: "Plain", URL: "http://q.us-east-1.amazonaws.com/generateAssistantResponse"},
+		{Name: "AmazonQ", URL: "https://q.us-east-1.amazonaws.com/generateAssistantResponse"},
+	}
+	got, err := validateKiroEndpointConfigs(configs, false)
+	if err != nil {
+		t.Fatalf("validateKiroEndpointConfigs() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Name != "AmazonQ" {
+		t.Fatalf("valid endpoints = %+v, want only AmazonQ", got)
+	}
+}
+
+func TestBuildKiroEndpointConfigs_AmzTarget(t *testing.T) {
+	byName := func(configs []kiroEndpointConfig) map[string]string {
+		targets := make(map[string]string, len(configs))
+		for _, cfg := range configs {
+			targets[cfg.Name] = cfg.AmzTarget
+		}
+		return targets
+	}
+
+	targets := byName(buildKiroEndpointConfigs("us-east-1"))
+	if got := targets["CodeWhisperer"]; got != "AmazonCodeWhispererStreamingService.GenerateAssistantResponse" {
+		t.Errorf("CodeWhisperer AmzTarget = %q", got)
+	}
+	if got := targets["AmazonQ"]; got != "" {
+		t.Errorf("AmazonQ AmzTarget = %q, want empty", got)
+	}
+	if got := resolveKiroAmzTarget("CodeWhisperer", "us-east-1", kiroOperationSendMessage); got != "AmazonCodeWhispererStreamingService.SendMessage" {
+		t.Errorf("SendMessage AmzTarget = %q", got)
+	}
+
+	overridden := NewKiroExecutor(&config.Config{KiroAmzTargets: map[string]string{
+		"CodeWhisperer/GenerateAssistantResponse":           "AmazonCodeWhispererStreamingService.GenerateAssistantResponseV2",
+		"codewhisperer/eu-west-1/GenerateAssistantResponse": "RegionalTarget.GenerateAssistantResponse",
+	}})
+	// A second executor must not replace the first one's overrides.
+	plain := NewKiroExecutor(&config.Config{})
+	resolve := func(e *KiroExecutor, region string) map[string]string {
+		configs, err := e.resolveKiroEndpoints(&cliproxyauth.Auth{Metadata: map[string]any{"api_region": region}})
+		if err != nil {
+			t.Fatalf("resolveKiroEndpoints(%s) error = %v", region, err)
+		}
+		return byName(configs)
+	}
+	if got := resolve(overridden, "us-east-1")["CodeWhisperer"]; got != "AmazonCodeWhispererStreamingService.GenerateAssistantResponseV2" {
+		t.Errorf("overridden CodeWhisperer AmzTarget = %q", got)
+	}
+	if got := resolve(overridden, "eu-west-1")["CodeWhisperer"]; got != "RegionalTarget.GenerateAssistantResponse" {
+		t.Errorf("region override AmzTarget = %q", got)
+	}
+	if got := resolve(plain, "us-east-1")["CodeWhisperer"]; got != "AmazonCodeWhispererStreamingService.GenerateAssistantResponse" {
+		t.Errorf("executor without overrides AmzTarget = %q, want the default", got)
+	}
+}
+
+func TestBuildKiroEndpointConfigs_SendMessage(t *testing.T) {
+	configs := buildKiroEndpointConfigs("eu-west-1", kiroCapabilitySendMessage)
+	if len(configs) != 3 {
+		t.Fatalf("expected 3 endpoint configs, got %d", len(configs))
+	}
+	generate, send := configs[1], configs[2]
+	if send.Name != "CodeWhispererSendMessage" {
+		t.Fatalf("SendMessage Name = %q", send.Name)
+	}
+	if send.URL != "https://codewhisperer.eu-west-1.amazonaws.com/SendMessageStreaming" {
+		t.Errorf("SendMessage URL = %q", send.URL)
+	}
+	if send.AmzTarget != "AmazonCodeWhispererStreamingService.SendMessage" {
+		t.Errorf("SendMessage AmzTarget = %q", send.AmzTarget)
+	}
+	if send.URL == generate.URL || send.AmzTarget == generate.AmzTarget {
+		t.Errorf("SendMessage variant should differ from GenerateAssistantResponse: %+v vs %+v", send, generate)
+	}
+	if send.Origin != generate.Origin {
+		t.Errorf("SendMessage Origin = %q, want %q", send.Origin, generate.Origin)
+	}
+}
+
+func TestGetKiroEndpointConfigs_SendMessageFlag(t *testing.T) {
+	if got := len(getKiroEndpointConfigs(&cliproxyauth.Auth{Metadata: map[string]any{}})); got != 2 {
+		t.Fatalf("expected 2 endpoint configs without the flag, got %d", got)
+	}
+
+	auth := &cliproxyauth.Auth{
+		Metadata:   map[string]any{"preferred_endpoint": "sendmessage"},
+		Attributes: map[string]string{"send_message": "true"},
+	}
+	configs := getKiroEndpointConfigs(auth)
+	if len(configs) != 3 {
+		t.Fatalf("expected 3 endpoint configs with the flag, got %d", len(configs))
+	}
+	if configs[0].Name != "CodeWhispererSendMessage" {
+		t.Errorf("first endpoint Name = %q, want %q", configs[0].Name, "CodeWhispererSendMessage")
+	}
+}
+
+// encodeKiroEventStreamFrame builds an AWS event-stream frame with string headers.
+// CRCs are left zero because the parser does not validate them.
+func encodeKiroEventStreamFrame(headers [][2]string, payload []byte) []byte {
+	var headerBytes bytes.Buffer
+	for _, h := range headers {
+		headerBytes.WriteByte(byte(len(h[0])))
+		headerBytes.WriteString(h[0])
+		headerBytes.WriteByte(7)
+		_ = binary.Write(&headerBytes, binary.BigEndian, uint16(len(h[1])))
+		headerBytes.WriteString(h[1])
+	}
+	total := 12 + headerBytes.Len() + len(payload) + 4
+	var frame bytes.Buffer
+	_ = binary.Write(&frame, binary.BigEndian, uint32(total))
+	_ = binary.Write(&frame, binary.BigEndian, uint32(headerBytes.Len()))
+	_ = binary.Write(&frame, binary.BigEndian, uint32(0))
+	frame.Write(headerBytes.Bytes())
+	frame.Write(payload)
+	_ = binary.Write(&frame, binary.BigEndian, uint32(0))
+	return frame.Bytes()
+}
+
+func TestParseEventStream_ExceptionFrame(t *testing.T) {
+	tests := []struct {
+		name        string
+		frame       []byte
+		wantCode    string
+		wantMessage string
+		wantStatus  int
+	}{
+		{
+			name: "exception",
+			frame: encodeKiroEventStreamFrame([][2]string{
+				{":message-type", "exception"},
+				{":exception-type", "ThrottlingException"},
+				{":content-type", "application/json"},
+			}, []byte(`{"message":"Too many requests, please wait"}`)),
+			wantCode:    "ThrottlingException",
+			wantMessage: "Too many requests, please wait",
+			wantStatus:  http.StatusTooManyRequests,
+		},
+		{
+			name: "error",
+			frame: encodeKiroEventStreamFrame([][2]string{
+				{":message-type", "error"},
+				{":error-code", "InternalFailure"},
+				{":error-message", "upstream exploded"},
+			}, nil),
+			wantCode:    "InternalFailure",
+			wantMessage: "upstream exploded",
+			wantStatus:  http.StatusBadGateway,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stream bytes.Buffer
+			stream.Write(encodeKiroEventStreamFrame([][2]string{
+				{":message-type", "event"},
+				{":event-type", "assistantResponseEvent"},
+			}, []byte(`{"content":"partial"}`)))
+			stream.Write(tt.frame)
+
+			e := &KiroExecutor{}
+			_, _, _, _, err := e.parseEventStream(&stream)
+
+			var streamErr *KiroStreamError
+			if !errors.As(err, &streamErr) {
+				t.Fatalf("parseEventStream error = %v, want *KiroStreamError", err)
+			}
+			if streamErr.Code != tt.wantCode || streamErr.Message != tt.wantMessage {
+				t.Fatalf("stream error = %+v, want code %q message %q", streamErr, tt.wantCode, tt.wantMessage)
+			}
+			if got := streamErr.StatusCode(); got != tt.wantStatus {
+				t.Fatalf("StatusCode() = %d, want %d", got, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestNewKiroHTTPClientWithPoolingAppliesProviderHTTP(t *testing.T) {
+	cfg := &config.Config{ProviderHTTP: map[string]config.ProviderHTTPConfig{
+		"kiro": {ResponseHeaderTimeout: 7 * time.Second, MaxIdleConns: 3},
+	}}
+	client := newKiroHTTPClientWithPooling(context.Background(), cfg, &cliproxyauth.Auth{Provider: "kiro"}, 0)
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("transport type = %T, want *http.Transport", client.Transport)
+	}
+	if transport.ResponseHeaderTimeout != 7*time.Second || transport.MaxIdleConns != 3 {
+		t.Fatalf("transport settings = %v/%d, want the kiro provider-http config", transport.ResponseHeaderTimeout, transport.MaxIdleConns)
+	}
+	if transport.MaxIdleConnsPerHost != 20 {
+		t.Fatalf("MaxIdleConnsPerHost = %d, want the pooled default 20", transport.MaxIdleConnsPerHost)
+	}
+
+	if got := newKiroHTTPClientWithPooling(context.Background(), &config.Config{}, nil, 0); got != getKiroPooledHTTPClient() {
+		t.Fatal("without provider-http settings the shared pooled client should be used")
+	}
+}
+
+func TestNewKiroHTTPClientWithPoolingAppliesCircuitBreaker(t *testing.T) {
+	cfg := &config.Config{ProviderHTTP: map[string]config.ProviderHTTPConfig{
+		"kiro": {CircuitBreakerThreshold: 2, CircuitBreakerTimeout: time.Minute},
+	}}
+	client := newKiroHTTPClientWithPooling(context.Background(), cfg, &cliproxyauth.Auth{Provider: "kiro"}, time.Second)
+	if _, ok := client.Transport.(*helps.CircuitBreakerTransport); !ok {
+		t.Fatalf("transport type = %T, want *helps.CircuitBreakerTransport", client.Transport)
+	}
+	if client.Timeout != time.Second {
+		t.Fatalf("Timeout = %v, want 1s", client.Timeout)
+	}
+}
+
+func TestKiroExecutorRefreshUsesOIDCClient(t *testing.T) {
+	mock := kirotest.NewMockSSOOIDCClient()
+	mock.SetRefreshTokenResponse(&kiroauth.KiroTokenData{
+		AccessToken:  "new-access-token",
+		RefreshToken: "executor-idc-refresh-rotated",
+		ExpiresAt:    time.Now().Add(time.Hour).Format(time.RFC3339),
+	}, nil)
+	auth := &cliproxyauth.Auth{ID: "kiro-idc.json", Provider: "kiro", Metadata: map[string]any{
+		"access_token":  "old-access-token",
+		"refresh_token": "executor-idc-refresh",
+		"client_id":     "client-id",
+		"client_secret": "client-secret",
+		"auth_method":   "idc",
+		"region":        "eu-west-1",
+		"start_url":     "https://org.awsapps.com/start",
+	}}
+
+	updated, err := NewKiroExecutor(&config.Config{}, WithOIDCClient(mock)).Refresh(context.Background(), auth)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if got := updated.Metadata["access_token"]; got != "new-access-token" {
+		t.Errorf("access_token = %v, want new-access-token", got)
+	}
+	calls := mock.Calls()
+	if len(calls) != 1 || calls[0].Method != kirotest.MethodRefreshIDCToken {
+		t.Fatalf("calls = %+v, want one %s", calls, kirotest.MethodRefreshIDCToken)
+	}
+}
@@ -14,7 +14,6 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 func resolveThinkingSignature(modelName, thinkingText, rawSignature string) string {
@@ -106,15 +105,15 @@ func ConvertClaudeRequestToAntigravity(modelName string, inputRawJSON []byte, _
 				}
 				partJSON := []byte(`{}`)
 				if systemPrompt != "" {
-					partJSON, _ = sjson.SetBytes(partJSON, "text", systemPrompt)
+					partJSON, _ = util.SafeSetJSON(partJSON, "text", systemPrompt)
 				}
-				systemInstructionJSON, _ = sjson.SetRawBytes(systemInstructionJSON, "parts.-1", partJSON)
+				systemInstructionJSON, _ = util.SafeSetRawJSON(systemInstructionJSON, "parts.-1", partJSON)
 				hasSystemInstruction = true
 			}
 		}
 	} else if systemResult.Type == gjson.String {
 		systemInstructionJSON = []byte(`{"role":"user","parts":[{"text":""}]}`)
-		systemInstructionJSON, _ = sjson.SetBytes(systemInstructionJSON, "parts.0.text", systemResult.String())
+		systemInstructionJSON, _ = util.SafeSetJSON(systemInstructionJSON, "parts.0.text", systemResult.String())
 		hasSystemInstruction = true
 	}
 
@@ -142,7 +141,7 @@ func ConvertClaudeRequestToAntigravity(modelName string, inputRawJSON []byte, _
 				role = "model"
 			}
 			clientContentJSON := []byte(`{"role":"","parts":[]}`)
-			clientContentJSON, _ = sjson.SetBytes(clientContentJSON, "role", role)
+			clientContentJSON, _ = util.SafeSetJSON(clientContentJSON, "role", role)
 			contentsResult := messageResult.Get("content")
 			if contentsResult.IsArray() {
 				contentResults := contentsResult.Array()
@@ -183,12 +182,12 @@ func ConvertClaudeRequestToAntigravity(modelName string, inputRawJSON []byte, _
 
 						// Valid signature with content, send as thought block.
 						partJSON := []byte(`{}`)
-						partJSON, _ = sjson.SetBytes(partJSON, "thought", true)
-						partJSON, _ = sjson.SetBytes(partJSON, "text", thinkingText)
+						partJSON, _ = util.SafeSetJSON(partJSON, "thought", true)
+						partJSON, _ = util.SafeSetJSON(partJSON, "text", thinkingText)
 						if signature != "" {
-							partJSON, _ = sjson.SetBytes(partJSON, "thoughtSignature", signature)
+							partJSON, _ = util.SafeSetJSON(partJSON, "thoughtSignature", signature)
 						}
-						clientContentJSON, _ = sjson.SetRawBytes(clientContentJSON, "parts.-1", partJSON)
+						clientContentJSON, _ = util.SafeSetRawJSON(clientContentJSON, "parts.-1", partJSON)
 					} else if contentTypeResult.Type == gjson.String && contentTypeResult.String() == "text" {
 						prompt := contentResult.Get("text").String()
 						// Skip empty text parts to avoid Gemini API error:
@@ -197,8 +196,8 @@ func ConvertClaudeRequestToAntigravity(modelName string, inputRawJSON []byte, _
 							continue
 						}
 						partJSON := []byte(`{}`)
-						partJSON, _ = sjson.SetBytes(partJSON, "text", prompt)
-						clientContentJSON, _ = sjson.SetRawBytes(clientContentJSON, "parts.-1", partJSON)
+						partJSON, _ = util.SafeSetJSON(partJSON, "text", prompt)
+						clientContentJSON, _ = util.SafeSetRawJSON(clientContentJSON, "parts.-1", partJSON)
 					} else if contentTypeResult.Type == gjson.String && contentTypeResult.String() == "tool_use" {
 						// NOTE: Do NOT inject dummy thinking blocks here.
 						// Antigravity API validates signatures, so dummy values are rejected.
@@ -231,18 +230,18 @@ func ConvertClaudeRequestToAntigravity(modelName string, inputRawJSON []byte, _
 							// and also works for Claude through Antigravity API
 							const skipSentinel = "skip_thought_signature_validator"
 							if hasResolvedThinkingSignature(modelName, currentMessageThinkingSignature) {
-								partJSON, _ = sjson.SetBytes(partJSON, "thoughtSignature", currentMessageThinkingSignature)
+								partJSON, _ = util.SafeSetJSON(partJSON, "thoughtSignature", currentMessageThinkingSignature)
 							} else {
 								// No valid signature - use skip sentinel to bypass validation
-								partJSON, _ = sjson.SetBytes(partJSON, "thoughtSignature", skipSentinel)
+								partJSON, _ = util.SafeSetJSON(partJSON, "thoughtSignature", skipSentinel)
 							}
 
 							if functionID != "" {
-								partJSON, _ = sjson.SetBytes(partJSON, "functionCall.id", functionID)
+								partJSON, _ = util.SafeSetJSON(partJSON, "functionCall.id", functionID)
 							}
-							partJSON, _ = sjson.SetBytes(partJSON, "functionCall.name", functionName)
-							partJSON, _ = sjson.SetRawBytes(partJSON, "functionCall.args", []byte(argsRaw))
-							clientContentJSON, _ = sjson.SetRawBytes(clientContentJSON, "parts.-1", partJSON)
+							partJSON, _ = util.SafeSetJSON(partJSON, "functionCall.name", functionName)
+							partJSON, _ = util.SafeSetRawJSON(partJSON, "functionCall.args", []byte(argsRaw))
+							clientContentJSON, _ = util.SafeSetRawJSON(clientContentJSON, "parts.-1", partJSON)
 						}
 					} else if contentTypeResult.Type == gjson.String && contentTypeResult.String() == "tool_result" {
 						toolCallID := contentResult.Get("tool_use_id").String()
@@ -264,13 +263,13 @@ func ConvertClaudeRequestToAntigravity(modelName string, inputRawJSON []byte, _
 							functionResponseResult := contentResult.Get("content")
 
 							functionResponseJSON := []byte(`{}`)
-							functionResponseJSON, _ = sjson.SetBytes(functionResponseJSON, "id", toolCallID)
-							functionResponseJSON, _ = sjson.SetBytes(functionResponseJSON, "name", util.SanitizeFunctionName(funcName))
+							functionResponseJSON, _ = util.SafeSetJSON(functionResponseJSON, "id", toolCallID)
+							functionResponseJSON, _ = util.SafeSetJSON(functionResponseJSON, "name", util.SanitizeFunctionName(funcName))
 
 							responseData := ""
 							if functionResponseResult.Type == gjson.String {
 								responseData = functionResponseResult.String()
-								functionResponseJSON, _ = sjson.SetBytes(functionResponseJSON, "response.result", responseData)
+								functionResponseJSON, _ = util.SafeSetJSON(functionResponseJSON, "response.result", responseData)
 							} else if functionResponseResult.IsArray() {
 								frResults := functionResponseResult.Array()
 								nonImageCount := 0
@@ -281,83 +280,83 @@ func ConvertClaudeRequestToAntigravity(modelName string, inputRawJSON []byte, _
 									if fr.Get("type").String() == "image" && fr.Get("source.type").String() == "base64" {
 										inlineDataJSON := []byte(`{}`)
 										if mimeType := fr.Get("source.media_type").String(); mimeType != "" {
-											inlineDataJSON, _ = sjson.SetBytes(inlineDataJSON, "mimeType", mimeType)
+											inlineDataJSON, _ = util.SafeSetJSON(inlineDataJSON, "mimeType", mimeType)
 										}
 										if data := fr.Get("source.data").String(); data != "" {
-											inlineDataJSON, _ = sjson.SetBytes(inlineDataJSON, "data", data)
+											inlineDataJSON, _ = util.SafeSetJSON(inlineDataJSON, "data", data)
 										}
 
 										imagePartJSON := []byte(`{}`)
-										imagePartJSON, _ = sjson.SetRawBytes(imagePartJSON, "inlineData", inlineDataJSON)
-										imagePartsJSON, _ = sjson.SetRawBytes(imagePartsJSON, "-1", imagePartJSON)
+										imagePartJSON, _ = util.SafeSetRawJSON(imagePartJSON, "inlineData", inlineDataJSON)
+										imagePartsJSON, _ = util.SafeSetRawJSON(imagePartsJSON, "-1", imagePartJSON)
 										continue
 									}
 
 									nonImageCount++
 									lastNonImageRaw = fr.Raw
-									filteredJSON, _ = sjson.SetRawBytes(filteredJSON, "-1", []byte(fr.Raw))
+									filteredJSON, _ = util.SafeSetRawJSON(filteredJSON, "-1", []byte(fr.Raw))
 								}
 
 								if nonImageCount == 1 {
-									functionResponseJSON, _ = sjson.SetRawBytes(functionResponseJSON, "response.result", []byte(lastNonImageRaw))
+									functionResponseJSON, _ = util.SafeSetRawJSON(functionResponseJSON, "response.result", []byte(lastNonImageRaw))
 								} else if nonImageCount > 1 {
-									functionResponseJSON, _ = sjson.SetRawBytes(functionResponseJSON, "response.result", filteredJSON)
+									functionResponseJSON, _ = util.SafeSetRawJSON(functionResponseJSON, "response.result", filteredJSON)
 								} else {
-									functionResponseJSON, _ = sjson.SetBytes(functionResponseJSON, "response.result", "")
+									functionResponseJSON, _ = util.SafeSetJSON(functionResponseJSON, "response.result", "")
 								}
 
 								// Place image data inside functionResponse.parts as inlineData
 								// instead of as sibling parts in the outer content, to avoid
 								// base64 data bloating the text context.
 								if gjson.GetBytes(imagePartsJSON, "#").Int() > 0 {
-									functionResponseJSON, _ = sjson.SetRawBytes(functionResponseJSON, "parts", imagePartsJSON)
+									functionResponseJSON, _ = util.SafeSetRawJSON(functionResponseJSON, "parts", imagePartsJSON)
 								}
 
 							} else if functionResponseResult.IsObject() {
 								if functionResponseResult.Get("type").String() == "image" && functionResponseResult.Get("source.type").String() == "base64" {
 									inlineDataJSON := []byte(`{}`)
 									if mimeType := functionResponseResult.Get("source.media_type").String(); mimeType != "" {
-										inlineDataJSON, _ = sjson.SetBytes(inlineDataJSON, "mimeType", mimeType)
+										inlineDataJSON, _ = util.SafeSetJSON(inlineDataJSON, "mimeType", mimeType)
 									}
 									if data := functionResponseResult.Get("source.data").String(); data != "" {
-										inlineDataJSON, _ = sjson.SetBytes(inlineDataJSON, "data", data)
+										inlineDataJSON, _ = util.SafeSetJSON(inlineDataJSON, "data", data)
 									}
 
 									imagePartJSON := []byte(`{}`)
-									imagePartJSON, _ = sjson.SetRawBytes(imagePartJSON, "inlineData", inlineDataJSON)
+									imagePartJSON, _ = util.SafeSetRawJSON(imagePartJSON, "inlineData", inlineDataJSON)
 									imagePartsJSON := []byte(`[]`)
-									imagePartsJSON, _ = sjson.SetRawBytes(imagePartsJSON, "-1", imagePartJSON)
-									functionResponseJSON, _ = sjson.SetRawBytes(functionResponseJSON, "parts", imagePartsJSON)
-									functionResponseJSON, _ = sjson.SetBytes(functionResponseJSON, "response.result", "")
+									imagePartsJSON, _ = util.SafeSetRawJSON(imagePartsJSON, "-1", imagePartJSON)
+									functionResponseJSON, _ = util.SafeSetRawJSON(functionResponseJSON, "parts", imagePartsJSON)
+									functionResponseJSON, _ = util.SafeSetJSON(functionResponseJSON, "response.result", "")
 								} else {
-									functionResponseJSON, _ = sjson.SetRawBytes(functionResponseJSON, "response.result", []byte(functionResponseResult.Raw))
+									functionResponseJSON, _ = util.SafeSetRawJSON(functionResponseJSON, "response.result", []byte(functionResponseResult.Raw))
 								}
 							} else if functionResponseResult.Raw != "" {
-								functionResponseJSON, _ = sjson.SetRawBytes(functionResponseJSON, "response.result", []byte(functionResponseResult.Raw))
+								functionResponseJSON, _ = util.SafeSetRawJSON(functionResponseJSON, "response.result", []byte(functionResponseResult.Raw))
 							} else {
 								// Content field is missing entirely — .Raw is empty which
-								// causes sjson.SetRaw to produce invalid JSON (e.g. "result":}).
-								functionResponseJSON, _ = sjson.SetBytes(functionResponseJSON, "response.result", "")
+								// causes a raw set to produce invalid JSON (e.g. "result":}).
+								functionResponseJSON, _ = util.SafeSetJSON(functionResponseJSON, "response.result", "")
 							}
 
 							partJSON := []byte(`{}`)
-							partJSON, _ = sjson.SetRawBytes(partJSON, "functionResponse", functionResponseJSON)
-							clientContentJSON, _ = sjson.SetRawBytes(clientContentJSON, "parts.-1", partJSON)
+							partJSON, _ = util.SafeSetRawJSON(partJSON, "functionResponse", functionResponseJSON)
+							clientContentJSON, _ = util.SafeSetRawJSON(clientContentJSON, "parts.-1", partJSON)
 						}
 					} else if contentTypeResult.Type == gjson.String && contentTypeResult.String() == "image" {
 						sourceResult := contentResult.Get("source")
 						if sourceResult.Get("type").String() == "base64" {
 							inlineDataJSON := []byte(`{}`)
 							if mimeType := sourceResult.Get("media_type").String(); mimeType != "" {
-								inlineDataJSON, _ = sjson.SetBytes(inlineDataJSON, "mimeType", mimeType)
+								inlineDataJSON, _ = util.SafeSetJSON(inlineDataJSON, "mimeType", mimeType)
 							}
 							if data := sourceResult.Get("data").String(); data != "" {
-								inlineDataJSON, _ = sjson.SetBytes(inlineDataJSON, "data", data)
+								inlineDataJSON, _ = util.SafeSetJSON(inlineDataJSON, "data", data)
 							}
 
 							partJSON := []byte(`{}`)
-							partJSON, _ = sjson.SetRawBytes(partJSON, "inlineData", inlineDataJSON)
-							clientContentJSON, _ = sjson.SetRawBytes(clientContentJSON, "parts.-1", partJSON)
+							partJSON, _ = util.SafeSetRawJSON(partJSON, "inlineData", inlineDataJSON)
+							clientContentJSON, _ = util.SafeSetRawJSON(clientContentJSON, "parts.-1", partJSON)
 						}
 					}
 				}
@@ -400,7 +399,7 @@ func ConvertClaudeRequestToAntigravity(modelName string, inputRawJSON []byte, _
 							for _, p := range functionCallParts {
 								newParts = append(newParts, p.Value())
 							}
-							clientContentJSON, _ = sjson.SetBytes(clientContentJSON, "parts", newParts)
+							clientContentJSON, _ = util.SafeSetJSON(clientContentJSON, "parts", newParts)
 						}
 					}
 				}
@@ -412,16 +411,16 @@ func ConvertClaudeRequestToAntigravity(modelName string, inputRawJSON []byte, _
 					continue
 				}
 
-				contentsJSON, _ = sjson.SetRawBytes(contentsJSON, "-1", clientContentJSON)
+				contentsJSON, _ = util.SafeSetRawJSON(contentsJSON, "-1", clientContentJSON)
 				hasContents = true
 			} else if contentsResult.Type == gjson.String {
 				prompt := contentsResult.String()
 				partJSON := []byte(`{}`)
 				if prompt != "" {
-					partJSON, _ = sjson.SetBytes(partJSON, "text", prompt)
+					partJSON, _ = util.SafeSetJSON(partJSON, "text", prompt)
 				}
-				clientContentJSON, _ = sjson.SetRawBytes(clientContentJSON, "parts.-1", partJSON)
-				contentsJSON, _ = sjson.SetRawBytes(contentsJSON, "-1", clientContentJSON)
+				clientContentJSON, _ = util.SafeSetRawJSON(clientContentJSON, "parts.-1", partJSON)
+				contentsJSON, _ = util.SafeSetRawJSON(contentsJSON, "-1", clientContentJSON)
 				hasContents = true
 			}
 		}
@@ -441,16 +440,16 @@ func ConvertClaudeRequestToAntigravity(modelName string, inputRawJSON []byte, _
 			if inputSchemaResult.Exists() && inputSchemaResult.IsObject() {
 				// Sanitize the input schema for Antigravity API compatibility
 				inputSchema := util.CleanJSONSchemaForAntigravity(inputSchemaResult.Raw)
-				tool, _ := sjson.DeleteBytes([]byte(toolResult.Raw), "input_schema")
-				tool, _ = sjson.SetRawBytes(tool, "parametersJsonSchema", []byte(inputSchema))
-				tool, _ = sjson.SetBytes(tool, "name", util.SanitizeFunctionName(gjson.GetBytes(tool, "name").String()))
+				tool, _ := util.SafeDeleteJSON([]byte(toolResult.Raw), "input_schema")
+				tool, _ = util.SafeSetRawJSON(tool, "parametersJsonSchema", []byte(inputSchema))
+				tool, _ = util.SafeSetJSON(tool, "name", util.SanitizeFunctionName(gjson.GetBytes(tool, "name").String()))
 				for toolKey := range gjson.ParseBytes(tool).Map() {
 					if util.InArray(allowedToolKeys, toolKey) {
 						continue
 					}
-					tool, _ = sjson.DeleteBytes(tool, toolKey)
+					tool, _ = util.SafeDeleteJSON(tool, toolKey)
 				}
-				toolsJSON, _ = sjson.SetRawBytes(toolsJSON, "0.functionDeclarations.-1", tool)
+				toolsJSON, _ = util.SafeSetRawJSON(toolsJSON, "0.functionDeclarations.-1", tool)
 				toolDeclCount++
 			}
 		}
@@ -458,7 +457,7 @@ func ConvertClaudeRequestToAntigravity(modelName string, inputRawJSON []byte, _
 
 	// Build output Gemini CLI request JSON
 	out := []byte(`{"model":"","request":{"contents":[]}}`)
-	out, _ = sjson.SetBytes(out, "model", modelName)
+	out, _ = util.SafeSetJSON(out, "model", modelName)
 
 	// Inject interleaved thinking hint when both tools and thinking are active
 	hasTools := toolDeclCount > 0
@@ -473,26 +472,26 @@ func ConvertClaudeRequestToAntigravity(modelName string, inputRawJSON []byte, _
 		if hasSystemInstruction {
 			// Append hint as a new part to existing system instruction
 			hintPart := []byte(`{"text":""}`)
-			hintPart, _ = sjson.SetBytes(hintPart, "text", interleavedHint)
-			systemInstructionJSON, _ = sjson.SetRawBytes(systemInstructionJSON, "parts.-1", hintPart)
+			hintPart, _ = util.SafeSetJSON(hintPart, "text", interleavedHint)
+			systemInstructionJSON, _ = util.SafeSetRawJSON(systemInstructionJSON, "parts.-1", hintPart)
 		} else {
 			// Create new system instruction with hint
 			systemInstructionJSON = []byte(`{"role":"user","parts":[]}`)
 			hintPart := []byte(`{"text":""}`)
-			hintPart, _ = sjson.SetBytes(hintPart, "text", interleavedHint)
-			systemInstructionJSON, _ = sjson.SetRawBytes(systemInstructionJSON, "parts.-1", hintPart)
+			hintPart, _ = util.SafeSetJSON(hintPart, "text", interleavedHint)
+			systemInstructionJSON, _ = util.SafeSetRawJSON(systemInstructionJSON, "parts.-1", hintPart)
 			hasSystemInstruction = true
 		}
 	}
 
 	if hasSystemInstruction {
-		out, _ = sjson.SetRawBytes(out, "request.systemInstruction", systemInstructionJSON)
+		out, _ = util.SafeSetRawJSON(out, "request.systemInstruction", systemInstructionJSON)
 	}
 	if hasContents {
-		out, _ = sjson.SetRawBytes(out, "request.contents", contentsJSON)
+		out, _ = util.SafeSetRawJSON(out, "request.contents", contentsJSON)
 	}
 	if toolDeclCount > 0 {
-		out, _ = sjson.SetRawBytes(out, "request.tools", toolsJSON)
+		out, _ = util.SafeSetRawJSON(out, "request.tools", toolsJSON)
 	}
 
 	// tool_choice
@@ -509,15 +508,15 @@ func ConvertClaudeRequestToAntigravity(modelName string, inputRawJSON []byte, _
 
 		switch toolChoiceType {
 		case "auto":
-			out, _ = sjson.SetBytes(out, "request.toolConfig.functionCallingConfig.mode", "AUTO")
+			out, _ = util.SafeSetJSON(out, "request.toolConfig.functionCallingConfig.mode", "AUTO")
 		case "none":
-			out, _ = sjson.SetBytes(out, "request.toolConfig.functionCallingConfig.mode", "NONE")
+			out, _ = util.SafeSetJSON(out, "request.toolConfig.functionCallingConfig.mode", "NONE")
 		case "any":
-			out, _ = sjson.SetBytes(out, "request.toolConfig.functionCallingConfig.mode", "ANY")
+			out, _ = util.SafeSetJSON(out, "request.toolConfig.functionCallingConfig.mode", "ANY")
 		case "tool":
-			out, _ = sjson.SetBytes(out, "request.toolConfig.functionCallingConfig.mode", "ANY")
+			out, _ = util.SafeSetJSON(out, "request.toolConfig.functionCallingConfig.mode", "ANY")
 			if toolChoiceName != "" {
-				out, _ = sjson.SetBytes(out, "request.toolConfig.functionCallingConfig.allowedFunctionNames", []string{util.SanitizeFunctionName(toolChoiceName)})
+				out, _ = util.SafeSetJSON(out, "request.toolConfig.functionCallingConfig.allowedFunctionNames", []string{util.SanitizeFunctionName(toolChoiceName)})
 			}
 		}
 	}
@@ -528,8 +527,8 @@ func ConvertClaudeRequestToAntigravity(modelName string, inputRawJSON []byte, _
 		case "enabled":
 			if b := t.Get("budget_tokens"); b.Exists() && b.Type == gjson.Number {
 				budget := int(b.Int())
-				out, _ = sjson.SetBytes(out, "request.generationConfig.thinkingConfig.thinkingBudget", budget)
-				out, _ = sjson.SetBytes(out, "request.generationConfig.thinkingConfig.includeThoughts", true)
+				out, _ = util.SafeSetJSON(out, "request.generationConfig.thinkingConfig.thinkingBudget", budget)
+				out, _ = util.SafeSetJSON(out, "request.generationConfig.thinkingConfig.includeThoughts", true)
 			}
 		case "adaptive", "auto":
 			// For adaptive thinking:
@@ -541,24 +540,24 @@ func ConvertClaudeRequestToAntigravity(modelName string, inputRawJSON []byte, _
 				effort = strings.ToLower(strings.TrimSpace(v.String()))
 			}
 			if effort != "" {
-				out, _ = sjson.SetBytes(out, "request.generationConfig.thinkingConfig.thinkingLevel", effort)
+				out, _ = util.SafeSetJSON(out, "request.generationConfig.thinkingConfig.thinkingLevel", effort)
 			} else {
-				out, _ = sjson.SetBytes(out, "request.generationConfig.thinkingConfig.thinkingLevel", "high")
+				out, _ = util.SafeSetJSON(out, "request.generationConfig.thinkingConfig.thinkingLevel", "high")
 			}
-			out, _ = sjson.SetBytes(out, "request.generationConfig.thinkingConfig.includeThoughts", true)
+			out, _ = util.SafeSetJSON(out, "request.generationConfig.thinkingConfig.includeThoughts", true)
 		}
 	}
 	if v := gjson.GetBytes(rawJSON, "temperature"); v.Exists() && v.Type == gjson.Number {
-		out, _ = sjson.SetBytes(out, "request.generationConfig.temperature", v.Num)
+		out, _ = util.SafeSetJSON(out, "request.generationConfig.temperature", v.Num)
 	}
 	if v := gjson.GetBytes(rawJSON, "top_p"); v.Exists() && v.Type == gjson.Number {
-		out, _ = sjson.SetBytes(out, "request.generationConfig.topP", v.Num)
+		out, _ = util.SafeSetJSON(out, "request.generationConfig.topP", v.Num)
 	}
 	if v := gjson.GetBytes(rawJSON, "top_k"); v.Exists() && v.Type == gjson.Number {
-		out, _ = sjson.SetBytes(out, "request.generationConfig.topK", v.Num)
+		out, _ = util.SafeSetJSON(out, "request.generationConfig.topK", v.Num)
 	}
 	if v := gjson.GetBytes(rawJSON, "max_tokens"); v.Exists() && v.Type == gjson.Number {
-		out, _ = sjson.SetBytes(out, "request.generationConfig.maxOutputTokens", v.Num)
+		out, _ = util.SafeSetJSON(out, "request.generationConfig.maxOutputTokens", v.Num)
 	}
 
 	out = common.AttachDefaultSafetySettings(out, "request.safetySettings")
@@ -54,8 +54,8 @@ import (
 	"unicode/utf8"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 	"google.golang.org/protobuf/encoding/protowire"
 )
 
@@ -100,9 +100,9 @@ func StripEmptySignatureThinkingBlocks(payload []byte) []byte {
 		if stripped {
 			modified = true
 			if len(kept) == 0 {
-				payload, _ = sjson.SetRawBytes(payload, fmt.Sprintf("messages.%d.content", i), []byte("[]"))
+				payload, _ = util.SafeSetRawJSON(payload, fmt.Sprintf("messages.%d.content", i), []byte("[]"))
 			} else {
-				payload, _ = sjson.SetRawBytes(payload, fmt.Sprintf("messages.%d.content", i), []byte("["+strings.Join(kept, ",")+"]"))
+				payload, _ = util.SafeSetRawJSON(payload, fmt.Sprintf("messages.%d.content", i), []byte("["+strings.Join(kept, ",")+"]"))
 			}
 		}
 	}
@@ -13,9 +13,22 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
+// DefaultClaudeSystemInstruction is injected as request.systemInstruction for Claude
+// models when the client sends no system instruction at all. Empty disables injection.
+// The service sets it from translator.claude-system-instruction before serving.
+var DefaultClaudeSystemInstruction string
+
+// UnsupportedGenerationConfigKeys lists, per model family, the request.generationConfig
+// keys the Antigravity backend rejects for that family and that are therefore removed
+// before the request is sent. Families are matched by substring of the model name;
+// models of other families keep generationConfig intact. Entries from
+// translator.unsupported-generation-config-keys replace the built-in ones at startup.
+var UnsupportedGenerationConfigKeys = map[string][]string{
+	"claude": {"topK", "top_k"},
+}
+
 // ConvertGeminiRequestToAntigravity parses and transforms a Gemini CLI API request into Gemini API format.
 // It extracts the model name, system instruction, message contents, and tool declarations
 // from the raw JSON request and returns them in the format expected by the Gemini API.
@@ -24,6 +37,9 @@ import (
 // 2. Restructures the JSON to match Gemini API format
 // 3. Converts system instructions to the expected format
 // 4. Fixes CLI tool response format and grouping
+// 5. Injects DefaultClaudeSystemInstruction for Claude models without a system instruction
+// 6. Drops a leading user turn that merely repeats the system instruction
+// 7. Prunes generationConfig keys listed in UnsupportedGenerationConfigKeys for the model family
 //
 // Parameters:
 //   - modelName: The name of the model to use for the request (unused in current implementation)
@@ -35,10 +51,10 @@ import (
 func ConvertGeminiRequestToAntigravity(modelName string, inputRawJSON []byte, _ bool) []byte {
 	rawJSON := inputRawJSON
 	template := `{"project":"","request":{},"model":""}`
-	templateBytes, _ := sjson.SetRawBytes([]byte(template), "request", rawJSON)
-	templateBytes, _ = sjson.SetBytes(templateBytes, "model", modelName)
+	templateBytes, _ := util.SafeSetRawJSON([]byte(template), "request", rawJSON)
+	templateBytes, _ = util.SafeSetJSON(templateBytes, "model", modelName)
+	templateBytes, _ = util.SafeDeleteJSON(templateBytes, "request.model")
 	template = string(templateBytes)
-	template, _ = sjson.Delete(template, "request.model")
 
 	template, errFixCLIToolResponse := fixCLIToolResponse(template)
 	if errFixCLIToolResponse != nil {
@@ -47,10 +63,11 @@ func ConvertGeminiRequestToAntigravity(modelName string, inputRawJSON []byte, _
 
 	systemInstructionResult := gjson.Get(template, "request.system_instruction")
 	if systemInstructionResult.Exists() {
-		templateBytes, _ = sjson.SetRawBytes([]byte(template), "request.systemInstruction", []byte(systemInstructionResult.Raw))
+		templateBytes, _ = util.SafeSetRawJSON([]byte(template), "request.systemInstruction", []byte(systemInstructionResult.Raw))
+		templateBytes, _ = util.SafeDeleteJSON(templateBytes, "request.system_instruction")
 		template = string(templateBytes)
-		template, _ = sjson.Delete(template, "request.system_instruction")
 	}
+	template = injectDefaultSystemInstruction(template, modelName)
 	rawJSON = []byte(template)
 
 	// Normalize roles in request.contents: default to valid values if missing/invalid
@@ -71,7 +88,7 @@ func ConvertGeminiRequestToAntigravity(modelName string, inputRawJSON []byte, _
 					newRole = "user"
 				}
 				path := fmt.Sprintf("request.contents.%d.role", idx)
-				rawJSON, _ = sjson.SetBytes(rawJSON, path, newRole)
+				rawJSON, _ = util.SafeSetJSON(rawJSON, path, newRole)
 				role = newRole
 			}
 			prevRole = role
@@ -80,23 +97,10 @@ func ConvertGeminiRequestToAntigravity(modelName string, inputRawJSON []byte, _
 		})
 	}
 
-	toolsResult := gjson.GetBytes(rawJSON, "request.tools")
-	if toolsResult.Exists() && toolsResult.IsArray() {
-		toolResults := toolsResult.Array()
-		for i := 0; i < len(toolResults); i++ {
-			functionDeclarationsResult := gjson.GetBytes(rawJSON, fmt.Sprintf("request.tools.%d.function_declarations", i))
-			if functionDeclarationsResult.Exists() && functionDeclarationsResult.IsArray() {
-				functionDeclarationsResults := functionDeclarationsResult.Array()
-				for j := 0; j < len(functionDeclarationsResults); j++ {
-					parametersResult := gjson.GetBytes(rawJSON, fmt.Sprintf("request.tools.%d.function_declarations.%d.parameters", i, j))
-					if parametersResult.Exists() {
-						strJson, _ := util.RenameKey(string(rawJSON), fmt.Sprintf("request.tools.%d.function_declarations.%d.parameters", i, j), fmt.Sprintf("request.tools.%d.function_declarations.%d.parametersJsonSchema", i, j))
-						rawJSON = []byte(strJson)
-					}
-				}
-			}
-		}
-	}
+	rawJSON = dropDuplicateSystemTurn(rawJSON)
+	rawJSON = pruneUnsupportedGenerationConfig(rawJSON, modelName)
+
+	rawJSON = common.ApplySchemaRenames(rawJSON, common.SchemaRenameGeminiToAntigravity, "request.")
 
 	// Gemini-specific handling for non-Claude models:
 	// - Add skip_thought_signature_validator to functionCall parts so upstream can bypass signature validation.
@@ -117,7 +121,7 @@ func ConvertGeminiRequestToAntigravity(modelName string, inputRawJSON []byte, _
 					if part.Get("functionCall").Exists() {
 						existingSig := part.Get("thoughtSignature").String()
 						if existingSig == "" || len(existingSig) < 50 {
-							rawJSON, _ = sjson.SetBytes(rawJSON, fmt.Sprintf("request.contents.%d.parts.%d.thoughtSignature", contentIdx.Int(), partIdx.Int()), skipSentinel)
+							rawJSON, _ = util.SafeSetJSON(rawJSON, fmt.Sprintf("request.contents.%d.parts.%d.thoughtSignature", contentIdx.Int(), partIdx.Int()), skipSentinel)
 						}
 					}
 					return true
@@ -126,7 +130,7 @@ func ConvertGeminiRequestToAntigravity(modelName string, inputRawJSON []byte, _
 				// Add skip_thought_signature_validator sentinel to thinking blocks in reverse order to preserve indices
 				for i := len(thinkingIndicesToSkipSignature) - 1; i >= 0; i-- {
 					idx := thinkingIndicesToSkipSignature[i]
-					rawJSON, _ = sjson.SetBytes(rawJSON, fmt.Sprintf("request.contents.%d.parts.%d.thoughtSignature", contentIdx.Int(), idx), skipSentinel)
+					rawJSON, _ = util.SafeSetJSON(rawJSON, fmt.Sprintf("request.contents.%d.parts.%d.thoughtSignature", contentIdx.Int(), idx), skipSentinel)
 				}
 			}
 			return true
@@ -136,6 +140,101 @@ func ConvertGeminiRequestToAntigravity(modelName string, inputRawJSON []byte, _
 	return common.AttachDefaultSafetySettings(rawJSON, "request.safetySettings")
 }
 
+// injectDefaultSystemInstruction sets DefaultClaudeSystemInstruction as the system
+// instruction of a Claude request that has none. A client-provided instruction,
+// even an empty one, is never replaced.
+func injectDefaultSystemInstruction(template, modelName string) string {
+	if DefaultClaudeSystemInstruction == "" || !strings.Contains(modelName, "claude") {
+		return template
+	}
+	if gjson.Get(template, "request.systemInstruction").Exists() {
+		return template
+	}
+	systemInstruction, _ := util.SafeSetJSON([]byte(`{"role":"user","parts":[{"text":""}]}`), "parts.0.text", DefaultClaudeSystemInstruction)
+	out, _ := util.SafeSetRawJSON([]byte(template), "request.systemInstruction", systemInstruction)
+	return string(out)
+}
+
+// pruneUnsupportedGenerationConfig deletes the request.generationConfig keys that
+// UnsupportedGenerationConfigKeys marks as unsupported for modelName's family.
+func pruneUnsupportedGenerationConfig(rawJSON []byte, modelName string) []byte {
+	if !gjson.GetBytes(rawJSON, "request.generationConfig").IsObject() {
+		return rawJSON
+	}
+	lowerModel := strings.ToLower(modelName)
+	for family, keys := range UnsupportedGenerationConfigKeys {
+		if family == "" || !strings.Contains(lowerModel, family) {
+			continue
+		}
+		for _, key := range keys {
+			path := "request.generationConfig." + key
+			if !gjson.GetBytes(rawJSON, path).Exists() {
+				continue
+			}
+			out, errDelete := util.SafeDeleteJSON(rawJSON, path)
+			if errDelete != nil {
+				log.Debugf("antigravity gemini: failed to prune generationConfig.%s: %v", key, errDelete)
+				continue
+			}
+			rawJSON = out
+		}
+	}
+	return rawJSON
+}
+
+// dropDuplicateSystemTurn removes the first user message when its text exactly matches
+// the system instruction. Some clients send the system prompt both as systemInstruction
+// and as the opening user turn, which duplicates the context sent upstream.
+func dropDuplicateSystemTurn(rawJSON []byte) []byte {
+	systemText := joinedPartsText(gjson.GetBytes(rawJSON, "request.systemInstruction.parts"))
+	if systemText == "" {
+		return rawJSON
+	}
+
+	firstUserIdx := -1
+	var firstUser gjson.Result
+	gjson.GetBytes(rawJSON, "request.contents").ForEach(func(idx, content gjson.Result) bool {
+		if content.Get("role").String() == "user" {
+			firstUserIdx = int(idx.Int())
+			firstUser = content
+			return false
+		}
+		return true
+	})
+	if firstUserIdx < 0 || joinedPartsText(firstUser.Get("parts")) != systemText {
+		return rawJSON
+	}
+
+	out, errDelete := util.SafeDeleteJSON(rawJSON, fmt.Sprintf("request.contents.%d", firstUserIdx))
+	if errDelete != nil {
+		log.Debugf("antigravity gemini: failed to drop duplicate system turn: %v", errDelete)
+		return rawJSON
+	}
+	return out
+}
+
+// joinedPartsText concatenates the text of parts, returning "" when any part is not plain text.
+func joinedPartsText(parts gjson.Result) string {
+	if !parts.IsArray() {
+		return ""
+	}
+	texts := make([]string, 0, len(parts.Array()))
+	plainText := true
+	parts.ForEach(func(_, part gjson.Result) bool {
+		text := part.Get("text")
+		if text.Type != gjson.String || part.Get("thought").Bool() {
+			plainText = false
+			return false
+		}
+		texts = append(texts, text.String())
+		return true
+	})
+	if !plainText {
+		return ""
+	}
+	return strings.TrimSpace(strings.Join(texts, "\n"))
+}
+
 // FunctionCallGroup represents a group of function calls and their responses
 type FunctionCallGroup struct {
 	ResponsesNeeded int
@@ -150,7 +249,7 @@ func parseFunctionResponseRaw(response gjson.Result, fallbackName string) string
 		raw := response.Raw
 		name := response.Get("functionResponse.name").String()
 		if strings.TrimSpace(name) == "" && fallbackName != "" {
-			updated, _ := sjson.SetBytes([]byte(raw), "functionResponse.name", fallbackName)
+			updated, _ := util.SafeSetJSON([]byte(raw), "functionResponse.name", fallbackName)
 			raw = string(updated)
 		}
 		return raw
@@ -164,10 +263,10 @@ func parseFunctionResponseRaw(response gjson.Result, fallbackName string) string
 		if strings.TrimSpace(name) == "" {
 			name = fallbackName
 		}
-		fr, _ = sjson.SetBytes(fr, "functionResponse.name", name)
-		fr, _ = sjson.SetBytes(fr, "functionResponse.response.result", funcResp.Get("response").String())
+		fr, _ = util.SafeSetJSON(fr, "functionResponse.name", name)
+		fr, _ = util.SafeSetJSON(fr, "functionResponse.response.result", funcResp.Get("response").String())
 		if id := funcResp.Get("id").String(); id != "" {
-			fr, _ = sjson.SetBytes(fr, "functionResponse.id", id)
+			fr, _ = util.SafeSetJSON(fr, "functionResponse.id", id)
 		}
 		return string(fr)
 	}
@@ -177,8 +276,8 @@ func parseFunctionResponseRaw(response gjson.Result, fallbackName string) string
 		useName = "unknown"
 	}
 	fr := []byte(`{"functionResponse":{"name":"","response":{"result":""}}}`)
-	fr, _ = sjson.SetBytes(fr, "functionResponse.name", useName)
-	fr, _ = sjson.SetBytes(fr, "functionResponse.response.result", response.String())
+	fr, _ = util.SafeSetJSON(fr, "functionResponse.name", useName)
+	fr, _ = util.SafeSetJSON(fr, "functionResponse.response.result", response.String())
 	return string(fr)
 }
 
@@ -243,12 +342,12 @@ func fixCLIToolResponse(input string) (string, error) {
 				for ri, response := range groupResponses {
 					partRaw := parseFunctionResponseRaw(response, group.CallNames[ri])
 					if partRaw != "" {
-						functionResponseContent, _ = sjson.SetRawBytes(functionResponseContent, "parts.-1", []byte(partRaw))
+						functionResponseContent, _ = util.SafeSetRawJSON(functionResponseContent, "parts.-1", []byte(partRaw))
 					}
 				}
 
 				if gjson.GetBytes(functionResponseContent, "parts.#").Int() > 0 {
-					contentsWrapper, _ = sjson.SetRawBytes(contentsWrapper, "contents.-1", functionResponseContent)
+					contentsWrapper, _ = util.SafeSetRawJSON(contentsWrapper, "contents.-1", functionResponseContent)
 				}
 			}
 
@@ -271,7 +370,7 @@ func fixCLIToolResponse(input string) (string, error) {
 					log.Warnf("failed to parse model content")
 					return true
 				}
-				contentsWrapper, _ = sjson.SetRawBytes(contentsWrapper, "contents.-1", []byte(value.Raw))
+				contentsWrapper, _ = util.SafeSetRawJSON(contentsWrapper, "contents.-1", []byte(value.Raw))
 
 				// Create a new group for tracking responses
 				group := &FunctionCallGroup{
@@ -285,7 +384,7 @@ func fixCLIToolResponse(input string) (string, error) {
 					log.Warnf("failed to parse content")
 					return true
 				}
-				contentsWrapper, _ = sjson.SetRawBytes(contentsWrapper, "contents.-1", []byte(value.Raw))
+				contentsWrapper, _ = util.SafeSetRawJSON(contentsWrapper, "contents.-1", []byte(value.Raw))
 			}
 		} else {
 			// Non-model content (user, etc.)
@@ -293,7 +392,7 @@ func fixCLIToolResponse(input string) (string, error) {
 				log.Warnf("failed to parse content")
 				return true
 			}
-			contentsWrapper, _ = sjson.SetRawBytes(contentsWrapper, "contents.-1", []byte(value.Raw))
+			contentsWrapper, _ = util.SafeSetRawJSON(contentsWrapper, "contents.-1", []byte(value.Raw))
 		}
 
 		return true
@@ -309,18 +408,18 @@ func fixCLIToolResponse(input string) (string, error) {
 			for ri, response := range groupResponses {
 				partRaw := parseFunctionResponseRaw(response, group.CallNames[ri])
 				if partRaw != "" {
-					functionResponseContent, _ = sjson.SetRawBytes(functionResponseContent, "parts.-1", []byte(partRaw))
+					functionResponseContent, _ = util.SafeSetRawJSON(functionResponseContent, "parts.-1", []byte(partRaw))
 				}
 			}
 
 			if gjson.GetBytes(functionResponseContent, "parts.#").Int() > 0 {
-				contentsWrapper, _ = sjson.SetRawBytes(contentsWrapper, "contents.-1", functionResponseContent)
+				contentsWrapper, _ = util.SafeSetRawJSON(contentsWrapper, "contents.-1", functionResponseContent)
 			}
 		}
 	}
 
 	// Update the original JSON with the new contents
-	result, _ := sjson.SetRawBytes([]byte(input), "request.contents", []byte(gjson.GetBytes(contentsWrapper, "contents").Raw))
+	result, _ := util.SafeSetRawJSON([]byte(input), "request.contents", []byte(gjson.GetBytes(contentsWrapper, "contents").Raw))
 
 	return string(result), nil
 }
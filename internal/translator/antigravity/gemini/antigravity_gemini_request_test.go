@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 	"github.com/tidwall/gjson"
 )
 
@@ -425,3 +426,219 @@ func TestFixCLIToolResponse_MultipleGroupsFIFO(t *testing.T) {
 		t.Errorf("Expected second group name 'Grep', got '%s'", name1)
 	}
 }
+
+func TestFixCLIToolResponse_DeterministicOutput(t *testing.T) {
+	// Overlapping groups with responses split across turns exercise the pending-group
+	// bookkeeping; the emitted contents must be byte-identical on every run.
+	input := `{
+		"model": "gemini-3-pro-preview",
+		"request": {
+			"contents": [
+				{"role": "user", "parts": [{"text": "inspect the repo"}]},
+				{
+					"role": "model",
+					"parts": [
+						{"functionCall": {"name": "Read", "args": {"path": "a.go"}}},
+						{"functionCall": {"name": "Grep", "args": {"pattern": "TODO"}}},
+						{"functionCall": {"name": "Glob", "args": {"pattern": "*.go"}}}
+					]
+				},
+				{
+					"role": "function",
+					"parts": [
+						{"functionResponse": {"name": "Grep", "response": {"result": "match"}}}
+					]
+				},
+				{"role": "user", "parts": [{"text": "keep going"}]},
+				{
+					"role": "function",
+					"parts": [
+						{"functionResponse": {"name": "", "response": {"result": "file content"}}},
+						{"functionResponse": {"name": "Glob", "response": {"result": "a.go b.go"}}}
+					]
+				},
+				{
+					"role": "model",
+					"parts": [
+						{"functionCall": {"name": "Read", "args": {"path": "b.go"}}}
+					]
+				},
+				{
+					"role": "function",
+					"parts": [
+						{"functionResponse": {"name": "", "response": {"result": "more content"}}}
+					]
+				}
+			]
+		}
+	}`
+
+	want, err := fixCLIToolResponse(input)
+	if err != nil {
+		t.Fatalf("fixCLIToolResponse failed: %v", err)
+	}
+	for i := 0; i < 200; i++ {
+		got, errRun := fixCLIToolResponse(input)
+		if errRun != nil {
+			t.Fatalf("run %d: fixCLIToolResponse failed: %v", i, errRun)
+		}
+		if got != want {
+			t.Fatalf("run %d: output differs from first run\nfirst: %s\ngot:   %s", i, want, got)
+		}
+	}
+}
+
+func TestConvertGeminiRequestToAntigravity_DropsUserTurnDuplicatingSystemInstruction(t *testing.T) {
+	inputJSON := []byte(`{
+		"system_instruction": {"parts": [{"text": "You are a helpful assistant."}]},
+		"contents": [
+			{"role": "user", "parts": [{"text": "You are a helpful assistant."}]},
+			{"role": "model", "parts": [{"text": "Understood."}]},
+			{"role": "user", "parts": [{"text": "You are a helpful assistant."}]},
+			{"role": "user", "parts": [{"text": "What is 2+2?"}]}
+		]
+	}`)
+
+	output := ConvertGeminiRequestToAntigravity("gemini-2.5-pro", inputJSON, false)
+
+	contents := gjson.GetBytes(output, "request.contents").Array()
+	if len(contents) != 3 {
+		t.Fatalf("Expected 3 contents after dropping duplicate, got %d: %s", len(contents), gjson.GetBytes(output, "request.contents").Raw)
+	}
+	if got := contents[0].Get("parts.0.text").String(); got != "Understood." {
+		t.Errorf("Expected first content to be the model reply, got %q", got)
+	}
+	if got := contents[1].Get("parts.0.text").String(); got != "You are a helpful assistant." {
+		t.Errorf("Expected later repeat of the system text to be preserved, got %q", got)
+	}
+	if got := contents[2].Get("parts.0.text").String(); got != "What is 2+2?" {
+		t.Errorf("Expected final user message to be preserved, got %q", got)
+	}
+	if got := gjson.GetBytes(output, "request.systemInstruction.parts.0.text").String(); got != "You are a helpful assistant." {
+		t.Errorf("Expected systemInstruction to be preserved, got %q", got)
+	}
+}
+
+func TestConvertGeminiRequestToAntigravity_KeepsDistinctFirstUserTurn(t *testing.T) {
+	inputJSON := []byte(`{
+		"system_instruction": {"parts": [{"text": "You are a helpful assistant."}]},
+		"contents": [
+			{"role": "user", "parts": [{"text": "Hello there"}]}
+		]
+	}`)
+
+	output := ConvertGeminiRequestToAntigravity("gemini-2.5-pro", inputJSON, false)
+
+	contents := gjson.GetBytes(output, "request.contents").Array()
+	if len(contents) != 1 {
+		t.Fatalf("Expected 1 content, got %d", len(contents))
+	}
+	if got := contents[0].Get("parts.0.text").String(); got != "Hello there" {
+		t.Errorf("Expected user message to be preserved, got %q", got)
+	}
+}
+
+func TestConvertGeminiRequestToAntigravity_InjectsDefaultSystemInstruction(t *testing.T) {
+	prev := DefaultClaudeSystemInstruction
+	DefaultClaudeSystemInstruction = "Use tools only when needed."
+	t.Cleanup(func() { DefaultClaudeSystemInstruction = prev })
+
+	inputJSON := []byte(`{
+		"contents": [
+			{"role": "user", "parts": [{"text": "Hello there"}]}
+		]
+	}`)
+
+	output := ConvertGeminiRequestToAntigravity("claude-sonnet-4-5", inputJSON, false)
+	if got := gjson.GetBytes(output, "request.systemInstruction.parts.0.text").String(); got != "Use tools only when needed." {
+		t.Errorf("Expected default system instruction to be injected, got %q", got)
+	}
+
+	output = ConvertGeminiRequestToAntigravity("gemini-2.5-pro", inputJSON, false)
+	if gjson.GetBytes(output, "request.systemInstruction").Exists() {
+		t.Error("Expected no system instruction to be injected for non-Claude models")
+	}
+}
+
+func TestConvertGeminiRequestToAntigravity_KeepsClientSystemInstruction(t *testing.T) {
+	prev := DefaultClaudeSystemInstruction
+	DefaultClaudeSystemInstruction = "Use tools only when needed."
+	t.Cleanup(func() { DefaultClaudeSystemInstruction = prev })
+
+	for _, field := range []string{"system_instruction", "systemInstruction"} {
+		t.Run(field, func(t *testing.T) {
+			inputJSON := []byte(`{
+				"` + field + `": {"parts": [{"text": "You are a helpful assistant."}]},
+				"contents": [
+					{"role": "user", "parts": [{"text": "Hello there"}]}
+				]
+			}`)
+
+			output := ConvertGeminiRequestToAntigravity("claude-sonnet-4-5", inputJSON, false)
+			parts := gjson.GetBytes(output, "request.systemInstruction.parts").Array()
+			if len(parts) != 1 || parts[0].Get("text").String() != "You are a helpful assistant." {
+				t.Errorf("Expected client system instruction to be untouched, got %s", gjson.GetBytes(output, "request.systemInstruction").Raw)
+			}
+			if gjson.GetBytes(output, "request.system_instruction").Exists() {
+				t.Error("Expected system_instruction to be normalized away")
+			}
+		})
+	}
+}
+
+func TestConvertGeminiRequestToAntigravity_CachedMatchesFresh(t *testing.T) {
+	input := []byte(`{"contents":[{"role":"user","parts":[{"text":"hi"}]}],"tools":[{"functionDeclarations":[{"name":"t","parameters":{"type":"object"}}]}]}`)
+	cached := sdktranslator.NewRequestCache(8).Wrap(ConvertGeminiRequestToAntigravity)
+
+	fresh := ConvertGeminiRequestToAntigravity("gemini-3-pro-preview", input, false)
+	for i := 0; i < 2; i++ {
+		if got := cached("gemini-3-pro-preview", input, false); string(got) != string(fresh) {
+			t.Fatalf("cached output (call %d) = %s, want %s", i, got, fresh)
+		}
+	}
+}
+
+func TestConvertGeminiRequestToAntigravity_PrunesUnsupportedGenerationConfig(t *testing.T) {
+	inputJSON := []byte(`{
+		"contents": [{"role": "user", "parts": [{"text": "hi"}]}],
+		"generationConfig": {"topK": 40, "topP": 0.9, "temperature": 0.5, "maxOutputTokens": 1024}
+	}`)
+
+	claude := ConvertGeminiRequestToAntigravity("claude-sonnet-4-5", inputJSON, false)
+	if gjson.GetBytes(claude, "request.generationConfig.topK").Exists() {
+		t.Errorf("topK should be removed for Claude: %s", gjson.GetBytes(claude, "request.generationConfig").Raw)
+	}
+	for path, want := range map[string]float64{"topP": 0.9, "temperature": 0.5, "maxOutputTokens": 1024} {
+		if got := gjson.GetBytes(claude, "request.generationConfig."+path).Float(); got != want {
+			t.Errorf("Claude generationConfig.%s = %v, want %v", path, got, want)
+		}
+	}
+	if got := gjson.GetBytes(claude, "request.contents.0.parts.0.text").String(); got != "hi" {
+		t.Errorf("contents changed: %q", got)
+	}
+
+	gemini := ConvertGeminiRequestToAntigravity("gemini-3-pro-preview", inputJSON, false)
+	if got := gjson.GetBytes(gemini, "request.generationConfig.topK").Int(); got != 40 {
+		t.Errorf("Gemini generationConfig.topK = %d, want 40", got)
+	}
+}
+
+func TestConvertGeminiRequestToAntigravity_PreservesTrailingModelTurn(t *testing.T) {
+	inputJSON := []byte(`{
+		"contents": [
+			{"role": "user", "parts": [{"text": "Write a haiku about rain."}]},
+			{"role": "model", "parts": [{"text": "Soft rain on the roof,"}]}
+		]
+	}`)
+
+	output := ConvertGeminiRequestToAntigravity("claude-sonnet-4-5", inputJSON, false)
+
+	contents := gjson.GetBytes(output, "request.contents").Array()
+	if len(contents) != 2 {
+		t.Fatalf("Expected 2 contents, got %d: %s", len(contents), gjson.GetBytes(output, "request.contents").Raw)
+	}
+	last := contents[1]
+	if last.Get("role").String() != "model" || last.Get("parts.0.text").String() != "Soft rain on the roof," {
+		t.Errorf("Expected the trailing model turn to be passed through unchanged, got %s", last.Raw)
+	}
+}
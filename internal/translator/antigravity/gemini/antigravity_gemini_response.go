@@ -10,8 +10,8 @@ import (
 	"context"
 
 	translatorcommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/common"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 // ConvertAntigravityResponseToGemini parses and transforms a Gemini CLI API request into Gemini API format.
@@ -51,7 +51,7 @@ func ConvertAntigravityResponseToGemini(ctx context.Context, _ string, originalR
 				for i := 0; i < len(responseResultItems); i++ {
 					responseResultItem := responseResultItems[i]
 					if responseResultItem.Get("response").Exists() {
-						chunkTemplate, _ = sjson.SetRawBytes(chunkTemplate, "-1", []byte(responseResultItem.Get("response").Raw))
+						chunkTemplate, _ = util.SafeSetRawJSON(chunkTemplate, "-1", []byte(responseResultItem.Get("response").Raw))
 					}
 				}
 			}
@@ -93,8 +93,8 @@ func GeminiTokenCount(ctx context.Context, count int64) []byte {
 // When returning standard Gemini API format, we must restore the original name.
 func restoreUsageMetadata(chunk []byte) []byte {
 	if cpaUsage := gjson.GetBytes(chunk, "cpaUsageMetadata"); cpaUsage.Exists() {
-		chunk, _ = sjson.SetRawBytes(chunk, "usageMetadata", []byte(cpaUsage.Raw))
-		chunk, _ = sjson.DeleteBytes(chunk, "cpaUsageMetadata")
+		chunk, _ = util.SafeSetRawJSON(chunk, "usageMetadata", []byte(cpaUsage.Raw))
+		chunk, _ = util.SafeDeleteJSON(chunk, "cpaUsageMetadata")
 	}
 	return chunk
 }
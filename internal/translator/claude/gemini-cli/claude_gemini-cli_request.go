@@ -7,8 +7,8 @@ package geminiCLI
 
 import (
 	. "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/claude/gemini"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 // ConvertGeminiCLIRequestToClaude parses and transforms a Gemini CLI API request into Claude Code API format.
@@ -34,11 +34,11 @@ func ConvertGeminiCLIRequestToClaude(modelName string, inputRawJSON []byte, stre
 	// Extract the inner request object and promote it to the top level
 	rawJSON = []byte(gjson.GetBytes(rawJSON, "request").Raw)
 	// Restore the model information at the top level
-	rawJSON, _ = sjson.SetBytes(rawJSON, "model", modelResult.String())
+	rawJSON, _ = util.SafeSetJSON(rawJSON, "model", modelResult.String())
 	// Convert systemInstruction field to system_instruction for Claude Code compatibility
 	if gjson.GetBytes(rawJSON, "systemInstruction").Exists() {
-		rawJSON, _ = sjson.SetRawBytes(rawJSON, "system_instruction", []byte(gjson.GetBytes(rawJSON, "systemInstruction").Raw))
-		rawJSON, _ = sjson.DeleteBytes(rawJSON, "systemInstruction")
+		rawJSON, _ = util.SafeSetRawJSON(rawJSON, "system_instruction", []byte(gjson.GetBytes(rawJSON, "systemInstruction").Raw))
+		rawJSON, _ = util.SafeDeleteJSON(rawJSON, "systemInstruction")
 	}
 	// Delegate to the Gemini-to-Claude conversion function for further processing
 	return ConvertGeminiRequestToClaude(modelName, rawJSON, stream)
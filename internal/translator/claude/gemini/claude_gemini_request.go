@@ -18,7 +18,6 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 var (
@@ -87,20 +86,20 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 	var pendingToolIDs []string
 
 	// Model mapping to specify which Claude Code model to use
-	out, _ = sjson.SetBytes(out, "model", modelName)
+	out, _ = util.SafeSetJSON(out, "model", modelName)
 
 	// Generation config extraction from Gemini format
 	if genConfig := root.Get("generationConfig"); genConfig.Exists() {
 		// Max output tokens configuration
 		if maxTokens := genConfig.Get("maxOutputTokens"); maxTokens.Exists() {
-			out, _ = sjson.SetBytes(out, "max_tokens", maxTokens.Int())
+			out, _ = util.SafeSetJSON(out, "max_tokens", maxTokens.Int())
 		}
 		// Temperature setting for controlling response randomness
 		if temp := genConfig.Get("temperature"); temp.Exists() {
-			out, _ = sjson.SetBytes(out, "temperature", temp.Float())
+			out, _ = util.SafeSetJSON(out, "temperature", temp.Float())
 		} else if topP := genConfig.Get("topP"); topP.Exists() {
 			// Top P setting for nucleus sampling (filtered out if temperature is set)
-			out, _ = sjson.SetBytes(out, "top_p", topP.Float())
+			out, _ = util.SafeSetJSON(out, "top_p", topP.Float())
 		}
 		// Stop sequences configuration for custom termination conditions
 		if stopSeqs := genConfig.Get("stopSequences"); stopSeqs.Exists() && stopSeqs.IsArray() {
@@ -110,7 +109,7 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 				return true
 			})
 			if len(stopSequences) > 0 {
-				out, _ = sjson.SetBytes(out, "stop_sequences", stopSequences)
+				out, _ = util.SafeSetJSON(out, "stop_sequences", stopSequences)
 			}
 		}
 		// Include thoughts configuration for reasoning process visibility
@@ -132,30 +131,30 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 					switch level {
 					case "":
 					case "none":
-						out, _ = sjson.SetBytes(out, "thinking.type", "disabled")
-						out, _ = sjson.DeleteBytes(out, "thinking.budget_tokens")
-						out, _ = sjson.DeleteBytes(out, "output_config.effort")
+						out, _ = util.SafeSetJSON(out, "thinking.type", "disabled")
+						out, _ = util.SafeDeleteJSON(out, "thinking.budget_tokens")
+						out, _ = util.SafeDeleteJSON(out, "output_config.effort")
 					default:
 						if mapped, ok := thinking.MapToClaudeEffort(level, supportsMax); ok {
 							level = mapped
 						}
-						out, _ = sjson.SetBytes(out, "thinking.type", "adaptive")
-						out, _ = sjson.DeleteBytes(out, "thinking.budget_tokens")
-						out, _ = sjson.SetBytes(out, "output_config.effort", level)
+						out, _ = util.SafeSetJSON(out, "thinking.type", "adaptive")
+						out, _ = util.SafeDeleteJSON(out, "thinking.budget_tokens")
+						out, _ = util.SafeSetJSON(out, "output_config.effort", level)
 					}
 				} else {
 					switch level {
 					case "":
 					case "none":
-						out, _ = sjson.SetBytes(out, "thinking.type", "disabled")
-						out, _ = sjson.DeleteBytes(out, "thinking.budget_tokens")
+						out, _ = util.SafeSetJSON(out, "thinking.type", "disabled")
+						out, _ = util.SafeDeleteJSON(out, "thinking.budget_tokens")
 					case "auto":
-						out, _ = sjson.SetBytes(out, "thinking.type", "enabled")
-						out, _ = sjson.DeleteBytes(out, "thinking.budget_tokens")
+						out, _ = util.SafeSetJSON(out, "thinking.type", "enabled")
+						out, _ = util.SafeDeleteJSON(out, "thinking.budget_tokens")
 					default:
 						if budget, ok := thinking.ConvertLevelToBudget(level); ok {
-							out, _ = sjson.SetBytes(out, "thinking.type", "enabled")
-							out, _ = sjson.SetBytes(out, "thinking.budget_tokens", budget)
+							out, _ = util.SafeSetJSON(out, "thinking.type", "enabled")
+							out, _ = util.SafeSetJSON(out, "thinking.budget_tokens", budget)
 						}
 					}
 				}
@@ -169,37 +168,37 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 					if supportsAdaptive {
 						switch budget {
 						case 0:
-							out, _ = sjson.SetBytes(out, "thinking.type", "disabled")
-							out, _ = sjson.DeleteBytes(out, "thinking.budget_tokens")
-							out, _ = sjson.DeleteBytes(out, "output_config.effort")
+							out, _ = util.SafeSetJSON(out, "thinking.type", "disabled")
+							out, _ = util.SafeDeleteJSON(out, "thinking.budget_tokens")
+							out, _ = util.SafeDeleteJSON(out, "output_config.effort")
 						default:
 							level, ok := thinking.ConvertBudgetToLevel(budget)
 							if ok {
 								if mapped, okM := thinking.MapToClaudeEffort(level, supportsMax); okM {
 									level = mapped
 								}
-								out, _ = sjson.SetBytes(out, "thinking.type", "adaptive")
-								out, _ = sjson.DeleteBytes(out, "thinking.budget_tokens")
-								out, _ = sjson.SetBytes(out, "output_config.effort", level)
+								out, _ = util.SafeSetJSON(out, "thinking.type", "adaptive")
+								out, _ = util.SafeDeleteJSON(out, "thinking.budget_tokens")
+								out, _ = util.SafeSetJSON(out, "output_config.effort", level)
 							}
 						}
 					} else {
 						switch budget {
 						case 0:
-							out, _ = sjson.SetBytes(out, "thinking.type", "disabled")
-							out, _ = sjson.DeleteBytes(out, "thinking.budget_tokens")
+							out, _ = util.SafeSetJSON(out, "thinking.type", "disabled")
+							out, _ = util.SafeDeleteJSON(out, "thinking.budget_tokens")
 						case -1:
-							out, _ = sjson.SetBytes(out, "thinking.type", "enabled")
-							out, _ = sjson.DeleteBytes(out, "thinking.budget_tokens")
+							out, _ = util.SafeSetJSON(out, "thinking.type", "enabled")
+							out, _ = util.SafeDeleteJSON(out, "thinking.budget_tokens")
 						default:
-							out, _ = sjson.SetBytes(out, "thinking.type", "enabled")
-							out, _ = sjson.SetBytes(out, "thinking.budget_tokens", budget)
+							out, _ = util.SafeSetJSON(out, "thinking.type", "enabled")
+							out, _ = util.SafeSetJSON(out, "thinking.budget_tokens", budget)
 						}
 					}
 				} else if includeThoughts := thinkingConfig.Get("includeThoughts"); includeThoughts.Exists() && includeThoughts.Type == gjson.True {
-					out, _ = sjson.SetBytes(out, "thinking.type", "enabled")
+					out, _ = util.SafeSetJSON(out, "thinking.type", "enabled")
 				} else if includeThoughts := thinkingConfig.Get("include_thoughts"); includeThoughts.Exists() && includeThoughts.Type == gjson.True {
-					out, _ = sjson.SetBytes(out, "thinking.type", "enabled")
+					out, _ = util.SafeSetJSON(out, "thinking.type", "enabled")
 				}
 			}
 		}
@@ -221,8 +220,8 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 			if systemText.Len() > 0 {
 				// Create system message in Claude Code format
 				systemMessage := []byte(`{"role":"user","content":[{"type":"text","text":""}]}`)
-				systemMessage, _ = sjson.SetBytes(systemMessage, "content.0.text", systemText.String())
-				out, _ = sjson.SetRawBytes(out, "messages.-1", systemMessage)
+				systemMessage, _ = util.SafeSetJSON(systemMessage, "content.0.text", systemText.String())
+				out, _ = util.SafeSetRawJSON(out, "messages.-1", systemMessage)
 			}
 		}
 	}
@@ -246,15 +245,15 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 
 			// Create message structure in Claude Code format
 			msg := []byte(`{"role":"","content":[]}`)
-			msg, _ = sjson.SetBytes(msg, "role", role)
+			msg, _ = util.SafeSetJSON(msg, "role", role)
 
 			if parts := content.Get("parts"); parts.Exists() && parts.IsArray() {
 				parts.ForEach(func(_, part gjson.Result) bool {
 					// Text content conversion
 					if text := part.Get("text"); text.Exists() {
 						textContent := []byte(`{"type":"text","text":""}`)
-						textContent, _ = sjson.SetBytes(textContent, "text", text.String())
-						msg, _ = sjson.SetRawBytes(msg, "content.-1", textContent)
+						textContent, _ = util.SafeSetJSON(textContent, "text", text.String())
+						msg, _ = util.SafeSetRawJSON(msg, "content.-1", textContent)
 						return true
 					}
 
@@ -266,15 +265,15 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 						// with the corresponding functionResponse
 						toolID := genToolCallID()
 						pendingToolIDs = append(pendingToolIDs, toolID)
-						toolUse, _ = sjson.SetBytes(toolUse, "id", toolID)
+						toolUse, _ = util.SafeSetJSON(toolUse, "id", toolID)
 
 						if name := fc.Get("name"); name.Exists() {
-							toolUse, _ = sjson.SetBytes(toolUse, "name", name.String())
+							toolUse, _ = util.SafeSetJSON(toolUse, "name", name.String())
 						}
 						if args := fc.Get("args"); args.Exists() && args.IsObject() {
-							toolUse, _ = sjson.SetRawBytes(toolUse, "input", []byte(args.Raw))
+							toolUse, _ = util.SafeSetRawJSON(toolUse, "input", []byte(args.Raw))
 						}
-						msg, _ = sjson.SetRawBytes(msg, "content.-1", toolUse)
+						msg, _ = util.SafeSetRawJSON(msg, "content.-1", toolUse)
 						return true
 					}
 
@@ -293,15 +292,15 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 							// Fallback: generate new ID if no pending tool_use found
 							toolID = genToolCallID()
 						}
-						toolResult, _ = sjson.SetBytes(toolResult, "tool_use_id", toolID)
+						toolResult, _ = util.SafeSetJSON(toolResult, "tool_use_id", toolID)
 
 						// Extract result content from the function response
 						if result := fr.Get("response.result"); result.Exists() {
-							toolResult, _ = sjson.SetBytes(toolResult, "content", result.String())
+							toolResult, _ = util.SafeSetJSON(toolResult, "content", result.String())
 						} else if response := fr.Get("response"); response.Exists() {
-							toolResult, _ = sjson.SetBytes(toolResult, "content", response.Raw)
+							toolResult, _ = util.SafeSetJSON(toolResult, "content", response.Raw)
 						}
-						msg, _ = sjson.SetRawBytes(msg, "content.-1", toolResult)
+						msg, _ = util.SafeSetRawJSON(msg, "content.-1", toolResult)
 						return true
 					}
 
@@ -309,12 +308,12 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 					if inlineData := part.Get("inline_data"); inlineData.Exists() {
 						imageContent := []byte(`{"type":"image","source":{"type":"base64","media_type":"","data":""}}`)
 						if mimeType := inlineData.Get("mime_type"); mimeType.Exists() {
-							imageContent, _ = sjson.SetBytes(imageContent, "source.media_type", mimeType.String())
+							imageContent, _ = util.SafeSetJSON(imageContent, "source.media_type", mimeType.String())
 						}
 						if data := inlineData.Get("data"); data.Exists() {
-							imageContent, _ = sjson.SetBytes(imageContent, "source.data", data.String())
+							imageContent, _ = util.SafeSetJSON(imageContent, "source.data", data.String())
 						}
-						msg, _ = sjson.SetRawBytes(msg, "content.-1", imageContent)
+						msg, _ = util.SafeSetRawJSON(msg, "content.-1", imageContent)
 						return true
 					}
 
@@ -326,8 +325,8 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 						if mimeType := fileData.Get("mime_type"); mimeType.Exists() {
 							fileInfo += " (Type: " + mimeType.String() + ")"
 						}
-						textContent, _ = sjson.SetBytes(textContent, "text", fileInfo)
-						msg, _ = sjson.SetRawBytes(msg, "content.-1", textContent)
+						textContent, _ = util.SafeSetJSON(textContent, "text", fileInfo)
+						msg, _ = util.SafeSetRawJSON(msg, "content.-1", textContent)
 						return true
 					}
 
@@ -337,7 +336,7 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 
 			// Only add message if it has content
 			if contentArray := gjson.GetBytes(msg, "content"); contentArray.Exists() && len(contentArray.Array()) > 0 {
-				out, _ = sjson.SetRawBytes(out, "messages.-1", msg)
+				out, _ = util.SafeSetRawJSON(out, "messages.-1", msg)
 			}
 
 			return true
@@ -354,23 +353,23 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 					anthropicTool := []byte(`{"name":"","description":"","input_schema":{}}`)
 
 					if name := funcDecl.Get("name"); name.Exists() {
-						anthropicTool, _ = sjson.SetBytes(anthropicTool, "name", name.String())
+						anthropicTool, _ = util.SafeSetJSON(anthropicTool, "name", name.String())
 					}
 					if desc := funcDecl.Get("description"); desc.Exists() {
-						anthropicTool, _ = sjson.SetBytes(anthropicTool, "description", desc.String())
+						anthropicTool, _ = util.SafeSetJSON(anthropicTool, "description", desc.String())
 					}
 					if params := funcDecl.Get("parameters"); params.Exists() {
 						// Clean up the parameters schema for Claude Code compatibility
 						cleaned := []byte(params.Raw)
-						cleaned, _ = sjson.SetBytes(cleaned, "additionalProperties", false)
-						cleaned, _ = sjson.SetBytes(cleaned, "$schema", "http://json-schema.org/draft-07/schema#")
-						anthropicTool, _ = sjson.SetRawBytes(anthropicTool, "input_schema", cleaned)
+						cleaned, _ = util.SafeSetJSON(cleaned, "additionalProperties", false)
+						cleaned, _ = util.SafeSetJSON(cleaned, "$schema", "http://json-schema.org/draft-07/schema#")
+						anthropicTool, _ = util.SafeSetRawJSON(anthropicTool, "input_schema", cleaned)
 					} else if params = funcDecl.Get("parametersJsonSchema"); params.Exists() {
 						// Clean up the parameters schema for Claude Code compatibility
 						cleaned := []byte(params.Raw)
-						cleaned, _ = sjson.SetBytes(cleaned, "additionalProperties", false)
-						cleaned, _ = sjson.SetBytes(cleaned, "$schema", "http://json-schema.org/draft-07/schema#")
-						anthropicTool, _ = sjson.SetRawBytes(anthropicTool, "input_schema", cleaned)
+						cleaned, _ = util.SafeSetJSON(cleaned, "additionalProperties", false)
+						cleaned, _ = util.SafeSetJSON(cleaned, "$schema", "http://json-schema.org/draft-07/schema#")
+						anthropicTool, _ = util.SafeSetRawJSON(anthropicTool, "input_schema", cleaned)
 					}
 
 					anthropicTools = append(anthropicTools, gjson.ParseBytes(anthropicTool).Value())
@@ -381,7 +380,7 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 		})
 
 		if len(anthropicTools) > 0 {
-			out, _ = sjson.SetBytes(out, "tools", anthropicTools)
+			out, _ = util.SafeSetJSON(out, "tools", anthropicTools)
 		}
 	}
 
@@ -391,18 +390,18 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 			if mode := funcCalling.Get("mode"); mode.Exists() {
 				switch mode.String() {
 				case "AUTO":
-					out, _ = sjson.SetRawBytes(out, "tool_choice", []byte(`{"type":"auto"}`))
+					out, _ = util.SafeSetRawJSON(out, "tool_choice", []byte(`{"type":"auto"}`))
 				case "NONE":
-					out, _ = sjson.SetRawBytes(out, "tool_choice", []byte(`{"type":"none"}`))
+					out, _ = util.SafeSetRawJSON(out, "tool_choice", []byte(`{"type":"none"}`))
 				case "ANY":
-					out, _ = sjson.SetRawBytes(out, "tool_choice", []byte(`{"type":"any"}`))
+					out, _ = util.SafeSetRawJSON(out, "tool_choice", []byte(`{"type":"any"}`))
 				}
 			}
 		}
 	}
 
 	// Stream setting configuration
-	out, _ = sjson.SetBytes(out, "stream", stream)
+	out, _ = util.SafeSetJSON(out, "stream", stream)
 
 	// Convert tool parameter types to lowercase for Claude Code compatibility
 	var pathsToLower []string
@@ -410,7 +409,7 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 	util.Walk(toolsResult, "", "type", &pathsToLower)
 	for _, p := range pathsToLower {
 		fullPath := fmt.Sprintf("tools.%s", p)
-		out, _ = sjson.SetBytes(out, fullPath, strings.ToLower(gjson.GetBytes(out, fullPath).String()))
+		out, _ = util.SafeSetJSON(out, fullPath, strings.ToLower(gjson.GetBytes(out, fullPath).String()))
 	}
 
 	return out
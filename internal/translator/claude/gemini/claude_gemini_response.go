@@ -13,8 +13,8 @@ import (
 	"time"
 
 	translatorcommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/common"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 var (
@@ -76,19 +76,19 @@ func ConvertClaudeResponseToGemini(_ context.Context, modelName string, original
 	// Set model version
 	if (*param).(*ConvertAnthropicResponseToGeminiParams).Model != "" {
 		// Map Claude model names back to Gemini model names
-		template, _ = sjson.SetBytes(template, "modelVersion", (*param).(*ConvertAnthropicResponseToGeminiParams).Model)
+		template, _ = util.SafeSetJSON(template, "modelVersion", (*param).(*ConvertAnthropicResponseToGeminiParams).Model)
 	}
 
 	// Set response ID and creation time
 	if (*param).(*ConvertAnthropicResponseToGeminiParams).ResponseID != "" {
-		template, _ = sjson.SetBytes(template, "responseId", (*param).(*ConvertAnthropicResponseToGeminiParams).ResponseID)
+		template, _ = util.SafeSetJSON(template, "responseId", (*param).(*ConvertAnthropicResponseToGeminiParams).ResponseID)
 	}
 
 	// Set creation time to current time if not provided
 	if (*param).(*ConvertAnthropicResponseToGeminiParams).CreatedAt == 0 {
 		(*param).(*ConvertAnthropicResponseToGeminiParams).CreatedAt = time.Now().Unix()
 	}
-	template, _ = sjson.SetBytes(template, "createTime", time.Unix((*param).(*ConvertAnthropicResponseToGeminiParams).CreatedAt, 0).Format(time.RFC3339Nano))
+	template, _ = util.SafeSetJSON(template, "createTime", time.Unix((*param).(*ConvertAnthropicResponseToGeminiParams).CreatedAt, 0).Format(time.RFC3339Nano))
 
 	switch eventType {
 	case "message_start":
@@ -124,15 +124,15 @@ func ConvertClaudeResponseToGemini(_ context.Context, modelName string, original
 				// Regular text content delta for normal response text
 				if text := delta.Get("text"); text.Exists() && text.String() != "" {
 					textPart := []byte(`{"text":""}`)
-					textPart, _ = sjson.SetBytes(textPart, "text", text.String())
-					template, _ = sjson.SetRawBytes(template, "candidates.0.content.parts.-1", textPart)
+					textPart, _ = util.SafeSetJSON(textPart, "text", text.String())
+					template, _ = util.SafeSetRawJSON(template, "candidates.0.content.parts.-1", textPart)
 				}
 			case "thinking_delta":
 				// Thinking/reasoning content delta for models with reasoning capabilities
 				if text := delta.Get("thinking"); text.Exists() && text.String() != "" {
 					thinkingPart := []byte(`{"thought":true,"text":""}`)
-					thinkingPart, _ = sjson.SetBytes(thinkingPart, "text", text.String())
-					template, _ = sjson.SetRawBytes(template, "candidates.0.content.parts.-1", thinkingPart)
+					thinkingPart, _ = util.SafeSetJSON(thinkingPart, "text", text.String())
+					template, _ = util.SafeSetRawJSON(template, "candidates.0.content.parts.-1", thinkingPart)
 				}
 			case "input_json_delta":
 				// Tool use input delta - accumulate partial_json by index for later assembly at content_block_stop
@@ -172,13 +172,13 @@ func ConvertClaudeResponseToGemini(_ context.Context, modelName string, original
 		if name != "" || argsTrim != "" {
 			functionCall := []byte(`{"functionCall":{"name":"","args":{}}}`)
 			if name != "" {
-				functionCall, _ = sjson.SetBytes(functionCall, "functionCall.name", name)
+				functionCall, _ = util.SafeSetJSON(functionCall, "functionCall.name", name)
 			}
 			if argsTrim != "" {
-				functionCall, _ = sjson.SetRawBytes(functionCall, "functionCall.args", []byte(argsTrim))
+				functionCall, _ = util.SafeSetRawJSON(functionCall, "functionCall.args", []byte(argsTrim))
 			}
-			template, _ = sjson.SetRawBytes(template, "candidates.0.content.parts.-1", functionCall)
-			template, _ = sjson.SetBytes(template, "candidates.0.finishReason", "STOP")
+			template, _ = util.SafeSetRawJSON(template, "candidates.0.content.parts.-1", functionCall)
+			template, _ = util.SafeSetJSON(template, "candidates.0.finishReason", "STOP")
 			(*param).(*ConvertAnthropicResponseToGeminiParams).LastStorageOutput = append([]byte(nil), template...)
 			// cleanup used state for this index
 			if (*param).(*ConvertAnthropicResponseToGeminiParams).ToolUseArgs != nil {
@@ -197,15 +197,15 @@ func ConvertClaudeResponseToGemini(_ context.Context, modelName string, original
 			if stopReason := delta.Get("stop_reason"); stopReason.Exists() {
 				switch stopReason.String() {
 				case "end_turn":
-					template, _ = sjson.SetBytes(template, "candidates.0.finishReason", "STOP")
+					template, _ = util.SafeSetJSON(template, "candidates.0.finishReason", "STOP")
 				case "tool_use":
-					template, _ = sjson.SetBytes(template, "candidates.0.finishReason", "STOP")
+					template, _ = util.SafeSetJSON(template, "candidates.0.finishReason", "STOP")
 				case "max_tokens":
-					template, _ = sjson.SetBytes(template, "candidates.0.finishReason", "MAX_TOKENS")
+					template, _ = util.SafeSetJSON(template, "candidates.0.finishReason", "MAX_TOKENS")
 				case "stop_sequence":
-					template, _ = sjson.SetBytes(template, "candidates.0.finishReason", "STOP")
+					template, _ = util.SafeSetJSON(template, "candidates.0.finishReason", "STOP")
 				default:
-					template, _ = sjson.SetBytes(template, "candidates.0.finishReason", "STOP")
+					template, _ = util.SafeSetJSON(template, "candidates.0.finishReason", "STOP")
 				}
 			}
 		}
@@ -216,30 +216,30 @@ func ConvertClaudeResponseToGemini(_ context.Context, modelName string, original
 			outputTokens := usage.Get("output_tokens").Int()
 
 			// Set basic usage metadata according to Gemini API specification
-			template, _ = sjson.SetBytes(template, "usageMetadata.promptTokenCount", inputTokens)
-			template, _ = sjson.SetBytes(template, "usageMetadata.candidatesTokenCount", outputTokens)
-			template, _ = sjson.SetBytes(template, "usageMetadata.totalTokenCount", inputTokens+outputTokens)
+			template, _ = util.SafeSetJSON(template, "usageMetadata.promptTokenCount", inputTokens)
+			template, _ = util.SafeSetJSON(template, "usageMetadata.candidatesTokenCount", outputTokens)
+			template, _ = util.SafeSetJSON(template, "usageMetadata.totalTokenCount", inputTokens+outputTokens)
 
 			// Add cache-related token counts if present (Claude Code API cache fields)
 			if cacheCreationTokens := usage.Get("cache_creation_input_tokens"); cacheCreationTokens.Exists() {
-				template, _ = sjson.SetBytes(template, "usageMetadata.cachedContentTokenCount", cacheCreationTokens.Int())
+				template, _ = util.SafeSetJSON(template, "usageMetadata.cachedContentTokenCount", cacheCreationTokens.Int())
 			}
 			if cacheReadTokens := usage.Get("cache_read_input_tokens"); cacheReadTokens.Exists() {
 				// Add cache read tokens to cached content count
 				existingCacheTokens := usage.Get("cache_creation_input_tokens").Int()
 				totalCacheTokens := existingCacheTokens + cacheReadTokens.Int()
-				template, _ = sjson.SetBytes(template, "usageMetadata.cachedContentTokenCount", totalCacheTokens)
+				template, _ = util.SafeSetJSON(template, "usageMetadata.cachedContentTokenCount", totalCacheTokens)
 			}
 
 			// Add thinking tokens if present (for models with reasoning capabilities)
 			if thinkingTokens := usage.Get("thinking_tokens"); thinkingTokens.Exists() {
-				template, _ = sjson.SetBytes(template, "usageMetadata.thoughtsTokenCount", thinkingTokens.Int())
+				template, _ = util.SafeSetJSON(template, "usageMetadata.thoughtsTokenCount", thinkingTokens.Int())
 			}
 
 			// Set traffic type (required by Gemini API)
-			template, _ = sjson.SetBytes(template, "usageMetadata.trafficType", "PROVISIONED_THROUGHPUT")
+			template, _ = util.SafeSetJSON(template, "usageMetadata.trafficType", "PROVISIONED_THROUGHPUT")
 		}
-		template, _ = sjson.SetBytes(template, "candidates.0.finishReason", "STOP")
+		template, _ = util.SafeSetJSON(template, "candidates.0.finishReason", "STOP")
 
 		return [][]byte{template}
 	case "message_stop":
@@ -254,7 +254,7 @@ func ConvertClaudeResponseToGemini(_ context.Context, modelName string, original
 
 		// Create error response in Gemini format
 		errorResponse := []byte(`{"error":{"code":400,"message":"","status":"INVALID_ARGUMENT"}}`)
-		errorResponse, _ = sjson.SetBytes(errorResponse, "error.message", errorMsg)
+		errorResponse, _ = util.SafeSetJSON(errorResponse, "error.message", errorMsg)
 		return [][]byte{errorResponse}
 
 	default:
@@ -281,7 +281,7 @@ func ConvertClaudeResponseToGeminiNonStream(_ context.Context, modelName string,
 	template := []byte(`{"candidates":[{"content":{"role":"model","parts":[]},"finishReason":"STOP"}],"usageMetadata":{"trafficType":"PROVISIONED_THROUGHPUT"},"modelVersion":"","createTime":"","responseId":""}`)
 
 	// Set model version
-	template, _ = sjson.SetBytes(template, "modelVersion", modelName)
+	template, _ = util.SafeSetJSON(template, "modelVersion", modelName)
 
 	streamingEvents := make([][]byte, 0)
 
@@ -361,14 +361,14 @@ func ConvertClaudeResponseToGeminiNonStream(_ context.Context, modelName string,
 					// Process regular text content
 					if text := delta.Get("text"); text.Exists() && text.String() != "" {
 						partJSON := []byte(`{"text":""}`)
-						partJSON, _ = sjson.SetBytes(partJSON, "text", text.String())
+						partJSON, _ = util.SafeSetJSON(partJSON, "text", text.String())
 						allParts = append(allParts, partJSON)
 					}
 				case "thinking_delta":
 					// Process reasoning/thinking content
 					if text := delta.Get("thinking"); text.Exists() && text.String() != "" {
 						partJSON := []byte(`{"thought":true,"text":""}`)
-						partJSON, _ = sjson.SetBytes(partJSON, "text", text.String())
+						partJSON, _ = util.SafeSetJSON(partJSON, "text", text.String())
 						allParts = append(allParts, partJSON)
 					}
 				case "input_json_delta":
@@ -404,10 +404,10 @@ func ConvertClaudeResponseToGeminiNonStream(_ context.Context, modelName string,
 			if name != "" || argsTrim != "" {
 				functionCallJSON := []byte(`{"functionCall":{"name":"","args":{}}}`)
 				if name != "" {
-					functionCallJSON, _ = sjson.SetBytes(functionCallJSON, "functionCall.name", name)
+					functionCallJSON, _ = util.SafeSetJSON(functionCallJSON, "functionCall.name", name)
 				}
 				if argsTrim != "" {
-					functionCallJSON, _ = sjson.SetRawBytes(functionCallJSON, "functionCall.args", []byte(argsTrim))
+					functionCallJSON, _ = util.SafeSetRawJSON(functionCallJSON, "functionCall.args", []byte(argsTrim))
 				}
 				allParts = append(allParts, functionCallJSON)
 				// cleanup used state for this index
@@ -429,28 +429,28 @@ func ConvertClaudeResponseToGeminiNonStream(_ context.Context, modelName string,
 				outputTokens := usage.Get("output_tokens").Int()
 
 				// Set basic usage metadata according to Gemini API specification
-				usageJSON, _ = sjson.SetBytes(usageJSON, "promptTokenCount", inputTokens)
-				usageJSON, _ = sjson.SetBytes(usageJSON, "candidatesTokenCount", outputTokens)
-				usageJSON, _ = sjson.SetBytes(usageJSON, "totalTokenCount", inputTokens+outputTokens)
+				usageJSON, _ = util.SafeSetJSON(usageJSON, "promptTokenCount", inputTokens)
+				usageJSON, _ = util.SafeSetJSON(usageJSON, "candidatesTokenCount", outputTokens)
+				usageJSON, _ = util.SafeSetJSON(usageJSON, "totalTokenCount", inputTokens+outputTokens)
 
 				// Add cache-related token counts if present (Claude Code API cache fields)
 				if cacheCreationTokens := usage.Get("cache_creation_input_tokens"); cacheCreationTokens.Exists() {
-					usageJSON, _ = sjson.SetBytes(usageJSON, "cachedContentTokenCount", cacheCreationTokens.Int())
+					usageJSON, _ = util.SafeSetJSON(usageJSON, "cachedContentTokenCount", cacheCreationTokens.Int())
 				}
 				if cacheReadTokens := usage.Get("cache_read_input_tokens"); cacheReadTokens.Exists() {
 					// Add cache read tokens to cached content count
 					existingCacheTokens := usage.Get("cache_creation_input_tokens").Int()
 					totalCacheTokens := existingCacheTokens + cacheReadTokens.Int()
-					usageJSON, _ = sjson.SetBytes(usageJSON, "cachedContentTokenCount", totalCacheTokens)
+					usageJSON, _ = util.SafeSetJSON(usageJSON, "cachedContentTokenCount", totalCacheTokens)
 				}
 
 				// Add thinking tokens if present (for models with reasoning capabilities)
 				if thinkingTokens := usage.Get("thinking_tokens"); thinkingTokens.Exists() {
-					usageJSON, _ = sjson.SetBytes(usageJSON, "thoughtsTokenCount", thinkingTokens.Int())
+					usageJSON, _ = util.SafeSetJSON(usageJSON, "thoughtsTokenCount", thinkingTokens.Int())
 				}
 
 				// Set traffic type (required by Gemini API)
-				usageJSON, _ = sjson.SetBytes(usageJSON, "trafficType", "PROVISIONED_THROUGHPUT")
+				usageJSON, _ = util.SafeSetJSON(usageJSON, "trafficType", "PROVISIONED_THROUGHPUT")
 
 				finalUsageJSON = usageJSON
 			}
@@ -459,10 +459,10 @@ func ConvertClaudeResponseToGeminiNonStream(_ context.Context, modelName string,
 
 	// Set response metadata
 	if responseID != "" {
-		template, _ = sjson.SetBytes(template, "responseId", responseID)
+		template, _ = util.SafeSetJSON(template, "responseId", responseID)
 	}
 	if createdAt > 0 {
-		template, _ = sjson.SetBytes(template, "createTime", time.Unix(createdAt, 0).Format(time.RFC3339Nano))
+		template, _ = util.SafeSetJSON(template, "createTime", time.Unix(createdAt, 0).Format(time.RFC3339Nano))
 	}
 
 	// Consolidate consecutive text parts and thinking parts for cleaner output
@@ -472,14 +472,14 @@ func ConvertClaudeResponseToGeminiNonStream(_ context.Context, modelName string,
 	if len(consolidatedParts) > 0 {
 		partsJSON := []byte(`[]`)
 		for _, partJSON := range consolidatedParts {
-			partsJSON, _ = sjson.SetRawBytes(partsJSON, "-1", partJSON)
+			partsJSON, _ = util.SafeSetRawJSON(partsJSON, "-1", partJSON)
 		}
-		template, _ = sjson.SetRawBytes(template, "candidates.0.content.parts", partsJSON)
+		template, _ = util.SafeSetRawJSON(template, "candidates.0.content.parts", partsJSON)
 	}
 
 	// Set usage metadata
 	if len(finalUsageJSON) > 0 {
-		template, _ = sjson.SetRawBytes(template, "usageMetadata", finalUsageJSON)
+		template, _ = util.SafeSetRawJSON(template, "usageMetadata", finalUsageJSON)
 	}
 
 	return template
@@ -507,7 +507,7 @@ func consolidateParts(parts [][]byte) [][]byte {
 		// Flush accumulated text content to the consolidated parts array
 		if hasText && currentTextPart.Len() > 0 {
 			textPartJSON := []byte(`{"text":""}`)
-			textPartJSON, _ = sjson.SetBytes(textPartJSON, "text", currentTextPart.String())
+			textPartJSON, _ = util.SafeSetJSON(textPartJSON, "text", currentTextPart.String())
 			consolidated = append(consolidated, textPartJSON)
 			currentTextPart.Reset()
 			hasText = false
@@ -518,7 +518,7 @@ func consolidateParts(parts [][]byte) [][]byte {
 		// Flush accumulated thinking content to the consolidated parts array
 		if hasThought && currentThoughtPart.Len() > 0 {
 			thoughtPartJSON := []byte(`{"thought":true,"text":""}`)
-			thoughtPartJSON, _ = sjson.SetBytes(thoughtPartJSON, "text", currentThoughtPart.String())
+			thoughtPartJSON, _ = util.SafeSetJSON(thoughtPartJSON, "text", currentThoughtPart.String())
 			consolidated = append(consolidated, thoughtPartJSON)
 			currentThoughtPart.Reset()
 			hasThought = false
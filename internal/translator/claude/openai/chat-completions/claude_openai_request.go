@@ -16,8 +16,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 var (
@@ -79,20 +79,20 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 			if supportsAdaptive {
 				switch effort {
 				case "none":
-					out, _ = sjson.SetBytes(out, "thinking.type", "disabled")
-					out, _ = sjson.DeleteBytes(out, "thinking.budget_tokens")
-					out, _ = sjson.DeleteBytes(out, "output_config.effort")
+					out, _ = util.SafeSetJSON(out, "thinking.type", "disabled")
+					out, _ = util.SafeDeleteJSON(out, "thinking.budget_tokens")
+					out, _ = util.SafeDeleteJSON(out, "output_config.effort")
 				case "auto":
-					out, _ = sjson.SetBytes(out, "thinking.type", "adaptive")
-					out, _ = sjson.DeleteBytes(out, "thinking.budget_tokens")
-					out, _ = sjson.DeleteBytes(out, "output_config.effort")
+					out, _ = util.SafeSetJSON(out, "thinking.type", "adaptive")
+					out, _ = util.SafeDeleteJSON(out, "thinking.budget_tokens")
+					out, _ = util.SafeDeleteJSON(out, "output_config.effort")
 				default:
 					if mapped, ok := thinking.MapToClaudeEffort(effort, supportsMax); ok {
 						effort = mapped
 					}
-					out, _ = sjson.SetBytes(out, "thinking.type", "adaptive")
-					out, _ = sjson.DeleteBytes(out, "thinking.budget_tokens")
-					out, _ = sjson.SetBytes(out, "output_config.effort", effort)
+					out, _ = util.SafeSetJSON(out, "thinking.type", "adaptive")
+					out, _ = util.SafeDeleteJSON(out, "thinking.budget_tokens")
+					out, _ = util.SafeSetJSON(out, "output_config.effort", effort)
 				}
 			} else {
 				// Legacy/manual thinking (budget_tokens).
@@ -100,13 +100,13 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 				if ok {
 					switch budget {
 					case 0:
-						out, _ = sjson.SetBytes(out, "thinking.type", "disabled")
+						out, _ = util.SafeSetJSON(out, "thinking.type", "disabled")
 					case -1:
-						out, _ = sjson.SetBytes(out, "thinking.type", "enabled")
+						out, _ = util.SafeSetJSON(out, "thinking.type", "enabled")
 					default:
 						if budget > 0 {
-							out, _ = sjson.SetBytes(out, "thinking.type", "enabled")
-							out, _ = sjson.SetBytes(out, "thinking.budget_tokens", budget)
+							out, _ = util.SafeSetJSON(out, "thinking.type", "enabled")
+							out, _ = util.SafeSetJSON(out, "thinking.budget_tokens", budget)
 						}
 					}
 				}
@@ -128,19 +128,19 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 	}
 
 	// Model mapping to specify which Claude Code model to use
-	out, _ = sjson.SetBytes(out, "model", modelName)
+	out, _ = util.SafeSetJSON(out, "model", modelName)
 
 	// Max tokens configuration with fallback to default value
 	if maxTokens := root.Get("max_tokens"); maxTokens.Exists() {
-		out, _ = sjson.SetBytes(out, "max_tokens", maxTokens.Int())
+		out, _ = util.SafeSetJSON(out, "max_tokens", maxTokens.Int())
 	}
 
 	// Temperature setting for controlling response randomness
 	if temp := root.Get("temperature"); temp.Exists() {
-		out, _ = sjson.SetBytes(out, "temperature", temp.Float())
+		out, _ = util.SafeSetJSON(out, "temperature", temp.Float())
 	} else if topP := root.Get("top_p"); topP.Exists() {
 		// Top P setting for nucleus sampling (filtered out if temperature is set)
-		out, _ = sjson.SetBytes(out, "top_p", topP.Float())
+		out, _ = util.SafeSetJSON(out, "top_p", topP.Float())
 	}
 
 	// Stop sequences configuration for custom termination conditions
@@ -152,15 +152,15 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 				return true
 			})
 			if len(stopSequences) > 0 {
-				out, _ = sjson.SetBytes(out, "stop_sequences", stopSequences)
+				out, _ = util.SafeSetJSON(out, "stop_sequences", stopSequences)
 			}
 		} else {
-			out, _ = sjson.SetBytes(out, "stop_sequences", []string{stop.String()})
+			out, _ = util.SafeSetJSON(out, "stop_sequences", []string{stop.String()})
 		}
 	}
 
 	// Stream configuration to enable or disable streaming responses
-	out, _ = sjson.SetBytes(out, "stream", stream)
+	out, _ = util.SafeSetJSON(out, "stream", stream)
 
 	// Process messages and transform them to Claude Code format
 	if messages := root.Get("messages"); messages.Exists() && messages.IsArray() {
@@ -173,32 +173,32 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 			case "system":
 				if contentResult.Exists() && contentResult.Type == gjson.String && contentResult.String() != "" {
 					textPart := []byte(`{"type":"text","text":""}`)
-					textPart, _ = sjson.SetBytes(textPart, "text", contentResult.String())
-					out, _ = sjson.SetRawBytes(out, "system.-1", textPart)
+					textPart, _ = util.SafeSetJSON(textPart, "text", contentResult.String())
+					out, _ = util.SafeSetRawJSON(out, "system.-1", textPart)
 				} else if contentResult.Exists() && contentResult.IsArray() {
 					contentResult.ForEach(func(_, part gjson.Result) bool {
 						if part.Get("type").String() == "text" {
 							textPart := []byte(`{"type":"text","text":""}`)
-							textPart, _ = sjson.SetBytes(textPart, "text", part.Get("text").String())
-							out, _ = sjson.SetRawBytes(out, "system.-1", textPart)
+							textPart, _ = util.SafeSetJSON(textPart, "text", part.Get("text").String())
+							out, _ = util.SafeSetRawJSON(out, "system.-1", textPart)
 						}
 						return true
 					})
 				}
 			case "user", "assistant":
 				msg := []byte(`{"role":"","content":[]}`)
-				msg, _ = sjson.SetBytes(msg, "role", role)
+				msg, _ = util.SafeSetJSON(msg, "role", role)
 
 				// Handle content based on its type (string or array)
 				if contentResult.Exists() && contentResult.Type == gjson.String && contentResult.String() != "" {
 					part := []byte(`{"type":"text","text":""}`)
-					part, _ = sjson.SetBytes(part, "text", contentResult.String())
-					msg, _ = sjson.SetRawBytes(msg, "content.-1", part)
+					part, _ = util.SafeSetJSON(part, "text", contentResult.String())
+					msg, _ = util.SafeSetRawJSON(msg, "content.-1", part)
 				} else if contentResult.Exists() && contentResult.IsArray() {
 					contentResult.ForEach(func(_, part gjson.Result) bool {
 						claudePart := convertOpenAIContentPartToClaudePart(part)
 						if claudePart != "" {
-							msg, _ = sjson.SetRawBytes(msg, "content.-1", []byte(claudePart))
+							msg, _ = util.SafeSetRawJSON(msg, "content.-1", []byte(claudePart))
 						}
 						return true
 					})
@@ -215,8 +215,8 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 
 							function := toolCall.Get("function")
 							toolUse := []byte(`{"type":"tool_use","id":"","name":"","input":{}}`)
-							toolUse, _ = sjson.SetBytes(toolUse, "id", toolCallID)
-							toolUse, _ = sjson.SetBytes(toolUse, "name", function.Get("name").String())
+							toolUse, _ = util.SafeSetJSON(toolUse, "id", toolCallID)
+							toolUse, _ = util.SafeSetJSON(toolUse, "name", function.Get("name").String())
 
 							// Parse arguments for the tool call
 							if args := function.Get("arguments"); args.Exists() {
@@ -224,24 +224,24 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 								if argsStr != "" && gjson.Valid(argsStr) {
 									argsJSON := gjson.Parse(argsStr)
 									if argsJSON.IsObject() {
-										toolUse, _ = sjson.SetRawBytes(toolUse, "input", []byte(argsJSON.Raw))
+										toolUse, _ = util.SafeSetRawJSON(toolUse, "input", []byte(argsJSON.Raw))
 									} else {
-										toolUse, _ = sjson.SetRawBytes(toolUse, "input", []byte("{}"))
+										toolUse, _ = util.SafeSetRawJSON(toolUse, "input", []byte("{}"))
 									}
 								} else {
-									toolUse, _ = sjson.SetRawBytes(toolUse, "input", []byte("{}"))
+									toolUse, _ = util.SafeSetRawJSON(toolUse, "input", []byte("{}"))
 								}
 							} else {
-								toolUse, _ = sjson.SetRawBytes(toolUse, "input", []byte("{}"))
+								toolUse, _ = util.SafeSetRawJSON(toolUse, "input", []byte("{}"))
 							}
 
-							msg, _ = sjson.SetRawBytes(msg, "content.-1", toolUse)
+							msg, _ = util.SafeSetRawJSON(msg, "content.-1", toolUse)
 						}
 						return true
 					})
 				}
 
-				out, _ = sjson.SetRawBytes(out, "messages.-1", msg)
+				out, _ = util.SafeSetRawJSON(out, "messages.-1", msg)
 				messageIndex++
 
 			case "tool":
@@ -250,14 +250,14 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 				toolContentResult := message.Get("content")
 
 				msg := []byte(`{"role":"user","content":[{"type":"tool_result","tool_use_id":"","content":""}]}`)
-				msg, _ = sjson.SetBytes(msg, "content.0.tool_use_id", toolCallID)
+				msg, _ = util.SafeSetJSON(msg, "content.0.tool_use_id", toolCallID)
 				toolResultContent, toolResultContentRaw := convertOpenAIToolResultContent(toolContentResult)
 				if toolResultContentRaw {
-					msg, _ = sjson.SetRawBytes(msg, "content.0.content", []byte(toolResultContent))
+					msg, _ = util.SafeSetRawJSON(msg, "content.0.content", []byte(toolResultContent))
 				} else {
-					msg, _ = sjson.SetBytes(msg, "content.0.content", toolResultContent)
+					msg, _ = util.SafeSetJSON(msg, "content.0.content", toolResultContent)
 				}
-				out, _ = sjson.SetRawBytes(out, "messages.-1", msg)
+				out, _ = util.SafeSetRawJSON(out, "messages.-1", msg)
 				messageIndex++
 			}
 			return true
@@ -269,7 +269,7 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 			system := gjson.GetBytes(out, "system")
 			if system.Exists() && system.IsArray() && len(system.Array()) > 0 {
 				fallbackMsg := []byte(`{"role":"user","content":[{"type":"text","text":""}]}`)
-				out, _ = sjson.SetRawBytes(out, "messages.-1", fallbackMsg)
+				out, _ = util.SafeSetRawJSON(out, "messages.-1", fallbackMsg)
 			}
 		}
 	}
@@ -281,24 +281,24 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 			if tool.Get("type").String() == "function" {
 				function := tool.Get("function")
 				anthropicTool := []byte(`{"name":"","description":""}`)
-				anthropicTool, _ = sjson.SetBytes(anthropicTool, "name", function.Get("name").String())
-				anthropicTool, _ = sjson.SetBytes(anthropicTool, "description", function.Get("description").String())
+				anthropicTool, _ = util.SafeSetJSON(anthropicTool, "name", function.Get("name").String())
+				anthropicTool, _ = util.SafeSetJSON(anthropicTool, "description", function.Get("description").String())
 
 				// Convert parameters schema for the tool
 				if parameters := function.Get("parameters"); parameters.Exists() {
-					anthropicTool, _ = sjson.SetRawBytes(anthropicTool, "input_schema", []byte(parameters.Raw))
+					anthropicTool, _ = util.SafeSetRawJSON(anthropicTool, "input_schema", []byte(parameters.Raw))
 				} else if parameters := function.Get("parametersJsonSchema"); parameters.Exists() {
-					anthropicTool, _ = sjson.SetRawBytes(anthropicTool, "input_schema", []byte(parameters.Raw))
+					anthropicTool, _ = util.SafeSetRawJSON(anthropicTool, "input_schema", []byte(parameters.Raw))
 				}
 
-				out, _ = sjson.SetRawBytes(out, "tools.-1", anthropicTool)
+				out, _ = util.SafeSetRawJSON(out, "tools.-1", anthropicTool)
 				hasAnthropicTools = true
 			}
 			return true
 		})
 
 		if !hasAnthropicTools {
-			out, _ = sjson.DeleteBytes(out, "tools")
+			out, _ = util.SafeDeleteJSON(out, "tools")
 		}
 	}
 
@@ -311,17 +311,17 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 			case "none":
 				// Don't set tool_choice, Claude Code will not use tools
 			case "auto":
-				out, _ = sjson.SetRawBytes(out, "tool_choice", []byte(`{"type":"auto"}`))
+				out, _ = util.SafeSetRawJSON(out, "tool_choice", []byte(`{"type":"auto"}`))
 			case "required":
-				out, _ = sjson.SetRawBytes(out, "tool_choice", []byte(`{"type":"any"}`))
+				out, _ = util.SafeSetRawJSON(out, "tool_choice", []byte(`{"type":"any"}`))
 			}
 		case gjson.JSON:
 			// Specific tool choice mapping
 			if toolChoice.Get("type").String() == "function" {
 				functionName := toolChoice.Get("function.name").String()
 				toolChoiceJSON := []byte(`{"type":"tool","name":""}`)
-				toolChoiceJSON, _ = sjson.SetBytes(toolChoiceJSON, "name", functionName)
-				out, _ = sjson.SetRawBytes(out, "tool_choice", toolChoiceJSON)
+				toolChoiceJSON, _ = util.SafeSetJSON(toolChoiceJSON, "name", functionName)
+				out, _ = util.SafeSetRawJSON(out, "tool_choice", toolChoiceJSON)
 			}
 		default:
 		}
@@ -334,7 +334,7 @@ func convertOpenAIContentPartToClaudePart(part gjson.Result) string {
 	switch part.Get("type").String() {
 	case "text":
 		textPart := []byte(`{"type":"text","text":""}`)
-		textPart, _ = sjson.SetBytes(textPart, "text", part.Get("text").String())
+		textPart, _ = util.SafeSetJSON(textPart, "text", part.Get("text").String())
 		return string(textPart)
 
 	case "image_url":
@@ -349,8 +349,8 @@ func convertOpenAIContentPartToClaudePart(part gjson.Result) string {
 				mediaType := strings.TrimPrefix(fileData[:semicolonIdx], "data:")
 				data := fileData[commaIdx+1:]
 				docPart := []byte(`{"type":"document","source":{"type":"base64","media_type":"","data":""}}`)
-				docPart, _ = sjson.SetBytes(docPart, "source.media_type", mediaType)
-				docPart, _ = sjson.SetBytes(docPart, "source.data", data)
+				docPart, _ = util.SafeSetJSON(docPart, "source.media_type", mediaType)
+				docPart, _ = util.SafeSetJSON(docPart, "source.data", data)
 				return string(docPart)
 			}
 		}
@@ -377,13 +377,13 @@ func convertOpenAIImageURLToClaudePart(imageURL string) string {
 		}
 
 		imagePart := []byte(`{"type":"image","source":{"type":"base64","media_type":"","data":""}}`)
-		imagePart, _ = sjson.SetBytes(imagePart, "source.media_type", mediaType)
-		imagePart, _ = sjson.SetBytes(imagePart, "source.data", parts[1])
+		imagePart, _ = util.SafeSetJSON(imagePart, "source.media_type", mediaType)
+		imagePart, _ = util.SafeSetJSON(imagePart, "source.data", parts[1])
 		return string(imagePart)
 	}
 
 	imagePart := []byte(`{"type":"image","source":{"type":"url","url":""}}`)
-	imagePart, _ = sjson.SetBytes(imagePart, "source.url", imageURL)
+	imagePart, _ = util.SafeSetJSON(imagePart, "source.url", imageURL)
 	return string(imagePart)
 }
 
@@ -403,15 +403,15 @@ func convertOpenAIToolResultContent(content gjson.Result) (string, bool) {
 		content.ForEach(func(_, part gjson.Result) bool {
 			if part.Type == gjson.String {
 				textPart := []byte(`{"type":"text","text":""}`)
-				textPart, _ = sjson.SetBytes(textPart, "text", part.String())
-				claudeContent, _ = sjson.SetRawBytes(claudeContent, "-1", textPart)
+				textPart, _ = util.SafeSetJSON(textPart, "text", part.String())
+				claudeContent, _ = util.SafeSetRawJSON(claudeContent, "-1", textPart)
 				partCount++
 				return true
 			}
 
 			claudePart := convertOpenAIContentPartToClaudePart(part)
 			if claudePart != "" {
-				claudeContent, _ = sjson.SetRawBytes(claudeContent, "-1", []byte(claudePart))
+				claudeContent, _ = util.SafeSetRawJSON(claudeContent, "-1", []byte(claudePart))
 				partCount++
 			}
 			return true
@@ -428,7 +428,7 @@ func convertOpenAIToolResultContent(content gjson.Result) (string, bool) {
 		claudePart := convertOpenAIContentPartToClaudePart(content)
 		if claudePart != "" {
 			claudeContent := []byte("[]")
-			claudeContent, _ = sjson.SetRawBytes(claudeContent, "-1", []byte(claudePart))
+			claudeContent, _ = util.SafeSetRawJSON(claudeContent, "-1", []byte(claudePart))
 			return string(claudeContent), true
 		}
 		return content.Raw, false
@@ -12,8 +12,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 var (
@@ -87,15 +87,15 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 
 	// Set model
 	if modelName != "" {
-		template, _ = sjson.SetBytes(template, "model", modelName)
+		template, _ = util.SafeSetJSON(template, "model", modelName)
 	}
 
 	// Set response ID and creation time
 	if (*param).(*ConvertAnthropicResponseToOpenAIParams).ResponseID != "" {
-		template, _ = sjson.SetBytes(template, "id", (*param).(*ConvertAnthropicResponseToOpenAIParams).ResponseID)
+		template, _ = util.SafeSetJSON(template, "id", (*param).(*ConvertAnthropicResponseToOpenAIParams).ResponseID)
 	}
 	if (*param).(*ConvertAnthropicResponseToOpenAIParams).CreatedAt > 0 {
-		template, _ = sjson.SetBytes(template, "created", (*param).(*ConvertAnthropicResponseToOpenAIParams).CreatedAt)
+		template, _ = util.SafeSetJSON(template, "created", (*param).(*ConvertAnthropicResponseToOpenAIParams).CreatedAt)
 	}
 
 	switch eventType {
@@ -105,12 +105,12 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 			(*param).(*ConvertAnthropicResponseToOpenAIParams).ResponseID = message.Get("id").String()
 			(*param).(*ConvertAnthropicResponseToOpenAIParams).CreatedAt = time.Now().Unix()
 
-			template, _ = sjson.SetBytes(template, "id", (*param).(*ConvertAnthropicResponseToOpenAIParams).ResponseID)
-			template, _ = sjson.SetBytes(template, "model", modelName)
-			template, _ = sjson.SetBytes(template, "created", (*param).(*ConvertAnthropicResponseToOpenAIParams).CreatedAt)
+			template, _ = util.SafeSetJSON(template, "id", (*param).(*ConvertAnthropicResponseToOpenAIParams).ResponseID)
+			template, _ = util.SafeSetJSON(template, "model", modelName)
+			template, _ = util.SafeSetJSON(template, "created", (*param).(*ConvertAnthropicResponseToOpenAIParams).CreatedAt)
 
 			// Set initial role to assistant for the response
-			template, _ = sjson.SetBytes(template, "choices.0.delta.role", "assistant")
+			template, _ = util.SafeSetJSON(template, "choices.0.delta.role", "assistant")
 
 			// Initialize tool calls accumulator for tracking tool call progress
 			if (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator == nil {
@@ -155,13 +155,13 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 			case "text_delta":
 				// Text content delta - send incremental text updates
 				if text := delta.Get("text"); text.Exists() {
-					template, _ = sjson.SetBytes(template, "choices.0.delta.content", text.String())
+					template, _ = util.SafeSetJSON(template, "choices.0.delta.content", text.String())
 					hasContent = true
 				}
 			case "thinking_delta":
 				// Accumulate reasoning/thinking content
 				if thinking := delta.Get("thinking"); thinking.Exists() {
-					template, _ = sjson.SetBytes(template, "choices.0.delta.reasoning_content", thinking.String())
+					template, _ = util.SafeSetJSON(template, "choices.0.delta.reasoning_content", thinking.String())
 					hasContent = true
 				}
 			case "input_json_delta":
@@ -194,11 +194,11 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 				if arguments == "" {
 					arguments = "{}"
 				}
-				template, _ = sjson.SetBytes(template, "choices.0.delta.tool_calls.0.index", index)
-				template, _ = sjson.SetBytes(template, "choices.0.delta.tool_calls.0.id", accumulator.ID)
-				template, _ = sjson.SetBytes(template, "choices.0.delta.tool_calls.0.type", "function")
-				template, _ = sjson.SetBytes(template, "choices.0.delta.tool_calls.0.function.name", accumulator.Name)
-				template, _ = sjson.SetBytes(template, "choices.0.delta.tool_calls.0.function.arguments", arguments)
+				template, _ = util.SafeSetJSON(template, "choices.0.delta.tool_calls.0.index", index)
+				template, _ = util.SafeSetJSON(template, "choices.0.delta.tool_calls.0.id", accumulator.ID)
+				template, _ = util.SafeSetJSON(template, "choices.0.delta.tool_calls.0.type", "function")
+				template, _ = util.SafeSetJSON(template, "choices.0.delta.tool_calls.0.function.name", accumulator.Name)
+				template, _ = util.SafeSetJSON(template, "choices.0.delta.tool_calls.0.function.arguments", arguments)
 
 				// Clean up the accumulator for this index
 				delete((*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator, index)
@@ -213,17 +213,17 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 		if delta := root.Get("delta"); delta.Exists() {
 			if stopReason := delta.Get("stop_reason"); stopReason.Exists() {
 				(*param).(*ConvertAnthropicResponseToOpenAIParams).FinishReason = mapAnthropicStopReasonToOpenAI(stopReason.String())
-				template, _ = sjson.SetBytes(template, "choices.0.finish_reason", (*param).(*ConvertAnthropicResponseToOpenAIParams).FinishReason)
+				template, _ = util.SafeSetJSON(template, "choices.0.finish_reason", (*param).(*ConvertAnthropicResponseToOpenAIParams).FinishReason)
 			}
 		}
 
 		// Handle usage information for token counts
 		if usage := root.Get("usage"); usage.Exists() {
 			promptTokens, completionTokens, totalTokens, cachedTokens := calculateClaudeUsageTokens(usage)
-			template, _ = sjson.SetBytes(template, "usage.prompt_tokens", promptTokens)
-			template, _ = sjson.SetBytes(template, "usage.completion_tokens", completionTokens)
-			template, _ = sjson.SetBytes(template, "usage.total_tokens", totalTokens)
-			template, _ = sjson.SetBytes(template, "usage.prompt_tokens_details.cached_tokens", cachedTokens)
+			template, _ = util.SafeSetJSON(template, "usage.prompt_tokens", promptTokens)
+			template, _ = util.SafeSetJSON(template, "usage.completion_tokens", completionTokens)
+			template, _ = util.SafeSetJSON(template, "usage.total_tokens", totalTokens)
+			template, _ = util.SafeSetJSON(template, "usage.prompt_tokens_details.cached_tokens", cachedTokens)
 		}
 		return [][]byte{template}
 
@@ -239,8 +239,8 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 		// Error event - format and return error response
 		if errorData := root.Get("error"); errorData.Exists() {
 			errorJSON := []byte(`{"error":{"message":"","type":""}}`)
-			errorJSON, _ = sjson.SetBytes(errorJSON, "error.message", errorData.Get("message").String())
-			errorJSON, _ = sjson.SetBytes(errorJSON, "error.type", errorData.Get("type").String())
+			errorJSON, _ = util.SafeSetJSON(errorJSON, "error.message", errorData.Get("message").String())
+			errorJSON, _ = util.SafeSetJSON(errorJSON, "error.type", errorData.Get("type").String())
 			return [][]byte{errorJSON}
 		}
 		return [][]byte{}
@@ -376,28 +376,28 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 			}
 			if usage := root.Get("usage"); usage.Exists() {
 				promptTokens, completionTokens, totalTokens, cachedTokens := calculateClaudeUsageTokens(usage)
-				out, _ = sjson.SetBytes(out, "usage.prompt_tokens", promptTokens)
-				out, _ = sjson.SetBytes(out, "usage.completion_tokens", completionTokens)
-				out, _ = sjson.SetBytes(out, "usage.total_tokens", totalTokens)
-				out, _ = sjson.SetBytes(out, "usage.prompt_tokens_details.cached_tokens", cachedTokens)
+				out, _ = util.SafeSetJSON(out, "usage.prompt_tokens", promptTokens)
+				out, _ = util.SafeSetJSON(out, "usage.completion_tokens", completionTokens)
+				out, _ = util.SafeSetJSON(out, "usage.total_tokens", totalTokens)
+				out, _ = util.SafeSetJSON(out, "usage.prompt_tokens_details.cached_tokens", cachedTokens)
 			}
 		}
 	}
 
 	// Set basic response fields including message ID, creation time, and model
-	out, _ = sjson.SetBytes(out, "id", messageID)
-	out, _ = sjson.SetBytes(out, "created", createdAt)
-	out, _ = sjson.SetBytes(out, "model", model)
+	out, _ = util.SafeSetJSON(out, "id", messageID)
+	out, _ = util.SafeSetJSON(out, "created", createdAt)
+	out, _ = util.SafeSetJSON(out, "model", model)
 
 	// Set message content by combining all text parts
 	messageContent := strings.Join(contentParts, "")
-	out, _ = sjson.SetBytes(out, "choices.0.message.content", messageContent)
+	out, _ = util.SafeSetJSON(out, "choices.0.message.content", messageContent)
 
 	// Add reasoning content if available (following OpenAI reasoning format)
 	if len(reasoningParts) > 0 {
 		reasoningContent := strings.Join(reasoningParts, "")
 		// Add reasoning as a separate field in the message
-		out, _ = sjson.SetBytes(out, "choices.0.message.reasoning", reasoningContent)
+		out, _ = util.SafeSetJSON(out, "choices.0.message.reasoning", reasoningContent)
 	}
 
 	// Set tool calls if any were accumulated during processing
@@ -423,19 +423,19 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 			namePath := fmt.Sprintf("choices.0.message.tool_calls.%d.function.name", toolCallsCount)
 			argumentsPath := fmt.Sprintf("choices.0.message.tool_calls.%d.function.arguments", toolCallsCount)
 
-			out, _ = sjson.SetBytes(out, idPath, accumulator.ID)
-			out, _ = sjson.SetBytes(out, typePath, "function")
-			out, _ = sjson.SetBytes(out, namePath, accumulator.Name)
-			out, _ = sjson.SetBytes(out, argumentsPath, arguments)
+			out, _ = util.SafeSetJSON(out, idPath, accumulator.ID)
+			out, _ = util.SafeSetJSON(out, typePath, "function")
+			out, _ = util.SafeSetJSON(out, namePath, accumulator.Name)
+			out, _ = util.SafeSetJSON(out, argumentsPath, arguments)
 			toolCallsCount++
 		}
 		if toolCallsCount > 0 {
-			out, _ = sjson.SetBytes(out, "choices.0.finish_reason", "tool_calls")
+			out, _ = util.SafeSetJSON(out, "choices.0.finish_reason", "tool_calls")
 		} else {
-			out, _ = sjson.SetBytes(out, "choices.0.finish_reason", mapAnthropicStopReasonToOpenAI(stopReason))
+			out, _ = util.SafeSetJSON(out, "choices.0.finish_reason", mapAnthropicStopReasonToOpenAI(stopReason))
 		}
 	} else {
-		out, _ = sjson.SetBytes(out, "choices.0.finish_reason", mapAnthropicStopReasonToOpenAI(stopReason))
+		out, _ = util.SafeSetJSON(out, "choices.0.finish_reason", mapAnthropicStopReasonToOpenAI(stopReason))
 	}
 
 	return out
@@ -11,8 +11,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 var (
@@ -67,20 +67,20 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 			if supportsAdaptive {
 				switch effort {
 				case "none":
-					out, _ = sjson.SetBytes(out, "thinking.type", "disabled")
-					out, _ = sjson.DeleteBytes(out, "thinking.budget_tokens")
-					out, _ = sjson.DeleteBytes(out, "output_config.effort")
+					out, _ = util.SafeSetJSON(out, "thinking.type", "disabled")
+					out, _ = util.SafeDeleteJSON(out, "thinking.budget_tokens")
+					out, _ = util.SafeDeleteJSON(out, "output_config.effort")
 				case "auto":
-					out, _ = sjson.SetBytes(out, "thinking.type", "adaptive")
-					out, _ = sjson.DeleteBytes(out, "thinking.budget_tokens")
-					out, _ = sjson.DeleteBytes(out, "output_config.effort")
+					out, _ = util.SafeSetJSON(out, "thinking.type", "adaptive")
+					out, _ = util.SafeDeleteJSON(out, "thinking.budget_tokens")
+					out, _ = util.SafeDeleteJSON(out, "output_config.effort")
 				default:
 					if mapped, ok := thinking.MapToClaudeEffort(effort, supportsMax); ok {
 						effort = mapped
 					}
-					out, _ = sjson.SetBytes(out, "thinking.type", "adaptive")
-					out, _ = sjson.DeleteBytes(out, "thinking.budget_tokens")
-					out, _ = sjson.SetBytes(out, "output_config.effort", effort)
+					out, _ = util.SafeSetJSON(out, "thinking.type", "adaptive")
+					out, _ = util.SafeDeleteJSON(out, "thinking.budget_tokens")
+					out, _ = util.SafeSetJSON(out, "output_config.effort", effort)
 				}
 			} else {
 				// Legacy/manual thinking (budget_tokens).
@@ -88,13 +88,13 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 				if ok {
 					switch budget {
 					case 0:
-						out, _ = sjson.SetBytes(out, "thinking.type", "disabled")
+						out, _ = util.SafeSetJSON(out, "thinking.type", "disabled")
 					case -1:
-						out, _ = sjson.SetBytes(out, "thinking.type", "enabled")
+						out, _ = util.SafeSetJSON(out, "thinking.type", "enabled")
 					default:
 						if budget > 0 {
-							out, _ = sjson.SetBytes(out, "thinking.type", "enabled")
-							out, _ = sjson.SetBytes(out, "thinking.budget_tokens", budget)
+							out, _ = util.SafeSetJSON(out, "thinking.type", "enabled")
+							out, _ = util.SafeSetJSON(out, "thinking.budget_tokens", budget)
 						}
 					}
 				}
@@ -114,15 +114,15 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 	}
 
 	// Model
-	out, _ = sjson.SetBytes(out, "model", modelName)
+	out, _ = util.SafeSetJSON(out, "model", modelName)
 
 	// Max tokens
 	if mot := root.Get("max_output_tokens"); mot.Exists() {
-		out, _ = sjson.SetBytes(out, "max_tokens", mot.Int())
+		out, _ = util.SafeSetJSON(out, "max_tokens", mot.Int())
 	}
 
 	// Stream
-	out, _ = sjson.SetBytes(out, "stream", stream)
+	out, _ = util.SafeSetJSON(out, "stream", stream)
 
 	// instructions -> as a leading message (use role user for Claude API compatibility)
 	instructionsText := ""
@@ -131,8 +131,8 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 		instructionsText = instr.String()
 		if instructionsText != "" {
 			sysMsg := []byte(`{"role":"user","content":""}`)
-			sysMsg, _ = sjson.SetBytes(sysMsg, "content", instructionsText)
-			out, _ = sjson.SetRawBytes(out, "messages.-1", sysMsg)
+			sysMsg, _ = util.SafeSetJSON(sysMsg, "content", instructionsText)
+			out, _ = util.SafeSetRawJSON(out, "messages.-1", sysMsg)
 		}
 	}
 
@@ -157,8 +157,8 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 					instructionsText = builder.String()
 					if instructionsText != "" {
 						sysMsg := []byte(`{"role":"user","content":""}`)
-						sysMsg, _ = sjson.SetBytes(sysMsg, "content", instructionsText)
-						out, _ = sjson.SetRawBytes(out, "messages.-1", sysMsg)
+						sysMsg, _ = util.SafeSetJSON(sysMsg, "content", instructionsText)
+						out, _ = util.SafeSetRawJSON(out, "messages.-1", sysMsg)
 						extractedFromSystem = true
 					}
 				}
@@ -194,7 +194,7 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 								txt := t.String()
 								textAggregate.WriteString(txt)
 								contentPart := []byte(`{"type":"text","text":""}`)
-								contentPart, _ = sjson.SetBytes(contentPart, "text", txt)
+								contentPart, _ = util.SafeSetJSON(contentPart, "text", txt)
 								partsJSON = append(partsJSON, string(contentPart))
 							}
 							if ptype == "input_text" {
@@ -222,12 +222,12 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 									}
 									if data != "" {
 										contentPart = []byte(`{"type":"image","source":{"type":"base64","media_type":"","data":""}}`)
-										contentPart, _ = sjson.SetBytes(contentPart, "source.media_type", mediaType)
-										contentPart, _ = sjson.SetBytes(contentPart, "source.data", data)
+										contentPart, _ = util.SafeSetJSON(contentPart, "source.media_type", mediaType)
+										contentPart, _ = util.SafeSetJSON(contentPart, "source.data", data)
 									}
 								} else {
 									contentPart = []byte(`{"type":"image","source":{"type":"url","url":""}}`)
-									contentPart, _ = sjson.SetBytes(contentPart, "source.url", url)
+									contentPart, _ = util.SafeSetJSON(contentPart, "source.url", url)
 								}
 								if len(contentPart) > 0 {
 									partsJSON = append(partsJSON, string(contentPart))
@@ -253,8 +253,8 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 									}
 								}
 								contentPart := []byte(`{"type":"document","source":{"type":"base64","media_type":"","data":""}}`)
-								contentPart, _ = sjson.SetBytes(contentPart, "source.media_type", mediaType)
-								contentPart, _ = sjson.SetBytes(contentPart, "source.data", data)
+								contentPart, _ = util.SafeSetJSON(contentPart, "source.media_type", mediaType)
+								contentPart, _ = util.SafeSetJSON(contentPart, "source.data", data)
 								partsJSON = append(partsJSON, string(contentPart))
 								if role == "" {
 									role = "user"
@@ -281,23 +281,23 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 
 				if len(partsJSON) > 0 {
 					msg := []byte(`{"role":"","content":[]}`)
-					msg, _ = sjson.SetBytes(msg, "role", role)
+					msg, _ = util.SafeSetJSON(msg, "role", role)
 					if len(partsJSON) == 1 && !hasImage && !hasFile {
 						// Preserve legacy behavior for single text content
-						msg, _ = sjson.DeleteBytes(msg, "content")
+						msg, _ = util.SafeDeleteJSON(msg, "content")
 						textPart := gjson.Parse(partsJSON[0])
-						msg, _ = sjson.SetBytes(msg, "content", textPart.Get("text").String())
+						msg, _ = util.SafeSetJSON(msg, "content", textPart.Get("text").String())
 					} else {
 						for _, partJSON := range partsJSON {
-							msg, _ = sjson.SetRawBytes(msg, "content.-1", []byte(partJSON))
+							msg, _ = util.SafeSetRawJSON(msg, "content.-1", []byte(partJSON))
 						}
 					}
-					out, _ = sjson.SetRawBytes(out, "messages.-1", msg)
+					out, _ = util.SafeSetRawJSON(out, "messages.-1", msg)
 				} else if textAggregate.Len() > 0 || role == "system" {
 					msg := []byte(`{"role":"","content":""}`)
-					msg, _ = sjson.SetBytes(msg, "role", role)
-					msg, _ = sjson.SetBytes(msg, "content", textAggregate.String())
-					out, _ = sjson.SetRawBytes(out, "messages.-1", msg)
+					msg, _ = util.SafeSetJSON(msg, "role", role)
+					msg, _ = util.SafeSetJSON(msg, "content", textAggregate.String())
+					out, _ = util.SafeSetRawJSON(out, "messages.-1", msg)
 				}
 
 			case "function_call":
@@ -310,30 +310,30 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 				argsStr := item.Get("arguments").String()
 
 				toolUse := []byte(`{"type":"tool_use","id":"","name":"","input":{}}`)
-				toolUse, _ = sjson.SetBytes(toolUse, "id", callID)
-				toolUse, _ = sjson.SetBytes(toolUse, "name", name)
+				toolUse, _ = util.SafeSetJSON(toolUse, "id", callID)
+				toolUse, _ = util.SafeSetJSON(toolUse, "name", name)
 				if argsStr != "" && gjson.Valid(argsStr) {
 					argsJSON := gjson.Parse(argsStr)
 					if argsJSON.IsObject() {
-						toolUse, _ = sjson.SetRawBytes(toolUse, "input", []byte(argsJSON.Raw))
+						toolUse, _ = util.SafeSetRawJSON(toolUse, "input", []byte(argsJSON.Raw))
 					}
 				}
 
 				asst := []byte(`{"role":"assistant","content":[]}`)
-				asst, _ = sjson.SetRawBytes(asst, "content.-1", toolUse)
-				out, _ = sjson.SetRawBytes(out, "messages.-1", asst)
+				asst, _ = util.SafeSetRawJSON(asst, "content.-1", toolUse)
+				out, _ = util.SafeSetRawJSON(out, "messages.-1", asst)
 
 			case "function_call_output":
 				// Map to user tool_result
 				callID := item.Get("call_id").String()
 				outputStr := item.Get("output").String()
 				toolResult := []byte(`{"type":"tool_result","tool_use_id":"","content":""}`)
-				toolResult, _ = sjson.SetBytes(toolResult, "tool_use_id", callID)
-				toolResult, _ = sjson.SetBytes(toolResult, "content", outputStr)
+				toolResult, _ = util.SafeSetJSON(toolResult, "tool_use_id", callID)
+				toolResult, _ = util.SafeSetJSON(toolResult, "content", outputStr)
 
 				usr := []byte(`{"role":"user","content":[]}`)
-				usr, _ = sjson.SetRawBytes(usr, "content.-1", toolResult)
-				out, _ = sjson.SetRawBytes(out, "messages.-1", usr)
+				usr, _ = util.SafeSetRawJSON(usr, "content.-1", toolResult)
+				out, _ = util.SafeSetRawJSON(out, "messages.-1", usr)
 			}
 			return true
 		})
@@ -352,12 +352,12 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 				if toolName != "" {
 					includedToolNames[toolName] = struct{}{}
 				}
-				toolsJSON, _ = sjson.SetRawBytes(toolsJSON, "-1", tJSON)
+				toolsJSON, _ = util.SafeSetRawJSON(toolsJSON, "-1", tJSON)
 			}
 			return true
 		})
 		if parsedTools := gjson.ParseBytes(toolsJSON); parsedTools.IsArray() && len(parsedTools.Array()) > 0 {
-			out, _ = sjson.SetRawBytes(out, "tools", toolsJSON)
+			out, _ = util.SafeSetRawJSON(out, "tools", toolsJSON)
 		}
 	}
 
@@ -367,12 +367,12 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 		case gjson.String:
 			switch toolChoice.String() {
 			case "auto":
-				out, _ = sjson.SetRawBytes(out, "tool_choice", []byte(`{"type":"auto"}`))
+				out, _ = util.SafeSetRawJSON(out, "tool_choice", []byte(`{"type":"auto"}`))
 			case "none":
 				// Leave unset; implies no tools
 			case "required":
 				if len(includedToolNames) > 0 {
-					out, _ = sjson.SetRawBytes(out, "tool_choice", []byte(`{"type":"any"}`))
+					out, _ = util.SafeSetRawJSON(out, "tool_choice", []byte(`{"type":"any"}`))
 				}
 			}
 		case gjson.JSON:
@@ -386,8 +386,8 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 				}
 				if _, ok := includedToolNames[fn]; ok {
 					toolChoiceJSON := []byte(`{"name":"","type":"tool"}`)
-					toolChoiceJSON, _ = sjson.SetBytes(toolChoiceJSON, "name", fn)
-					out, _ = sjson.SetRawBytes(out, "tool_choice", toolChoiceJSON)
+					toolChoiceJSON, _ = util.SafeSetJSON(toolChoiceJSON, "name", fn)
+					out, _ = util.SafeSetRawJSON(out, "tool_choice", toolChoiceJSON)
 				}
 			}
 		default:
@@ -458,11 +458,11 @@ func convertResponsesFunctionToolToClaude(tool gjson.Result, overrideName string
 	}
 
 	tJSON := []byte(`{"name":"","description":"","input_schema":{}}`)
-	tJSON, _ = sjson.SetBytes(tJSON, "name", name)
+	tJSON, _ = util.SafeSetJSON(tJSON, "name", name)
 	if d := responsesToolDescription(tool); d != "" {
-		tJSON, _ = sjson.SetBytes(tJSON, "description", d)
+		tJSON, _ = util.SafeSetJSON(tJSON, "description", d)
 	}
-	tJSON, _ = sjson.SetRawBytes(tJSON, "input_schema", normalizeClaudeToolInputSchema(responsesToolParameters(tool)))
+	tJSON, _ = util.SafeSetRawJSON(tJSON, "input_schema", normalizeClaudeToolInputSchema(responsesToolParameters(tool)))
 	return tJSON, true
 }
 
@@ -476,15 +476,15 @@ func convertResponsesWebSearchToolToClaude(tool gjson.Result) ([]byte, bool) {
 		name = "web_search"
 	}
 	tJSON := []byte(`{"type":"web_search_20250305","name":""}`)
-	tJSON, _ = sjson.SetBytes(tJSON, "name", name)
+	tJSON, _ = util.SafeSetJSON(tJSON, "name", name)
 	if maxUses := tool.Get("max_uses"); maxUses.Exists() {
-		tJSON, _ = sjson.SetBytes(tJSON, "max_uses", maxUses.Int())
+		tJSON, _ = util.SafeSetJSON(tJSON, "max_uses", maxUses.Int())
 	}
 	if allowedDomains := tool.Get("filters.allowed_domains"); allowedDomains.Exists() && allowedDomains.IsArray() {
-		tJSON, _ = sjson.SetRawBytes(tJSON, "allowed_domains", []byte(allowedDomains.Raw))
+		tJSON, _ = util.SafeSetRawJSON(tJSON, "allowed_domains", []byte(allowedDomains.Raw))
 	}
 	if userLocation := tool.Get("user_location"); userLocation.Exists() && userLocation.IsObject() {
-		tJSON, _ = sjson.SetRawBytes(tJSON, "user_location", []byte(userLocation.Raw))
+		tJSON, _ = util.SafeSetRawJSON(tJSON, "user_location", []byte(userLocation.Raw))
 	}
 	return tJSON, true
 }
@@ -530,11 +530,11 @@ func normalizeClaudeToolInputSchema(parameters gjson.Result) []byte {
 	schema := []byte(raw)
 	schemaType := result.Get("type").String()
 	if schemaType == "" {
-		schema, _ = sjson.SetBytes(schema, "type", "object")
+		schema, _ = util.SafeSetJSON(schema, "type", "object")
 		schemaType = "object"
 	}
 	if schemaType == "object" && !result.Get("properties").Exists() {
-		schema, _ = sjson.SetRawBytes(schema, "properties", []byte(`{}`))
+		schema, _ = util.SafeSetRawJSON(schema, "properties", []byte(`{}`))
 	}
 	return schema
 }
@@ -9,8 +9,8 @@ import (
 	"time"
 
 	translatorcommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/common"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 type claudeToResponsesState struct {
@@ -109,15 +109,15 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 			}
 			// response.created
 			created := []byte(`{"type":"response.created","sequence_number":0,"response":{"id":"","object":"response","created_at":0,"status":"in_progress","background":false,"error":null,"output":[]}}`)
-			created, _ = sjson.SetBytes(created, "sequence_number", nextSeq())
-			created, _ = sjson.SetBytes(created, "response.id", st.ResponseID)
-			created, _ = sjson.SetBytes(created, "response.created_at", st.CreatedAt)
+			created, _ = util.SafeSetJSON(created, "sequence_number", nextSeq())
+			created, _ = util.SafeSetJSON(created, "response.id", st.ResponseID)
+			created, _ = util.SafeSetJSON(created, "response.created_at", st.CreatedAt)
 			out = append(out, emitEvent("response.created", created))
 			// response.in_progress
 			inprog := []byte(`{"type":"response.in_progress","sequence_number":0,"response":{"id":"","object":"response","created_at":0,"status":"in_progress"}}`)
-			inprog, _ = sjson.SetBytes(inprog, "sequence_number", nextSeq())
-			inprog, _ = sjson.SetBytes(inprog, "response.id", st.ResponseID)
-			inprog, _ = sjson.SetBytes(inprog, "response.created_at", st.CreatedAt)
+			inprog, _ = util.SafeSetJSON(inprog, "sequence_number", nextSeq())
+			inprog, _ = util.SafeSetJSON(inprog, "response.id", st.ResponseID)
+			inprog, _ = util.SafeSetJSON(inprog, "response.created_at", st.CreatedAt)
 			out = append(out, emitEvent("response.in_progress", inprog))
 		}
 	case "content_block_start":
@@ -133,24 +133,24 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 			st.CurrentTextBuf.Reset()
 			st.CurrentMsgID = fmt.Sprintf("msg_%s_0", st.ResponseID)
 			item := []byte(`{"type":"response.output_item.added","sequence_number":0,"output_index":0,"item":{"id":"","type":"message","status":"in_progress","content":[],"role":"assistant"}}`)
-			item, _ = sjson.SetBytes(item, "sequence_number", nextSeq())
-			item, _ = sjson.SetBytes(item, "item.id", st.CurrentMsgID)
+			item, _ = util.SafeSetJSON(item, "sequence_number", nextSeq())
+			item, _ = util.SafeSetJSON(item, "item.id", st.CurrentMsgID)
 			out = append(out, emitEvent("response.output_item.added", item))
 
 			part := []byte(`{"type":"response.content_part.added","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"part":{"type":"output_text","annotations":[],"logprobs":[],"text":""}}`)
-			part, _ = sjson.SetBytes(part, "sequence_number", nextSeq())
-			part, _ = sjson.SetBytes(part, "item_id", st.CurrentMsgID)
+			part, _ = util.SafeSetJSON(part, "sequence_number", nextSeq())
+			part, _ = util.SafeSetJSON(part, "item_id", st.CurrentMsgID)
 			out = append(out, emitEvent("response.content_part.added", part))
 		} else if typ == "tool_use" {
 			st.InFuncBlock = true
 			st.CurrentFCID = cb.Get("id").String()
 			name := cb.Get("name").String()
 			item := []byte(`{"type":"response.output_item.added","sequence_number":0,"output_index":0,"item":{"id":"","type":"function_call","status":"in_progress","arguments":"","call_id":"","name":""}}`)
-			item, _ = sjson.SetBytes(item, "sequence_number", nextSeq())
-			item, _ = sjson.SetBytes(item, "output_index", idx)
-			item, _ = sjson.SetBytes(item, "item.id", fmt.Sprintf("fc_%s", st.CurrentFCID))
-			item, _ = sjson.SetBytes(item, "item.call_id", st.CurrentFCID)
-			item, _ = sjson.SetBytes(item, "item.name", name)
+			item, _ = util.SafeSetJSON(item, "sequence_number", nextSeq())
+			item, _ = util.SafeSetJSON(item, "output_index", idx)
+			item, _ = util.SafeSetJSON(item, "item.id", fmt.Sprintf("fc_%s", st.CurrentFCID))
+			item, _ = util.SafeSetJSON(item, "item.call_id", st.CurrentFCID)
+			item, _ = util.SafeSetJSON(item, "item.name", name)
 			out = append(out, emitEvent("response.output_item.added", item))
 			if st.FuncArgsBuf[idx] == nil {
 				st.FuncArgsBuf[idx] = &strings.Builder{}
@@ -165,15 +165,15 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 			st.ReasoningBuf.Reset()
 			st.ReasoningItemID = fmt.Sprintf("rs_%s_%d", st.ResponseID, idx)
 			item := []byte(`{"type":"response.output_item.added","sequence_number":0,"output_index":0,"item":{"id":"","type":"reasoning","status":"in_progress","summary":[]}}`)
-			item, _ = sjson.SetBytes(item, "sequence_number", nextSeq())
-			item, _ = sjson.SetBytes(item, "output_index", idx)
-			item, _ = sjson.SetBytes(item, "item.id", st.ReasoningItemID)
+			item, _ = util.SafeSetJSON(item, "sequence_number", nextSeq())
+			item, _ = util.SafeSetJSON(item, "output_index", idx)
+			item, _ = util.SafeSetJSON(item, "item.id", st.ReasoningItemID)
 			out = append(out, emitEvent("response.output_item.added", item))
 			// add a summary part placeholder
 			part := []byte(`{"type":"response.reasoning_summary_part.added","sequence_number":0,"item_id":"","output_index":0,"summary_index":0,"part":{"type":"summary_text","text":""}}`)
-			part, _ = sjson.SetBytes(part, "sequence_number", nextSeq())
-			part, _ = sjson.SetBytes(part, "item_id", st.ReasoningItemID)
-			part, _ = sjson.SetBytes(part, "output_index", idx)
+			part, _ = util.SafeSetJSON(part, "sequence_number", nextSeq())
+			part, _ = util.SafeSetJSON(part, "item_id", st.ReasoningItemID)
+			part, _ = util.SafeSetJSON(part, "output_index", idx)
 			out = append(out, emitEvent("response.reasoning_summary_part.added", part))
 			st.ReasoningPartAdded = true
 		}
@@ -186,9 +186,9 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 		if dt == "text_delta" {
 			if t := d.Get("text"); t.Exists() {
 				msg := []byte(`{"type":"response.output_text.delta","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"delta":"","logprobs":[]}`)
-				msg, _ = sjson.SetBytes(msg, "sequence_number", nextSeq())
-				msg, _ = sjson.SetBytes(msg, "item_id", st.CurrentMsgID)
-				msg, _ = sjson.SetBytes(msg, "delta", t.String())
+				msg, _ = util.SafeSetJSON(msg, "sequence_number", nextSeq())
+				msg, _ = util.SafeSetJSON(msg, "item_id", st.CurrentMsgID)
+				msg, _ = util.SafeSetJSON(msg, "delta", t.String())
 				out = append(out, emitEvent("response.output_text.delta", msg))
 				// aggregate text for response.output
 				st.TextBuf.WriteString(t.String())
@@ -202,10 +202,10 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 				}
 				st.FuncArgsBuf[idx].WriteString(pj.String())
 				msg := []byte(`{"type":"response.function_call_arguments.delta","sequence_number":0,"item_id":"","output_index":0,"delta":""}`)
-				msg, _ = sjson.SetBytes(msg, "sequence_number", nextSeq())
-				msg, _ = sjson.SetBytes(msg, "item_id", fmt.Sprintf("fc_%s", st.CurrentFCID))
-				msg, _ = sjson.SetBytes(msg, "output_index", idx)
-				msg, _ = sjson.SetBytes(msg, "delta", pj.String())
+				msg, _ = util.SafeSetJSON(msg, "sequence_number", nextSeq())
+				msg, _ = util.SafeSetJSON(msg, "item_id", fmt.Sprintf("fc_%s", st.CurrentFCID))
+				msg, _ = util.SafeSetJSON(msg, "output_index", idx)
+				msg, _ = util.SafeSetJSON(msg, "delta", pj.String())
 				out = append(out, emitEvent("response.function_call_arguments.delta", msg))
 			}
 		} else if dt == "thinking_delta" {
@@ -213,10 +213,10 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 				if t := d.Get("thinking"); t.Exists() {
 					st.ReasoningBuf.WriteString(t.String())
 					msg := []byte(`{"type":"response.reasoning_summary_text.delta","sequence_number":0,"item_id":"","output_index":0,"summary_index":0,"delta":""}`)
-					msg, _ = sjson.SetBytes(msg, "sequence_number", nextSeq())
-					msg, _ = sjson.SetBytes(msg, "item_id", st.ReasoningItemID)
-					msg, _ = sjson.SetBytes(msg, "output_index", st.ReasoningIndex)
-					msg, _ = sjson.SetBytes(msg, "delta", t.String())
+					msg, _ = util.SafeSetJSON(msg, "sequence_number", nextSeq())
+					msg, _ = util.SafeSetJSON(msg, "item_id", st.ReasoningItemID)
+					msg, _ = util.SafeSetJSON(msg, "output_index", st.ReasoningIndex)
+					msg, _ = util.SafeSetJSON(msg, "delta", t.String())
 					out = append(out, emitEvent("response.reasoning_summary_text.delta", msg))
 				}
 			}
@@ -226,19 +226,19 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 		if st.InTextBlock {
 			fullText := st.CurrentTextBuf.String()
 			done := []byte(`{"type":"response.output_text.done","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"text":"","logprobs":[]}`)
-			done, _ = sjson.SetBytes(done, "sequence_number", nextSeq())
-			done, _ = sjson.SetBytes(done, "item_id", st.CurrentMsgID)
-			done, _ = sjson.SetBytes(done, "text", fullText)
+			done, _ = util.SafeSetJSON(done, "sequence_number", nextSeq())
+			done, _ = util.SafeSetJSON(done, "item_id", st.CurrentMsgID)
+			done, _ = util.SafeSetJSON(done, "text", fullText)
 			out = append(out, emitEvent("response.output_text.done", done))
 			partDone := []byte(`{"type":"response.content_part.done","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"part":{"type":"output_text","annotations":[],"logprobs":[],"text":""}}`)
-			partDone, _ = sjson.SetBytes(partDone, "sequence_number", nextSeq())
-			partDone, _ = sjson.SetBytes(partDone, "item_id", st.CurrentMsgID)
-			partDone, _ = sjson.SetBytes(partDone, "part.text", fullText)
+			partDone, _ = util.SafeSetJSON(partDone, "sequence_number", nextSeq())
+			partDone, _ = util.SafeSetJSON(partDone, "item_id", st.CurrentMsgID)
+			partDone, _ = util.SafeSetJSON(partDone, "part.text", fullText)
 			out = append(out, emitEvent("response.content_part.done", partDone))
 			final := []byte(`{"type":"response.output_item.done","sequence_number":0,"output_index":0,"item":{"id":"","type":"message","status":"completed","content":[{"type":"output_text","text":""}],"role":"assistant"}}`)
-			final, _ = sjson.SetBytes(final, "sequence_number", nextSeq())
-			final, _ = sjson.SetBytes(final, "item.id", st.CurrentMsgID)
-			final, _ = sjson.SetBytes(final, "item.content.0.text", fullText)
+			final, _ = util.SafeSetJSON(final, "sequence_number", nextSeq())
+			final, _ = util.SafeSetJSON(final, "item.id", st.CurrentMsgID)
+			final, _ = util.SafeSetJSON(final, "item.content.0.text", fullText)
 			out = append(out, emitEvent("response.output_item.done", final))
 			st.InTextBlock = false
 		} else if st.InFuncBlock {
@@ -249,33 +249,33 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 				}
 			}
 			fcDone := []byte(`{"type":"response.function_call_arguments.done","sequence_number":0,"item_id":"","output_index":0,"arguments":""}`)
-			fcDone, _ = sjson.SetBytes(fcDone, "sequence_number", nextSeq())
-			fcDone, _ = sjson.SetBytes(fcDone, "item_id", fmt.Sprintf("fc_%s", st.CurrentFCID))
-			fcDone, _ = sjson.SetBytes(fcDone, "output_index", idx)
-			fcDone, _ = sjson.SetBytes(fcDone, "arguments", args)
+			fcDone, _ = util.SafeSetJSON(fcDone, "sequence_number", nextSeq())
+			fcDone, _ = util.SafeSetJSON(fcDone, "item_id", fmt.Sprintf("fc_%s", st.CurrentFCID))
+			fcDone, _ = util.SafeSetJSON(fcDone, "output_index", idx)
+			fcDone, _ = util.SafeSetJSON(fcDone, "arguments", args)
 			out = append(out, emitEvent("response.function_call_arguments.done", fcDone))
 			itemDone := []byte(`{"type":"response.output_item.done","sequence_number":0,"output_index":0,"item":{"id":"","type":"function_call","status":"completed","arguments":"","call_id":"","name":""}}`)
-			itemDone, _ = sjson.SetBytes(itemDone, "sequence_number", nextSeq())
-			itemDone, _ = sjson.SetBytes(itemDone, "output_index", idx)
-			itemDone, _ = sjson.SetBytes(itemDone, "item.id", fmt.Sprintf("fc_%s", st.CurrentFCID))
-			itemDone, _ = sjson.SetBytes(itemDone, "item.arguments", args)
-			itemDone, _ = sjson.SetBytes(itemDone, "item.call_id", st.CurrentFCID)
-			itemDone, _ = sjson.SetBytes(itemDone, "item.name", st.FuncNames[idx])
+			itemDone, _ = util.SafeSetJSON(itemDone, "sequence_number", nextSeq())
+			itemDone, _ = util.SafeSetJSON(itemDone, "output_index", idx)
+			itemDone, _ = util.SafeSetJSON(itemDone, "item.id", fmt.Sprintf("fc_%s", st.CurrentFCID))
+			itemDone, _ = util.SafeSetJSON(itemDone, "item.arguments", args)
+			itemDone, _ = util.SafeSetJSON(itemDone, "item.call_id", st.CurrentFCID)
+			itemDone, _ = util.SafeSetJSON(itemDone, "item.name", st.FuncNames[idx])
 			out = append(out, emitEvent("response.output_item.done", itemDone))
 			st.InFuncBlock = false
 		} else if st.ReasoningActive {
 			full := st.ReasoningBuf.String()
 			textDone := []byte(`{"type":"response.reasoning_summary_text.done","sequence_number":0,"item_id":"","output_index":0,"summary_index":0,"text":""}`)
-			textDone, _ = sjson.SetBytes(textDone, "sequence_number", nextSeq())
-			textDone, _ = sjson.SetBytes(textDone, "item_id", st.ReasoningItemID)
-			textDone, _ = sjson.SetBytes(textDone, "output_index", st.ReasoningIndex)
-			textDone, _ = sjson.SetBytes(textDone, "text", full)
+			textDone, _ = util.SafeSetJSON(textDone, "sequence_number", nextSeq())
+			textDone, _ = util.SafeSetJSON(textDone, "item_id", st.ReasoningItemID)
+			textDone, _ = util.SafeSetJSON(textDone, "output_index", st.ReasoningIndex)
+			textDone, _ = util.SafeSetJSON(textDone, "text", full)
 			out = append(out, emitEvent("response.reasoning_summary_text.done", textDone))
 			partDone := []byte(`{"type":"response.reasoning_summary_part.done","sequence_number":0,"item_id":"","output_index":0,"summary_index":0,"part":{"type":"summary_text","text":""}}`)
-			partDone, _ = sjson.SetBytes(partDone, "sequence_number", nextSeq())
-			partDone, _ = sjson.SetBytes(partDone, "item_id", st.ReasoningItemID)
-			partDone, _ = sjson.SetBytes(partDone, "output_index", st.ReasoningIndex)
-			partDone, _ = sjson.SetBytes(partDone, "part.text", full)
+			partDone, _ = util.SafeSetJSON(partDone, "sequence_number", nextSeq())
+			partDone, _ = util.SafeSetJSON(partDone, "item_id", st.ReasoningItemID)
+			partDone, _ = util.SafeSetJSON(partDone, "output_index", st.ReasoningIndex)
+			partDone, _ = util.SafeSetJSON(partDone, "part.text", full)
 			out = append(out, emitEvent("response.reasoning_summary_part.done", partDone))
 			st.ReasoningActive = false
 			st.ReasoningPartAdded = false
@@ -294,73 +294,73 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 	case "message_stop":
 
 		completed := []byte(`{"type":"response.completed","sequence_number":0,"response":{"id":"","object":"response","created_at":0,"status":"completed","background":false,"error":null}}`)
-		completed, _ = sjson.SetBytes(completed, "sequence_number", nextSeq())
-		completed, _ = sjson.SetBytes(completed, "response.id", st.ResponseID)
-		completed, _ = sjson.SetBytes(completed, "response.created_at", st.CreatedAt)
+		completed, _ = util.SafeSetJSON(completed, "sequence_number", nextSeq())
+		completed, _ = util.SafeSetJSON(completed, "response.id", st.ResponseID)
+		completed, _ = util.SafeSetJSON(completed, "response.created_at", st.CreatedAt)
 		// Inject original request fields into response as per docs/response.completed.json
 
 		reqBytes := pickRequestJSON(originalRequestRawJSON, requestRawJSON)
 		if len(reqBytes) > 0 {
 			req := gjson.ParseBytes(reqBytes)
 			if v := req.Get("instructions"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.instructions", v.String())
+				completed, _ = util.SafeSetJSON(completed, "response.instructions", v.String())
 			}
 			if v := req.Get("max_output_tokens"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.max_output_tokens", v.Int())
+				completed, _ = util.SafeSetJSON(completed, "response.max_output_tokens", v.Int())
 			}
 			if v := req.Get("max_tool_calls"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.max_tool_calls", v.Int())
+				completed, _ = util.SafeSetJSON(completed, "response.max_tool_calls", v.Int())
 			}
 			if v := req.Get("model"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.model", v.String())
+				completed, _ = util.SafeSetJSON(completed, "response.model", v.String())
 			}
 			if v := req.Get("parallel_tool_calls"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.parallel_tool_calls", v.Bool())
+				completed, _ = util.SafeSetJSON(completed, "response.parallel_tool_calls", v.Bool())
 			}
 			if v := req.Get("previous_response_id"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.previous_response_id", v.String())
+				completed, _ = util.SafeSetJSON(completed, "response.previous_response_id", v.String())
 			}
 			if v := req.Get("prompt_cache_key"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.prompt_cache_key", v.String())
+				completed, _ = util.SafeSetJSON(completed, "response.prompt_cache_key", v.String())
 			}
 			if v := req.Get("reasoning"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.reasoning", v.Value())
+				completed, _ = util.SafeSetJSON(completed, "response.reasoning", v.Value())
 			}
 			if v := req.Get("safety_identifier"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.safety_identifier", v.String())
+				completed, _ = util.SafeSetJSON(completed, "response.safety_identifier", v.String())
 			}
 			if v := req.Get("service_tier"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.service_tier", v.String())
+				completed, _ = util.SafeSetJSON(completed, "response.service_tier", v.String())
 			}
 			if v := req.Get("store"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.store", v.Bool())
+				completed, _ = util.SafeSetJSON(completed, "response.store", v.Bool())
 			}
 			if v := req.Get("temperature"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.temperature", v.Float())
+				completed, _ = util.SafeSetJSON(completed, "response.temperature", v.Float())
 			}
 			if v := req.Get("text"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.text", v.Value())
+				completed, _ = util.SafeSetJSON(completed, "response.text", v.Value())
 			}
 			if v := req.Get("tool_choice"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.tool_choice", v.Value())
+				completed, _ = util.SafeSetJSON(completed, "response.tool_choice", v.Value())
 			}
 			if v := req.Get("tools"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.tools", v.Value())
+				completed, _ = util.SafeSetJSON(completed, "response.tools", v.Value())
 			}
 			if v := req.Get("top_logprobs"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.top_logprobs", v.Int())
+				completed, _ = util.SafeSetJSON(completed, "response.top_logprobs", v.Int())
 			}
 			if v := req.Get("top_p"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.top_p", v.Float())
+				completed, _ = util.SafeSetJSON(completed, "response.top_p", v.Float())
 			}
 			if v := req.Get("truncation"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.truncation", v.String())
+				completed, _ = util.SafeSetJSON(completed, "response.truncation", v.String())
 			}
 			if v := req.Get("user"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.user", v.Value())
+				completed, _ = util.SafeSetJSON(completed, "response.user", v.Value())
 			}
 			if v := req.Get("metadata"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.metadata", v.Value())
+				completed, _ = util.SafeSetJSON(completed, "response.metadata", v.Value())
 			}
 		}
 
@@ -369,16 +369,16 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 		// reasoning item (if any)
 		if st.ReasoningBuf.Len() > 0 || st.ReasoningPartAdded {
 			item := []byte(`{"id":"","type":"reasoning","summary":[{"type":"summary_text","text":""}]}`)
-			item, _ = sjson.SetBytes(item, "id", st.ReasoningItemID)
-			item, _ = sjson.SetBytes(item, "summary.0.text", st.ReasoningBuf.String())
-			outputsWrapper, _ = sjson.SetRawBytes(outputsWrapper, "arr.-1", item)
+			item, _ = util.SafeSetJSON(item, "id", st.ReasoningItemID)
+			item, _ = util.SafeSetJSON(item, "summary.0.text", st.ReasoningBuf.String())
+			outputsWrapper, _ = util.SafeSetRawJSON(outputsWrapper, "arr.-1", item)
 		}
 		// assistant message item (if any text)
 		if st.TextBuf.Len() > 0 || st.InTextBlock || st.CurrentMsgID != "" {
 			item := []byte(`{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}`)
-			item, _ = sjson.SetBytes(item, "id", st.CurrentMsgID)
-			item, _ = sjson.SetBytes(item, "content.0.text", st.TextBuf.String())
-			outputsWrapper, _ = sjson.SetRawBytes(outputsWrapper, "arr.-1", item)
+			item, _ = util.SafeSetJSON(item, "id", st.CurrentMsgID)
+			item, _ = util.SafeSetJSON(item, "content.0.text", st.TextBuf.String())
+			outputsWrapper, _ = util.SafeSetRawJSON(outputsWrapper, "arr.-1", item)
 		}
 		// function_call items (in ascending index order for determinism)
 		if len(st.FuncArgsBuf) > 0 {
@@ -406,15 +406,15 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 					callID = st.CurrentFCID
 				}
 				item := []byte(`{"id":"","type":"function_call","status":"completed","arguments":"","call_id":"","name":""}`)
-				item, _ = sjson.SetBytes(item, "id", fmt.Sprintf("fc_%s", callID))
-				item, _ = sjson.SetBytes(item, "arguments", args)
-				item, _ = sjson.SetBytes(item, "call_id", callID)
-				item, _ = sjson.SetBytes(item, "name", name)
-				outputsWrapper, _ = sjson.SetRawBytes(outputsWrapper, "arr.-1", item)
+				item, _ = util.SafeSetJSON(item, "id", fmt.Sprintf("fc_%s", callID))
+				item, _ = util.SafeSetJSON(item, "arguments", args)
+				item, _ = util.SafeSetJSON(item, "call_id", callID)
+				item, _ = util.SafeSetJSON(item, "name", name)
+				outputsWrapper, _ = util.SafeSetRawJSON(outputsWrapper, "arr.-1", item)
 			}
 		}
 		if gjson.GetBytes(outputsWrapper, "arr.#").Int() > 0 {
-			completed, _ = sjson.SetRawBytes(completed, "response.output", []byte(gjson.GetBytes(outputsWrapper, "arr").Raw))
+			completed, _ = util.SafeSetRawJSON(completed, "response.output", []byte(gjson.GetBytes(outputsWrapper, "arr").Raw))
 		}
 
 		reasoningTokens := int64(0)
@@ -423,15 +423,15 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 		}
 		usagePresent := st.UsageSeen || reasoningTokens > 0
 		if usagePresent {
-			completed, _ = sjson.SetBytes(completed, "response.usage.input_tokens", st.InputTokens)
-			completed, _ = sjson.SetBytes(completed, "response.usage.input_tokens_details.cached_tokens", 0)
-			completed, _ = sjson.SetBytes(completed, "response.usage.output_tokens", st.OutputTokens)
+			completed, _ = util.SafeSetJSON(completed, "response.usage.input_tokens", st.InputTokens)
+			completed, _ = util.SafeSetJSON(completed, "response.usage.input_tokens_details.cached_tokens", 0)
+			completed, _ = util.SafeSetJSON(completed, "response.usage.output_tokens", st.OutputTokens)
 			if reasoningTokens > 0 {
-				completed, _ = sjson.SetBytes(completed, "response.usage.output_tokens_details.reasoning_tokens", reasoningTokens)
+				completed, _ = util.SafeSetJSON(completed, "response.usage.output_tokens_details.reasoning_tokens", reasoningTokens)
 			}
 			total := st.InputTokens + st.OutputTokens
 			if total > 0 || st.UsageSeen {
-				completed, _ = sjson.SetBytes(completed, "response.usage.total_tokens", total)
+				completed, _ = util.SafeSetJSON(completed, "response.usage.total_tokens", total)
 			}
 		}
 		out = append(out, emitEvent("response.completed", completed))
@@ -566,72 +566,72 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 	}
 
 	// Populate base fields
-	out, _ = sjson.SetBytes(out, "id", responseID)
-	out, _ = sjson.SetBytes(out, "created_at", createdAt)
+	out, _ = util.SafeSetJSON(out, "id", responseID)
+	out, _ = util.SafeSetJSON(out, "created_at", createdAt)
 
 	// Inject request echo fields as top-level (similar to streaming variant)
 	reqBytes := pickRequestJSON(originalRequestRawJSON, requestRawJSON)
 	if len(reqBytes) > 0 {
 		req := gjson.ParseBytes(reqBytes)
 		if v := req.Get("instructions"); v.Exists() {
-			out, _ = sjson.SetBytes(out, "instructions", v.String())
+			out, _ = util.SafeSetJSON(out, "instructions", v.String())
 		}
 		if v := req.Get("max_output_tokens"); v.Exists() {
-			out, _ = sjson.SetBytes(out, "max_output_tokens", v.Int())
+			out, _ = util.SafeSetJSON(out, "max_output_tokens", v.Int())
 		}
 		if v := req.Get("max_tool_calls"); v.Exists() {
-			out, _ = sjson.SetBytes(out, "max_tool_calls", v.Int())
+			out, _ = util.SafeSetJSON(out, "max_tool_calls", v.Int())
 		}
 		if v := req.Get("model"); v.Exists() {
-			out, _ = sjson.SetBytes(out, "model", v.String())
+			out, _ = util.SafeSetJSON(out, "model", v.String())
 		}
 		if v := req.Get("parallel_tool_calls"); v.Exists() {
-			out, _ = sjson.SetBytes(out, "parallel_tool_calls", v.Bool())
+			out, _ = util.SafeSetJSON(out, "parallel_tool_calls", v.Bool())
 		}
 		if v := req.Get("previous_response_id"); v.Exists() {
-			out, _ = sjson.SetBytes(out, "previous_response_id", v.String())
+			out, _ = util.SafeSetJSON(out, "previous_response_id", v.String())
 		}
 		if v := req.Get("prompt_cache_key"); v.Exists() {
-			out, _ = sjson.SetBytes(out, "prompt_cache_key", v.String())
+			out, _ = util.SafeSetJSON(out, "prompt_cache_key", v.String())
 		}
 		if v := req.Get("reasoning"); v.Exists() {
-			out, _ = sjson.SetBytes(out, "reasoning", v.Value())
+			out, _ = util.SafeSetJSON(out, "reasoning", v.Value())
 		}
 		if v := req.Get("safety_identifier"); v.Exists() {
-			out, _ = sjson.SetBytes(out, "safety_identifier", v.String())
+			out, _ = util.SafeSetJSON(out, "safety_identifier", v.String())
 		}
 		if v := req.Get("service_tier"); v.Exists() {
-			out, _ = sjson.SetBytes(out, "service_tier", v.String())
+			out, _ = util.SafeSetJSON(out, "service_tier", v.String())
 		}
 		if v := req.Get("store"); v.Exists() {
-			out, _ = sjson.SetBytes(out, "store", v.Bool())
+			out, _ = util.SafeSetJSON(out, "store", v.Bool())
 		}
 		if v := req.Get("temperature"); v.Exists() {
-			out, _ = sjson.SetBytes(out, "temperature", v.Float())
+			out, _ = util.SafeSetJSON(out, "temperature", v.Float())
 		}
 		if v := req.Get("text"); v.Exists() {
-			out, _ = sjson.SetBytes(out, "text", v.Value())
+			out, _ = util.SafeSetJSON(out, "text", v.Value())
 		}
 		if v := req.Get("tool_choice"); v.Exists() {
-			out, _ = sjson.SetBytes(out, "tool_choice", v.Value())
+			out, _ = util.SafeSetJSON(out, "tool_choice", v.Value())
 		}
 		if v := req.Get("tools"); v.Exists() {
-			out, _ = sjson.SetBytes(out, "tools", v.Value())
+			out, _ = util.SafeSetJSON(out, "tools", v.Value())
 		}
 		if v := req.Get("top_logprobs"); v.Exists() {
-			out, _ = sjson.SetBytes(out, "top_logprobs", v.Int())
+			out, _ = util.SafeSetJSON(out, "top_logprobs", v.Int())
 		}
 		if v := req.Get("top_p"); v.Exists() {
-			out, _ = sjson.SetBytes(out, "top_p", v.Float())
+			out, _ = util.SafeSetJSON(out, "top_p", v.Float())
 		}
 		if v := req.Get("truncation"); v.Exists() {
-			out, _ = sjson.SetBytes(out, "truncation", v.String())
+			out, _ = util.SafeSetJSON(out, "truncation", v.String())
 		}
 		if v := req.Get("user"); v.Exists() {
-			out, _ = sjson.SetBytes(out, "user", v.Value())
+			out, _ = util.SafeSetJSON(out, "user", v.Value())
 		}
 		if v := req.Get("metadata"); v.Exists() {
-			out, _ = sjson.SetBytes(out, "metadata", v.Value())
+			out, _ = util.SafeSetJSON(out, "metadata", v.Value())
 		}
 	}
 
@@ -639,15 +639,15 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 	outputsWrapper := []byte(`{"arr":[]}`)
 	if reasoningBuf.Len() > 0 {
 		item := []byte(`{"id":"","type":"reasoning","summary":[{"type":"summary_text","text":""}]}`)
-		item, _ = sjson.SetBytes(item, "id", reasoningItemID)
-		item, _ = sjson.SetBytes(item, "summary.0.text", reasoningBuf.String())
-		outputsWrapper, _ = sjson.SetRawBytes(outputsWrapper, "arr.-1", item)
+		item, _ = util.SafeSetJSON(item, "id", reasoningItemID)
+		item, _ = util.SafeSetJSON(item, "summary.0.text", reasoningBuf.String())
+		outputsWrapper, _ = util.SafeSetRawJSON(outputsWrapper, "arr.-1", item)
 	}
 	if currentMsgID != "" || textBuf.Len() > 0 {
 		item := []byte(`{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}`)
-		item, _ = sjson.SetBytes(item, "id", currentMsgID)
-		item, _ = sjson.SetBytes(item, "content.0.text", textBuf.String())
-		outputsWrapper, _ = sjson.SetRawBytes(outputsWrapper, "arr.-1", item)
+		item, _ = util.SafeSetJSON(item, "id", currentMsgID)
+		item, _ = util.SafeSetJSON(item, "content.0.text", textBuf.String())
+		outputsWrapper, _ = util.SafeSetRawJSON(outputsWrapper, "arr.-1", item)
 	}
 	if len(toolCalls) > 0 {
 		// Preserve index order
@@ -669,27 +669,27 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 				args = "{}"
 			}
 			item := []byte(`{"id":"","type":"function_call","status":"completed","arguments":"","call_id":"","name":""}`)
-			item, _ = sjson.SetBytes(item, "id", fmt.Sprintf("fc_%s", st.id))
-			item, _ = sjson.SetBytes(item, "arguments", args)
-			item, _ = sjson.SetBytes(item, "call_id", st.id)
-			item, _ = sjson.SetBytes(item, "name", st.name)
-			outputsWrapper, _ = sjson.SetRawBytes(outputsWrapper, "arr.-1", item)
+			item, _ = util.SafeSetJSON(item, "id", fmt.Sprintf("fc_%s", st.id))
+			item, _ = util.SafeSetJSON(item, "arguments", args)
+			item, _ = util.SafeSetJSON(item, "call_id", st.id)
+			item, _ = util.SafeSetJSON(item, "name", st.name)
+			outputsWrapper, _ = util.SafeSetRawJSON(outputsWrapper, "arr.-1", item)
 		}
 	}
 	if gjson.GetBytes(outputsWrapper, "arr.#").Int() > 0 {
-		out, _ = sjson.SetRawBytes(out, "output", []byte(gjson.GetBytes(outputsWrapper, "arr").Raw))
+		out, _ = util.SafeSetRawJSON(out, "output", []byte(gjson.GetBytes(outputsWrapper, "arr").Raw))
 	}
 
 	// Usage
 	total := inputTokens + outputTokens
-	out, _ = sjson.SetBytes(out, "usage.input_tokens", inputTokens)
-	out, _ = sjson.SetBytes(out, "usage.output_tokens", outputTokens)
-	out, _ = sjson.SetBytes(out, "usage.total_tokens", total)
+	out, _ = util.SafeSetJSON(out, "usage.input_tokens", inputTokens)
+	out, _ = util.SafeSetJSON(out, "usage.output_tokens", outputTokens)
+	out, _ = util.SafeSetJSON(out, "usage.total_tokens", total)
 	if reasoningBuf.Len() > 0 {
 		// Rough estimate similar to chat completions
 		reasoningTokens := int64(len(reasoningBuf.String()) / 4)
 		if reasoningTokens > 0 {
-			out, _ = sjson.SetBytes(out, "usage.output_tokens_details.reasoning_tokens", reasoningTokens)
+			out, _ = util.SafeSetJSON(out, "usage.output_tokens_details.reasoning_tokens", reasoningTokens)
 		}
 	}
 
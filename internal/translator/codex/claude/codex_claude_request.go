@@ -12,8 +12,8 @@ import (
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 // ConvertClaudeRequestToCodex parses and transforms a Claude Code API request into the internal client format.
@@ -41,7 +41,7 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 
 	rootResult := gjson.ParseBytes(rawJSON)
 	toolNameMap := buildReverseMapFromClaudeOriginalToShort(rawJSON)
-	template, _ = sjson.SetBytes(template, "model", modelName)
+	template, _ = util.SafeSetJSON(template, "model", modelName)
 
 	// Process system messages and convert them to input content format.
 	systemsResult := rootResult.Get("system")
@@ -54,8 +54,8 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 				return
 			}
 
-			message, _ = sjson.SetBytes(message, fmt.Sprintf("content.%d.type", contentIndex), "input_text")
-			message, _ = sjson.SetBytes(message, fmt.Sprintf("content.%d.text", contentIndex), text)
+			message, _ = util.SafeSetJSON(message, fmt.Sprintf("content.%d.type", contentIndex), "input_text")
+			message, _ = util.SafeSetJSON(message, fmt.Sprintf("content.%d.text", contentIndex), text)
 			contentIndex++
 		}
 
@@ -72,7 +72,7 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 		}
 
 		if contentIndex > 0 {
-			template, _ = sjson.SetRawBytes(template, "input.-1", message)
+			template, _ = util.SafeSetRawJSON(template, "input.-1", message)
 		}
 	}
 
@@ -87,7 +87,7 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 
 			newMessage := func() []byte {
 				msg := []byte(`{"type":"message","role":"","content":[]}`)
-				msg, _ = sjson.SetBytes(msg, "role", messageRole)
+				msg, _ = util.SafeSetJSON(msg, "role", messageRole)
 				return msg
 			}
 
@@ -97,7 +97,7 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 
 			flushMessage := func() {
 				if hasContent {
-					template, _ = sjson.SetRawBytes(template, "input.-1", message)
+					template, _ = util.SafeSetRawJSON(template, "input.-1", message)
 					message = newMessage()
 					contentIndex = 0
 					hasContent = false
@@ -109,15 +109,15 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 				if messageRole == "assistant" {
 					partType = "output_text"
 				}
-				message, _ = sjson.SetBytes(message, fmt.Sprintf("content.%d.type", contentIndex), partType)
-				message, _ = sjson.SetBytes(message, fmt.Sprintf("content.%d.text", contentIndex), text)
+				message, _ = util.SafeSetJSON(message, fmt.Sprintf("content.%d.type", contentIndex), partType)
+				message, _ = util.SafeSetJSON(message, fmt.Sprintf("content.%d.text", contentIndex), text)
 				contentIndex++
 				hasContent = true
 			}
 
 			appendImageContent := func(dataURL string) {
-				message, _ = sjson.SetBytes(message, fmt.Sprintf("content.%d.type", contentIndex), "input_image")
-				message, _ = sjson.SetBytes(message, fmt.Sprintf("content.%d.image_url", contentIndex), dataURL)
+				message, _ = util.SafeSetJSON(message, fmt.Sprintf("content.%d.type", contentIndex), "input_image")
+				message, _ = util.SafeSetJSON(message, fmt.Sprintf("content.%d.image_url", contentIndex), dataURL)
 				contentIndex++
 				hasContent = true
 			}
@@ -134,8 +134,8 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 
 				flushMessage()
 				reasoningItem := []byte(`{"type":"reasoning","summary":[],"content":null}`)
-				reasoningItem, _ = sjson.SetBytes(reasoningItem, "encrypted_content", signature)
-				template, _ = sjson.SetRawBytes(template, "input.-1", reasoningItem)
+				reasoningItem, _ = util.SafeSetJSON(reasoningItem, "encrypted_content", signature)
+				template, _ = util.SafeSetRawJSON(template, "input.-1", reasoningItem)
 			}
 
 			messageContentsResult := messageResult.Get("content")
@@ -172,7 +172,7 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 					case "tool_use":
 						flushMessage()
 						functionCallMessage := []byte(`{"type":"function_call"}`)
-						functionCallMessage, _ = sjson.SetBytes(functionCallMessage, "call_id", messageContentResult.Get("id").String())
+						functionCallMessage, _ = util.SafeSetJSON(functionCallMessage, "call_id", messageContentResult.Get("id").String())
 						{
 							name := messageContentResult.Get("name").String()
 							if short, ok := toolNameMap[name]; ok {
@@ -180,14 +180,14 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 							} else {
 								name = shortenNameIfNeeded(name)
 							}
-							functionCallMessage, _ = sjson.SetBytes(functionCallMessage, "name", name)
+							functionCallMessage, _ = util.SafeSetJSON(functionCallMessage, "name", name)
 						}
-						functionCallMessage, _ = sjson.SetBytes(functionCallMessage, "arguments", messageContentResult.Get("input").Raw)
-						template, _ = sjson.SetRawBytes(template, "input.-1", functionCallMessage)
+						functionCallMessage, _ = util.SafeSetJSON(functionCallMessage, "arguments", messageContentResult.Get("input").Raw)
+						template, _ = util.SafeSetRawJSON(template, "input.-1", functionCallMessage)
 					case "tool_result":
 						flushMessage()
 						functionCallOutputMessage := []byte(`{"type":"function_call_output"}`)
-						functionCallOutputMessage, _ = sjson.SetBytes(functionCallOutputMessage, "call_id", messageContentResult.Get("tool_use_id").String())
+						functionCallOutputMessage, _ = util.SafeSetJSON(functionCallOutputMessage, "call_id", messageContentResult.Get("tool_use_id").String())
 
 						contentResult := messageContentResult.Get("content")
 						if contentResult.IsArray() {
@@ -213,27 +213,27 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 											}
 											dataURL := fmt.Sprintf("data:%s;base64,%s", mediaType, data)
 
-											toolResultContent, _ = sjson.SetBytes(toolResultContent, fmt.Sprintf("%d.type", toolResultContentIndex), "input_image")
-											toolResultContent, _ = sjson.SetBytes(toolResultContent, fmt.Sprintf("%d.image_url", toolResultContentIndex), dataURL)
+											toolResultContent, _ = util.SafeSetJSON(toolResultContent, fmt.Sprintf("%d.type", toolResultContentIndex), "input_image")
+											toolResultContent, _ = util.SafeSetJSON(toolResultContent, fmt.Sprintf("%d.image_url", toolResultContentIndex), dataURL)
 											toolResultContentIndex++
 										}
 									}
 								} else if toolResultContentType == "text" {
-									toolResultContent, _ = sjson.SetBytes(toolResultContent, fmt.Sprintf("%d.type", toolResultContentIndex), "input_text")
-									toolResultContent, _ = sjson.SetBytes(toolResultContent, fmt.Sprintf("%d.text", toolResultContentIndex), contentResults[k].Get("text").String())
+									toolResultContent, _ = util.SafeSetJSON(toolResultContent, fmt.Sprintf("%d.type", toolResultContentIndex), "input_text")
+									toolResultContent, _ = util.SafeSetJSON(toolResultContent, fmt.Sprintf("%d.text", toolResultContentIndex), contentResults[k].Get("text").String())
 									toolResultContentIndex++
 								}
 							}
 							if toolResultContentIndex > 0 {
-								functionCallOutputMessage, _ = sjson.SetRawBytes(functionCallOutputMessage, "output", toolResultContent)
+								functionCallOutputMessage, _ = util.SafeSetRawJSON(functionCallOutputMessage, "output", toolResultContent)
 							} else {
-								functionCallOutputMessage, _ = sjson.SetBytes(functionCallOutputMessage, "output", messageContentResult.Get("content").String())
+								functionCallOutputMessage, _ = util.SafeSetJSON(functionCallOutputMessage, "output", messageContentResult.Get("content").String())
 							}
 						} else {
-							functionCallOutputMessage, _ = sjson.SetBytes(functionCallOutputMessage, "output", messageContentResult.Get("content").String())
+							functionCallOutputMessage, _ = util.SafeSetJSON(functionCallOutputMessage, "output", messageContentResult.Get("content").String())
 						}
 
-						template, _ = sjson.SetRawBytes(template, "input.-1", functionCallOutputMessage)
+						template, _ = util.SafeSetRawJSON(template, "input.-1", functionCallOutputMessage)
 					}
 				}
 				flushMessage()
@@ -248,19 +248,19 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 	// Convert tools declarations to the expected format for the Codex API.
 	toolsResult := rootResult.Get("tools")
 	if toolsResult.IsArray() {
-		template, _ = sjson.SetRawBytes(template, "tools", []byte(`[]`))
+		template, _ = util.SafeSetRawJSON(template, "tools", []byte(`[]`))
 		webSearchToolNames := buildClaudeWebSearchToolNameSet(toolsResult)
-		template, _ = sjson.SetRawBytes(template, "tool_choice", convertClaudeToolChoiceToCodex(rootResult.Get("tool_choice"), toolNameMap, webSearchToolNames))
+		template, _ = util.SafeSetRawJSON(template, "tool_choice", convertClaudeToolChoiceToCodex(rootResult.Get("tool_choice"), toolNameMap, webSearchToolNames))
 		toolResults := toolsResult.Array()
 		for i := 0; i < len(toolResults); i++ {
 			toolResult := toolResults[i]
 			// Special handling: map Claude web search tool to Codex web_search
 			if isClaudeWebSearchToolType(toolResult.Get("type").String()) {
-				template, _ = sjson.SetRawBytes(template, "tools.-1", convertClaudeWebSearchToolToCodex(toolResult))
+				template, _ = util.SafeSetRawJSON(template, "tools.-1", convertClaudeWebSearchToolToCodex(toolResult))
 				continue
 			}
 			tool := []byte(toolResult.Raw)
-			tool, _ = sjson.SetBytes(tool, "type", "function")
+			tool, _ = util.SafeSetJSON(tool, "type", "function")
 			// Apply shortened name if needed
 			if v := toolResult.Get("name"); v.Exists() {
 				name := v.String()
@@ -269,15 +269,15 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 				} else {
 					name = shortenNameIfNeeded(name)
 				}
-				tool, _ = sjson.SetBytes(tool, "name", name)
+				tool, _ = util.SafeSetJSON(tool, "name", name)
 			}
-			tool, _ = sjson.SetRawBytes(tool, "parameters", []byte(normalizeToolParameters(toolResult.Get("input_schema").Raw)))
-			tool, _ = sjson.DeleteBytes(tool, "input_schema")
-			tool, _ = sjson.DeleteBytes(tool, "parameters.$schema")
-			tool, _ = sjson.DeleteBytes(tool, "cache_control")
-			tool, _ = sjson.DeleteBytes(tool, "defer_loading")
-			tool, _ = sjson.SetBytes(tool, "strict", false)
-			template, _ = sjson.SetRawBytes(template, "tools.-1", tool)
+			tool, _ = util.SafeSetRawJSON(tool, "parameters", []byte(normalizeToolParameters(toolResult.Get("input_schema").Raw)))
+			tool, _ = util.SafeDeleteJSON(tool, "input_schema")
+			tool, _ = util.SafeDeleteJSON(tool, "parameters.$schema")
+			tool, _ = util.SafeDeleteJSON(tool, "cache_control")
+			tool, _ = util.SafeDeleteJSON(tool, "defer_loading")
+			tool, _ = util.SafeSetJSON(tool, "strict", false)
+			template, _ = util.SafeSetRawJSON(template, "tools.-1", tool)
 		}
 	}
 
@@ -288,7 +288,7 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 	}
 
 	// Add additional configuration parameters for the Codex API.
-	template, _ = sjson.SetBytes(template, "parallel_tool_calls", parallelToolCalls)
+	template, _ = util.SafeSetJSON(template, "parallel_tool_calls", parallelToolCalls)
 
 	// Convert thinking.budget_tokens to reasoning.effort.
 	reasoningEffort := "medium"
@@ -319,11 +319,11 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 			}
 		}
 	}
-	template, _ = sjson.SetBytes(template, "reasoning.effort", reasoningEffort)
-	template, _ = sjson.SetBytes(template, "reasoning.summary", "auto")
-	template, _ = sjson.SetBytes(template, "stream", true)
-	template, _ = sjson.SetBytes(template, "store", false)
-	template, _ = sjson.SetBytes(template, "include", []string{"reasoning.encrypted_content"})
+	template, _ = util.SafeSetJSON(template, "reasoning.effort", reasoningEffort)
+	template, _ = util.SafeSetJSON(template, "reasoning.summary", "auto")
+	template, _ = util.SafeSetJSON(template, "stream", true)
+	template, _ = util.SafeSetJSON(template, "store", false)
+	template, _ = util.SafeSetJSON(template, "include", []string{"reasoning.encrypted_content"})
 
 	return template
 }
@@ -418,7 +418,7 @@ func convertClaudeToolChoiceToCodex(toolChoice gjson.Result, toolNameMap map[str
 		}
 
 		choice := []byte(`{"type":"function","name":""}`)
-		choice, _ = sjson.SetBytes(choice, "name", name)
+		choice, _ = util.SafeSetJSON(choice, "name", name)
 		return choice
 	default:
 		return []byte(`"auto"`)
@@ -428,10 +428,10 @@ func convertClaudeToolChoiceToCodex(toolChoice gjson.Result, toolNameMap map[str
 func convertClaudeWebSearchToolToCodex(tool gjson.Result) []byte {
 	out := []byte(`{"type":"web_search"}`)
 	if allowedDomains := tool.Get("allowed_domains"); allowedDomains.Exists() && allowedDomains.IsArray() {
-		out, _ = sjson.SetRawBytes(out, "filters.allowed_domains", []byte(allowedDomains.Raw))
+		out, _ = util.SafeSetRawJSON(out, "filters.allowed_domains", []byte(allowedDomains.Raw))
 	}
 	if userLocation := tool.Get("user_location"); userLocation.Exists() && userLocation.IsObject() {
-		out, _ = sjson.SetRawBytes(out, "user_location", []byte(userLocation.Raw))
+		out, _ = util.SafeSetRawJSON(out, "user_location", []byte(userLocation.Raw))
 	}
 	return out
 }
@@ -540,11 +540,11 @@ func normalizeToolParameters(raw string) string {
 	schema := []byte(raw)
 	schemaType := result.Get("type").String()
 	if schemaType == "" {
-		schema, _ = sjson.SetBytes(schema, "type", "object")
+		schema, _ = util.SafeSetJSON(schema, "type", "object")
 		schemaType = "object"
 	}
 	if schemaType == "object" && !result.Get("properties").Exists() {
-		schema, _ = sjson.SetRawBytes(schema, "properties", []byte(`{}`))
+		schema, _ = util.SafeSetRawJSON(schema, "properties", []byte(`{}`))
 	}
 	return string(schema)
 }
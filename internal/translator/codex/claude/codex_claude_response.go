@@ -14,7 +14,6 @@ import (
 	translatorcommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/common"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 var (
@@ -79,8 +78,8 @@ func ConvertCodexResponseToClaude(_ context.Context, _ string, originalRequestRa
 
 	if typeStr == "response.created" {
 		template = []byte(`{"type":"message_start","message":{"id":"","type":"message","role":"assistant","model":"claude-opus-4-1-20250805","stop_sequence":null,"usage":{"input_tokens":0,"output_tokens":0},"content":[],"stop_reason":null}}`)
-		template, _ = sjson.SetBytes(template, "message.model", rootResult.Get("response.model").String())
-		template, _ = sjson.SetBytes(template, "message.id", rootResult.Get("response.id").String())
+		template, _ = util.SafeSetJSON(template, "message.model", rootResult.Get("response.model").String())
+		template, _ = util.SafeSetJSON(template, "message.id", rootResult.Get("response.id").String())
 
 		output = translatorcommon.AppendSSEEventBytes(output, "message_start", template, 2)
 	} else if typeStr == "response.reasoning_summary_part.added" {
@@ -91,28 +90,28 @@ func ConvertCodexResponseToClaude(_ context.Context, _ string, originalRequestRa
 		output = append(output, startCodexThinkingBlock(params)...)
 	} else if typeStr == "response.reasoning_summary_text.delta" {
 		template = []byte(`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":""}}`)
-		template, _ = sjson.SetBytes(template, "index", params.BlockIndex)
-		template, _ = sjson.SetBytes(template, "delta.thinking", rootResult.Get("delta").String())
+		template, _ = util.SafeSetJSON(template, "index", params.BlockIndex)
+		template, _ = util.SafeSetJSON(template, "delta.thinking", rootResult.Get("delta").String())
 
 		output = translatorcommon.AppendSSEEventBytes(output, "content_block_delta", template, 2)
 	} else if typeStr == "response.reasoning_summary_part.done" {
 		params.ThinkingStopPending = true
 	} else if typeStr == "response.content_part.added" {
 		template = []byte(`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`)
-		template, _ = sjson.SetBytes(template, "index", params.BlockIndex)
+		template, _ = util.SafeSetJSON(template, "index", params.BlockIndex)
 		params.TextBlockOpen = true
 
 		output = translatorcommon.AppendSSEEventBytes(output, "content_block_start", template, 2)
 	} else if typeStr == "response.output_text.delta" {
 		params.HasTextDelta = true
 		template = []byte(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":""}}`)
-		template, _ = sjson.SetBytes(template, "index", params.BlockIndex)
-		template, _ = sjson.SetBytes(template, "delta.text", rootResult.Get("delta").String())
+		template, _ = util.SafeSetJSON(template, "index", params.BlockIndex)
+		template, _ = util.SafeSetJSON(template, "delta.text", rootResult.Get("delta").String())
 
 		output = translatorcommon.AppendSSEEventBytes(output, "content_block_delta", template, 2)
 	} else if typeStr == "response.content_part.done" {
 		template = []byte(`{"type":"content_block_stop","index":0}`)
-		template, _ = sjson.SetBytes(template, "index", params.BlockIndex)
+		template, _ = util.SafeSetJSON(template, "index", params.BlockIndex)
 		params.TextBlockOpen = false
 		params.BlockIndex++
 
@@ -120,13 +119,13 @@ func ConvertCodexResponseToClaude(_ context.Context, _ string, originalRequestRa
 	} else if typeStr == "response.completed" || typeStr == "response.incomplete" {
 		template = []byte(`{"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"input_tokens":0,"output_tokens":0}}`)
 		responseData := rootResult.Get("response")
-		template, _ = sjson.SetBytes(template, "delta.stop_reason", mapCodexStopReasonToClaude(codexStopReason(responseData), params.HasToolCall))
+		template, _ = util.SafeSetJSON(template, "delta.stop_reason", mapCodexStopReasonToClaude(codexStopReason(responseData), params.HasToolCall))
 		template = setClaudeStopSequence(template, "delta.stop_sequence", responseData)
 		inputTokens, outputTokens, cachedTokens := extractResponsesUsage(responseData.Get("usage"))
-		template, _ = sjson.SetBytes(template, "usage.input_tokens", inputTokens)
-		template, _ = sjson.SetBytes(template, "usage.output_tokens", outputTokens)
+		template, _ = util.SafeSetJSON(template, "usage.input_tokens", inputTokens)
+		template, _ = util.SafeSetJSON(template, "usage.output_tokens", outputTokens)
 		if cachedTokens > 0 {
-			template, _ = sjson.SetBytes(template, "usage.cache_read_input_tokens", cachedTokens)
+			template, _ = util.SafeSetJSON(template, "usage.cache_read_input_tokens", cachedTokens)
 		}
 
 		output = translatorcommon.AppendSSEEventBytes(output, "message_delta", template, 2)
@@ -139,21 +138,21 @@ func ConvertCodexResponseToClaude(_ context.Context, _ string, originalRequestRa
 			params.HasToolCall = true
 			params.HasReceivedArgumentsDelta = false
 			template = []byte(`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"","name":"","input":{}}}`)
-			template, _ = sjson.SetBytes(template, "index", params.BlockIndex)
-			template, _ = sjson.SetBytes(template, "content_block.id", util.SanitizeClaudeToolID(itemResult.Get("call_id").String()))
+			template, _ = util.SafeSetJSON(template, "index", params.BlockIndex)
+			template, _ = util.SafeSetJSON(template, "content_block.id", util.SanitizeClaudeToolID(itemResult.Get("call_id").String()))
 			{
 				name := itemResult.Get("name").String()
 				rev := buildReverseMapFromClaudeOriginalShortToOriginal(originalRequestRawJSON)
 				if orig, ok := rev[name]; ok {
 					name = orig
 				}
-				template, _ = sjson.SetBytes(template, "content_block.name", name)
+				template, _ = util.SafeSetJSON(template, "content_block.name", name)
 			}
 
 			output = translatorcommon.AppendSSEEventBytes(output, "content_block_start", template, 2)
 
 			template = []byte(`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":""}}`)
-			template, _ = sjson.SetBytes(template, "index", params.BlockIndex)
+			template, _ = util.SafeSetJSON(template, "index", params.BlockIndex)
 
 			output = translatorcommon.AppendSSEEventBytes(output, "content_block_delta", template, 2)
 		} else if itemType == "reasoning" {
@@ -189,25 +188,25 @@ func ConvertCodexResponseToClaude(_ context.Context, _ string, originalRequestRa
 			output = append(output, finalizeCodexThinkingBlock(params)...)
 			if !params.TextBlockOpen {
 				template = []byte(`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`)
-				template, _ = sjson.SetBytes(template, "index", params.BlockIndex)
+				template, _ = util.SafeSetJSON(template, "index", params.BlockIndex)
 				params.TextBlockOpen = true
 				output = translatorcommon.AppendSSEEventBytes(output, "content_block_start", template, 2)
 			}
 
 			template = []byte(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":""}}`)
-			template, _ = sjson.SetBytes(template, "index", params.BlockIndex)
-			template, _ = sjson.SetBytes(template, "delta.text", text)
+			template, _ = util.SafeSetJSON(template, "index", params.BlockIndex)
+			template, _ = util.SafeSetJSON(template, "delta.text", text)
 			output = translatorcommon.AppendSSEEventBytes(output, "content_block_delta", template, 2)
 
 			template = []byte(`{"type":"content_block_stop","index":0}`)
-			template, _ = sjson.SetBytes(template, "index", params.BlockIndex)
+			template, _ = util.SafeSetJSON(template, "index", params.BlockIndex)
 			params.TextBlockOpen = false
 			params.BlockIndex++
 			params.HasTextDelta = true
 			output = translatorcommon.AppendSSEEventBytes(output, "content_block_stop", template, 2)
 		} else if itemType == "function_call" {
 			template = []byte(`{"type":"content_block_stop","index":0}`)
-			template, _ = sjson.SetBytes(template, "index", params.BlockIndex)
+			template, _ = util.SafeSetJSON(template, "index", params.BlockIndex)
 			params.BlockIndex++
 
 			output = translatorcommon.AppendSSEEventBytes(output, "content_block_stop", template, 2)
@@ -226,16 +225,16 @@ func ConvertCodexResponseToClaude(_ context.Context, _ string, originalRequestRa
 	} else if typeStr == "response.function_call_arguments.delta" {
 		params.HasReceivedArgumentsDelta = true
 		template = []byte(`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":""}}`)
-		template, _ = sjson.SetBytes(template, "index", params.BlockIndex)
-		template, _ = sjson.SetBytes(template, "delta.partial_json", rootResult.Get("delta").String())
+		template, _ = util.SafeSetJSON(template, "index", params.BlockIndex)
+		template, _ = util.SafeSetJSON(template, "delta.partial_json", rootResult.Get("delta").String())
 
 		output = translatorcommon.AppendSSEEventBytes(output, "content_block_delta", template, 2)
 	} else if typeStr == "response.function_call_arguments.done" {
 		if !params.HasReceivedArgumentsDelta {
 			if args := rootResult.Get("arguments").String(); args != "" {
 				template = []byte(`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":""}}`)
-				template, _ = sjson.SetBytes(template, "index", params.BlockIndex)
-				template, _ = sjson.SetBytes(template, "delta.partial_json", args)
+				template, _ = util.SafeSetJSON(template, "index", params.BlockIndex)
+				template, _ = util.SafeSetJSON(template, "delta.partial_json", args)
 
 				output = translatorcommon.AppendSSEEventBytes(output, "content_block_delta", template, 2)
 			}
@@ -264,13 +263,13 @@ func ConvertCodexResponseToClaudeNonStream(_ context.Context, _ string, original
 	}
 
 	out := []byte(`{"id":"","type":"message","role":"assistant","model":"","content":[],"stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":0,"output_tokens":0}}`)
-	out, _ = sjson.SetBytes(out, "id", responseData.Get("id").String())
-	out, _ = sjson.SetBytes(out, "model", responseData.Get("model").String())
+	out, _ = util.SafeSetJSON(out, "id", responseData.Get("id").String())
+	out, _ = util.SafeSetJSON(out, "model", responseData.Get("model").String())
 	inputTokens, outputTokens, cachedTokens := extractResponsesUsage(responseData.Get("usage"))
-	out, _ = sjson.SetBytes(out, "usage.input_tokens", inputTokens)
-	out, _ = sjson.SetBytes(out, "usage.output_tokens", outputTokens)
+	out, _ = util.SafeSetJSON(out, "usage.input_tokens", inputTokens)
+	out, _ = util.SafeSetJSON(out, "usage.output_tokens", outputTokens)
 	if cachedTokens > 0 {
-		out, _ = sjson.SetBytes(out, "usage.cache_read_input_tokens", cachedTokens)
+		out, _ = util.SafeSetJSON(out, "usage.cache_read_input_tokens", cachedTokens)
 	}
 
 	hasToolCall := false
@@ -313,11 +312,11 @@ func ConvertCodexResponseToClaudeNonStream(_ context.Context, _ string, original
 				}
 				if thinkingBuilder.Len() > 0 || signature != "" {
 					block := []byte(`{"type":"thinking","thinking":""}`)
-					block, _ = sjson.SetBytes(block, "thinking", thinkingBuilder.String())
+					block, _ = util.SafeSetJSON(block, "thinking", thinkingBuilder.String())
 					if signature != "" {
-						block, _ = sjson.SetBytes(block, "signature", signature)
+						block, _ = util.SafeSetJSON(block, "signature", signature)
 					}
-					out, _ = sjson.SetRawBytes(out, "content.-1", block)
+					out, _ = util.SafeSetRawJSON(out, "content.-1", block)
 				}
 			case "message":
 				if content := item.Get("content"); content.Exists() {
@@ -327,8 +326,8 @@ func ConvertCodexResponseToClaudeNonStream(_ context.Context, _ string, original
 								text := part.Get("text").String()
 								if text != "" {
 									block := []byte(`{"type":"text","text":""}`)
-									block, _ = sjson.SetBytes(block, "text", text)
-									out, _ = sjson.SetRawBytes(out, "content.-1", block)
+									block, _ = util.SafeSetJSON(block, "text", text)
+									out, _ = util.SafeSetRawJSON(out, "content.-1", block)
 								}
 							}
 							return true
@@ -337,8 +336,8 @@ func ConvertCodexResponseToClaudeNonStream(_ context.Context, _ string, original
 						text := content.String()
 						if text != "" {
 							block := []byte(`{"type":"text","text":""}`)
-							block, _ = sjson.SetBytes(block, "text", text)
-							out, _ = sjson.SetRawBytes(out, "content.-1", block)
+							block, _ = util.SafeSetJSON(block, "text", text)
+							out, _ = util.SafeSetRawJSON(out, "content.-1", block)
 						}
 					}
 				}
@@ -350,8 +349,8 @@ func ConvertCodexResponseToClaudeNonStream(_ context.Context, _ string, original
 				}
 
 				toolBlock := []byte(`{"type":"tool_use","id":"","name":"","input":{}}`)
-				toolBlock, _ = sjson.SetBytes(toolBlock, "id", util.SanitizeClaudeToolID(item.Get("call_id").String()))
-				toolBlock, _ = sjson.SetBytes(toolBlock, "name", name)
+				toolBlock, _ = util.SafeSetJSON(toolBlock, "id", util.SanitizeClaudeToolID(item.Get("call_id").String()))
+				toolBlock, _ = util.SafeSetJSON(toolBlock, "name", name)
 				inputRaw := "{}"
 				if argsStr := item.Get("arguments").String(); argsStr != "" && gjson.Valid(argsStr) {
 					argsJSON := gjson.Parse(argsStr)
@@ -359,14 +358,14 @@ func ConvertCodexResponseToClaudeNonStream(_ context.Context, _ string, original
 						inputRaw = argsJSON.Raw
 					}
 				}
-				toolBlock, _ = sjson.SetRawBytes(toolBlock, "input", []byte(inputRaw))
-				out, _ = sjson.SetRawBytes(out, "content.-1", toolBlock)
+				toolBlock, _ = util.SafeSetRawJSON(toolBlock, "input", []byte(inputRaw))
+				out, _ = util.SafeSetRawJSON(out, "content.-1", toolBlock)
 			}
 			return true
 		})
 	}
 
-	out, _ = sjson.SetBytes(out, "stop_reason", mapCodexStopReasonToClaude(codexStopReason(responseData), hasToolCall))
+	out, _ = util.SafeSetJSON(out, "stop_reason", mapCodexStopReasonToClaude(codexStopReason(responseData), hasToolCall))
 	out = setClaudeStopSequence(out, "stop_sequence", responseData)
 
 	return out
@@ -415,7 +414,7 @@ func codexStopSequence(responseData gjson.Result) gjson.Result {
 
 func setClaudeStopSequence(out []byte, path string, responseData gjson.Result) []byte {
 	if stopSequence := codexStopSequence(responseData); stopSequence.Exists() && stopSequence.String() != "" {
-		out, _ = sjson.SetRawBytes(out, path, []byte(stopSequence.Raw))
+		out, _ = util.SafeSetRawJSON(out, path, []byte(stopSequence.Raw))
 	}
 	return out
 }
@@ -474,7 +473,7 @@ func startCodexThinkingBlock(params *ConvertCodexResponseToClaudeParams) []byte
 	}
 
 	template := []byte(`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`)
-	template, _ = sjson.SetBytes(template, "index", params.BlockIndex)
+	template, _ = util.SafeSetJSON(template, "index", params.BlockIndex)
 	params.ThinkingBlockOpen = true
 	params.ThinkingStopPending = false
 
@@ -499,13 +498,13 @@ func finalizeCodexThinkingBlock(params *ConvertCodexResponseToClaudeParams) []by
 	output := make([]byte, 0, 256)
 	if params.ThinkingSignature != "" {
 		signatureDelta := []byte(`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":""}}`)
-		signatureDelta, _ = sjson.SetBytes(signatureDelta, "index", params.BlockIndex)
-		signatureDelta, _ = sjson.SetBytes(signatureDelta, "delta.signature", params.ThinkingSignature)
+		signatureDelta, _ = util.SafeSetJSON(signatureDelta, "index", params.BlockIndex)
+		signatureDelta, _ = util.SafeSetJSON(signatureDelta, "delta.signature", params.ThinkingSignature)
 		output = translatorcommon.AppendSSEEventBytes(output, "content_block_delta", signatureDelta, 2)
 	}
 
 	contentBlockStop := []byte(`{"type":"content_block_stop","index":0}`)
-	contentBlockStop, _ = sjson.SetBytes(contentBlockStop, "index", params.BlockIndex)
+	contentBlockStop, _ = util.SafeSetJSON(contentBlockStop, "index", params.BlockIndex)
 	output = translatorcommon.AppendSSEEventBytes(output, "content_block_stop", contentBlockStop, 2)
 
 	params.BlockIndex++
@@ -7,8 +7,8 @@ package geminiCLI
 
 import (
 	. "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/codex/gemini"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 // ConvertGeminiCLIRequestToCodex parses and transforms a Gemini CLI API request into Codex API format.
@@ -31,10 +31,10 @@ func ConvertGeminiCLIRequestToCodex(modelName string, inputRawJSON []byte, strea
 	rawJSON := inputRawJSON
 
 	rawJSON = []byte(gjson.GetBytes(rawJSON, "request").Raw)
-	rawJSON, _ = sjson.SetBytes(rawJSON, "model", modelName)
+	rawJSON, _ = util.SafeSetJSON(rawJSON, "model", modelName)
 	if gjson.GetBytes(rawJSON, "systemInstruction").Exists() {
-		rawJSON, _ = sjson.SetRawBytes(rawJSON, "system_instruction", []byte(gjson.GetBytes(rawJSON, "systemInstruction").Raw))
-		rawJSON, _ = sjson.DeleteBytes(rawJSON, "systemInstruction")
+		rawJSON, _ = util.SafeSetRawJSON(rawJSON, "system_instruction", []byte(gjson.GetBytes(rawJSON, "systemInstruction").Raw))
+		rawJSON, _ = util.SafeDeleteJSON(rawJSON, "systemInstruction")
 	}
 
 	return ConvertGeminiRequestToCodex(modelName, rawJSON, stream)
@@ -15,7 +15,6 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 // ConvertGeminiRequestToCodex parses and transforms a Gemini API request into Codex API format.
@@ -82,7 +81,7 @@ func ConvertGeminiRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 	}
 
 	// Model
-	out, _ = sjson.SetBytes(out, "model", modelName)
+	out, _ = util.SafeSetJSON(out, "model", modelName)
 
 	// System instruction -> as a user message with input_text parts
 	sysParts := root.Get("system_instruction.parts")
@@ -93,13 +92,13 @@ func ConvertGeminiRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 			p := arr[i]
 			if t := p.Get("text"); t.Exists() {
 				part := []byte(`{}`)
-				part, _ = sjson.SetBytes(part, "type", "input_text")
-				part, _ = sjson.SetBytes(part, "text", t.String())
-				msg, _ = sjson.SetRawBytes(msg, "content.-1", part)
+				part, _ = util.SafeSetJSON(part, "type", "input_text")
+				part, _ = util.SafeSetJSON(part, "text", t.String())
+				msg, _ = util.SafeSetRawJSON(msg, "content.-1", part)
 			}
 		}
 		if len(gjson.GetBytes(msg, "content").Array()) > 0 {
-			out, _ = sjson.SetRawBytes(out, "input.-1", msg)
+			out, _ = util.SafeSetRawJSON(out, "input.-1", msg)
 		}
 	}
 
@@ -124,16 +123,16 @@ func ConvertGeminiRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 				// text part
 				if t := p.Get("text"); t.Exists() {
 					msg := []byte(`{"type":"message","role":"","content":[]}`)
-					msg, _ = sjson.SetBytes(msg, "role", role)
+					msg, _ = util.SafeSetJSON(msg, "role", role)
 					partType := "input_text"
 					if role == "assistant" {
 						partType = "output_text"
 					}
 					part := []byte(`{}`)
-					part, _ = sjson.SetBytes(part, "type", partType)
-					part, _ = sjson.SetBytes(part, "text", t.String())
-					msg, _ = sjson.SetRawBytes(msg, "content.-1", part)
-					out, _ = sjson.SetRawBytes(out, "input.-1", msg)
+					part, _ = util.SafeSetJSON(part, "type", partType)
+					part, _ = util.SafeSetJSON(part, "text", t.String())
+					msg, _ = util.SafeSetRawJSON(msg, "content.-1", part)
+					out, _ = util.SafeSetRawJSON(out, "input.-1", msg)
 					continue
 				}
 
@@ -147,18 +146,18 @@ func ConvertGeminiRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 						} else {
 							n = shortenNameIfNeeded(n)
 						}
-						fn, _ = sjson.SetBytes(fn, "name", n)
+						fn, _ = util.SafeSetJSON(fn, "name", n)
 					}
 					if args := fc.Get("args"); args.Exists() {
-						fn, _ = sjson.SetBytes(fn, "arguments", args.Raw)
+						fn, _ = util.SafeSetJSON(fn, "arguments", args.Raw)
 					}
 					// generate a paired random call_id and enqueue it so the
 					// corresponding functionResponse can pop the earliest id
 					// to preserve ordering when multiple calls are present.
 					id := genCallID()
-					fn, _ = sjson.SetBytes(fn, "call_id", id)
+					fn, _ = util.SafeSetJSON(fn, "call_id", id)
 					pendingCallIDs = append(pendingCallIDs, id)
-					out, _ = sjson.SetRawBytes(out, "input.-1", fn)
+					out, _ = util.SafeSetRawJSON(out, "input.-1", fn)
 					continue
 				}
 
@@ -167,11 +166,11 @@ func ConvertGeminiRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 					fno := []byte(`{"type":"function_call_output"}`)
 					// Prefer a string result if present; otherwise embed the raw response as a string
 					if res := fr.Get("response.result"); res.Exists() {
-						fno, _ = sjson.SetBytes(fno, "output", res.String())
+						fno, _ = util.SafeSetJSON(fno, "output", res.String())
 					} else if resp := fr.Get("response"); resp.Exists() {
-						fno, _ = sjson.SetBytes(fno, "output", resp.Raw)
+						fno, _ = util.SafeSetJSON(fno, "output", resp.Raw)
 					}
-					// fno, _ = sjson.SetBytes(fno, "call_id", "call_W6nRJzFXyPM2LFBbfo98qAbq")
+					// fno, _ = util.SafeSetJSON(fno, "call_id", "call_W6nRJzFXyPM2LFBbfo98qAbq")
 					// attach the oldest queued call_id to pair the response
 					// with its call. If the queue is empty, generate a new id.
 					var id string
@@ -182,8 +181,8 @@ func ConvertGeminiRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 					} else {
 						id = genCallID()
 					}
-					fno, _ = sjson.SetBytes(fno, "call_id", id)
-					out, _ = sjson.SetRawBytes(out, "input.-1", fno)
+					fno, _ = util.SafeSetJSON(fno, "call_id", id)
+					out, _ = util.SafeSetRawJSON(out, "input.-1", fno)
 					continue
 				}
 			}
@@ -193,8 +192,8 @@ func ConvertGeminiRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 	// Tools mapping: Gemini functionDeclarations -> Codex tools
 	tools := root.Get("tools")
 	if tools.IsArray() {
-		out, _ = sjson.SetRawBytes(out, "tools", []byte(`[]`))
-		out, _ = sjson.SetBytes(out, "tool_choice", "auto")
+		out, _ = util.SafeSetRawJSON(out, "tools", []byte(`[]`))
+		out, _ = util.SafeSetJSON(out, "tool_choice", "auto")
 		tarr := tools.Array()
 		for i := 0; i < len(tarr); i++ {
 			td := tarr[i]
@@ -206,7 +205,7 @@ func ConvertGeminiRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 			for j := 0; j < len(farr); j++ {
 				fn := farr[j]
 				tool := []byte(`{}`)
-				tool, _ = sjson.SetBytes(tool, "type", "function")
+				tool, _ = util.SafeSetJSON(tool, "type", "function")
 				if v := fn.Get("name"); v.Exists() {
 					name := v.String()
 					if short, ok := shortMap[name]; ok {
@@ -214,32 +213,32 @@ func ConvertGeminiRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 					} else {
 						name = shortenNameIfNeeded(name)
 					}
-					tool, _ = sjson.SetBytes(tool, "name", name)
+					tool, _ = util.SafeSetJSON(tool, "name", name)
 				}
 				if v := fn.Get("description"); v.Exists() {
-					tool, _ = sjson.SetBytes(tool, "description", v.String())
+					tool, _ = util.SafeSetJSON(tool, "description", v.String())
 				}
 				if prm := fn.Get("parameters"); prm.Exists() {
 					// Remove optional $schema field if present
 					cleaned := []byte(prm.Raw)
-					cleaned, _ = sjson.DeleteBytes(cleaned, "$schema")
-					cleaned, _ = sjson.SetBytes(cleaned, "additionalProperties", false)
-					tool, _ = sjson.SetRawBytes(tool, "parameters", cleaned)
+					cleaned, _ = util.SafeDeleteJSON(cleaned, "$schema")
+					cleaned, _ = util.SafeSetJSON(cleaned, "additionalProperties", false)
+					tool, _ = util.SafeSetRawJSON(tool, "parameters", cleaned)
 				} else if prm = fn.Get("parametersJsonSchema"); prm.Exists() {
 					// Remove optional $schema field if present
 					cleaned := []byte(prm.Raw)
-					cleaned, _ = sjson.DeleteBytes(cleaned, "$schema")
-					cleaned, _ = sjson.SetBytes(cleaned, "additionalProperties", false)
-					tool, _ = sjson.SetRawBytes(tool, "parameters", cleaned)
+					cleaned, _ = util.SafeDeleteJSON(cleaned, "$schema")
+					cleaned, _ = util.SafeSetJSON(cleaned, "additionalProperties", false)
+					tool, _ = util.SafeSetRawJSON(tool, "parameters", cleaned)
 				}
-				tool, _ = sjson.SetBytes(tool, "strict", false)
-				out, _ = sjson.SetRawBytes(out, "tools.-1", tool)
+				tool, _ = util.SafeSetJSON(tool, "strict", false)
+				out, _ = util.SafeSetRawJSON(out, "tools.-1", tool)
 			}
 		}
 	}
 
 	// Fixed flags aligning with Codex expectations
-	out, _ = sjson.SetBytes(out, "parallel_tool_calls", true)
+	out, _ = util.SafeSetJSON(out, "parallel_tool_calls", true)
 
 	// Convert Gemini thinkingConfig to Codex reasoning.effort.
 	// Note: Google official Python SDK sends snake_case fields (thinking_level/thinking_budget).
@@ -253,7 +252,7 @@ func ConvertGeminiRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 			if thinkingLevel.Exists() {
 				effort := strings.ToLower(strings.TrimSpace(thinkingLevel.String()))
 				if effort != "" {
-					out, _ = sjson.SetBytes(out, "reasoning.effort", effort)
+					out, _ = util.SafeSetJSON(out, "reasoning.effort", effort)
 					effortSet = true
 				}
 			} else {
@@ -263,7 +262,7 @@ func ConvertGeminiRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 				}
 				if thinkingBudget.Exists() {
 					if effort, ok := thinking.ConvertBudgetToLevel(int(thinkingBudget.Int())); ok {
-						out, _ = sjson.SetBytes(out, "reasoning.effort", effort)
+						out, _ = util.SafeSetJSON(out, "reasoning.effort", effort)
 						effortSet = true
 					}
 				}
@@ -272,19 +271,19 @@ func ConvertGeminiRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 	}
 	if !effortSet {
 		// No thinking config, set default effort
-		out, _ = sjson.SetBytes(out, "reasoning.effort", "medium")
+		out, _ = util.SafeSetJSON(out, "reasoning.effort", "medium")
 	}
-	out, _ = sjson.SetBytes(out, "reasoning.summary", "auto")
-	out, _ = sjson.SetBytes(out, "stream", true)
-	out, _ = sjson.SetBytes(out, "store", false)
-	out, _ = sjson.SetBytes(out, "include", []string{"reasoning.encrypted_content"})
+	out, _ = util.SafeSetJSON(out, "reasoning.summary", "auto")
+	out, _ = util.SafeSetJSON(out, "stream", true)
+	out, _ = util.SafeSetJSON(out, "store", false)
+	out, _ = util.SafeSetJSON(out, "include", []string{"reasoning.encrypted_content"})
 
 	var pathsToLower []string
 	toolsResult := gjson.GetBytes(out, "tools")
 	util.Walk(toolsResult, "", "type", &pathsToLower)
 	for _, p := range pathsToLower {
 		fullPath := fmt.Sprintf("tools.%s", p)
-		out, _ = sjson.SetBytes(out, fullPath, strings.ToLower(gjson.GetBytes(out, fullPath).String()))
+		out, _ = util.SafeSetJSON(out, fullPath, strings.ToLower(gjson.GetBytes(out, fullPath).String()))
 	}
 
 	return out
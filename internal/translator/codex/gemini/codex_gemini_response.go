@@ -12,8 +12,8 @@ import (
 	"time"
 
 	translatorcommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/common"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 var (
@@ -69,13 +69,13 @@ func ConvertCodexResponseToGemini(_ context.Context, modelName string, originalR
 	// Base Gemini response template
 	template := []byte(`{"candidates":[{"content":{"role":"model","parts":[]}}],"usageMetadata":{"trafficType":"PROVISIONED_THROUGHPUT"},"modelVersion":"gemini-2.5-pro","createTime":"2025-08-15T02:52:03.884209Z","responseId":"06CeaPH7NaCU48APvNXDyA4"}`)
 	{
-		template, _ = sjson.SetBytes(template, "modelVersion", params.Model)
+		template, _ = util.SafeSetJSON(template, "modelVersion", params.Model)
 		createdAtResult := rootResult.Get("response.created_at")
 		if createdAtResult.Exists() {
 			params.CreatedAt = createdAtResult.Int()
-			template, _ = sjson.SetBytes(template, "createTime", time.Unix(params.CreatedAt, 0).Format(time.RFC3339Nano))
+			template, _ = util.SafeSetJSON(template, "createTime", time.Unix(params.CreatedAt, 0).Format(time.RFC3339Nano))
 		}
-		template, _ = sjson.SetBytes(template, "responseId", params.ResponseID)
+		template, _ = util.SafeSetJSON(template, "responseId", params.ResponseID)
 	}
 
 	if typeStr == "response.image_generation_call.partial_image" {
@@ -99,9 +99,9 @@ func ConvertCodexResponseToGemini(_ context.Context, modelName string, originalR
 		mimeType := mimeTypeFromCodexOutputFormat(outputFormat)
 
 		part := []byte(`{"inlineData":{"data":"","mimeType":""}}`)
-		part, _ = sjson.SetBytes(part, "inlineData.data", b64)
-		part, _ = sjson.SetBytes(part, "inlineData.mimeType", mimeType)
-		template, _ = sjson.SetRawBytes(template, "candidates.0.content.parts.-1", part)
+		part, _ = util.SafeSetJSON(part, "inlineData.data", b64)
+		part, _ = util.SafeSetJSON(part, "inlineData.mimeType", mimeType)
+		template, _ = util.SafeSetRawJSON(template, "candidates.0.content.parts.-1", part)
 		return [][]byte{template}
 	}
 
@@ -130,9 +130,9 @@ func ConvertCodexResponseToGemini(_ context.Context, modelName string, originalR
 			mimeType := mimeTypeFromCodexOutputFormat(outputFormat)
 
 			part := []byte(`{"inlineData":{"data":"","mimeType":""}}`)
-			part, _ = sjson.SetBytes(part, "inlineData.data", b64)
-			part, _ = sjson.SetBytes(part, "inlineData.mimeType", mimeType)
-			template, _ = sjson.SetRawBytes(template, "candidates.0.content.parts.-1", part)
+			part, _ = util.SafeSetJSON(part, "inlineData.data", b64)
+			part, _ = util.SafeSetJSON(part, "inlineData.mimeType", mimeType)
+			template, _ = util.SafeSetRawJSON(template, "candidates.0.content.parts.-1", part)
 			return [][]byte{template}
 		}
 		if itemType == "function_call" {
@@ -145,7 +145,7 @@ func ConvertCodexResponseToGemini(_ context.Context, modelName string, originalR
 				if orig, ok := rev[n]; ok {
 					n = orig
 				}
-				functionCall, _ = sjson.SetBytes(functionCall, "functionCall.name", n)
+				functionCall, _ = util.SafeSetJSON(functionCall, "functionCall.name", n)
 			}
 
 			// Parse and set arguments
@@ -153,12 +153,12 @@ func ConvertCodexResponseToGemini(_ context.Context, modelName string, originalR
 			if argsStr != "" {
 				argsResult := gjson.Parse(argsStr)
 				if argsResult.IsObject() {
-					functionCall, _ = sjson.SetRawBytes(functionCall, "functionCall.args", []byte(argsStr))
+					functionCall, _ = util.SafeSetRawJSON(functionCall, "functionCall.args", []byte(argsStr))
 				}
 			}
 
-			template, _ = sjson.SetRawBytes(template, "candidates.0.content.parts.-1", functionCall)
-			template, _ = sjson.SetBytes(template, "candidates.0.finishReason", "STOP")
+			template, _ = util.SafeSetRawJSON(template, "candidates.0.content.parts.-1", functionCall)
+			template, _ = util.SafeSetJSON(template, "candidates.0.finishReason", "STOP")
 
 			params.LastStorageOutput = append([]byte(nil), template...)
 
@@ -168,18 +168,18 @@ func ConvertCodexResponseToGemini(_ context.Context, modelName string, originalR
 	}
 
 	if typeStr == "response.created" { // Handle response creation - set model and response ID
-		template, _ = sjson.SetBytes(template, "modelVersion", rootResult.Get("response.model").String())
-		template, _ = sjson.SetBytes(template, "responseId", rootResult.Get("response.id").String())
+		template, _ = util.SafeSetJSON(template, "modelVersion", rootResult.Get("response.model").String())
+		template, _ = util.SafeSetJSON(template, "responseId", rootResult.Get("response.id").String())
 		params.ResponseID = rootResult.Get("response.id").String()
 	} else if typeStr == "response.reasoning_summary_text.delta" { // Handle reasoning/thinking content delta
 		part := []byte(`{"thought":true,"text":""}`)
-		part, _ = sjson.SetBytes(part, "text", rootResult.Get("delta").String())
-		template, _ = sjson.SetRawBytes(template, "candidates.0.content.parts.-1", part)
+		part, _ = util.SafeSetJSON(part, "text", rootResult.Get("delta").String())
+		template, _ = util.SafeSetRawJSON(template, "candidates.0.content.parts.-1", part)
 	} else if typeStr == "response.output_text.delta" { // Handle regular text content delta
 		params.HasOutputTextDelta = true
 		part := []byte(`{"text":""}`)
-		part, _ = sjson.SetBytes(part, "text", rootResult.Get("delta").String())
-		template, _ = sjson.SetRawBytes(template, "candidates.0.content.parts.-1", part)
+		part, _ = util.SafeSetJSON(part, "text", rootResult.Get("delta").String())
+		template, _ = util.SafeSetRawJSON(template, "candidates.0.content.parts.-1", part)
 	} else if typeStr == "response.output_item.done" { // Fallback: emit final message text when no delta chunks were received
 		itemResult := rootResult.Get("item")
 		if itemResult.Get("type").String() != "message" || params.HasOutputTextDelta {
@@ -199,8 +199,8 @@ func ConvertCodexResponseToGemini(_ context.Context, modelName string, originalR
 				return true
 			}
 			part := []byte(`{"text":""}`)
-			part, _ = sjson.SetBytes(part, "text", text)
-			template, _ = sjson.SetRawBytes(template, "candidates.0.content.parts.-1", part)
+			part, _ = util.SafeSetJSON(part, "text", text)
+			template, _ = util.SafeSetRawJSON(template, "candidates.0.content.parts.-1", part)
 			wroteText = true
 			return true
 		})
@@ -210,10 +210,10 @@ func ConvertCodexResponseToGemini(_ context.Context, modelName string, originalR
 		}
 		return [][]byte{}
 	} else if typeStr == "response.completed" { // Handle response completion with usage metadata
-		template, _ = sjson.SetBytes(template, "usageMetadata.promptTokenCount", rootResult.Get("response.usage.input_tokens").Int())
-		template, _ = sjson.SetBytes(template, "usageMetadata.candidatesTokenCount", rootResult.Get("response.usage.output_tokens").Int())
+		template, _ = util.SafeSetJSON(template, "usageMetadata.promptTokenCount", rootResult.Get("response.usage.input_tokens").Int())
+		template, _ = util.SafeSetJSON(template, "usageMetadata.candidatesTokenCount", rootResult.Get("response.usage.output_tokens").Int())
 		totalTokens := rootResult.Get("response.usage.input_tokens").Int() + rootResult.Get("response.usage.output_tokens").Int()
-		template, _ = sjson.SetBytes(template, "usageMetadata.totalTokenCount", totalTokens)
+		template, _ = util.SafeSetJSON(template, "usageMetadata.totalTokenCount", totalTokens)
 	} else {
 		return [][]byte{}
 	}
@@ -251,19 +251,19 @@ func ConvertCodexResponseToGeminiNonStream(_ context.Context, modelName string,
 	template := []byte(`{"candidates":[{"content":{"role":"model","parts":[]},"finishReason":"STOP"}],"usageMetadata":{"trafficType":"PROVISIONED_THROUGHPUT"},"modelVersion":"","createTime":"","responseId":""}`)
 
 	// Set model version
-	template, _ = sjson.SetBytes(template, "modelVersion", modelName)
+	template, _ = util.SafeSetJSON(template, "modelVersion", modelName)
 
 	// Set response metadata from the completed response
 	responseData := rootResult.Get("response")
 	if responseData.Exists() {
 		// Set response ID
 		if responseId := responseData.Get("id"); responseId.Exists() {
-			template, _ = sjson.SetBytes(template, "responseId", responseId.String())
+			template, _ = util.SafeSetJSON(template, "responseId", responseId.String())
 		}
 
 		// Set creation time
 		if createdAt := responseData.Get("created_at"); createdAt.Exists() {
-			template, _ = sjson.SetBytes(template, "createTime", time.Unix(createdAt.Int(), 0).Format(time.RFC3339Nano))
+			template, _ = util.SafeSetJSON(template, "createTime", time.Unix(createdAt.Int(), 0).Format(time.RFC3339Nano))
 		}
 
 		// Set usage metadata
@@ -272,9 +272,9 @@ func ConvertCodexResponseToGeminiNonStream(_ context.Context, modelName string,
 			outputTokens := usage.Get("output_tokens").Int()
 			totalTokens := inputTokens + outputTokens
 
-			template, _ = sjson.SetBytes(template, "usageMetadata.promptTokenCount", inputTokens)
-			template, _ = sjson.SetBytes(template, "usageMetadata.candidatesTokenCount", outputTokens)
-			template, _ = sjson.SetBytes(template, "usageMetadata.totalTokenCount", totalTokens)
+			template, _ = util.SafeSetJSON(template, "usageMetadata.promptTokenCount", inputTokens)
+			template, _ = util.SafeSetJSON(template, "usageMetadata.candidatesTokenCount", outputTokens)
+			template, _ = util.SafeSetJSON(template, "usageMetadata.totalTokenCount", totalTokens)
 		}
 
 		// Process output content to build parts array
@@ -288,7 +288,7 @@ func ConvertCodexResponseToGeminiNonStream(_ context.Context, modelName string,
 			// Add all pending function calls as individual parts
 			// This maintains the original Gemini API format while ensuring consecutive calls are grouped together
 			for _, fc := range pendingFunctionCalls {
-				template, _ = sjson.SetRawBytes(template, "candidates.0.content.parts.-1", fc)
+				template, _ = util.SafeSetRawJSON(template, "candidates.0.content.parts.-1", fc)
 			}
 			pendingFunctionCalls = nil
 		}
@@ -305,8 +305,8 @@ func ConvertCodexResponseToGeminiNonStream(_ context.Context, modelName string,
 					// Add thinking content
 					if content := value.Get("content"); content.Exists() {
 						part := []byte(`{"text":"","thought":true}`)
-						part, _ = sjson.SetBytes(part, "text", content.String())
-						template, _ = sjson.SetRawBytes(template, "candidates.0.content.parts.-1", part)
+						part, _ = util.SafeSetJSON(part, "text", content.String())
+						template, _ = util.SafeSetRawJSON(template, "candidates.0.content.parts.-1", part)
 					}
 
 				case "message":
@@ -319,8 +319,8 @@ func ConvertCodexResponseToGeminiNonStream(_ context.Context, modelName string,
 							if contentItem.Get("type").String() == "output_text" {
 								if text := contentItem.Get("text"); text.Exists() {
 									part := []byte(`{"text":""}`)
-									part, _ = sjson.SetBytes(part, "text", text.String())
-									template, _ = sjson.SetRawBytes(template, "candidates.0.content.parts.-1", part)
+									part, _ = util.SafeSetJSON(part, "text", text.String())
+									template, _ = util.SafeSetRawJSON(template, "candidates.0.content.parts.-1", part)
 								}
 							}
 							return true
@@ -337,9 +337,9 @@ func ConvertCodexResponseToGeminiNonStream(_ context.Context, modelName string,
 					mimeType := mimeTypeFromCodexOutputFormat(outputFormat)
 
 					part := []byte(`{"inlineData":{"data":"","mimeType":""}}`)
-					part, _ = sjson.SetBytes(part, "inlineData.data", b64)
-					part, _ = sjson.SetBytes(part, "inlineData.mimeType", mimeType)
-					template, _ = sjson.SetRawBytes(template, "candidates.0.content.parts.-1", part)
+					part, _ = util.SafeSetJSON(part, "inlineData.data", b64)
+					part, _ = util.SafeSetJSON(part, "inlineData.mimeType", mimeType)
+					template, _ = util.SafeSetRawJSON(template, "candidates.0.content.parts.-1", part)
 
 				case "function_call":
 					// Collect function call for potential merging with consecutive ones
@@ -351,14 +351,14 @@ func ConvertCodexResponseToGeminiNonStream(_ context.Context, modelName string,
 						if orig, ok := rev[n]; ok {
 							n = orig
 						}
-						functionCall, _ = sjson.SetBytes(functionCall, "functionCall.name", n)
+						functionCall, _ = util.SafeSetJSON(functionCall, "functionCall.name", n)
 					}
 
 					// Parse and set arguments
 					if argsStr := value.Get("arguments").String(); argsStr != "" {
 						argsResult := gjson.Parse(argsStr)
 						if argsResult.IsObject() {
-							functionCall, _ = sjson.SetRawBytes(functionCall, "functionCall.args", []byte(argsStr))
+							functionCall, _ = util.SafeSetRawJSON(functionCall, "functionCall.args", []byte(argsStr))
 						}
 					}
 
@@ -373,9 +373,9 @@ func ConvertCodexResponseToGeminiNonStream(_ context.Context, modelName string,
 
 		// Set finish reason based on whether there were tool calls
 		if hasToolCall {
-			template, _ = sjson.SetBytes(template, "candidates.0.finishReason", "STOP")
+			template, _ = util.SafeSetJSON(template, "candidates.0.finishReason", "STOP")
 		} else {
-			template, _ = sjson.SetBytes(template, "candidates.0.finishReason", "STOP")
+			template, _ = util.SafeSetJSON(template, "candidates.0.finishReason", "STOP")
 		}
 	}
 	return template
@@ -10,8 +10,8 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 // ConvertOpenAIRequestToCodex converts an OpenAI Chat Completions request JSON
@@ -32,39 +32,39 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 	out := []byte(`{"instructions":""}`)
 
 	// Stream must be set to true
-	out, _ = sjson.SetBytes(out, "stream", stream)
+	out, _ = util.SafeSetJSON(out, "stream", stream)
 
 	// Codex not support temperature, top_p, top_k, max_output_tokens, so comment them
 	// if v := gjson.GetBytes(rawJSON, "temperature"); v.Exists() {
-	// 	out, _ = sjson.SetBytes(out, "temperature", v.Value())
+	// 	out, _ = util.SafeSetJSON(out, "temperature", v.Value())
 	// }
 	// if v := gjson.GetBytes(rawJSON, "top_p"); v.Exists() {
-	// 	out, _ = sjson.SetBytes(out, "top_p", v.Value())
+	// 	out, _ = util.SafeSetJSON(out, "top_p", v.Value())
 	// }
 	// if v := gjson.GetBytes(rawJSON, "top_k"); v.Exists() {
-	// 	out, _ = sjson.SetBytes(out, "top_k", v.Value())
+	// 	out, _ = util.SafeSetJSON(out, "top_k", v.Value())
 	// }
 
 	// Map token limits
 	// if v := gjson.GetBytes(rawJSON, "max_tokens"); v.Exists() {
-	// 	out, _ = sjson.SetBytes(out, "max_output_tokens", v.Value())
+	// 	out, _ = util.SafeSetJSON(out, "max_output_tokens", v.Value())
 	// }
 	// if v := gjson.GetBytes(rawJSON, "max_completion_tokens"); v.Exists() {
-	// 	out, _ = sjson.SetBytes(out, "max_output_tokens", v.Value())
+	// 	out, _ = util.SafeSetJSON(out, "max_output_tokens", v.Value())
 	// }
 
 	// Map reasoning effort
 	if v := gjson.GetBytes(rawJSON, "reasoning_effort"); v.Exists() {
-		out, _ = sjson.SetBytes(out, "reasoning.effort", v.Value())
+		out, _ = util.SafeSetJSON(out, "reasoning.effort", v.Value())
 	} else {
-		out, _ = sjson.SetBytes(out, "reasoning.effort", "medium")
+		out, _ = util.SafeSetJSON(out, "reasoning.effort", "medium")
 	}
-	out, _ = sjson.SetBytes(out, "parallel_tool_calls", true)
-	out, _ = sjson.SetBytes(out, "reasoning.summary", "auto")
-	out, _ = sjson.SetBytes(out, "include", []string{"reasoning.encrypted_content"})
+	out, _ = util.SafeSetJSON(out, "parallel_tool_calls", true)
+	out, _ = util.SafeSetJSON(out, "reasoning.summary", "auto")
+	out, _ = util.SafeSetJSON(out, "include", []string{"reasoning.encrypted_content"})
 
 	// Model
-	out, _ = sjson.SetBytes(out, "model", modelName)
+	out, _ = util.SafeSetJSON(out, "model", modelName)
 
 	// Build tool name shortening map from original tools (if any)
 	originalToolNameMap := map[string]string{}
@@ -100,9 +100,9 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 	// 		if m.Get("role").String() == "system" {
 	// 			c := m.Get("content")
 	// 			if c.Type == gjson.String {
-	// 				out, _ = sjson.SetBytes(out, "instructions", c.String())
+	// 				out, _ = util.SafeSetJSON(out, "instructions", c.String())
 	// 			} else if c.IsObject() && c.Get("type").String() == "text" {
-	// 				out, _ = sjson.SetBytes(out, "instructions", c.Get("text").String())
+	// 				out, _ = util.SafeSetJSON(out, "instructions", c.Get("text").String())
 	// 			}
 	// 			break
 	// 		}
@@ -110,7 +110,7 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 	// }
 
 	// Build input from messages, handling all message types including tool calls
-	out, _ = sjson.SetRawBytes(out, "input", []byte(`[]`))
+	out, _ = util.SafeSetRawJSON(out, "input", []byte(`[]`))
 	if messages.IsArray() {
 		arr := messages.Array()
 		for i := 0; i < len(arr); i++ {
@@ -125,22 +125,22 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 
 				// Create function_call_output object
 				funcOutput := []byte(`{}`)
-				funcOutput, _ = sjson.SetBytes(funcOutput, "type", "function_call_output")
-				funcOutput, _ = sjson.SetBytes(funcOutput, "call_id", toolCallID)
-				funcOutput, _ = sjson.SetBytes(funcOutput, "output", content)
-				out, _ = sjson.SetRawBytes(out, "input.-1", funcOutput)
+				funcOutput, _ = util.SafeSetJSON(funcOutput, "type", "function_call_output")
+				funcOutput, _ = util.SafeSetJSON(funcOutput, "call_id", toolCallID)
+				funcOutput, _ = util.SafeSetJSON(funcOutput, "output", content)
+				out, _ = util.SafeSetRawJSON(out, "input.-1", funcOutput)
 
 			default:
 				// Handle regular messages
 				msg := []byte(`{}`)
-				msg, _ = sjson.SetBytes(msg, "type", "message")
+				msg, _ = util.SafeSetJSON(msg, "type", "message")
 				if role == "system" {
-					msg, _ = sjson.SetBytes(msg, "role", "developer")
+					msg, _ = util.SafeSetJSON(msg, "role", "developer")
 				} else {
-					msg, _ = sjson.SetBytes(msg, "role", role)
+					msg, _ = util.SafeSetJSON(msg, "role", role)
 				}
 
-				msg, _ = sjson.SetRawBytes(msg, "content", []byte(`[]`))
+				msg, _ = util.SafeSetRawJSON(msg, "content", []byte(`[]`))
 
 				// Handle regular content
 				c := m.Get("content")
@@ -151,9 +151,9 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 						partType = "output_text"
 					}
 					part := []byte(`{}`)
-					part, _ = sjson.SetBytes(part, "type", partType)
-					part, _ = sjson.SetBytes(part, "text", c.String())
-					msg, _ = sjson.SetRawBytes(msg, "content.-1", part)
+					part, _ = util.SafeSetJSON(part, "type", partType)
+					part, _ = util.SafeSetJSON(part, "text", c.String())
+					msg, _ = util.SafeSetRawJSON(msg, "content.-1", part)
 				} else if c.Exists() && c.IsArray() {
 					items := c.Array()
 					for j := 0; j < len(items); j++ {
@@ -166,18 +166,18 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 								partType = "output_text"
 							}
 							part := []byte(`{}`)
-							part, _ = sjson.SetBytes(part, "type", partType)
-							part, _ = sjson.SetBytes(part, "text", it.Get("text").String())
-							msg, _ = sjson.SetRawBytes(msg, "content.-1", part)
+							part, _ = util.SafeSetJSON(part, "type", partType)
+							part, _ = util.SafeSetJSON(part, "text", it.Get("text").String())
+							msg, _ = util.SafeSetRawJSON(msg, "content.-1", part)
 						case "image_url":
 							// Map image inputs to input_image for Responses API
 							if role == "user" {
 								part := []byte(`{}`)
-								part, _ = sjson.SetBytes(part, "type", "input_image")
+								part, _ = util.SafeSetJSON(part, "type", "input_image")
 								if u := it.Get("image_url.url"); u.Exists() {
-									part, _ = sjson.SetBytes(part, "image_url", u.String())
+									part, _ = util.SafeSetJSON(part, "image_url", u.String())
 								}
-								msg, _ = sjson.SetRawBytes(msg, "content.-1", part)
+								msg, _ = util.SafeSetRawJSON(msg, "content.-1", part)
 							}
 						case "file":
 							if role == "user" {
@@ -185,12 +185,12 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 								filename := it.Get("file.filename").String()
 								if fileData != "" {
 									part := []byte(`{}`)
-									part, _ = sjson.SetBytes(part, "type", "input_file")
-									part, _ = sjson.SetBytes(part, "file_data", fileData)
+									part, _ = util.SafeSetJSON(part, "type", "input_file")
+									part, _ = util.SafeSetJSON(part, "file_data", fileData)
 									if filename != "" {
-										part, _ = sjson.SetBytes(part, "filename", filename)
+										part, _ = util.SafeSetJSON(part, "filename", filename)
 									}
-									msg, _ = sjson.SetRawBytes(msg, "content.-1", part)
+									msg, _ = util.SafeSetRawJSON(msg, "content.-1", part)
 								}
 							}
 						}
@@ -201,7 +201,7 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 				// are present — Responses API needs function_call items
 				// directly, otherwise call_id matching fails (#2132).
 				if role != "assistant" || len(gjson.GetBytes(msg, "content").Array()) > 0 {
-					out, _ = sjson.SetRawBytes(out, "input.-1", msg)
+					out, _ = util.SafeSetRawJSON(out, "input.-1", msg)
 				}
 
 				// Handle tool calls for assistant messages as separate top-level objects
@@ -214,8 +214,8 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 							if tc.Get("type").String() == "function" {
 								// Create function_call as top-level object
 								funcCall := []byte(`{}`)
-								funcCall, _ = sjson.SetBytes(funcCall, "type", "function_call")
-								funcCall, _ = sjson.SetBytes(funcCall, "call_id", tc.Get("id").String())
+								funcCall, _ = util.SafeSetJSON(funcCall, "type", "function_call")
+								funcCall, _ = util.SafeSetJSON(funcCall, "call_id", tc.Get("id").String())
 								{
 									name := tc.Get("function.name").String()
 									if short, ok := originalToolNameMap[name]; ok {
@@ -223,10 +223,10 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 									} else {
 										name = shortenNameIfNeeded(name)
 									}
-									funcCall, _ = sjson.SetBytes(funcCall, "name", name)
+									funcCall, _ = util.SafeSetJSON(funcCall, "name", name)
 								}
-								funcCall, _ = sjson.SetBytes(funcCall, "arguments", tc.Get("function.arguments").String())
-								out, _ = sjson.SetRawBytes(out, "input.-1", funcCall)
+								funcCall, _ = util.SafeSetJSON(funcCall, "arguments", tc.Get("function.arguments").String())
+								out, _ = util.SafeSetRawJSON(out, "input.-1", funcCall)
 							}
 						}
 					}
@@ -241,25 +241,25 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 	if rf.Exists() {
 		// Always create text object when response_format provided
 		if !gjson.GetBytes(out, "text").Exists() {
-			out, _ = sjson.SetRawBytes(out, "text", []byte(`{}`))
+			out, _ = util.SafeSetRawJSON(out, "text", []byte(`{}`))
 		}
 
 		rft := rf.Get("type").String()
 		switch rft {
 		case "text":
-			out, _ = sjson.SetBytes(out, "text.format.type", "text")
+			out, _ = util.SafeSetJSON(out, "text.format.type", "text")
 		case "json_schema":
 			js := rf.Get("json_schema")
 			if js.Exists() {
-				out, _ = sjson.SetBytes(out, "text.format.type", "json_schema")
+				out, _ = util.SafeSetJSON(out, "text.format.type", "json_schema")
 				if v := js.Get("name"); v.Exists() {
-					out, _ = sjson.SetBytes(out, "text.format.name", v.Value())
+					out, _ = util.SafeSetJSON(out, "text.format.name", v.Value())
 				}
 				if v := js.Get("strict"); v.Exists() {
-					out, _ = sjson.SetBytes(out, "text.format.strict", v.Value())
+					out, _ = util.SafeSetJSON(out, "text.format.strict", v.Value())
 				}
 				if v := js.Get("schema"); v.Exists() {
-					out, _ = sjson.SetRawBytes(out, "text.format.schema", []byte(v.Raw))
+					out, _ = util.SafeSetRawJSON(out, "text.format.schema", []byte(v.Raw))
 				}
 			}
 		}
@@ -267,23 +267,23 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 		// Map verbosity if provided
 		if text.Exists() {
 			if v := text.Get("verbosity"); v.Exists() {
-				out, _ = sjson.SetBytes(out, "text.verbosity", v.Value())
+				out, _ = util.SafeSetJSON(out, "text.verbosity", v.Value())
 			}
 		}
 	} else if text.Exists() {
 		// If only text.verbosity present (no response_format), map verbosity
 		if v := text.Get("verbosity"); v.Exists() {
 			if !gjson.GetBytes(out, "text").Exists() {
-				out, _ = sjson.SetRawBytes(out, "text", []byte(`{}`))
+				out, _ = util.SafeSetRawJSON(out, "text", []byte(`{}`))
 			}
-			out, _ = sjson.SetBytes(out, "text.verbosity", v.Value())
+			out, _ = util.SafeSetJSON(out, "text.verbosity", v.Value())
 		}
 	}
 
 	// Map tools (flatten function fields)
 	tools := gjson.GetBytes(rawJSON, "tools")
 	if tools.IsArray() && len(tools.Array()) > 0 {
-		out, _ = sjson.SetRawBytes(out, "tools", []byte(`[]`))
+		out, _ = util.SafeSetRawJSON(out, "tools", []byte(`[]`))
 		arr := tools.Array()
 		for i := 0; i < len(arr); i++ {
 			t := arr[i]
@@ -291,13 +291,13 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 			// Pass through built-in tools (e.g. {"type":"web_search"}) directly for the Responses API.
 			// Only "function" needs structural conversion because Chat Completions nests details under "function".
 			if toolType != "" && toolType != "function" && t.IsObject() {
-				out, _ = sjson.SetRawBytes(out, "tools.-1", []byte(t.Raw))
+				out, _ = util.SafeSetRawJSON(out, "tools.-1", []byte(t.Raw))
 				continue
 			}
 
 			if toolType == "function" {
 				item := []byte(`{}`)
-				item, _ = sjson.SetBytes(item, "type", "function")
+				item, _ = util.SafeSetJSON(item, "type", "function")
 				fn := t.Get("function")
 				if fn.Exists() {
 					if v := fn.Get("name"); v.Exists() {
@@ -307,19 +307,19 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 						} else {
 							name = shortenNameIfNeeded(name)
 						}
-						item, _ = sjson.SetBytes(item, "name", name)
+						item, _ = util.SafeSetJSON(item, "name", name)
 					}
 					if v := fn.Get("description"); v.Exists() {
-						item, _ = sjson.SetBytes(item, "description", v.Value())
+						item, _ = util.SafeSetJSON(item, "description", v.Value())
 					}
 					if v := fn.Get("parameters"); v.Exists() {
-						item, _ = sjson.SetRawBytes(item, "parameters", []byte(v.Raw))
+						item, _ = util.SafeSetRawJSON(item, "parameters", []byte(v.Raw))
 					}
 					if v := fn.Get("strict"); v.Exists() {
-						item, _ = sjson.SetBytes(item, "strict", v.Value())
+						item, _ = util.SafeSetJSON(item, "strict", v.Value())
 					}
 				}
-				out, _ = sjson.SetRawBytes(out, "tools.-1", item)
+				out, _ = util.SafeSetRawJSON(out, "tools.-1", item)
 			}
 		}
 	}
@@ -330,7 +330,7 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 	if tc := gjson.GetBytes(rawJSON, "tool_choice"); tc.Exists() {
 		switch {
 		case tc.Type == gjson.String:
-			out, _ = sjson.SetBytes(out, "tool_choice", tc.String())
+			out, _ = util.SafeSetJSON(out, "tool_choice", tc.String())
 		case tc.IsObject():
 			tcType := tc.Get("type").String()
 			if tcType == "function" {
@@ -343,19 +343,19 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 					}
 				}
 				choice := []byte(`{}`)
-				choice, _ = sjson.SetBytes(choice, "type", "function")
+				choice, _ = util.SafeSetJSON(choice, "type", "function")
 				if name != "" {
-					choice, _ = sjson.SetBytes(choice, "name", name)
+					choice, _ = util.SafeSetJSON(choice, "name", name)
 				}
-				out, _ = sjson.SetRawBytes(out, "tool_choice", choice)
+				out, _ = util.SafeSetRawJSON(out, "tool_choice", choice)
 			} else if tcType != "" {
 				// Built-in tool choices (e.g. {"type":"web_search"}) are already Responses-compatible.
-				out, _ = sjson.SetRawBytes(out, "tool_choice", []byte(tc.Raw))
+				out, _ = util.SafeSetRawJSON(out, "tool_choice", []byte(tc.Raw))
 			}
 		}
 	}
 
-	out, _ = sjson.SetBytes(out, "store", false)
+	out, _ = util.SafeSetJSON(out, "store", false)
 	return out
 }
 
@@ -12,8 +12,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 var (
@@ -83,49 +83,49 @@ func ConvertCodexResponseToOpenAI(_ context.Context, modelName string, originalR
 	// Extract and set the model version.
 	cachedModel := (*param).(*ConvertCliToOpenAIParams).Model
 	if modelResult := gjson.GetBytes(rawJSON, "model"); modelResult.Exists() {
-		template, _ = sjson.SetBytes(template, "model", modelResult.String())
+		template, _ = util.SafeSetJSON(template, "model", modelResult.String())
 	} else if cachedModel != "" {
-		template, _ = sjson.SetBytes(template, "model", cachedModel)
+		template, _ = util.SafeSetJSON(template, "model", cachedModel)
 	} else if modelName != "" {
-		template, _ = sjson.SetBytes(template, "model", modelName)
+		template, _ = util.SafeSetJSON(template, "model", modelName)
 	}
 
-	template, _ = sjson.SetBytes(template, "created", (*param).(*ConvertCliToOpenAIParams).CreatedAt)
+	template, _ = util.SafeSetJSON(template, "created", (*param).(*ConvertCliToOpenAIParams).CreatedAt)
 
 	// Extract and set the response ID.
-	template, _ = sjson.SetBytes(template, "id", (*param).(*ConvertCliToOpenAIParams).ResponseID)
+	template, _ = util.SafeSetJSON(template, "id", (*param).(*ConvertCliToOpenAIParams).ResponseID)
 
 	// Extract and set usage metadata (token counts).
 	if usageResult := gjson.GetBytes(rawJSON, "response.usage"); usageResult.Exists() {
 		if outputTokensResult := usageResult.Get("output_tokens"); outputTokensResult.Exists() {
-			template, _ = sjson.SetBytes(template, "usage.completion_tokens", outputTokensResult.Int())
+			template, _ = util.SafeSetJSON(template, "usage.completion_tokens", outputTokensResult.Int())
 		}
 		if totalTokensResult := usageResult.Get("total_tokens"); totalTokensResult.Exists() {
-			template, _ = sjson.SetBytes(template, "usage.total_tokens", totalTokensResult.Int())
+			template, _ = util.SafeSetJSON(template, "usage.total_tokens", totalTokensResult.Int())
 		}
 		if inputTokensResult := usageResult.Get("input_tokens"); inputTokensResult.Exists() {
-			template, _ = sjson.SetBytes(template, "usage.prompt_tokens", inputTokensResult.Int())
+			template, _ = util.SafeSetJSON(template, "usage.prompt_tokens", inputTokensResult.Int())
 		}
 		if cachedTokensResult := usageResult.Get("input_tokens_details.cached_tokens"); cachedTokensResult.Exists() {
-			template, _ = sjson.SetBytes(template, "usage.prompt_tokens_details.cached_tokens", cachedTokensResult.Int())
+			template, _ = util.SafeSetJSON(template, "usage.prompt_tokens_details.cached_tokens", cachedTokensResult.Int())
 		}
 		if reasoningTokensResult := usageResult.Get("output_tokens_details.reasoning_tokens"); reasoningTokensResult.Exists() {
-			template, _ = sjson.SetBytes(template, "usage.completion_tokens_details.reasoning_tokens", reasoningTokensResult.Int())
+			template, _ = util.SafeSetJSON(template, "usage.completion_tokens_details.reasoning_tokens", reasoningTokensResult.Int())
 		}
 	}
 
 	if dataType == "response.reasoning_summary_text.delta" {
 		if deltaResult := rootResult.Get("delta"); deltaResult.Exists() {
-			template, _ = sjson.SetBytes(template, "choices.0.delta.role", "assistant")
-			template, _ = sjson.SetBytes(template, "choices.0.delta.reasoning_content", deltaResult.String())
+			template, _ = util.SafeSetJSON(template, "choices.0.delta.role", "assistant")
+			template, _ = util.SafeSetJSON(template, "choices.0.delta.reasoning_content", deltaResult.String())
 		}
 	} else if dataType == "response.reasoning_summary_text.done" {
-		template, _ = sjson.SetBytes(template, "choices.0.delta.role", "assistant")
-		template, _ = sjson.SetBytes(template, "choices.0.delta.reasoning_content", "\n\n")
+		template, _ = util.SafeSetJSON(template, "choices.0.delta.role", "assistant")
+		template, _ = util.SafeSetJSON(template, "choices.0.delta.reasoning_content", "\n\n")
 	} else if dataType == "response.output_text.delta" {
 		if deltaResult := rootResult.Get("delta"); deltaResult.Exists() {
-			template, _ = sjson.SetBytes(template, "choices.0.delta.role", "assistant")
-			template, _ = sjson.SetBytes(template, "choices.0.delta.content", deltaResult.String())
+			template, _ = util.SafeSetJSON(template, "choices.0.delta.role", "assistant")
+			template, _ = util.SafeSetJSON(template, "choices.0.delta.content", deltaResult.String())
 		}
 	} else if dataType == "response.image_generation_call.partial_image" {
 		itemID := rootResult.Get("item_id").String()
@@ -151,22 +151,22 @@ func ConvertCodexResponseToOpenAI(_ context.Context, modelName string, originalR
 
 		imagesResult := gjson.GetBytes(template, "choices.0.delta.images")
 		if !imagesResult.Exists() || !imagesResult.IsArray() {
-			template, _ = sjson.SetRawBytes(template, "choices.0.delta.images", []byte(`[]`))
+			template, _ = util.SafeSetRawJSON(template, "choices.0.delta.images", []byte(`[]`))
 		}
 		imageIndex := len(gjson.GetBytes(template, "choices.0.delta.images").Array())
 		imagePayload := []byte(`{"type":"image_url","image_url":{"url":""}}`)
-		imagePayload, _ = sjson.SetBytes(imagePayload, "index", imageIndex)
-		imagePayload, _ = sjson.SetBytes(imagePayload, "image_url.url", imageURL)
+		imagePayload, _ = util.SafeSetJSON(imagePayload, "index", imageIndex)
+		imagePayload, _ = util.SafeSetJSON(imagePayload, "image_url.url", imageURL)
 
-		template, _ = sjson.SetBytes(template, "choices.0.delta.role", "assistant")
-		template, _ = sjson.SetRawBytes(template, "choices.0.delta.images.-1", imagePayload)
+		template, _ = util.SafeSetJSON(template, "choices.0.delta.role", "assistant")
+		template, _ = util.SafeSetRawJSON(template, "choices.0.delta.images.-1", imagePayload)
 	} else if dataType == "response.completed" {
 		finishReason := "stop"
 		if (*param).(*ConvertCliToOpenAIParams).FunctionCallIndex != -1 {
 			finishReason = "tool_calls"
 		}
-		template, _ = sjson.SetBytes(template, "choices.0.finish_reason", finishReason)
-		template, _ = sjson.SetBytes(template, "choices.0.native_finish_reason", finishReason)
+		template, _ = util.SafeSetJSON(template, "choices.0.finish_reason", finishReason)
+		template, _ = util.SafeSetJSON(template, "choices.0.native_finish_reason", finishReason)
 	} else if dataType == "response.output_item.added" {
 		itemResult := rootResult.Get("item")
 		if !itemResult.Exists() || itemResult.Get("type").String() != "function_call" {
@@ -179,8 +179,8 @@ func ConvertCodexResponseToOpenAI(_ context.Context, modelName string, originalR
 		(*param).(*ConvertCliToOpenAIParams).HasToolCallAnnounced = true
 
 		functionCallItemTemplate := []byte(`{"index":0,"id":"","type":"function","function":{"name":"","arguments":""}}`)
-		functionCallItemTemplate, _ = sjson.SetBytes(functionCallItemTemplate, "index", (*param).(*ConvertCliToOpenAIParams).FunctionCallIndex)
-		functionCallItemTemplate, _ = sjson.SetBytes(functionCallItemTemplate, "id", itemResult.Get("call_id").String())
+		functionCallItemTemplate, _ = util.SafeSetJSON(functionCallItemTemplate, "index", (*param).(*ConvertCliToOpenAIParams).FunctionCallIndex)
+		functionCallItemTemplate, _ = util.SafeSetJSON(functionCallItemTemplate, "id", itemResult.Get("call_id").String())
 
 		// Restore original tool name if it was shortened.
 		name := itemResult.Get("name").String()
@@ -188,23 +188,23 @@ func ConvertCodexResponseToOpenAI(_ context.Context, modelName string, originalR
 		if orig, ok := rev[name]; ok {
 			name = orig
 		}
-		functionCallItemTemplate, _ = sjson.SetBytes(functionCallItemTemplate, "function.name", name)
-		functionCallItemTemplate, _ = sjson.SetBytes(functionCallItemTemplate, "function.arguments", "")
+		functionCallItemTemplate, _ = util.SafeSetJSON(functionCallItemTemplate, "function.name", name)
+		functionCallItemTemplate, _ = util.SafeSetJSON(functionCallItemTemplate, "function.arguments", "")
 
-		template, _ = sjson.SetBytes(template, "choices.0.delta.role", "assistant")
-		template, _ = sjson.SetRawBytes(template, "choices.0.delta.tool_calls", []byte(`[]`))
-		template, _ = sjson.SetRawBytes(template, "choices.0.delta.tool_calls.-1", functionCallItemTemplate)
+		template, _ = util.SafeSetJSON(template, "choices.0.delta.role", "assistant")
+		template, _ = util.SafeSetRawJSON(template, "choices.0.delta.tool_calls", []byte(`[]`))
+		template, _ = util.SafeSetRawJSON(template, "choices.0.delta.tool_calls.-1", functionCallItemTemplate)
 
 	} else if dataType == "response.function_call_arguments.delta" {
 		(*param).(*ConvertCliToOpenAIParams).HasReceivedArgumentsDelta = true
 
 		deltaValue := rootResult.Get("delta").String()
 		functionCallItemTemplate := []byte(`{"index":0,"function":{"arguments":""}}`)
-		functionCallItemTemplate, _ = sjson.SetBytes(functionCallItemTemplate, "index", (*param).(*ConvertCliToOpenAIParams).FunctionCallIndex)
-		functionCallItemTemplate, _ = sjson.SetBytes(functionCallItemTemplate, "function.arguments", deltaValue)
+		functionCallItemTemplate, _ = util.SafeSetJSON(functionCallItemTemplate, "index", (*param).(*ConvertCliToOpenAIParams).FunctionCallIndex)
+		functionCallItemTemplate, _ = util.SafeSetJSON(functionCallItemTemplate, "function.arguments", deltaValue)
 
-		template, _ = sjson.SetRawBytes(template, "choices.0.delta.tool_calls", []byte(`[]`))
-		template, _ = sjson.SetRawBytes(template, "choices.0.delta.tool_calls.-1", functionCallItemTemplate)
+		template, _ = util.SafeSetRawJSON(template, "choices.0.delta.tool_calls", []byte(`[]`))
+		template, _ = util.SafeSetRawJSON(template, "choices.0.delta.tool_calls.-1", functionCallItemTemplate)
 
 	} else if dataType == "response.function_call_arguments.done" {
 		if (*param).(*ConvertCliToOpenAIParams).HasReceivedArgumentsDelta {
@@ -215,11 +215,11 @@ func ConvertCodexResponseToOpenAI(_ context.Context, modelName string, originalR
 		// Fallback: no delta events were received, emit the full arguments as a single chunk.
 		fullArgs := rootResult.Get("arguments").String()
 		functionCallItemTemplate := []byte(`{"index":0,"function":{"arguments":""}}`)
-		functionCallItemTemplate, _ = sjson.SetBytes(functionCallItemTemplate, "index", (*param).(*ConvertCliToOpenAIParams).FunctionCallIndex)
-		functionCallItemTemplate, _ = sjson.SetBytes(functionCallItemTemplate, "function.arguments", fullArgs)
+		functionCallItemTemplate, _ = util.SafeSetJSON(functionCallItemTemplate, "index", (*param).(*ConvertCliToOpenAIParams).FunctionCallIndex)
+		functionCallItemTemplate, _ = util.SafeSetJSON(functionCallItemTemplate, "function.arguments", fullArgs)
 
-		template, _ = sjson.SetRawBytes(template, "choices.0.delta.tool_calls", []byte(`[]`))
-		template, _ = sjson.SetRawBytes(template, "choices.0.delta.tool_calls.-1", functionCallItemTemplate)
+		template, _ = util.SafeSetRawJSON(template, "choices.0.delta.tool_calls", []byte(`[]`))
+		template, _ = util.SafeSetRawJSON(template, "choices.0.delta.tool_calls.-1", functionCallItemTemplate)
 
 	} else if dataType == "response.output_item.done" {
 		itemResult := rootResult.Get("item")
@@ -251,15 +251,15 @@ func ConvertCodexResponseToOpenAI(_ context.Context, modelName string, originalR
 
 			imagesResult := gjson.GetBytes(template, "choices.0.delta.images")
 			if !imagesResult.Exists() || !imagesResult.IsArray() {
-				template, _ = sjson.SetRawBytes(template, "choices.0.delta.images", []byte(`[]`))
+				template, _ = util.SafeSetRawJSON(template, "choices.0.delta.images", []byte(`[]`))
 			}
 			imageIndex := len(gjson.GetBytes(template, "choices.0.delta.images").Array())
 			imagePayload := []byte(`{"type":"image_url","image_url":{"url":""}}`)
-			imagePayload, _ = sjson.SetBytes(imagePayload, "index", imageIndex)
-			imagePayload, _ = sjson.SetBytes(imagePayload, "image_url.url", imageURL)
+			imagePayload, _ = util.SafeSetJSON(imagePayload, "index", imageIndex)
+			imagePayload, _ = util.SafeSetJSON(imagePayload, "image_url.url", imageURL)
 
-			template, _ = sjson.SetBytes(template, "choices.0.delta.role", "assistant")
-			template, _ = sjson.SetRawBytes(template, "choices.0.delta.images.-1", imagePayload)
+			template, _ = util.SafeSetJSON(template, "choices.0.delta.role", "assistant")
+			template, _ = util.SafeSetRawJSON(template, "choices.0.delta.images.-1", imagePayload)
 			return [][]byte{template}
 		}
 		if itemType != "function_call" {
@@ -276,10 +276,10 @@ func ConvertCodexResponseToOpenAI(_ context.Context, modelName string, originalR
 		(*param).(*ConvertCliToOpenAIParams).FunctionCallIndex++
 
 		functionCallItemTemplate := []byte(`{"index":0,"id":"","type":"function","function":{"name":"","arguments":""}}`)
-		functionCallItemTemplate, _ = sjson.SetBytes(functionCallItemTemplate, "index", (*param).(*ConvertCliToOpenAIParams).FunctionCallIndex)
+		functionCallItemTemplate, _ = util.SafeSetJSON(functionCallItemTemplate, "index", (*param).(*ConvertCliToOpenAIParams).FunctionCallIndex)
 
-		template, _ = sjson.SetRawBytes(template, "choices.0.delta.tool_calls", []byte(`[]`))
-		functionCallItemTemplate, _ = sjson.SetBytes(functionCallItemTemplate, "id", itemResult.Get("call_id").String())
+		template, _ = util.SafeSetRawJSON(template, "choices.0.delta.tool_calls", []byte(`[]`))
+		functionCallItemTemplate, _ = util.SafeSetJSON(functionCallItemTemplate, "id", itemResult.Get("call_id").String())
 
 		// Restore original tool name if it was shortened.
 		name := itemResult.Get("name").String()
@@ -287,11 +287,11 @@ func ConvertCodexResponseToOpenAI(_ context.Context, modelName string, originalR
 		if orig, ok := rev[name]; ok {
 			name = orig
 		}
-		functionCallItemTemplate, _ = sjson.SetBytes(functionCallItemTemplate, "function.name", name)
+		functionCallItemTemplate, _ = util.SafeSetJSON(functionCallItemTemplate, "function.name", name)
 
-		functionCallItemTemplate, _ = sjson.SetBytes(functionCallItemTemplate, "function.arguments", itemResult.Get("arguments").String())
-		template, _ = sjson.SetBytes(template, "choices.0.delta.role", "assistant")
-		template, _ = sjson.SetRawBytes(template, "choices.0.delta.tool_calls.-1", functionCallItemTemplate)
+		functionCallItemTemplate, _ = util.SafeSetJSON(functionCallItemTemplate, "function.arguments", itemResult.Get("arguments").String())
+		template, _ = util.SafeSetJSON(template, "choices.0.delta.role", "assistant")
+		template, _ = util.SafeSetRawJSON(template, "choices.0.delta.tool_calls.-1", functionCallItemTemplate)
 
 	} else {
 		return [][]byte{}
@@ -328,37 +328,37 @@ func ConvertCodexResponseToOpenAINonStream(_ context.Context, _ string, original
 
 	// Extract and set the model version.
 	if modelResult := responseResult.Get("model"); modelResult.Exists() {
-		template, _ = sjson.SetBytes(template, "model", modelResult.String())
+		template, _ = util.SafeSetJSON(template, "model", modelResult.String())
 	}
 
 	// Extract and set the creation timestamp.
 	if createdAtResult := responseResult.Get("created_at"); createdAtResult.Exists() {
-		template, _ = sjson.SetBytes(template, "created", createdAtResult.Int())
+		template, _ = util.SafeSetJSON(template, "created", createdAtResult.Int())
 	} else {
-		template, _ = sjson.SetBytes(template, "created", unixTimestamp)
+		template, _ = util.SafeSetJSON(template, "created", unixTimestamp)
 	}
 
 	// Extract and set the response ID.
 	if idResult := responseResult.Get("id"); idResult.Exists() {
-		template, _ = sjson.SetBytes(template, "id", idResult.String())
+		template, _ = util.SafeSetJSON(template, "id", idResult.String())
 	}
 
 	// Extract and set usage metadata (token counts).
 	if usageResult := responseResult.Get("usage"); usageResult.Exists() {
 		if outputTokensResult := usageResult.Get("output_tokens"); outputTokensResult.Exists() {
-			template, _ = sjson.SetBytes(template, "usage.completion_tokens", outputTokensResult.Int())
+			template, _ = util.SafeSetJSON(template, "usage.completion_tokens", outputTokensResult.Int())
 		}
 		if totalTokensResult := usageResult.Get("total_tokens"); totalTokensResult.Exists() {
-			template, _ = sjson.SetBytes(template, "usage.total_tokens", totalTokensResult.Int())
+			template, _ = util.SafeSetJSON(template, "usage.total_tokens", totalTokensResult.Int())
 		}
 		if inputTokensResult := usageResult.Get("input_tokens"); inputTokensResult.Exists() {
-			template, _ = sjson.SetBytes(template, "usage.prompt_tokens", inputTokensResult.Int())
+			template, _ = util.SafeSetJSON(template, "usage.prompt_tokens", inputTokensResult.Int())
 		}
 		if cachedTokensResult := usageResult.Get("input_tokens_details.cached_tokens"); cachedTokensResult.Exists() {
-			template, _ = sjson.SetBytes(template, "usage.prompt_tokens_details.cached_tokens", cachedTokensResult.Int())
+			template, _ = util.SafeSetJSON(template, "usage.prompt_tokens_details.cached_tokens", cachedTokensResult.Int())
 		}
 		if reasoningTokensResult := usageResult.Get("output_tokens_details.reasoning_tokens"); reasoningTokensResult.Exists() {
-			template, _ = sjson.SetBytes(template, "usage.completion_tokens_details.reasoning_tokens", reasoningTokensResult.Int())
+			template, _ = util.SafeSetJSON(template, "usage.completion_tokens_details.reasoning_tokens", reasoningTokensResult.Int())
 		}
 	}
 
@@ -402,7 +402,7 @@ func ConvertCodexResponseToOpenAINonStream(_ context.Context, _ string, original
 				functionCallTemplate := []byte(`{"id":"","type":"function","function":{"name":"","arguments":""}}`)
 
 				if callIdResult := outputItem.Get("call_id"); callIdResult.Exists() {
-					functionCallTemplate, _ = sjson.SetBytes(functionCallTemplate, "id", callIdResult.String())
+					functionCallTemplate, _ = util.SafeSetJSON(functionCallTemplate, "id", callIdResult.String())
 				}
 
 				if nameResult := outputItem.Get("name"); nameResult.Exists() {
@@ -411,11 +411,11 @@ func ConvertCodexResponseToOpenAINonStream(_ context.Context, _ string, original
 					if orig, ok := rev[n]; ok {
 						n = orig
 					}
-					functionCallTemplate, _ = sjson.SetBytes(functionCallTemplate, "function.name", n)
+					functionCallTemplate, _ = util.SafeSetJSON(functionCallTemplate, "function.name", n)
 				}
 
 				if argsResult := outputItem.Get("arguments"); argsResult.Exists() {
-					functionCallTemplate, _ = sjson.SetBytes(functionCallTemplate, "function.arguments", argsResult.String())
+					functionCallTemplate, _ = util.SafeSetJSON(functionCallTemplate, "function.arguments", argsResult.String())
 				}
 
 				toolCalls = append(toolCalls, functionCallTemplate)
@@ -429,39 +429,39 @@ func ConvertCodexResponseToOpenAINonStream(_ context.Context, _ string, original
 				imageURL := "data:" + mimeType + ";base64," + b64
 
 				imagePayload := []byte(`{"type":"image_url","image_url":{"url":""}}`)
-				imagePayload, _ = sjson.SetBytes(imagePayload, "index", len(images))
-				imagePayload, _ = sjson.SetBytes(imagePayload, "image_url.url", imageURL)
+				imagePayload, _ = util.SafeSetJSON(imagePayload, "index", len(images))
+				imagePayload, _ = util.SafeSetJSON(imagePayload, "image_url.url", imageURL)
 				images = append(images, imagePayload)
 			}
 		}
 
 		// Set content and reasoning content if found
 		if contentText != "" {
-			template, _ = sjson.SetBytes(template, "choices.0.message.content", contentText)
-			template, _ = sjson.SetBytes(template, "choices.0.message.role", "assistant")
+			template, _ = util.SafeSetJSON(template, "choices.0.message.content", contentText)
+			template, _ = util.SafeSetJSON(template, "choices.0.message.role", "assistant")
 		}
 
 		if reasoningText != "" {
-			template, _ = sjson.SetBytes(template, "choices.0.message.reasoning_content", reasoningText)
-			template, _ = sjson.SetBytes(template, "choices.0.message.role", "assistant")
+			template, _ = util.SafeSetJSON(template, "choices.0.message.reasoning_content", reasoningText)
+			template, _ = util.SafeSetJSON(template, "choices.0.message.role", "assistant")
 		}
 
 		// Add tool calls if any
 		if len(toolCalls) > 0 {
-			template, _ = sjson.SetRawBytes(template, "choices.0.message.tool_calls", []byte(`[]`))
+			template, _ = util.SafeSetRawJSON(template, "choices.0.message.tool_calls", []byte(`[]`))
 			for _, toolCall := range toolCalls {
-				template, _ = sjson.SetRawBytes(template, "choices.0.message.tool_calls.-1", toolCall)
+				template, _ = util.SafeSetRawJSON(template, "choices.0.message.tool_calls.-1", toolCall)
 			}
-			template, _ = sjson.SetBytes(template, "choices.0.message.role", "assistant")
+			template, _ = util.SafeSetJSON(template, "choices.0.message.role", "assistant")
 		}
 
 		// Add images if any
 		if len(images) > 0 {
-			template, _ = sjson.SetRawBytes(template, "choices.0.message.images", []byte(`[]`))
+			template, _ = util.SafeSetRawJSON(template, "choices.0.message.images", []byte(`[]`))
 			for _, image := range images {
-				template, _ = sjson.SetRawBytes(template, "choices.0.message.images.-1", image)
+				template, _ = util.SafeSetRawJSON(template, "choices.0.message.images.-1", image)
 			}
-			template, _ = sjson.SetBytes(template, "choices.0.message.role", "assistant")
+			template, _ = util.SafeSetJSON(template, "choices.0.message.role", "assistant")
 		}
 	}
 
@@ -473,8 +473,8 @@ func ConvertCodexResponseToOpenAINonStream(_ context.Context, _ string, original
 			if len(toolCalls) > 0 {
 				finishReason = "tool_calls"
 			}
-			template, _ = sjson.SetBytes(template, "choices.0.finish_reason", finishReason)
-			template, _ = sjson.SetBytes(template, "choices.0.native_finish_reason", finishReason)
+			template, _ = util.SafeSetJSON(template, "choices.0.finish_reason", finishReason)
+			template, _ = util.SafeSetJSON(template, "choices.0.native_finish_reason", finishReason)
 		}
 	}
 
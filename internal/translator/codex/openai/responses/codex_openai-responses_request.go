@@ -3,9 +3,9 @@ package responses
 import (
 	"fmt"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 func ConvertOpenAIResponsesRequestToCodex(modelName string, inputRawJSON []byte, _ bool) []byte {
@@ -13,30 +13,30 @@ func ConvertOpenAIResponsesRequestToCodex(modelName string, inputRawJSON []byte,
 
 	inputResult := gjson.GetBytes(rawJSON, "input")
 	if inputResult.Type == gjson.String {
-		input, _ := sjson.SetBytes([]byte(`[{"type":"message","role":"user","content":[{"type":"input_text","text":""}]}]`), "0.content.0.text", inputResult.String())
-		rawJSON, _ = sjson.SetRawBytes(rawJSON, "input", input)
+		input, _ := util.SafeSetJSON([]byte(`[{"type":"message","role":"user","content":[{"type":"input_text","text":""}]}]`), "0.content.0.text", inputResult.String())
+		rawJSON, _ = util.SafeSetRawJSON(rawJSON, "input", input)
 	}
 
-	rawJSON, _ = sjson.SetBytes(rawJSON, "stream", true)
-	rawJSON, _ = sjson.SetBytes(rawJSON, "store", false)
-	rawJSON, _ = sjson.SetBytes(rawJSON, "parallel_tool_calls", true)
-	rawJSON, _ = sjson.SetBytes(rawJSON, "include", []string{"reasoning.encrypted_content"})
+	rawJSON, _ = util.SafeSetJSON(rawJSON, "stream", true)
+	rawJSON, _ = util.SafeSetJSON(rawJSON, "store", false)
+	rawJSON, _ = util.SafeSetJSON(rawJSON, "parallel_tool_calls", true)
+	rawJSON, _ = util.SafeSetJSON(rawJSON, "include", []string{"reasoning.encrypted_content"})
 	// Codex Responses rejects token limit fields, so strip them out before forwarding.
-	rawJSON, _ = sjson.DeleteBytes(rawJSON, "max_output_tokens")
-	rawJSON, _ = sjson.DeleteBytes(rawJSON, "max_completion_tokens")
-	rawJSON, _ = sjson.DeleteBytes(rawJSON, "temperature")
-	rawJSON, _ = sjson.DeleteBytes(rawJSON, "top_p")
+	rawJSON, _ = util.SafeDeleteJSON(rawJSON, "max_output_tokens")
+	rawJSON, _ = util.SafeDeleteJSON(rawJSON, "max_completion_tokens")
+	rawJSON, _ = util.SafeDeleteJSON(rawJSON, "temperature")
+	rawJSON, _ = util.SafeDeleteJSON(rawJSON, "top_p")
 	if v := gjson.GetBytes(rawJSON, "service_tier"); v.Exists() {
 		if v.String() != "priority" {
-			rawJSON, _ = sjson.DeleteBytes(rawJSON, "service_tier")
+			rawJSON, _ = util.SafeDeleteJSON(rawJSON, "service_tier")
 		}
 	}
 
-	rawJSON, _ = sjson.DeleteBytes(rawJSON, "truncation")
+	rawJSON, _ = util.SafeDeleteJSON(rawJSON, "truncation")
 	rawJSON = applyResponsesCompactionCompatibility(rawJSON)
 
 	// Delete the user field as it is not supported by the Codex upstream.
-	rawJSON, _ = sjson.DeleteBytes(rawJSON, "user")
+	rawJSON, _ = util.SafeDeleteJSON(rawJSON, "user")
 
 	// Convert role "system" to "developer" in input array to comply with Codex API requirements.
 	rawJSON = convertSystemRoleToDeveloper(rawJSON)
@@ -58,7 +58,7 @@ func applyResponsesCompactionCompatibility(rawJSON []byte) []byte {
 		return rawJSON
 	}
 
-	rawJSON, _ = sjson.DeleteBytes(rawJSON, "context_management")
+	rawJSON, _ = util.SafeDeleteJSON(rawJSON, "context_management")
 	return rawJSON
 }
 
@@ -78,7 +78,7 @@ func convertSystemRoleToDeveloper(rawJSON []byte) []byte {
 	for i := 0; i < len(inputArray); i++ {
 		rolePath := fmt.Sprintf("input.%d.role", i)
 		if gjson.GetBytes(result, rolePath).String() == "system" {
-			result, _ = sjson.SetBytes(result, rolePath, "developer")
+			result, _ = util.SafeSetJSON(result, rolePath, "developer")
 		}
 	}
 
@@ -120,7 +120,7 @@ func normalizeCodexBuiltinToolAtPath(rawJSON []byte, path string) []byte {
 		return rawJSON
 	}
 
-	updated, err := sjson.SetBytes(rawJSON, path, normalizedType)
+	updated, err := util.SafeSetJSON(rawJSON, path, normalizedType)
 	if err != nil {
 		return rawJSON
 	}
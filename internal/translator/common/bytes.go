@@ -3,11 +3,11 @@ package common
 import (
 	"strconv"
 
-	"github.com/tidwall/sjson"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 )
 
 func WrapGeminiCLIResponse(response []byte) []byte {
-	out, err := sjson.SetRawBytes([]byte(`{"response":{}}`), "response", response)
+	out, err := util.SafeSetRawJSON([]byte(`{"response":{}}`), "response", response)
 	if err != nil {
 		return response
 	}
@@ -11,7 +11,6 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 const geminiCLIClaudeThoughtSignature = "skip_thought_signature_validator"
@@ -39,7 +38,7 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 
 	// Build output Gemini CLI request JSON
 	out := []byte(`{"model":"","request":{"contents":[]}}`)
-	out, _ = sjson.SetBytes(out, "model", modelName)
+	out, _ = util.SafeSetJSON(out, "model", modelName)
 
 	// system instruction
 	if systemResult := gjson.GetBytes(rawJSON, "system"); systemResult.IsArray() {
@@ -50,18 +49,18 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 				textResult := systemPromptResult.Get("text")
 				if textResult.Type == gjson.String {
 					part := []byte(`{"text":""}`)
-					part, _ = sjson.SetBytes(part, "text", textResult.String())
-					systemInstruction, _ = sjson.SetRawBytes(systemInstruction, "parts.-1", part)
+					part, _ = util.SafeSetJSON(part, "text", textResult.String())
+					systemInstruction, _ = util.SafeSetRawJSON(systemInstruction, "parts.-1", part)
 					hasSystemParts = true
 				}
 			}
 			return true
 		})
 		if hasSystemParts {
-			out, _ = sjson.SetRawBytes(out, "request.systemInstruction", systemInstruction)
+			out, _ = util.SafeSetRawJSON(out, "request.systemInstruction", systemInstruction)
 		}
 	} else if systemResult.Type == gjson.String {
-		out, _ = sjson.SetBytes(out, "request.systemInstruction.parts.-1.text", systemResult.String())
+		out, _ = util.SafeSetJSON(out, "request.systemInstruction.parts.-1.text", systemResult.String())
 	}
 
 	// contents
@@ -77,7 +76,7 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 			}
 
 			contentJSON := []byte(`{"role":"","parts":[]}`)
-			contentJSON, _ = sjson.SetBytes(contentJSON, "role", role)
+			contentJSON, _ = util.SafeSetJSON(contentJSON, "role", role)
 
 			contentsResult := messageResult.Get("content")
 			if contentsResult.IsArray() {
@@ -85,8 +84,8 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 					switch contentResult.Get("type").String() {
 					case "text":
 						part := []byte(`{"text":""}`)
-						part, _ = sjson.SetBytes(part, "text", contentResult.Get("text").String())
-						contentJSON, _ = sjson.SetRawBytes(contentJSON, "parts.-1", part)
+						part, _ = util.SafeSetJSON(part, "text", contentResult.Get("text").String())
+						contentJSON, _ = util.SafeSetRawJSON(contentJSON, "parts.-1", part)
 
 					case "tool_use":
 						functionName := util.SanitizeFunctionName(contentResult.Get("name").String())
@@ -94,10 +93,10 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 						argsResult := gjson.Parse(functionArgs)
 						if argsResult.IsObject() && gjson.Valid(functionArgs) {
 							part := []byte(`{"thoughtSignature":"","functionCall":{"name":"","args":{}}}`)
-							part, _ = sjson.SetBytes(part, "thoughtSignature", geminiCLIClaudeThoughtSignature)
-							part, _ = sjson.SetBytes(part, "functionCall.name", functionName)
-							part, _ = sjson.SetRawBytes(part, "functionCall.args", []byte(functionArgs))
-							contentJSON, _ = sjson.SetRawBytes(contentJSON, "parts.-1", part)
+							part, _ = util.SafeSetJSON(part, "thoughtSignature", geminiCLIClaudeThoughtSignature)
+							part, _ = util.SafeSetJSON(part, "functionCall.name", functionName)
+							part, _ = util.SafeSetRawJSON(part, "functionCall.args", []byte(functionArgs))
+							contentJSON, _ = util.SafeSetRawJSON(contentJSON, "parts.-1", part)
 						}
 
 					case "tool_result":
@@ -112,9 +111,9 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 						}
 						responseData := contentResult.Get("content").Raw
 						part := []byte(`{"functionResponse":{"name":"","response":{"result":""}}}`)
-						part, _ = sjson.SetBytes(part, "functionResponse.name", util.SanitizeFunctionName(funcName))
-						part, _ = sjson.SetBytes(part, "functionResponse.response.result", responseData)
-						contentJSON, _ = sjson.SetRawBytes(contentJSON, "parts.-1", part)
+						part, _ = util.SafeSetJSON(part, "functionResponse.name", util.SanitizeFunctionName(funcName))
+						part, _ = util.SafeSetJSON(part, "functionResponse.response.result", responseData)
+						contentJSON, _ = util.SafeSetRawJSON(contentJSON, "parts.-1", part)
 
 					case "image":
 						source := contentResult.Get("source")
@@ -123,20 +122,20 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 							data := source.Get("data").String()
 							if mimeType != "" && data != "" {
 								part := []byte(`{"inlineData":{"mime_type":"","data":""}}`)
-								part, _ = sjson.SetBytes(part, "inlineData.mime_type", mimeType)
-								part, _ = sjson.SetBytes(part, "inlineData.data", data)
-								contentJSON, _ = sjson.SetRawBytes(contentJSON, "parts.-1", part)
+								part, _ = util.SafeSetJSON(part, "inlineData.mime_type", mimeType)
+								part, _ = util.SafeSetJSON(part, "inlineData.data", data)
+								contentJSON, _ = util.SafeSetRawJSON(contentJSON, "parts.-1", part)
 							}
 						}
 					}
 					return true
 				})
-				out, _ = sjson.SetRawBytes(out, "request.contents.-1", contentJSON)
+				out, _ = util.SafeSetRawJSON(out, "request.contents.-1", contentJSON)
 			} else if contentsResult.Type == gjson.String {
 				part := []byte(`{"text":""}`)
-				part, _ = sjson.SetBytes(part, "text", contentsResult.String())
-				contentJSON, _ = sjson.SetRawBytes(contentJSON, "parts.-1", part)
-				out, _ = sjson.SetRawBytes(out, "request.contents.-1", contentJSON)
+				part, _ = util.SafeSetJSON(part, "text", contentsResult.String())
+				contentJSON, _ = util.SafeSetRawJSON(contentJSON, "parts.-1", part)
+				out, _ = util.SafeSetRawJSON(out, "request.contents.-1", contentJSON)
 			}
 			return true
 		})
@@ -149,27 +148,27 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 			inputSchemaResult := toolResult.Get("input_schema")
 			if inputSchemaResult.Exists() && inputSchemaResult.IsObject() {
 				inputSchema := util.CleanJSONSchemaForGemini(inputSchemaResult.Raw)
-				tool, _ := sjson.DeleteBytes([]byte(toolResult.Raw), "input_schema")
-				tool, _ = sjson.SetRawBytes(tool, "parametersJsonSchema", []byte(inputSchema))
-				tool, _ = sjson.SetBytes(tool, "name", util.SanitizeFunctionName(gjson.GetBytes(tool, "name").String()))
-				tool, _ = sjson.DeleteBytes(tool, "strict")
-				tool, _ = sjson.DeleteBytes(tool, "input_examples")
-				tool, _ = sjson.DeleteBytes(tool, "type")
-				tool, _ = sjson.DeleteBytes(tool, "cache_control")
-				tool, _ = sjson.DeleteBytes(tool, "defer_loading")
-				tool, _ = sjson.DeleteBytes(tool, "eager_input_streaming")
+				tool, _ := util.SafeDeleteJSON([]byte(toolResult.Raw), "input_schema")
+				tool, _ = util.SafeSetRawJSON(tool, "parametersJsonSchema", []byte(inputSchema))
+				tool, _ = util.SafeSetJSON(tool, "name", util.SanitizeFunctionName(gjson.GetBytes(tool, "name").String()))
+				tool, _ = util.SafeDeleteJSON(tool, "strict")
+				tool, _ = util.SafeDeleteJSON(tool, "input_examples")
+				tool, _ = util.SafeDeleteJSON(tool, "type")
+				tool, _ = util.SafeDeleteJSON(tool, "cache_control")
+				tool, _ = util.SafeDeleteJSON(tool, "defer_loading")
+				tool, _ = util.SafeDeleteJSON(tool, "eager_input_streaming")
 				if gjson.ValidBytes(tool) && gjson.ParseBytes(tool).IsObject() {
 					if !hasTools {
-						out, _ = sjson.SetRawBytes(out, "request.tools", []byte(`[{"functionDeclarations":[]}]`))
+						out, _ = util.SafeSetRawJSON(out, "request.tools", []byte(`[{"functionDeclarations":[]}]`))
 						hasTools = true
 					}
-					out, _ = sjson.SetRawBytes(out, "request.tools.0.functionDeclarations.-1", tool)
+					out, _ = util.SafeSetRawJSON(out, "request.tools.0.functionDeclarations.-1", tool)
 				}
 			}
 			return true
 		})
 		if !hasTools {
-			out, _ = sjson.DeleteBytes(out, "request.tools")
+			out, _ = util.SafeDeleteJSON(out, "request.tools")
 		}
 	}
 
@@ -187,15 +186,15 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 
 		switch toolChoiceType {
 		case "auto":
-			out, _ = sjson.SetBytes(out, "request.toolConfig.functionCallingConfig.mode", "AUTO")
+			out, _ = util.SafeSetJSON(out, "request.toolConfig.functionCallingConfig.mode", "AUTO")
 		case "none":
-			out, _ = sjson.SetBytes(out, "request.toolConfig.functionCallingConfig.mode", "NONE")
+			out, _ = util.SafeSetJSON(out, "request.toolConfig.functionCallingConfig.mode", "NONE")
 		case "any":
-			out, _ = sjson.SetBytes(out, "request.toolConfig.functionCallingConfig.mode", "ANY")
+			out, _ = util.SafeSetJSON(out, "request.toolConfig.functionCallingConfig.mode", "ANY")
 		case "tool":
-			out, _ = sjson.SetBytes(out, "request.toolConfig.functionCallingConfig.mode", "ANY")
+			out, _ = util.SafeSetJSON(out, "request.toolConfig.functionCallingConfig.mode", "ANY")
 			if toolChoiceName != "" {
-				out, _ = sjson.SetBytes(out, "request.toolConfig.functionCallingConfig.allowedFunctionNames", []string{util.SanitizeFunctionName(toolChoiceName)})
+				out, _ = util.SafeSetJSON(out, "request.toolConfig.functionCallingConfig.allowedFunctionNames", []string{util.SanitizeFunctionName(toolChoiceName)})
 			}
 		}
 	}
@@ -207,8 +206,8 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 		case "enabled":
 			if b := t.Get("budget_tokens"); b.Exists() && b.Type == gjson.Number {
 				budget := int(b.Int())
-				out, _ = sjson.SetBytes(out, "request.generationConfig.thinkingConfig.thinkingBudget", budget)
-				out, _ = sjson.SetBytes(out, "request.generationConfig.thinkingConfig.includeThoughts", true)
+				out, _ = util.SafeSetJSON(out, "request.generationConfig.thinkingConfig.thinkingBudget", budget)
+				out, _ = util.SafeSetJSON(out, "request.generationConfig.thinkingConfig.includeThoughts", true)
 			}
 		case "adaptive", "auto":
 			// For adaptive thinking:
@@ -220,21 +219,21 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 				effort = strings.ToLower(strings.TrimSpace(v.String()))
 			}
 			if effort != "" {
-				out, _ = sjson.SetBytes(out, "request.generationConfig.thinkingConfig.thinkingLevel", effort)
+				out, _ = util.SafeSetJSON(out, "request.generationConfig.thinkingConfig.thinkingLevel", effort)
 			} else {
-				out, _ = sjson.SetBytes(out, "request.generationConfig.thinkingConfig.thinkingLevel", "high")
+				out, _ = util.SafeSetJSON(out, "request.generationConfig.thinkingConfig.thinkingLevel", "high")
 			}
-			out, _ = sjson.SetBytes(out, "request.generationConfig.thinkingConfig.includeThoughts", true)
+			out, _ = util.SafeSetJSON(out, "request.generationConfig.thinkingConfig.includeThoughts", true)
 		}
 	}
 	if v := gjson.GetBytes(rawJSON, "temperature"); v.Exists() && v.Type == gjson.Number {
-		out, _ = sjson.SetBytes(out, "request.generationConfig.temperature", v.Num)
+		out, _ = util.SafeSetJSON(out, "request.generationConfig.temperature", v.Num)
 	}
 	if v := gjson.GetBytes(rawJSON, "top_p"); v.Exists() && v.Type == gjson.Number {
-		out, _ = sjson.SetBytes(out, "request.generationConfig.topP", v.Num)
+		out, _ = util.SafeSetJSON(out, "request.generationConfig.topP", v.Num)
 	}
 	if v := gjson.GetBytes(rawJSON, "top_k"); v.Exists() && v.Type == gjson.Number {
-		out, _ = sjson.SetBytes(out, "request.generationConfig.topK", v.Num)
+		out, _ = util.SafeSetJSON(out, "request.generationConfig.topK", v.Num)
 	}
 
 	out = common.AttachDefaultSafetySettings(out, "request.safetySettings")
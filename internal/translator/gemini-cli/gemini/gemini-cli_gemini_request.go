@@ -13,7 +13,6 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 // ConvertGeminiRequestToGeminiCLI parses and transforms a Gemini CLI API request into Gemini API format.
@@ -35,9 +34,9 @@ import (
 func ConvertGeminiRequestToGeminiCLI(_ string, inputRawJSON []byte, _ bool) []byte {
 	rawJSON := inputRawJSON
 	template := []byte(`{"project":"","request":{},"model":""}`)
-	template, _ = sjson.SetRawBytes(template, "request", rawJSON)
-	template, _ = sjson.SetBytes(template, "model", gjson.GetBytes(template, "request.model").String())
-	template, _ = sjson.DeleteBytes(template, "request.model")
+	template, _ = util.SafeSetRawJSON(template, "request", rawJSON)
+	template, _ = util.SafeSetJSON(template, "model", gjson.GetBytes(template, "request.model").String())
+	template, _ = util.SafeDeleteJSON(template, "request.model")
 
 	templateStr, errFixCLIToolResponse := fixCLIToolResponse(string(template))
 	if errFixCLIToolResponse != nil {
@@ -47,8 +46,8 @@ func ConvertGeminiRequestToGeminiCLI(_ string, inputRawJSON []byte, _ bool) []by
 
 	systemInstructionResult := gjson.GetBytes(template, "request.system_instruction")
 	if systemInstructionResult.Exists() {
-		template, _ = sjson.SetRawBytes(template, "request.systemInstruction", []byte(systemInstructionResult.Raw))
-		template, _ = sjson.DeleteBytes(template, "request.system_instruction")
+		template, _ = util.SafeSetRawJSON(template, "request.systemInstruction", []byte(systemInstructionResult.Raw))
+		template, _ = util.SafeDeleteJSON(template, "request.system_instruction")
 	}
 	rawJSON = template
 
@@ -70,7 +69,7 @@ func ConvertGeminiRequestToGeminiCLI(_ string, inputRawJSON []byte, _ bool) []by
 					newRole = "user"
 				}
 				path := fmt.Sprintf("request.contents.%d.role", idx)
-				rawJSON, _ = sjson.SetBytes(rawJSON, path, newRole)
+				rawJSON, _ = util.SafeSetJSON(rawJSON, path, newRole)
 				role = newRole
 			}
 			prevRole = role
@@ -79,31 +78,15 @@ func ConvertGeminiRequestToGeminiCLI(_ string, inputRawJSON []byte, _ bool) []by
 		})
 	}
 
-	toolsResult := gjson.GetBytes(rawJSON, "request.tools")
-	if toolsResult.Exists() && toolsResult.IsArray() {
-		toolResults := toolsResult.Array()
-		for i := 0; i < len(toolResults); i++ {
-			functionDeclarationsResult := gjson.GetBytes(rawJSON, fmt.Sprintf("request.tools.%d.function_declarations", i))
-			if functionDeclarationsResult.Exists() && functionDeclarationsResult.IsArray() {
-				functionDeclarationsResults := functionDeclarationsResult.Array()
-				for j := 0; j < len(functionDeclarationsResults); j++ {
-					parametersResult := gjson.GetBytes(rawJSON, fmt.Sprintf("request.tools.%d.function_declarations.%d.parameters", i, j))
-					if parametersResult.Exists() {
-						strJson, _ := util.RenameKey(string(rawJSON), fmt.Sprintf("request.tools.%d.function_declarations.%d.parameters", i, j), fmt.Sprintf("request.tools.%d.function_declarations.%d.parametersJsonSchema", i, j))
-						rawJSON = []byte(strJson)
-					}
-				}
-			}
-		}
-	}
+	rawJSON = common.ApplySchemaRenames(rawJSON, common.SchemaRenameGeminiToGeminiCLI, "request.")
 
 	gjson.GetBytes(rawJSON, "request.contents").ForEach(func(key, content gjson.Result) bool {
 		if content.Get("role").String() == "model" {
 			content.Get("parts").ForEach(func(partKey, part gjson.Result) bool {
 				if part.Get("functionCall").Exists() {
-					rawJSON, _ = sjson.SetBytes(rawJSON, fmt.Sprintf("request.contents.%d.parts.%d.thoughtSignature", key.Int(), partKey.Int()), "skip_thought_signature_validator")
+					rawJSON, _ = util.SafeSetJSON(rawJSON, fmt.Sprintf("request.contents.%d.parts.%d.thoughtSignature", key.Int(), partKey.Int()), "skip_thought_signature_validator")
 				} else if part.Get("thoughtSignature").Exists() {
-					rawJSON, _ = sjson.SetBytes(rawJSON, fmt.Sprintf("request.contents.%d.parts.%d.thoughtSignature", key.Int(), partKey.Int()), "skip_thought_signature_validator")
+					rawJSON, _ = util.SafeSetJSON(rawJSON, fmt.Sprintf("request.contents.%d.parts.%d.thoughtSignature", key.Int(), partKey.Int()), "skip_thought_signature_validator")
 				}
 				return true
 			})
@@ -121,11 +104,11 @@ func ConvertGeminiRequestToGeminiCLI(_ string, inputRawJSON []byte, _ bool) []by
 			hasFiltered = true
 			return true
 		}
-		filteredContents, _ = sjson.SetRawBytes(filteredContents, "-1", []byte(content.Raw))
+		filteredContents, _ = util.SafeSetRawJSON(filteredContents, "-1", []byte(content.Raw))
 		return true
 	})
 	if hasFiltered {
-		rawJSON, _ = sjson.SetRawBytes(rawJSON, "request.contents", filteredContents)
+		rawJSON, _ = util.SafeSetRawJSON(rawJSON, "request.contents", filteredContents)
 	}
 
 	return common.AttachDefaultSafetySettings(rawJSON, "request.safetySettings")
@@ -142,7 +125,7 @@ type FunctionCallGroup struct {
 func backfillFunctionResponseName(raw string, fallbackName string) string {
 	name := gjson.Get(raw, "functionResponse.name").String()
 	if strings.TrimSpace(name) == "" && fallbackName != "" {
-		rawBytes, _ := sjson.SetBytes([]byte(raw), "functionResponse.name", fallbackName)
+		rawBytes, _ := util.SafeSetJSON([]byte(raw), "functionResponse.name", fallbackName)
 		raw = string(rawBytes)
 	}
 	return raw
@@ -212,11 +195,11 @@ func fixCLIToolResponse(input string) (string, error) {
 						continue
 					}
 					raw := backfillFunctionResponseName(response.Raw, group.CallNames[ri])
-					functionResponseContent, _ = sjson.SetRawBytes(functionResponseContent, "parts.-1", []byte(raw))
+					functionResponseContent, _ = util.SafeSetRawJSON(functionResponseContent, "parts.-1", []byte(raw))
 				}
 
 				if gjson.GetBytes(functionResponseContent, "parts.#").Int() > 0 {
-					contentsWrapper, _ = sjson.SetRawBytes(contentsWrapper, "contents.-1", functionResponseContent)
+					contentsWrapper, _ = util.SafeSetRawJSON(contentsWrapper, "contents.-1", functionResponseContent)
 				}
 			}
 
@@ -239,7 +222,7 @@ func fixCLIToolResponse(input string) (string, error) {
 					log.Warnf("failed to parse model content")
 					return true
 				}
-				contentsWrapper, _ = sjson.SetRawBytes(contentsWrapper, "contents.-1", []byte(value.Raw))
+				contentsWrapper, _ = util.SafeSetRawJSON(contentsWrapper, "contents.-1", []byte(value.Raw))
 
 				// Create a new group for tracking responses
 				group := &FunctionCallGroup{
@@ -253,7 +236,7 @@ func fixCLIToolResponse(input string) (string, error) {
 					log.Warnf("failed to parse content")
 					return true
 				}
-				contentsWrapper, _ = sjson.SetRawBytes(contentsWrapper, "contents.-1", []byte(value.Raw))
+				contentsWrapper, _ = util.SafeSetRawJSON(contentsWrapper, "contents.-1", []byte(value.Raw))
 			}
 		} else {
 			// Non-model content (user, etc.)
@@ -261,7 +244,7 @@ func fixCLIToolResponse(input string) (string, error) {
 				log.Warnf("failed to parse content")
 				return true
 			}
-			contentsWrapper, _ = sjson.SetRawBytes(contentsWrapper, "contents.-1", []byte(value.Raw))
+			contentsWrapper, _ = util.SafeSetRawJSON(contentsWrapper, "contents.-1", []byte(value.Raw))
 		}
 
 		return true
@@ -280,18 +263,18 @@ func fixCLIToolResponse(input string) (string, error) {
 					continue
 				}
 				raw := backfillFunctionResponseName(response.Raw, group.CallNames[ri])
-				functionResponseContent, _ = sjson.SetRawBytes(functionResponseContent, "parts.-1", []byte(raw))
+				functionResponseContent, _ = util.SafeSetRawJSON(functionResponseContent, "parts.-1", []byte(raw))
 			}
 
 			if gjson.GetBytes(functionResponseContent, "parts.#").Int() > 0 {
-				contentsWrapper, _ = sjson.SetRawBytes(contentsWrapper, "contents.-1", functionResponseContent)
+				contentsWrapper, _ = util.SafeSetRawJSON(contentsWrapper, "contents.-1", functionResponseContent)
 			}
 		}
 	}
 
 	// Update the original JSON with the new contents
 	result := []byte(input)
-	result, _ = sjson.SetRawBytes(result, "request.contents", []byte(gjson.GetBytes(contentsWrapper, "contents").Raw))
+	result, _ = util.SafeSetRawJSON(result, "request.contents", []byte(gjson.GetBytes(contentsWrapper, "contents").Raw))
 
 	return string(result), nil
 }
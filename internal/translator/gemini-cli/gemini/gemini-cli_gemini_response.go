@@ -10,8 +10,8 @@ import (
 	"context"
 
 	translatorcommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/common"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 // ConvertGeminiCliResponseToGemini parses and transforms a Gemini CLI API request into Gemini API format.
@@ -50,7 +50,7 @@ func ConvertGeminiCliResponseToGemini(ctx context.Context, _ string, originalReq
 				for i := 0; i < len(responseResultItems); i++ {
 					responseResultItem := responseResultItems[i]
 					if responseResultItem.Get("response").Exists() {
-						chunkTemplate, _ = sjson.SetRawBytes(chunkTemplate, "-1", []byte(responseResultItem.Get("response").Raw))
+						chunkTemplate, _ = util.SafeSetRawJSON(chunkTemplate, "-1", []byte(responseResultItem.Get("response").Raw))
 					}
 				}
 			}
@@ -11,7 +11,6 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 const geminiCLIFunctionThoughtSignature = "skip_thought_signature_validator"
@@ -32,11 +31,11 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 	out := []byte(`{"project":"","request":{"contents":[]},"model":"gemini-2.5-pro"}`)
 
 	// Model
-	out, _ = sjson.SetBytes(out, "model", modelName)
+	out, _ = util.SafeSetJSON(out, "model", modelName)
 
 	// Let user-provided generationConfig pass through
 	if genConfig := gjson.GetBytes(rawJSON, "generationConfig"); genConfig.Exists() {
-		out, _ = sjson.SetRawBytes(out, "request.generationConfig", []byte(genConfig.Raw))
+		out, _ = util.SafeSetRawJSON(out, "request.generationConfig", []byte(genConfig.Raw))
 	}
 
 	// Apply thinking configuration: convert OpenAI reasoning_effort to Gemini CLI thinkingConfig.
@@ -47,30 +46,30 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 		if effort != "" {
 			thinkingPath := "request.generationConfig.thinkingConfig"
 			if effort == "auto" {
-				out, _ = sjson.SetBytes(out, thinkingPath+".thinkingBudget", -1)
-				out, _ = sjson.SetBytes(out, thinkingPath+".includeThoughts", true)
+				out, _ = util.SafeSetJSON(out, thinkingPath+".thinkingBudget", -1)
+				out, _ = util.SafeSetJSON(out, thinkingPath+".includeThoughts", true)
 			} else {
-				out, _ = sjson.SetBytes(out, thinkingPath+".thinkingLevel", effort)
-				out, _ = sjson.SetBytes(out, thinkingPath+".includeThoughts", effort != "none")
+				out, _ = util.SafeSetJSON(out, thinkingPath+".thinkingLevel", effort)
+				out, _ = util.SafeSetJSON(out, thinkingPath+".includeThoughts", effort != "none")
 			}
 		}
 	}
 
 	// Temperature/top_p/top_k
 	if tr := gjson.GetBytes(rawJSON, "temperature"); tr.Exists() && tr.Type == gjson.Number {
-		out, _ = sjson.SetBytes(out, "request.generationConfig.temperature", tr.Num)
+		out, _ = util.SafeSetJSON(out, "request.generationConfig.temperature", tr.Num)
 	}
 	if tpr := gjson.GetBytes(rawJSON, "top_p"); tpr.Exists() && tpr.Type == gjson.Number {
-		out, _ = sjson.SetBytes(out, "request.generationConfig.topP", tpr.Num)
+		out, _ = util.SafeSetJSON(out, "request.generationConfig.topP", tpr.Num)
 	}
 	if tkr := gjson.GetBytes(rawJSON, "top_k"); tkr.Exists() && tkr.Type == gjson.Number {
-		out, _ = sjson.SetBytes(out, "request.generationConfig.topK", tkr.Num)
+		out, _ = util.SafeSetJSON(out, "request.generationConfig.topK", tkr.Num)
 	}
 
 	// Candidate count (OpenAI 'n' parameter)
 	if n := gjson.GetBytes(rawJSON, "n"); n.Exists() && n.Type == gjson.Number {
 		if val := n.Int(); val > 1 {
-			out, _ = sjson.SetBytes(out, "request.generationConfig.candidateCount", val)
+			out, _ = util.SafeSetJSON(out, "request.generationConfig.candidateCount", val)
 		}
 	}
 
@@ -87,7 +86,7 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 			}
 		}
 		if len(responseMods) > 0 {
-			out, _ = sjson.SetBytes(out, "request.generationConfig.responseModalities", responseMods)
+			out, _ = util.SafeSetJSON(out, "request.generationConfig.responseModalities", responseMods)
 		}
 	}
 
@@ -95,10 +94,10 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 	// If the input uses top-level image_config.aspect_ratio, map it into request.generationConfig.imageConfig.aspectRatio.
 	if imgCfg := gjson.GetBytes(rawJSON, "image_config"); imgCfg.Exists() && imgCfg.IsObject() {
 		if ar := imgCfg.Get("aspect_ratio"); ar.Exists() && ar.Type == gjson.String {
-			out, _ = sjson.SetBytes(out, "request.generationConfig.imageConfig.aspectRatio", ar.Str)
+			out, _ = util.SafeSetJSON(out, "request.generationConfig.imageConfig.aspectRatio", ar.Str)
 		}
 		if size := imgCfg.Get("image_size"); size.Exists() && size.Type == gjson.String {
-			out, _ = sjson.SetBytes(out, "request.generationConfig.imageConfig.imageSize", size.Str)
+			out, _ = util.SafeSetJSON(out, "request.generationConfig.imageConfig.imageSize", size.Str)
 		}
 	}
 
@@ -149,19 +148,19 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 			if (role == "system" || role == "developer") && len(arr) > 1 {
 				// system -> request.systemInstruction as a user message style
 				if content.Type == gjson.String {
-					out, _ = sjson.SetBytes(out, "request.systemInstruction.role", "user")
-					out, _ = sjson.SetBytes(out, fmt.Sprintf("request.systemInstruction.parts.%d.text", systemPartIndex), content.String())
+					out, _ = util.SafeSetJSON(out, "request.systemInstruction.role", "user")
+					out, _ = util.SafeSetJSON(out, fmt.Sprintf("request.systemInstruction.parts.%d.text", systemPartIndex), content.String())
 					systemPartIndex++
 				} else if content.IsObject() && content.Get("type").String() == "text" {
-					out, _ = sjson.SetBytes(out, "request.systemInstruction.role", "user")
-					out, _ = sjson.SetBytes(out, fmt.Sprintf("request.systemInstruction.parts.%d.text", systemPartIndex), content.Get("text").String())
+					out, _ = util.SafeSetJSON(out, "request.systemInstruction.role", "user")
+					out, _ = util.SafeSetJSON(out, fmt.Sprintf("request.systemInstruction.parts.%d.text", systemPartIndex), content.Get("text").String())
 					systemPartIndex++
 				} else if content.IsArray() {
 					contents := content.Array()
 					if len(contents) > 0 {
-						out, _ = sjson.SetBytes(out, "request.systemInstruction.role", "user")
+						out, _ = util.SafeSetJSON(out, "request.systemInstruction.role", "user")
 						for j := 0; j < len(contents); j++ {
-							out, _ = sjson.SetBytes(out, fmt.Sprintf("request.systemInstruction.parts.%d.text", systemPartIndex), contents[j].Get("text").String())
+							out, _ = util.SafeSetJSON(out, fmt.Sprintf("request.systemInstruction.parts.%d.text", systemPartIndex), contents[j].Get("text").String())
 							systemPartIndex++
 						}
 					}
@@ -170,14 +169,14 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 				// Build single user content node to avoid splitting into multiple contents
 				node := []byte(`{"role":"user","parts":[]}`)
 				if content.Type == gjson.String {
-					node, _ = sjson.SetBytes(node, "parts.0.text", content.String())
+					node, _ = util.SafeSetJSON(node, "parts.0.text", content.String())
 				} else if content.IsArray() {
 					items := content.Array()
 					p := 0
 					for _, item := range items {
 						switch item.Get("type").String() {
 						case "text":
-							node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".text", item.Get("text").String())
+							node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".text", item.Get("text").String())
 							p++
 						case "image_url":
 							imageURL := item.Get("image_url.url").String()
@@ -186,9 +185,9 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 								if len(pieces) == 2 && len(pieces[1]) > 7 {
 									mime := pieces[0]
 									data := pieces[1][7:]
-									node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mime_type", mime)
-									node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", data)
-									node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".thoughtSignature", geminiCLIFunctionThoughtSignature)
+									node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".inlineData.mime_type", mime)
+									node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".inlineData.data", data)
+									node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".thoughtSignature", geminiCLIFunctionThoughtSignature)
 									p++
 								}
 							}
@@ -200,8 +199,8 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 								ext = sp[len(sp)-1]
 							}
 							if mimeType, ok := misc.MimeTypes[ext]; ok {
-								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mime_type", mimeType)
-								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", fileData)
+								node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".inlineData.mime_type", mimeType)
+								node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".inlineData.data", fileData)
 								p++
 							} else {
 								log.Warnf("Unknown file name extension '%s' in user message, skip", ext)
@@ -209,20 +208,20 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 						}
 					}
 				}
-				out, _ = sjson.SetRawBytes(out, "request.contents.-1", node)
+				out, _ = util.SafeSetRawJSON(out, "request.contents.-1", node)
 			} else if role == "assistant" {
 				p := 0
 				node := []byte(`{"role":"model","parts":[]}`)
 				if content.Type == gjson.String {
 					// Assistant text -> single model content
-					node, _ = sjson.SetBytes(node, "parts.-1.text", content.String())
+					node, _ = util.SafeSetJSON(node, "parts.-1.text", content.String())
 					p++
 				} else if content.IsArray() {
 					// Assistant multimodal content (e.g. text + image) -> single model content with parts
 					for _, item := range content.Array() {
 						switch item.Get("type").String() {
 						case "text":
-							node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".text", item.Get("text").String())
+							node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".text", item.Get("text").String())
 							p++
 						case "image_url":
 							// If the assistant returned an inline data URL, preserve it for history fidelity.
@@ -232,9 +231,9 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 								if len(pieces) == 2 && len(pieces[1]) > 7 {
 									mime := pieces[0]
 									data := pieces[1][7:]
-									node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mime_type", mime)
-									node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", data)
-									node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".thoughtSignature", geminiCLIFunctionThoughtSignature)
+									node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".inlineData.mime_type", mime)
+									node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".inlineData.data", data)
+									node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".thoughtSignature", geminiCLIFunctionThoughtSignature)
 									p++
 								}
 							}
@@ -253,35 +252,35 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 						fid := tc.Get("id").String()
 						fname := util.SanitizeFunctionName(tc.Get("function.name").String())
 						fargs := tc.Get("function.arguments").String()
-						node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".functionCall.name", fname)
-						node, _ = sjson.SetRawBytes(node, "parts."+itoa(p)+".functionCall.args", []byte(fargs))
-						node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".thoughtSignature", geminiCLIFunctionThoughtSignature)
+						node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".functionCall.name", fname)
+						node, _ = util.SafeSetRawJSON(node, "parts."+itoa(p)+".functionCall.args", []byte(fargs))
+						node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".thoughtSignature", geminiCLIFunctionThoughtSignature)
 						p++
 						if fid != "" {
 							fIDs = append(fIDs, fid)
 						}
 					}
-					out, _ = sjson.SetRawBytes(out, "request.contents.-1", node)
+					out, _ = util.SafeSetRawJSON(out, "request.contents.-1", node)
 
 					// Append a single tool content combining name + response per function
 					toolNode := []byte(`{"role":"user","parts":[]}`)
 					pp := 0
 					for _, fid := range fIDs {
 						if name, ok := tcID2Name[fid]; ok {
-							toolNode, _ = sjson.SetBytes(toolNode, "parts."+itoa(pp)+".functionResponse.name", util.SanitizeFunctionName(name))
+							toolNode, _ = util.SafeSetJSON(toolNode, "parts."+itoa(pp)+".functionResponse.name", util.SanitizeFunctionName(name))
 							resp := toolResponses[fid]
 							if resp == "" {
 								resp = "{}"
 							}
-							toolNode, _ = sjson.SetBytes(toolNode, "parts."+itoa(pp)+".functionResponse.response.result", []byte(resp))
+							toolNode, _ = util.SafeSetJSON(toolNode, "parts."+itoa(pp)+".functionResponse.response.result", []byte(resp))
 							pp++
 						}
 					}
 					if pp > 0 {
-						out, _ = sjson.SetRawBytes(out, "request.contents.-1", toolNode)
+						out, _ = util.SafeSetRawJSON(out, "request.contents.-1", toolNode)
 					}
 				} else {
-					out, _ = sjson.SetRawBytes(out, "request.contents.-1", node)
+					out, _ = util.SafeSetRawJSON(out, "request.contents.-1", node)
 				}
 			}
 		}
@@ -305,12 +304,12 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 						if errRename != nil {
 							log.Warnf("Failed to rename parameters for tool '%s': %v", fn.Get("name").String(), errRename)
 							var errSet error
-							fnRaw, errSet = sjson.SetBytes(fnRaw, "parametersJsonSchema.type", "object")
+							fnRaw, errSet = util.SafeSetJSON(fnRaw, "parametersJsonSchema.type", "object")
 							if errSet != nil {
 								log.Warnf("Failed to set default schema type for tool '%s': %v", fn.Get("name").String(), errSet)
 								continue
 							}
-							fnRaw, errSet = sjson.SetRawBytes(fnRaw, "parametersJsonSchema.properties", []byte(`{}`))
+							fnRaw, errSet = util.SafeSetRawJSON(fnRaw, "parametersJsonSchema.properties", []byte(`{}`))
 							if errSet != nil {
 								log.Warnf("Failed to set default schema properties for tool '%s': %v", fn.Get("name").String(), errSet)
 								continue
@@ -320,23 +319,23 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 						}
 					} else {
 						var errSet error
-						fnRaw, errSet = sjson.SetBytes(fnRaw, "parametersJsonSchema.type", "object")
+						fnRaw, errSet = util.SafeSetJSON(fnRaw, "parametersJsonSchema.type", "object")
 						if errSet != nil {
 							log.Warnf("Failed to set default schema type for tool '%s': %v", fn.Get("name").String(), errSet)
 							continue
 						}
-						fnRaw, errSet = sjson.SetRawBytes(fnRaw, "parametersJsonSchema.properties", []byte(`{}`))
+						fnRaw, errSet = util.SafeSetRawJSON(fnRaw, "parametersJsonSchema.properties", []byte(`{}`))
 						if errSet != nil {
 							log.Warnf("Failed to set default schema properties for tool '%s': %v", fn.Get("name").String(), errSet)
 							continue
 						}
 					}
-					fnRaw, _ = sjson.SetBytes(fnRaw, "name", util.SanitizeFunctionName(fn.Get("name").String()))
-					fnRaw, _ = sjson.DeleteBytes(fnRaw, "strict")
+					fnRaw, _ = util.SafeSetJSON(fnRaw, "name", util.SanitizeFunctionName(fn.Get("name").String()))
+					fnRaw, _ = util.SafeDeleteJSON(fnRaw, "strict")
 					if !hasFunction {
-						functionToolNode, _ = sjson.SetRawBytes(functionToolNode, "functionDeclarations", []byte("[]"))
+						functionToolNode, _ = util.SafeSetRawJSON(functionToolNode, "functionDeclarations", []byte("[]"))
 					}
-					tmp, errSet := sjson.SetRawBytes(functionToolNode, "functionDeclarations.-1", fnRaw)
+					tmp, errSet := util.SafeSetRawJSON(functionToolNode, "functionDeclarations.-1", fnRaw)
 					if errSet != nil {
 						log.Warnf("Failed to append tool declaration for '%s': %v", fn.Get("name").String(), errSet)
 						continue
@@ -348,7 +347,7 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 			if gs := t.Get("google_search"); gs.Exists() {
 				googleToolNode := []byte(`{}`)
 				var errSet error
-				googleToolNode, errSet = sjson.SetRawBytes(googleToolNode, "googleSearch", []byte(gs.Raw))
+				googleToolNode, errSet = util.SafeSetRawJSON(googleToolNode, "googleSearch", []byte(gs.Raw))
 				if errSet != nil {
 					log.Warnf("Failed to set googleSearch tool: %v", errSet)
 					continue
@@ -358,7 +357,7 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 			if ce := t.Get("code_execution"); ce.Exists() {
 				codeToolNode := []byte(`{}`)
 				var errSet error
-				codeToolNode, errSet = sjson.SetRawBytes(codeToolNode, "codeExecution", []byte(ce.Raw))
+				codeToolNode, errSet = util.SafeSetRawJSON(codeToolNode, "codeExecution", []byte(ce.Raw))
 				if errSet != nil {
 					log.Warnf("Failed to set codeExecution tool: %v", errSet)
 					continue
@@ -368,7 +367,7 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 			if uc := t.Get("url_context"); uc.Exists() {
 				urlToolNode := []byte(`{}`)
 				var errSet error
-				urlToolNode, errSet = sjson.SetRawBytes(urlToolNode, "urlContext", []byte(uc.Raw))
+				urlToolNode, errSet = util.SafeSetRawJSON(urlToolNode, "urlContext", []byte(uc.Raw))
 				if errSet != nil {
 					log.Warnf("Failed to set urlContext tool: %v", errSet)
 					continue
@@ -379,18 +378,18 @@ func ConvertOpenAIRequestToGeminiCLI(modelName string, inputRawJSON []byte, _ bo
 		if hasFunction || len(googleSearchNodes) > 0 || len(codeExecutionNodes) > 0 || len(urlContextNodes) > 0 {
 			toolsNode := []byte("[]")
 			if hasFunction {
-				toolsNode, _ = sjson.SetRawBytes(toolsNode, "-1", functionToolNode)
+				toolsNode, _ = util.SafeSetRawJSON(toolsNode, "-1", functionToolNode)
 			}
 			for _, googleNode := range googleSearchNodes {
-				toolsNode, _ = sjson.SetRawBytes(toolsNode, "-1", googleNode)
+				toolsNode, _ = util.SafeSetRawJSON(toolsNode, "-1", googleNode)
 			}
 			for _, codeNode := range codeExecutionNodes {
-				toolsNode, _ = sjson.SetRawBytes(toolsNode, "-1", codeNode)
+				toolsNode, _ = util.SafeSetRawJSON(toolsNode, "-1", codeNode)
 			}
 			for _, urlNode := range urlContextNodes {
-				toolsNode, _ = sjson.SetRawBytes(toolsNode, "-1", urlNode)
+				toolsNode, _ = util.SafeSetRawJSON(toolsNode, "-1", urlNode)
 			}
-			out, _ = sjson.SetRawBytes(out, "request.tools", toolsNode)
+			out, _ = util.SafeSetRawJSON(out, "request.tools", toolsNode)
 		}
 	}
 
@@ -13,7 +13,6 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 const geminiClaudeThoughtSignature = "skip_thought_signature_validator"
@@ -33,7 +32,7 @@ func ConvertClaudeRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 	rawJSON := inputRawJSON
 	// Build output Gemini CLI request JSON
 	out := []byte(`{"contents":[]}`)
-	out, _ = sjson.SetBytes(out, "model", modelName)
+	out, _ = util.SafeSetJSON(out, "model", modelName)
 
 	// system instruction
 	if systemResult := gjson.GetBytes(rawJSON, "system"); systemResult.IsArray() {
@@ -44,18 +43,18 @@ func ConvertClaudeRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 				textResult := systemPromptResult.Get("text")
 				if textResult.Type == gjson.String {
 					part := []byte(`{"text":""}`)
-					part, _ = sjson.SetBytes(part, "text", textResult.String())
-					systemInstruction, _ = sjson.SetRawBytes(systemInstruction, "parts.-1", part)
+					part, _ = util.SafeSetJSON(part, "text", textResult.String())
+					systemInstruction, _ = util.SafeSetRawJSON(systemInstruction, "parts.-1", part)
 					hasSystemParts = true
 				}
 			}
 			return true
 		})
 		if hasSystemParts {
-			out, _ = sjson.SetRawBytes(out, "system_instruction", systemInstruction)
+			out, _ = util.SafeSetRawJSON(out, "system_instruction", systemInstruction)
 		}
 	} else if systemResult.Type == gjson.String {
-		out, _ = sjson.SetBytes(out, "system_instruction.parts.-1.text", systemResult.String())
+		out, _ = util.SafeSetJSON(out, "system_instruction.parts.-1.text", systemResult.String())
 	}
 
 	// contents
@@ -71,7 +70,7 @@ func ConvertClaudeRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 			}
 
 			contentJSON := []byte(`{"role":"","parts":[]}`)
-			contentJSON, _ = sjson.SetBytes(contentJSON, "role", role)
+			contentJSON, _ = util.SafeSetJSON(contentJSON, "role", role)
 
 			contentsResult := messageResult.Get("content")
 			if contentsResult.IsArray() {
@@ -79,8 +78,8 @@ func ConvertClaudeRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 					switch contentResult.Get("type").String() {
 					case "text":
 						part := []byte(`{"text":""}`)
-						part, _ = sjson.SetBytes(part, "text", contentResult.Get("text").String())
-						contentJSON, _ = sjson.SetRawBytes(contentJSON, "parts.-1", part)
+						part, _ = util.SafeSetJSON(part, "text", contentResult.Get("text").String())
+						contentJSON, _ = util.SafeSetRawJSON(contentJSON, "parts.-1", part)
 
 					case "tool_use":
 						functionName := contentResult.Get("name").String()
@@ -94,10 +93,10 @@ func ConvertClaudeRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 						argsResult := gjson.Parse(functionArgs)
 						if argsResult.IsObject() && gjson.Valid(functionArgs) {
 							part := []byte(`{"thoughtSignature":"","functionCall":{"name":"","args":{}}}`)
-							part, _ = sjson.SetBytes(part, "thoughtSignature", geminiClaudeThoughtSignature)
-							part, _ = sjson.SetBytes(part, "functionCall.name", functionName)
-							part, _ = sjson.SetRawBytes(part, "functionCall.args", []byte(functionArgs))
-							contentJSON, _ = sjson.SetRawBytes(contentJSON, "parts.-1", part)
+							part, _ = util.SafeSetJSON(part, "thoughtSignature", geminiClaudeThoughtSignature)
+							part, _ = util.SafeSetJSON(part, "functionCall.name", functionName)
+							part, _ = util.SafeSetRawJSON(part, "functionCall.args", []byte(functionArgs))
+							contentJSON, _ = util.SafeSetRawJSON(contentJSON, "parts.-1", part)
 						}
 
 					case "tool_result":
@@ -112,9 +111,9 @@ func ConvertClaudeRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 						funcName = util.SanitizeFunctionName(funcName)
 						responseData := contentResult.Get("content").Raw
 						part := []byte(`{"functionResponse":{"name":"","response":{"result":""}}}`)
-						part, _ = sjson.SetBytes(part, "functionResponse.name", funcName)
-						part, _ = sjson.SetBytes(part, "functionResponse.response.result", responseData)
-						contentJSON, _ = sjson.SetRawBytes(contentJSON, "parts.-1", part)
+						part, _ = util.SafeSetJSON(part, "functionResponse.name", funcName)
+						part, _ = util.SafeSetJSON(part, "functionResponse.response.result", responseData)
+						contentJSON, _ = util.SafeSetRawJSON(contentJSON, "parts.-1", part)
 
 					case "image":
 						source := contentResult.Get("source")
@@ -127,18 +126,18 @@ func ConvertClaudeRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 							return true
 						}
 						part := []byte(`{"inline_data":{"mime_type":"","data":""}}`)
-						part, _ = sjson.SetBytes(part, "inline_data.mime_type", mimeType)
-						part, _ = sjson.SetBytes(part, "inline_data.data", data)
-						contentJSON, _ = sjson.SetRawBytes(contentJSON, "parts.-1", part)
+						part, _ = util.SafeSetJSON(part, "inline_data.mime_type", mimeType)
+						part, _ = util.SafeSetJSON(part, "inline_data.data", data)
+						contentJSON, _ = util.SafeSetRawJSON(contentJSON, "parts.-1", part)
 					}
 					return true
 				})
-				out, _ = sjson.SetRawBytes(out, "contents.-1", contentJSON)
+				out, _ = util.SafeSetRawJSON(out, "contents.-1", contentJSON)
 			} else if contentsResult.Type == gjson.String {
 				part := []byte(`{"text":""}`)
-				part, _ = sjson.SetBytes(part, "text", contentsResult.String())
-				contentJSON, _ = sjson.SetRawBytes(contentJSON, "parts.-1", part)
-				out, _ = sjson.SetRawBytes(out, "contents.-1", contentJSON)
+				part, _ = util.SafeSetJSON(part, "text", contentsResult.String())
+				contentJSON, _ = util.SafeSetRawJSON(contentJSON, "parts.-1", part)
+				out, _ = util.SafeSetRawJSON(out, "contents.-1", contentJSON)
 			}
 			return true
 		})
@@ -162,7 +161,7 @@ func ConvertClaudeRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 					return true
 				})
 				if hasFC {
-					out, _ = sjson.DeleteBytes(out, fmt.Sprintf("contents.%d", len(arr)-1))
+					out, _ = util.SafeDeleteJSON(out, fmt.Sprintf("contents.%d", len(arr)-1))
 				}
 			}
 		}
@@ -177,33 +176,33 @@ func ConvertClaudeRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 				inputSchema := util.CleanJSONSchemaForGemini(inputSchemaResult.Raw)
 				tool := []byte(toolResult.Raw)
 				var err error
-				tool, err = sjson.DeleteBytes(tool, "input_schema")
+				tool, err = util.SafeDeleteJSON(tool, "input_schema")
 				if err != nil {
 					return true
 				}
-				tool, err = sjson.SetRawBytes(tool, "parametersJsonSchema", []byte(inputSchema))
+				tool, err = util.SafeSetRawJSON(tool, "parametersJsonSchema", []byte(inputSchema))
 				if err != nil {
 					return true
 				}
-				tool, _ = sjson.DeleteBytes(tool, "strict")
-				tool, _ = sjson.DeleteBytes(tool, "input_examples")
-				tool, _ = sjson.DeleteBytes(tool, "type")
-				tool, _ = sjson.DeleteBytes(tool, "cache_control")
-				tool, _ = sjson.DeleteBytes(tool, "defer_loading")
-				tool, _ = sjson.DeleteBytes(tool, "eager_input_streaming")
-				tool, _ = sjson.SetBytes(tool, "name", util.SanitizeFunctionName(gjson.GetBytes(tool, "name").String()))
+				tool, _ = util.SafeDeleteJSON(tool, "strict")
+				tool, _ = util.SafeDeleteJSON(tool, "input_examples")
+				tool, _ = util.SafeDeleteJSON(tool, "type")
+				tool, _ = util.SafeDeleteJSON(tool, "cache_control")
+				tool, _ = util.SafeDeleteJSON(tool, "defer_loading")
+				tool, _ = util.SafeDeleteJSON(tool, "eager_input_streaming")
+				tool, _ = util.SafeSetJSON(tool, "name", util.SanitizeFunctionName(gjson.GetBytes(tool, "name").String()))
 				if gjson.ValidBytes(tool) && gjson.ParseBytes(tool).IsObject() {
 					if !hasTools {
-						out, _ = sjson.SetRawBytes(out, "tools", []byte(`[{"functionDeclarations":[]}]`))
+						out, _ = util.SafeSetRawJSON(out, "tools", []byte(`[{"functionDeclarations":[]}]`))
 						hasTools = true
 					}
-					out, _ = sjson.SetRawBytes(out, "tools.0.functionDeclarations.-1", tool)
+					out, _ = util.SafeSetRawJSON(out, "tools.0.functionDeclarations.-1", tool)
 				}
 			}
 			return true
 		})
 		if !hasTools {
-			out, _ = sjson.DeleteBytes(out, "tools")
+			out, _ = util.SafeDeleteJSON(out, "tools")
 		}
 	}
 
@@ -221,15 +220,15 @@ func ConvertClaudeRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 
 		switch toolChoiceType {
 		case "auto":
-			out, _ = sjson.SetBytes(out, "toolConfig.functionCallingConfig.mode", "AUTO")
+			out, _ = util.SafeSetJSON(out, "toolConfig.functionCallingConfig.mode", "AUTO")
 		case "none":
-			out, _ = sjson.SetBytes(out, "toolConfig.functionCallingConfig.mode", "NONE")
+			out, _ = util.SafeSetJSON(out, "toolConfig.functionCallingConfig.mode", "NONE")
 		case "any":
-			out, _ = sjson.SetBytes(out, "toolConfig.functionCallingConfig.mode", "ANY")
+			out, _ = util.SafeSetJSON(out, "toolConfig.functionCallingConfig.mode", "ANY")
 		case "tool":
-			out, _ = sjson.SetBytes(out, "toolConfig.functionCallingConfig.mode", "ANY")
+			out, _ = util.SafeSetJSON(out, "toolConfig.functionCallingConfig.mode", "ANY")
 			if toolChoiceName != "" {
-				out, _ = sjson.SetBytes(out, "toolConfig.functionCallingConfig.allowedFunctionNames", []string{util.SanitizeFunctionName(toolChoiceName)})
+				out, _ = util.SafeSetJSON(out, "toolConfig.functionCallingConfig.allowedFunctionNames", []string{util.SanitizeFunctionName(toolChoiceName)})
 			}
 		}
 	}
@@ -241,8 +240,8 @@ func ConvertClaudeRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 		case "enabled":
 			if b := t.Get("budget_tokens"); b.Exists() && b.Type == gjson.Number {
 				budget := int(b.Int())
-				out, _ = sjson.SetBytes(out, "generationConfig.thinkingConfig.thinkingBudget", budget)
-				out, _ = sjson.SetBytes(out, "generationConfig.thinkingConfig.includeThoughts", true)
+				out, _ = util.SafeSetJSON(out, "generationConfig.thinkingConfig.thinkingBudget", budget)
+				out, _ = util.SafeSetJSON(out, "generationConfig.thinkingConfig.includeThoughts", true)
 			}
 		case "adaptive", "auto":
 			// For adaptive thinking:
@@ -254,29 +253,29 @@ func ConvertClaudeRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 				effort = strings.ToLower(strings.TrimSpace(v.String()))
 			}
 			if effort != "" {
-				out, _ = sjson.SetBytes(out, "generationConfig.thinkingConfig.thinkingLevel", effort)
+				out, _ = util.SafeSetJSON(out, "generationConfig.thinkingConfig.thinkingLevel", effort)
 			} else {
 				maxBudget := 0
 				if mi := registry.LookupModelInfo(modelName, "gemini"); mi != nil && mi.Thinking != nil {
 					maxBudget = mi.Thinking.Max
 				}
 				if maxBudget > 0 {
-					out, _ = sjson.SetBytes(out, "generationConfig.thinkingConfig.thinkingBudget", maxBudget)
+					out, _ = util.SafeSetJSON(out, "generationConfig.thinkingConfig.thinkingBudget", maxBudget)
 				} else {
-					out, _ = sjson.SetBytes(out, "generationConfig.thinkingConfig.thinkingLevel", "high")
+					out, _ = util.SafeSetJSON(out, "generationConfig.thinkingConfig.thinkingLevel", "high")
 				}
 			}
-			out, _ = sjson.SetBytes(out, "generationConfig.thinkingConfig.includeThoughts", true)
+			out, _ = util.SafeSetJSON(out, "generationConfig.thinkingConfig.includeThoughts", true)
 		}
 	}
 	if v := gjson.GetBytes(rawJSON, "temperature"); v.Exists() && v.Type == gjson.Number {
-		out, _ = sjson.SetBytes(out, "generationConfig.temperature", v.Num)
+		out, _ = util.SafeSetJSON(out, "generationConfig.temperature", v.Num)
 	}
 	if v := gjson.GetBytes(rawJSON, "top_p"); v.Exists() && v.Type == gjson.Number {
-		out, _ = sjson.SetBytes(out, "generationConfig.topP", v.Num)
+		out, _ = util.SafeSetJSON(out, "generationConfig.topP", v.Num)
 	}
 	if v := gjson.GetBytes(rawJSON, "top_k"); v.Exists() && v.Type == gjson.Number {
-		out, _ = sjson.SetBytes(out, "generationConfig.topK", v.Num)
+		out, _ = util.SafeSetJSON(out, "generationConfig.topK", v.Num)
 	}
 
 	result := out
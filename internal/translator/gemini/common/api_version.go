@@ -0,0 +1,69 @@
+package common
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+)
+
+// Gemini API versions understood by NormalizeAPIVersion.
+const (
+	APIVersionV1Alpha = "v1alpha"
+	APIVersionV1Beta  = "v1beta"
+)
+
+// apiVersionFields maps top-level request fields from their v1beta (camelCase)
+// spelling to their v1alpha (snake_case) spelling.
+var apiVersionFields = [][2]string{
+	{"safetySettings", "safety_settings"},
+	{"generationConfig", "generation_config"},
+	{"systemInstruction", "system_instruction"},
+	{"toolConfig", "tool_config"},
+	{"cachedContent", "cached_content"},
+}
+
+// NormalizeAPIVersion renames top-level request fields to the spelling used by
+// the given Gemini API version: snake_case (e.g. safety_settings) for v1alpha and
+// camelCase (e.g. safetySettings) for v1beta. When version is empty it is read
+// from the payload's api_version field. The api_version field is always removed.
+// If both spellings of a field are present, the target spelling is kept. Unknown
+// versions leave the fields unchanged.
+func NormalizeAPIVersion(rawJSON []byte, version string) []byte {
+	if detected := gjson.GetBytes(rawJSON, "api_version"); detected.Exists() {
+		if version == "" {
+			version = detected.String()
+		}
+		rawJSON, _ = util.SafeDeleteJSON(rawJSON, "api_version")
+	}
+
+	var toSnake bool
+	switch strings.ToLower(strings.TrimSpace(version)) {
+	case APIVersionV1Alpha:
+		toSnake = true
+	case APIVersionV1Beta:
+		toSnake = false
+	default:
+		return rawJSON
+	}
+
+	for _, field := range apiVersionFields {
+		from, to := field[1], field[0]
+		if toSnake {
+			from, to = field[0], field[1]
+		}
+		value := gjson.GetBytes(rawJSON, from)
+		if !value.Exists() {
+			continue
+		}
+		if !gjson.GetBytes(rawJSON, to).Exists() {
+			renamed, errRename := util.RenameKey(string(rawJSON), from, to)
+			if errRename == nil {
+				rawJSON = []byte(renamed)
+				continue
+			}
+		}
+		rawJSON, _ = util.SafeDeleteJSON(rawJSON, from)
+	}
+	return rawJSON
+}
@@ -0,0 +1,78 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestNormalizeAPIVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		version string
+		want    []string
+		absent  []string
+	}{
+		{
+			name:    "v1alpha renames to snake_case",
+			input:   `{"safetySettings":[{"category":"HARM_CATEGORY_HARASSMENT","threshold":"OFF"}],"generationConfig":{"temperature":1}}`,
+			version: APIVersionV1Alpha,
+			want:    []string{"safety_settings.0.threshold", "generation_config.temperature"},
+			absent:  []string{"safetySettings", "generationConfig"},
+		},
+		{
+			name:    "v1beta renames to camelCase",
+			input:   `{"safety_settings":[{"category":"HARM_CATEGORY_HARASSMENT","threshold":"OFF"}],"system_instruction":{"parts":[{"text":"x"}]}}`,
+			version: APIVersionV1Beta,
+			want:    []string{"safetySettings.0.threshold", "systemInstruction.parts.0.text"},
+			absent:  []string{"safety_settings", "system_instruction"},
+		},
+		{
+			name:   "version detected from api_version field",
+			input:  `{"api_version":"v1alpha","safetySettings":[]}`,
+			want:   []string{"safety_settings"},
+			absent: []string{"safetySettings", "api_version"},
+		},
+		{
+			name:    "parameter overrides api_version field",
+			input:   `{"api_version":"v1alpha","safety_settings":[]}`,
+			version: APIVersionV1Beta,
+			want:    []string{"safetySettings"},
+			absent:  []string{"safety_settings", "api_version"},
+		},
+		{
+			name:    "target spelling wins when both are present",
+			input:   `{"safetySettings":[{"threshold":"OFF"}],"safety_settings":[{"threshold":"BLOCK_NONE"}]}`,
+			version: APIVersionV1Alpha,
+			want:    []string{"safety_settings"},
+			absent:  []string{"safetySettings"},
+		},
+		{
+			name:   "unknown version leaves fields unchanged",
+			input:  `{"safetySettings":[]}`,
+			want:   []string{"safetySettings"},
+			absent: []string{"safety_settings"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := NormalizeAPIVersion([]byte(tt.input), tt.version)
+			for _, path := range tt.want {
+				if !gjson.GetBytes(out, path).Exists() {
+					t.Errorf("missing %s in %s", path, out)
+				}
+			}
+			for _, path := range tt.absent {
+				if gjson.GetBytes(out, path).Exists() {
+					t.Errorf("unexpected %s in %s", path, out)
+				}
+			}
+		})
+	}
+
+	out := NormalizeAPIVersion([]byte(`{"safetySettings":[{"threshold":"OFF"}],"safety_settings":[{"threshold":"BLOCK_NONE"}]}`), APIVersionV1Alpha)
+	if got := gjson.GetBytes(out, "safety_settings.0.threshold").String(); got != "BLOCK_NONE" {
+		t.Errorf("safety_settings.0.threshold = %q, want BLOCK_NONE", got)
+	}
+}
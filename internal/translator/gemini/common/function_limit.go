@@ -0,0 +1,54 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tidwall/gjson"
+)
+
+// MaxFunctionDeclarations caps the number of function declarations, summed
+// across all tools entries, that a Gemini or Antigravity request may carry.
+// Requests over the cap are rejected before they are sent upstream, where they
+// would fail with a 400 anyway. Zero means unlimited; the service applies
+// translator.max-function-declarations to it on startup.
+var MaxFunctionDeclarations int
+
+// ErrTooManyFunctionDeclarations is returned by CheckFunctionDeclarationLimit
+// when a request exceeds MaxFunctionDeclarations.
+var ErrTooManyFunctionDeclarations = errors.New("too many function declarations")
+
+// CountFunctionDeclarations returns the number of function declarations in a
+// Gemini request, counting both the function_declarations and
+// functionDeclarations spellings. Requests wrapped in a "request" envelope
+// (Gemini CLI, Antigravity) are also accepted.
+func CountFunctionDeclarations(rawJSON []byte) int {
+	tools := gjson.GetBytes(rawJSON, "tools")
+	if !tools.Exists() {
+		tools = gjson.GetBytes(rawJSON, "request.tools")
+	}
+	count := 0
+	tools.ForEach(func(_, tool gjson.Result) bool {
+		for _, key := range []string{"function_declarations", "functionDeclarations"} {
+			if declarations := tool.Get(key); declarations.IsArray() {
+				count += len(declarations.Array())
+			}
+		}
+		return true
+	})
+	return count
+}
+
+// CheckFunctionDeclarationLimit returns ErrTooManyFunctionDeclarations, with the
+// count and the limit, when rawJSON declares more functions than
+// MaxFunctionDeclarations allows.
+func CheckFunctionDeclarationLimit(rawJSON []byte) error {
+	limit := MaxFunctionDeclarations
+	if limit <= 0 {
+		return nil
+	}
+	if count := CountFunctionDeclarations(rawJSON); count > limit {
+		return fmt.Errorf("%w: request declares %d functions, the limit is %d", ErrTooManyFunctionDeclarations, count, limit)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package common
+
+import (
+	"errors"
+	"testing"
+)
+
+func setMaxFunctionDeclarations(t *testing.T, limit int) {
+	t.Helper()
+	previous := MaxFunctionDeclarations
+	MaxFunctionDeclarations = limit
+	t.Cleanup(func() { MaxFunctionDeclarations = previous })
+}
+
+func TestCountFunctionDeclarations(t *testing.T) {
+	tests := map[string]int{
+		`{}`: 0,
+		`{"tools":[{"function_declarations":[{"name":"a"},{"name":"b"}]},{"googleSearch":{}},{"functionDeclarations":[{"name":"c"}]}]}`: 3,
+		`{"request":{"tools":[{"function_declarations":[{"name":"a"}]},{"function_declarations":[{"name":"b"}]}]}}`:                     2,
+	}
+	for payload, want := range tests {
+		if got := CountFunctionDeclarations([]byte(payload)); got != want {
+			t.Errorf("CountFunctionDeclarations(%s) = %d, want %d", payload, got, want)
+		}
+	}
+}
+
+func TestCheckFunctionDeclarationLimit(t *testing.T) {
+	payload := []byte(`{"request":{"tools":[{"function_declarations":[{"name":"a"},{"name":"b"}]},{"function_declarations":[{"name":"c"}]}]}}`)
+
+	setMaxFunctionDeclarations(t, 0)
+	if err := CheckFunctionDeclarationLimit(payload); err != nil {
+		t.Fatalf("unlimited: %v", err)
+	}
+
+	setMaxFunctionDeclarations(t, 3)
+	if err := CheckFunctionDeclarationLimit(payload); err != nil {
+		t.Fatalf("at the cap: %v", err)
+	}
+
+	setMaxFunctionDeclarations(t, 2)
+	err := CheckFunctionDeclarationLimit(payload)
+	if !errors.Is(err, ErrTooManyFunctionDeclarations) {
+		t.Fatalf("over the cap: error = %v, want ErrTooManyFunctionDeclarations", err)
+	}
+	if want := "too many function declarations: request declares 3 functions, the limit is 2"; err.Error() != want {
+		t.Fatalf("error = %q, want %q", err.Error(), want)
+	}
+}
@@ -1,8 +1,10 @@
 package common
 
 import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 // DefaultSafetySettings returns the default Gemini safety configuration we attach to requests.
@@ -32,16 +34,53 @@ func DefaultSafetySettings() []map[string]string {
 }
 
 // AttachDefaultSafetySettings ensures the default safety settings are present when absent.
-// The caller must provide the target JSON path (e.g. "safetySettings" or "request.safetySettings").
+// The caller must provide the target JSON path (e.g. "safetySettings", "safety_settings" or
+// "request.safetySettings"); use SafetySettingsPath to pick the spelling for an API version.
+// Nothing is written when the settings already exist under either spelling, so a
+// payload never carries both safetySettings and safety_settings.
 func AttachDefaultSafetySettings(rawJSON []byte, path string) []byte {
 	if gjson.GetBytes(rawJSON, path).Exists() {
 		return rawJSON
 	}
+	if alt := alternateSafetySettingsPath(path); alt != "" && gjson.GetBytes(rawJSON, alt).Exists() {
+		return rawJSON
+	}
 
-	out, err := sjson.SetBytes(rawJSON, path, DefaultSafetySettings())
+	out, err := util.SafeSetJSON(rawJSON, path, DefaultSafetySettings())
 	if err != nil {
 		return rawJSON
 	}
 
 	return out
 }
+
+// SafetySettingsPath returns the safety settings path under parent (e.g. "request",
+// or "" for the top level) spelled for the given Gemini API version: safety_settings
+// for v1alpha and safetySettings otherwise.
+func SafetySettingsPath(parent, apiVersion string) string {
+	key := "safetySettings"
+	if strings.EqualFold(strings.TrimSpace(apiVersion), APIVersionV1Alpha) {
+		key = "safety_settings"
+	}
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+// alternateSafetySettingsPath swaps the final safetySettings/safety_settings segment
+// of path, returning "" when path ends in neither.
+func alternateSafetySettingsPath(path string) string {
+	parent, key := "", path
+	if idx := strings.LastIndex(path, "."); idx >= 0 {
+		parent, key = path[:idx+1], path[idx+1:]
+	}
+	switch key {
+	case "safetySettings":
+		return parent + "safety_settings"
+	case "safety_settings":
+		return parent + "safetySettings"
+	default:
+		return ""
+	}
+}
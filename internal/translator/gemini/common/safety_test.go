@@ -0,0 +1,41 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestAttachDefaultSafetySettings(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		path   string
+		want   string
+		absent string
+		keep   bool
+	}{
+		{"camelCase", `{}`, SafetySettingsPath("", APIVersionV1Beta), "safetySettings", "safety_settings", false},
+		{"snake_case", `{}`, SafetySettingsPath("", APIVersionV1Alpha), "safety_settings", "safetySettings", false},
+		{"nested snake_case", `{"request":{}}`, SafetySettingsPath("request", APIVersionV1Alpha), "request.safety_settings", "request.safetySettings", false},
+		{"existing other spelling", `{"safety_settings":[]}`, "safetySettings", "safety_settings", "safetySettings", true},
+		{"existing nested other spelling", `{"request":{"safetySettings":[]}}`, "request.safety_settings", "request.safetySettings", "request.safety_settings", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := AttachDefaultSafetySettings([]byte(tt.input), tt.path)
+			if !gjson.GetBytes(out, tt.want).Exists() {
+				t.Fatalf("missing %s in %s", tt.want, out)
+			}
+			if gjson.GetBytes(out, tt.absent).Exists() {
+				t.Fatalf("unexpected %s in %s", tt.absent, out)
+			}
+			if tt.keep && string(out) != tt.input {
+				t.Fatalf("payload modified: %s, want %s", out, tt.input)
+			}
+			if !tt.keep && gjson.GetBytes(out, tt.want+".#").Int() != int64(len(DefaultSafetySettings())) {
+				t.Fatalf("defaults not written under %s: %s", tt.want, out)
+			}
+		})
+	}
+}
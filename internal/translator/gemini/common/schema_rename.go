@@ -0,0 +1,125 @@
+package common
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+)
+
+// Translators that apply schema renames, named after their package paths under
+// internal/translator.
+const (
+	// SchemaRenameGeminiToGemini is the gemini → gemini request translator.
+	SchemaRenameGeminiToGemini = "gemini/gemini"
+	// SchemaRenameGeminiCLIToGemini is the gemini-cli → gemini request translator.
+	SchemaRenameGeminiCLIToGemini = "gemini/gemini-cli"
+	// SchemaRenameGeminiToGeminiCLI is the gemini → gemini-cli request translator.
+	SchemaRenameGeminiToGeminiCLI = "gemini-cli/gemini"
+	// SchemaRenameGeminiToAntigravity is the gemini → antigravity request translator.
+	SchemaRenameGeminiToAntigravity = "antigravity/gemini"
+)
+
+// SchemaRename moves a JSON schema field to the key the upstream API expects.
+// FromPath and ToPath are gjson paths relative to the translator's request root
+// ("" or "request."); a "#" segment matches every index of an array, and both
+// paths must use the same "#" segments. AppliesTo lists the translators, by their
+// SchemaRename* names, that perform the rename.
+type SchemaRename struct {
+	FromPath  string
+	ToPath    string
+	AppliesTo []string
+}
+
+var schemaRenames = []SchemaRename{
+	{
+		FromPath: "tools.#.function_declarations.#.parameters",
+		ToPath:   "tools.#.function_declarations.#.parametersJsonSchema",
+		AppliesTo: []string{
+			SchemaRenameGeminiToGemini,
+			SchemaRenameGeminiCLIToGemini,
+			SchemaRenameGeminiToGeminiCLI,
+			SchemaRenameGeminiToAntigravity,
+		},
+	},
+	{
+		FromPath:  "generationConfig.responseSchema",
+		ToPath:    "generationConfig.responseJsonSchema",
+		AppliesTo: []string{SchemaRenameGeminiToGemini},
+	},
+}
+
+// SchemaRenames returns a copy of the rename table.
+func SchemaRenames() []SchemaRename {
+	out := make([]SchemaRename, len(schemaRenames))
+	for i, rename := range schemaRenames {
+		rename.AppliesTo = slices.Clone(rename.AppliesTo)
+		out[i] = rename
+	}
+	return out
+}
+
+// ApplySchemaRenames performs every rename that applies to translator on
+// rawJSON, with paths resolved under root (e.g. "request." for wrapped
+// payloads). Entries whose source field is absent are skipped.
+func ApplySchemaRenames(rawJSON []byte, translator, root string) []byte {
+	for _, rename := range schemaRenames {
+		if !slices.Contains(rename.AppliesTo, translator) {
+			continue
+		}
+		for _, indices := range expandSchemaPath(rawJSON, root, rename.FromPath) {
+			from := root + fillSchemaPath(rename.FromPath, indices)
+			if !gjson.GetBytes(rawJSON, from).Exists() {
+				continue
+			}
+			renamed, errRename := util.RenameKey(string(rawJSON), from, root+fillSchemaPath(rename.ToPath, indices))
+			if errRename == nil {
+				rawJSON = []byte(renamed)
+			}
+		}
+	}
+	return rawJSON
+}
+
+// expandSchemaPath returns the array indices, one set per match, that the "#"
+// segments of path take in rawJSON.
+func expandSchemaPath(rawJSON []byte, root, path string) [][]int {
+	segments := strings.Split(path, ".")
+	matches := [][]int{nil}
+	prefix := []string(nil)
+	for _, segment := range segments {
+		if segment != "#" {
+			prefix = append(prefix, segment)
+			continue
+		}
+		var next [][]int
+		for _, indices := range matches {
+			arrayPath := root + fillSchemaPath(strings.Join(prefix, "."), indices)
+			array := gjson.GetBytes(rawJSON, arrayPath)
+			if !array.IsArray() {
+				continue
+			}
+			for i := range array.Array() {
+				next = append(next, append(slices.Clone(indices), i))
+			}
+		}
+		matches = next
+		prefix = append(prefix, "#")
+	}
+	return matches
+}
+
+// fillSchemaPath replaces the "#" segments of path with indices in order.
+func fillSchemaPath(path string, indices []int) string {
+	segments := strings.Split(path, ".")
+	next := 0
+	for i, segment := range segments {
+		if segment == "#" && next < len(indices) {
+			segments[i] = strconv.Itoa(indices[next])
+			next++
+		}
+	}
+	return strings.Join(segments, ".")
+}
@@ -0,0 +1,67 @@
+package common
+
+import (
+	"testing"
+)
+
+const schemaRenameSample = `{"tools":[{"function_declarations":[{"name":"a","parameters":{"type":"object","properties":{"x":{"type":"string"}}}},{"name":"b","description":"no params"}]},{"googleSearch":{}},{"function_declarations":[{"name":"c","parameters":{"type":"object"}}]}],"generationConfig":{"responseSchema":{"type":"object"},"temperature":0.5}}`
+
+func TestApplySchemaRenamesMatchesTranslatorOutputs(t *testing.T) {
+	// Expected outputs are what the hardcoded renames produced before the table.
+	const renamedTools = `"tools":[{"function_declarations":[{"name":"a","parametersJsonSchema":{"type":"object","properties":{"x":{"type":"string"}}}},{"name":"b","description":"no params"}]},{"googleSearch":{}},{"function_declarations":[{"name":"c","parametersJsonSchema":{"type":"object"}}]}]`
+	tests := []struct {
+		translator string
+		root       string
+		input      string
+		want       string
+	}{
+		{
+			translator: SchemaRenameGeminiToGemini,
+			input:      schemaRenameSample,
+			want:       `{` + renamedTools + `,"generationConfig":{"temperature":0.5,"responseJsonSchema":{"type":"object"}}}`,
+		},
+		{
+			translator: SchemaRenameGeminiCLIToGemini,
+			input:      schemaRenameSample,
+			want:       `{` + renamedTools + `,"generationConfig":{"responseSchema":{"type":"object"},"temperature":0.5}}`,
+		},
+		{
+			translator: SchemaRenameGeminiToGeminiCLI,
+			root:       "request.",
+			input:      `{"request":` + schemaRenameSample + `}`,
+			want:       `{"request":{` + renamedTools + `,"generationConfig":{"responseSchema":{"type":"object"},"temperature":0.5}}}`,
+		},
+		{
+			translator: SchemaRenameGeminiToAntigravity,
+			root:       "request.",
+			input:      `{"request":` + schemaRenameSample + `}`,
+			want:       `{"request":{` + renamedTools + `,"generationConfig":{"responseSchema":{"type":"object"},"temperature":0.5}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.translator, func(t *testing.T) {
+			if got := string(ApplySchemaRenames([]byte(tt.input), tt.translator, tt.root)); got != tt.want {
+				t.Fatalf("ApplySchemaRenames() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplySchemaRenamesUnknownTranslator(t *testing.T) {
+	if got := string(ApplySchemaRenames([]byte(schemaRenameSample), "openai/gemini", "")); got != schemaRenameSample {
+		t.Fatalf("unknown translator changed the payload: %s", got)
+	}
+}
+
+func TestSchemaRenamesReturnsCopy(t *testing.T) {
+	renames := SchemaRenames()
+	if len(renames) != 2 {
+		t.Fatalf("len(SchemaRenames()) = %d, want 2", len(renames))
+	}
+	renames[0].AppliesTo[0] = "mutated"
+	renames[1].ToPath = "mutated"
+	fresh := SchemaRenames()
+	if fresh[0].AppliesTo[0] == "mutated" || fresh[1].ToPath == "mutated" {
+		t.Fatal("SchemaRenames exposed the internal table")
+	}
+}
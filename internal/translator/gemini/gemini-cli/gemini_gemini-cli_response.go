@@ -9,7 +9,7 @@ import (
 	"context"
 
 	translatorcommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/common"
-	"github.com/tidwall/sjson"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 )
 
 var dataTag = []byte("data:")
@@ -36,7 +36,7 @@ func ConvertGeminiResponseToGeminiCLI(_ context.Context, _ string, originalReque
 	if bytes.Equal(rawJSON, []byte("[DONE]")) {
 		return [][]byte{}
 	}
-	rawJSON, _ = sjson.SetRawBytes([]byte(`{"response":{}}`), "response", rawJSON)
+	rawJSON, _ = util.SafeSetRawJSON([]byte(`{"response":{}}`), "response", rawJSON)
 	return [][]byte{rawJSON}
 }
 
@@ -51,7 +51,7 @@ func ConvertGeminiResponseToGeminiCLI(_ context.Context, _ string, originalReque
 // Returns:
 //   - []byte: A Gemini CLI-compatible JSON response.
 func ConvertGeminiResponseToGeminiCLINonStream(_ context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) []byte {
-	rawJSON, _ = sjson.SetRawBytes([]byte(`{"response":{}}`), "response", rawJSON)
+	rawJSON, _ = util.SafeSetRawJSON([]byte(`{"response":{}}`), "response", rawJSON)
 	return rawJSON
 }
 
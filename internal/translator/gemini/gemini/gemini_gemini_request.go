@@ -9,11 +9,11 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 func generateToolID() string {
@@ -22,13 +22,21 @@ func generateToolID() string {
 	return "toolu_" + hex.EncodeToString(b)
 }
 
+// DefaultThinkingBudget is injected as generationConfig.thinkingConfig.thinkingBudget
+// for thinking-capable models when the client specifies neither a budget nor a level.
+// Zero disables injection. It comes from translator.default-thinking-budget and is
+// not changed by config reloads, as requests read it without locking.
+var DefaultThinkingBudget int
+
 // ConvertGeminiRequestToGemini normalizes Gemini v1beta requests.
 //   - Adds a default role for each content if missing or invalid.
 //     The first message defaults to "user", then alternates user/model when needed.
+//   - Injects DefaultThinkingBudget for thinking-capable models without a thinking budget.
+//   - Passes googleSearch tool declarations through without renaming their fields.
 //
 // It keeps the payload otherwise unchanged.
-func ConvertGeminiRequestToGemini(_ string, inputRawJSON []byte, _ bool) []byte {
-	rawJSON := inputRawJSON
+func ConvertGeminiRequestToGemini(modelName string, inputRawJSON []byte, _ bool) []byte {
+	rawJSON := injectDefaultThinkingBudget(inputRawJSON, modelName)
 	// Fast path: if no contents field, only attach safety settings
 	contents := gjson.GetBytes(rawJSON, "contents")
 	if !contents.Exists() {
@@ -39,22 +47,10 @@ func ConvertGeminiRequestToGemini(_ string, inputRawJSON []byte, _ bool) []byte
 	if toolsResult.Exists() && toolsResult.IsArray() {
 		toolResults := toolsResult.Array()
 		for i := 0; i < len(toolResults); i++ {
-			if gjson.GetBytes(rawJSON, fmt.Sprintf("tools.%d.functionDeclarations", i)).Exists() {
-				strJson, _ := util.RenameKey(string(rawJSON), fmt.Sprintf("tools.%d.functionDeclarations", i), fmt.Sprintf("tools.%d.function_declarations", i))
-				rawJSON = []byte(strJson)
-			}
-
-			functionDeclarationsResult := gjson.GetBytes(rawJSON, fmt.Sprintf("tools.%d.function_declarations", i))
-			if functionDeclarationsResult.Exists() && functionDeclarationsResult.IsArray() {
-				functionDeclarationsResults := functionDeclarationsResult.Array()
-				for j := 0; j < len(functionDeclarationsResults); j++ {
-					parametersResult := gjson.GetBytes(rawJSON, fmt.Sprintf("tools.%d.function_declarations.%d.parameters", i, j))
-					if parametersResult.Exists() {
-						strJson, _ := util.RenameKey(string(rawJSON), fmt.Sprintf("tools.%d.function_declarations.%d.parameters", i, j), fmt.Sprintf("tools.%d.function_declarations.%d.parametersJsonSchema", i, j))
-						rawJSON = []byte(strJson)
-					}
-				}
+			if isBuiltinSearchTool(toolResults[i]) {
+				continue
 			}
+			rawJSON = mergeFunctionDeclarations(rawJSON, i)
 		}
 	}
 
@@ -77,7 +73,7 @@ func ConvertGeminiRequestToGemini(_ string, inputRawJSON []byte, _ bool) []byte
 				newRole = "user"
 			}
 			path := fmt.Sprintf("contents.%d.role", idx)
-			out, _ = sjson.SetBytes(out, path, newRole)
+			out, _ = util.SafeSetJSON(out, path, newRole)
 			role = newRole
 		}
 
@@ -104,17 +100,17 @@ func ConvertGeminiRequestToGemini(_ string, inputRawJSON []byte, _ bool) []byte
 			content.Get("parts").ForEach(func(partKey, part gjson.Result) bool {
 				partIdx := partKey.Int()
 				if part.Get("functionCall").Exists() {
-					out, _ = sjson.SetBytes(out, fmt.Sprintf("contents.%d.parts.%d.thoughtSignature", contentIdx, partIdx), "skip_thought_signature_validator")
+					out, _ = util.SafeSetJSON(out, fmt.Sprintf("contents.%d.parts.%d.thoughtSignature", contentIdx, partIdx), "skip_thought_signature_validator")
 					if !part.Get("functionCall.id").Exists() || part.Get("functionCall.id").String() == "" {
 						funcName := part.Get("functionCall.name").String()
 						toolID := generateToolID()
 						n := callCount[funcName]
 						callCount[funcName] = n + 1
 						toolIDMap[callKey{funcName, n}] = toolID
-						out, _ = sjson.SetBytes(out, fmt.Sprintf("contents.%d.parts.%d.functionCall.id", contentIdx, partIdx), toolID)
+						out, _ = util.SafeSetJSON(out, fmt.Sprintf("contents.%d.parts.%d.functionCall.id", contentIdx, partIdx), toolID)
 					}
 				} else if part.Get("thoughtSignature").Exists() {
-					out, _ = sjson.SetBytes(out, fmt.Sprintf("contents.%d.parts.%d.thoughtSignature", contentIdx, partIdx), "skip_thought_signature_validator")
+					out, _ = util.SafeSetJSON(out, fmt.Sprintf("contents.%d.parts.%d.thoughtSignature", contentIdx, partIdx), "skip_thought_signature_validator")
 				}
 				return true
 			})
@@ -127,7 +123,7 @@ func ConvertGeminiRequestToGemini(_ string, inputRawJSON []byte, _ bool) []byte
 						n := respCount[funcName]
 						respCount[funcName] = n + 1
 						if id, ok := toolIDMap[callKey{funcName, n}]; ok {
-							out, _ = sjson.SetBytes(out, fmt.Sprintf("contents.%d.parts.%d.functionResponse.id", contentIdx, partIdx), id)
+							out, _ = util.SafeSetJSON(out, fmt.Sprintf("contents.%d.parts.%d.functionResponse.id", contentIdx, partIdx), id)
 						}
 					}
 				}
@@ -137,10 +133,7 @@ func ConvertGeminiRequestToGemini(_ string, inputRawJSON []byte, _ bool) []byte
 		return true
 	})
 
-	if gjson.GetBytes(rawJSON, "generationConfig.responseSchema").Exists() {
-		strJson, _ := util.RenameKey(string(out), "generationConfig.responseSchema", "generationConfig.responseJsonSchema")
-		out = []byte(strJson)
-	}
+	out = common.ApplySchemaRenames(out, common.SchemaRenameGeminiToGemini, "")
 
 	// Backfill empty functionResponse.name from the preceding functionCall.name.
 	// Amp may send function responses with empty names; the Gemini API rejects these.
@@ -150,6 +143,72 @@ func ConvertGeminiRequestToGemini(_ string, inputRawJSON []byte, _ bool) []byte
 	return out
 }
 
+// mergeFunctionDeclarations moves tools[toolIdx].functionDeclarations into the
+// canonical function_declarations key. When a tool carries both spellings, the
+// non-empty arrays are concatenated in canonical-first order; declarations from
+// functionDeclarations whose name already appears in function_declarations are dropped.
+func mergeFunctionDeclarations(rawJSON []byte, toolIdx int) []byte {
+	camelPath := fmt.Sprintf("tools.%d.functionDeclarations", toolIdx)
+	snakePath := fmt.Sprintf("tools.%d.function_declarations", toolIdx)
+	camel := gjson.GetBytes(rawJSON, camelPath)
+	if !camel.Exists() {
+		return rawJSON
+	}
+	snake := gjson.GetBytes(rawJSON, snakePath)
+	if !snake.Exists() || !snake.IsArray() || len(snake.Array()) == 0 {
+		out, _ := util.SafeDeleteJSON(rawJSON, snakePath)
+		strJson, errRename := util.RenameKey(string(out), camelPath, snakePath)
+		if errRename != nil {
+			return rawJSON
+		}
+		return []byte(strJson)
+	}
+
+	out, _ := util.SafeDeleteJSON(rawJSON, camelPath)
+	if !camel.IsArray() {
+		return out
+	}
+	seen := make(map[string]struct{})
+	for _, decl := range snake.Array() {
+		seen[decl.Get("name").String()] = struct{}{}
+	}
+	for _, decl := range camel.Array() {
+		name := decl.Get("name").String()
+		if _, ok := seen[name]; ok && name != "" {
+			continue
+		}
+		seen[name] = struct{}{}
+		out, _ = util.SafeSetRawJSON(out, snakePath+".-1", []byte(decl.Raw))
+	}
+	return out
+}
+
+// isBuiltinSearchTool reports whether tool is a search grounding declaration such as
+// {"googleSearch": {}} without function declarations. These are passed through unchanged.
+func isBuiltinSearchTool(tool gjson.Result) bool {
+	if tool.Get("functionDeclarations").Exists() || tool.Get("function_declarations").Exists() {
+		return false
+	}
+	return tool.Get("googleSearch").Exists() || tool.Get("google_search").Exists()
+}
+
+// injectDefaultThinkingBudget sets DefaultThinkingBudget on requests for models that
+// support thinking. A client-provided thinkingBudget or thinkingLevel is never overridden.
+func injectDefaultThinkingBudget(rawJSON []byte, modelName string) []byte {
+	if DefaultThinkingBudget == 0 {
+		return rawJSON
+	}
+	thinkingConfig := gjson.GetBytes(rawJSON, "generationConfig.thinkingConfig")
+	if thinkingConfig.Get("thinkingBudget").Exists() || thinkingConfig.Get("thinkingLevel").Exists() {
+		return rawJSON
+	}
+	if mi := registry.LookupModelInfo(modelName, "gemini"); mi == nil || mi.Thinking == nil {
+		return rawJSON
+	}
+	out, _ := util.SafeSetJSON(rawJSON, "generationConfig.thinkingConfig.thinkingBudget", DefaultThinkingBudget)
+	return out
+}
+
 // backfillEmptyFunctionResponseNames walks the contents array and for each
 // model turn containing functionCall parts, records the call names in order.
 // For the immediately following user/function turn containing functionResponse
@@ -191,7 +250,7 @@ func backfillEmptyFunctionResponseNames(data []byte) []byte {
 					name := part.Get("functionResponse.name").String()
 					if strings.TrimSpace(name) == "" {
 						if ri < len(pendingCallNames) {
-							out, _ = sjson.SetBytes(out,
+							out, _ = util.SafeSetJSON(out,
 								fmt.Sprintf("contents.%d.parts.%d.functionResponse.name", contentIdx.Int(), partIdx.Int()),
 								pendingCallNames[ri])
 						} else {
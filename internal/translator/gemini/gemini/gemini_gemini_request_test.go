@@ -1,8 +1,10 @@
 package gemini
 
 import (
+	"fmt"
 	"testing"
 
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 	"github.com/tidwall/gjson"
 )
 
@@ -191,3 +193,139 @@ func TestBackfillEmptyFunctionResponseNames_MultipleGroups(t *testing.T) {
 		t.Errorf("Expected second group name 'Grep', got '%s'", name1)
 	}
 }
+
+func setDefaultThinkingBudget(t *testing.T, budget int) {
+	t.Helper()
+	prev := DefaultThinkingBudget
+	DefaultThinkingBudget = budget
+	t.Cleanup(func() { DefaultThinkingBudget = prev })
+}
+
+func TestConvertGeminiRequestToGemini_InjectsDefaultThinkingBudget(t *testing.T) {
+	setDefaultThinkingBudget(t, 4096)
+	input := []byte(`{"contents":[{"role":"user","parts":[{"text":"hi"}]}],"generationConfig":{"temperature":0.5}}`)
+
+	out := ConvertGeminiRequestToGemini("gemini-2.5-pro", input, false)
+
+	if got := gjson.GetBytes(out, "generationConfig.thinkingConfig.thinkingBudget").Int(); got != 4096 {
+		t.Errorf("Expected thinkingBudget 4096, got %d", got)
+	}
+	if got := gjson.GetBytes(out, "generationConfig.temperature").Float(); got != 0.5 {
+		t.Errorf("Expected temperature to be preserved, got %v", got)
+	}
+}
+
+func TestConvertGeminiRequestToGemini_KeepsClientThinkingConfig(t *testing.T) {
+	setDefaultThinkingBudget(t, 4096)
+	tests := []struct {
+		name  string
+		input string
+		path  string
+		want  string
+	}{
+		{"budget", `{"contents":[{"role":"user","parts":[{"text":"hi"}]}],"generationConfig":{"thinkingConfig":{"thinkingBudget":128}}}`, "generationConfig.thinkingConfig.thinkingBudget", "128"},
+		{"zero budget", `{"contents":[{"role":"user","parts":[{"text":"hi"}]}],"generationConfig":{"thinkingConfig":{"thinkingBudget":0}}}`, "generationConfig.thinkingConfig.thinkingBudget", "0"},
+		{"level", `{"contents":[{"role":"user","parts":[{"text":"hi"}]}],"generationConfig":{"thinkingConfig":{"thinkingLevel":"low"}}}`, "generationConfig.thinkingConfig.thinkingBudget", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := ConvertGeminiRequestToGemini("gemini-2.5-pro", []byte(tt.input), false)
+			if got := gjson.GetBytes(out, tt.path).Raw; got != tt.want {
+				t.Errorf("Expected %s = %q, got %q", tt.path, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConvertGeminiRequestToGemini_NoThinkingBudgetForNonThinkingModel(t *testing.T) {
+	setDefaultThinkingBudget(t, 4096)
+	input := []byte(`{"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`)
+
+	for _, model := range []string{"gemini-2.5-flash-image", "unknown-model"} {
+		out := ConvertGeminiRequestToGemini(model, input, false)
+		if gjson.GetBytes(out, "generationConfig.thinkingConfig").Exists() {
+			t.Errorf("Expected no thinkingConfig for %s, got %s", model, gjson.GetBytes(out, "generationConfig").Raw)
+		}
+	}
+}
+
+func TestConvertGeminiRequestToGemini_CachedMatchesFresh(t *testing.T) {
+	input := []byte(`{"contents":[{"role":"user","parts":[{"text":"hi"}]}],"tools":[{"functionDeclarations":[{"name":"t","parameters":{"type":"object"}}]}]}`)
+	cached := sdktranslator.NewRequestCache(8).Wrap(ConvertGeminiRequestToGemini)
+
+	fresh := ConvertGeminiRequestToGemini("gemini-2.5-pro", input, false)
+	for i := 0; i < 2; i++ {
+		if got := cached("gemini-2.5-pro", input, false); string(got) != string(fresh) {
+			t.Fatalf("cached output (call %d) = %s, want %s", i, got, fresh)
+		}
+	}
+}
+
+func TestConvertGeminiRequestToGemini_GoogleSearchToolPassthrough(t *testing.T) {
+	input := []byte(`{
+		"contents": [{"role": "user", "parts": [{"text": "latest news"}]}],
+		"tools": [
+			{"googleSearch": {"timeRangeFilter": {"startTime": "2024-01-01T00:00:00Z"}}},
+			{"functionDeclarations": [{"name": "lookup", "parameters": {"type": "object"}}]}
+		]
+	}`)
+
+	out := ConvertGeminiRequestToGemini("gemini-2.5-pro", input, false)
+
+	search := gjson.GetBytes(out, "tools.0")
+	if !search.Get("googleSearch").Exists() {
+		t.Fatalf("googleSearch tool was dropped: %s", out)
+	}
+	if got := search.Get("googleSearch.timeRangeFilter.startTime").String(); got != "2024-01-01T00:00:00Z" {
+		t.Errorf("googleSearch contents changed: %s", search.Raw)
+	}
+	if search.Get("function_declarations").Exists() {
+		t.Errorf("unexpected function_declarations on googleSearch tool: %s", search.Raw)
+	}
+	if !gjson.GetBytes(out, "tools.1.function_declarations.0.parametersJsonSchema").Exists() {
+		t.Errorf("function declarations were not normalized: %s", out)
+	}
+}
+
+func TestConvertGeminiRequestToGemini_MergesFunctionDeclarationSpellings(t *testing.T) {
+	input := []byte(`{
+		"contents": [{"role": "user", "parts": [{"text": "hi"}]}],
+		"tools": [
+			{
+				"functionDeclarations": [
+					{"name": "read", "parameters": {"type": "object"}},
+					{"name": "shared", "parameters": {"type": "object"}}
+				],
+				"function_declarations": [
+					{"name": "write", "parameters": {"type": "object"}},
+					{"name": "shared", "parameters": {"type": "object"}}
+				]
+			},
+			{
+				"functionDeclarations": [{"name": "grep", "parameters": {"type": "object"}}],
+				"function_declarations": []
+			}
+		]
+	}`)
+
+	out := ConvertGeminiRequestToGemini("gemini-2.5-pro", input, false)
+
+	for i, want := range [][]string{{"write", "shared", "read"}, {"grep"}} {
+		tool := gjson.GetBytes(out, fmt.Sprintf("tools.%d", i))
+		if tool.Get("functionDeclarations").Exists() {
+			t.Fatalf("tools.%d still has functionDeclarations: %s", i, tool.Raw)
+		}
+		decls := tool.Get("function_declarations").Array()
+		if len(decls) != len(want) {
+			t.Fatalf("tools.%d has %d declarations, want %d: %s", i, len(decls), len(want), tool.Raw)
+		}
+		for j, name := range want {
+			if got := decls[j].Get("name").String(); got != name {
+				t.Errorf("tools.%d.function_declarations.%d.name = %q, want %q", i, j, got, name)
+			}
+			if !decls[j].Get("parametersJsonSchema").Exists() || decls[j].Get("parameters").Exists() {
+				t.Errorf("tools.%d.function_declarations.%d parameters not renamed: %s", i, j, decls[j].Raw)
+			}
+		}
+	}
+}
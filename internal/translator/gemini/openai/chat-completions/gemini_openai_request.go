@@ -11,7 +11,6 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 const geminiFunctionThoughtSignature = "skip_thought_signature_validator"
@@ -32,11 +31,11 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 	out := []byte(`{"contents":[]}`)
 
 	// Model
-	out, _ = sjson.SetBytes(out, "model", modelName)
+	out, _ = util.SafeSetJSON(out, "model", modelName)
 
 	// Let user-provided generationConfig pass through
 	if genConfig := gjson.GetBytes(rawJSON, "generationConfig"); genConfig.Exists() {
-		out, _ = sjson.SetRawBytes(out, "generationConfig", []byte(genConfig.Raw))
+		out, _ = util.SafeSetRawJSON(out, "generationConfig", []byte(genConfig.Raw))
 	}
 
 	// Apply thinking configuration: convert OpenAI reasoning_effort to Gemini thinkingConfig.
@@ -47,30 +46,30 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 		if effort != "" {
 			thinkingPath := "generationConfig.thinkingConfig"
 			if effort == "auto" {
-				out, _ = sjson.SetBytes(out, thinkingPath+".thinkingBudget", -1)
-				out, _ = sjson.SetBytes(out, thinkingPath+".includeThoughts", true)
+				out, _ = util.SafeSetJSON(out, thinkingPath+".thinkingBudget", -1)
+				out, _ = util.SafeSetJSON(out, thinkingPath+".includeThoughts", true)
 			} else {
-				out, _ = sjson.SetBytes(out, thinkingPath+".thinkingLevel", effort)
-				out, _ = sjson.SetBytes(out, thinkingPath+".includeThoughts", effort != "none")
+				out, _ = util.SafeSetJSON(out, thinkingPath+".thinkingLevel", effort)
+				out, _ = util.SafeSetJSON(out, thinkingPath+".includeThoughts", effort != "none")
 			}
 		}
 	}
 
 	// Temperature/top_p/top_k
 	if tr := gjson.GetBytes(rawJSON, "temperature"); tr.Exists() && tr.Type == gjson.Number {
-		out, _ = sjson.SetBytes(out, "generationConfig.temperature", tr.Num)
+		out, _ = util.SafeSetJSON(out, "generationConfig.temperature", tr.Num)
 	}
 	if tpr := gjson.GetBytes(rawJSON, "top_p"); tpr.Exists() && tpr.Type == gjson.Number {
-		out, _ = sjson.SetBytes(out, "generationConfig.topP", tpr.Num)
+		out, _ = util.SafeSetJSON(out, "generationConfig.topP", tpr.Num)
 	}
 	if tkr := gjson.GetBytes(rawJSON, "top_k"); tkr.Exists() && tkr.Type == gjson.Number {
-		out, _ = sjson.SetBytes(out, "generationConfig.topK", tkr.Num)
+		out, _ = util.SafeSetJSON(out, "generationConfig.topK", tkr.Num)
 	}
 
 	// Candidate count (OpenAI 'n' parameter)
 	if n := gjson.GetBytes(rawJSON, "n"); n.Exists() && n.Type == gjson.Number {
 		if val := n.Int(); val > 1 {
-			out, _ = sjson.SetBytes(out, "generationConfig.candidateCount", val)
+			out, _ = util.SafeSetJSON(out, "generationConfig.candidateCount", val)
 		}
 	}
 
@@ -87,7 +86,7 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 			}
 		}
 		if len(responseMods) > 0 {
-			out, _ = sjson.SetBytes(out, "generationConfig.responseModalities", responseMods)
+			out, _ = util.SafeSetJSON(out, "generationConfig.responseModalities", responseMods)
 		}
 	}
 
@@ -95,10 +94,10 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 	// If the input uses top-level image_config.aspect_ratio, map it into generationConfig.imageConfig.aspectRatio.
 	if imgCfg := gjson.GetBytes(rawJSON, "image_config"); imgCfg.Exists() && imgCfg.IsObject() {
 		if ar := imgCfg.Get("aspect_ratio"); ar.Exists() && ar.Type == gjson.String {
-			out, _ = sjson.SetBytes(out, "generationConfig.imageConfig.aspectRatio", ar.Str)
+			out, _ = util.SafeSetJSON(out, "generationConfig.imageConfig.aspectRatio", ar.Str)
 		}
 		if size := imgCfg.Get("image_size"); size.Exists() && size.Type == gjson.String {
-			out, _ = sjson.SetBytes(out, "generationConfig.imageConfig.imageSize", size.Str)
+			out, _ = util.SafeSetJSON(out, "generationConfig.imageConfig.imageSize", size.Str)
 		}
 	}
 
@@ -149,19 +148,19 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 			if (role == "system" || role == "developer") && len(arr) > 1 {
 				// system -> systemInstruction as a user message style
 				if content.Type == gjson.String {
-					out, _ = sjson.SetBytes(out, "systemInstruction.role", "user")
-					out, _ = sjson.SetBytes(out, fmt.Sprintf("systemInstruction.parts.%d.text", systemPartIndex), content.String())
+					out, _ = util.SafeSetJSON(out, "systemInstruction.role", "user")
+					out, _ = util.SafeSetJSON(out, fmt.Sprintf("systemInstruction.parts.%d.text", systemPartIndex), content.String())
 					systemPartIndex++
 				} else if content.IsObject() && content.Get("type").String() == "text" {
-					out, _ = sjson.SetBytes(out, "systemInstruction.role", "user")
-					out, _ = sjson.SetBytes(out, fmt.Sprintf("systemInstruction.parts.%d.text", systemPartIndex), content.Get("text").String())
+					out, _ = util.SafeSetJSON(out, "systemInstruction.role", "user")
+					out, _ = util.SafeSetJSON(out, fmt.Sprintf("systemInstruction.parts.%d.text", systemPartIndex), content.Get("text").String())
 					systemPartIndex++
 				} else if content.IsArray() {
 					contents := content.Array()
 					if len(contents) > 0 {
-						out, _ = sjson.SetBytes(out, "systemInstruction.role", "user")
+						out, _ = util.SafeSetJSON(out, "systemInstruction.role", "user")
 						for j := 0; j < len(contents); j++ {
-							out, _ = sjson.SetBytes(out, fmt.Sprintf("systemInstruction.parts.%d.text", systemPartIndex), contents[j].Get("text").String())
+							out, _ = util.SafeSetJSON(out, fmt.Sprintf("systemInstruction.parts.%d.text", systemPartIndex), contents[j].Get("text").String())
 							systemPartIndex++
 						}
 					}
@@ -170,7 +169,7 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 				// Build single user content node to avoid splitting into multiple contents
 				node := []byte(`{"role":"user","parts":[]}`)
 				if content.Type == gjson.String {
-					node, _ = sjson.SetBytes(node, "parts.0.text", content.String())
+					node, _ = util.SafeSetJSON(node, "parts.0.text", content.String())
 				} else if content.IsArray() {
 					items := content.Array()
 					p := 0
@@ -179,7 +178,7 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 						case "text":
 							text := item.Get("text").String()
 							if text != "" {
-								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".text", text)
+								node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".text", text)
 							}
 							p++
 						case "image_url":
@@ -189,9 +188,9 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 								if len(pieces) == 2 && len(pieces[1]) > 7 {
 									mime := pieces[0]
 									data := pieces[1][7:]
-									node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mime_type", mime)
-									node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", data)
-									node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".thoughtSignature", geminiFunctionThoughtSignature)
+									node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".inlineData.mime_type", mime)
+									node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".inlineData.data", data)
+									node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".thoughtSignature", geminiFunctionThoughtSignature)
 									p++
 								}
 							}
@@ -203,8 +202,8 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 								ext = sp[len(sp)-1]
 							}
 							if mimeType, ok := misc.MimeTypes[ext]; ok {
-								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mime_type", mimeType)
-								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", fileData)
+								node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".inlineData.mime_type", mimeType)
+								node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".inlineData.data", fileData)
 								p++
 							} else {
 								log.Warnf("Unknown file name extension '%s' in user message, skip", ext)
@@ -212,13 +211,13 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 						}
 					}
 				}
-				out, _ = sjson.SetRawBytes(out, "contents.-1", node)
+				out, _ = util.SafeSetRawJSON(out, "contents.-1", node)
 			} else if role == "assistant" {
 				node := []byte(`{"role":"model","parts":[]}`)
 				p := 0
 				if content.Type == gjson.String {
 					// Assistant text -> single model content
-					node, _ = sjson.SetBytes(node, "parts.-1.text", content.String())
+					node, _ = util.SafeSetJSON(node, "parts.-1.text", content.String())
 					p++
 				} else if content.IsArray() {
 					// Assistant multimodal content (e.g. text + image) -> single model content with parts
@@ -227,7 +226,7 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 						case "text":
 							text := item.Get("text").String()
 							if text != "" {
-								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".text", text)
+								node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".text", text)
 							}
 							p++
 						case "image_url":
@@ -238,9 +237,9 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 								if len(pieces) == 2 && len(pieces[1]) > 7 {
 									mime := pieces[0]
 									data := pieces[1][7:]
-									node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mime_type", mime)
-									node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", data)
-									node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".thoughtSignature", geminiFunctionThoughtSignature)
+									node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".inlineData.mime_type", mime)
+									node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".inlineData.data", data)
+									node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".thoughtSignature", geminiFunctionThoughtSignature)
 									p++
 								}
 							}
@@ -259,35 +258,35 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 						fid := tc.Get("id").String()
 						fname := util.SanitizeFunctionName(tc.Get("function.name").String())
 						fargs := tc.Get("function.arguments").String()
-						node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".functionCall.name", fname)
-						node, _ = sjson.SetRawBytes(node, "parts."+itoa(p)+".functionCall.args", []byte(fargs))
-						node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".thoughtSignature", geminiFunctionThoughtSignature)
+						node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".functionCall.name", fname)
+						node, _ = util.SafeSetRawJSON(node, "parts."+itoa(p)+".functionCall.args", []byte(fargs))
+						node, _ = util.SafeSetJSON(node, "parts."+itoa(p)+".thoughtSignature", geminiFunctionThoughtSignature)
 						p++
 						if fid != "" {
 							fIDs = append(fIDs, fid)
 						}
 					}
-					out, _ = sjson.SetRawBytes(out, "contents.-1", node)
+					out, _ = util.SafeSetRawJSON(out, "contents.-1", node)
 
 					// Append a single tool content combining name + response per function
 					toolNode := []byte(`{"role":"user","parts":[]}`)
 					pp := 0
 					for _, fid := range fIDs {
 						if name, ok := tcID2Name[fid]; ok {
-							toolNode, _ = sjson.SetBytes(toolNode, "parts."+itoa(pp)+".functionResponse.name", util.SanitizeFunctionName(name))
+							toolNode, _ = util.SafeSetJSON(toolNode, "parts."+itoa(pp)+".functionResponse.name", util.SanitizeFunctionName(name))
 							resp := toolResponses[fid]
 							if resp == "" {
 								resp = "{}"
 							}
-							toolNode, _ = sjson.SetBytes(toolNode, "parts."+itoa(pp)+".functionResponse.response.result", []byte(resp))
+							toolNode, _ = util.SafeSetJSON(toolNode, "parts."+itoa(pp)+".functionResponse.response.result", []byte(resp))
 							pp++
 						}
 					}
 					if pp > 0 {
-						out, _ = sjson.SetRawBytes(out, "contents.-1", toolNode)
+						out, _ = util.SafeSetRawJSON(out, "contents.-1", toolNode)
 					}
 				} else {
-					out, _ = sjson.SetRawBytes(out, "contents.-1", node)
+					out, _ = util.SafeSetRawJSON(out, "contents.-1", node)
 				}
 			}
 		}
@@ -312,12 +311,12 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 							log.Warnf("Failed to rename parameters for tool '%s': %v", fn.Get("name").String(), errRename)
 							var errSet error
 							fnRawBytes := []byte(fnRaw)
-							fnRawBytes, errSet = sjson.SetBytes(fnRawBytes, "parametersJsonSchema.type", "object")
+							fnRawBytes, errSet = util.SafeSetJSON(fnRawBytes, "parametersJsonSchema.type", "object")
 							if errSet != nil {
 								log.Warnf("Failed to set default schema type for tool '%s': %v", fn.Get("name").String(), errSet)
 								continue
 							}
-							fnRawBytes, errSet = sjson.SetRawBytes(fnRawBytes, "parametersJsonSchema.properties", []byte(`{}`))
+							fnRawBytes, errSet = util.SafeSetRawJSON(fnRawBytes, "parametersJsonSchema.properties", []byte(`{}`))
 							if errSet != nil {
 								log.Warnf("Failed to set default schema properties for tool '%s': %v", fn.Get("name").String(), errSet)
 								continue
@@ -329,12 +328,12 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 					} else {
 						var errSet error
 						fnRawBytes := []byte(fnRaw)
-						fnRawBytes, errSet = sjson.SetBytes(fnRawBytes, "parametersJsonSchema.type", "object")
+						fnRawBytes, errSet = util.SafeSetJSON(fnRawBytes, "parametersJsonSchema.type", "object")
 						if errSet != nil {
 							log.Warnf("Failed to set default schema type for tool '%s': %v", fn.Get("name").String(), errSet)
 							continue
 						}
-						fnRawBytes, errSet = sjson.SetRawBytes(fnRawBytes, "parametersJsonSchema.properties", []byte(`{}`))
+						fnRawBytes, errSet = util.SafeSetRawJSON(fnRawBytes, "parametersJsonSchema.properties", []byte(`{}`))
 						if errSet != nil {
 							log.Warnf("Failed to set default schema properties for tool '%s': %v", fn.Get("name").String(), errSet)
 							continue
@@ -342,13 +341,13 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 						fnRaw = string(fnRawBytes)
 					}
 					fnRawBytes := []byte(fnRaw)
-					fnRawBytes, _ = sjson.SetBytes(fnRawBytes, "name", util.SanitizeFunctionName(fn.Get("name").String()))
+					fnRawBytes, _ = util.SafeSetJSON(fnRawBytes, "name", util.SanitizeFunctionName(fn.Get("name").String()))
+					fnRawBytes, _ = util.SafeDeleteJSON(fnRawBytes, "strict")
 					fnRaw = string(fnRawBytes)
-					fnRaw, _ = sjson.Delete(fnRaw, "strict")
 					if !hasFunction {
-						functionToolNode, _ = sjson.SetRawBytes(functionToolNode, "functionDeclarations", []byte("[]"))
+						functionToolNode, _ = util.SafeSetRawJSON(functionToolNode, "functionDeclarations", []byte("[]"))
 					}
-					tmp, errSet := sjson.SetRawBytes(functionToolNode, "functionDeclarations.-1", []byte(fnRaw))
+					tmp, errSet := util.SafeSetRawJSON(functionToolNode, "functionDeclarations.-1", []byte(fnRaw))
 					if errSet != nil {
 						log.Warnf("Failed to append tool declaration for '%s': %v", fn.Get("name").String(), errSet)
 						continue
@@ -360,7 +359,7 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 			if gs := t.Get("google_search"); gs.Exists() {
 				googleToolNode := []byte(`{}`)
 				var errSet error
-				googleToolNode, errSet = sjson.SetRawBytes(googleToolNode, "googleSearch", []byte(gs.Raw))
+				googleToolNode, errSet = util.SafeSetRawJSON(googleToolNode, "googleSearch", []byte(gs.Raw))
 				if errSet != nil {
 					log.Warnf("Failed to set googleSearch tool: %v", errSet)
 					continue
@@ -370,7 +369,7 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 			if ce := t.Get("code_execution"); ce.Exists() {
 				codeToolNode := []byte(`{}`)
 				var errSet error
-				codeToolNode, errSet = sjson.SetRawBytes(codeToolNode, "codeExecution", []byte(ce.Raw))
+				codeToolNode, errSet = util.SafeSetRawJSON(codeToolNode, "codeExecution", []byte(ce.Raw))
 				if errSet != nil {
 					log.Warnf("Failed to set codeExecution tool: %v", errSet)
 					continue
@@ -380,7 +379,7 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 			if uc := t.Get("url_context"); uc.Exists() {
 				urlToolNode := []byte(`{}`)
 				var errSet error
-				urlToolNode, errSet = sjson.SetRawBytes(urlToolNode, "urlContext", []byte(uc.Raw))
+				urlToolNode, errSet = util.SafeSetRawJSON(urlToolNode, "urlContext", []byte(uc.Raw))
 				if errSet != nil {
 					log.Warnf("Failed to set urlContext tool: %v", errSet)
 					continue
@@ -391,18 +390,18 @@ func ConvertOpenAIRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 		if hasFunction || len(googleSearchNodes) > 0 || len(codeExecutionNodes) > 0 || len(urlContextNodes) > 0 {
 			toolsNode := []byte("[]")
 			if hasFunction {
-				toolsNode, _ = sjson.SetRawBytes(toolsNode, "-1", functionToolNode)
+				toolsNode, _ = util.SafeSetRawJSON(toolsNode, "-1", functionToolNode)
 			}
 			for _, googleNode := range googleSearchNodes {
-				toolsNode, _ = sjson.SetRawBytes(toolsNode, "-1", googleNode)
+				toolsNode, _ = util.SafeSetRawJSON(toolsNode, "-1", googleNode)
 			}
 			for _, codeNode := range codeExecutionNodes {
-				toolsNode, _ = sjson.SetRawBytes(toolsNode, "-1", codeNode)
+				toolsNode, _ = util.SafeSetRawJSON(toolsNode, "-1", codeNode)
 			}
 			for _, urlNode := range urlContextNodes {
-				toolsNode, _ = sjson.SetRawBytes(toolsNode, "-1", urlNode)
+				toolsNode, _ = util.SafeSetRawJSON(toolsNode, "-1", urlNode)
 			}
-			out, _ = sjson.SetRawBytes(out, "tools", toolsNode)
+			out, _ = util.SafeSetRawJSON(out, "tools", toolsNode)
 		}
 	}
 
@@ -7,7 +7,6 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 const geminiResponsesThoughtSignature = "skip_thought_signature_validator"
@@ -27,8 +26,8 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 	// Extract system instruction from OpenAI "instructions" field
 	if instructions := root.Get("instructions"); instructions.Exists() {
 		systemInstr := []byte(`{"parts":[{"text":""}]}`)
-		systemInstr, _ = sjson.SetBytes(systemInstr, "parts.0.text", instructions.String())
-		out, _ = sjson.SetRawBytes(out, "systemInstruction", systemInstr)
+		systemInstr, _ = util.SafeSetJSON(systemInstr, "parts.0.text", instructions.String())
+		out, _ = util.SafeSetRawJSON(out, "systemInstruction", systemInstr)
 	}
 
 	// Convert input messages to Gemini contents format
@@ -129,18 +128,18 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 							contentArray.ForEach(func(_, contentItem gjson.Result) bool {
 								part := []byte(`{"text":""}`)
 								text := contentItem.Get("text").String()
-								part, _ = sjson.SetBytes(part, "text", text)
-								systemInstr, _ = sjson.SetRawBytes(systemInstr, "parts.-1", part)
+								part, _ = util.SafeSetJSON(part, "text", text)
+								systemInstr, _ = util.SafeSetRawJSON(systemInstr, "parts.-1", part)
 								return true
 							})
 						} else if contentArray.Type == gjson.String {
 							part := []byte(`{"text":""}`)
-							part, _ = sjson.SetBytes(part, "text", contentArray.String())
-							systemInstr, _ = sjson.SetRawBytes(systemInstr, "parts.-1", part)
+							part, _ = util.SafeSetJSON(part, "text", contentArray.String())
+							systemInstr, _ = util.SafeSetRawJSON(systemInstr, "parts.-1", part)
 						}
 
 						if gjson.GetBytes(systemInstr, "parts.#").Int() > 0 {
-							out, _ = sjson.SetRawBytes(out, "systemInstruction", systemInstr)
+							out, _ = util.SafeSetRawJSON(out, "systemInstruction", systemInstr)
 						}
 					}
 					continue
@@ -160,11 +159,11 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 							return
 						}
 						one := []byte(`{"role":"","parts":[]}`)
-						one, _ = sjson.SetBytes(one, "role", currentRole)
+						one, _ = util.SafeSetJSON(one, "role", currentRole)
 						for _, part := range currentParts {
-							one, _ = sjson.SetRawBytes(one, "parts.-1", part)
+							one, _ = util.SafeSetRawJSON(one, "parts.-1", part)
 						}
-						out, _ = sjson.SetRawBytes(out, "contents.-1", one)
+						out, _ = util.SafeSetRawJSON(out, "contents.-1", one)
 						currentParts = currentParts[:0]
 					}
 
@@ -203,7 +202,7 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 						case "input_text", "output_text":
 							if text := contentItem.Get("text"); text.Exists() {
 								partJSON = []byte(`{"text":""}`)
-								partJSON, _ = sjson.SetBytes(partJSON, "text", text.String())
+								partJSON, _ = util.SafeSetJSON(partJSON, "text", text.String())
 							}
 						case "input_image":
 							imageURL := contentItem.Get("image_url").String()
@@ -233,8 +232,8 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 								}
 								if data != "" {
 									partJSON = []byte(`{"inline_data":{"mime_type":"","data":""}}`)
-									partJSON, _ = sjson.SetBytes(partJSON, "inline_data.mime_type", mimeType)
-									partJSON, _ = sjson.SetBytes(partJSON, "inline_data.data", data)
+									partJSON, _ = util.SafeSetJSON(partJSON, "inline_data.mime_type", mimeType)
+									partJSON, _ = util.SafeSetJSON(partJSON, "inline_data.data", data)
 								}
 							}
 						case "input_audio":
@@ -261,8 +260,8 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 									}
 								}
 								partJSON = []byte(`{"inline_data":{"mime_type":"","data":""}}`)
-								partJSON, _ = sjson.SetBytes(partJSON, "inline_data.mime_type", mimeType)
-								partJSON, _ = sjson.SetBytes(partJSON, "inline_data.data", audioData)
+								partJSON, _ = util.SafeSetJSON(partJSON, "inline_data.mime_type", mimeType)
+								partJSON, _ = util.SafeSetJSON(partJSON, "inline_data.data", audioData)
 							}
 						}
 
@@ -285,9 +284,9 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 					}
 
 					one := []byte(`{"role":"","parts":[{"text":""}]}`)
-					one, _ = sjson.SetBytes(one, "role", effRole)
-					one, _ = sjson.SetBytes(one, "parts.0.text", contentArray.String())
-					out, _ = sjson.SetRawBytes(out, "contents.-1", one)
+					one, _ = util.SafeSetJSON(one, "role", effRole)
+					one, _ = util.SafeSetJSON(one, "parts.0.text", contentArray.String())
+					out, _ = util.SafeSetRawJSON(out, "contents.-1", one)
 				}
 
 			case "function_call":
@@ -297,18 +296,18 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 
 				modelContent := []byte(`{"role":"model","parts":[]}`)
 				functionCall := []byte(`{"functionCall":{"name":"","args":{}}}`)
-				functionCall, _ = sjson.SetBytes(functionCall, "functionCall.name", name)
-				functionCall, _ = sjson.SetBytes(functionCall, "thoughtSignature", geminiResponsesThoughtSignature)
-				functionCall, _ = sjson.SetBytes(functionCall, "functionCall.id", item.Get("call_id").String())
+				functionCall, _ = util.SafeSetJSON(functionCall, "functionCall.name", name)
+				functionCall, _ = util.SafeSetJSON(functionCall, "thoughtSignature", geminiResponsesThoughtSignature)
+				functionCall, _ = util.SafeSetJSON(functionCall, "functionCall.id", item.Get("call_id").String())
 
 				// Parse arguments JSON string and set as args object
 				if arguments != "" {
 					argsResult := gjson.Parse(arguments)
-					functionCall, _ = sjson.SetRawBytes(functionCall, "functionCall.args", []byte(argsResult.Raw))
+					functionCall, _ = util.SafeSetRawJSON(functionCall, "functionCall.args", []byte(argsResult.Raw))
 				}
 
-				modelContent, _ = sjson.SetRawBytes(modelContent, "parts.-1", functionCall)
-				out, _ = sjson.SetRawBytes(out, "contents.-1", modelContent)
+				modelContent, _ = util.SafeSetRawJSON(modelContent, "parts.-1", functionCall)
+				out, _ = util.SafeSetRawJSON(out, "contents.-1", modelContent)
 
 			case "function_call_output":
 				// Handle function call outputs - convert to function message with functionResponse
@@ -336,36 +335,36 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 				}
 				functionName = util.SanitizeFunctionName(functionName)
 
-				functionResponse, _ = sjson.SetBytes(functionResponse, "functionResponse.name", functionName)
-				functionResponse, _ = sjson.SetBytes(functionResponse, "functionResponse.id", callID)
+				functionResponse, _ = util.SafeSetJSON(functionResponse, "functionResponse.name", functionName)
+				functionResponse, _ = util.SafeSetJSON(functionResponse, "functionResponse.id", callID)
 
 				// Set the raw JSON output directly (preserves string encoding)
 				if outputRaw != "" && outputRaw != "null" {
 					output := gjson.Parse(outputRaw)
 					if output.Type == gjson.JSON && json.Valid([]byte(output.Raw)) {
-						functionResponse, _ = sjson.SetRawBytes(functionResponse, "functionResponse.response.result", []byte(output.Raw))
+						functionResponse, _ = util.SafeSetRawJSON(functionResponse, "functionResponse.response.result", []byte(output.Raw))
 					} else {
-						functionResponse, _ = sjson.SetBytes(functionResponse, "functionResponse.response.result", outputRaw)
+						functionResponse, _ = util.SafeSetJSON(functionResponse, "functionResponse.response.result", outputRaw)
 					}
 				}
-				functionContent, _ = sjson.SetRawBytes(functionContent, "parts.-1", functionResponse)
-				out, _ = sjson.SetRawBytes(out, "contents.-1", functionContent)
+				functionContent, _ = util.SafeSetRawJSON(functionContent, "parts.-1", functionResponse)
+				out, _ = util.SafeSetRawJSON(out, "contents.-1", functionContent)
 
 			case "reasoning":
 				thoughtContent := []byte(`{"role":"model","parts":[]}`)
 				thought := []byte(`{"text":"","thoughtSignature":"","thought":true}`)
-				thought, _ = sjson.SetBytes(thought, "text", item.Get("summary.0.text").String())
-				thought, _ = sjson.SetBytes(thought, "thoughtSignature", item.Get("encrypted_content").String())
+				thought, _ = util.SafeSetJSON(thought, "text", item.Get("summary.0.text").String())
+				thought, _ = util.SafeSetJSON(thought, "thoughtSignature", item.Get("encrypted_content").String())
 
-				thoughtContent, _ = sjson.SetRawBytes(thoughtContent, "parts.-1", thought)
-				out, _ = sjson.SetRawBytes(out, "contents.-1", thoughtContent)
+				thoughtContent, _ = util.SafeSetRawJSON(thoughtContent, "parts.-1", thought)
+				out, _ = util.SafeSetRawJSON(out, "contents.-1", thoughtContent)
 			}
 		}
 	} else if input.Exists() && input.Type == gjson.String {
 		// Simple string input conversion to user message
 		userContent := []byte(`{"role":"user","parts":[{"text":""}]}`)
-		userContent, _ = sjson.SetBytes(userContent, "parts.0.text", input.String())
-		out, _ = sjson.SetRawBytes(out, "contents.-1", userContent)
+		userContent, _ = util.SafeSetJSON(userContent, "parts.0.text", input.String())
+		out, _ = util.SafeSetRawJSON(out, "contents.-1", userContent)
 	}
 
 	// Convert tools to Gemini functionDeclarations format
@@ -377,60 +376,60 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 				funcDecl := []byte(`{"name":"","description":"","parametersJsonSchema":{}}`)
 
 				if name := tool.Get("name"); name.Exists() {
-					funcDecl, _ = sjson.SetBytes(funcDecl, "name", util.SanitizeFunctionName(name.String()))
+					funcDecl, _ = util.SafeSetJSON(funcDecl, "name", util.SanitizeFunctionName(name.String()))
 				}
 				if desc := tool.Get("description"); desc.Exists() {
-					funcDecl, _ = sjson.SetBytes(funcDecl, "description", desc.String())
+					funcDecl, _ = util.SafeSetJSON(funcDecl, "description", desc.String())
 				}
 				if params := tool.Get("parameters"); params.Exists() {
-					funcDecl, _ = sjson.SetRawBytes(funcDecl, "parametersJsonSchema", []byte(params.Raw))
+					funcDecl, _ = util.SafeSetRawJSON(funcDecl, "parametersJsonSchema", []byte(params.Raw))
 				}
 
-				geminiTools, _ = sjson.SetRawBytes(geminiTools, "0.functionDeclarations.-1", funcDecl)
+				geminiTools, _ = util.SafeSetRawJSON(geminiTools, "0.functionDeclarations.-1", funcDecl)
 			}
 			return true
 		})
 
 		// Only add tools if there are function declarations
 		if funcDecls := gjson.GetBytes(geminiTools, "0.functionDeclarations"); funcDecls.Exists() && len(funcDecls.Array()) > 0 {
-			out, _ = sjson.SetRawBytes(out, "tools", geminiTools)
+			out, _ = util.SafeSetRawJSON(out, "tools", geminiTools)
 		}
 	}
 
 	// Handle generation config from OpenAI format
 	if maxOutputTokens := root.Get("max_output_tokens"); maxOutputTokens.Exists() {
 		genConfig := []byte(`{"maxOutputTokens":0}`)
-		genConfig, _ = sjson.SetBytes(genConfig, "maxOutputTokens", maxOutputTokens.Int())
-		out, _ = sjson.SetRawBytes(out, "generationConfig", genConfig)
+		genConfig, _ = util.SafeSetJSON(genConfig, "maxOutputTokens", maxOutputTokens.Int())
+		out, _ = util.SafeSetRawJSON(out, "generationConfig", genConfig)
 	}
 
 	// Handle temperature if present
 	if temperature := root.Get("temperature"); temperature.Exists() {
 		if !gjson.GetBytes(out, "generationConfig").Exists() {
-			out, _ = sjson.SetRawBytes(out, "generationConfig", []byte(`{}`))
+			out, _ = util.SafeSetRawJSON(out, "generationConfig", []byte(`{}`))
 		}
-		out, _ = sjson.SetBytes(out, "generationConfig.temperature", temperature.Float())
+		out, _ = util.SafeSetJSON(out, "generationConfig.temperature", temperature.Float())
 	}
 
 	// Handle top_p if present
 	if topP := root.Get("top_p"); topP.Exists() {
 		if !gjson.GetBytes(out, "generationConfig").Exists() {
-			out, _ = sjson.SetRawBytes(out, "generationConfig", []byte(`{}`))
+			out, _ = util.SafeSetRawJSON(out, "generationConfig", []byte(`{}`))
 		}
-		out, _ = sjson.SetBytes(out, "generationConfig.topP", topP.Float())
+		out, _ = util.SafeSetJSON(out, "generationConfig.topP", topP.Float())
 	}
 
 	// Handle stop sequences
 	if stopSequences := root.Get("stop_sequences"); stopSequences.Exists() && stopSequences.IsArray() {
 		if !gjson.GetBytes(out, "generationConfig").Exists() {
-			out, _ = sjson.SetRawBytes(out, "generationConfig", []byte(`{}`))
+			out, _ = util.SafeSetRawJSON(out, "generationConfig", []byte(`{}`))
 		}
 		var sequences []string
 		stopSequences.ForEach(func(_, seq gjson.Result) bool {
 			sequences = append(sequences, seq.String())
 			return true
 		})
-		out, _ = sjson.SetBytes(out, "generationConfig.stopSequences", sequences)
+		out, _ = util.SafeSetJSON(out, "generationConfig.stopSequences", sequences)
 	}
 
 	// Apply thinking configuration: convert OpenAI Responses API reasoning.effort to Gemini thinkingConfig.
@@ -441,11 +440,11 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 		if effort != "" {
 			thinkingPath := "generationConfig.thinkingConfig"
 			if effort == "auto" {
-				out, _ = sjson.SetBytes(out, thinkingPath+".thinkingBudget", -1)
-				out, _ = sjson.SetBytes(out, thinkingPath+".includeThoughts", true)
+				out, _ = util.SafeSetJSON(out, thinkingPath+".thinkingBudget", -1)
+				out, _ = util.SafeSetJSON(out, thinkingPath+".includeThoughts", true)
 			} else {
-				out, _ = sjson.SetBytes(out, thinkingPath+".thinkingLevel", effort)
-				out, _ = sjson.SetBytes(out, thinkingPath+".includeThoughts", effort != "none")
+				out, _ = util.SafeSetJSON(out, thinkingPath+".thinkingLevel", effort)
+				out, _ = util.SafeSetJSON(out, thinkingPath+".includeThoughts", effort != "none")
 			}
 		}
 	}
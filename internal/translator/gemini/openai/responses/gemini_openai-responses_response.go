@@ -11,7 +11,6 @@ import (
 	translatorcommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/common"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 type geminiToResponsesState struct {
@@ -143,25 +142,25 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 		}
 		full := st.ReasoningBuf.String()
 		textDone := []byte(`{"type":"response.reasoning_summary_text.done","sequence_number":0,"item_id":"","output_index":0,"summary_index":0,"text":""}`)
-		textDone, _ = sjson.SetBytes(textDone, "sequence_number", nextSeq())
-		textDone, _ = sjson.SetBytes(textDone, "item_id", st.ReasoningItemID)
-		textDone, _ = sjson.SetBytes(textDone, "output_index", st.ReasoningIndex)
-		textDone, _ = sjson.SetBytes(textDone, "text", full)
+		textDone, _ = util.SafeSetJSON(textDone, "sequence_number", nextSeq())
+		textDone, _ = util.SafeSetJSON(textDone, "item_id", st.ReasoningItemID)
+		textDone, _ = util.SafeSetJSON(textDone, "output_index", st.ReasoningIndex)
+		textDone, _ = util.SafeSetJSON(textDone, "text", full)
 		out = append(out, emitEvent("response.reasoning_summary_text.done", textDone))
 
 		partDone := []byte(`{"type":"response.reasoning_summary_part.done","sequence_number":0,"item_id":"","output_index":0,"summary_index":0,"part":{"type":"summary_text","text":""}}`)
-		partDone, _ = sjson.SetBytes(partDone, "sequence_number", nextSeq())
-		partDone, _ = sjson.SetBytes(partDone, "item_id", st.ReasoningItemID)
-		partDone, _ = sjson.SetBytes(partDone, "output_index", st.ReasoningIndex)
-		partDone, _ = sjson.SetBytes(partDone, "part.text", full)
+		partDone, _ = util.SafeSetJSON(partDone, "sequence_number", nextSeq())
+		partDone, _ = util.SafeSetJSON(partDone, "item_id", st.ReasoningItemID)
+		partDone, _ = util.SafeSetJSON(partDone, "output_index", st.ReasoningIndex)
+		partDone, _ = util.SafeSetJSON(partDone, "part.text", full)
 		out = append(out, emitEvent("response.reasoning_summary_part.done", partDone))
 
 		itemDone := []byte(`{"type":"response.output_item.done","sequence_number":0,"output_index":0,"item":{"id":"","type":"reasoning","encrypted_content":"","summary":[{"type":"summary_text","text":""}]}}`)
-		itemDone, _ = sjson.SetBytes(itemDone, "sequence_number", nextSeq())
-		itemDone, _ = sjson.SetBytes(itemDone, "item.id", st.ReasoningItemID)
-		itemDone, _ = sjson.SetBytes(itemDone, "output_index", st.ReasoningIndex)
-		itemDone, _ = sjson.SetBytes(itemDone, "item.encrypted_content", st.ReasoningEnc)
-		itemDone, _ = sjson.SetBytes(itemDone, "item.summary.0.text", full)
+		itemDone, _ = util.SafeSetJSON(itemDone, "sequence_number", nextSeq())
+		itemDone, _ = util.SafeSetJSON(itemDone, "item.id", st.ReasoningItemID)
+		itemDone, _ = util.SafeSetJSON(itemDone, "output_index", st.ReasoningIndex)
+		itemDone, _ = util.SafeSetJSON(itemDone, "item.encrypted_content", st.ReasoningEnc)
+		itemDone, _ = util.SafeSetJSON(itemDone, "item.summary.0.text", full)
 		out = append(out, emitEvent("response.output_item.done", itemDone))
 
 		st.ReasoningClosed = true
@@ -176,22 +175,22 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 		}
 		fullText := st.ItemTextBuf.String()
 		done := []byte(`{"type":"response.output_text.done","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"text":"","logprobs":[]}`)
-		done, _ = sjson.SetBytes(done, "sequence_number", nextSeq())
-		done, _ = sjson.SetBytes(done, "item_id", st.CurrentMsgID)
-		done, _ = sjson.SetBytes(done, "output_index", st.MsgIndex)
-		done, _ = sjson.SetBytes(done, "text", fullText)
+		done, _ = util.SafeSetJSON(done, "sequence_number", nextSeq())
+		done, _ = util.SafeSetJSON(done, "item_id", st.CurrentMsgID)
+		done, _ = util.SafeSetJSON(done, "output_index", st.MsgIndex)
+		done, _ = util.SafeSetJSON(done, "text", fullText)
 		out = append(out, emitEvent("response.output_text.done", done))
 		partDone := []byte(`{"type":"response.content_part.done","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"part":{"type":"output_text","annotations":[],"logprobs":[],"text":""}}`)
-		partDone, _ = sjson.SetBytes(partDone, "sequence_number", nextSeq())
-		partDone, _ = sjson.SetBytes(partDone, "item_id", st.CurrentMsgID)
-		partDone, _ = sjson.SetBytes(partDone, "output_index", st.MsgIndex)
-		partDone, _ = sjson.SetBytes(partDone, "part.text", fullText)
+		partDone, _ = util.SafeSetJSON(partDone, "sequence_number", nextSeq())
+		partDone, _ = util.SafeSetJSON(partDone, "item_id", st.CurrentMsgID)
+		partDone, _ = util.SafeSetJSON(partDone, "output_index", st.MsgIndex)
+		partDone, _ = util.SafeSetJSON(partDone, "part.text", fullText)
 		out = append(out, emitEvent("response.content_part.done", partDone))
 		final := []byte(`{"type":"response.output_item.done","sequence_number":0,"output_index":0,"item":{"id":"","type":"message","status":"completed","content":[{"type":"output_text","text":""}],"role":"assistant"}}`)
-		final, _ = sjson.SetBytes(final, "sequence_number", nextSeq())
-		final, _ = sjson.SetBytes(final, "output_index", st.MsgIndex)
-		final, _ = sjson.SetBytes(final, "item.id", st.CurrentMsgID)
-		final, _ = sjson.SetBytes(final, "item.content.0.text", fullText)
+		final, _ = util.SafeSetJSON(final, "sequence_number", nextSeq())
+		final, _ = util.SafeSetJSON(final, "output_index", st.MsgIndex)
+		final, _ = util.SafeSetJSON(final, "item.id", st.CurrentMsgID)
+		final, _ = util.SafeSetJSON(final, "item.content.0.text", fullText)
 		out = append(out, emitEvent("response.output_item.done", final))
 
 		st.MsgClosed = true
@@ -216,15 +215,15 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 		}
 
 		created := []byte(`{"type":"response.created","sequence_number":0,"response":{"id":"","object":"response","created_at":0,"status":"in_progress","background":false,"error":null,"output":[]}}`)
-		created, _ = sjson.SetBytes(created, "sequence_number", nextSeq())
-		created, _ = sjson.SetBytes(created, "response.id", st.ResponseID)
-		created, _ = sjson.SetBytes(created, "response.created_at", st.CreatedAt)
+		created, _ = util.SafeSetJSON(created, "sequence_number", nextSeq())
+		created, _ = util.SafeSetJSON(created, "response.id", st.ResponseID)
+		created, _ = util.SafeSetJSON(created, "response.created_at", st.CreatedAt)
 		out = append(out, emitEvent("response.created", created))
 
 		inprog := []byte(`{"type":"response.in_progress","sequence_number":0,"response":{"id":"","object":"response","created_at":0,"status":"in_progress"}}`)
-		inprog, _ = sjson.SetBytes(inprog, "sequence_number", nextSeq())
-		inprog, _ = sjson.SetBytes(inprog, "response.id", st.ResponseID)
-		inprog, _ = sjson.SetBytes(inprog, "response.created_at", st.CreatedAt)
+		inprog, _ = util.SafeSetJSON(inprog, "sequence_number", nextSeq())
+		inprog, _ = util.SafeSetJSON(inprog, "response.id", st.ResponseID)
+		inprog, _ = util.SafeSetJSON(inprog, "response.created_at", st.CreatedAt)
 		out = append(out, emitEvent("response.in_progress", inprog))
 
 		st.Started = true
@@ -251,24 +250,24 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 					st.NextIndex++
 					st.ReasoningItemID = fmt.Sprintf("rs_%s_%d", st.ResponseID, st.ReasoningIndex)
 					item := []byte(`{"type":"response.output_item.added","sequence_number":0,"output_index":0,"item":{"id":"","type":"reasoning","status":"in_progress","encrypted_content":"","summary":[]}}`)
-					item, _ = sjson.SetBytes(item, "sequence_number", nextSeq())
-					item, _ = sjson.SetBytes(item, "output_index", st.ReasoningIndex)
-					item, _ = sjson.SetBytes(item, "item.id", st.ReasoningItemID)
-					item, _ = sjson.SetBytes(item, "item.encrypted_content", st.ReasoningEnc)
+					item, _ = util.SafeSetJSON(item, "sequence_number", nextSeq())
+					item, _ = util.SafeSetJSON(item, "output_index", st.ReasoningIndex)
+					item, _ = util.SafeSetJSON(item, "item.id", st.ReasoningItemID)
+					item, _ = util.SafeSetJSON(item, "item.encrypted_content", st.ReasoningEnc)
 					out = append(out, emitEvent("response.output_item.added", item))
 					partAdded := []byte(`{"type":"response.reasoning_summary_part.added","sequence_number":0,"item_id":"","output_index":0,"summary_index":0,"part":{"type":"summary_text","text":""}}`)
-					partAdded, _ = sjson.SetBytes(partAdded, "sequence_number", nextSeq())
-					partAdded, _ = sjson.SetBytes(partAdded, "item_id", st.ReasoningItemID)
-					partAdded, _ = sjson.SetBytes(partAdded, "output_index", st.ReasoningIndex)
+					partAdded, _ = util.SafeSetJSON(partAdded, "sequence_number", nextSeq())
+					partAdded, _ = util.SafeSetJSON(partAdded, "item_id", st.ReasoningItemID)
+					partAdded, _ = util.SafeSetJSON(partAdded, "output_index", st.ReasoningIndex)
 					out = append(out, emitEvent("response.reasoning_summary_part.added", partAdded))
 				}
 				if t := part.Get("text"); t.Exists() && t.String() != "" {
 					st.ReasoningBuf.WriteString(t.String())
 					msg := []byte(`{"type":"response.reasoning_summary_text.delta","sequence_number":0,"item_id":"","output_index":0,"summary_index":0,"delta":""}`)
-					msg, _ = sjson.SetBytes(msg, "sequence_number", nextSeq())
-					msg, _ = sjson.SetBytes(msg, "item_id", st.ReasoningItemID)
-					msg, _ = sjson.SetBytes(msg, "output_index", st.ReasoningIndex)
-					msg, _ = sjson.SetBytes(msg, "delta", t.String())
+					msg, _ = util.SafeSetJSON(msg, "sequence_number", nextSeq())
+					msg, _ = util.SafeSetJSON(msg, "item_id", st.ReasoningItemID)
+					msg, _ = util.SafeSetJSON(msg, "output_index", st.ReasoningIndex)
+					msg, _ = util.SafeSetJSON(msg, "delta", t.String())
 					out = append(out, emitEvent("response.reasoning_summary_text.delta", msg))
 				}
 				return true
@@ -284,24 +283,24 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 					st.NextIndex++
 					st.CurrentMsgID = fmt.Sprintf("msg_%s_0", st.ResponseID)
 					item := []byte(`{"type":"response.output_item.added","sequence_number":0,"output_index":0,"item":{"id":"","type":"message","status":"in_progress","content":[],"role":"assistant"}}`)
-					item, _ = sjson.SetBytes(item, "sequence_number", nextSeq())
-					item, _ = sjson.SetBytes(item, "output_index", st.MsgIndex)
-					item, _ = sjson.SetBytes(item, "item.id", st.CurrentMsgID)
+					item, _ = util.SafeSetJSON(item, "sequence_number", nextSeq())
+					item, _ = util.SafeSetJSON(item, "output_index", st.MsgIndex)
+					item, _ = util.SafeSetJSON(item, "item.id", st.CurrentMsgID)
 					out = append(out, emitEvent("response.output_item.added", item))
 					partAdded := []byte(`{"type":"response.content_part.added","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"part":{"type":"output_text","annotations":[],"logprobs":[],"text":""}}`)
-					partAdded, _ = sjson.SetBytes(partAdded, "sequence_number", nextSeq())
-					partAdded, _ = sjson.SetBytes(partAdded, "item_id", st.CurrentMsgID)
-					partAdded, _ = sjson.SetBytes(partAdded, "output_index", st.MsgIndex)
+					partAdded, _ = util.SafeSetJSON(partAdded, "sequence_number", nextSeq())
+					partAdded, _ = util.SafeSetJSON(partAdded, "item_id", st.CurrentMsgID)
+					partAdded, _ = util.SafeSetJSON(partAdded, "output_index", st.MsgIndex)
 					out = append(out, emitEvent("response.content_part.added", partAdded))
 					st.ItemTextBuf.Reset()
 				}
 				st.TextBuf.WriteString(t.String())
 				st.ItemTextBuf.WriteString(t.String())
 				msg := []byte(`{"type":"response.output_text.delta","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"delta":"","logprobs":[]}`)
-				msg, _ = sjson.SetBytes(msg, "sequence_number", nextSeq())
-				msg, _ = sjson.SetBytes(msg, "item_id", st.CurrentMsgID)
-				msg, _ = sjson.SetBytes(msg, "output_index", st.MsgIndex)
-				msg, _ = sjson.SetBytes(msg, "delta", t.String())
+				msg, _ = util.SafeSetJSON(msg, "sequence_number", nextSeq())
+				msg, _ = util.SafeSetJSON(msg, "item_id", st.CurrentMsgID)
+				msg, _ = util.SafeSetJSON(msg, "output_index", st.MsgIndex)
+				msg, _ = util.SafeSetJSON(msg, "delta", t.String())
 				out = append(out, emitEvent("response.output_text.delta", msg))
 				return true
 			}
@@ -334,40 +333,40 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 
 				// Emit item.added for function call
 				item := []byte(`{"type":"response.output_item.added","sequence_number":0,"output_index":0,"item":{"id":"","type":"function_call","status":"in_progress","arguments":"","call_id":"","name":""}}`)
-				item, _ = sjson.SetBytes(item, "sequence_number", nextSeq())
-				item, _ = sjson.SetBytes(item, "output_index", idx)
-				item, _ = sjson.SetBytes(item, "item.id", fmt.Sprintf("fc_%s", st.FuncCallIDs[idx]))
-				item, _ = sjson.SetBytes(item, "item.call_id", st.FuncCallIDs[idx])
-				item, _ = sjson.SetBytes(item, "item.name", name)
+				item, _ = util.SafeSetJSON(item, "sequence_number", nextSeq())
+				item, _ = util.SafeSetJSON(item, "output_index", idx)
+				item, _ = util.SafeSetJSON(item, "item.id", fmt.Sprintf("fc_%s", st.FuncCallIDs[idx]))
+				item, _ = util.SafeSetJSON(item, "item.call_id", st.FuncCallIDs[idx])
+				item, _ = util.SafeSetJSON(item, "item.name", name)
 				out = append(out, emitEvent("response.output_item.added", item))
 
 				// Emit arguments delta (full args in one chunk).
 				// When Gemini omits args, emit "{}" to keep Responses streaming event order consistent.
 				if argsJSON != "" {
 					ad := []byte(`{"type":"response.function_call_arguments.delta","sequence_number":0,"item_id":"","output_index":0,"delta":""}`)
-					ad, _ = sjson.SetBytes(ad, "sequence_number", nextSeq())
-					ad, _ = sjson.SetBytes(ad, "item_id", fmt.Sprintf("fc_%s", st.FuncCallIDs[idx]))
-					ad, _ = sjson.SetBytes(ad, "output_index", idx)
-					ad, _ = sjson.SetBytes(ad, "delta", argsJSON)
+					ad, _ = util.SafeSetJSON(ad, "sequence_number", nextSeq())
+					ad, _ = util.SafeSetJSON(ad, "item_id", fmt.Sprintf("fc_%s", st.FuncCallIDs[idx]))
+					ad, _ = util.SafeSetJSON(ad, "output_index", idx)
+					ad, _ = util.SafeSetJSON(ad, "delta", argsJSON)
 					out = append(out, emitEvent("response.function_call_arguments.delta", ad))
 				}
 
 				// Gemini emits the full function call payload at once, so we can finalize it immediately.
 				if !st.FuncDone[idx] {
 					fcDone := []byte(`{"type":"response.function_call_arguments.done","sequence_number":0,"item_id":"","output_index":0,"arguments":""}`)
-					fcDone, _ = sjson.SetBytes(fcDone, "sequence_number", nextSeq())
-					fcDone, _ = sjson.SetBytes(fcDone, "item_id", fmt.Sprintf("fc_%s", st.FuncCallIDs[idx]))
-					fcDone, _ = sjson.SetBytes(fcDone, "output_index", idx)
-					fcDone, _ = sjson.SetBytes(fcDone, "arguments", argsJSON)
+					fcDone, _ = util.SafeSetJSON(fcDone, "sequence_number", nextSeq())
+					fcDone, _ = util.SafeSetJSON(fcDone, "item_id", fmt.Sprintf("fc_%s", st.FuncCallIDs[idx]))
+					fcDone, _ = util.SafeSetJSON(fcDone, "output_index", idx)
+					fcDone, _ = util.SafeSetJSON(fcDone, "arguments", argsJSON)
 					out = append(out, emitEvent("response.function_call_arguments.done", fcDone))
 
 					itemDone := []byte(`{"type":"response.output_item.done","sequence_number":0,"output_index":0,"item":{"id":"","type":"function_call","status":"completed","arguments":"","call_id":"","name":""}}`)
-					itemDone, _ = sjson.SetBytes(itemDone, "sequence_number", nextSeq())
-					itemDone, _ = sjson.SetBytes(itemDone, "output_index", idx)
-					itemDone, _ = sjson.SetBytes(itemDone, "item.id", fmt.Sprintf("fc_%s", st.FuncCallIDs[idx]))
-					itemDone, _ = sjson.SetBytes(itemDone, "item.arguments", argsJSON)
-					itemDone, _ = sjson.SetBytes(itemDone, "item.call_id", st.FuncCallIDs[idx])
-					itemDone, _ = sjson.SetBytes(itemDone, "item.name", st.FuncNames[idx])
+					itemDone, _ = util.SafeSetJSON(itemDone, "sequence_number", nextSeq())
+					itemDone, _ = util.SafeSetJSON(itemDone, "output_index", idx)
+					itemDone, _ = util.SafeSetJSON(itemDone, "item.id", fmt.Sprintf("fc_%s", st.FuncCallIDs[idx]))
+					itemDone, _ = util.SafeSetJSON(itemDone, "item.arguments", argsJSON)
+					itemDone, _ = util.SafeSetJSON(itemDone, "item.call_id", st.FuncCallIDs[idx])
+					itemDone, _ = util.SafeSetJSON(itemDone, "item.name", st.FuncNames[idx])
 					out = append(out, emitEvent("response.output_item.done", itemDone))
 
 					st.FuncDone[idx] = true
@@ -409,19 +408,19 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 					args = b.String()
 				}
 				fcDone := []byte(`{"type":"response.function_call_arguments.done","sequence_number":0,"item_id":"","output_index":0,"arguments":""}`)
-				fcDone, _ = sjson.SetBytes(fcDone, "sequence_number", nextSeq())
-				fcDone, _ = sjson.SetBytes(fcDone, "item_id", fmt.Sprintf("fc_%s", st.FuncCallIDs[idx]))
-				fcDone, _ = sjson.SetBytes(fcDone, "output_index", idx)
-				fcDone, _ = sjson.SetBytes(fcDone, "arguments", args)
+				fcDone, _ = util.SafeSetJSON(fcDone, "sequence_number", nextSeq())
+				fcDone, _ = util.SafeSetJSON(fcDone, "item_id", fmt.Sprintf("fc_%s", st.FuncCallIDs[idx]))
+				fcDone, _ = util.SafeSetJSON(fcDone, "output_index", idx)
+				fcDone, _ = util.SafeSetJSON(fcDone, "arguments", args)
 				out = append(out, emitEvent("response.function_call_arguments.done", fcDone))
 
 				itemDone := []byte(`{"type":"response.output_item.done","sequence_number":0,"output_index":0,"item":{"id":"","type":"function_call","status":"completed","arguments":"","call_id":"","name":""}}`)
-				itemDone, _ = sjson.SetBytes(itemDone, "sequence_number", nextSeq())
-				itemDone, _ = sjson.SetBytes(itemDone, "output_index", idx)
-				itemDone, _ = sjson.SetBytes(itemDone, "item.id", fmt.Sprintf("fc_%s", st.FuncCallIDs[idx]))
-				itemDone, _ = sjson.SetBytes(itemDone, "item.arguments", args)
-				itemDone, _ = sjson.SetBytes(itemDone, "item.call_id", st.FuncCallIDs[idx])
-				itemDone, _ = sjson.SetBytes(itemDone, "item.name", st.FuncNames[idx])
+				itemDone, _ = util.SafeSetJSON(itemDone, "sequence_number", nextSeq())
+				itemDone, _ = util.SafeSetJSON(itemDone, "output_index", idx)
+				itemDone, _ = util.SafeSetJSON(itemDone, "item.id", fmt.Sprintf("fc_%s", st.FuncCallIDs[idx]))
+				itemDone, _ = util.SafeSetJSON(itemDone, "item.arguments", args)
+				itemDone, _ = util.SafeSetJSON(itemDone, "item.call_id", st.FuncCallIDs[idx])
+				itemDone, _ = util.SafeSetJSON(itemDone, "item.name", st.FuncNames[idx])
 				out = append(out, emitEvent("response.output_item.done", itemDone))
 
 				st.FuncDone[idx] = true
@@ -432,71 +431,71 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 
 		// Build response.completed with aggregated outputs and request echo fields
 		completed := []byte(`{"type":"response.completed","sequence_number":0,"response":{"id":"","object":"response","created_at":0,"status":"completed","background":false,"error":null}}`)
-		completed, _ = sjson.SetBytes(completed, "sequence_number", nextSeq())
-		completed, _ = sjson.SetBytes(completed, "response.id", st.ResponseID)
-		completed, _ = sjson.SetBytes(completed, "response.created_at", st.CreatedAt)
+		completed, _ = util.SafeSetJSON(completed, "sequence_number", nextSeq())
+		completed, _ = util.SafeSetJSON(completed, "response.id", st.ResponseID)
+		completed, _ = util.SafeSetJSON(completed, "response.created_at", st.CreatedAt)
 
 		if reqJSON := pickRequestJSON(originalRequestRawJSON, requestRawJSON); len(reqJSON) > 0 {
 			req := unwrapRequestRoot(gjson.ParseBytes(reqJSON))
 			if v := req.Get("instructions"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.instructions", v.String())
+				completed, _ = util.SafeSetJSON(completed, "response.instructions", v.String())
 			}
 			if v := req.Get("max_output_tokens"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.max_output_tokens", v.Int())
+				completed, _ = util.SafeSetJSON(completed, "response.max_output_tokens", v.Int())
 			}
 			if v := req.Get("max_tool_calls"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.max_tool_calls", v.Int())
+				completed, _ = util.SafeSetJSON(completed, "response.max_tool_calls", v.Int())
 			}
 			if v := req.Get("model"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.model", v.String())
+				completed, _ = util.SafeSetJSON(completed, "response.model", v.String())
 			}
 			if v := req.Get("parallel_tool_calls"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.parallel_tool_calls", v.Bool())
+				completed, _ = util.SafeSetJSON(completed, "response.parallel_tool_calls", v.Bool())
 			}
 			if v := req.Get("previous_response_id"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.previous_response_id", v.String())
+				completed, _ = util.SafeSetJSON(completed, "response.previous_response_id", v.String())
 			}
 			if v := req.Get("prompt_cache_key"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.prompt_cache_key", v.String())
+				completed, _ = util.SafeSetJSON(completed, "response.prompt_cache_key", v.String())
 			}
 			if v := req.Get("reasoning"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.reasoning", v.Value())
+				completed, _ = util.SafeSetJSON(completed, "response.reasoning", v.Value())
 			}
 			if v := req.Get("safety_identifier"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.safety_identifier", v.String())
+				completed, _ = util.SafeSetJSON(completed, "response.safety_identifier", v.String())
 			}
 			if v := req.Get("service_tier"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.service_tier", v.String())
+				completed, _ = util.SafeSetJSON(completed, "response.service_tier", v.String())
 			}
 			if v := req.Get("store"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.store", v.Bool())
+				completed, _ = util.SafeSetJSON(completed, "response.store", v.Bool())
 			}
 			if v := req.Get("temperature"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.temperature", v.Float())
+				completed, _ = util.SafeSetJSON(completed, "response.temperature", v.Float())
 			}
 			if v := req.Get("text"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.text", v.Value())
+				completed, _ = util.SafeSetJSON(completed, "response.text", v.Value())
 			}
 			if v := req.Get("tool_choice"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.tool_choice", v.Value())
+				completed, _ = util.SafeSetJSON(completed, "response.tool_choice", v.Value())
 			}
 			if v := req.Get("tools"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.tools", v.Value())
+				completed, _ = util.SafeSetJSON(completed, "response.tools", v.Value())
 			}
 			if v := req.Get("top_logprobs"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.top_logprobs", v.Int())
+				completed, _ = util.SafeSetJSON(completed, "response.top_logprobs", v.Int())
 			}
 			if v := req.Get("top_p"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.top_p", v.Float())
+				completed, _ = util.SafeSetJSON(completed, "response.top_p", v.Float())
 			}
 			if v := req.Get("truncation"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.truncation", v.String())
+				completed, _ = util.SafeSetJSON(completed, "response.truncation", v.String())
 			}
 			if v := req.Get("user"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.user", v.Value())
+				completed, _ = util.SafeSetJSON(completed, "response.user", v.Value())
 			}
 			if v := req.Get("metadata"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.metadata", v.Value())
+				completed, _ = util.SafeSetJSON(completed, "response.metadata", v.Value())
 			}
 		}
 
@@ -505,17 +504,17 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 		for idx := 0; idx < st.NextIndex; idx++ {
 			if st.ReasoningOpened && idx == st.ReasoningIndex {
 				item := []byte(`{"id":"","type":"reasoning","encrypted_content":"","summary":[{"type":"summary_text","text":""}]}`)
-				item, _ = sjson.SetBytes(item, "id", st.ReasoningItemID)
-				item, _ = sjson.SetBytes(item, "encrypted_content", st.ReasoningEnc)
-				item, _ = sjson.SetBytes(item, "summary.0.text", st.ReasoningBuf.String())
-				outputsWrapper, _ = sjson.SetRawBytes(outputsWrapper, "arr.-1", item)
+				item, _ = util.SafeSetJSON(item, "id", st.ReasoningItemID)
+				item, _ = util.SafeSetJSON(item, "encrypted_content", st.ReasoningEnc)
+				item, _ = util.SafeSetJSON(item, "summary.0.text", st.ReasoningBuf.String())
+				outputsWrapper, _ = util.SafeSetRawJSON(outputsWrapper, "arr.-1", item)
 				continue
 			}
 			if st.MsgOpened && idx == st.MsgIndex {
 				item := []byte(`{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}`)
-				item, _ = sjson.SetBytes(item, "id", st.CurrentMsgID)
-				item, _ = sjson.SetBytes(item, "content.0.text", st.TextBuf.String())
-				outputsWrapper, _ = sjson.SetRawBytes(outputsWrapper, "arr.-1", item)
+				item, _ = util.SafeSetJSON(item, "id", st.CurrentMsgID)
+				item, _ = util.SafeSetJSON(item, "content.0.text", st.TextBuf.String())
+				outputsWrapper, _ = util.SafeSetRawJSON(outputsWrapper, "arr.-1", item)
 				continue
 			}
 
@@ -525,39 +524,39 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 					args = b.String()
 				}
 				item := []byte(`{"id":"","type":"function_call","status":"completed","arguments":"","call_id":"","name":""}`)
-				item, _ = sjson.SetBytes(item, "id", fmt.Sprintf("fc_%s", callID))
-				item, _ = sjson.SetBytes(item, "arguments", args)
-				item, _ = sjson.SetBytes(item, "call_id", callID)
-				item, _ = sjson.SetBytes(item, "name", st.FuncNames[idx])
-				outputsWrapper, _ = sjson.SetRawBytes(outputsWrapper, "arr.-1", item)
+				item, _ = util.SafeSetJSON(item, "id", fmt.Sprintf("fc_%s", callID))
+				item, _ = util.SafeSetJSON(item, "arguments", args)
+				item, _ = util.SafeSetJSON(item, "call_id", callID)
+				item, _ = util.SafeSetJSON(item, "name", st.FuncNames[idx])
+				outputsWrapper, _ = util.SafeSetRawJSON(outputsWrapper, "arr.-1", item)
 			}
 		}
 		if gjson.GetBytes(outputsWrapper, "arr.#").Int() > 0 {
-			completed, _ = sjson.SetRawBytes(completed, "response.output", []byte(gjson.GetBytes(outputsWrapper, "arr").Raw))
+			completed, _ = util.SafeSetRawJSON(completed, "response.output", []byte(gjson.GetBytes(outputsWrapper, "arr").Raw))
 		}
 
 		// usage mapping
 		if um := root.Get("usageMetadata"); um.Exists() {
 			// input tokens = prompt only (thoughts go to output)
 			input := um.Get("promptTokenCount").Int()
-			completed, _ = sjson.SetBytes(completed, "response.usage.input_tokens", input)
+			completed, _ = util.SafeSetJSON(completed, "response.usage.input_tokens", input)
 			// cached token details: align with OpenAI "cached_tokens" semantics.
-			completed, _ = sjson.SetBytes(completed, "response.usage.input_tokens_details.cached_tokens", um.Get("cachedContentTokenCount").Int())
+			completed, _ = util.SafeSetJSON(completed, "response.usage.input_tokens_details.cached_tokens", um.Get("cachedContentTokenCount").Int())
 			// output tokens
 			if v := um.Get("candidatesTokenCount"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.usage.output_tokens", v.Int())
+				completed, _ = util.SafeSetJSON(completed, "response.usage.output_tokens", v.Int())
 			} else {
-				completed, _ = sjson.SetBytes(completed, "response.usage.output_tokens", 0)
+				completed, _ = util.SafeSetJSON(completed, "response.usage.output_tokens", 0)
 			}
 			if v := um.Get("thoughtsTokenCount"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.usage.output_tokens_details.reasoning_tokens", v.Int())
+				completed, _ = util.SafeSetJSON(completed, "response.usage.output_tokens_details.reasoning_tokens", v.Int())
 			} else {
-				completed, _ = sjson.SetBytes(completed, "response.usage.output_tokens_details.reasoning_tokens", 0)
+				completed, _ = util.SafeSetJSON(completed, "response.usage.output_tokens_details.reasoning_tokens", 0)
 			}
 			if v := um.Get("totalTokenCount"); v.Exists() {
-				completed, _ = sjson.SetBytes(completed, "response.usage.total_tokens", v.Int())
+				completed, _ = util.SafeSetJSON(completed, "response.usage.total_tokens", v.Int())
 			} else {
-				completed, _ = sjson.SetBytes(completed, "response.usage.total_tokens", 0)
+				completed, _ = util.SafeSetJSON(completed, "response.usage.total_tokens", 0)
 			}
 		}
 
@@ -585,7 +584,7 @@ func ConvertGeminiResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 	if !strings.HasPrefix(id, "resp_") {
 		id = fmt.Sprintf("resp_%s", id)
 	}
-	resp, _ = sjson.SetBytes(resp, "id", id)
+	resp, _ = util.SafeSetJSON(resp, "id", id)
 
 	// created_at: map from createTime if available
 	createdAt := time.Now().Unix()
@@ -594,75 +593,75 @@ func ConvertGeminiResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 			createdAt = t.Unix()
 		}
 	}
-	resp, _ = sjson.SetBytes(resp, "created_at", createdAt)
+	resp, _ = util.SafeSetJSON(resp, "created_at", createdAt)
 
 	// Echo request fields when present; fallback model from response modelVersion
 	if reqJSON := pickRequestJSON(originalRequestRawJSON, requestRawJSON); len(reqJSON) > 0 {
 		req := unwrapRequestRoot(gjson.ParseBytes(reqJSON))
 		if v := req.Get("instructions"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "instructions", v.String())
+			resp, _ = util.SafeSetJSON(resp, "instructions", v.String())
 		}
 		if v := req.Get("max_output_tokens"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "max_output_tokens", v.Int())
+			resp, _ = util.SafeSetJSON(resp, "max_output_tokens", v.Int())
 		}
 		if v := req.Get("max_tool_calls"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "max_tool_calls", v.Int())
+			resp, _ = util.SafeSetJSON(resp, "max_tool_calls", v.Int())
 		}
 		if v := req.Get("model"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "model", v.String())
+			resp, _ = util.SafeSetJSON(resp, "model", v.String())
 		} else if v = root.Get("modelVersion"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "model", v.String())
+			resp, _ = util.SafeSetJSON(resp, "model", v.String())
 		}
 		if v := req.Get("parallel_tool_calls"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "parallel_tool_calls", v.Bool())
+			resp, _ = util.SafeSetJSON(resp, "parallel_tool_calls", v.Bool())
 		}
 		if v := req.Get("previous_response_id"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "previous_response_id", v.String())
+			resp, _ = util.SafeSetJSON(resp, "previous_response_id", v.String())
 		}
 		if v := req.Get("prompt_cache_key"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "prompt_cache_key", v.String())
+			resp, _ = util.SafeSetJSON(resp, "prompt_cache_key", v.String())
 		}
 		if v := req.Get("reasoning"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "reasoning", v.Value())
+			resp, _ = util.SafeSetJSON(resp, "reasoning", v.Value())
 		}
 		if v := req.Get("safety_identifier"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "safety_identifier", v.String())
+			resp, _ = util.SafeSetJSON(resp, "safety_identifier", v.String())
 		}
 		if v := req.Get("service_tier"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "service_tier", v.String())
+			resp, _ = util.SafeSetJSON(resp, "service_tier", v.String())
 		}
 		if v := req.Get("store"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "store", v.Bool())
+			resp, _ = util.SafeSetJSON(resp, "store", v.Bool())
 		}
 		if v := req.Get("temperature"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "temperature", v.Float())
+			resp, _ = util.SafeSetJSON(resp, "temperature", v.Float())
 		}
 		if v := req.Get("text"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "text", v.Value())
+			resp, _ = util.SafeSetJSON(resp, "text", v.Value())
 		}
 		if v := req.Get("tool_choice"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "tool_choice", v.Value())
+			resp, _ = util.SafeSetJSON(resp, "tool_choice", v.Value())
 		}
 		if v := req.Get("tools"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "tools", v.Value())
+			resp, _ = util.SafeSetJSON(resp, "tools", v.Value())
 		}
 		if v := req.Get("top_logprobs"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "top_logprobs", v.Int())
+			resp, _ = util.SafeSetJSON(resp, "top_logprobs", v.Int())
 		}
 		if v := req.Get("top_p"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "top_p", v.Float())
+			resp, _ = util.SafeSetJSON(resp, "top_p", v.Float())
 		}
 		if v := req.Get("truncation"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "truncation", v.String())
+			resp, _ = util.SafeSetJSON(resp, "truncation", v.String())
 		}
 		if v := req.Get("user"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "user", v.Value())
+			resp, _ = util.SafeSetJSON(resp, "user", v.Value())
 		}
 		if v := req.Get("metadata"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "metadata", v.Value())
+			resp, _ = util.SafeSetJSON(resp, "metadata", v.Value())
 		}
 	} else if v := root.Get("modelVersion"); v.Exists() {
-		resp, _ = sjson.SetBytes(resp, "model", v.String())
+		resp, _ = util.SafeSetJSON(resp, "model", v.String())
 	}
 
 	// Build outputs from candidates[0].content.parts
@@ -676,12 +675,12 @@ func ConvertGeminiResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 		if haveOutput {
 			return
 		}
-		resp, _ = sjson.SetRawBytes(resp, "output", []byte("[]"))
+		resp, _ = util.SafeSetRawJSON(resp, "output", []byte("[]"))
 		haveOutput = true
 	}
 	appendOutput := func(itemJSON []byte) {
 		ensureOutput()
-		resp, _ = sjson.SetRawBytes(resp, "output.-1", itemJSON)
+		resp, _ = util.SafeSetRawJSON(resp, "output.-1", itemJSON)
 	}
 
 	if parts := root.Get("candidates.0.content.parts"); parts.Exists() && parts.IsArray() {
@@ -705,14 +704,14 @@ func ConvertGeminiResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 				args := fc.Get("args")
 				callID := fmt.Sprintf("call_%x_%d", time.Now().UnixNano(), atomic.AddUint64(&funcCallIDCounter, 1))
 				itemJSON := []byte(`{"id":"","type":"function_call","status":"completed","arguments":"","call_id":"","name":""}`)
-				itemJSON, _ = sjson.SetBytes(itemJSON, "id", fmt.Sprintf("fc_%s", callID))
-				itemJSON, _ = sjson.SetBytes(itemJSON, "call_id", callID)
-				itemJSON, _ = sjson.SetBytes(itemJSON, "name", name)
+				itemJSON, _ = util.SafeSetJSON(itemJSON, "id", fmt.Sprintf("fc_%s", callID))
+				itemJSON, _ = util.SafeSetJSON(itemJSON, "call_id", callID)
+				itemJSON, _ = util.SafeSetJSON(itemJSON, "name", name)
 				argsStr := ""
 				if args.Exists() {
 					argsStr = args.Raw
 				}
-				itemJSON, _ = sjson.SetBytes(itemJSON, "arguments", argsStr)
+				itemJSON, _ = util.SafeSetJSON(itemJSON, "arguments", argsStr)
 				appendOutput(itemJSON)
 				return true
 			}
@@ -724,13 +723,13 @@ func ConvertGeminiResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 	if reasoningText.Len() > 0 || reasoningEncrypted != "" {
 		rid := strings.TrimPrefix(id, "resp_")
 		itemJSON := []byte(`{"id":"","type":"reasoning","encrypted_content":""}`)
-		itemJSON, _ = sjson.SetBytes(itemJSON, "id", fmt.Sprintf("rs_%s", rid))
-		itemJSON, _ = sjson.SetBytes(itemJSON, "encrypted_content", reasoningEncrypted)
+		itemJSON, _ = util.SafeSetJSON(itemJSON, "id", fmt.Sprintf("rs_%s", rid))
+		itemJSON, _ = util.SafeSetJSON(itemJSON, "encrypted_content", reasoningEncrypted)
 		if reasoningText.Len() > 0 {
 			summaryJSON := []byte(`{"type":"summary_text","text":""}`)
-			summaryJSON, _ = sjson.SetBytes(summaryJSON, "text", reasoningText.String())
-			itemJSON, _ = sjson.SetRawBytes(itemJSON, "summary", []byte(`[]`))
-			itemJSON, _ = sjson.SetRawBytes(itemJSON, "summary.-1", summaryJSON)
+			summaryJSON, _ = util.SafeSetJSON(summaryJSON, "text", reasoningText.String())
+			itemJSON, _ = util.SafeSetRawJSON(itemJSON, "summary", []byte(`[]`))
+			itemJSON, _ = util.SafeSetRawJSON(itemJSON, "summary.-1", summaryJSON)
 		}
 		appendOutput(itemJSON)
 	}
@@ -738,8 +737,8 @@ func ConvertGeminiResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 	// Assistant message output item
 	if haveMessage {
 		itemJSON := []byte(`{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}`)
-		itemJSON, _ = sjson.SetBytes(itemJSON, "id", fmt.Sprintf("msg_%s_0", strings.TrimPrefix(id, "resp_")))
-		itemJSON, _ = sjson.SetBytes(itemJSON, "content.0.text", messageText.String())
+		itemJSON, _ = util.SafeSetJSON(itemJSON, "id", fmt.Sprintf("msg_%s_0", strings.TrimPrefix(id, "resp_")))
+		itemJSON, _ = util.SafeSetJSON(itemJSON, "content.0.text", messageText.String())
 		appendOutput(itemJSON)
 	}
 
@@ -747,18 +746,18 @@ func ConvertGeminiResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 	if um := root.Get("usageMetadata"); um.Exists() {
 		// input tokens = prompt only (thoughts go to output)
 		input := um.Get("promptTokenCount").Int()
-		resp, _ = sjson.SetBytes(resp, "usage.input_tokens", input)
+		resp, _ = util.SafeSetJSON(resp, "usage.input_tokens", input)
 		// cached token details: align with OpenAI "cached_tokens" semantics.
-		resp, _ = sjson.SetBytes(resp, "usage.input_tokens_details.cached_tokens", um.Get("cachedContentTokenCount").Int())
+		resp, _ = util.SafeSetJSON(resp, "usage.input_tokens_details.cached_tokens", um.Get("cachedContentTokenCount").Int())
 		// output tokens
 		if v := um.Get("candidatesTokenCount"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "usage.output_tokens", v.Int())
+			resp, _ = util.SafeSetJSON(resp, "usage.output_tokens", v.Int())
 		}
 		if v := um.Get("thoughtsTokenCount"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "usage.output_tokens_details.reasoning_tokens", v.Int())
+			resp, _ = util.SafeSetJSON(resp, "usage.output_tokens_details.reasoning_tokens", v.Int())
 		}
 		if v := um.Get("totalTokenCount"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "usage.total_tokens", v.Int())
+			resp, _ = util.SafeSetJSON(resp, "usage.total_tokens", v.Int())
 		}
 	}
 
@@ -12,9 +12,9 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 // cachedToolDescription stores the dynamically-fetched web_search tool description.
@@ -265,7 +265,7 @@ func ReplaceWebSearchToolDescription(body []byte) ([]byte, error) {
 	if err != nil {
 		return body, fmt.Errorf("failed to marshal updated tools: %w", err)
 	}
-	result, err := sjson.SetRawBytes(body, "tools", updatedJSON)
+	result, err := util.SafeSetRawJSON(body, "tools", updatedJSON)
 	if err != nil {
 		return body, fmt.Errorf("failed to set updated tools: %w", err)
 	}
@@ -9,8 +9,8 @@ import (
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 // ConvertClaudeRequestToOpenAI parses and transforms an Anthropic API request into OpenAI Chat Completions API format.
@@ -24,18 +24,18 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 	root := gjson.ParseBytes(rawJSON)
 
 	// Model mapping
-	out, _ = sjson.SetBytes(out, "model", modelName)
+	out, _ = util.SafeSetJSON(out, "model", modelName)
 
 	// Max tokens
 	if maxTokens := root.Get("max_tokens"); maxTokens.Exists() {
-		out, _ = sjson.SetBytes(out, "max_tokens", maxTokens.Int())
+		out, _ = util.SafeSetJSON(out, "max_tokens", maxTokens.Int())
 	}
 
 	// Temperature
 	if temp := root.Get("temperature"); temp.Exists() {
-		out, _ = sjson.SetBytes(out, "temperature", temp.Float())
+		out, _ = util.SafeSetJSON(out, "temperature", temp.Float())
 	} else if topP := root.Get("top_p"); topP.Exists() { // Top P
-		out, _ = sjson.SetBytes(out, "top_p", topP.Float())
+		out, _ = util.SafeSetJSON(out, "top_p", topP.Float())
 	}
 
 	// Stop sequences -> stop
@@ -48,16 +48,16 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 			})
 			if len(stops) > 0 {
 				if len(stops) == 1 {
-					out, _ = sjson.SetBytes(out, "stop", stops[0])
+					out, _ = util.SafeSetJSON(out, "stop", stops[0])
 				} else {
-					out, _ = sjson.SetBytes(out, "stop", stops)
+					out, _ = util.SafeSetJSON(out, "stop", stops)
 				}
 			}
 		}
 	}
 
 	// Stream
-	out, _ = sjson.SetBytes(out, "stream", stream)
+	out, _ = util.SafeSetJSON(out, "stream", stream)
 
 	// Thinking: Convert Claude thinking.budget_tokens to OpenAI reasoning_effort
 	if thinkingConfig := root.Get("thinking"); thinkingConfig.Exists() && thinkingConfig.IsObject() {
@@ -67,12 +67,12 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 				if budgetTokens := thinkingConfig.Get("budget_tokens"); budgetTokens.Exists() {
 					budget := int(budgetTokens.Int())
 					if effort, ok := thinking.ConvertBudgetToLevel(budget); ok && effort != "" {
-						out, _ = sjson.SetBytes(out, "reasoning_effort", effort)
+						out, _ = util.SafeSetJSON(out, "reasoning_effort", effort)
 					}
 				} else {
 					// No budget_tokens specified, default to "auto" for enabled thinking
 					if effort, ok := thinking.ConvertBudgetToLevel(-1); ok && effort != "" {
-						out, _ = sjson.SetBytes(out, "reasoning_effort", effort)
+						out, _ = util.SafeSetJSON(out, "reasoning_effort", effort)
 					}
 				}
 			case "adaptive", "auto":
@@ -83,13 +83,13 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 					effort = strings.ToLower(strings.TrimSpace(v.String()))
 				}
 				if effort != "" {
-					out, _ = sjson.SetBytes(out, "reasoning_effort", effort)
+					out, _ = util.SafeSetJSON(out, "reasoning_effort", effort)
 				} else {
-					out, _ = sjson.SetBytes(out, "reasoning_effort", string(thinking.LevelXHigh))
+					out, _ = util.SafeSetJSON(out, "reasoning_effort", string(thinking.LevelXHigh))
 				}
 			case "disabled":
 				if effort, ok := thinking.ConvertBudgetToLevel(0); ok && effort != "" {
-					out, _ = sjson.SetBytes(out, "reasoning_effort", effort)
+					out, _ = util.SafeSetJSON(out, "reasoning_effort", effort)
 				}
 			}
 		}
@@ -105,8 +105,8 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 		if system.Type == gjson.String {
 			if system.String() != "" {
 				oldSystem := []byte(`{"type":"text","text":""}`)
-				oldSystem, _ = sjson.SetBytes(oldSystem, "text", system.String())
-				systemMsgJSON, _ = sjson.SetRawBytes(systemMsgJSON, "content.-1", oldSystem)
+				oldSystem, _ = util.SafeSetJSON(oldSystem, "text", system.String())
+				systemMsgJSON, _ = util.SafeSetRawJSON(systemMsgJSON, "content.-1", oldSystem)
 				hasSystemContent = true
 			}
 		} else if system.Type == gjson.JSON {
@@ -114,7 +114,7 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 				systemResults := system.Array()
 				for i := 0; i < len(systemResults); i++ {
 					if contentItem, ok := convertClaudeContentPart(systemResults[i]); ok {
-						systemMsgJSON, _ = sjson.SetRawBytes(systemMsgJSON, "content.-1", []byte(contentItem))
+						systemMsgJSON, _ = util.SafeSetRawJSON(systemMsgJSON, "content.-1", []byte(contentItem))
 						hasSystemContent = true
 					}
 				}
@@ -123,7 +123,7 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 	}
 	// Only add system message if it has content
 	if hasSystemContent {
-		messagesJSON, _ = sjson.SetRawBytes(messagesJSON, "-1", systemMsgJSON)
+		messagesJSON, _ = util.SafeSetRawJSON(messagesJSON, "-1", systemMsgJSON)
 	}
 
 	// Process Anthropic messages
@@ -166,14 +166,14 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 						// Only allow tool_use -> tool_calls for assistant messages (security: prevent injection).
 						if role == "assistant" {
 							toolCallJSON := []byte(`{"id":"","type":"function","function":{"name":"","arguments":""}}`)
-							toolCallJSON, _ = sjson.SetBytes(toolCallJSON, "id", part.Get("id").String())
-							toolCallJSON, _ = sjson.SetBytes(toolCallJSON, "function.name", part.Get("name").String())
+							toolCallJSON, _ = util.SafeSetJSON(toolCallJSON, "id", part.Get("id").String())
+							toolCallJSON, _ = util.SafeSetJSON(toolCallJSON, "function.name", part.Get("name").String())
 
 							// Convert input to arguments JSON string
 							if input := part.Get("input"); input.Exists() {
-								toolCallJSON, _ = sjson.SetBytes(toolCallJSON, "function.arguments", input.Raw)
+								toolCallJSON, _ = util.SafeSetJSON(toolCallJSON, "function.arguments", input.Raw)
 							} else {
-								toolCallJSON, _ = sjson.SetBytes(toolCallJSON, "function.arguments", "{}")
+								toolCallJSON, _ = util.SafeSetJSON(toolCallJSON, "function.arguments", "{}")
 							}
 
 							toolCalls = append(toolCalls, gjson.ParseBytes(toolCallJSON).Value())
@@ -182,12 +182,12 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 					case "tool_result":
 						// Collect tool_result to emit after the main message (ensures tool results follow tool_calls)
 						toolResultJSON := []byte(`{"role":"tool","tool_call_id":"","content":""}`)
-						toolResultJSON, _ = sjson.SetBytes(toolResultJSON, "tool_call_id", part.Get("tool_use_id").String())
+						toolResultJSON, _ = util.SafeSetJSON(toolResultJSON, "tool_call_id", part.Get("tool_use_id").String())
 						toolResultContent, toolResultContentRaw := convertClaudeToolResultContent(part.Get("content"))
 						if toolResultContentRaw {
-							toolResultJSON, _ = sjson.SetRawBytes(toolResultJSON, "content", []byte(toolResultContent))
+							toolResultJSON, _ = util.SafeSetRawJSON(toolResultJSON, "content", []byte(toolResultContent))
 						} else {
-							toolResultJSON, _ = sjson.SetBytes(toolResultJSON, "content", toolResultContent)
+							toolResultJSON, _ = util.SafeSetJSON(toolResultJSON, "content", toolResultContent)
 						}
 						toolResults = append(toolResults, toolResultJSON)
 					}
@@ -209,7 +209,7 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 				// Therefore, we emit tool_result messages FIRST (they respond to the previous assistant's tool_calls),
 				// then emit the current message's content.
 				for _, toolResultJSON := range toolResults {
-					messagesJSON, _ = sjson.SetRawBytes(messagesJSON, "-1", toolResultJSON)
+					messagesJSON, _ = util.SafeSetRawJSON(messagesJSON, "-1", toolResultJSON)
 				}
 
 				// For assistant messages: emit a single unified message with content, tool_calls, and reasoning_content
@@ -222,40 +222,40 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 						if hasContent {
 							contentArrayJSON := []byte(`[]`)
 							for _, contentItem := range contentItems {
-								contentArrayJSON, _ = sjson.SetRawBytes(contentArrayJSON, "-1", contentItem)
+								contentArrayJSON, _ = util.SafeSetRawJSON(contentArrayJSON, "-1", contentItem)
 							}
-							msgJSON, _ = sjson.SetRawBytes(msgJSON, "content", contentArrayJSON)
+							msgJSON, _ = util.SafeSetRawJSON(msgJSON, "content", contentArrayJSON)
 						} else {
 							// Ensure content field exists for OpenAI compatibility
-							msgJSON, _ = sjson.SetBytes(msgJSON, "content", "")
+							msgJSON, _ = util.SafeSetJSON(msgJSON, "content", "")
 						}
 
 						// Add reasoning_content if present
 						if hasReasoning {
-							msgJSON, _ = sjson.SetBytes(msgJSON, "reasoning_content", reasoningContent)
+							msgJSON, _ = util.SafeSetJSON(msgJSON, "reasoning_content", reasoningContent)
 						}
 
 						// Add tool_calls if present (in same message as content)
 						if hasToolCalls {
-							msgJSON, _ = sjson.SetBytes(msgJSON, "tool_calls", toolCalls)
+							msgJSON, _ = util.SafeSetJSON(msgJSON, "tool_calls", toolCalls)
 						}
 
-						messagesJSON, _ = sjson.SetRawBytes(messagesJSON, "-1", msgJSON)
+						messagesJSON, _ = util.SafeSetRawJSON(messagesJSON, "-1", msgJSON)
 					}
 				} else {
 					// For non-assistant roles: emit content message if we have content
 					// If the message only contains tool_results (no text/image), we still processed them above
 					if hasContent {
 						msgJSON := []byte(`{"role":""}`)
-						msgJSON, _ = sjson.SetBytes(msgJSON, "role", role)
+						msgJSON, _ = util.SafeSetJSON(msgJSON, "role", role)
 
 						contentArrayJSON := []byte(`[]`)
 						for _, contentItem := range contentItems {
-							contentArrayJSON, _ = sjson.SetRawBytes(contentArrayJSON, "-1", contentItem)
+							contentArrayJSON, _ = util.SafeSetRawJSON(contentArrayJSON, "-1", contentItem)
 						}
-						msgJSON, _ = sjson.SetRawBytes(msgJSON, "content", contentArrayJSON)
+						msgJSON, _ = util.SafeSetRawJSON(msgJSON, "content", contentArrayJSON)
 
-						messagesJSON, _ = sjson.SetRawBytes(messagesJSON, "-1", msgJSON)
+						messagesJSON, _ = util.SafeSetRawJSON(messagesJSON, "-1", msgJSON)
 					} else if hasToolResults && !hasContent {
 						// tool_results already emitted above, no additional user message needed
 					}
@@ -264,9 +264,9 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 			} else if contentResult.Exists() && contentResult.Type == gjson.String {
 				// Simple string content
 				msgJSON := []byte(`{"role":"","content":""}`)
-				msgJSON, _ = sjson.SetBytes(msgJSON, "role", role)
-				msgJSON, _ = sjson.SetBytes(msgJSON, "content", contentResult.String())
-				messagesJSON, _ = sjson.SetRawBytes(messagesJSON, "-1", msgJSON)
+				msgJSON, _ = util.SafeSetJSON(msgJSON, "role", role)
+				msgJSON, _ = util.SafeSetJSON(msgJSON, "content", contentResult.String())
+				messagesJSON, _ = util.SafeSetRawJSON(messagesJSON, "-1", msgJSON)
 			}
 
 			return true
@@ -275,7 +275,7 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 
 	// Set messages
 	if msgs := gjson.ParseBytes(messagesJSON); msgs.IsArray() && len(msgs.Array()) > 0 {
-		out, _ = sjson.SetRawBytes(out, "messages", messagesJSON)
+		out, _ = util.SafeSetRawJSON(out, "messages", messagesJSON)
 	}
 
 	// Process tools - convert Anthropic tools to OpenAI functions
@@ -284,20 +284,20 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 
 		tools.ForEach(func(_, tool gjson.Result) bool {
 			openAIToolJSON := []byte(`{"type":"function","function":{"name":"","description":""}}`)
-			openAIToolJSON, _ = sjson.SetBytes(openAIToolJSON, "function.name", tool.Get("name").String())
-			openAIToolJSON, _ = sjson.SetBytes(openAIToolJSON, "function.description", tool.Get("description").String())
+			openAIToolJSON, _ = util.SafeSetJSON(openAIToolJSON, "function.name", tool.Get("name").String())
+			openAIToolJSON, _ = util.SafeSetJSON(openAIToolJSON, "function.description", tool.Get("description").String())
 
 			// Convert Anthropic input_schema to OpenAI function parameters
 			if inputSchema := tool.Get("input_schema"); inputSchema.Exists() {
-				openAIToolJSON, _ = sjson.SetBytes(openAIToolJSON, "function.parameters", inputSchema.Value())
+				openAIToolJSON, _ = util.SafeSetJSON(openAIToolJSON, "function.parameters", inputSchema.Value())
 			}
 
-			toolsJSON, _ = sjson.SetRawBytes(toolsJSON, "-1", openAIToolJSON)
+			toolsJSON, _ = util.SafeSetRawJSON(toolsJSON, "-1", openAIToolJSON)
 			return true
 		})
 
 		if parsed := gjson.ParseBytes(toolsJSON); parsed.IsArray() && len(parsed.Array()) > 0 {
-			out, _ = sjson.SetRawBytes(out, "tools", toolsJSON)
+			out, _ = util.SafeSetRawJSON(out, "tools", toolsJSON)
 		}
 	}
 
@@ -305,24 +305,24 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 	if toolChoice := root.Get("tool_choice"); toolChoice.Exists() {
 		switch toolChoice.Get("type").String() {
 		case "auto":
-			out, _ = sjson.SetBytes(out, "tool_choice", "auto")
+			out, _ = util.SafeSetJSON(out, "tool_choice", "auto")
 		case "any":
-			out, _ = sjson.SetBytes(out, "tool_choice", "required")
+			out, _ = util.SafeSetJSON(out, "tool_choice", "required")
 		case "tool":
 			// Specific tool choice
 			toolName := toolChoice.Get("name").String()
 			toolChoiceJSON := []byte(`{"type":"function","function":{"name":""}}`)
-			toolChoiceJSON, _ = sjson.SetBytes(toolChoiceJSON, "function.name", toolName)
-			out, _ = sjson.SetRawBytes(out, "tool_choice", toolChoiceJSON)
+			toolChoiceJSON, _ = util.SafeSetJSON(toolChoiceJSON, "function.name", toolName)
+			out, _ = util.SafeSetRawJSON(out, "tool_choice", toolChoiceJSON)
 		default:
 			// Default to auto if not specified
-			out, _ = sjson.SetBytes(out, "tool_choice", "auto")
+			out, _ = util.SafeSetJSON(out, "tool_choice", "auto")
 		}
 	}
 
 	// Handle user parameter (for tracking)
 	if user := root.Get("user"); user.Exists() {
-		out, _ = sjson.SetBytes(out, "user", user.String())
+		out, _ = util.SafeSetJSON(out, "user", user.String())
 	}
 
 	return out
@@ -338,7 +338,7 @@ func convertClaudeContentPart(part gjson.Result) (string, bool) {
 			return "", false
 		}
 		textContent := []byte(`{"type":"text","text":""}`)
-		textContent, _ = sjson.SetBytes(textContent, "text", text)
+		textContent, _ = util.SafeSetJSON(textContent, "text", text)
 		return string(textContent), true
 
 	case "image":
@@ -370,7 +370,7 @@ func convertClaudeContentPart(part gjson.Result) (string, bool) {
 		}
 
 		imageContent := []byte(`{"type":"image_url","image_url":{"url":""}}`)
-		imageContent, _ = sjson.SetBytes(imageContent, "image_url.url", imageURL)
+		imageContent, _ = util.SafeSetJSON(imageContent, "image_url.url", imageURL)
 
 		return string(imageContent), true
 
@@ -398,18 +398,18 @@ func convertClaudeToolResultContent(content gjson.Result) (string, bool) {
 				text := item.String()
 				parts = append(parts, text)
 				textContent := []byte(`{"type":"text","text":""}`)
-				textContent, _ = sjson.SetBytes(textContent, "text", text)
-				contentJSON, _ = sjson.SetRawBytes(contentJSON, "-1", textContent)
+				textContent, _ = util.SafeSetJSON(textContent, "text", text)
+				contentJSON, _ = util.SafeSetRawJSON(contentJSON, "-1", textContent)
 			case item.IsObject() && item.Get("type").String() == "text":
 				text := item.Get("text").String()
 				parts = append(parts, text)
 				textContent := []byte(`{"type":"text","text":""}`)
-				textContent, _ = sjson.SetBytes(textContent, "text", text)
-				contentJSON, _ = sjson.SetRawBytes(contentJSON, "-1", textContent)
+				textContent, _ = util.SafeSetJSON(textContent, "text", text)
+				contentJSON, _ = util.SafeSetRawJSON(contentJSON, "-1", textContent)
 			case item.IsObject() && item.Get("type").String() == "image":
 				contentItem, ok := convertClaudeContentPart(item)
 				if ok {
-					contentJSON, _ = sjson.SetRawBytes(contentJSON, "-1", []byte(contentItem))
+					contentJSON, _ = util.SafeSetRawJSON(contentJSON, "-1", []byte(contentItem))
 					hasImagePart = true
 				} else {
 					parts = append(parts, item.Raw)
@@ -438,7 +438,7 @@ func convertClaudeToolResultContent(content gjson.Result) (string, bool) {
 			contentItem, ok := convertClaudeContentPart(content)
 			if ok {
 				contentJSON := []byte(`[]`)
-				contentJSON, _ = sjson.SetRawBytes(contentJSON, "-1", []byte(contentItem))
+				contentJSON, _ = util.SafeSetRawJSON(contentJSON, "-1", []byte(contentItem))
 				return string(contentJSON), true
 			}
 		}
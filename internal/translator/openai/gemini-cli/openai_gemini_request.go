@@ -7,8 +7,8 @@ package geminiCLI
 
 import (
 	. "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/openai/gemini"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 // ConvertGeminiCLIRequestToOpenAI parses and transforms a Gemini API request into OpenAI Chat Completions API format.
@@ -17,10 +17,10 @@ import (
 func ConvertGeminiCLIRequestToOpenAI(modelName string, inputRawJSON []byte, stream bool) []byte {
 	rawJSON := inputRawJSON
 	rawJSON = []byte(gjson.GetBytes(rawJSON, "request").Raw)
-	rawJSON, _ = sjson.SetBytes(rawJSON, "model", modelName)
+	rawJSON, _ = util.SafeSetJSON(rawJSON, "model", modelName)
 	if gjson.GetBytes(rawJSON, "systemInstruction").Exists() {
-		rawJSON, _ = sjson.SetRawBytes(rawJSON, "system_instruction", []byte(gjson.GetBytes(rawJSON, "systemInstruction").Raw))
-		rawJSON, _ = sjson.DeleteBytes(rawJSON, "systemInstruction")
+		rawJSON, _ = util.SafeSetRawJSON(rawJSON, "system_instruction", []byte(gjson.GetBytes(rawJSON, "systemInstruction").Raw))
+		rawJSON, _ = util.SafeDeleteJSON(rawJSON, "systemInstruction")
 	}
 
 	return ConvertGeminiRequestToOpenAI(modelName, rawJSON, stream)
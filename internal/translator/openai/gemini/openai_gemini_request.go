@@ -12,8 +12,8 @@ import (
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 // ConvertGeminiRequestToOpenAI parses and transforms a Gemini API request into OpenAI Chat Completions API format.
@@ -39,29 +39,29 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 	}
 
 	// Model mapping
-	out, _ = sjson.SetBytes(out, "model", modelName)
+	out, _ = util.SafeSetJSON(out, "model", modelName)
 
 	// Generation config mapping
 	if genConfig := root.Get("generationConfig"); genConfig.Exists() {
 		// Temperature
 		if temp := genConfig.Get("temperature"); temp.Exists() {
-			out, _ = sjson.SetBytes(out, "temperature", temp.Float())
+			out, _ = util.SafeSetJSON(out, "temperature", temp.Float())
 		}
 
 		// Max tokens
 		if maxTokens := genConfig.Get("maxOutputTokens"); maxTokens.Exists() {
-			out, _ = sjson.SetBytes(out, "max_tokens", maxTokens.Int())
+			out, _ = util.SafeSetJSON(out, "max_tokens", maxTokens.Int())
 		}
 
 		// Top P
 		if topP := genConfig.Get("topP"); topP.Exists() {
-			out, _ = sjson.SetBytes(out, "top_p", topP.Float())
+			out, _ = util.SafeSetJSON(out, "top_p", topP.Float())
 		}
 
 		// Top K (OpenAI doesn't have direct equivalent, but we can map it)
 		if topK := genConfig.Get("topK"); topK.Exists() {
 			// Store as custom parameter for potential use
-			out, _ = sjson.SetBytes(out, "top_k", topK.Int())
+			out, _ = util.SafeSetJSON(out, "top_k", topK.Int())
 		}
 
 		// Stop sequences
@@ -72,13 +72,13 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 				return true
 			})
 			if len(stops) > 0 {
-				out, _ = sjson.SetBytes(out, "stop", stops)
+				out, _ = util.SafeSetJSON(out, "stop", stops)
 			}
 		}
 
 		// Candidate count (OpenAI 'n' parameter)
 		if candidateCount := genConfig.Get("candidateCount"); candidateCount.Exists() {
-			out, _ = sjson.SetBytes(out, "n", candidateCount.Int())
+			out, _ = util.SafeSetJSON(out, "n", candidateCount.Int())
 		}
 
 		// Map Gemini thinkingConfig to OpenAI reasoning_effort.
@@ -92,7 +92,7 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 			if thinkingLevel.Exists() {
 				effort := strings.ToLower(strings.TrimSpace(thinkingLevel.String()))
 				if effort != "" {
-					out, _ = sjson.SetBytes(out, "reasoning_effort", effort)
+					out, _ = util.SafeSetJSON(out, "reasoning_effort", effort)
 				}
 			} else {
 				thinkingBudget := thinkingConfig.Get("thinkingBudget")
@@ -101,7 +101,7 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 				}
 				if thinkingBudget.Exists() {
 					if effort, ok := thinking.ConvertBudgetToLevel(int(thinkingBudget.Int())); ok {
-						out, _ = sjson.SetBytes(out, "reasoning_effort", effort)
+						out, _ = util.SafeSetJSON(out, "reasoning_effort", effort)
 					}
 				}
 			}
@@ -109,7 +109,7 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 	}
 
 	// Stream parameter
-	out, _ = sjson.SetBytes(out, "stream", stream)
+	out, _ = util.SafeSetJSON(out, "stream", stream)
 
 	// Process contents (Gemini messages) -> OpenAI messages
 	var toolCallIDs []string // Track tool call IDs for matching with tool results
@@ -130,8 +130,8 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 				// Handle text parts
 				if text := part.Get("text"); text.Exists() {
 					contentPart := []byte(`{"type":"text","text":""}`)
-					contentPart, _ = sjson.SetBytes(contentPart, "text", text.String())
-					msg, _ = sjson.SetRawBytes(msg, "content.-1", contentPart)
+					contentPart, _ = util.SafeSetJSON(contentPart, "text", text.String())
+					msg, _ = util.SafeSetRawJSON(msg, "content.-1", contentPart)
 					hasContent = true
 				}
 
@@ -145,8 +145,8 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 					imageURL := fmt.Sprintf("data:%s;base64,%s", mimeType, data)
 
 					contentPart := []byte(`{"type":"image_url","image_url":{"url":""}}`)
-					contentPart, _ = sjson.SetBytes(contentPart, "image_url.url", imageURL)
-					msg, _ = sjson.SetRawBytes(msg, "content.-1", contentPart)
+					contentPart, _ = util.SafeSetJSON(contentPart, "image_url.url", imageURL)
+					msg, _ = util.SafeSetRawJSON(msg, "content.-1", contentPart)
 					hasContent = true
 				}
 				return true
@@ -154,7 +154,7 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 		}
 
 		if hasContent {
-			out, _ = sjson.SetRawBytes(out, "messages.-1", msg)
+			out, _ = util.SafeSetRawJSON(out, "messages.-1", msg)
 		}
 	}
 
@@ -169,7 +169,7 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 			}
 
 			msg := []byte(`{"role":"","content":""}`)
-			msg, _ = sjson.SetBytes(msg, "role", role)
+			msg, _ = util.SafeSetJSON(msg, "role", role)
 
 			var textBuilder strings.Builder
 			contentWrapper := []byte(`{"arr":[]}`)
@@ -185,8 +185,8 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 						formattedText := text.String()
 						textBuilder.WriteString(formattedText)
 						contentPart := []byte(`{"type":"text","text":""}`)
-						contentPart, _ = sjson.SetBytes(contentPart, "text", formattedText)
-						contentWrapper, _ = sjson.SetRawBytes(contentWrapper, "arr.-1", contentPart)
+						contentPart, _ = util.SafeSetJSON(contentPart, "text", formattedText)
+						contentWrapper, _ = util.SafeSetRawJSON(contentWrapper, "arr.-1", contentPart)
 						contentPartsCount++
 					}
 
@@ -202,8 +202,8 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 						imageURL := fmt.Sprintf("data:%s;base64,%s", mimeType, data)
 
 						contentPart := []byte(`{"type":"image_url","image_url":{"url":""}}`)
-						contentPart, _ = sjson.SetBytes(contentPart, "image_url.url", imageURL)
-						contentWrapper, _ = sjson.SetRawBytes(contentWrapper, "arr.-1", contentPart)
+						contentPart, _ = util.SafeSetJSON(contentPart, "image_url.url", imageURL)
+						contentWrapper, _ = util.SafeSetRawJSON(contentWrapper, "arr.-1", contentPart)
 						contentPartsCount++
 					}
 
@@ -213,17 +213,17 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 						toolCallIDs = append(toolCallIDs, toolCallID)
 
 						toolCall := []byte(`{"id":"","type":"function","function":{"name":"","arguments":""}}`)
-						toolCall, _ = sjson.SetBytes(toolCall, "id", toolCallID)
-						toolCall, _ = sjson.SetBytes(toolCall, "function.name", functionCall.Get("name").String())
+						toolCall, _ = util.SafeSetJSON(toolCall, "id", toolCallID)
+						toolCall, _ = util.SafeSetJSON(toolCall, "function.name", functionCall.Get("name").String())
 
 						// Convert args to arguments JSON string
 						if args := functionCall.Get("args"); args.Exists() {
-							toolCall, _ = sjson.SetBytes(toolCall, "function.arguments", args.Raw)
+							toolCall, _ = util.SafeSetJSON(toolCall, "function.arguments", args.Raw)
 						} else {
-							toolCall, _ = sjson.SetBytes(toolCall, "function.arguments", "{}")
+							toolCall, _ = util.SafeSetJSON(toolCall, "function.arguments", "{}")
 						}
 
-						toolCallsWrapper, _ = sjson.SetRawBytes(toolCallsWrapper, "arr.-1", toolCall)
+						toolCallsWrapper, _ = util.SafeSetRawJSON(toolCallsWrapper, "arr.-1", toolCall)
 						toolCallsCount++
 					}
 
@@ -235,9 +235,9 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 						// Convert response.content to JSON string
 						if response := functionResponse.Get("response"); response.Exists() {
 							if contentField := response.Get("content"); contentField.Exists() {
-								toolMsg, _ = sjson.SetBytes(toolMsg, "content", contentField.Raw)
+								toolMsg, _ = util.SafeSetJSON(toolMsg, "content", contentField.Raw)
 							} else {
-								toolMsg, _ = sjson.SetBytes(toolMsg, "content", response.Raw)
+								toolMsg, _ = util.SafeSetJSON(toolMsg, "content", response.Raw)
 							}
 						}
 
@@ -246,13 +246,13 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 						if len(toolCallIDs) > 0 {
 							// Use the last tool call ID (simple matching by function name)
 							// In a real implementation, you might want more sophisticated matching
-							toolMsg, _ = sjson.SetBytes(toolMsg, "tool_call_id", toolCallIDs[len(toolCallIDs)-1])
+							toolMsg, _ = util.SafeSetJSON(toolMsg, "tool_call_id", toolCallIDs[len(toolCallIDs)-1])
 						} else {
 							// Generate a tool call ID if none available
-							toolMsg, _ = sjson.SetBytes(toolMsg, "tool_call_id", genToolCallID())
+							toolMsg, _ = util.SafeSetJSON(toolMsg, "tool_call_id", genToolCallID())
 						}
 
-						out, _ = sjson.SetRawBytes(out, "messages.-1", toolMsg)
+						out, _ = util.SafeSetRawJSON(out, "messages.-1", toolMsg)
 					}
 
 					return true
@@ -262,18 +262,18 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 			// Set content
 			if contentPartsCount > 0 {
 				if onlyTextContent {
-					msg, _ = sjson.SetBytes(msg, "content", textBuilder.String())
+					msg, _ = util.SafeSetJSON(msg, "content", textBuilder.String())
 				} else {
-					msg, _ = sjson.SetRawBytes(msg, "content", []byte(gjson.GetBytes(contentWrapper, "arr").Raw))
+					msg, _ = util.SafeSetRawJSON(msg, "content", []byte(gjson.GetBytes(contentWrapper, "arr").Raw))
 				}
 			}
 
 			// Set tool calls if any
 			if toolCallsCount > 0 {
-				msg, _ = sjson.SetRawBytes(msg, "tool_calls", []byte(gjson.GetBytes(toolCallsWrapper, "arr").Raw))
+				msg, _ = util.SafeSetRawJSON(msg, "tool_calls", []byte(gjson.GetBytes(toolCallsWrapper, "arr").Raw))
 			}
 
-			out, _ = sjson.SetRawBytes(out, "messages.-1", msg)
+			out, _ = util.SafeSetRawJSON(out, "messages.-1", msg)
 			return true
 		})
 	}
@@ -284,17 +284,17 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 			if functionDeclarations := tool.Get("functionDeclarations"); functionDeclarations.Exists() && functionDeclarations.IsArray() {
 				functionDeclarations.ForEach(func(_, funcDecl gjson.Result) bool {
 					openAITool := []byte(`{"type":"function","function":{"name":"","description":""}}`)
-					openAITool, _ = sjson.SetBytes(openAITool, "function.name", funcDecl.Get("name").String())
-					openAITool, _ = sjson.SetBytes(openAITool, "function.description", funcDecl.Get("description").String())
+					openAITool, _ = util.SafeSetJSON(openAITool, "function.name", funcDecl.Get("name").String())
+					openAITool, _ = util.SafeSetJSON(openAITool, "function.description", funcDecl.Get("description").String())
 
 					// Convert parameters schema
 					if parameters := funcDecl.Get("parameters"); parameters.Exists() {
-						openAITool, _ = sjson.SetRawBytes(openAITool, "function.parameters", []byte(parameters.Raw))
+						openAITool, _ = util.SafeSetRawJSON(openAITool, "function.parameters", []byte(parameters.Raw))
 					} else if parameters := funcDecl.Get("parametersJsonSchema"); parameters.Exists() {
-						openAITool, _ = sjson.SetRawBytes(openAITool, "function.parameters", []byte(parameters.Raw))
+						openAITool, _ = util.SafeSetRawJSON(openAITool, "function.parameters", []byte(parameters.Raw))
 					}
 
-					out, _ = sjson.SetRawBytes(out, "tools.-1", openAITool)
+					out, _ = util.SafeSetRawJSON(out, "tools.-1", openAITool)
 					return true
 				})
 			}
@@ -308,11 +308,11 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 			mode := functionCallingConfig.Get("mode").String()
 			switch mode {
 			case "NONE":
-				out, _ = sjson.SetBytes(out, "tool_choice", "none")
+				out, _ = util.SafeSetJSON(out, "tool_choice", "none")
 			case "AUTO":
-				out, _ = sjson.SetBytes(out, "tool_choice", "auto")
+				out, _ = util.SafeSetJSON(out, "tool_choice", "auto")
 			case "ANY":
-				out, _ = sjson.SetBytes(out, "tool_choice", "required")
+				out, _ = util.SafeSetJSON(out, "tool_choice", "required")
 			}
 		}
 	}
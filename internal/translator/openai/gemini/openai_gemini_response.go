@@ -13,8 +13,8 @@ import (
 	"strings"
 
 	translatorcommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/common"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 // ConvertOpenAIResponseToGeminiParams holds parameters for response conversion
@@ -81,14 +81,14 @@ func ConvertOpenAIResponseToGemini(_ context.Context, _ string, originalRequestR
 
 				// Set model if available
 				if model := root.Get("model"); model.Exists() {
-					template, _ = sjson.SetBytes(template, "model", model.String())
+					template, _ = util.SafeSetJSON(template, "model", model.String())
 				}
 
-				template, _ = sjson.SetBytes(template, "usageMetadata.promptTokenCount", usage.Get("prompt_tokens").Int())
-				template, _ = sjson.SetBytes(template, "usageMetadata.candidatesTokenCount", usage.Get("completion_tokens").Int())
-				template, _ = sjson.SetBytes(template, "usageMetadata.totalTokenCount", usage.Get("total_tokens").Int())
+				template, _ = util.SafeSetJSON(template, "usageMetadata.promptTokenCount", usage.Get("prompt_tokens").Int())
+				template, _ = util.SafeSetJSON(template, "usageMetadata.candidatesTokenCount", usage.Get("completion_tokens").Int())
+				template, _ = util.SafeSetJSON(template, "usageMetadata.totalTokenCount", usage.Get("total_tokens").Int())
 				if reasoningTokens := reasoningTokensFromUsage(usage); reasoningTokens > 0 {
-					template, _ = sjson.SetBytes(template, "usageMetadata.thoughtsTokenCount", reasoningTokens)
+					template, _ = util.SafeSetJSON(template, "usageMetadata.thoughtsTokenCount", reasoningTokens)
 				}
 				return [][]byte{template}
 			}
@@ -103,7 +103,7 @@ func ConvertOpenAIResponseToGemini(_ context.Context, _ string, originalRequestR
 
 			// Set model if available
 			if model := root.Get("model"); model.Exists() {
-				template, _ = sjson.SetBytes(template, "model", model.String())
+				template, _ = util.SafeSetJSON(template, "model", model.String())
 			}
 
 			_ = int(choice.Get("index").Int()) // choiceIdx not used in streaming
@@ -114,7 +114,7 @@ func ConvertOpenAIResponseToGemini(_ context.Context, _ string, originalRequestR
 			if role := delta.Get("role"); role.Exists() && (*param).(*ConvertOpenAIResponseToGeminiParams).IsFirstChunk {
 				// OpenAI assistant -> Gemini model
 				if role.String() == "assistant" {
-					template, _ = sjson.SetBytes(template, "candidates.0.content.role", "model")
+					template, _ = util.SafeSetJSON(template, "candidates.0.content.role", "model")
 				}
 				(*param).(*ConvertOpenAIResponseToGeminiParams).IsFirstChunk = false
 				results = append(results, template)
@@ -130,8 +130,8 @@ func ConvertOpenAIResponseToGemini(_ context.Context, _ string, originalRequestR
 						continue
 					}
 					reasoningTemplate := append([]byte(nil), baseTemplate...)
-					reasoningTemplate, _ = sjson.SetBytes(reasoningTemplate, "candidates.0.content.parts.0.thought", true)
-					reasoningTemplate, _ = sjson.SetBytes(reasoningTemplate, "candidates.0.content.parts.0.text", reasoningText)
+					reasoningTemplate, _ = util.SafeSetJSON(reasoningTemplate, "candidates.0.content.parts.0.thought", true)
+					reasoningTemplate, _ = util.SafeSetJSON(reasoningTemplate, "candidates.0.content.parts.0.text", reasoningText)
 					chunkOutputs = append(chunkOutputs, reasoningTemplate)
 				}
 			}
@@ -143,7 +143,7 @@ func ConvertOpenAIResponseToGemini(_ context.Context, _ string, originalRequestR
 
 				// Create text part for this delta
 				contentTemplate := append([]byte(nil), baseTemplate...)
-				contentTemplate, _ = sjson.SetBytes(contentTemplate, "candidates.0.content.parts.0.text", contentText)
+				contentTemplate, _ = util.SafeSetJSON(contentTemplate, "candidates.0.content.parts.0.text", contentText)
 				chunkOutputs = append(chunkOutputs, contentTemplate)
 			}
 
@@ -208,7 +208,7 @@ func ConvertOpenAIResponseToGemini(_ context.Context, _ string, originalRequestR
 			// Handle finish reason
 			if finishReason := choice.Get("finish_reason"); finishReason.Exists() {
 				geminiFinishReason := mapOpenAIFinishReasonToGemini(finishReason.String())
-				template, _ = sjson.SetBytes(template, "candidates.0.finishReason", geminiFinishReason)
+				template, _ = util.SafeSetJSON(template, "candidates.0.finishReason", geminiFinishReason)
 
 				// If we have accumulated tool calls, output them now
 				if len((*param).(*ConvertOpenAIResponseToGeminiParams).ToolCallsAccumulator) > 0 {
@@ -216,8 +216,8 @@ func ConvertOpenAIResponseToGemini(_ context.Context, _ string, originalRequestR
 					for _, accumulator := range (*param).(*ConvertOpenAIResponseToGeminiParams).ToolCallsAccumulator {
 						namePath := fmt.Sprintf("candidates.0.content.parts.%d.functionCall.name", partIndex)
 						argsPath := fmt.Sprintf("candidates.0.content.parts.%d.functionCall.args", partIndex)
-						template, _ = sjson.SetBytes(template, namePath, accumulator.Name)
-						template, _ = sjson.SetRawBytes(template, argsPath, []byte(parseArgsToObjectRaw(accumulator.Arguments.String())))
+						template, _ = util.SafeSetJSON(template, namePath, accumulator.Name)
+						template, _ = util.SafeSetRawJSON(template, argsPath, []byte(parseArgsToObjectRaw(accumulator.Arguments.String())))
 						partIndex++
 					}
 
@@ -231,11 +231,11 @@ func ConvertOpenAIResponseToGemini(_ context.Context, _ string, originalRequestR
 
 			// Handle usage information
 			if usage := root.Get("usage"); usage.Exists() {
-				template, _ = sjson.SetBytes(template, "usageMetadata.promptTokenCount", usage.Get("prompt_tokens").Int())
-				template, _ = sjson.SetBytes(template, "usageMetadata.candidatesTokenCount", usage.Get("completion_tokens").Int())
-				template, _ = sjson.SetBytes(template, "usageMetadata.totalTokenCount", usage.Get("total_tokens").Int())
+				template, _ = util.SafeSetJSON(template, "usageMetadata.promptTokenCount", usage.Get("prompt_tokens").Int())
+				template, _ = util.SafeSetJSON(template, "usageMetadata.candidatesTokenCount", usage.Get("completion_tokens").Int())
+				template, _ = util.SafeSetJSON(template, "usageMetadata.totalTokenCount", usage.Get("total_tokens").Int())
 				if reasoningTokens := reasoningTokensFromUsage(usage); reasoningTokens > 0 {
-					template, _ = sjson.SetBytes(template, "usageMetadata.thoughtsTokenCount", reasoningTokens)
+					template, _ = util.SafeSetJSON(template, "usageMetadata.thoughtsTokenCount", reasoningTokens)
 				}
 				results = append(results, template)
 				return true
@@ -363,10 +363,10 @@ func tolerantParseJSONObjectRaw(s string) string {
 			valToken, ni := parseJSONStringRunes(runes, i)
 			if ni == -1 {
 				// Malformed; treat as empty string
-				result, _ = sjson.SetBytes(result, sjsonKey, "")
+				result, _ = util.SafeSetJSON(result, sjsonKey, "")
 				i = n
 			} else {
-				result, _ = sjson.SetBytes(result, sjsonKey, jsonStringTokenToRawString(valToken))
+				result, _ = util.SafeSetJSON(result, sjsonKey, jsonStringTokenToRawString(valToken))
 				i = ni
 			}
 		case '{', '[':
@@ -376,9 +376,9 @@ func tolerantParseJSONObjectRaw(s string) string {
 				i = n
 			} else {
 				if gjson.Valid(seg) {
-					result, _ = sjson.SetRawBytes(result, sjsonKey, []byte(seg))
+					result, _ = util.SafeSetRawJSON(result, sjsonKey, []byte(seg))
 				} else {
-					result, _ = sjson.SetBytes(result, sjsonKey, seg)
+					result, _ = util.SafeSetJSON(result, sjsonKey, seg)
 				}
 				i = ni
 			}
@@ -391,15 +391,15 @@ func tolerantParseJSONObjectRaw(s string) string {
 			token := strings.TrimSpace(string(runes[i:j]))
 			// Interpret common JSON atoms and numbers; otherwise treat as string
 			if token == "true" {
-				result, _ = sjson.SetBytes(result, sjsonKey, true)
+				result, _ = util.SafeSetJSON(result, sjsonKey, true)
 			} else if token == "false" {
-				result, _ = sjson.SetBytes(result, sjsonKey, false)
+				result, _ = util.SafeSetJSON(result, sjsonKey, false)
 			} else if token == "null" {
-				result, _ = sjson.SetBytes(result, sjsonKey, nil)
+				result, _ = util.SafeSetJSON(result, sjsonKey, nil)
 			} else if numVal, ok := tryParseNumber(token); ok {
-				result, _ = sjson.SetBytes(result, sjsonKey, numVal)
+				result, _ = util.SafeSetJSON(result, sjsonKey, numVal)
 			} else {
-				result, _ = sjson.SetBytes(result, sjsonKey, token)
+				result, _ = util.SafeSetJSON(result, sjsonKey, token)
 			}
 			i = j
 		}
@@ -541,7 +541,7 @@ func ConvertOpenAIResponseToGeminiNonStream(_ context.Context, _ string, origina
 
 	// Set model if available
 	if model := root.Get("model"); model.Exists() {
-		out, _ = sjson.SetBytes(out, "model", model.String())
+		out, _ = util.SafeSetJSON(out, "model", model.String())
 	}
 
 	// Process choices
@@ -553,7 +553,7 @@ func ConvertOpenAIResponseToGeminiNonStream(_ context.Context, _ string, origina
 			// Set role
 			if role := message.Get("role"); role.Exists() {
 				if role.String() == "assistant" {
-					out, _ = sjson.SetBytes(out, "candidates.0.content.role", "model")
+					out, _ = util.SafeSetJSON(out, "candidates.0.content.role", "model")
 				}
 			}
 
@@ -565,15 +565,15 @@ func ConvertOpenAIResponseToGeminiNonStream(_ context.Context, _ string, origina
 					if reasoningText == "" {
 						continue
 					}
-					out, _ = sjson.SetBytes(out, fmt.Sprintf("candidates.0.content.parts.%d.thought", partIndex), true)
-					out, _ = sjson.SetBytes(out, fmt.Sprintf("candidates.0.content.parts.%d.text", partIndex), reasoningText)
+					out, _ = util.SafeSetJSON(out, fmt.Sprintf("candidates.0.content.parts.%d.thought", partIndex), true)
+					out, _ = util.SafeSetJSON(out, fmt.Sprintf("candidates.0.content.parts.%d.text", partIndex), reasoningText)
 					partIndex++
 				}
 			}
 
 			// Handle content first
 			if content := message.Get("content"); content.Exists() && content.String() != "" {
-				out, _ = sjson.SetBytes(out, fmt.Sprintf("candidates.0.content.parts.%d.text", partIndex), content.String())
+				out, _ = util.SafeSetJSON(out, fmt.Sprintf("candidates.0.content.parts.%d.text", partIndex), content.String())
 				partIndex++
 			}
 
@@ -587,8 +587,8 @@ func ConvertOpenAIResponseToGeminiNonStream(_ context.Context, _ string, origina
 
 						namePath := fmt.Sprintf("candidates.0.content.parts.%d.functionCall.name", partIndex)
 						argsPath := fmt.Sprintf("candidates.0.content.parts.%d.functionCall.args", partIndex)
-						out, _ = sjson.SetBytes(out, namePath, functionName)
-						out, _ = sjson.SetRawBytes(out, argsPath, []byte(parseArgsToObjectRaw(functionArgs)))
+						out, _ = util.SafeSetJSON(out, namePath, functionName)
+						out, _ = util.SafeSetRawJSON(out, argsPath, []byte(parseArgsToObjectRaw(functionArgs)))
 						partIndex++
 					}
 					return true
@@ -598,11 +598,11 @@ func ConvertOpenAIResponseToGeminiNonStream(_ context.Context, _ string, origina
 			// Handle finish reason
 			if finishReason := choice.Get("finish_reason"); finishReason.Exists() {
 				geminiFinishReason := mapOpenAIFinishReasonToGemini(finishReason.String())
-				out, _ = sjson.SetBytes(out, "candidates.0.finishReason", geminiFinishReason)
+				out, _ = util.SafeSetJSON(out, "candidates.0.finishReason", geminiFinishReason)
 			}
 
 			// Set index
-			out, _ = sjson.SetBytes(out, "candidates.0.index", choiceIdx)
+			out, _ = util.SafeSetJSON(out, "candidates.0.index", choiceIdx)
 
 			return true
 		})
@@ -610,11 +610,11 @@ func ConvertOpenAIResponseToGeminiNonStream(_ context.Context, _ string, origina
 
 	// Handle usage information
 	if usage := root.Get("usage"); usage.Exists() {
-		out, _ = sjson.SetBytes(out, "usageMetadata.promptTokenCount", usage.Get("prompt_tokens").Int())
-		out, _ = sjson.SetBytes(out, "usageMetadata.candidatesTokenCount", usage.Get("completion_tokens").Int())
-		out, _ = sjson.SetBytes(out, "usageMetadata.totalTokenCount", usage.Get("total_tokens").Int())
+		out, _ = util.SafeSetJSON(out, "usageMetadata.promptTokenCount", usage.Get("prompt_tokens").Int())
+		out, _ = util.SafeSetJSON(out, "usageMetadata.candidatesTokenCount", usage.Get("completion_tokens").Int())
+		out, _ = util.SafeSetJSON(out, "usageMetadata.totalTokenCount", usage.Get("total_tokens").Int())
 		if reasoningTokens := reasoningTokensFromUsage(usage); reasoningTokens > 0 {
-			out, _ = sjson.SetBytes(out, "usageMetadata.thoughtsTokenCount", reasoningTokens)
+			out, _ = util.SafeSetJSON(out, "usageMetadata.thoughtsTokenCount", reasoningTokens)
 		}
 	}
 
@@ -3,7 +3,7 @@
 package chat_completions
 
 import (
-	"github.com/tidwall/sjson"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 )
 
 // ConvertOpenAIRequestToOpenAI converts an OpenAI Chat Completions request (raw JSON)
@@ -18,8 +18,8 @@ import (
 //   - []byte: The transformed request data in Gemini CLI API format
 func ConvertOpenAIRequestToOpenAI(modelName string, inputRawJSON []byte, _ bool) []byte {
 	// Update the "model" field in the JSON payload with the provided modelName
-	// The sjson.SetBytes function returns a new byte slice with the updated JSON.
-	updatedJSON, err := sjson.SetBytes(inputRawJSON, "model", modelName)
+	// util.SafeSetJSON returns a new byte slice with the updated JSON.
+	updatedJSON, err := util.SafeSetJSON(inputRawJSON, "model", modelName)
 	if err != nil {
 		// If there's an error, return the original JSON or handle the error appropriately.
 		// For now, we'll return the original, but in a real scenario, logging or a more robust error
@@ -3,8 +3,8 @@ package responses
 import (
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 // ConvertOpenAIResponsesRequestToOpenAIChatCompletions converts OpenAI responses format to OpenAI chat completions format.
@@ -34,25 +34,25 @@ func ConvertOpenAIResponsesRequestToOpenAIChatCompletions(modelName string, inpu
 	root := gjson.ParseBytes(rawJSON)
 
 	// Set model name
-	out, _ = sjson.SetBytes(out, "model", modelName)
+	out, _ = util.SafeSetJSON(out, "model", modelName)
 
 	// Set stream configuration
-	out, _ = sjson.SetBytes(out, "stream", stream)
+	out, _ = util.SafeSetJSON(out, "stream", stream)
 
 	// Map generation parameters from responses format to chat completions format
 	if maxTokens := root.Get("max_output_tokens"); maxTokens.Exists() {
-		out, _ = sjson.SetBytes(out, "max_tokens", maxTokens.Int())
+		out, _ = util.SafeSetJSON(out, "max_tokens", maxTokens.Int())
 	}
 
 	if parallelToolCalls := root.Get("parallel_tool_calls"); parallelToolCalls.Exists() {
-		out, _ = sjson.SetBytes(out, "parallel_tool_calls", parallelToolCalls.Bool())
+		out, _ = util.SafeSetJSON(out, "parallel_tool_calls", parallelToolCalls.Bool())
 	}
 
 	// Convert instructions to system message
 	if instructions := root.Get("instructions"); instructions.Exists() {
 		systemMessage := []byte(`{"role":"system","content":""}`)
-		systemMessage, _ = sjson.SetBytes(systemMessage, "content", instructions.String())
-		out, _ = sjson.SetRawBytes(out, "messages.-1", systemMessage)
+		systemMessage, _ = util.SafeSetJSON(systemMessage, "content", instructions.String())
+		out, _ = util.SafeSetRawJSON(out, "messages.-1", systemMessage)
 	}
 
 	// Convert input array to messages
@@ -71,7 +71,7 @@ func ConvertOpenAIResponsesRequestToOpenAIChatCompletions(modelName string, inpu
 					role = "user"
 				}
 				message := []byte(`{"role":"","content":[]}`)
-				message, _ = sjson.SetBytes(message, "role", role)
+				message, _ = util.SafeSetJSON(message, "role", role)
 
 				if content := item.Get("content"); content.Exists() && content.IsArray() {
 					var messageContent string
@@ -87,29 +87,29 @@ func ConvertOpenAIResponsesRequestToOpenAIChatCompletions(modelName string, inpu
 						case "input_text", "output_text":
 							text := contentItem.Get("text").String()
 							contentPart := []byte(`{"type":"text","text":""}`)
-							contentPart, _ = sjson.SetBytes(contentPart, "text", text)
-							message, _ = sjson.SetRawBytes(message, "content.-1", contentPart)
+							contentPart, _ = util.SafeSetJSON(contentPart, "text", text)
+							message, _ = util.SafeSetRawJSON(message, "content.-1", contentPart)
 						case "input_image":
 							imageURL := contentItem.Get("image_url").String()
 							contentPart := []byte(`{"type":"image_url","image_url":{"url":""}}`)
-							contentPart, _ = sjson.SetBytes(contentPart, "image_url.url", imageURL)
-							message, _ = sjson.SetRawBytes(message, "content.-1", contentPart)
+							contentPart, _ = util.SafeSetJSON(contentPart, "image_url.url", imageURL)
+							message, _ = util.SafeSetRawJSON(message, "content.-1", contentPart)
 						}
 						return true
 					})
 
 					if messageContent != "" {
-						message, _ = sjson.SetBytes(message, "content", messageContent)
+						message, _ = util.SafeSetJSON(message, "content", messageContent)
 					}
 
 					if len(toolCalls) > 0 {
-						message, _ = sjson.SetBytes(message, "tool_calls", toolCalls)
+						message, _ = util.SafeSetJSON(message, "tool_calls", toolCalls)
 					}
 				} else if content.Type == gjson.String {
-					message, _ = sjson.SetBytes(message, "content", content.String())
+					message, _ = util.SafeSetJSON(message, "content", content.String())
 				}
 
-				out, _ = sjson.SetRawBytes(out, "messages.-1", message)
+				out, _ = util.SafeSetRawJSON(out, "messages.-1", message)
 
 			case "function_call":
 				// Handle function call conversion to assistant message with tool_calls
@@ -118,42 +118,42 @@ func ConvertOpenAIResponsesRequestToOpenAIChatCompletions(modelName string, inpu
 				toolCall := []byte(`{"id":"","type":"function","function":{"name":"","arguments":""}}`)
 
 				if callId := item.Get("call_id"); callId.Exists() {
-					toolCall, _ = sjson.SetBytes(toolCall, "id", callId.String())
+					toolCall, _ = util.SafeSetJSON(toolCall, "id", callId.String())
 				}
 
 				if name := item.Get("name"); name.Exists() {
-					toolCall, _ = sjson.SetBytes(toolCall, "function.name", name.String())
+					toolCall, _ = util.SafeSetJSON(toolCall, "function.name", name.String())
 				}
 
 				if arguments := item.Get("arguments"); arguments.Exists() {
-					toolCall, _ = sjson.SetBytes(toolCall, "function.arguments", arguments.String())
+					toolCall, _ = util.SafeSetJSON(toolCall, "function.arguments", arguments.String())
 				}
 
-				assistantMessage, _ = sjson.SetRawBytes(assistantMessage, "tool_calls.0", toolCall)
-				out, _ = sjson.SetRawBytes(out, "messages.-1", assistantMessage)
+				assistantMessage, _ = util.SafeSetRawJSON(assistantMessage, "tool_calls.0", toolCall)
+				out, _ = util.SafeSetRawJSON(out, "messages.-1", assistantMessage)
 
 			case "function_call_output":
 				// Handle function call output conversion to tool message
 				toolMessage := []byte(`{"role":"tool","tool_call_id":"","content":""}`)
 
 				if callId := item.Get("call_id"); callId.Exists() {
-					toolMessage, _ = sjson.SetBytes(toolMessage, "tool_call_id", callId.String())
+					toolMessage, _ = util.SafeSetJSON(toolMessage, "tool_call_id", callId.String())
 				}
 
 				if output := item.Get("output"); output.Exists() {
-					toolMessage, _ = sjson.SetBytes(toolMessage, "content", output.String())
+					toolMessage, _ = util.SafeSetJSON(toolMessage, "content", output.String())
 				}
 
-				out, _ = sjson.SetRawBytes(out, "messages.-1", toolMessage)
+				out, _ = util.SafeSetRawJSON(out, "messages.-1", toolMessage)
 			}
 
 			return true
 		})
 	} else if input.Type == gjson.String {
 		msg := []byte(`{}`)
-		msg, _ = sjson.SetBytes(msg, "role", "user")
-		msg, _ = sjson.SetBytes(msg, "content", input.String())
-		out, _ = sjson.SetRawBytes(out, "messages.-1", msg)
+		msg, _ = util.SafeSetJSON(msg, "role", "user")
+		msg, _ = util.SafeSetJSON(msg, "content", input.String())
+		out, _ = util.SafeSetRawJSON(out, "messages.-1", msg)
 	}
 
 	// Convert tools from responses format to chat completions format
@@ -176,38 +176,38 @@ func ConvertOpenAIResponsesRequestToOpenAIChatCompletions(modelName string, inpu
 			function := []byte(`{"name":"","description":"","parameters":{}}`)
 
 			if name := tool.Get("name"); name.Exists() {
-				function, _ = sjson.SetBytes(function, "name", name.String())
+				function, _ = util.SafeSetJSON(function, "name", name.String())
 			}
 
 			if description := tool.Get("description"); description.Exists() {
-				function, _ = sjson.SetBytes(function, "description", description.String())
+				function, _ = util.SafeSetJSON(function, "description", description.String())
 			}
 
 			if parameters := tool.Get("parameters"); parameters.Exists() {
-				function, _ = sjson.SetRawBytes(function, "parameters", []byte(parameters.Raw))
+				function, _ = util.SafeSetRawJSON(function, "parameters", []byte(parameters.Raw))
 			}
 
-			chatTool, _ = sjson.SetRawBytes(chatTool, "function", function)
+			chatTool, _ = util.SafeSetRawJSON(chatTool, "function", function)
 			chatCompletionsTools = append(chatCompletionsTools, gjson.ParseBytes(chatTool).Value())
 
 			return true
 		})
 
 		if len(chatCompletionsTools) > 0 {
-			out, _ = sjson.SetBytes(out, "tools", chatCompletionsTools)
+			out, _ = util.SafeSetJSON(out, "tools", chatCompletionsTools)
 		}
 	}
 
 	if reasoningEffort := root.Get("reasoning.effort"); reasoningEffort.Exists() {
 		effort := strings.ToLower(strings.TrimSpace(reasoningEffort.String()))
 		if effort != "" {
-			out, _ = sjson.SetBytes(out, "reasoning_effort", effort)
+			out, _ = util.SafeSetJSON(out, "reasoning_effort", effort)
 		}
 	}
 
 	// Convert tool_choice if present
 	if toolChoice := root.Get("tool_choice"); toolChoice.Exists() {
-		out, _ = sjson.SetBytes(out, "tool_choice", toolChoice.String())
+		out, _ = util.SafeSetJSON(out, "tool_choice", toolChoice.String())
 	}
 
 	return out
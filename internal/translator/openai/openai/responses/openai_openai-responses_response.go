@@ -10,8 +10,8 @@ import (
 	"time"
 
 	translatorcommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/common"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 type oaiToResponsesStateReasoning struct {
@@ -64,71 +64,71 @@ func emitRespEvent(event string, payload []byte) []byte {
 
 func buildResponsesCompletedEvent(st *oaiToResponsesState, requestRawJSON []byte, nextSeq func() int) []byte {
 	completed := []byte(`{"type":"response.completed","sequence_number":0,"response":{"id":"","object":"response","created_at":0,"status":"completed","background":false,"error":null}}`)
-	completed, _ = sjson.SetBytes(completed, "sequence_number", nextSeq())
-	completed, _ = sjson.SetBytes(completed, "response.id", st.ResponseID)
-	completed, _ = sjson.SetBytes(completed, "response.created_at", st.Created)
+	completed, _ = util.SafeSetJSON(completed, "sequence_number", nextSeq())
+	completed, _ = util.SafeSetJSON(completed, "response.id", st.ResponseID)
+	completed, _ = util.SafeSetJSON(completed, "response.created_at", st.Created)
 	// Inject original request fields into response as per docs/response.completed.json
 	if requestRawJSON != nil {
 		req := gjson.ParseBytes(requestRawJSON)
 		if v := req.Get("instructions"); v.Exists() {
-			completed, _ = sjson.SetBytes(completed, "response.instructions", v.String())
+			completed, _ = util.SafeSetJSON(completed, "response.instructions", v.String())
 		}
 		if v := req.Get("max_output_tokens"); v.Exists() {
-			completed, _ = sjson.SetBytes(completed, "response.max_output_tokens", v.Int())
+			completed, _ = util.SafeSetJSON(completed, "response.max_output_tokens", v.Int())
 		}
 		if v := req.Get("max_tool_calls"); v.Exists() {
-			completed, _ = sjson.SetBytes(completed, "response.max_tool_calls", v.Int())
+			completed, _ = util.SafeSetJSON(completed, "response.max_tool_calls", v.Int())
 		}
 		if v := req.Get("model"); v.Exists() {
-			completed, _ = sjson.SetBytes(completed, "response.model", v.String())
+			completed, _ = util.SafeSetJSON(completed, "response.model", v.String())
 		}
 		if v := req.Get("parallel_tool_calls"); v.Exists() {
-			completed, _ = sjson.SetBytes(completed, "response.parallel_tool_calls", v.Bool())
+			completed, _ = util.SafeSetJSON(completed, "response.parallel_tool_calls", v.Bool())
 		}
 		if v := req.Get("previous_response_id"); v.Exists() {
-			completed, _ = sjson.SetBytes(completed, "response.previous_response_id", v.String())
+			completed, _ = util.SafeSetJSON(completed, "response.previous_response_id", v.String())
 		}
 		if v := req.Get("prompt_cache_key"); v.Exists() {
-			completed, _ = sjson.SetBytes(completed, "response.prompt_cache_key", v.String())
+			completed, _ = util.SafeSetJSON(completed, "response.prompt_cache_key", v.String())
 		}
 		if v := req.Get("reasoning"); v.Exists() {
-			completed, _ = sjson.SetBytes(completed, "response.reasoning", v.Value())
+			completed, _ = util.SafeSetJSON(completed, "response.reasoning", v.Value())
 		}
 		if v := req.Get("safety_identifier"); v.Exists() {
-			completed, _ = sjson.SetBytes(completed, "response.safety_identifier", v.String())
+			completed, _ = util.SafeSetJSON(completed, "response.safety_identifier", v.String())
 		}
 		if v := req.Get("service_tier"); v.Exists() {
-			completed, _ = sjson.SetBytes(completed, "response.service_tier", v.String())
+			completed, _ = util.SafeSetJSON(completed, "response.service_tier", v.String())
 		}
 		if v := req.Get("store"); v.Exists() {
-			completed, _ = sjson.SetBytes(completed, "response.store", v.Bool())
+			completed, _ = util.SafeSetJSON(completed, "response.store", v.Bool())
 		}
 		if v := req.Get("temperature"); v.Exists() {
-			completed, _ = sjson.SetBytes(completed, "response.temperature", v.Float())
+			completed, _ = util.SafeSetJSON(completed, "response.temperature", v.Float())
 		}
 		if v := req.Get("text"); v.Exists() {
-			completed, _ = sjson.SetBytes(completed, "response.text", v.Value())
+			completed, _ = util.SafeSetJSON(completed, "response.text", v.Value())
 		}
 		if v := req.Get("tool_choice"); v.Exists() {
-			completed, _ = sjson.SetBytes(completed, "response.tool_choice", v.Value())
+			completed, _ = util.SafeSetJSON(completed, "response.tool_choice", v.Value())
 		}
 		if v := req.Get("tools"); v.Exists() {
-			completed, _ = sjson.SetBytes(completed, "response.tools", v.Value())
+			completed, _ = util.SafeSetJSON(completed, "response.tools", v.Value())
 		}
 		if v := req.Get("top_logprobs"); v.Exists() {
-			completed, _ = sjson.SetBytes(completed, "response.top_logprobs", v.Int())
+			completed, _ = util.SafeSetJSON(completed, "response.top_logprobs", v.Int())
 		}
 		if v := req.Get("top_p"); v.Exists() {
-			completed, _ = sjson.SetBytes(completed, "response.top_p", v.Float())
+			completed, _ = util.SafeSetJSON(completed, "response.top_p", v.Float())
 		}
 		if v := req.Get("truncation"); v.Exists() {
-			completed, _ = sjson.SetBytes(completed, "response.truncation", v.String())
+			completed, _ = util.SafeSetJSON(completed, "response.truncation", v.String())
 		}
 		if v := req.Get("user"); v.Exists() {
-			completed, _ = sjson.SetBytes(completed, "response.user", v.Value())
+			completed, _ = util.SafeSetJSON(completed, "response.user", v.Value())
 		}
 		if v := req.Get("metadata"); v.Exists() {
-			completed, _ = sjson.SetBytes(completed, "response.metadata", v.Value())
+			completed, _ = util.SafeSetJSON(completed, "response.metadata", v.Value())
 		}
 	}
 
@@ -141,8 +141,8 @@ func buildResponsesCompletedEvent(st *oaiToResponsesState, requestRawJSON []byte
 	if len(st.Reasonings) > 0 {
 		for _, r := range st.Reasonings {
 			item := []byte(`{"id":"","type":"reasoning","summary":[{"type":"summary_text","text":""}]}`)
-			item, _ = sjson.SetBytes(item, "id", r.ReasoningID)
-			item, _ = sjson.SetBytes(item, "summary.0.text", r.ReasoningData)
+			item, _ = util.SafeSetJSON(item, "id", r.ReasoningID)
+			item, _ = util.SafeSetJSON(item, "summary.0.text", r.ReasoningData)
 			outputItems = append(outputItems, completedOutputItem{index: r.OutputIndex, raw: item})
 		}
 	}
@@ -153,8 +153,8 @@ func buildResponsesCompletedEvent(st *oaiToResponsesState, requestRawJSON []byte
 				txt = b.String()
 			}
 			item := []byte(`{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}`)
-			item, _ = sjson.SetBytes(item, "id", fmt.Sprintf("msg_%s_%d", st.ResponseID, i))
-			item, _ = sjson.SetBytes(item, "content.0.text", txt)
+			item, _ = util.SafeSetJSON(item, "id", fmt.Sprintf("msg_%s_%d", st.ResponseID, i))
+			item, _ = util.SafeSetJSON(item, "content.0.text", txt)
 			outputItems = append(outputItems, completedOutputItem{index: st.MsgOutputIx[i], raw: item})
 		}
 	}
@@ -167,32 +167,32 @@ func buildResponsesCompletedEvent(st *oaiToResponsesState, requestRawJSON []byte
 			callID := st.FuncCallIDs[key]
 			name := st.FuncNames[key]
 			item := []byte(`{"id":"","type":"function_call","status":"completed","arguments":"","call_id":"","name":""}`)
-			item, _ = sjson.SetBytes(item, "id", fmt.Sprintf("fc_%s", callID))
-			item, _ = sjson.SetBytes(item, "arguments", args)
-			item, _ = sjson.SetBytes(item, "call_id", callID)
-			item, _ = sjson.SetBytes(item, "name", name)
+			item, _ = util.SafeSetJSON(item, "id", fmt.Sprintf("fc_%s", callID))
+			item, _ = util.SafeSetJSON(item, "arguments", args)
+			item, _ = util.SafeSetJSON(item, "call_id", callID)
+			item, _ = util.SafeSetJSON(item, "name", name)
 			outputItems = append(outputItems, completedOutputItem{index: st.FuncOutputIx[key], raw: item})
 		}
 	}
 	sort.Slice(outputItems, func(i, j int) bool { return outputItems[i].index < outputItems[j].index })
 	for _, item := range outputItems {
-		outputsWrapper, _ = sjson.SetRawBytes(outputsWrapper, "arr.-1", item.raw)
+		outputsWrapper, _ = util.SafeSetRawJSON(outputsWrapper, "arr.-1", item.raw)
 	}
 	if gjson.GetBytes(outputsWrapper, "arr.#").Int() > 0 {
-		completed, _ = sjson.SetRawBytes(completed, "response.output", []byte(gjson.GetBytes(outputsWrapper, "arr").Raw))
+		completed, _ = util.SafeSetRawJSON(completed, "response.output", []byte(gjson.GetBytes(outputsWrapper, "arr").Raw))
 	}
 	if st.UsageSeen {
-		completed, _ = sjson.SetBytes(completed, "response.usage.input_tokens", st.PromptTokens)
-		completed, _ = sjson.SetBytes(completed, "response.usage.input_tokens_details.cached_tokens", st.CachedTokens)
-		completed, _ = sjson.SetBytes(completed, "response.usage.output_tokens", st.CompletionTokens)
+		completed, _ = util.SafeSetJSON(completed, "response.usage.input_tokens", st.PromptTokens)
+		completed, _ = util.SafeSetJSON(completed, "response.usage.input_tokens_details.cached_tokens", st.CachedTokens)
+		completed, _ = util.SafeSetJSON(completed, "response.usage.output_tokens", st.CompletionTokens)
 		if st.ReasoningTokens > 0 {
-			completed, _ = sjson.SetBytes(completed, "response.usage.output_tokens_details.reasoning_tokens", st.ReasoningTokens)
+			completed, _ = util.SafeSetJSON(completed, "response.usage.output_tokens_details.reasoning_tokens", st.ReasoningTokens)
 		}
 		total := st.TotalTokens
 		if total == 0 {
 			total = st.PromptTokens + st.CompletionTokens
 		}
-		completed, _ = sjson.SetBytes(completed, "response.usage.total_tokens", total)
+		completed, _ = util.SafeSetJSON(completed, "response.usage.total_tokens", total)
 	}
 	return emitRespEvent("response.completed", completed)
 }
@@ -310,15 +310,15 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 		st.CompletedEmitted = false
 		// response.created
 		created := []byte(`{"type":"response.created","sequence_number":0,"response":{"id":"","object":"response","created_at":0,"status":"in_progress","background":false,"error":null,"output":[]}}`)
-		created, _ = sjson.SetBytes(created, "sequence_number", nextSeq())
-		created, _ = sjson.SetBytes(created, "response.id", st.ResponseID)
-		created, _ = sjson.SetBytes(created, "response.created_at", st.Created)
+		created, _ = util.SafeSetJSON(created, "sequence_number", nextSeq())
+		created, _ = util.SafeSetJSON(created, "response.id", st.ResponseID)
+		created, _ = util.SafeSetJSON(created, "response.created_at", st.Created)
 		out = append(out, emitRespEvent("response.created", created))
 
 		inprog := []byte(`{"type":"response.in_progress","sequence_number":0,"response":{"id":"","object":"response","created_at":0,"status":"in_progress"}}`)
-		inprog, _ = sjson.SetBytes(inprog, "sequence_number", nextSeq())
-		inprog, _ = sjson.SetBytes(inprog, "response.id", st.ResponseID)
-		inprog, _ = sjson.SetBytes(inprog, "response.created_at", st.Created)
+		inprog, _ = util.SafeSetJSON(inprog, "sequence_number", nextSeq())
+		inprog, _ = util.SafeSetJSON(inprog, "response.id", st.ResponseID)
+		inprog, _ = util.SafeSetJSON(inprog, "response.created_at", st.Created)
 		out = append(out, emitRespEvent("response.in_progress", inprog))
 		st.Started = true
 	}
@@ -326,22 +326,22 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 	stopReasoning := func(text string) {
 		// Emit reasoning done events
 		textDone := []byte(`{"type":"response.reasoning_summary_text.done","sequence_number":0,"item_id":"","output_index":0,"summary_index":0,"text":""}`)
-		textDone, _ = sjson.SetBytes(textDone, "sequence_number", nextSeq())
-		textDone, _ = sjson.SetBytes(textDone, "item_id", st.ReasoningID)
-		textDone, _ = sjson.SetBytes(textDone, "output_index", st.ReasoningIndex)
-		textDone, _ = sjson.SetBytes(textDone, "text", text)
+		textDone, _ = util.SafeSetJSON(textDone, "sequence_number", nextSeq())
+		textDone, _ = util.SafeSetJSON(textDone, "item_id", st.ReasoningID)
+		textDone, _ = util.SafeSetJSON(textDone, "output_index", st.ReasoningIndex)
+		textDone, _ = util.SafeSetJSON(textDone, "text", text)
 		out = append(out, emitRespEvent("response.reasoning_summary_text.done", textDone))
 		partDone := []byte(`{"type":"response.reasoning_summary_part.done","sequence_number":0,"item_id":"","output_index":0,"summary_index":0,"part":{"type":"summary_text","text":""}}`)
-		partDone, _ = sjson.SetBytes(partDone, "sequence_number", nextSeq())
-		partDone, _ = sjson.SetBytes(partDone, "item_id", st.ReasoningID)
-		partDone, _ = sjson.SetBytes(partDone, "output_index", st.ReasoningIndex)
-		partDone, _ = sjson.SetBytes(partDone, "part.text", text)
+		partDone, _ = util.SafeSetJSON(partDone, "sequence_number", nextSeq())
+		partDone, _ = util.SafeSetJSON(partDone, "item_id", st.ReasoningID)
+		partDone, _ = util.SafeSetJSON(partDone, "output_index", st.ReasoningIndex)
+		partDone, _ = util.SafeSetJSON(partDone, "part.text", text)
 		out = append(out, emitRespEvent("response.reasoning_summary_part.done", partDone))
 		outputItemDone := []byte(`{"type":"response.output_item.done","item":{"id":"","type":"reasoning","encrypted_content":"","summary":[{"type":"summary_text","text":""}]},"output_index":0,"sequence_number":0}`)
-		outputItemDone, _ = sjson.SetBytes(outputItemDone, "sequence_number", nextSeq())
-		outputItemDone, _ = sjson.SetBytes(outputItemDone, "item.id", st.ReasoningID)
-		outputItemDone, _ = sjson.SetBytes(outputItemDone, "output_index", st.ReasoningIndex)
-		outputItemDone, _ = sjson.SetBytes(outputItemDone, "item.summary.text", text)
+		outputItemDone, _ = util.SafeSetJSON(outputItemDone, "sequence_number", nextSeq())
+		outputItemDone, _ = util.SafeSetJSON(outputItemDone, "item.id", st.ReasoningID)
+		outputItemDone, _ = util.SafeSetJSON(outputItemDone, "output_index", st.ReasoningIndex)
+		outputItemDone, _ = util.SafeSetJSON(outputItemDone, "item.summary.text", text)
 		out = append(out, emitRespEvent("response.output_item.done", outputItemDone))
 
 		st.Reasonings = append(st.Reasonings, oaiToResponsesStateReasoning{ReasoningID: st.ReasoningID, ReasoningData: text, OutputIndex: st.ReasoningIndex})
@@ -366,28 +366,28 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 					msgOutputIndex := st.MsgOutputIx[idx]
 					if !st.MsgItemAdded[idx] {
 						item := []byte(`{"type":"response.output_item.added","sequence_number":0,"output_index":0,"item":{"id":"","type":"message","status":"in_progress","content":[],"role":"assistant"}}`)
-						item, _ = sjson.SetBytes(item, "sequence_number", nextSeq())
-						item, _ = sjson.SetBytes(item, "output_index", msgOutputIndex)
-						item, _ = sjson.SetBytes(item, "item.id", fmt.Sprintf("msg_%s_%d", st.ResponseID, idx))
+						item, _ = util.SafeSetJSON(item, "sequence_number", nextSeq())
+						item, _ = util.SafeSetJSON(item, "output_index", msgOutputIndex)
+						item, _ = util.SafeSetJSON(item, "item.id", fmt.Sprintf("msg_%s_%d", st.ResponseID, idx))
 						out = append(out, emitRespEvent("response.output_item.added", item))
 						st.MsgItemAdded[idx] = true
 					}
 					if !st.MsgContentAdded[idx] {
 						part := []byte(`{"type":"response.content_part.added","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"part":{"type":"output_text","annotations":[],"logprobs":[],"text":""}}`)
-						part, _ = sjson.SetBytes(part, "sequence_number", nextSeq())
-						part, _ = sjson.SetBytes(part, "item_id", fmt.Sprintf("msg_%s_%d", st.ResponseID, idx))
-						part, _ = sjson.SetBytes(part, "output_index", msgOutputIndex)
-						part, _ = sjson.SetBytes(part, "content_index", 0)
+						part, _ = util.SafeSetJSON(part, "sequence_number", nextSeq())
+						part, _ = util.SafeSetJSON(part, "item_id", fmt.Sprintf("msg_%s_%d", st.ResponseID, idx))
+						part, _ = util.SafeSetJSON(part, "output_index", msgOutputIndex)
+						part, _ = util.SafeSetJSON(part, "content_index", 0)
 						out = append(out, emitRespEvent("response.content_part.added", part))
 						st.MsgContentAdded[idx] = true
 					}
 
 					msg := []byte(`{"type":"response.output_text.delta","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"delta":"","logprobs":[]}`)
-					msg, _ = sjson.SetBytes(msg, "sequence_number", nextSeq())
-					msg, _ = sjson.SetBytes(msg, "item_id", fmt.Sprintf("msg_%s_%d", st.ResponseID, idx))
-					msg, _ = sjson.SetBytes(msg, "output_index", msgOutputIndex)
-					msg, _ = sjson.SetBytes(msg, "content_index", 0)
-					msg, _ = sjson.SetBytes(msg, "delta", c.String())
+					msg, _ = util.SafeSetJSON(msg, "sequence_number", nextSeq())
+					msg, _ = util.SafeSetJSON(msg, "item_id", fmt.Sprintf("msg_%s_%d", st.ResponseID, idx))
+					msg, _ = util.SafeSetJSON(msg, "output_index", msgOutputIndex)
+					msg, _ = util.SafeSetJSON(msg, "content_index", 0)
+					msg, _ = util.SafeSetJSON(msg, "delta", c.String())
 					out = append(out, emitRespEvent("response.output_text.delta", msg))
 					// aggregate for response.output
 					if st.MsgTextBuf[idx] == nil {
@@ -403,23 +403,23 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 						st.ReasoningID = fmt.Sprintf("rs_%s_%d", st.ResponseID, idx)
 						st.ReasoningIndex = allocOutputIndex()
 						item := []byte(`{"type":"response.output_item.added","sequence_number":0,"output_index":0,"item":{"id":"","type":"reasoning","status":"in_progress","summary":[]}}`)
-						item, _ = sjson.SetBytes(item, "sequence_number", nextSeq())
-						item, _ = sjson.SetBytes(item, "output_index", st.ReasoningIndex)
-						item, _ = sjson.SetBytes(item, "item.id", st.ReasoningID)
+						item, _ = util.SafeSetJSON(item, "sequence_number", nextSeq())
+						item, _ = util.SafeSetJSON(item, "output_index", st.ReasoningIndex)
+						item, _ = util.SafeSetJSON(item, "item.id", st.ReasoningID)
 						out = append(out, emitRespEvent("response.output_item.added", item))
 						part := []byte(`{"type":"response.reasoning_summary_part.added","sequence_number":0,"item_id":"","output_index":0,"summary_index":0,"part":{"type":"summary_text","text":""}}`)
-						part, _ = sjson.SetBytes(part, "sequence_number", nextSeq())
-						part, _ = sjson.SetBytes(part, "item_id", st.ReasoningID)
-						part, _ = sjson.SetBytes(part, "output_index", st.ReasoningIndex)
+						part, _ = util.SafeSetJSON(part, "sequence_number", nextSeq())
+						part, _ = util.SafeSetJSON(part, "item_id", st.ReasoningID)
+						part, _ = util.SafeSetJSON(part, "output_index", st.ReasoningIndex)
 						out = append(out, emitRespEvent("response.reasoning_summary_part.added", part))
 					}
 					// Append incremental text to reasoning buffer
 					st.ReasoningBuf.WriteString(rc.String())
 					msg := []byte(`{"type":"response.reasoning_summary_text.delta","sequence_number":0,"item_id":"","output_index":0,"summary_index":0,"delta":""}`)
-					msg, _ = sjson.SetBytes(msg, "sequence_number", nextSeq())
-					msg, _ = sjson.SetBytes(msg, "item_id", st.ReasoningID)
-					msg, _ = sjson.SetBytes(msg, "output_index", st.ReasoningIndex)
-					msg, _ = sjson.SetBytes(msg, "delta", rc.String())
+					msg, _ = util.SafeSetJSON(msg, "sequence_number", nextSeq())
+					msg, _ = util.SafeSetJSON(msg, "item_id", st.ReasoningID)
+					msg, _ = util.SafeSetJSON(msg, "output_index", st.ReasoningIndex)
+					msg, _ = util.SafeSetJSON(msg, "delta", rc.String())
 					out = append(out, emitRespEvent("response.reasoning_summary_text.delta", msg))
 				}
 
@@ -438,26 +438,26 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 							fullText = b.String()
 						}
 						done := []byte(`{"type":"response.output_text.done","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"text":"","logprobs":[]}`)
-						done, _ = sjson.SetBytes(done, "sequence_number", nextSeq())
-						done, _ = sjson.SetBytes(done, "item_id", fmt.Sprintf("msg_%s_%d", st.ResponseID, idx))
-						done, _ = sjson.SetBytes(done, "output_index", msgOutputIndex)
-						done, _ = sjson.SetBytes(done, "content_index", 0)
-						done, _ = sjson.SetBytes(done, "text", fullText)
+						done, _ = util.SafeSetJSON(done, "sequence_number", nextSeq())
+						done, _ = util.SafeSetJSON(done, "item_id", fmt.Sprintf("msg_%s_%d", st.ResponseID, idx))
+						done, _ = util.SafeSetJSON(done, "output_index", msgOutputIndex)
+						done, _ = util.SafeSetJSON(done, "content_index", 0)
+						done, _ = util.SafeSetJSON(done, "text", fullText)
 						out = append(out, emitRespEvent("response.output_text.done", done))
 
 						partDone := []byte(`{"type":"response.content_part.done","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"part":{"type":"output_text","annotations":[],"logprobs":[],"text":""}}`)
-						partDone, _ = sjson.SetBytes(partDone, "sequence_number", nextSeq())
-						partDone, _ = sjson.SetBytes(partDone, "item_id", fmt.Sprintf("msg_%s_%d", st.ResponseID, idx))
-						partDone, _ = sjson.SetBytes(partDone, "output_index", msgOutputIndex)
-						partDone, _ = sjson.SetBytes(partDone, "content_index", 0)
-						partDone, _ = sjson.SetBytes(partDone, "part.text", fullText)
+						partDone, _ = util.SafeSetJSON(partDone, "sequence_number", nextSeq())
+						partDone, _ = util.SafeSetJSON(partDone, "item_id", fmt.Sprintf("msg_%s_%d", st.ResponseID, idx))
+						partDone, _ = util.SafeSetJSON(partDone, "output_index", msgOutputIndex)
+						partDone, _ = util.SafeSetJSON(partDone, "content_index", 0)
+						partDone, _ = util.SafeSetJSON(partDone, "part.text", fullText)
 						out = append(out, emitRespEvent("response.content_part.done", partDone))
 
 						itemDone := []byte(`{"type":"response.output_item.done","sequence_number":0,"output_index":0,"item":{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}}`)
-						itemDone, _ = sjson.SetBytes(itemDone, "sequence_number", nextSeq())
-						itemDone, _ = sjson.SetBytes(itemDone, "output_index", msgOutputIndex)
-						itemDone, _ = sjson.SetBytes(itemDone, "item.id", fmt.Sprintf("msg_%s_%d", st.ResponseID, idx))
-						itemDone, _ = sjson.SetBytes(itemDone, "item.content.0.text", fullText)
+						itemDone, _ = util.SafeSetJSON(itemDone, "sequence_number", nextSeq())
+						itemDone, _ = util.SafeSetJSON(itemDone, "output_index", msgOutputIndex)
+						itemDone, _ = util.SafeSetJSON(itemDone, "item.id", fmt.Sprintf("msg_%s_%d", st.ResponseID, idx))
+						itemDone, _ = util.SafeSetJSON(itemDone, "item.content.0.text", fullText)
 						out = append(out, emitRespEvent("response.output_item.done", itemDone))
 						st.MsgItemDone[idx] = true
 					}
@@ -484,11 +484,11 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 						if shouldEmitItem && effectiveCallID != "" {
 							outputIndex := st.FuncOutputIx[key]
 							o := []byte(`{"type":"response.output_item.added","sequence_number":0,"output_index":0,"item":{"id":"","type":"function_call","status":"in_progress","arguments":"","call_id":"","name":""}}`)
-							o, _ = sjson.SetBytes(o, "sequence_number", nextSeq())
-							o, _ = sjson.SetBytes(o, "output_index", outputIndex)
-							o, _ = sjson.SetBytes(o, "item.id", fmt.Sprintf("fc_%s", effectiveCallID))
-							o, _ = sjson.SetBytes(o, "item.call_id", effectiveCallID)
-							o, _ = sjson.SetBytes(o, "item.name", st.FuncNames[key])
+							o, _ = util.SafeSetJSON(o, "sequence_number", nextSeq())
+							o, _ = util.SafeSetJSON(o, "output_index", outputIndex)
+							o, _ = util.SafeSetJSON(o, "item.id", fmt.Sprintf("fc_%s", effectiveCallID))
+							o, _ = util.SafeSetJSON(o, "item.call_id", effectiveCallID)
+							o, _ = util.SafeSetJSON(o, "item.name", st.FuncNames[key])
 							out = append(out, emitRespEvent("response.output_item.added", o))
 						}
 
@@ -504,10 +504,10 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 							if refCallID != "" {
 								outputIndex := st.FuncOutputIx[key]
 								ad := []byte(`{"type":"response.function_call_arguments.delta","sequence_number":0,"item_id":"","output_index":0,"delta":""}`)
-								ad, _ = sjson.SetBytes(ad, "sequence_number", nextSeq())
-								ad, _ = sjson.SetBytes(ad, "item_id", fmt.Sprintf("fc_%s", refCallID))
-								ad, _ = sjson.SetBytes(ad, "output_index", outputIndex)
-								ad, _ = sjson.SetBytes(ad, "delta", args.String())
+								ad, _ = util.SafeSetJSON(ad, "sequence_number", nextSeq())
+								ad, _ = util.SafeSetJSON(ad, "item_id", fmt.Sprintf("fc_%s", refCallID))
+								ad, _ = util.SafeSetJSON(ad, "output_index", outputIndex)
+								ad, _ = util.SafeSetJSON(ad, "delta", args.String())
 								out = append(out, emitRespEvent("response.function_call_arguments.delta", ad))
 							}
 							st.FuncArgsBuf[key].WriteString(args.String())
@@ -537,26 +537,26 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 								fullText = b.String()
 							}
 							done := []byte(`{"type":"response.output_text.done","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"text":"","logprobs":[]}`)
-							done, _ = sjson.SetBytes(done, "sequence_number", nextSeq())
-							done, _ = sjson.SetBytes(done, "item_id", fmt.Sprintf("msg_%s_%d", st.ResponseID, i))
-							done, _ = sjson.SetBytes(done, "output_index", msgOutputIndex)
-							done, _ = sjson.SetBytes(done, "content_index", 0)
-							done, _ = sjson.SetBytes(done, "text", fullText)
+							done, _ = util.SafeSetJSON(done, "sequence_number", nextSeq())
+							done, _ = util.SafeSetJSON(done, "item_id", fmt.Sprintf("msg_%s_%d", st.ResponseID, i))
+							done, _ = util.SafeSetJSON(done, "output_index", msgOutputIndex)
+							done, _ = util.SafeSetJSON(done, "content_index", 0)
+							done, _ = util.SafeSetJSON(done, "text", fullText)
 							out = append(out, emitRespEvent("response.output_text.done", done))
 
 							partDone := []byte(`{"type":"response.content_part.done","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"part":{"type":"output_text","annotations":[],"logprobs":[],"text":""}}`)
-							partDone, _ = sjson.SetBytes(partDone, "sequence_number", nextSeq())
-							partDone, _ = sjson.SetBytes(partDone, "item_id", fmt.Sprintf("msg_%s_%d", st.ResponseID, i))
-							partDone, _ = sjson.SetBytes(partDone, "output_index", msgOutputIndex)
-							partDone, _ = sjson.SetBytes(partDone, "content_index", 0)
-							partDone, _ = sjson.SetBytes(partDone, "part.text", fullText)
+							partDone, _ = util.SafeSetJSON(partDone, "sequence_number", nextSeq())
+							partDone, _ = util.SafeSetJSON(partDone, "item_id", fmt.Sprintf("msg_%s_%d", st.ResponseID, i))
+							partDone, _ = util.SafeSetJSON(partDone, "output_index", msgOutputIndex)
+							partDone, _ = util.SafeSetJSON(partDone, "content_index", 0)
+							partDone, _ = util.SafeSetJSON(partDone, "part.text", fullText)
 							out = append(out, emitRespEvent("response.content_part.done", partDone))
 
 							itemDone := []byte(`{"type":"response.output_item.done","sequence_number":0,"output_index":0,"item":{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}}`)
-							itemDone, _ = sjson.SetBytes(itemDone, "sequence_number", nextSeq())
-							itemDone, _ = sjson.SetBytes(itemDone, "output_index", msgOutputIndex)
-							itemDone, _ = sjson.SetBytes(itemDone, "item.id", fmt.Sprintf("msg_%s_%d", st.ResponseID, i))
-							itemDone, _ = sjson.SetBytes(itemDone, "item.content.0.text", fullText)
+							itemDone, _ = util.SafeSetJSON(itemDone, "sequence_number", nextSeq())
+							itemDone, _ = util.SafeSetJSON(itemDone, "output_index", msgOutputIndex)
+							itemDone, _ = util.SafeSetJSON(itemDone, "item.id", fmt.Sprintf("msg_%s_%d", st.ResponseID, i))
+							itemDone, _ = util.SafeSetJSON(itemDone, "item.content.0.text", fullText)
 							out = append(out, emitRespEvent("response.output_item.done", itemDone))
 							st.MsgItemDone[i] = true
 						}
@@ -590,19 +590,19 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 							args = b.String()
 						}
 						fcDone := []byte(`{"type":"response.function_call_arguments.done","sequence_number":0,"item_id":"","output_index":0,"arguments":""}`)
-						fcDone, _ = sjson.SetBytes(fcDone, "sequence_number", nextSeq())
-						fcDone, _ = sjson.SetBytes(fcDone, "item_id", fmt.Sprintf("fc_%s", callID))
-						fcDone, _ = sjson.SetBytes(fcDone, "output_index", outputIndex)
-						fcDone, _ = sjson.SetBytes(fcDone, "arguments", args)
+						fcDone, _ = util.SafeSetJSON(fcDone, "sequence_number", nextSeq())
+						fcDone, _ = util.SafeSetJSON(fcDone, "item_id", fmt.Sprintf("fc_%s", callID))
+						fcDone, _ = util.SafeSetJSON(fcDone, "output_index", outputIndex)
+						fcDone, _ = util.SafeSetJSON(fcDone, "arguments", args)
 						out = append(out, emitRespEvent("response.function_call_arguments.done", fcDone))
 
 						itemDone := []byte(`{"type":"response.output_item.done","sequence_number":0,"output_index":0,"item":{"id":"","type":"function_call","status":"completed","arguments":"","call_id":"","name":""}}`)
-						itemDone, _ = sjson.SetBytes(itemDone, "sequence_number", nextSeq())
-						itemDone, _ = sjson.SetBytes(itemDone, "output_index", outputIndex)
-						itemDone, _ = sjson.SetBytes(itemDone, "item.id", fmt.Sprintf("fc_%s", callID))
-						itemDone, _ = sjson.SetBytes(itemDone, "item.arguments", args)
-						itemDone, _ = sjson.SetBytes(itemDone, "item.call_id", callID)
-						itemDone, _ = sjson.SetBytes(itemDone, "item.name", st.FuncNames[key])
+						itemDone, _ = util.SafeSetJSON(itemDone, "sequence_number", nextSeq())
+						itemDone, _ = util.SafeSetJSON(itemDone, "output_index", outputIndex)
+						itemDone, _ = util.SafeSetJSON(itemDone, "item.id", fmt.Sprintf("fc_%s", callID))
+						itemDone, _ = util.SafeSetJSON(itemDone, "item.arguments", args)
+						itemDone, _ = util.SafeSetJSON(itemDone, "item.call_id", callID)
+						itemDone, _ = util.SafeSetJSON(itemDone, "item.name", st.FuncNames[key])
 						out = append(out, emitRespEvent("response.output_item.done", itemDone))
 						st.FuncItemDone[key] = true
 						st.FuncArgsDone[key] = true
@@ -631,88 +631,88 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponsesNonStream(_ context.Co
 	if id == "" {
 		id = fmt.Sprintf("resp_%x_%d", time.Now().UnixNano(), atomic.AddUint64(&responseIDCounter, 1))
 	}
-	resp, _ = sjson.SetBytes(resp, "id", id)
+	resp, _ = util.SafeSetJSON(resp, "id", id)
 
 	// created_at: map from chat.completion created
 	created := root.Get("created").Int()
 	if created == 0 {
 		created = time.Now().Unix()
 	}
-	resp, _ = sjson.SetBytes(resp, "created_at", created)
+	resp, _ = util.SafeSetJSON(resp, "created_at", created)
 
 	// Echo request fields when available (aligns with streaming path behavior)
 	if len(requestRawJSON) > 0 {
 		req := gjson.ParseBytes(requestRawJSON)
 		if v := req.Get("instructions"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "instructions", v.String())
+			resp, _ = util.SafeSetJSON(resp, "instructions", v.String())
 		}
 		if v := req.Get("max_output_tokens"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "max_output_tokens", v.Int())
+			resp, _ = util.SafeSetJSON(resp, "max_output_tokens", v.Int())
 		} else {
 			// Also support max_tokens from chat completion style
 			if v = req.Get("max_tokens"); v.Exists() {
-				resp, _ = sjson.SetBytes(resp, "max_output_tokens", v.Int())
+				resp, _ = util.SafeSetJSON(resp, "max_output_tokens", v.Int())
 			}
 		}
 		if v := req.Get("max_tool_calls"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "max_tool_calls", v.Int())
+			resp, _ = util.SafeSetJSON(resp, "max_tool_calls", v.Int())
 		}
 		if v := req.Get("model"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "model", v.String())
+			resp, _ = util.SafeSetJSON(resp, "model", v.String())
 		} else if v = root.Get("model"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "model", v.String())
+			resp, _ = util.SafeSetJSON(resp, "model", v.String())
 		}
 		if v := req.Get("parallel_tool_calls"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "parallel_tool_calls", v.Bool())
+			resp, _ = util.SafeSetJSON(resp, "parallel_tool_calls", v.Bool())
 		}
 		if v := req.Get("previous_response_id"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "previous_response_id", v.String())
+			resp, _ = util.SafeSetJSON(resp, "previous_response_id", v.String())
 		}
 		if v := req.Get("prompt_cache_key"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "prompt_cache_key", v.String())
+			resp, _ = util.SafeSetJSON(resp, "prompt_cache_key", v.String())
 		}
 		if v := req.Get("reasoning"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "reasoning", v.Value())
+			resp, _ = util.SafeSetJSON(resp, "reasoning", v.Value())
 		}
 		if v := req.Get("safety_identifier"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "safety_identifier", v.String())
+			resp, _ = util.SafeSetJSON(resp, "safety_identifier", v.String())
 		}
 		if v := req.Get("service_tier"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "service_tier", v.String())
+			resp, _ = util.SafeSetJSON(resp, "service_tier", v.String())
 		}
 		if v := req.Get("store"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "store", v.Bool())
+			resp, _ = util.SafeSetJSON(resp, "store", v.Bool())
 		}
 		if v := req.Get("temperature"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "temperature", v.Float())
+			resp, _ = util.SafeSetJSON(resp, "temperature", v.Float())
 		}
 		if v := req.Get("text"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "text", v.Value())
+			resp, _ = util.SafeSetJSON(resp, "text", v.Value())
 		}
 		if v := req.Get("tool_choice"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "tool_choice", v.Value())
+			resp, _ = util.SafeSetJSON(resp, "tool_choice", v.Value())
 		}
 		if v := req.Get("tools"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "tools", v.Value())
+			resp, _ = util.SafeSetJSON(resp, "tools", v.Value())
 		}
 		if v := req.Get("top_logprobs"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "top_logprobs", v.Int())
+			resp, _ = util.SafeSetJSON(resp, "top_logprobs", v.Int())
 		}
 		if v := req.Get("top_p"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "top_p", v.Float())
+			resp, _ = util.SafeSetJSON(resp, "top_p", v.Float())
 		}
 		if v := req.Get("truncation"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "truncation", v.String())
+			resp, _ = util.SafeSetJSON(resp, "truncation", v.String())
 		}
 		if v := req.Get("user"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "user", v.Value())
+			resp, _ = util.SafeSetJSON(resp, "user", v.Value())
 		}
 		if v := req.Get("metadata"); v.Exists() {
-			resp, _ = sjson.SetBytes(resp, "metadata", v.Value())
+			resp, _ = util.SafeSetJSON(resp, "metadata", v.Value())
 		}
 	} else if v := root.Get("model"); v.Exists() {
 		// Fallback model from response
-		resp, _ = sjson.SetBytes(resp, "model", v.String())
+		resp, _ = util.SafeSetJSON(resp, "model", v.String())
 	}
 
 	// Build output list from choices[...]
@@ -730,12 +730,12 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponsesNonStream(_ context.Co
 		}
 		// Prefer summary_text from reasoning_content; encrypted_content is optional
 		reasoningItem := []byte(`{"id":"","type":"reasoning","encrypted_content":"","summary":[]}`)
-		reasoningItem, _ = sjson.SetBytes(reasoningItem, "id", fmt.Sprintf("rs_%s", rid))
+		reasoningItem, _ = util.SafeSetJSON(reasoningItem, "id", fmt.Sprintf("rs_%s", rid))
 		if rcText != "" {
-			reasoningItem, _ = sjson.SetBytes(reasoningItem, "summary.0.type", "summary_text")
-			reasoningItem, _ = sjson.SetBytes(reasoningItem, "summary.0.text", rcText)
+			reasoningItem, _ = util.SafeSetJSON(reasoningItem, "summary.0.type", "summary_text")
+			reasoningItem, _ = util.SafeSetJSON(reasoningItem, "summary.0.text", rcText)
 		}
-		outputsWrapper, _ = sjson.SetRawBytes(outputsWrapper, "arr.-1", reasoningItem)
+		outputsWrapper, _ = util.SafeSetRawJSON(outputsWrapper, "arr.-1", reasoningItem)
 	}
 
 	if choices := root.Get("choices"); choices.Exists() && choices.IsArray() {
@@ -745,9 +745,9 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponsesNonStream(_ context.Co
 				// Text message part
 				if c := msg.Get("content"); c.Exists() && c.String() != "" {
 					item := []byte(`{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}`)
-					item, _ = sjson.SetBytes(item, "id", fmt.Sprintf("msg_%s_%d", id, int(choice.Get("index").Int())))
-					item, _ = sjson.SetBytes(item, "content.0.text", c.String())
-					outputsWrapper, _ = sjson.SetRawBytes(outputsWrapper, "arr.-1", item)
+					item, _ = util.SafeSetJSON(item, "id", fmt.Sprintf("msg_%s_%d", id, int(choice.Get("index").Int())))
+					item, _ = util.SafeSetJSON(item, "content.0.text", c.String())
+					outputsWrapper, _ = util.SafeSetRawJSON(outputsWrapper, "arr.-1", item)
 				}
 
 				// Function/tool calls
@@ -757,11 +757,11 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponsesNonStream(_ context.Co
 						name := tc.Get("function.name").String()
 						args := tc.Get("function.arguments").String()
 						item := []byte(`{"id":"","type":"function_call","status":"completed","arguments":"","call_id":"","name":""}`)
-						item, _ = sjson.SetBytes(item, "id", fmt.Sprintf("fc_%s", callID))
-						item, _ = sjson.SetBytes(item, "arguments", args)
-						item, _ = sjson.SetBytes(item, "call_id", callID)
-						item, _ = sjson.SetBytes(item, "name", name)
-						outputsWrapper, _ = sjson.SetRawBytes(outputsWrapper, "arr.-1", item)
+						item, _ = util.SafeSetJSON(item, "id", fmt.Sprintf("fc_%s", callID))
+						item, _ = util.SafeSetJSON(item, "arguments", args)
+						item, _ = util.SafeSetJSON(item, "call_id", callID)
+						item, _ = util.SafeSetJSON(item, "name", name)
+						outputsWrapper, _ = util.SafeSetRawJSON(outputsWrapper, "arr.-1", item)
 						return true
 					})
 				}
@@ -770,26 +770,26 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponsesNonStream(_ context.Co
 		})
 	}
 	if gjson.GetBytes(outputsWrapper, "arr.#").Int() > 0 {
-		resp, _ = sjson.SetRawBytes(resp, "output", []byte(gjson.GetBytes(outputsWrapper, "arr").Raw))
+		resp, _ = util.SafeSetRawJSON(resp, "output", []byte(gjson.GetBytes(outputsWrapper, "arr").Raw))
 	}
 
 	// usage mapping
 	if usage := root.Get("usage"); usage.Exists() {
 		// Map common tokens
 		if usage.Get("prompt_tokens").Exists() || usage.Get("completion_tokens").Exists() || usage.Get("total_tokens").Exists() {
-			resp, _ = sjson.SetBytes(resp, "usage.input_tokens", usage.Get("prompt_tokens").Int())
+			resp, _ = util.SafeSetJSON(resp, "usage.input_tokens", usage.Get("prompt_tokens").Int())
 			if d := usage.Get("prompt_tokens_details.cached_tokens"); d.Exists() {
-				resp, _ = sjson.SetBytes(resp, "usage.input_tokens_details.cached_tokens", d.Int())
+				resp, _ = util.SafeSetJSON(resp, "usage.input_tokens_details.cached_tokens", d.Int())
 			}
-			resp, _ = sjson.SetBytes(resp, "usage.output_tokens", usage.Get("completion_tokens").Int())
+			resp, _ = util.SafeSetJSON(resp, "usage.output_tokens", usage.Get("completion_tokens").Int())
 			// Reasoning tokens not available in Chat Completions; set only if present under output_tokens_details
 			if d := usage.Get("output_tokens_details.reasoning_tokens"); d.Exists() {
-				resp, _ = sjson.SetBytes(resp, "usage.output_tokens_details.reasoning_tokens", d.Int())
+				resp, _ = util.SafeSetJSON(resp, "usage.output_tokens_details.reasoning_tokens", d.Int())
 			}
-			resp, _ = sjson.SetBytes(resp, "usage.total_tokens", usage.Get("total_tokens").Int())
+			resp, _ = util.SafeSetJSON(resp, "usage.total_tokens", usage.Get("total_tokens").Int())
 		} else {
 			// Fallback to raw usage object if structure differs
-			resp, _ = sjson.SetBytes(resp, "usage", usage.Value())
+			resp, _ = util.SafeSetJSON(resp, "usage", usage.Value())
 		}
 	}
 
@@ -0,0 +1,75 @@
+package util
+
+import (
+	"net/http"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// AuthClientOption configures the HTTP client of a provider auth client (Kiro,
+// Copilot). The provider packages re-export it as their ClientOption.
+type AuthClientOption func(*authClientOptions)
+
+type authClientOptions struct {
+	httpClient *http.Client
+	minTLS     uint16
+}
+
+// WithAuthHTTPClient uses client instead of building one from the proxy
+// configuration. The minimum TLS version is still enforced on a copy of the client.
+func WithAuthHTTPClient(client *http.Client) AuthClientOption {
+	return func(o *authClientOptions) {
+		o.httpClient = client
+	}
+}
+
+// WithAuthMinTLS sets the minimum TLS version (e.g. tls.VersionTLS13) for
+// outbound connections. The default is DefaultMinTLSVersion.
+func WithAuthMinTLS(version uint16) AuthClientOption {
+	return func(o *authClientOptions) {
+		o.minTLS = version
+	}
+}
+
+// NewAuthHTTPClient builds the HTTP client for a provider auth client: the client
+// from WithAuthHTTPClient, or NewProviderHTTPClient for provider with defaults,
+// with the minimum TLS version applied.
+func NewAuthHTTPClient(cfg *config.Config, provider string, defaults HTTPClientConfig, opts ...AuthClientOption) *http.Client {
+	o := authClientOptions{minTLS: DefaultMinTLSVersion}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	client := o.httpClient
+	if client == nil {
+		client = NewProviderHTTPClient(cfg, provider, HTTPClientConfig{}, defaults, false)
+	}
+	return SetMinTLS(client, o.minTLS)
+}
+
+// ProviderHTTPClientConfig returns the timeouts configured for provider under
+// provider-http. Unset fields are zero.
+func ProviderHTTPClientConfig(cfg *config.Config, provider string) HTTPClientConfig {
+	providerHTTP, ok := cfg.ProviderHTTPFor(provider)
+	if !ok {
+		return HTTPClientConfig{}
+	}
+	return HTTPClientConfig{
+		DialTimeout:           providerHTTP.ConnectTimeout,
+		TLSHandshakeTimeout:   providerHTTP.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: providerHTTP.ResponseHeaderTimeout,
+		TotalTimeout:          providerHTTP.TotalTimeout,
+	}
+}
+
+// NewProviderHTTPClient builds a proxy-aware HTTP client for provider that
+// requires DefaultMinTLSVersion. Each timeout is taken from timeouts, else from
+// the provider-http settings for provider, else from defaults.
+func NewProviderHTTPClient(cfg *config.Config, provider string, timeouts, defaults HTTPClientConfig, streaming bool) *http.Client {
+	timeouts = timeouts.WithDefaults(ProviderHTTPClientConfig(cfg, provider)).WithDefaults(defaults)
+	var sdkCfg *config.SDKConfig
+	if cfg != nil {
+		sdkCfg = &cfg.SDKConfig
+	}
+	client := NewConfiguredHTTPClient(sdkCfg, timeouts, streaming)
+	return SetMinTLS(client, DefaultMinTLSVersion)
+}
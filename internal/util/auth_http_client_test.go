@@ -0,0 +1,65 @@
+package util
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestNewProviderHTTPClientTimeoutPrecedence(t *testing.T) {
+	cfg := &config.Config{ProviderHTTP: map[string]config.ProviderHTTPConfig{
+		"Kiro": {TLSHandshakeTimeout: 4 * time.Second, ResponseHeaderTimeout: 5 * time.Second, TotalTimeout: 6 * time.Minute},
+	}}
+	defaults := HTTPClientConfig{
+		DialTimeout:           10 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+		TotalTimeout:          2 * time.Minute,
+	}
+
+	client := NewProviderHTTPClient(cfg, "kiro", HTTPClientConfig{ResponseHeaderTimeout: 7 * time.Second}, defaults, false)
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("transport = %T, want *http.Transport", client.Transport)
+	}
+	if transport.ResponseHeaderTimeout != 7*time.Second {
+		t.Errorf("ResponseHeaderTimeout = %v, want the explicit 7s", transport.ResponseHeaderTimeout)
+	}
+	if transport.TLSHandshakeTimeout != 4*time.Second {
+		t.Errorf("TLSHandshakeTimeout = %v, want the configured 4s", transport.TLSHandshakeTimeout)
+	}
+	if client.Timeout != 6*time.Minute {
+		t.Errorf("Timeout = %v, want the configured 6m", client.Timeout)
+	}
+	if transport.TLSClientConfig == nil || transport.TLSClientConfig.MinVersion != tls.VersionTLS12 {
+		t.Error("minimum TLS version not enforced")
+	}
+
+	other := NewProviderHTTPClient(cfg, "github-copilot", HTTPClientConfig{}, defaults, false)
+	if other.Timeout != defaults.TotalTimeout {
+		t.Errorf("unconfigured provider Timeout = %v, want default %v", other.Timeout, defaults.TotalTimeout)
+	}
+}
+
+func TestNewAuthHTTPClient(t *testing.T) {
+	custom := &http.Client{Timeout: time.Second}
+	client := NewAuthHTTPClient(nil, "kiro", HTTPClientConfig{TotalTimeout: 30 * time.Second}, WithAuthHTTPClient(custom), WithAuthMinTLS(tls.VersionTLS13))
+	if client.Timeout != time.Second {
+		t.Errorf("Timeout = %v, want the custom client's 1s", client.Timeout)
+	}
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok || transport.TLSClientConfig.MinVersion != tls.VersionTLS13 {
+		t.Errorf("custom client transport = %T, want TLS 1.3 minimum", client.Transport)
+	}
+
+	cfg := &config.Config{ProviderHTTP: map[string]config.ProviderHTTPConfig{"kiro": {TotalTimeout: time.Minute}}}
+	if got := NewAuthHTTPClient(cfg, "kiro", HTTPClientConfig{TotalTimeout: 30 * time.Second}).Timeout; got != time.Minute {
+		t.Errorf("configured Timeout = %v, want 1m", got)
+	}
+	if got := NewAuthHTTPClient(nil, "kiro", HTTPClientConfig{TotalTimeout: 30 * time.Second}).Timeout; got != 30*time.Second {
+		t.Errorf("default Timeout = %v, want 30s", got)
+	}
+}
@@ -0,0 +1,62 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tidwall/gjson"
+)
+
+// FieldPatch sets Path to Value in a JSON payload. When, if non-empty, is a gjson
+// path expression evaluated against the payload before any patch is applied;
+// the patch only applies when it yields a truthy value.
+type FieldPatch struct {
+	Path  string
+	Value any
+	When  string
+}
+
+// ApplyFieldPatches applies patches to rawJSON in order. Conditions are
+// evaluated against the original payload, so one patch cannot enable another.
+// A value is truthy unless it is missing, null, false, 0 or the empty string.
+// Invalid JSON, an empty Path or a failed write returns rawJSON unchanged and
+// an error.
+func ApplyFieldPatches(rawJSON []byte, patches []FieldPatch) ([]byte, error) {
+	if len(patches) == 0 {
+		return rawJSON, nil
+	}
+	if !gjson.ValidBytes(rawJSON) {
+		return rawJSON, errors.New("apply field patches: invalid JSON payload")
+	}
+
+	out := rawJSON
+	for i, patch := range patches {
+		if patch.Path == "" {
+			return rawJSON, fmt.Errorf("apply field patches: patch %d has an empty path", i)
+		}
+		if patch.When != "" && !isTruthyJSON(gjson.GetBytes(rawJSON, patch.When)) {
+			continue
+		}
+		updated, errSet := SafeSetJSON(out, patch.Path, patch.Value)
+		if errSet != nil {
+			return rawJSON, fmt.Errorf("apply field patches: patch %d: %w", i, errSet)
+		}
+		out = updated
+	}
+	return out, nil
+}
+
+func isTruthyJSON(value gjson.Result) bool {
+	switch value.Type {
+	case gjson.True:
+		return true
+	case gjson.Number:
+		return value.Num != 0
+	case gjson.String:
+		return value.Str != ""
+	case gjson.JSON:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,87 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestApplyFieldPatches_Unconditional(t *testing.T) {
+	out, err := ApplyFieldPatches([]byte(`{"model":"claude","max_tokens":10}`), []FieldPatch{
+		{Path: "anthropic_version", Value: "bedrock-2023-05-31"},
+		{Path: "max_tokens", Value: 1024},
+		{Path: "metadata.source", Value: "proxy"},
+	})
+	if err != nil {
+		t.Fatalf("ApplyFieldPatches: %v", err)
+	}
+	if got := gjson.GetBytes(out, "anthropic_version").String(); got != "bedrock-2023-05-31" {
+		t.Errorf("anthropic_version = %q", got)
+	}
+	if got := gjson.GetBytes(out, "max_tokens").Int(); got != 1024 {
+		t.Errorf("max_tokens = %d, want 1024", got)
+	}
+	if got := gjson.GetBytes(out, "metadata.source").String(); got != "proxy" {
+		t.Errorf("metadata.source = %q", got)
+	}
+	if got := gjson.GetBytes(out, "model").String(); got != "claude" {
+		t.Errorf("model = %q, want untouched", got)
+	}
+}
+
+func TestApplyFieldPatches_Conditional(t *testing.T) {
+	payload := []byte(`{"model":"claude-sonnet","stream":true,"zero":0,"empty":"","off":false,"none":null,"tools":[{"name":"a"}]}`)
+	tests := []struct {
+		name  string
+		when  string
+		apply bool
+	}{
+		{"true bool", "stream", true},
+		{"non-empty array", "tools", true},
+		{"query match", `tools.#(name=="a")`, true},
+		{"query miss", `tools.#(name=="b")`, false},
+		{"missing", "thinking", false},
+		{"zero", "zero", false},
+		{"empty string", "empty", false},
+		{"false", "off", false},
+		{"null", "none", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := ApplyFieldPatches(payload, []FieldPatch{{Path: "patched", Value: true, When: tt.when}})
+			if err != nil {
+				t.Fatalf("ApplyFieldPatches: %v", err)
+			}
+			if got := gjson.GetBytes(out, "patched").Exists(); got != tt.apply {
+				t.Errorf("patch applied = %v, want %v", got, tt.apply)
+			}
+		})
+	}
+}
+
+func TestApplyFieldPatches_ConditionsUseOriginalPayload(t *testing.T) {
+	out, err := ApplyFieldPatches([]byte(`{}`), []FieldPatch{
+		{Path: "first", Value: true},
+		{Path: "second", Value: true, When: "first"},
+	})
+	if err != nil {
+		t.Fatalf("ApplyFieldPatches: %v", err)
+	}
+	if gjson.GetBytes(out, "second").Exists() {
+		t.Errorf("second patch should not see the first patch's write: %s", out)
+	}
+}
+
+func TestApplyFieldPatches_Errors(t *testing.T) {
+	if _, err := ApplyFieldPatches([]byte(`{not json`), []FieldPatch{{Path: "a", Value: 1}}); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+	in := []byte(`{"a":1}`)
+	out, err := ApplyFieldPatches(in, []FieldPatch{{Path: "b", Value: 2}, {Path: "", Value: 3}})
+	if err == nil {
+		t.Fatal("expected an error for an empty path")
+	}
+	if string(out) != string(in) {
+		t.Errorf("payload changed on error: %s", out)
+	}
+}
@@ -0,0 +1,63 @@
+package util
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+)
+
+// EstimateGeminiRequestSize summarizes the content of a Gemini generateContent
+// request for local budget checks before it is sent. It counts the characters
+// of text parts in contents and systemInstruction, the number of image and
+// audio parts (inline or file data, by MIME type) and the number of function
+// calls. Requests wrapped in a Gemini CLI "request" envelope are also accepted.
+func EstimateGeminiRequestSize(rawJSON []byte) (textChars int, imageParts int, audioParts int, toolCalls int) {
+	root := gjson.ParseBytes(rawJSON)
+	if !root.Get("contents").Exists() && root.Get("request.contents").Exists() {
+		root = root.Get("request")
+	}
+
+	countPart := func(part gjson.Result) {
+		if text := part.Get("text"); text.Exists() {
+			textChars += utf8.RuneCountInString(text.String())
+		}
+		if part.Get("functionCall").Exists() {
+			toolCalls++
+		}
+		media := firstExisting(part, "inlineData", "inline_data", "fileData", "file_data")
+		if !media.Exists() {
+			return
+		}
+		mimeType := strings.ToLower(firstExisting(media, "mimeType", "mime_type").String())
+		switch {
+		case strings.HasPrefix(mimeType, "image/"):
+			imageParts++
+		case strings.HasPrefix(mimeType, "audio/"):
+			audioParts++
+		}
+	}
+
+	firstExisting(root, "systemInstruction", "system_instruction").Get("parts").ForEach(func(_, part gjson.Result) bool {
+		countPart(part)
+		return true
+	})
+	root.Get("contents").ForEach(func(_, content gjson.Result) bool {
+		content.Get("parts").ForEach(func(_, part gjson.Result) bool {
+			countPart(part)
+			return true
+		})
+		return true
+	})
+	return textChars, imageParts, audioParts, toolCalls
+}
+
+// firstExisting returns the first of paths that exists in result.
+func firstExisting(result gjson.Result, paths ...string) gjson.Result {
+	for _, path := range paths {
+		if value := result.Get(path); value.Exists() {
+			return value
+		}
+	}
+	return gjson.Result{}
+}
@@ -0,0 +1,54 @@
+package util
+
+import "testing"
+
+func TestEstimateGeminiRequestSize(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantText   int
+		wantImages int
+		wantAudio  int
+		wantCalls  int
+	}{
+		{
+			name:     "text and system instruction",
+			input:    `{"systemInstruction":{"parts":[{"text":"Be brief."}]},"contents":[{"role":"user","parts":[{"text":"hello"},{"text":"héllo"}]}]}`,
+			wantText: 9 + 5 + 5,
+		},
+		{
+			name:       "inline and file images",
+			input:      `{"contents":[{"role":"user","parts":[{"inlineData":{"mimeType":"image/png","data":"AAAA"}},{"file_data":{"mime_type":"image/jpeg","file_uri":"gs://b/x.jpg"}}]}]}`,
+			wantImages: 2,
+		},
+		{
+			name:      "audio",
+			input:     `{"contents":[{"role":"user","parts":[{"inline_data":{"mime_type":"audio/wav","data":"AAAA"}},{"inlineData":{"mimeType":"application/pdf","data":"AAAA"}}]}]}`,
+			wantAudio: 1,
+		},
+		{
+			name:      "function calls",
+			input:     `{"contents":[{"role":"model","parts":[{"functionCall":{"name":"a","args":{}}},{"functionCall":{"name":"b","args":{}}}]},{"role":"user","parts":[{"functionResponse":{"name":"a","response":{}}}]}]}`,
+			wantCalls: 2,
+		},
+		{
+			name:       "gemini cli envelope",
+			input:      `{"model":"gemini-2.5-pro","request":{"contents":[{"role":"user","parts":[{"text":"abc"},{"inlineData":{"mimeType":"image/webp","data":"AAAA"}}]}]}}`,
+			wantText:   3,
+			wantImages: 1,
+		},
+		{
+			name:  "empty",
+			input: `{}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, images, audio, calls := EstimateGeminiRequestSize([]byte(tt.input))
+			if text != tt.wantText || images != tt.wantImages || audio != tt.wantAudio || calls != tt.wantCalls {
+				t.Fatalf("EstimateGeminiRequestSize() = (%d, %d, %d, %d), want (%d, %d, %d, %d)",
+					text, images, audio, calls, tt.wantText, tt.wantImages, tt.wantAudio, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package util
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/proxyutil"
+	log "github.com/sirupsen/logrus"
+)
+
+// HTTPClientConfig holds the timeouts of a provider HTTP client. Zero fields
+// fall back to the provider's defaults.
+type HTTPClientConfig struct {
+	// DialTimeout bounds establishing the TCP connection.
+	DialTimeout time.Duration
+	// TLSHandshakeTimeout bounds the TLS handshake.
+	TLSHandshakeTimeout time.Duration
+	// ResponseHeaderTimeout bounds the wait for response headers after the
+	// request has been written.
+	ResponseHeaderTimeout time.Duration
+	// TotalTimeout bounds the whole exchange including reading the body. It is
+	// not applied by streaming clients.
+	TotalTimeout time.Duration
+}
+
+// WithDefaults returns c with every zero field taken from defaults.
+func (c HTTPClientConfig) WithDefaults(defaults HTTPClientConfig) HTTPClientConfig {
+	if c.DialTimeout <= 0 {
+		c.DialTimeout = defaults.DialTimeout
+	}
+	if c.TLSHandshakeTimeout <= 0 {
+		c.TLSHandshakeTimeout = defaults.TLSHandshakeTimeout
+	}
+	if c.ResponseHeaderTimeout <= 0 {
+		c.ResponseHeaderTimeout = defaults.ResponseHeaderTimeout
+	}
+	if c.TotalTimeout <= 0 {
+		c.TotalTimeout = defaults.TotalTimeout
+	}
+	return c
+}
+
+// NewConfiguredHTTPClient builds a proxy-aware HTTP client whose *http.Transport
+// honors timeouts. Streaming clients leave http.Client.Timeout unset so long
+// response bodies are not cut off; they are still bounded by the dial, TLS
+// handshake and response header timeouts.
+func NewConfiguredHTTPClient(cfg *config.SDKConfig, timeouts HTTPClientConfig, streaming bool) *http.Client {
+	var transport *http.Transport
+	if cfg != nil {
+		built, _, errBuild := proxyutil.BuildHTTPTransport(cfg.ProxyURL)
+		if errBuild != nil {
+			log.Errorf("%v", errBuild)
+		}
+		transport = built
+	}
+	if transport == nil {
+		if base, ok := http.DefaultTransport.(*http.Transport); ok {
+			transport = base.Clone()
+		} else {
+			transport = &http.Transport{Proxy: http.ProxyFromEnvironment}
+		}
+	}
+	applyTransportTimeouts(transport, timeouts)
+
+	client := &http.Client{Transport: transport}
+	if !streaming {
+		client.Timeout = timeouts.TotalTimeout
+	}
+	return client
+}
+
+func applyTransportTimeouts(transport *http.Transport, timeouts HTTPClientConfig) {
+	SetDialTimeout(transport, timeouts.DialTimeout)
+	transport.TLSHandshakeTimeout = timeouts.TLSHandshakeTimeout
+	transport.ResponseHeaderTimeout = timeouts.ResponseHeaderTimeout
+}
+
+// SetDialTimeout wraps transport's dialer so establishing a connection takes at
+// most timeout. Non-positive timeouts leave the transport unchanged.
+func SetDialTimeout(transport *http.Transport, timeout time.Duration) {
+	if transport == nil || timeout <= 0 {
+		return
+	}
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{KeepAlive: 30 * time.Second}).DialContext
+	}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return dial(dialCtx, network, addr)
+	}
+}
@@ -0,0 +1,95 @@
+package util
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newSlowBodyServer sends headers immediately and then writes chunks spread
+// over roughly total.
+func newSlowBodyServer(t *testing.T, chunks int, total time.Duration) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for i := 0; i < chunks; i++ {
+			time.Sleep(total / time.Duration(chunks))
+			_, _ = w.Write([]byte("data: chunk\n\n"))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestNewConfiguredHTTPClientSetsTransportTimeouts(t *testing.T) {
+	timeouts := HTTPClientConfig{
+		DialTimeout:           3 * time.Second,
+		TLSHandshakeTimeout:   4 * time.Second,
+		ResponseHeaderTimeout: 5 * time.Second,
+		TotalTimeout:          6 * time.Second,
+	}
+	for _, streaming := range []bool{false, true} {
+		client := NewConfiguredHTTPClient(nil, timeouts, streaming)
+		transport, ok := client.Transport.(*http.Transport)
+		if !ok {
+			t.Fatalf("streaming=%v: transport = %T, want *http.Transport", streaming, client.Transport)
+		}
+		if transport.TLSHandshakeTimeout != timeouts.TLSHandshakeTimeout {
+			t.Errorf("streaming=%v: TLSHandshakeTimeout = %v", streaming, transport.TLSHandshakeTimeout)
+		}
+		if transport.ResponseHeaderTimeout != timeouts.ResponseHeaderTimeout {
+			t.Errorf("streaming=%v: ResponseHeaderTimeout = %v", streaming, transport.ResponseHeaderTimeout)
+		}
+		if transport.DialContext == nil {
+			t.Errorf("streaming=%v: DialContext not set", streaming)
+		}
+		wantTotal := timeouts.TotalTimeout
+		if streaming {
+			wantTotal = 0
+		}
+		if client.Timeout != wantTotal {
+			t.Errorf("streaming=%v: client Timeout = %v, want %v", streaming, client.Timeout, wantTotal)
+		}
+	}
+}
+
+func TestNewConfiguredHTTPClientStreamingOutlivesTotalTimeout(t *testing.T) {
+	srv := newSlowBodyServer(t, 4, 400*time.Millisecond)
+	timeouts := HTTPClientConfig{ResponseHeaderTimeout: time.Second, TotalTimeout: 100 * time.Millisecond}
+
+	resp, err := NewConfiguredHTTPClient(nil, timeouts, true).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("streaming GET: %v", err)
+	}
+	body, errRead := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if errRead != nil {
+		t.Fatalf("streaming body cut off after %d bytes: %v", len(body), errRead)
+	}
+	if want := 4 * len("data: chunk\n\n"); len(body) != want {
+		t.Fatalf("body length = %d, want %d", len(body), want)
+	}
+
+	// The non-streaming client enforces the total timeout on the same body.
+	resp, err = NewConfiguredHTTPClient(nil, timeouts, false).Get(srv.URL)
+	if err == nil {
+		_, err = io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+	}
+	if err == nil {
+		t.Fatal("expected the total timeout to cut off the non-streaming read")
+	}
+}
+
+func TestHTTPClientConfigWithDefaults(t *testing.T) {
+	defaults := HTTPClientConfig{DialTimeout: 1, TLSHandshakeTimeout: 2, ResponseHeaderTimeout: 3, TotalTimeout: 4}
+	got := HTTPClientConfig{ResponseHeaderTimeout: 30}.WithDefaults(defaults)
+	want := HTTPClientConfig{DialTimeout: 1, TLSHandshakeTimeout: 2, ResponseHeaderTimeout: 30, TotalTimeout: 4}
+	if got != want {
+		t.Fatalf("WithDefaults = %+v, want %+v", got, want)
+	}
+}
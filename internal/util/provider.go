@@ -181,6 +181,26 @@ func HideAPIKey(apiKey string) string {
 	return apiKey
 }
 
+// ObfuscateToken renders a token for log output as its first and last 4 characters
+// separated by "..." (e.g. "ghu_...abcd").
+//
+// Parameters:
+//   - token: The token to obfuscate.
+//
+// Returns:
+//   - string: "[empty]" for blank tokens, "[short]" for tokens under 12 characters,
+//     otherwise the obfuscated token.
+func ObfuscateToken(token string) string {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "[empty]"
+	}
+	if len(token) < 12 {
+		return "[short]"
+	}
+	return token[:4] + "..." + token[len(token)-4:]
+}
+
 // maskAuthorizationHeader masks the Authorization header value while preserving the auth type prefix.
 // Common formats: "Bearer <token>", "Basic <credentials>", "ApiKey <key>", etc.
 // It preserves the prefix (e.g., "Bearer ") and only masks the token/credential part.
@@ -4,6 +4,7 @@
 package util
 
 import (
+	"crypto/tls"
 	"net/http"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
@@ -28,3 +29,40 @@ func SetProxy(cfg *config.SDKConfig, httpClient *http.Client) *http.Client {
 	}
 	return httpClient
 }
+
+// DefaultMinTLSVersion is the minimum TLS version enforced on outbound auth clients.
+const DefaultMinTLSVersion uint16 = tls.VersionTLS12
+
+// SetMinTLS returns a copy of httpClient whose transport requires at least the given
+// TLS version. A nil transport is replaced with a clone of http.DefaultTransport.
+// Transports that are not *http.Transport (e.g. test round trippers) are left as is.
+func SetMinTLS(httpClient *http.Client, version uint16) *http.Client {
+	if httpClient == nil {
+		return nil
+	}
+	if version == 0 {
+		version = DefaultMinTLSVersion
+	}
+
+	var transport *http.Transport
+	switch t := httpClient.Transport.(type) {
+	case nil:
+		base, ok := http.DefaultTransport.(*http.Transport)
+		if !ok {
+			return httpClient
+		}
+		transport = base.Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return httpClient
+	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.MinVersion = version
+
+	client := *httpClient
+	client.Transport = transport
+	return &client
+}
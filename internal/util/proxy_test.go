@@ -0,0 +1,51 @@
+package util
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+)
+
+type stubRoundTripper struct{}
+
+func (stubRoundTripper) RoundTrip(*http.Request) (*http.Response, error) { return nil, nil }
+
+func TestSetMinTLS(t *testing.T) {
+	base := &http.Transport{TLSClientConfig: &tls.Config{ServerName: "example.com"}}
+	tests := []struct {
+		name    string
+		client  *http.Client
+		version uint16
+		want    uint16
+	}{
+		{"nil transport uses default", &http.Client{}, 0, tls.VersionTLS12},
+		{"http transport", &http.Client{Transport: base}, tls.VersionTLS13, tls.VersionTLS13},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SetMinTLS(tt.client, tt.version)
+			transport, ok := got.Transport.(*http.Transport)
+			if !ok {
+				t.Fatalf("transport = %T, want *http.Transport", got.Transport)
+			}
+			if transport.TLSClientConfig == nil || transport.TLSClientConfig.MinVersion != tt.want {
+				t.Fatalf("MinVersion = %v, want %v", transport.TLSClientConfig, tt.want)
+			}
+		})
+	}
+
+	if base.TLSClientConfig.MinVersion != 0 {
+		t.Fatalf("original transport was modified: MinVersion = %d", base.TLSClientConfig.MinVersion)
+	}
+	if got := SetMinTLS(&http.Client{Transport: base}, 0); got.Transport.(*http.Transport).TLSClientConfig.ServerName != "example.com" {
+		t.Fatal("existing TLS settings were not preserved")
+	}
+}
+
+func TestSetMinTLSLeavesCustomTransport(t *testing.T) {
+	client := &http.Client{Transport: stubRoundTripper{}}
+	got := SetMinTLS(client, tls.VersionTLS13)
+	if _, ok := got.Transport.(stubRoundTripper); !ok {
+		t.Fatalf("transport = %T, want stubRoundTripper", got.Transport)
+	}
+}
@@ -2,6 +2,7 @@ package util
 
 import (
 	"testing"
+	"unicode/utf8"
 )
 
 func TestSanitizeFunctionName(t *testing.T) {
@@ -128,3 +129,63 @@ func TestRestoreSanitizedToolName(t *testing.T) {
 		t.Errorf("expected empty for empty name, got %q", got)
 	}
 }
+
+func TestTruncateString(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		maxBytes int
+		expected string
+	}{
+		{"ASCII within limit", "hello", 10, "hello"},
+		{"ASCII exact limit", "hello", 5, "hello"},
+		{"ASCII one over limit", "hello!", 5, "he..."},
+		{"ASCII long", "abcdefghijklmnopqrstuvwxyz", 10, "abcdefg..."},
+		{"Multi-byte within limit", "héllo", 6, "héllo"},
+		{"Multi-byte cut mid-rune", "日本語テキスト", 10, "日本..."},
+		{"Multi-byte cut on boundary", "日本語テキスト", 9, "日本..."},
+		{"Emoji cut mid-rune", "ab😀cd", 6, "ab..."},
+		{"Limit smaller than marker", "日本語", 2, ""},
+		{"Limit smaller than marker ASCII", "abcdef", 2, "ab"},
+		{"Zero limit", "abc", 0, ""},
+		{"Empty string", "", 5, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TruncateString(tt.input, tt.maxBytes)
+			if got != tt.expected {
+				t.Errorf("TruncateString(%q, %d) = %q, want %q", tt.input, tt.maxBytes, got, tt.expected)
+			}
+			if len(got) > tt.maxBytes && tt.maxBytes >= 0 {
+				t.Errorf("TruncateString(%q, %d) returned %d bytes", tt.input, tt.maxBytes, len(got))
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("TruncateString(%q, %d) returned invalid UTF-8 %q", tt.input, tt.maxBytes, got)
+			}
+		})
+	}
+}
+
+func TestObfuscateToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Empty", "", "[empty]"},
+		{"Blank", "   ", "[empty]"},
+		{"Short", "ghu_abc", "[short]"},
+		{"Eleven chars", "abcdefghijk", "[short]"},
+		{"Twelve chars", "abcdefghijkl", "abcd...ijkl"},
+		{"GitHub token", "ghu_1234567890abcd", "ghu_...abcd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ObfuscateToken(tt.input); got != tt.expected {
+				t.Errorf("ObfuscateToken(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sync"
+)
+
+// sseSubscriberBuffer is the number of events buffered per SSE subscriber.
+const sseSubscriberBuffer = 10
+
+// SSEDroppedEvent is the last event a subscriber receives before it is dropped
+// for falling behind. It is a valid SSE event, so relaying it tells the client
+// why its stream ended early.
+var SSEDroppedEvent = []byte("event: error\ndata: {\"error\":{\"message\":\"stream subscriber dropped: reader fell behind\",\"type\":\"subscriber_dropped\"}}\n\n")
+
+// SSEBroadcaster fans one upstream SSE stream out to many subscribers. Each event
+// (the lines up to and including the blank line that ends it) is delivered to
+// every subscriber as a separate slice. A subscriber whose 10-event buffer is full
+// receives SSEDroppedEvent and is dropped, so one slow reader cannot stall the
+// others. All channels are closed once the upstream ends.
+type SSEBroadcaster struct {
+	src io.Reader
+
+	mu          sync.Mutex
+	subscribers map[int]chan []byte
+	nextID      int
+	closed      bool
+	err         error
+
+	startOnce sync.Once
+	done      chan struct{}
+}
+
+// NewSSEBroadcaster creates a broadcaster reading events from src. Call Subscribe
+// for every initial subscriber before Start so none of them misses events.
+func NewSSEBroadcaster(src io.Reader) *SSEBroadcaster {
+	return &SSEBroadcaster{
+		src:         src,
+		subscribers: make(map[int]chan []byte),
+		done:        make(chan struct{}),
+	}
+}
+
+// Subscribe registers a subscriber and returns its event channel and a cancel
+// function that unsubscribes and closes the channel. Subscribers added after
+// Start receive only later events; after the upstream has ended the returned
+// channel is already closed. cancel is safe to call more than once.
+func (b *SSEBroadcaster) Subscribe() (<-chan []byte, func()) {
+	// One slot beyond the buffer is reserved for SSEDroppedEvent.
+	ch := make(chan []byte, sseSubscriberBuffer+1)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	id := b.nextID
+	b.nextID++
+	b.subscribers[id] = ch
+	return ch, func() { b.unsubscribe(id) }
+}
+
+// Start launches the goroutine that reads the upstream and broadcasts its events.
+// Calls after the first are no-ops.
+func (b *SSEBroadcaster) Start() {
+	b.startOnce.Do(func() {
+		go b.run()
+	})
+}
+
+// Done is closed once the upstream has ended and every channel is closed.
+func (b *SSEBroadcaster) Done() <-chan struct{} {
+	return b.done
+}
+
+// Err returns the error that ended the upstream read, or nil on a clean EOF.
+// It is only meaningful after Done is closed.
+func (b *SSEBroadcaster) Err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+func (b *SSEBroadcaster) run() {
+	reader := bufio.NewReader(b.src)
+	var event []byte
+	var errRead error
+	for {
+		var line []byte
+		line, errRead = reader.ReadBytes('\n')
+		event = append(event, line...)
+		if len(bytes.TrimRight(line, "\r\n")) == 0 {
+			if len(bytes.TrimSpace(event)) > 0 {
+				b.broadcast(event)
+			}
+			event = nil
+		}
+		if errRead != nil {
+			break
+		}
+	}
+	// Deliver a final event that was not terminated by a blank line.
+	if len(bytes.TrimSpace(event)) > 0 {
+		b.broadcast(event)
+	}
+	if errRead == io.EOF {
+		errRead = nil
+	}
+	b.finish(errRead)
+}
+
+func (b *SSEBroadcaster) broadcast(event []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// Only broadcast sends, and it holds mu, so a channel below the buffer size
+	// always has room for the event, and a full one for SSEDroppedEvent.
+	for id, ch := range b.subscribers {
+		if len(ch) >= sseSubscriberBuffer {
+			ch <- bytes.Clone(SSEDroppedEvent)
+			close(ch)
+			delete(b.subscribers, id)
+			continue
+		}
+		ch <- bytes.Clone(event)
+	}
+}
+
+func (b *SSEBroadcaster) unsubscribe(id int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch, ok := b.subscribers[id]; ok {
+		close(ch)
+		delete(b.subscribers, id)
+	}
+}
+
+func (b *SSEBroadcaster) finish(err error) {
+	b.mu.Lock()
+	b.closed = true
+	b.err = err
+	for id, ch := range b.subscribers {
+		close(ch)
+		delete(b.subscribers, id)
+	}
+	b.mu.Unlock()
+	close(b.done)
+}
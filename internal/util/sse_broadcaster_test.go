@@ -0,0 +1,124 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func collectSSE(ch <-chan []byte) []string {
+	var events []string
+	for event := range ch {
+		events = append(events, string(event))
+	}
+	return events
+}
+
+func TestSSEBroadcaster_AllSubscribersReceiveAllEvents(t *testing.T) {
+	pr, pw := io.Pipe()
+	b := NewSSEBroadcaster(pr)
+	first, cancelFirst := b.Subscribe()
+	defer cancelFirst()
+	second, cancelSecond := b.Subscribe()
+	defer cancelSecond()
+	b.Start()
+
+	var want []string
+	for i := 0; i < 25; i++ {
+		want = append(want, fmt.Sprintf("data: {\"n\":%d}\n\n", i))
+	}
+
+	// Each subscriber acknowledges every event so the writer never gets more than
+	// one event ahead; otherwise a briefly descheduled reader could be dropped.
+	var wg sync.WaitGroup
+	results := make([][]string, 2)
+	acks := make(chan struct{}, 2)
+	for i, ch := range []<-chan []byte{first, second} {
+		wg.Add(1)
+		go func(i int, ch <-chan []byte) {
+			defer wg.Done()
+			for event := range ch {
+				results[i] = append(results[i], string(event))
+				acks <- struct{}{}
+			}
+		}(i, ch)
+	}
+
+	go func() {
+		for _, event := range want {
+			_, _ = io.WriteString(pw, event)
+			for range 2 {
+				select {
+				case <-acks:
+				case <-time.After(5 * time.Second):
+				}
+			}
+		}
+		_ = pw.Close()
+	}()
+	wg.Wait()
+
+	for i, got := range results {
+		if strings.Join(got, "") != strings.Join(want, "") || len(got) != len(want) {
+			t.Fatalf("subscriber %d received %d events, want %d: %q", i, len(got), len(want), got)
+		}
+	}
+	<-b.Done()
+	if err := b.Err(); err != nil {
+		t.Fatalf("Err() = %v, want nil", err)
+	}
+}
+
+func TestSSEBroadcaster_DropsSlowSubscriber(t *testing.T) {
+	var stream strings.Builder
+	for i := 0; i < 2*sseSubscriberBuffer; i++ {
+		fmt.Fprintf(&stream, "data: %d\n\n", i)
+	}
+	b := NewSSEBroadcaster(strings.NewReader(stream.String()))
+	slow, cancel := b.Subscribe()
+	defer cancel()
+	b.Start()
+
+	select {
+	case <-b.Done():
+	case <-time.After(time.Second):
+		t.Fatal("broadcaster blocked on a slow subscriber")
+	}
+	events := collectSSE(slow)
+	if got := len(events); got != sseSubscriberBuffer+1 {
+		t.Fatalf("slow subscriber received %d events, want %d buffered plus the drop event", got, sseSubscriberBuffer)
+	}
+	if last := events[len(events)-1]; last != string(SSEDroppedEvent) {
+		t.Fatalf("last event = %q, want SSEDroppedEvent", last)
+	}
+}
+
+func TestSSEBroadcaster_CancelAndLateSubscribe(t *testing.T) {
+	pr, pw := io.Pipe()
+	b := NewSSEBroadcaster(pr)
+	ch, cancel := b.Subscribe()
+	b.Start()
+
+	cancel()
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Fatal("expected channel to be closed after cancel")
+	}
+
+	errUpstream := errors.New("upstream reset")
+	_ = pw.CloseWithError(errUpstream)
+	<-b.Done()
+	if err := b.Err(); !errors.Is(err, errUpstream) {
+		t.Fatalf("Err() = %v, want %v", err, errUpstream)
+	}
+
+	late, cancelLate := b.Subscribe()
+	defer cancelLate()
+	if _, ok := <-late; ok {
+		t.Fatal("expected a subscription after the upstream ended to be closed")
+	}
+}
@@ -88,6 +88,53 @@ func RenameKey(jsonStr, oldKeyPath, newKeyPath string) (string, error) {
 	return string(finalJSON), nil
 }
 
+// SafeSetJSON sets value at path like sjson.SetBytes but recovers from panics
+// raised on malformed input. On failure it returns rawJSON unchanged together
+// with the error, so callers that ignore the error keep the original payload.
+func SafeSetJSON(rawJSON []byte, path string, value any) (out []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			out, err = rawJSON, fmt.Errorf("set json path %q: panic: %v", path, r)
+		}
+	}()
+	updated, errSet := sjson.SetBytes(rawJSON, path, value)
+	if errSet != nil {
+		return rawJSON, fmt.Errorf("set json path %q: %w", path, errSet)
+	}
+	return updated, nil
+}
+
+// SafeSetRawJSON sets the raw JSON value at path like sjson.SetRawBytes, with
+// the same recovery and failure behaviour as SafeSetJSON.
+func SafeSetRawJSON(rawJSON []byte, path string, value []byte) (out []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			out, err = rawJSON, fmt.Errorf("set raw json path %q: panic: %v", path, r)
+		}
+	}()
+	updated, errSet := sjson.SetRawBytes(rawJSON, path, value)
+	if errSet != nil {
+		return rawJSON, fmt.Errorf("set raw json path %q: %w", path, errSet)
+	}
+	return updated, nil
+}
+
+// SafeDeleteJSON deletes path like sjson.DeleteBytes but recovers from panics
+// raised on malformed input. On failure it returns rawJSON unchanged together
+// with the error.
+func SafeDeleteJSON(rawJSON []byte, path string) (out []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			out, err = rawJSON, fmt.Errorf("delete json path %q: panic: %v", path, r)
+		}
+	}()
+	updated, errDelete := sjson.DeleteBytes(rawJSON, path)
+	if errDelete != nil {
+		return rawJSON, fmt.Errorf("delete json path %q: %w", path, errDelete)
+	}
+	return updated, nil
+}
+
 // FixJSON converts non-standard JSON that uses single quotes for strings into
 // RFC 8259-compliant JSON by converting those single-quoted strings to
 // double-quoted strings with proper escaping.
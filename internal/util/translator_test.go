@@ -0,0 +1,67 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestSafeSetJSON(t *testing.T) {
+	raw := []byte(`{"a":1}`)
+
+	out, err := SafeSetJSON(raw, "b.c", "x")
+	if err != nil {
+		t.Fatalf("SafeSetJSON: %v", err)
+	}
+	if got := gjson.GetBytes(out, "b.c").String(); got != "x" {
+		t.Fatalf("b.c = %q, want x", got)
+	}
+
+	out, err = SafeSetJSON(raw, "", "x")
+	if err == nil {
+		t.Fatal("expected error for empty path")
+	}
+	if string(out) != string(raw) {
+		t.Fatalf("output on error = %s, want original %s", out, raw)
+	}
+}
+
+func TestSafeSetRawJSON(t *testing.T) {
+	raw := []byte(`{"a":1}`)
+
+	out, err := SafeSetRawJSON(raw, "b", []byte(`{"c":[1,2]}`))
+	if err != nil {
+		t.Fatalf("SafeSetRawJSON: %v", err)
+	}
+	if got := gjson.GetBytes(out, "b.c.1").Int(); got != 2 {
+		t.Fatalf("b.c.1 = %d, want 2", got)
+	}
+
+	out, err = SafeSetRawJSON(raw, "", []byte(`1`))
+	if err == nil {
+		t.Fatal("expected error for empty path")
+	}
+	if string(out) != string(raw) {
+		t.Fatalf("output on error = %s, want original %s", out, raw)
+	}
+}
+
+func TestSafeDeleteJSON(t *testing.T) {
+	raw := []byte(`{"a":1,"b":2}`)
+
+	out, err := SafeDeleteJSON(raw, "a")
+	if err != nil {
+		t.Fatalf("SafeDeleteJSON: %v", err)
+	}
+	if gjson.GetBytes(out, "a").Exists() || !gjson.GetBytes(out, "b").Exists() {
+		t.Fatalf("unexpected output %s", out)
+	}
+
+	out, err = SafeDeleteJSON(raw, "")
+	if err == nil {
+		t.Fatal("expected error for empty path")
+	}
+	if string(out) != string(raw) {
+		t.Fatalf("output on error = %s, want original %s", out, raw)
+	}
+}
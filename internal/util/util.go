@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	log "github.com/sirupsen/logrus"
@@ -54,6 +55,31 @@ func SanitizeFunctionName(name string) string {
 	return sanitized
 }
 
+// TruncateString shortens s so the result, including a trailing "..." marker,
+// fits in maxBytes. The cut lands on a UTF-8 rune boundary so the result stays
+// valid UTF-8. Strings already within maxBytes are returned unchanged; when
+// maxBytes is too small for the marker the rune-aligned prefix is returned alone.
+func TruncateString(s string, maxBytes int) string {
+	if maxBytes <= 0 {
+		return ""
+	}
+	if len(s) <= maxBytes {
+		return s
+	}
+
+	const ellipsis = "..."
+	limit := maxBytes - len(ellipsis)
+	suffix := ellipsis
+	if limit < 0 {
+		limit = maxBytes
+		suffix = ""
+	}
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	return s[:limit] + suffix
+}
+
 // SetLogLevel configures the logrus log level based on the configuration.
 // It sets the log level to DebugLevel if debug mode is enabled, otherwise to InfoLevel.
 func SetLogLevel(cfg *config.Config) {
@@ -74,15 +100,25 @@ func SetLogLevel(cfg *config.Config) {
 // ResolveAuthDir normalizes the auth directory path for consistent reuse throughout the app.
 // It expands a leading tilde (~) to the user's home directory and returns a cleaned path.
 func ResolveAuthDir(authDir string) (string, error) {
-	if authDir == "" {
+	resolved, err := ResolvePath(authDir)
+	if err != nil {
+		return "", fmt.Errorf("resolve auth dir: %w", err)
+	}
+	return resolved, nil
+}
+
+// ResolvePath expands a leading tilde (~) in a configured path to the user's home
+// directory and returns the cleaned path. An empty path stays empty.
+func ResolvePath(path string) (string, error) {
+	if path == "" {
 		return "", nil
 	}
-	if strings.HasPrefix(authDir, "~") {
+	if strings.HasPrefix(path, "~") {
 		home, err := os.UserHomeDir()
 		if err != nil {
-			return "", fmt.Errorf("resolve auth dir: %w", err)
+			return "", err
 		}
-		remainder := strings.TrimPrefix(authDir, "~")
+		remainder := strings.TrimPrefix(path, "~")
 		remainder = strings.TrimLeft(remainder, "/\\")
 		if remainder == "" {
 			return filepath.Clean(home), nil
@@ -90,7 +126,7 @@ func ResolveAuthDir(authDir string) (string, error) {
 		normalized := strings.ReplaceAll(remainder, "\\", "/")
 		return filepath.Clean(filepath.Join(home, filepath.FromSlash(normalized))), nil
 	}
-	return filepath.Clean(authDir), nil
+	return filepath.Clean(path), nil
 }
 
 // CountAuthFiles returns the number of auth records available through the provided Store.
@@ -0,0 +1,29 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolvePathExpandsHome(t *testing.T) {
+	home, errHome := os.UserHomeDir()
+	if errHome != nil {
+		t.Skipf("no home directory: %v", errHome)
+	}
+	cases := map[string]string{
+		"":              "",
+		"~":             filepath.Clean(home),
+		"~/plugins":     filepath.Join(home, "plugins"),
+		"/opt/plugins/": "/opt/plugins",
+	}
+	for in, want := range cases {
+		got, err := ResolvePath(in)
+		if err != nil {
+			t.Fatalf("ResolvePath(%q) error = %v", in, err)
+		}
+		if got != want {
+			t.Errorf("ResolvePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
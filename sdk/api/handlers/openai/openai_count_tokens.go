@@ -0,0 +1,116 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor/helps"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+)
+
+// countTokensResponse is the body returned by CountTokens.
+type countTokensResponse struct {
+	TotalTokens int64 `json:"total_tokens"`
+}
+
+// CountTokens handles the /v1/count_tokens endpoint. The request body is
+// {"model": "...", "messages": [...]} with OpenAI chat messages, and the
+// response is {"total_tokens": N}.
+//
+// Claude models are counted locally with tiktoken. Every other model family is
+// routed through the auth manager to its provider's native counter, e.g. the
+// Gemini countTokens API.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) CountTokens(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	modelName := strings.TrimSpace(gjson.GetBytes(rawJSON, "model").String())
+	if modelName == "" || !gjson.GetBytes(rawJSON, "messages").IsArray() {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "Invalid request: model and messages are required",
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	if isClaudeModelFamily(modelName) {
+		total, errCount := countTokensLocally(modelName, rawJSON)
+		if errCount != nil {
+			c.JSON(http.StatusInternalServerError, handlers.ErrorResponse{
+				Error: handlers.ErrorDetail{
+					Message: fmt.Sprintf("count tokens: %v", errCount),
+					Type:    "server_error",
+				},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, countTokensResponse{TotalTokens: total})
+		return
+	}
+
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, upstreamHeaders, errMsg := h.ExecuteCountWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, h.GetAlt(c))
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	total, ok := tokenCountFromResponse(resp)
+	if !ok {
+		c.JSON(http.StatusBadGateway, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "upstream response did not contain a token count",
+				Type:    "server_error",
+			},
+		})
+		cliCancel(fmt.Errorf("missing token count in upstream response"))
+		return
+	}
+	handlers.WriteUpstreamHeaders(c.Writer.Header(), upstreamHeaders)
+	c.JSON(http.StatusOK, countTokensResponse{TotalTokens: total})
+	cliCancel()
+}
+
+// isClaudeModelFamily reports whether modelName is counted with the local
+// Claude tokenizer rather than a provider API.
+func isClaudeModelFamily(modelName string) bool {
+	return strings.Contains(strings.ToLower(modelName), "claude")
+}
+
+// countTokensLocally counts the OpenAI chat messages in rawJSON with tiktoken.
+func countTokensLocally(modelName string, rawJSON []byte) (int64, error) {
+	enc, err := helps.TokenizerForModel(modelName)
+	if err != nil {
+		return 0, err
+	}
+	return helps.CountOpenAIChatTokens(enc, rawJSON)
+}
+
+// tokenCountFromResponse extracts the token total from the count payloads the
+// executors return: Gemini (totalTokens), Claude (input_tokens), OpenAI usage,
+// and the local estimators ({"count": N}).
+func tokenCountFromResponse(payload []byte) (int64, bool) {
+	for _, path := range []string{"total_tokens", "totalTokens", "input_tokens", "count", "usage.total_tokens", "usage.prompt_tokens"} {
+		if value := gjson.GetBytes(payload, path); value.Exists() {
+			return value.Int(), true
+		}
+	}
+	return 0, false
+}
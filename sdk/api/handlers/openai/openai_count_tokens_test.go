@@ -0,0 +1,151 @@
+package openai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func newCountTokensRouter(t *testing.T, manager *coreauth.Manager) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	h := NewOpenAIAPIHandler(handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager))
+	router := gin.New()
+	router.POST("/v1/count_tokens", h.CountTokens)
+	return router
+}
+
+func postCountTokens(router *gin.Engine, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/count_tokens", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestCountTokensUsesGeminiNativeCounter(t *testing.T) {
+	var gotPath, gotKey string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotKey = r.Header.Get("x-goog-api-key")
+		gotBody, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"totalTokens":42}`))
+	}))
+	defer server.Close()
+
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor.NewGeminiExecutor(&config.Config{}))
+	auth := &coreauth.Auth{
+		ID:         "count-tokens-gemini",
+		Provider:   "gemini",
+		Status:     coreauth.StatusActive,
+		Attributes: map[string]string{"api_key": "test-key", "base_url": server.URL},
+	}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("Register auth: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "gemini-count-test"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	resp := postCountTokens(newCountTokensRouter(t, manager), `{"model":"gemini-count-test","messages":[{"role":"user","content":"hello there"}]}`)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", resp.Code, http.StatusOK, resp.Body.String())
+	}
+	if got := gjson.Get(resp.Body.String(), "total_tokens").Int(); got != 42 {
+		t.Fatalf("total_tokens = %d, want 42: %s", got, resp.Body.String())
+	}
+	if gotPath != "/v1beta/models/gemini-count-test:countTokens" {
+		t.Errorf("upstream path = %q", gotPath)
+	}
+	if gotKey != "test-key" {
+		t.Errorf("x-goog-api-key = %q", gotKey)
+	}
+	if text := gjson.GetBytes(gotBody, "contents.0.parts.0.text").String(); text != "hello there" {
+		t.Errorf("upstream contents text = %q, body %s", text, gotBody)
+	}
+}
+
+func TestCountTokensGeminiUpstreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"message":"bad key"}}`, http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor.NewGeminiExecutor(&config.Config{}))
+	auth := &coreauth.Auth{
+		ID:         "count-tokens-gemini-error",
+		Provider:   "gemini",
+		Status:     coreauth.StatusActive,
+		Attributes: map[string]string{"api_key": "test-key", "base_url": server.URL},
+	}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("Register auth: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "gemini-count-error-test"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	resp := postCountTokens(newCountTokensRouter(t, manager), `{"model":"gemini-count-error-test","messages":[{"role":"user","content":"hi"}]}`)
+
+	if resp.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d: %s", resp.Code, http.StatusForbidden, resp.Body.String())
+	}
+}
+
+func TestCountTokensCountsClaudeLocally(t *testing.T) {
+	// No executor is registered: Claude models must not reach the auth manager.
+	router := newCountTokensRouter(t, coreauth.NewManager(nil, nil, nil))
+
+	resp := postCountTokens(router, `{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"hello there, how are you?"}]}`)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", resp.Code, http.StatusOK, resp.Body.String())
+	}
+	if got := gjson.Get(resp.Body.String(), "total_tokens").Int(); got <= 0 {
+		t.Fatalf("total_tokens = %d, want > 0", got)
+	}
+}
+
+func TestCountTokensRejectsMissingMessages(t *testing.T) {
+	router := newCountTokensRouter(t, coreauth.NewManager(nil, nil, nil))
+
+	for _, body := range []string{`{"model":"gemini-2.5-pro"}`, `{"messages":[]}`} {
+		resp := postCountTokens(router, body)
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("body %s: status = %d, want %d", body, resp.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestTokenCountFromResponse(t *testing.T) {
+	tests := map[string]int64{
+		`{"totalTokens":7}`:                   7,
+		`{"input_tokens":8}`:                  8,
+		`{"count":9}`:                         9,
+		`{"usage":{"prompt_tokens":10}}`:      10,
+		`{"total_tokens":11,"totalTokens":1}`: 11,
+	}
+	for payload, want := range tests {
+		if got, ok := tokenCountFromResponse([]byte(payload)); !ok || got != want {
+			t.Errorf("tokenCountFromResponse(%s) = (%d, %v), want (%d, true)", payload, got, ok, want)
+		}
+	}
+	if _, ok := tokenCountFromResponse([]byte(`{"foo":1}`)); ok {
+		t.Error("expected no count for unrelated payload")
+	}
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+// systemPromptPrefixSeparator joins the configured prefix to an existing system prompt.
+const systemPromptPrefixSeparator = "\n\n"
+
+// applySystemPromptPrefix prepends cfg.SystemPromptPrefix to the system prompt of
+// rawJSON, which is a request in the client's format. Requests without a system
+// prompt receive the prefix as their only system instruction. The request is
+// returned unchanged when no prefix is configured or the format is unknown.
+func applySystemPromptPrefix(cfg *config.SDKConfig, format sdktranslator.Format, rawJSON []byte) []byte {
+	if cfg == nil || strings.TrimSpace(cfg.SystemPromptPrefix) == "" || !gjson.ValidBytes(rawJSON) {
+		return rawJSON
+	}
+	prefix := cfg.SystemPromptPrefix
+	switch format {
+	case sdktranslator.FormatOpenAI:
+		return prefixOpenAISystemMessage(rawJSON, prefix)
+	case sdktranslator.FormatOpenAIResponse:
+		return prefixStringField(rawJSON, "instructions", prefix)
+	case sdktranslator.FormatClaude:
+		return prefixClaudeSystem(rawJSON, prefix)
+	case sdktranslator.FormatGemini:
+		return prefixGeminiSystemInstruction(rawJSON, "", prefix)
+	case sdktranslator.FormatGeminiCLI:
+		return prefixGeminiSystemInstruction(rawJSON, "request.", prefix)
+	default:
+		return rawJSON
+	}
+}
+
+func joinSystemPrompt(prefix, existing string) string {
+	if existing == "" {
+		return prefix
+	}
+	return prefix + systemPromptPrefixSeparator + existing
+}
+
+func prefixStringField(rawJSON []byte, path, prefix string) []byte {
+	out, _ := util.SafeSetJSON(rawJSON, path, joinSystemPrompt(prefix, gjson.GetBytes(rawJSON, path).String()))
+	return out
+}
+
+// prefixOpenAISystemMessage prefixes the leading system or developer message, or
+// inserts a system message when the conversation has none.
+func prefixOpenAISystemMessage(rawJSON []byte, prefix string) []byte {
+	messages := gjson.GetBytes(rawJSON, "messages")
+	if !messages.IsArray() {
+		return rawJSON
+	}
+	first := messages.Get("0")
+	if role := first.Get("role").String(); role == "system" || role == "developer" {
+		content := first.Get("content")
+		if content.IsArray() {
+			return prefixTextParts(rawJSON, "messages.0.content", prefix, `{"type":"text","text":""}`)
+		}
+		return prefixStringField(rawJSON, "messages.0.content", prefix)
+	}
+
+	system, errSystem := util.SafeSetJSON([]byte(`{"role":"system"}`), "content", prefix)
+	if errSystem != nil {
+		return rawJSON
+	}
+	items := []string{string(system)}
+	for _, message := range messages.Array() {
+		items = append(items, message.Raw)
+	}
+	out, _ := util.SafeSetRawJSON(rawJSON, "messages", []byte("["+strings.Join(items, ",")+"]"))
+	return out
+}
+
+// prefixClaudeSystem handles both the string and the content-block forms of the
+// Claude system prompt.
+func prefixClaudeSystem(rawJSON []byte, prefix string) []byte {
+	if gjson.GetBytes(rawJSON, "system").IsArray() {
+		return prefixTextParts(rawJSON, "system", prefix, `{"type":"text","text":""}`)
+	}
+	return prefixStringField(rawJSON, "system", prefix)
+}
+
+// prefixGeminiSystemInstruction prefixes the first text part of the system
+// instruction under root, accepting both the camelCase and snake_case keys.
+func prefixGeminiSystemInstruction(rawJSON []byte, root, prefix string) []byte {
+	path := root + "systemInstruction"
+	if !gjson.GetBytes(rawJSON, path).Exists() && gjson.GetBytes(rawJSON, root+"system_instruction").Exists() {
+		path = root + "system_instruction"
+	}
+	return prefixTextParts(rawJSON, path+".parts", prefix, `{"text":""}`)
+}
+
+// prefixTextParts prepends prefix to the text of the first element of the array
+// at path, inserting a new element built from template when the first element
+// carries no text.
+func prefixTextParts(rawJSON []byte, path, prefix, template string) []byte {
+	parts := gjson.GetBytes(rawJSON, path)
+	first := parts.Get("0")
+	if first.Get("text").Type == gjson.String {
+		return prefixStringField(rawJSON, path+".0.text", prefix)
+	}
+
+	part, errPart := util.SafeSetJSON([]byte(template), "text", prefix)
+	if errPart != nil {
+		return rawJSON
+	}
+	items := []string{string(part)}
+	for _, existing := range parts.Array() {
+		items = append(items, existing.Raw)
+	}
+	out, _ := util.SafeSetRawJSON(rawJSON, path, []byte("["+strings.Join(items, ",")+"]"))
+	return out
+}
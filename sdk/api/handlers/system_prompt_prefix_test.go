@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+func TestApplySystemPromptPrefix(t *testing.T) {
+	cfg := &sdkconfig.SDKConfig{SystemPromptPrefix: "PREFIX"}
+	tests := []struct {
+		name   string
+		format sdktranslator.Format
+		body   string
+		path   string
+		want   string
+	}{
+		{"openai system", sdktranslator.FormatOpenAI, `{"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"}]}`, "messages.0.content", "PREFIX\n\nbe brief"},
+		{"openai no system", sdktranslator.FormatOpenAI, `{"messages":[{"role":"user","content":"hi"}]}`, "messages.0.content", "PREFIX"},
+		{"openai parts", sdktranslator.FormatOpenAI, `{"messages":[{"role":"developer","content":[{"type":"text","text":"be brief"}]}]}`, "messages.0.content.0.text", "PREFIX\n\nbe brief"},
+		{"responses", sdktranslator.FormatOpenAIResponse, `{"instructions":"be brief","input":"hi"}`, "instructions", "PREFIX\n\nbe brief"},
+		{"claude string", sdktranslator.FormatClaude, `{"system":"be brief","messages":[]}`, "system", "PREFIX\n\nbe brief"},
+		{"claude blocks", sdktranslator.FormatClaude, `{"system":[{"type":"text","text":"be brief"}],"messages":[]}`, "system.0.text", "PREFIX\n\nbe brief"},
+		{"claude none", sdktranslator.FormatClaude, `{"messages":[]}`, "system", "PREFIX"},
+		{"gemini", sdktranslator.FormatGemini, `{"systemInstruction":{"parts":[{"text":"be brief"}]}}`, "systemInstruction.parts.0.text", "PREFIX\n\nbe brief"},
+		{"gemini snake case", sdktranslator.FormatGemini, `{"system_instruction":{"parts":[{"text":"be brief"}]}}`, "system_instruction.parts.0.text", "PREFIX\n\nbe brief"},
+		{"gemini none", sdktranslator.FormatGemini, `{"contents":[]}`, "systemInstruction.parts.0.text", "PREFIX"},
+		{"gemini-cli", sdktranslator.FormatGeminiCLI, `{"request":{"systemInstruction":{"parts":[{"text":"be brief"}]}}}`, "request.systemInstruction.parts.0.text", "PREFIX\n\nbe brief"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := applySystemPromptPrefix(cfg, tt.format, []byte(tt.body))
+			if got := gjson.GetBytes(out, tt.path).String(); got != tt.want {
+				t.Fatalf("%s = %q, want %q; body %s", tt.path, got, tt.want, out)
+			}
+		})
+	}
+}
+
+func TestApplySystemPromptPrefixDisabled(t *testing.T) {
+	body := []byte(`{"messages":[{"role":"user","content":"hi"}]}`)
+	out := applySystemPromptPrefix(&sdkconfig.SDKConfig{}, sdktranslator.FormatOpenAI, body)
+	if string(out) != string(body) {
+		t.Fatalf("body changed without a prefix: %s", out)
+	}
+}
+
+// translatingExecutor records the request translated to the Claude format.
+type translatingExecutor struct {
+	translated []byte
+}
+
+func (e *translatingExecutor) Identifier() string { return "claude" }
+
+func (e *translatingExecutor) Execute(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, opts coreexecutor.Options) (coreexecutor.Response, error) {
+	e.translated = sdktranslator.TranslateRequest(opts.SourceFormat, sdktranslator.FormatClaude, req.Model, req.Payload, false)
+	return coreexecutor.Response{Payload: []byte(`{}`)}, nil
+}
+
+func (e *translatingExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (*coreexecutor.StreamResult, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (e *translatingExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *translatingExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, errors.New("not implemented")
+}
+
+func (e *translatingExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestExecuteWithAuthManagerAppliesSystemPromptPrefix(t *testing.T) {
+	executor := &translatingExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "prefix-auth", Provider: executor.Identifier(), Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "prefix-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{SystemPromptPrefix: "Operator rules."}, manager)
+	body := `{"model":"prefix-model","messages":[{"role":"system","content":"Be brief."},{"role":"user","content":"hi"}]}`
+	if _, _, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "prefix-model", []byte(body), ""); errMsg != nil {
+		t.Fatalf("ExecuteWithAuthManager: %v", errMsg.Error)
+	}
+
+	system := gjson.GetBytes(executor.translated, "system")
+	var text string
+	for _, block := range system.Array() {
+		text += block.Get("text").String()
+	}
+	if system.Type == gjson.String {
+		text = system.String()
+	}
+	if want := "Operator rules.\n\nBe brief."; text != want {
+		t.Fatalf("translated system = %q, want %q; body %s", text, want, executor.translated)
+	}
+}
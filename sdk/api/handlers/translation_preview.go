@@ -0,0 +1,25 @@
+package handlers
+
+import "github.com/gin-gonic/gin"
+
+// translationPreviewSinkContextKey is the gin context key holding the preview sink.
+const translationPreviewSinkContextKey = "TRANSLATION_PREVIEW_SINK"
+
+// SetTranslationPreviewSink marks the request in c as a translation preview.
+// Executions started for it translate the request, pass the translator output
+// to sink and skip the backend.
+func SetTranslationPreviewSink(c *gin.Context, sink func([]byte)) {
+	if c == nil || sink == nil {
+		return
+	}
+	c.Set(translationPreviewSinkContextKey, sink)
+}
+
+func translationPreviewSinkFromGin(c *gin.Context) func([]byte) {
+	value, exists := c.Get(translationPreviewSinkContextKey)
+	if !exists {
+		return nil
+	}
+	sink, _ := value.(func([]byte))
+	return sink
+}
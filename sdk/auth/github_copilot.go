@@ -94,6 +94,9 @@ func (a GitHubCopilotAuthenticator) Login(ctx context.Context, cfg *config.Confi
 		"timestamp":    time.Now().UnixMilli(),
 	}
 
+	if authBundle.TokenData.ExpiresAt != "" {
+		metadata["expires_at"] = authBundle.TokenData.ExpiresAt
+	}
 	if apiToken.ExpiresAt > 0 {
 		metadata["api_token_expires_at"] = apiToken.ExpiresAt
 	}
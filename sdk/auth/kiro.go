@@ -42,13 +42,21 @@ func extractKiroIdentifier(accountName, profileArn, clientID string) string {
 }
 
 // KiroAuthenticator implements OAuth authentication for Kiro with Google login.
-type KiroAuthenticator struct{}
+type KiroAuthenticator struct {
+	oidcClient kiroauth.OIDCClient
+}
 
 // NewKiroAuthenticator constructs a Kiro authenticator.
 func NewKiroAuthenticator() *KiroAuthenticator {
 	return &KiroAuthenticator{}
 }
 
+// NewKiroAuthenticatorWithOIDCClient constructs a Kiro authenticator that
+// refreshes Builder ID and IDC tokens through client.
+func NewKiroAuthenticatorWithOIDCClient(client kiroauth.OIDCClient) *KiroAuthenticator {
+	return &KiroAuthenticator{oidcClient: client}
+}
+
 // Provider returns the provider key for the authenticator.
 func (a *KiroAuthenticator) Provider() string {
 	return "kiro"
@@ -367,28 +375,36 @@ func (a *KiroAuthenticator) Refresh(ctx context.Context, cfg *config.Config, aut
 		}
 	}
 
-	var tokenData *kiroauth.KiroTokenData
-	var err error
-
-	ssoClient := kiroauth.NewSSOOIDCClient(cfg)
-
-	// Use SSO OIDC refresh for AWS Builder ID or IDC, otherwise use Kiro's OAuth refresh endpoint
-	switch {
-	case clientID != "" && clientSecret != "" && authMethod == "idc" && region != "":
-		// IDC refresh with region-specific endpoint
-		tokenData, err = ssoClient.RefreshTokenWithRegion(ctx, clientID, clientSecret, refreshToken, region, startURL)
-	case clientID != "" && clientSecret != "" && (authMethod == "builder-id" || authMethod == "idc"):
-		// Builder ID or IDC refresh with default endpoint (us-east-1)
-		tokenData, err = ssoClient.RefreshToken(ctx, clientID, clientSecret, refreshToken)
-	default:
-		// Fallback to Kiro's refresh endpoint (for social auth: Google/GitHub)
-		oauth := kiroauth.NewKiroOAuth(cfg)
-		tokenData, err = oauth.RefreshToken(ctx, refreshToken)
-	}
-
+	ssoClient := a.oidcClient
+	if ssoClient == nil {
+		ssoClient = kiroauth.NewSSOOIDCClient(cfg)
+	}
+
+	// Share the refresh with concurrent refreshes of the same token elsewhere
+	// (background refresher, executor) so the refresh token is used once.
+	tokenData, err := kiroauth.SharedRefresh(ctx, refreshToken, func(ctx context.Context) (*kiroauth.KiroTokenData, error) {
+		// Use SSO OIDC refresh for AWS Builder ID or IDC, otherwise use Kiro's OAuth refresh endpoint
+		switch {
+		case clientID != "" && clientSecret != "" && authMethod == "idc" && region != "":
+			// IDC refresh with region-specific endpoint
+			return ssoClient.RefreshTokenWithRegion(ctx, clientID, clientSecret, refreshToken, region, startURL)
+		case clientID != "" && clientSecret != "" && (authMethod == "builder-id" || authMethod == "idc"):
+			// Builder ID or IDC refresh with default endpoint (us-east-1)
+			return ssoClient.RefreshToken(ctx, clientID, clientSecret, refreshToken)
+		default:
+			// Fallback to Kiro's refresh endpoint (for social auth: Google/GitHub)
+			oauth := kiroauth.NewKiroOAuth(cfg)
+			return oauth.RefreshToken(ctx, refreshToken)
+		}
+	})
 	if err != nil {
 		return nil, fmt.Errorf("token refresh failed: %w", err)
 	}
+	storedEmail, _ := auth.Metadata["email"].(string)
+	previousAccessToken, _ := auth.Metadata["access_token"].(string)
+	if errIdentity := kiroauth.VerifyRefreshedIdentity(storedEmail, previousAccessToken, tokenData.AccessToken); errIdentity != nil {
+		return nil, fmt.Errorf("token refresh failed: %w", errIdentity)
+	}
 
 	// Parse expires_at
 	expiresAt, err := time.Parse(time.RFC3339, tokenData.ExpiresAt)
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	kiroauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro/kirotest"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func kiroTestJWT(claims map[string]any) string {
+	payload, _ := json.Marshal(claims)
+	return base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte("fake-signature"))
+}
+
+func TestKiroAuthenticatorRefreshUsesOIDCClient(t *testing.T) {
+	mock := kirotest.NewMockSSOOIDCClient()
+	mock.SetRefreshTokenResponse(&kiroauth.KiroTokenData{
+		AccessToken:  kiroTestJWT(map[string]any{"email": "alice@example.com", "sub": "user-alice"}),
+		RefreshToken: "sdk-builder-id-refresh-rotated",
+		ExpiresAt:    time.Now().Add(time.Hour).Format(time.RFC3339),
+	}, nil)
+	auth := &coreauth.Auth{ID: "kiro-alice.json", Provider: "kiro", Metadata: map[string]any{
+		"access_token":  kiroTestJWT(map[string]any{"email": "alice@example.com", "sub": "user-alice"}),
+		"refresh_token": "sdk-builder-id-refresh",
+		"client_id":     "client-id",
+		"client_secret": "client-secret",
+		"auth_method":   "builder-id",
+	}}
+
+	updated, err := NewKiroAuthenticatorWithOIDCClient(mock).Refresh(context.Background(), nil, auth)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if got := updated.Metadata["refresh_token"]; got != "sdk-builder-id-refresh-rotated" {
+		t.Errorf("refresh_token = %v, want the rotated token", got)
+	}
+	calls := mock.Calls()
+	if len(calls) != 1 || calls[0].Method != kirotest.MethodRefreshBuilderIDToken {
+		t.Fatalf("calls = %+v, want one %s", calls, kirotest.MethodRefreshBuilderIDToken)
+	}
+}
+
+func TestKiroAuthenticatorRefreshRejectsDifferentAccount(t *testing.T) {
+	mock := kirotest.NewMockSSOOIDCClient()
+	mock.SetRefreshTokenResponse(&kiroauth.KiroTokenData{
+		AccessToken:  kiroTestJWT(map[string]any{"email": "bob@example.com", "sub": "user-bob"}),
+		RefreshToken: "bob-refresh-token",
+		ExpiresAt:    time.Now().Add(time.Hour).Format(time.RFC3339),
+	}, nil)
+	auth := &coreauth.Auth{ID: "kiro-alice.json", Provider: "kiro", Metadata: map[string]any{
+		"access_token":  kiroTestJWT(map[string]any{"email": "alice@example.com", "sub": "user-alice"}),
+		"refresh_token": "sdk-idc-refresh",
+		"client_id":     "client-id",
+		"client_secret": "client-secret",
+		"auth_method":   "idc",
+		"region":        "eu-west-1",
+		"start_url":     "https://org.awsapps.com/start",
+		"email":         "alice@example.com",
+	}}
+
+	if _, err := NewKiroAuthenticatorWithOIDCClient(mock).Refresh(context.Background(), nil, auth); !errors.Is(err, kiroauth.ErrRefreshIdentityMismatch) {
+		t.Fatalf("Refresh() error = %v, want ErrRefreshIdentityMismatch", err)
+	}
+	calls := mock.Calls()
+	want := []string{"client-id", "client-secret", "sdk-idc-refresh", "eu-west-1", "https://org.awsapps.com/start"}
+	if len(calls) != 1 || calls[0].Method != kirotest.MethodRefreshToken || !reflect.DeepEqual(calls[0].Args, want) {
+		t.Fatalf("calls = %+v, want one %s with %v", calls, kirotest.MethodRefreshToken, want)
+	}
+}
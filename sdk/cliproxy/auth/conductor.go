@@ -1186,6 +1186,8 @@ func (m *Manager) Execute(ctx context.Context, providers []string, req cliproxye
 	if len(normalized) == 0 {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "no provider supplied"}
 	}
+	ctx, cancel, _ := m.withModelTimeout(ctx, req.Model, false)
+	defer cancel()
 
 	_, maxRetryCredentials, maxWait := m.retrySettings()
 
@@ -1221,6 +1223,8 @@ func (m *Manager) ExecuteCount(ctx context.Context, providers []string, req clip
 	if len(normalized) == 0 {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "no provider supplied"}
 	}
+	ctx, cancel, _ := m.withModelTimeout(ctx, req.Model, false)
+	defer cancel()
 
 	_, maxRetryCredentials, maxWait := m.retrySettings()
 
@@ -1252,7 +1256,16 @@ func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cli
 	if len(normalized) == 0 {
 		return nil, &Error{Code: "provider_not_found", Message: "no provider supplied"}
 	}
+	ctx, cancel, timed := m.withModelTimeout(ctx, req.Model, true)
+	result, errStream := m.executeStream(ctx, normalized, req, opts)
+	if errStream != nil || result == nil || !timed {
+		cancel()
+		return result, errStream
+	}
+	return releaseWhenDrained(ctx, result, cancel), nil
+}
 
+func (m *Manager) executeStream(ctx context.Context, normalized []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (*cliproxyexecutor.StreamResult, error) {
 	_, maxRetryCredentials, maxWait := m.retrySettings()
 
 	var lastErr error
@@ -1314,7 +1327,7 @@ func (m *Manager) executeMixedOnce(ctx context.Context, providers []string, req
 		publishSelectedAuthMetadata(opts.Metadata, auth.ID)
 
 		tried[auth.ID] = struct{}{}
-		execCtx := ctx
+		execCtx, executor := withTranslationPreview(ctx, executor, opts)
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
@@ -1392,7 +1405,7 @@ func (m *Manager) executeCountMixedOnce(ctx context.Context, providers []string,
 		publishSelectedAuthMetadata(opts.Metadata, auth.ID)
 
 		tried[auth.ID] = struct{}{}
-		execCtx := ctx
+		execCtx, executor := withTranslationPreview(ctx, executor, opts)
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
@@ -1470,7 +1483,7 @@ func (m *Manager) executeStreamMixedOnce(ctx context.Context, providers []string
 		publishSelectedAuthMetadata(opts.Metadata, auth.ID)
 
 		tried[auth.ID] = struct{}{}
-		execCtx := ctx
+		execCtx, executor := withTranslationPreview(ctx, executor, opts)
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
@@ -2008,9 +2021,10 @@ func waitForCooldown(ctx context.Context, wait time.Duration) error {
 	}
 }
 
-// MarkResult records an execution result and notifies hooks.
+// MarkResult records an execution result and notifies hooks. Results of
+// translation previews never reach a backend and are ignored.
 func (m *Manager) MarkResult(ctx context.Context, result Result) {
-	if result.AuthID == "" {
+	if result.AuthID == "" || isTranslationPreview(ctx) {
 		return
 	}
 
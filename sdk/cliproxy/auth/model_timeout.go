@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// DeriveTimeout returns the deadline configured for modelName in cfg.ModelTimeouts,
+// picking the streaming or non-streaming value of the first matching rule. Zero
+// means the request has no model-specific deadline.
+func DeriveTimeout(modelName string, stream bool, cfg *internalconfig.Config) time.Duration {
+	if cfg == nil {
+		return 0
+	}
+	modelName = strings.ToLower(strings.TrimSpace(modelName))
+	if modelName == "" {
+		return 0
+	}
+	for _, rule := range cfg.ModelTimeouts {
+		if !matchTimeoutPattern(strings.ToLower(strings.TrimSpace(rule.ModelPattern)), modelName) {
+			continue
+		}
+		if stream {
+			return max(rule.StreamingTimeout, 0)
+		}
+		return max(rule.NonStreamingTimeout, 0)
+	}
+	return 0
+}
+
+// matchTimeoutPattern reports whether name matches pattern, where '*' matches
+// any run of characters.
+func matchTimeoutPattern(pattern, name string) bool {
+	if pattern == "" {
+		return false
+	}
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == name
+	}
+	if !strings.HasPrefix(name, parts[0]) {
+		return false
+	}
+	name = name[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(name, part)
+		if idx < 0 {
+			return false
+		}
+		name = name[idx+len(part):]
+	}
+	return strings.HasSuffix(name, last)
+}
+
+// withModelTimeout applies the deadline derived for model to ctx. The returned
+// cancel func is never nil; applied is false when no deadline was configured and
+// ctx is returned unchanged.
+func (m *Manager) withModelTimeout(ctx context.Context, model string, stream bool) (_ context.Context, cancel context.CancelFunc, applied bool) {
+	cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config)
+	timeout := DeriveTimeout(model, stream, cfg)
+	if timeout <= 0 || ctx == nil {
+		return ctx, func() {}, false
+	}
+	ctx, cancel = context.WithTimeout(ctx, timeout)
+	return ctx, cancel, true
+}
+
+// streamErrorSendWait bounds how long releaseWhenDrained waits for a reader to
+// take the final error chunk, so an abandoned stream does not leak the goroutine.
+const streamErrorSendWait = 5 * time.Second
+
+// releaseWhenDrained forwards result's chunks and calls cancel once the stream
+// ends, so a streaming deadline covers reading the whole response. When ctx is
+// done, forwarding stops and a final chunk carrying ctx.Err() tells the reader
+// why the stream ended early.
+func releaseWhenDrained(ctx context.Context, result *cliproxyexecutor.StreamResult, cancel context.CancelFunc) *cliproxyexecutor.StreamResult {
+	if result == nil || result.Chunks == nil {
+		cancel()
+		return result
+	}
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func(in <-chan cliproxyexecutor.StreamChunk) {
+		defer cancel()
+		defer close(out)
+		abort := func() {
+			discardStreamChunks(in)
+			sendStreamError(out, ctx.Err())
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				abort()
+				return
+			case chunk, ok := <-in:
+				if !ok {
+					return
+				}
+				select {
+				case <-ctx.Done():
+					abort()
+					return
+				case out <- chunk:
+				}
+			}
+		}
+	}(result.Chunks)
+	return &cliproxyexecutor.StreamResult{Headers: result.Headers, Chunks: out}
+}
+
+// sendStreamError delivers err as a chunk on out unless no reader takes it
+// within streamErrorSendWait.
+func sendStreamError(out chan<- cliproxyexecutor.StreamChunk, err error) {
+	timer := time.NewTimer(streamErrorSendWait)
+	defer timer.Stop()
+	select {
+	case out <- cliproxyexecutor.StreamChunk{Err: err}:
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func modelTimeoutTestConfig() *internalconfig.Config {
+	return &internalconfig.Config{ModelTimeouts: []internalconfig.ModelTimeout{
+		{ModelPattern: "claude-*", StreamingTimeout: 10 * time.Minute, NonStreamingTimeout: 2 * time.Minute},
+		{ModelPattern: "gemini-*-pro", StreamingTimeout: 5 * time.Minute, NonStreamingTimeout: 90 * time.Second},
+		{ModelPattern: "gemini-*", StreamingTimeout: 3 * time.Minute, NonStreamingTimeout: 30 * time.Second},
+	}}
+}
+
+func TestDeriveTimeout(t *testing.T) {
+	cfg := modelTimeoutTestConfig()
+	tests := []struct {
+		model  string
+		stream bool
+		want   time.Duration
+	}{
+		{"claude-sonnet-4-5", true, 10 * time.Minute},
+		{"claude-sonnet-4-5", false, 2 * time.Minute},
+		{"Claude-Opus-4", false, 2 * time.Minute},
+		{"gemini-2.5-pro", true, 5 * time.Minute},
+		{"gemini-2.5-pro", false, 90 * time.Second},
+		{"gemini-2.5-flash", true, 3 * time.Minute},
+		{"gemini-2.5-flash", false, 30 * time.Second},
+		{"gpt-5", true, 0},
+		{"gpt-5", false, 0},
+		{"", false, 0},
+	}
+	for _, tt := range tests {
+		if got := DeriveTimeout(tt.model, tt.stream, cfg); got != tt.want {
+			t.Errorf("DeriveTimeout(%q, stream=%v) = %v, want %v", tt.model, tt.stream, got, tt.want)
+		}
+	}
+	if got := DeriveTimeout("claude-sonnet-4-5", true, nil); got != 0 {
+		t.Errorf("DeriveTimeout with nil config = %v, want 0", got)
+	}
+}
+
+func TestMatchTimeoutPattern(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"claude-*", "claude-opus-4", true},
+		{"*-pro", "gemini-2.5-pro", true},
+		{"gemini-*-pro", "gemini-2.5-flash", false},
+		{"gpt-5", "gpt-5", true},
+		{"gpt-5", "gpt-5-mini", false},
+		{"*", "anything", true},
+		{"", "anything", false},
+	}
+	for _, tt := range tests {
+		if got := matchTimeoutPattern(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchTimeoutPattern(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+// deadlineExecutor records the deadline of the context each call receives.
+type deadlineExecutor struct {
+	deadline    time.Time
+	hasDeadline bool
+}
+
+func (e *deadlineExecutor) Identifier() string { return "claude" }
+
+func (e *deadlineExecutor) Execute(ctx context.Context, _ *Auth, _ cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.deadline, e.hasDeadline = ctx.Deadline()
+	return cliproxyexecutor.Response{Payload: []byte(`{}`)}, nil
+}
+
+func (e *deadlineExecutor) ExecuteStream(ctx context.Context, _ *Auth, _ cliproxyexecutor.Request, _ cliproxyexecutor.Options) (*cliproxyexecutor.StreamResult, error) {
+	e.deadline, e.hasDeadline = ctx.Deadline()
+	ch := make(chan cliproxyexecutor.StreamChunk, 1)
+	ch <- cliproxyexecutor.StreamChunk{Payload: []byte("data")}
+	close(ch)
+	return &cliproxyexecutor.StreamResult{Chunks: ch}, nil
+}
+
+func (e *deadlineExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) {
+	return auth, nil
+}
+
+func (e *deadlineExecutor) CountTokens(ctx context.Context, _ *Auth, _ cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.deadline, e.hasDeadline = ctx.Deadline()
+	return cliproxyexecutor.Response{Payload: []byte(`{}`)}, nil
+}
+
+func (e *deadlineExecutor) HttpRequest(context.Context, *Auth, *http.Request) (*http.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestManagerAppliesModelTimeout(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	m.SetConfig(modelTimeoutTestConfig())
+	executor := &deadlineExecutor{}
+	m.RegisterExecutor(executor)
+
+	auth := &Auth{ID: uuid.NewString(), Provider: "claude"}
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient(auth.ID, "claude", []*registry.ModelInfo{{ID: "claude-sonnet-4-5"}, {ID: "gpt-5"}})
+	t.Cleanup(func() { reg.UnregisterClient(auth.ID) })
+	if _, errRegister := m.Register(context.Background(), auth); errRegister != nil {
+		t.Fatalf("register auth: %v", errRegister)
+	}
+
+	start := time.Now()
+	if _, errExec := m.Execute(context.Background(), []string{"claude"}, cliproxyexecutor.Request{Model: "claude-sonnet-4-5"}, cliproxyexecutor.Options{}); errExec != nil {
+		t.Fatalf("Execute: %v", errExec)
+	}
+	if !executor.hasDeadline {
+		t.Fatal("Execute context has no deadline")
+	}
+	if got := executor.deadline.Sub(start); got < 2*time.Minute || got > 3*time.Minute {
+		t.Fatalf("Execute deadline in %v, want about 2m", got)
+	}
+
+	start = time.Now()
+	if _, errCount := m.ExecuteCount(context.Background(), []string{"claude"}, cliproxyexecutor.Request{Model: "claude-sonnet-4-5"}, cliproxyexecutor.Options{}); errCount != nil {
+		t.Fatalf("ExecuteCount: %v", errCount)
+	}
+	if got := executor.deadline.Sub(start); !executor.hasDeadline || got < 2*time.Minute || got > 3*time.Minute {
+		t.Fatalf("ExecuteCount deadline in %v (set=%v), want about 2m", got, executor.hasDeadline)
+	}
+
+	result, errStream := m.ExecuteStream(context.Background(), []string{"claude"}, cliproxyexecutor.Request{Model: "claude-sonnet-4-5"}, cliproxyexecutor.Options{Stream: true})
+	if errStream != nil {
+		t.Fatalf("ExecuteStream: %v", errStream)
+	}
+	var chunks int
+	for range result.Chunks {
+		chunks++
+	}
+	if chunks != 1 {
+		t.Fatalf("stream chunks = %d, want 1", chunks)
+	}
+	if got := executor.deadline.Sub(start); !executor.hasDeadline || got < 10*time.Minute || got > 11*time.Minute {
+		t.Fatalf("ExecuteStream deadline in %v (set=%v), want about 10m", got, executor.hasDeadline)
+	}
+
+	if _, errExec := m.Execute(context.Background(), []string{"claude"}, cliproxyexecutor.Request{Model: "gpt-5"}, cliproxyexecutor.Options{}); errExec != nil {
+		t.Fatalf("Execute unknown model: %v", errExec)
+	}
+	if executor.hasDeadline {
+		t.Fatal("unmatched model got a deadline")
+	}
+
+	// ExecuteStream only wraps the stream when a deadline was applied.
+	if _, cancelStream, applied := m.withModelTimeout(context.Background(), "gpt-5", true); applied {
+		cancelStream()
+		t.Fatal("withModelTimeout applied = true for a model without a rule")
+	}
+	_, cancelStream, applied := m.withModelTimeout(context.Background(), "claude-sonnet-4-5", true)
+	cancelStream()
+	if !applied {
+		t.Fatal("withModelTimeout applied = false for a model with a streaming rule")
+	}
+}
+
+func TestReleaseWhenDrainedReportsDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	in := make(chan cliproxyexecutor.StreamChunk, 1)
+	in <- cliproxyexecutor.StreamChunk{Payload: []byte("data")}
+	t.Cleanup(func() { close(in) })
+
+	released := make(chan struct{})
+	result := releaseWhenDrained(ctx, &cliproxyexecutor.StreamResult{Chunks: in}, func() {
+		cancel()
+		close(released)
+	})
+
+	var chunks []cliproxyexecutor.StreamChunk
+	for chunk := range result.Chunks {
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 2 || string(chunks[0].Payload) != "data" {
+		t.Fatalf("chunks = %+v, want the payload followed by an error", chunks)
+	}
+	if !errors.Is(chunks[1].Err, context.DeadlineExceeded) {
+		t.Fatalf("final chunk error = %v, want context.DeadlineExceeded", chunks[1].Err)
+	}
+	<-released
+}
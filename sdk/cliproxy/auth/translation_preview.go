@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+// translationPreviewExecutor wraps a ProviderExecutor for requests carrying
+// cliproxyexecutor.TranslationPreviewMetadataKey. Execute, ExecuteStream and
+// CountTokens translate the request to the provider format and return it
+// without calling the wrapped executor, so no backend is contacted. Payload
+// building that happens inside the wrapped executor is not reflected.
+type translationPreviewExecutor struct {
+	ProviderExecutor
+	sink func([]byte)
+}
+
+// translationPreviewContextKey marks execution contexts of a translation
+// preview, whose results must not change auth state.
+type translationPreviewContextKey struct{}
+
+// translationPreviewSink returns the preview sink carried by opts, or nil.
+func translationPreviewSink(opts cliproxyexecutor.Options) func([]byte) {
+	if opts.Metadata == nil {
+		return nil
+	}
+	sink, _ := opts.Metadata[cliproxyexecutor.TranslationPreviewMetadataKey].(func([]byte))
+	return sink
+}
+
+// withTranslationPreview returns executor wrapped in a translationPreviewExecutor
+// and ctx marked as a preview when opts requests one, and both unchanged otherwise.
+func withTranslationPreview(ctx context.Context, executor ProviderExecutor, opts cliproxyexecutor.Options) (context.Context, ProviderExecutor) {
+	sink := translationPreviewSink(opts)
+	if executor == nil || sink == nil {
+		return ctx, executor
+	}
+	return context.WithValue(ctx, translationPreviewContextKey{}, true), &translationPreviewExecutor{ProviderExecutor: executor, sink: sink}
+}
+
+// isTranslationPreview reports whether ctx belongs to a translation preview.
+func isTranslationPreview(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	preview, _ := ctx.Value(translationPreviewContextKey{}).(bool)
+	return preview
+}
+
+// translate returns the translator output for the provider. The target is the
+// translator format named after the provider; providers without a registered
+// translator get the payload unchanged.
+func (e *translationPreviewExecutor) translate(req cliproxyexecutor.Request, opts cliproxyexecutor.Options) []byte {
+	to := sdktranslator.FromString(e.Identifier())
+	translated := sdktranslator.TranslateRequest(opts.SourceFormat, to, req.Model, req.Payload, opts.Stream)
+	e.sink(translated)
+	return translated
+}
+
+func (e *translationPreviewExecutor) previewHeaders() http.Header {
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/json")
+	return headers
+}
+
+func (e *translationPreviewExecutor) Execute(_ context.Context, _ *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{Payload: e.translate(req, opts), Headers: e.previewHeaders()}, nil
+}
+
+func (e *translationPreviewExecutor) ExecuteStream(_ context.Context, _ *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (*cliproxyexecutor.StreamResult, error) {
+	chunks := make(chan cliproxyexecutor.StreamChunk, 1)
+	chunks <- cliproxyexecutor.StreamChunk{Payload: e.translate(req, opts)}
+	close(chunks)
+	return &cliproxyexecutor.StreamResult{Headers: e.previewHeaders(), Chunks: chunks}, nil
+}
+
+func (e *translationPreviewExecutor) CountTokens(_ context.Context, _ *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{Payload: e.translate(req, opts), Headers: e.previewHeaders()}, nil
+}
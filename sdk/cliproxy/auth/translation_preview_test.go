@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// resultRecordingHook records every result passed to OnResult.
+type resultRecordingHook struct {
+	NoopHook
+	mu      sync.Mutex
+	results []Result
+}
+
+func (h *resultRecordingHook) OnResult(_ context.Context, result Result) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.results = append(h.results, result)
+}
+
+func (h *resultRecordingHook) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.results)
+}
+
+func TestTranslationPreviewDoesNotMarkResults(t *testing.T) {
+	hook := &resultRecordingHook{}
+	m := NewManager(nil, nil, hook)
+	m.RegisterExecutor(&deadlineExecutor{})
+
+	auth := &Auth{ID: uuid.NewString(), Provider: "claude"}
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient(auth.ID, "claude", []*registry.ModelInfo{{ID: "claude-sonnet-4-5"}})
+	t.Cleanup(func() { reg.UnregisterClient(auth.ID) })
+	if _, errRegister := m.Register(context.Background(), auth); errRegister != nil {
+		t.Fatalf("register auth: %v", errRegister)
+	}
+
+	var previews int
+	opts := cliproxyexecutor.Options{Metadata: map[string]any{
+		cliproxyexecutor.TranslationPreviewMetadataKey: func([]byte) { previews++ },
+	}}
+	req := cliproxyexecutor.Request{Model: "claude-sonnet-4-5", Payload: []byte(`{}`)}
+	if _, errExec := m.Execute(context.Background(), []string{"claude"}, req, opts); errExec != nil {
+		t.Fatalf("Execute: %v", errExec)
+	}
+	if _, errCount := m.ExecuteCount(context.Background(), []string{"claude"}, req, opts); errCount != nil {
+		t.Fatalf("ExecuteCount: %v", errCount)
+	}
+	opts.Stream = true
+	result, errStream := m.ExecuteStream(context.Background(), []string{"claude"}, req, opts)
+	if errStream != nil {
+		t.Fatalf("ExecuteStream: %v", errStream)
+	}
+	for range result.Chunks {
+	}
+
+	if previews != 3 {
+		t.Errorf("previews = %d, want 3", previews)
+	}
+	if got := hook.count(); got != 0 {
+		t.Fatalf("translation preview recorded %d results, want 0", got)
+	}
+
+	if _, errExec := m.Execute(context.Background(), []string{"claude"}, req, cliproxyexecutor.Options{}); errExec != nil {
+		t.Fatalf("Execute without preview: %v", errExec)
+	}
+	if got := hook.count(); got != 1 {
+		t.Fatalf("regular execution recorded %d results, want 1", got)
+	}
+}
@@ -62,6 +62,11 @@ type Hooks struct {
 	// OnAfterStart is called after the service has started successfully,
 	// providing access to the service instance for additional operations.
 	OnAfterStart func(*Service)
+
+	// OnKiroAccountKey is called with the source every Kiro account key is derived
+	// from (executor.AccountKeySourceClientID and friends). When nil, the service
+	// logs each source the first time it is used.
+	OnKiroAccountKey func(source string)
 }
 
 // NewBuilder creates a Builder with default dependencies left unset.
@@ -26,6 +26,9 @@ const (
 	SelectedAuthCallbackMetadataKey = "selected_auth_callback"
 	// ExecutionSessionMetadataKey identifies a long-lived downstream execution session.
 	ExecutionSessionMetadataKey = "execution_session_id"
+	// TranslationPreviewMetadataKey marks a translation preview. Its value is a
+	// func([]byte) that receives the translator output; no backend is contacted.
+	TranslationPreviewMetadataKey = "translation_preview"
 )
 
 // Request encapsulates the translated payload that will be sent to a provider executor.
@@ -17,6 +17,10 @@ import (
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/redisqueue"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	antigravitygemini "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/antigravity/gemini"
+	geminicommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
+	geminigemini "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/gemini"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/watcher"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/wsrelay"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
@@ -24,6 +28,8 @@ import (
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	translatorplugin "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator/plugin"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -437,7 +443,7 @@ func (s *Service) ensureExecutorsForAuthWithMode(a *coreauth.Auth, forceReplace
 	case "kimi":
 		s.coreManager.RegisterExecutor(executor.NewKimiExecutor(s.cfg))
 	case "kiro":
-		s.coreManager.RegisterExecutor(executor.NewKiroExecutor(s.cfg))
+		s.coreManager.RegisterExecutor(executor.NewKiroExecutor(s.cfg, s.kiroExecutorOptions()...))
 	case "kilo":
 		s.coreManager.RegisterExecutor(executor.NewKiloExecutor(s.cfg))
 	case "cursor":
@@ -518,6 +524,10 @@ func (s *Service) Run(ctx context.Context) error {
 	}
 
 	s.applyRetryConfig(s.cfg)
+	applyTranslatorConfig(s.cfg.Translator)
+	if errPlugins := s.loadTranslatorPlugins(); errPlugins != nil {
+		return errPlugins
+	}
 
 	if s.coreManager != nil {
 		if errLoad := s.coreManager.Load(ctx); errLoad != nil {
@@ -709,6 +719,7 @@ func (s *Service) Run(ctx context.Context) error {
 			s.coreManager.SetConfig(newCfg)
 			s.coreManager.SetOAuthModelAlias(newCfg.OAuthModelAlias)
 		}
+		executor.PruneGeminiKeyRotation(newCfg)
 		s.rebindExecutors()
 	}
 
@@ -829,6 +840,67 @@ func (s *Service) Shutdown(ctx context.Context) error {
 	return shutdownErr
 }
 
+// kiroExecutorOptions returns the options the Kiro executor is built with.
+func (s *Service) kiroExecutorOptions() []executor.KiroExecutorOption {
+	onAccountKey := s.hooks.OnKiroAccountKey
+	if onAccountKey == nil {
+		onAccountKey = logFirstKiroAccountKeySource()
+	}
+	opts := []executor.KiroExecutorOption{executor.WithAccountKeyMetrics(onAccountKey)}
+	if s.cfg != nil && s.cfg.KiroSigV4Signing {
+		opts = append(opts, executor.WithSigV4Signing(true))
+	}
+	return opts
+}
+
+// logFirstKiroAccountKeySource returns an account key hook that logs each source
+// once, so operators can see which derivation paths are in use.
+func logFirstKiroAccountKeySource() func(source string) {
+	var seen sync.Map
+	return func(source string) {
+		if _, loaded := seen.LoadOrStore(source, struct{}{}); !loaded {
+			log.Infof("kiro: deriving account keys from %s", source)
+		}
+	}
+}
+
+// applyTranslatorConfig sets the translator package defaults from cfg. It runs once,
+// before requests are served, because the translators read them without locking.
+func applyTranslatorConfig(cfg config.TranslatorConfig) {
+	antigravitygemini.DefaultClaudeSystemInstruction = cfg.ClaudeSystemInstruction
+	geminigemini.DefaultThinkingBudget = cfg.DefaultThinkingBudget
+	geminicommon.MaxFunctionDeclarations = cfg.MaxFunctionDeclarations
+	for family, keys := range cfg.UnsupportedGenerationConfigKeys {
+		antigravitygemini.UnsupportedGenerationConfigKeys[strings.ToLower(strings.TrimSpace(family))] = keys
+	}
+	sdktranslator.InvalidateRequestCaches()
+}
+
+// loadTranslatorPlugins registers the translator plugins found in the configured
+// plugin directory. Plugins that fail to load are logged and skipped; a plugin
+// directory configured on a binary without plugin support is a startup error.
+func (s *Service) loadTranslatorPlugins() error {
+	dir, errResolve := util.ResolvePath(strings.TrimSpace(s.cfg.TranslatorPluginDir))
+	if errResolve != nil {
+		log.Warnf("failed to resolve translator plugin dir: %v", errResolve)
+		return nil
+	}
+	if dir == "" {
+		return nil
+	}
+	loaded, errLoad := translatorplugin.NewLoader(dir).Load()
+	if errors.Is(errLoad, translatorplugin.ErrUnsupported) {
+		return fmt.Errorf("translator-plugin-dir is set to %s: %w", dir, errLoad)
+	}
+	if errLoad != nil {
+		log.Warnf("failed to load translator plugins: %v", errLoad)
+	}
+	if len(loaded) > 0 {
+		log.Infof("loaded %d translator plugin(s) from %s", len(loaded), dir)
+	}
+	return nil
+}
+
 func (s *Service) ensureAuthDir() error {
 	info, err := os.Stat(s.cfg.AuthDir)
 	if err != nil {
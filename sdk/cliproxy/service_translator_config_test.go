@@ -0,0 +1,46 @@
+package cliproxy
+
+import (
+	"reflect"
+	"testing"
+
+	antigravitygemini "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/antigravity/gemini"
+	geminicommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
+	geminigemini "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/gemini"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestApplyTranslatorConfig(t *testing.T) {
+	prevInstruction := antigravitygemini.DefaultClaudeSystemInstruction
+	prevBudget := geminigemini.DefaultThinkingBudget
+	prevMax := geminicommon.MaxFunctionDeclarations
+	prevKeys := antigravitygemini.UnsupportedGenerationConfigKeys
+	antigravitygemini.UnsupportedGenerationConfigKeys = map[string][]string{"claude": {"topK", "top_k"}}
+	t.Cleanup(func() {
+		antigravitygemini.DefaultClaudeSystemInstruction = prevInstruction
+		geminigemini.DefaultThinkingBudget = prevBudget
+		geminicommon.MaxFunctionDeclarations = prevMax
+		antigravitygemini.UnsupportedGenerationConfigKeys = prevKeys
+	})
+
+	applyTranslatorConfig(config.TranslatorConfig{
+		ClaudeSystemInstruction:         "Use tools sparingly.",
+		DefaultThinkingBudget:           2048,
+		MaxFunctionDeclarations:         64,
+		UnsupportedGenerationConfigKeys: map[string][]string{"Gemini-3": {"seed"}},
+	})
+
+	if got := antigravitygemini.DefaultClaudeSystemInstruction; got != "Use tools sparingly." {
+		t.Errorf("DefaultClaudeSystemInstruction = %q", got)
+	}
+	if got := geminigemini.DefaultThinkingBudget; got != 2048 {
+		t.Errorf("DefaultThinkingBudget = %d, want 2048", got)
+	}
+	if got := geminicommon.MaxFunctionDeclarations; got != 64 {
+		t.Errorf("MaxFunctionDeclarations = %d, want 64", got)
+	}
+	want := map[string][]string{"claude": {"topK", "top_k"}, "gemini-3": {"seed"}}
+	if got := antigravitygemini.UnsupportedGenerationConfigKeys; !reflect.DeepEqual(got, want) {
+		t.Errorf("UnsupportedGenerationConfigKeys = %v, want %v", got, want)
+	}
+}
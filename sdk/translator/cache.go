@@ -0,0 +1,133 @@
+package translator
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+)
+
+// requestCacheGeneration is part of every cache key; bumping it orphans all
+// cached entries.
+var requestCacheGeneration atomic.Uint64
+
+// InvalidateRequestCaches makes every RequestCache miss on its existing entries.
+// Translations also depend on the translator defaults set from config (default
+// thinking budget, Claude system instruction, function declaration limit,
+// unsupported generationConfig keys); whoever changes them must call this so
+// cached output computed with the old values is not served.
+func InvalidateRequestCaches() {
+	requestCacheGeneration.Add(1)
+}
+
+// RequestCache is a bounded LRU cache of translated request payloads keyed by a
+// hash of the model name, stream flag, raw request bytes and the cache
+// generation (see InvalidateRequestCaches).
+type RequestCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[[sha256.Size]byte]*list.Element
+	order   *list.List
+}
+
+type requestCacheEntry struct {
+	key    [sha256.Size]byte
+	output []byte
+}
+
+// NewRequestCache creates a cache that holds at most size translated requests.
+// A non-positive size returns nil, which disables caching.
+func NewRequestCache(size int) *RequestCache {
+	if size <= 0 {
+		return nil
+	}
+	return &RequestCache{
+		size:    size,
+		entries: make(map[[sha256.Size]byte]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+// Wrap returns a RequestTransform that serves identical requests from the cache
+// and only calls fn on a miss. fn must be deterministic: the same model, payload
+// and stream flag must always produce the same output. A nil cache returns fn.
+func (c *RequestCache) Wrap(fn RequestTransform) RequestTransform {
+	if c == nil || fn == nil {
+		return fn
+	}
+	return func(model string, rawJSON []byte, stream bool) []byte {
+		key := requestCacheKey(model, rawJSON, stream)
+		if output, ok := c.get(key); ok {
+			return output
+		}
+		output := fn(model, rawJSON, stream)
+		c.put(key, output)
+		return output
+	}
+}
+
+// Len returns the number of cached entries.
+func (c *RequestCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *RequestCache) get(key [sha256.Size]byte) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	// Return a copy so callers that modify the payload in place cannot corrupt the cache.
+	return append([]byte(nil), elem.Value.(*requestCacheEntry).output...), true
+}
+
+func (c *RequestCache) put(key [sha256.Size]byte, output []byte) {
+	stored := append([]byte(nil), output...)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*requestCacheEntry).output = stored
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&requestCacheEntry{key: key, output: stored})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*requestCacheEntry).key)
+	}
+}
+
+// requestCacheKey hashes the key components, each variable-length one behind its
+// length so different (model, rawJSON) splits of the same bytes cannot collide.
+func requestCacheKey(model string, rawJSON []byte, stream bool) [sha256.Size]byte {
+	h := sha256.New()
+	var buf [8]byte
+	writeLen := func(n int) {
+		binary.BigEndian.PutUint64(buf[:], uint64(n))
+		h.Write(buf[:])
+	}
+	binary.BigEndian.PutUint64(buf[:], requestCacheGeneration.Load())
+	h.Write(buf[:])
+	writeLen(len(model))
+	h.Write([]byte(model))
+	if stream {
+		h.Write([]byte{1})
+	} else {
+		h.Write([]byte{0})
+	}
+	writeLen(len(rawJSON))
+	h.Write(rawJSON)
+	var key [sha256.Size]byte
+	copy(key[:], h.Sum(nil))
+	return key
+}
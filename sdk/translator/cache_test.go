@@ -0,0 +1,130 @@
+package translator
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tidwall/sjson"
+)
+
+func countingTransform(calls *int) RequestTransform {
+	return func(model string, rawJSON []byte, stream bool) []byte {
+		*calls++
+		out, _ := sjson.SetBytes(rawJSON, "model", model)
+		return out
+	}
+}
+
+func TestRequestCache_HitsSkipTranslation(t *testing.T) {
+	calls := 0
+	fn := NewRequestCache(4).Wrap(countingTransform(&calls))
+	raw := []byte(`{"contents":[]}`)
+
+	first := fn("m", raw, false)
+	second := fn("m", raw, false)
+	if calls != 1 {
+		t.Fatalf("transform called %d times, want 1", calls)
+	}
+	if !bytes.Equal(first, second) {
+		t.Fatalf("cached output %s != fresh output %s", second, first)
+	}
+
+	fn("m", raw, true)
+	fn("other", raw, false)
+	if calls != 3 {
+		t.Fatalf("stream flag and model must be part of the key: calls = %d, want 3", calls)
+	}
+}
+
+func TestRequestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	calls := 0
+	cache := NewRequestCache(2)
+	fn := cache.Wrap(countingTransform(&calls))
+
+	fn("a", []byte(`{}`), false)
+	fn("b", []byte(`{}`), false)
+	fn("a", []byte(`{}`), false) // refresh a
+	fn("c", []byte(`{}`), false) // evicts b
+	if cache.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", cache.Len())
+	}
+
+	calls = 0
+	fn("a", []byte(`{}`), false)
+	fn("b", []byte(`{}`), false)
+	if calls != 1 {
+		t.Fatalf("after eviction calls = %d, want 1 (only b re-translated)", calls)
+	}
+}
+
+func TestRequestCache_ReturnsCopies(t *testing.T) {
+	calls := 0
+	fn := NewRequestCache(1).Wrap(countingTransform(&calls))
+	out := fn("m", []byte(`{}`), false)
+	want := string(out)
+	out[0] = 'X'
+	if got := string(fn("m", []byte(`{}`), false)); got != want {
+		t.Fatalf("cached output mutated: got %s, want %s", got, want)
+	}
+}
+
+func TestNewRequestCache_DisabledForNonPositiveSize(t *testing.T) {
+	calls := 0
+	fn := NewRequestCache(0).Wrap(countingTransform(&calls))
+	fn("m", []byte(`{}`), false)
+	fn("m", []byte(`{}`), false)
+	if calls != 2 {
+		t.Fatalf("disabled cache should not memoize: calls = %d, want 2", calls)
+	}
+}
+
+func BenchmarkRequestCache(b *testing.B) {
+	raw := []byte(`{"contents":[{"role":"user","parts":[{"text":"hello"}]}],"generationConfig":{"temperature":0.5}}`)
+	translate := func(model string, rawJSON []byte, stream bool) []byte {
+		out := rawJSON
+		for i := 0; i < 50; i++ {
+			out, _ = sjson.SetBytes(out, "generationConfig.temperature", float64(i)/100)
+		}
+		return out
+	}
+
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			translate("m", raw, false)
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		calls := 0
+		fn := NewRequestCache(16).Wrap(func(model string, rawJSON []byte, stream bool) []byte {
+			calls++
+			return translate(model, rawJSON, stream)
+		})
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			fn("m", raw, false)
+		}
+		b.StopTimer()
+		if calls != 1 {
+			b.Fatalf("transform called %d times, want 1", calls)
+		}
+	})
+}
+
+func TestRequestCache_KeySeparatesComponents(t *testing.T) {
+	if requestCacheKey("ab", []byte("c"), false) == requestCacheKey("a", []byte("bc"), false) {
+		t.Fatal("model and payload boundaries must be part of the key")
+	}
+}
+
+func TestRequestCache_InvalidateRequestCaches(t *testing.T) {
+	calls := 0
+	fn := NewRequestCache(4).Wrap(countingTransform(&calls))
+	raw := []byte(`{"contents":[]}`)
+
+	fn("m", raw, false)
+	InvalidateRequestCaches()
+	fn("m", raw, false)
+	if calls != 2 {
+		t.Fatalf("transform called %d times after invalidation, want 2", calls)
+	}
+}
@@ -0,0 +1,136 @@
+// Package plugin loads translator plugins built with -buildmode=plugin and
+// registers them in a translator registry, so operators can add translation
+// logic for proprietary backends without recompiling the proxy.
+//
+// A plugin is a package main that exports a TranslatorPlugin variable whose
+// value implements TranslatorFunc; see examples/plugin_template.
+//
+// Plugins only work in cgo builds on Linux, macOS and FreeBSD, and a plugin must
+// be built with the same Go toolchain and module versions as the proxy. The
+// release binaries and Docker image are built with CGO_ENABLED=0 and cannot
+// load plugins; see Supported.
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	goplugin "plugin"
+	"sort"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+)
+
+// SymbolName is the exported symbol the loader looks up in every plugin.
+const SymbolName = "TranslatorPlugin"
+
+// ErrUnsupported is returned by Load when the binary was built without plugin
+// support (for example with CGO_ENABLED=0).
+var ErrUnsupported = errors.New("translator plugins: this binary was built without plugin support (requires cgo on linux, darwin or freebsd)")
+
+// TranslatorFunc is the contract a translator plugin implements. Formats names
+// the client schema (from) and the backend schema (to); the request is
+// translated from -> to and responses are translated back to -> from.
+type TranslatorFunc interface {
+	Formats() (from, to translator.Format)
+	TranslateRequest(model string, rawJSON []byte, stream bool) []byte
+	Response() translator.ResponseTransform
+}
+
+// symbolLookup is the part of *plugin.Plugin the loader uses.
+type symbolLookup interface {
+	Lookup(symName string) (goplugin.Symbol, error)
+}
+
+// Loader loads every .so file in Dir and registers its TranslatorPlugin.
+type Loader struct {
+	// Dir is the directory scanned for plugins; subdirectories are not scanned.
+	Dir string
+	// Registry receives the translators. Nil uses translator.Default().
+	Registry *translator.Registry
+
+	open func(path string) (symbolLookup, error)
+}
+
+// NewLoader creates a Loader for dir that registers into the default registry.
+func NewLoader(dir string) *Loader {
+	return &Loader{Dir: dir}
+}
+
+// Load opens the plugins in Dir in name order and registers each one. A plugin
+// that fails to open or does not export a valid TranslatorPlugin is skipped and
+// its error is included in the returned error; the others are still loaded.
+// It returns the paths of the plugins that were registered, or ErrUnsupported
+// when the binary cannot load plugins at all.
+func (l *Loader) Load() ([]string, error) {
+	if l.open == nil && !Supported {
+		return nil, ErrUnsupported
+	}
+	entries, errRead := os.ReadDir(l.Dir)
+	if errRead != nil {
+		return nil, fmt.Errorf("translator plugins: read %s: %w", l.Dir, errRead)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".so") {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	registry := l.Registry
+	if registry == nil {
+		registry = translator.Default()
+	}
+	open := l.open
+	if open == nil {
+		open = openPlugin
+	}
+
+	var loaded []string
+	var errs []error
+	for _, name := range names {
+		path := filepath.Join(l.Dir, name)
+		fn, errLoad := lookupTranslator(open, path)
+		if errLoad != nil {
+			errs = append(errs, errLoad)
+			continue
+		}
+		from, to := fn.Formats()
+		if from == "" || to == "" {
+			errs = append(errs, fmt.Errorf("translator plugin %s: empty source or target format", path))
+			continue
+		}
+		registry.Register(from, to, fn.TranslateRequest, fn.Response())
+		log.Infof("translator plugin %s registered for %s -> %s", name, from, to)
+		loaded = append(loaded, path)
+	}
+	return loaded, errors.Join(errs...)
+}
+
+// lookupTranslator opens path and returns its TranslatorPlugin. The symbol may
+// be declared with the TranslatorFunc interface type or with a concrete type
+// whose pointer implements it.
+func lookupTranslator(open func(string) (symbolLookup, error), path string) (TranslatorFunc, error) {
+	p, errOpen := open(path)
+	if errOpen != nil {
+		return nil, fmt.Errorf("translator plugin %s: open: %w", path, errOpen)
+	}
+	sym, errLookup := p.Lookup(SymbolName)
+	if errLookup != nil {
+		return nil, fmt.Errorf("translator plugin %s: %w", path, errLookup)
+	}
+	switch v := sym.(type) {
+	case *TranslatorFunc:
+		if v != nil && *v != nil {
+			return *v, nil
+		}
+	case TranslatorFunc:
+		return v, nil
+	}
+	return nil, fmt.Errorf("translator plugin %s: %s is %T, want plugin.TranslatorFunc", path, SymbolName, sym)
+}
@@ -0,0 +1,139 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	goplugin "plugin"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+type fakeTranslator struct{ to string }
+
+func (f fakeTranslator) Formats() (from, to translator.Format) {
+	return translator.FormatOpenAI, translator.FromString(f.to)
+}
+
+func (f fakeTranslator) TranslateRequest(model string, rawJSON []byte, _ bool) []byte {
+	return []byte(`{"translated_for":"` + f.to + `","model":"` + model + `"}`)
+}
+
+func (f fakeTranslator) Response() translator.ResponseTransform {
+	return translator.ResponseTransform{
+		NonStream: func(context.Context, string, []byte, []byte, []byte, *any) []byte {
+			return []byte(`{"from":"` + f.to + `"}`)
+		},
+	}
+}
+
+// fakePlugin mimics *plugin.Plugin by serving symbols from a map.
+type fakePlugin map[string]goplugin.Symbol
+
+func (p fakePlugin) Lookup(name string) (goplugin.Symbol, error) {
+	if sym, ok := p[name]; ok {
+		return sym, nil
+	}
+	return nil, errors.New("symbol " + name + " not found")
+}
+
+func newTestLoader(t *testing.T, plugins map[string]symbolLookup, extraFiles ...string) (*Loader, *translator.Registry) {
+	t.Helper()
+	dir := t.TempDir()
+	for name := range plugins {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range extraFiles {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	registry := translator.NewRegistry()
+	loader := &Loader{
+		Dir:      dir,
+		Registry: registry,
+		open: func(path string) (symbolLookup, error) {
+			p, ok := plugins[filepath.Base(path)]
+			if !ok {
+				return nil, errors.New("unexpected plugin " + path)
+			}
+			return p, nil
+		},
+	}
+	return loader, registry
+}
+
+func TestLoaderRegistersTranslators(t *testing.T) {
+	var ifaceVar TranslatorFunc = fakeTranslator{to: "acme"}
+	concrete := &fakeTranslator{to: "globex"}
+	loader, registry := newTestLoader(t, map[string]symbolLookup{
+		"acme.so":   fakePlugin{SymbolName: &ifaceVar},
+		"globex.so": fakePlugin{SymbolName: concrete},
+	}, "README.md", "notes.so.txt")
+
+	loaded, err := loader.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(loaded) != 2 || filepath.Base(loaded[0]) != "acme.so" || filepath.Base(loaded[1]) != "globex.so" {
+		t.Fatalf("loaded = %v", loaded)
+	}
+
+	for _, target := range []string{"acme", "globex"} {
+		got := registry.TranslateRequest(translator.FormatOpenAI, translator.FromString(target), "m1", []byte(`{}`), false)
+		if want := `{"translated_for":"` + target + `","model":"m1"}`; string(got) != want {
+			t.Errorf("request for %s = %s, want %s", target, got, want)
+		}
+		// Responses are looked up backend -> client, as executors call it.
+		resp := registry.TranslateNonStream(context.Background(), translator.FromString(target), translator.FormatOpenAI, "m1", nil, nil, []byte(`{}`), nil)
+		if want := `{"from":"` + target + `"}`; string(resp) != want {
+			t.Errorf("response for %s = %s, want %s", target, resp, want)
+		}
+	}
+}
+
+func TestLoaderSkipsInvalidPlugins(t *testing.T) {
+	var good TranslatorFunc = fakeTranslator{to: "acme"}
+	wrongType := "not a translator"
+	loader, registry := newTestLoader(t, map[string]symbolLookup{
+		"a-missing.so": fakePlugin{},
+		"b-wrong.so":   fakePlugin{SymbolName: &wrongType},
+		"c-good.so":    fakePlugin{SymbolName: &good},
+	})
+
+	loaded, err := loader.Load()
+	if len(loaded) != 1 || filepath.Base(loaded[0]) != "c-good.so" {
+		t.Fatalf("loaded = %v, want only c-good.so", loaded)
+	}
+	if err == nil {
+		t.Fatal("expected an error describing the invalid plugins")
+	}
+	for _, name := range []string{"a-missing.so", "b-wrong.so"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error %q does not mention %s", err, name)
+		}
+	}
+	if got := registry.TranslateRequest(translator.FormatOpenAI, "acme", "m", []byte(`{}`), false); !strings.Contains(string(got), "acme") {
+		t.Errorf("valid plugin not registered: %s", got)
+	}
+}
+
+func TestLoaderMissingDir(t *testing.T) {
+	if _, err := NewLoader(filepath.Join(t.TempDir(), "absent")).Load(); err == nil {
+		t.Fatal("expected an error for a missing directory")
+	}
+}
+
+func TestLoaderRejectsBinaryWithoutPluginSupport(t *testing.T) {
+	if Supported {
+		t.Skip("binary supports plugins")
+	}
+	if _, err := NewLoader(t.TempDir()).Load(); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("Load() error = %v, want ErrUnsupported", err)
+	}
+}
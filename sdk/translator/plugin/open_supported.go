@@ -0,0 +1,12 @@
+//go:build cgo && (linux || darwin || freebsd)
+
+package plugin
+
+import goplugin "plugin"
+
+// Supported reports whether this binary can load plugins.
+const Supported = true
+
+func openPlugin(path string) (symbolLookup, error) {
+	return goplugin.Open(path)
+}
@@ -0,0 +1,11 @@
+//go:build !cgo || !(linux || darwin || freebsd)
+
+package plugin
+
+// Supported reports whether this binary can load plugins. Go only supports
+// plugins in cgo builds on Linux, macOS and FreeBSD.
+const Supported = false
+
+func openPlugin(path string) (symbolLookup, error) {
+	return nil, ErrUnsupported
+}
@@ -2,6 +2,7 @@ package translator
 
 import (
 	"context"
+	"sort"
 	"sync"
 
 	log "github.com/sirupsen/logrus"
@@ -65,6 +66,35 @@ func (r *Registry) TranslateRequest(from, to Format, model string, rawJSON []byt
 	return rawJSON
 }
 
+// FormatPair identifies a translation direction between two formats.
+type FormatPair struct {
+	From Format
+	To   Format
+}
+
+// RequestPairs returns every direction with a registered request translator,
+// sorted by source and then target format.
+func (r *Registry) RequestPairs() []FormatPair {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var pairs []FormatPair
+	for from, byTarget := range r.requests {
+		for to, fn := range byTarget {
+			if fn != nil {
+				pairs = append(pairs, FormatPair{From: from, To: to})
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].From != pairs[j].From {
+			return pairs[i].From < pairs[j].From
+		}
+		return pairs[i].To < pairs[j].To
+	})
+	return pairs
+}
+
 // HasResponseTransformer indicates whether a response translator exists.
 func (r *Registry) HasResponseTransformer(from, to Format) bool {
 	r.mu.RLock()
@@ -134,6 +164,11 @@ func TranslateRequest(from, to Format, model string, rawJSON []byte, stream bool
 	return defaultRegistry.TranslateRequest(from, to, model, rawJSON, stream)
 }
 
+// RequestPairs lists the request translation directions in the default registry.
+func RequestPairs() []FormatPair {
+	return defaultRegistry.RequestPairs()
+}
+
 // HasResponseTransformer inspects the default registry.
 func HasResponseTransformer(from, to Format) bool {
 	return defaultRegistry.HasResponseTransformer(from, to)
@@ -90,3 +90,23 @@ func TestTranslateRequest_RegisteredTransformTakesPrecedence(t *testing.T) {
 		t.Errorf("expected registered transform to take precedence, got model = %q", gotModel)
 	}
 }
+
+func TestRegistryRequestPairsSorted(t *testing.T) {
+	r := NewRegistry()
+	noop := func(_ string, raw []byte, _ bool) []byte { return raw }
+	r.Register(Format("b"), Format("a"), noop, ResponseTransform{})
+	r.Register(Format("a"), Format("c"), noop, ResponseTransform{})
+	r.Register(Format("a"), Format("b"), noop, ResponseTransform{})
+	r.Register(Format("c"), Format("a"), nil, ResponseTransform{})
+
+	got := r.RequestPairs()
+	want := []FormatPair{{"a", "b"}, {"a", "c"}, {"b", "a"}}
+	if len(got) != len(want) {
+		t.Fatalf("RequestPairs() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("RequestPairs() = %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
+
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+// TestAllTranslatorsOutputValidJSON runs every registered request translator over
+// the fixtures in testdata/translator_inputs/<source format>/ and checks that each
+// output is valid JSON.
+func TestAllTranslatorsOutputValidJSON(t *testing.T) {
+	pairs := sdktranslator.RequestPairs()
+	if len(pairs) == 0 {
+		t.Fatal("no request translators registered")
+	}
+
+	for _, pair := range pairs {
+		fixtures, err := filepath.Glob(filepath.Join("testdata", "translator_inputs", pair.From.String(), "*.json"))
+		if err != nil {
+			t.Fatalf("glob fixtures for %s: %v", pair.From, err)
+		}
+		if len(fixtures) == 0 {
+			t.Errorf("no fixtures for source format %s; add some to testdata/translator_inputs/%s", pair.From, pair.From)
+			continue
+		}
+
+		pair, fixtures := pair, fixtures
+		t.Run(pair.From.String()+"->"+pair.To.String(), func(t *testing.T) {
+			t.Parallel()
+			for _, fixture := range fixtures {
+				input, errRead := os.ReadFile(fixture)
+				if errRead != nil {
+					t.Fatalf("read %s: %v", fixture, errRead)
+				}
+				if !json.Valid(input) {
+					t.Fatalf("fixture %s is not valid JSON", fixture)
+				}
+				for _, stream := range []bool{false, true} {
+					out := sdktranslator.TranslateRequest(pair.From, pair.To, "test-model", append([]byte(nil), input...), stream)
+					if !json.Valid(out) {
+						t.Errorf("%s (stream=%v): output is not valid JSON:\n%s", filepath.Base(fixture), stream, out)
+					}
+				}
+			}
+		})
+	}
+}